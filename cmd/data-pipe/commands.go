@@ -0,0 +1,385 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/config"
+	"github.com/IEatCodeDaily/data-pipe/pkg/dlq"
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"github.com/IEatCodeDaily/data-pipe/pkg/sink"
+	"github.com/IEatCodeDaily/data-pipe/pkg/source"
+)
+
+// Commands of the data-pipe binary
+const (
+	cmdRun         = "run"
+	cmdValidate    = "validate"
+	cmdSyncOnce    = "sync-once"
+	cmdResync      = "resync"
+	cmdVerify      = "verify"
+	cmdDLQReplay   = "dlq replay"
+	cmdBench       = "bench"
+	cmdInit        = "init"
+	cmdInferSchema = "infer-schema"
+	cmdREPL        = "repl"
+	cmdReplay      = "replay"
+	cmdSample      = "sample"
+	cmdCutover     = "cutover"
+)
+
+// Exit codes of the verify command
+const (
+	exitVerifyFailed   = 1 // the counts could not be read
+	exitVerifyMismatch = 3 // the source and sink counts differ
+)
+
+// options are the parsed command line
+type options struct {
+	command    string
+	configPath string
+	profile    string // overlay merged onto the configuration, see config.LoadProfile
+
+	// run
+	configRefresh  time.Duration
+	logFile        string
+	reloadOnSIGHUP bool
+	startAt        string // RFC 3339 time the source starts at, once
+	startAfter     string // position the source starts after, once
+	stopAt         string // RFC 3339 time change data capture ends at
+	stopWhenIdle   time.Duration
+
+	// run -capture and replay
+	captureFile     string
+	captureDuration time.Duration
+	captureEvents   int
+	replayInput     string
+	replayOutput    string
+
+	// cutover
+	catchUpIdle time.Duration
+
+	// sample
+	sampleCount     int
+	sampleAnonymize bool
+	sampleOutput    string
+
+	// dlq replay
+	replay          dlq.ReplayOptions
+	replayTransform bool
+
+	// bench
+	benchEvents    int
+	benchFields    int
+	benchFieldSize int
+	benchUseSink   bool
+
+	// init and infer-schema
+	scaffold initOptions
+	infer    inferOptions
+}
+
+// command describes a subcommand and registers its own flags
+type command struct {
+	name    string
+	args    string // usage of the command's flags
+	summary string
+	flags   func(fs *flag.FlagSet, opts *options)
+
+	// standalone commands do not read a configuration file
+	standalone bool
+}
+
+var commands = []command{
+	{name: cmdRun, args: "[-config file|uri] [-profile name] [-config-refresh interval] [-log-file path] [-reload-on-sighup] [-capture file] [-capture-duration d] [-capture-events n] [-start-at time | -start-after position] [-stop-at time] [-stop-when-idle d]", summary: "Run the pipeline: initial sync if configured, then change data capture (default)", flags: func(fs *flag.FlagSet, opts *options) {
+		fs.DurationVar(&opts.configRefresh, "config-refresh", 0, "Fetch the configuration this often and restart the pipeline when it changes, e.g. 5m (default: 0, never)")
+		fs.StringVar(&opts.logFile, "log-file", "", "Append logs to this file instead of stdout; SIGHUP reopens it after rotation")
+		fs.BoolVar(&opts.reloadOnSIGHUP, "reload-on-sighup", false, "Also restart the pipeline with the configuration on SIGHUP, if it is valid")
+		fs.StringVar(&opts.captureFile, "capture", "", "Tee the raw change events read from the source to this JSON-lines file (.gz and .zst are compressed), for the replay command")
+		fs.DurationVar(&opts.captureDuration, "capture-duration", 10*time.Minute, "Stop capturing after this long (0: until the pipeline stops)")
+		fs.IntVar(&opts.captureEvents, "capture-events", 0, "Stop capturing after this many events (default: 0, no limit)")
+		fs.StringVar(&opts.startAt, "start-at", "", "Start change data capture at this RFC 3339 time instead of the checkpoint, e.g. to backfill from the start of an outage")
+		fs.StringVar(&opts.startAfter, "start-after", "", "Start change data capture after this position (a resume token as logged or checkpointed) instead of the checkpoint")
+		fs.StringVar(&opts.stopAt, "stop-at", "", "End change data capture at the first change made at or after this RFC 3339 time")
+		fs.DurationVar(&opts.stopWhenIdle, "stop-when-idle", 0, "End change data capture once no change has arrived for this long, i.e. it has caught up (default: 0, never)")
+	}},
+	{name: cmdCutover, args: "[-config file] [-profile name] [-catch-up-idle d]", summary: "Migrate: initial sync, then change data capture until SIGUSR1, then catch up with the stopped source, verify and exit", flags: func(fs *flag.FlagSet, opts *options) {
+		fs.DurationVar(&opts.catchUpIdle, "catch-up-idle", 10*time.Second, "After SIGUSR1, the source counts as caught up once no change has arrived for this long")
+	}},
+	{name: cmdValidate, args: "[-config file] [-profile name]", summary: "Check the configuration and build every component without starting the pipeline"},
+	{name: cmdSyncOnce, args: "[-config file] [-profile name]", summary: "Perform a single sync, print a JSON summary and exit"},
+	{name: cmdResync, args: "[-config file] [-profile name]", summary: "Like sync-once, but re-copy every document as with force_initial_sync"},
+	{name: cmdVerify, args: "[-config file] [-profile name]", summary: "Compare the source's document count with the sink's row count"},
+	{name: cmdDLQReplay, args: "[-config file] [-profile name] [-pipeline name] [-filter regexp] [-transform]", summary: "Re-send dead-lettered events to the sink", flags: func(fs *flag.FlagSet, opts *options) {
+		fs.StringVar(&opts.replay.Pipeline, "pipeline", "", "Replay only this pipeline's entries (default: the configured pipeline)")
+		fs.StringVar(&opts.replay.Filter, "filter", "", "Replay only entries whose ID, event ID or reason matches this regular expression")
+		fs.BoolVar(&opts.replayTransform, "transform", false, "Re-run the configured transformer before the sink")
+	}},
+	{name: cmdBench, args: "[-config file] [-profile name] [-events n] [-fields n] [-field-size n] [-use-sink]", summary: "Measure throughput of the transformer and sink with generated events", flags: func(fs *flag.FlagSet, opts *options) {
+		fs.IntVar(&opts.benchEvents, "events", 100000, "Number of events to generate")
+		fs.IntVar(&opts.benchFields, "fields", 10, "Data fields per generated event")
+		fs.IntVar(&opts.benchFieldSize, "field-size", 16, "Length of each generated string value")
+		fs.BoolVar(&opts.benchUseSink, "use-sink", false, "Write to the configured sink instead of discarding events")
+	}},
+	{name: cmdReplay, args: "[-config file] [-profile name] -input file [-output file]", summary: "Feed events captured with run -capture through the configured transformer and sink", flags: func(fs *flag.FlagSet, opts *options) {
+		fs.StringVar(&opts.replayInput, "input", "", "Capture file to replay")
+		fs.StringVar(&opts.replayOutput, "output", "", "Write the transformed events to this JSON-lines file instead of the configured sink")
+	}},
+	{name: cmdSample, args: "[-config file] [-profile name] [-count n] [-anonymize] [-output file]", summary: "Export sample documents of the source as events, anonymized with the pipeline.pii rules", flags: func(fs *flag.FlagSet, opts *options) {
+		fs.IntVar(&opts.sampleCount, "count", 100, "Number of documents to sample")
+		fs.BoolVar(&opts.sampleAnonymize, "anonymize", false, "Mask personal data with the pipeline.pii rules")
+		fs.StringVar(&opts.sampleOutput, "output", "", "JSON-lines file to write, readable by replay and the file source (default: stdout)")
+	}},
+	{name: cmdREPL, args: "[-config file] [-profile name]", summary: "Transform pasted JSON documents with the configured transformer and show the SQL written for them"},
+	{name: cmdInit, args: "[-source mongodb] [-sink postgresql] -uri uri -database name -collection name [-connection-string dsn] [-table name] [-sample n] [-output file] [-force]", summary: "Sample a collection and write a starter configuration", standalone: true, flags: func(fs *flag.FlagSet, opts *options) {
+		fs.StringVar(&opts.scaffold.source, "source", "mongodb", "Source type")
+		fs.StringVar(&opts.scaffold.sink, "sink", "postgresql", "Sink type")
+		sampleFlags(fs, &opts.scaffold.sampleOptions)
+		fs.StringVar(&opts.scaffold.connectionString, "connection-string", "", "PostgreSQL connection string (default: a placeholder to edit)")
+		fs.StringVar(&opts.scaffold.table, "table", "", "PostgreSQL table (default: the collection name in snake_case)")
+		fs.StringVar(&opts.scaffold.output, "output", "config.json", "Path of the configuration file to write")
+		fs.BoolVar(&opts.scaffold.force, "force", false, "Overwrite the output file if it exists")
+	}},
+	{name: cmdInferSchema, args: "-uri uri -database name -collection name [-sample n] [-depth n] [-table name] [-key-fields list] [-format all|mapping|schema|sql]", summary: "Sample a collection and print a field mapping, schema and CREATE TABLE statement", standalone: true, flags: func(fs *flag.FlagSet, opts *options) {
+		sampleFlags(fs, &opts.infer.sampleOptions)
+		fs.IntVar(&opts.infer.depth, "depth", 1, "Levels of nested documents to describe field by field")
+		fs.StringVar(&opts.infer.table, "table", "", "Table of the CREATE TABLE statement (default: the collection name in snake_case)")
+		fs.StringVar(&opts.infer.keyFields, "key-fields", "_id", "Comma-separated primary key columns")
+		fs.StringVar(&opts.infer.format, "format", "all", "Output: all, mapping, schema or sql")
+	}},
+}
+
+// sampleFlags registers the flags selecting the documents to sample
+func sampleFlags(fs *flag.FlagSet, opts *sampleOptions) {
+	fs.StringVar(&opts.uri, "uri", "", "MongoDB connection URI")
+	fs.StringVar(&opts.database, "database", "", "MongoDB database")
+	fs.StringVar(&opts.collection, "collection", "", "MongoDB collection to sample")
+	fs.IntVar(&opts.sample, "sample", 100, "Number of documents to sample")
+}
+
+// parseCommandLine parses the subcommand and its flags, exiting on errors and
+// on help. Without a subcommand, or when the first argument is a flag, the
+// pipeline is run, as before subcommands existed.
+func parseCommandLine(args []string) options {
+	name := cmdRun
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+		if name == "dlq" {
+			if len(args) == 0 || args[0] != "replay" {
+				fmt.Fprintln(os.Stderr, "usage: data-pipe dlq replay [flags]")
+				os.Exit(2)
+			}
+			name, args = cmdDLQReplay, args[1:]
+		}
+	}
+	if name == "help" {
+		printUsage(os.Stdout)
+		os.Exit(0)
+	}
+
+	for _, c := range commands {
+		if c.name != name {
+			continue
+		}
+		opts := options{command: name}
+		fs := flag.NewFlagSet("data-pipe "+name, flag.ExitOnError)
+		if !c.standalone {
+			fs.StringVar(&opts.configPath, "config", "config.json", "Path to configuration file, or an http(s)://, s3:// or consul:// URI")
+			fs.StringVar(&opts.profile, "profile", os.Getenv("DATA_PIPE_PROFILE"), "Profile whose overlay, e.g. config.prod.json, is merged onto the configuration (default: $DATA_PIPE_PROFILE)")
+		}
+		if c.flags != nil {
+			c.flags(fs, &opts)
+		}
+		fs.Parse(args)
+		if fs.NArg() > 0 {
+			fmt.Fprintf(os.Stderr, "data-pipe %s: unexpected argument %q\n", name, fs.Arg(0))
+			os.Exit(2)
+		}
+		return opts
+	}
+
+	fmt.Fprintf(os.Stderr, "data-pipe: unknown command %q\n\n", name)
+	printUsage(os.Stderr)
+	os.Exit(2)
+	return options{}
+}
+
+// printUsage lists the commands
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: data-pipe <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", c.name, c.summary)
+		fmt.Fprintf(w, "  %-12s   data-pipe %s %s\n", "", c.name, c.args)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run \"data-pipe <command> -h\" for the flags of a command.")
+}
+
+// applyCommand adjusts the configuration for the command being run
+func applyCommand(cfg *config.Config, opts options) {
+	switch opts.command {
+	case cmdResync:
+		cfg.Pipeline.Sync.ForceInitialSync = true
+	case cmdBench:
+		// Generated events must not reach the pipeline's checkpoints, queues
+		// or logs, and the benchmark runs once without syncing
+		cfg.Source = config.SourceConfig{Type: "generator", Settings: map[string]interface{}{
+			"count":      opts.benchEvents,
+			"fields":     opts.benchFields,
+			"field_size": opts.benchFieldSize,
+		}}
+		if !opts.benchUseSink {
+			cfg.Sink = config.SinkConfig{Type: "null"}
+		}
+		cfg.Pipeline.Sync = config.SyncConfig{}
+		cfg.Pipeline.Schedule = config.ScheduleConfig{}
+		cfg.Pipeline.Checkpoint = config.CheckpointConfig{}
+		cfg.Pipeline.Mode = ""
+		cfg.Pipeline.WAL = config.BufferConfig{}
+		cfg.Pipeline.Buffer = config.BufferConfig{}
+		cfg.Pipeline.DLQ = config.DLQConfig{}
+		cfg.Pipeline.Audit = config.AuditConfig{}
+		cfg.Pipeline.Alerts = config.AlertsConfig{}
+		cfg.Pipeline.Metrics.Enabled = false
+	case cmdCutover:
+		// The sink starts from a full copy, and change data capture runs until the cutover
+		cfg.Pipeline.Sync.InitialSync = true
+		cfg.Pipeline.Schedule = config.ScheduleConfig{}
+	case cmdReplay:
+		// Captured events carry the positions of another source, so they
+		// must not reach the checkpoint, and the replay runs once
+		cfg.Source = config.SourceConfig{Type: "file", Settings: map[string]interface{}{"path": opts.replayInput}}
+		if opts.replayOutput != "" {
+			cfg.Sink = config.SinkConfig{Type: "file", Settings: map[string]interface{}{"path": opts.replayOutput}}
+		}
+		cfg.Pipeline.Sync = config.SyncConfig{}
+		cfg.Pipeline.Schedule = config.ScheduleConfig{}
+		cfg.Pipeline.Checkpoint = config.CheckpointConfig{}
+		cfg.Pipeline.Mode = ""
+		cfg.Pipeline.WAL = config.BufferConfig{}
+		cfg.Pipeline.Buffer = config.BufferConfig{}
+		cfg.Pipeline.Alerts = config.AlertsConfig{}
+	}
+}
+
+// verifySummary is the JSON report printed by the verify command
+type verifySummary struct {
+	Pipeline        string `json:"pipeline"`
+	SourceDocuments int64  `json:"source_documents"`
+	SinkRows        int64  `json:"sink_rows"`
+	Difference      int64  `json:"difference"`
+	Match           bool   `json:"match"`
+}
+
+// verifyCounts compares the number of documents an initial sync would copy
+// with the number of rows in the sink, prints a summary and returns the exit
+// code. Changes made while counting, or documents the transformer drops,
+// show up as a difference.
+func verifyCounts(ctx context.Context, cfg *config.Config, src pipeline.Source, snk pipeline.Sink, logger *log.Logger) int {
+	summary, err := compareCounts(ctx, cfg, src, snk)
+	if err != nil {
+		logger.Printf("Verify failed: %v", err)
+		return exitVerifyFailed
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(summary); err != nil {
+		logger.Printf("Failed to write verify summary: %v", err)
+	}
+	if !summary.Match {
+		return exitVerifyMismatch
+	}
+	return 0
+}
+
+// compareCounts counts the documents an initial sync would copy and the rows
+// in the sink
+func compareCounts(ctx context.Context, cfg *config.Config, src pipeline.Source, snk pipeline.Sink) (verifySummary, error) {
+	mongoSrc, ok := src.(*source.MongoDBSource)
+	pgSink, ok2 := snk.(*sink.PostgreSQLSink)
+	if !ok || !ok2 {
+		return verifySummary{}, fmt.Errorf("verification requires a mongodb source and a postgresql sink")
+	}
+	filter, err := source.ParseFilter(cfg.Pipeline.Sync.Filter)
+	if err != nil {
+		return verifySummary{}, fmt.Errorf("invalid sync configuration: %w", err)
+	}
+	if err := mongoSrc.Connect(ctx); err != nil {
+		return verifySummary{}, fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+	defer mongoSrc.Close()
+	if err := pgSink.Connect(ctx); err != nil {
+		return verifySummary{}, fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+	}
+	defer pgSink.Close()
+
+	documents, err := mongoSrc.CountDocuments(ctx, filter)
+	if err != nil {
+		return verifySummary{}, err
+	}
+	rows, err := pgSink.CountRows(ctx)
+	if err != nil {
+		return verifySummary{}, err
+	}
+	return verifySummary{
+		Pipeline:        cfg.Pipeline.Name,
+		SourceDocuments: documents,
+		SinkRows:        rows,
+		Difference:      documents - rows,
+		Match:           documents == rows,
+	}, nil
+}
+
+// benchSummary is the JSON report printed by the bench command
+type benchSummary struct {
+	Pipeline        string  `json:"pipeline"`
+	Sink            string  `json:"sink"`
+	Events          int     `json:"events"`
+	Errors          int64   `json:"errors"`
+	Seconds         float64 `json:"seconds"`
+	EventsPerSecond float64 `json:"events_per_second"`
+}
+
+// runBenchmark runs the pipeline over the generated events, prints its
+// throughput and returns the exit code
+func runBenchmark(ctx context.Context, cfg *config.Config, pipe *pipeline.Pipeline, events int, logger *log.Logger) int {
+	var started time.Time
+	pipe.SetHooks(pipeline.Hooks{OnStart: func() { started = time.Now() }})
+	if err := pipe.Run(ctx); err != nil {
+		logger.Printf("Benchmark failed: %v", err)
+		return 1
+	}
+	if started.IsZero() {
+		logger.Printf("Benchmark failed: the pipeline did not start")
+		return 1
+	}
+
+	summary := benchSummary{
+		Pipeline: cfg.Pipeline.Name,
+		Sink:     cfg.Sink.Type,
+		Events:   events,
+		Errors:   pipe.Stats().Errors,
+		Seconds:  time.Since(started).Seconds(),
+	}
+	if summary.Seconds > 0 {
+		summary.EventsPerSecond = float64(events) / summary.Seconds
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(summary); err != nil {
+		logger.Printf("Failed to write benchmark summary: %v", err)
+	}
+	if summary.Errors > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/config"
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// cutoverSummary is the JSON report printed by the cutover command
+type cutoverSummary struct {
+	verifySummary
+	CaughtUpAt       time.Time  `json:"caught_up_at"`
+	LastSourceChange *time.Time `json:"last_source_change,omitempty"`
+	CatchUpSeconds   float64    `json:"catch_up_seconds"`
+	Errors           int64      `json:"errors"`
+}
+
+// finishCutover runs once the operator signalled the cutover: with writes to
+// the source stopped, it catches the sink up until the source is idle,
+// compares the source and sink counts, prints a summary and returns the exit
+// code, that of the verify command
+func finishCutover(ctx context.Context, cfg *config.Config, src pipeline.Source, snk pipeline.Sink, pipe *pipeline.Pipeline, opts options, logger *log.Logger) int {
+	logger.Printf("Cutover: catching up until no change has arrived for %s; writes to the source must be stopped now", opts.catchUpIdle)
+	started := time.Now()
+	pipe.SetEndPoint(pipeline.EndPoint{Idle: opts.catchUpIdle})
+	if err := pipe.Run(ctx); err != nil {
+		logger.Printf("Cutover catch-up failed: %v", err)
+		return exitVerifyFailed
+	}
+	if ctx.Err() != nil {
+		logger.Println("Cutover interrupted before the sink caught up")
+		return exitVerifyFailed
+	}
+
+	stats := pipe.Stats()
+	summary := cutoverSummary{
+		CaughtUpAt:     time.Now(),
+		CatchUpSeconds: time.Since(started).Seconds(),
+		Errors:         stats.Errors,
+	}
+	if stats.Lag > 0 {
+		last := summary.CaughtUpAt.Add(-stats.Lag)
+		summary.LastSourceChange = &last
+	}
+	logger.Println("Cutover: caught up, verifying the sink")
+	var err error
+	if summary.verifySummary, err = compareCounts(ctx, cfg, src, snk); err != nil {
+		logger.Printf("Cutover verification failed: %v", err)
+		return exitVerifyFailed
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(summary); err != nil {
+		logger.Printf("Failed to write cutover summary: %v", err)
+	}
+	if !summary.Match {
+		logger.Printf("Cutover: the sink differs from the source by %d documents", summary.Difference)
+		return exitVerifyMismatch
+	}
+	logger.Println("Cutover complete: the sink matches the source")
+	return 0
+}
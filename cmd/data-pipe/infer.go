@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/schema"
+	"github.com/IEatCodeDaily/data-pipe/pkg/sink"
+)
+
+// inferOptions are the flags of the infer-schema command
+type inferOptions struct {
+	sampleOptions
+	depth     int
+	table     string
+	keyFields string
+	format    string
+}
+
+// inferSchema samples a collection and prints the inferred schema with a
+// field mapping and table definition for it, returning the exit code
+func inferSchema(opts inferOptions) int {
+	logger := log.New(os.Stderr, "[data-pipe] ", log.LstdFlags)
+	switch opts.format {
+	case "all", "mapping", "schema", "sql":
+	default:
+		logger.Printf("infer-schema failed: unsupported format %q, expected all, mapping, schema or sql", opts.format)
+		return 2
+	}
+	inferred, err := sampleSchema(opts.sampleOptions, opts.depth, logger)
+	if err != nil {
+		logger.Printf("infer-schema failed: %v", err)
+		return 1
+	}
+	if err := printInferred(os.Stdout, inferred, opts); err != nil {
+		logger.Printf("infer-schema failed: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// printInferred writes the parts of the inference selected by the format.
+// Everything but all prints one part alone, so it can be piped to a file.
+func printInferred(w io.Writer, inferred schema.Schema, opts inferOptions) error {
+	mappings := proposeMappings(inferred)
+	mapping, err := json.MarshalIndent(map[string]interface{}{"mappings": mappings, "include_all": false, "strict_mode": false}, "", "  ")
+	if err != nil {
+		return err
+	}
+	schemas, err := json.MarshalIndent([]schema.Schema{inferred}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Columns are typed by the schema, as schema_check types them
+	fieldTypes := make(map[string]schema.FieldType, len(inferred.Fields))
+	for _, field := range inferred.Fields {
+		fieldTypes[field.Name] = field.Type
+	}
+	columns := make([]sink.ExpectedColumn, 0, len(mappings))
+	for _, m := range mappings {
+		field := m.Source
+		if m.NestedPath != "" {
+			field = m.NestedPath
+		}
+		columns = append(columns, sink.ExpectedColumn{Name: m.Destination, Type: schemaColumnType(fieldTypes[field])})
+	}
+	table := opts.table
+	if table == "" {
+		table = columnName(inferred.Collection)
+	}
+	var keys []string
+	for _, key := range strings.Split(opts.keyFields, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	statement, err := sink.CreateTableStatement(table, columns, keys)
+	if err != nil {
+		return err
+	}
+
+	switch opts.format {
+	case "mapping":
+		fmt.Fprintln(w, string(mapping))
+	case "schema":
+		fmt.Fprintln(w, string(schemas))
+	case "sql":
+		fmt.Fprintln(w, statement)
+	default:
+		fmt.Fprintln(w, "# Field mapper settings (transformer.settings of a fieldmapper transformer)")
+		fmt.Fprintln(w, string(mapping))
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "# Schema (pipeline.schemas.collections)")
+		fmt.Fprintln(w, string(schemas))
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "# PostgreSQL table")
+		fmt.Fprintln(w, statement)
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/config"
+	"github.com/IEatCodeDaily/data-pipe/pkg/schema"
+	"github.com/IEatCodeDaily/data-pipe/pkg/source"
+)
+
+// sampleOptions select the documents init and infer-schema sample
+type sampleOptions struct {
+	uri        string
+	database   string
+	collection string
+	sample     int
+}
+
+// initOptions are the flags of the init command
+type initOptions struct {
+	sampleOptions
+	source           string
+	sink             string
+	connectionString string
+	table            string
+	output           string
+	force            bool
+}
+
+// sampleTimeout bounds connecting to the source and sampling it
+const sampleTimeout = time.Minute
+
+// placeholderConnectionString is written when no sink connection string is given
+const placeholderConnectionString = "host=localhost port=5432 user=postgres password=postgres dbname=postgres sslmode=disable"
+
+// starterConfig is the configuration file written by init, its sections in
+// the order of the example configurations
+type starterConfig struct {
+	Pipeline    starterPipeline          `json:"pipeline"`
+	Source      config.SourceConfig      `json:"source"`
+	Sink        config.SinkConfig        `json:"sink"`
+	Transformer config.TransformerConfig `json:"transformer"`
+}
+
+type starterPipeline struct {
+	Name    string               `json:"name"`
+	Sync    starterSync          `json:"sync"`
+	Schemas config.SchemasConfig `json:"schemas"`
+}
+
+type starterSync struct {
+	InitialSync    bool   `json:"initial_sync"`
+	TimestampField string `json:"timestamp_field,omitempty"`
+}
+
+// proposedMapping is a field mapping without the settings left unset
+type proposedMapping struct {
+	Source      string `json:"source"`
+	NestedPath  string `json:"nested_path,omitempty"`
+	Destination string `json:"destination"`
+	Format      string `json:"format,omitempty"`
+}
+
+// initConfig samples the source collection and writes a starter configuration
+// with a field mapping and the inferred schema, returning the exit code
+func initConfig(opts initOptions) int {
+	logger := log.New(os.Stderr, "[data-pipe] ", log.LstdFlags)
+	if err := writeStarterConfig(opts, logger); err != nil {
+		logger.Printf("init failed: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// writeStarterConfig does the work of initConfig
+func writeStarterConfig(opts initOptions, logger *log.Logger) error {
+	if opts.source != "mongodb" {
+		return fmt.Errorf("unsupported source type %q: init supports mongodb", opts.source)
+	}
+	if opts.sink != "postgresql" {
+		return fmt.Errorf("unsupported sink type %q: init supports postgresql", opts.sink)
+	}
+	if opts.table == "" {
+		opts.table = columnName(opts.collection)
+	}
+	if !opts.force {
+		if _, err := os.Stat(opts.output); err == nil {
+			return fmt.Errorf("%s already exists, pass -force to overwrite it", opts.output)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	inferred, err := sampleSchema(opts.sampleOptions, 0, logger)
+	if err != nil {
+		return err
+	}
+
+	connStr := opts.connectionString
+	if connStr == "" {
+		connStr = placeholderConnectionString
+		logger.Printf("No -connection-string given, edit the placeholder in sink.settings")
+	}
+	starter, err := buildStarterConfig(opts, connStr, inferred)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(starter, "", "  ")
+	if err != nil {
+		return err
+	}
+	// The file holds the connection strings, so only the owner may read it
+	if err := os.WriteFile(opts.output, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", opts.output, err)
+	}
+	fmt.Printf("Wrote %s with %d mapped fields; review it, then run: data-pipe validate -config %s\n", opts.output, len(inferred.Fields), opts.output)
+	return nil
+}
+
+// buildStarterConfig proposes a pipeline copying the inferred fields to
+// snake_case columns, typed by the schema through schema_check
+func buildStarterConfig(opts initOptions, connStr string, inferred schema.Schema) (starterConfig, error) {
+	collections, err := json.Marshal([]schema.Schema{inferred})
+	if err != nil {
+		return starterConfig{}, err
+	}
+	var timestampField string
+	for _, field := range inferred.Fields {
+		// An update time makes later initial syncs incremental. The sink is
+		// queried for the same name, so only a field keeping its name qualifies.
+		if field.Type == schema.Timestamp && strings.Contains(strings.ToLower(field.Name), "updated") && columnName(field.Name) == field.Name {
+			timestampField = field.Name
+			break
+		}
+	}
+
+	return starterConfig{
+		Pipeline: starterPipeline{
+			Name:    opts.collection + "-to-" + opts.table,
+			Sync:    starterSync{InitialSync: true, TimestampField: timestampField},
+			Schemas: config.SchemasConfig{Policy: "warn", Collections: collections},
+		},
+		Source: config.SourceConfig{Type: opts.source, Settings: map[string]interface{}{
+			"uri":        opts.uri,
+			"database":   opts.database,
+			"collection": opts.collection,
+		}},
+		Sink: config.SinkConfig{Type: opts.sink, Settings: map[string]interface{}{
+			"connection_string": connStr,
+			"table":             opts.table,
+			"schema_check":      "fail",
+		}},
+		Transformer: config.TransformerConfig{Type: "fieldmapper", Settings: map[string]interface{}{
+			"mappings": proposeMappings(inferred),
+		}},
+	}, nil
+}
+
+// sampleSchema samples the collection and infers its schema, describing
+// nested documents down to depth levels
+func sampleSchema(opts sampleOptions, depth int, logger *log.Logger) (schema.Schema, error) {
+	if opts.uri == "" || opts.database == "" || opts.collection == "" {
+		return schema.Schema{}, fmt.Errorf("-uri, -database and -collection are required")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sampleTimeout)
+	defer cancel()
+	src := source.NewMongoDBSource(opts.uri, opts.database, opts.collection, logger)
+	if err := src.Connect(ctx); err != nil {
+		return schema.Schema{}, fmt.Errorf("failed to connect to MongoDB: %w", err)
+	}
+	defer src.Close()
+	documents, err := src.Sample(ctx, opts.sample)
+	if err != nil {
+		return schema.Schema{}, err
+	}
+	if len(documents) == 0 {
+		return schema.Schema{}, fmt.Errorf("collection %s is empty, there is nothing to infer a schema from", opts.collection)
+	}
+	inferred := schema.Infer(opts.collection, documents, depth)
+	logger.Printf("Sampled %d documents with %d fields", len(documents), len(inferred.Fields))
+	return inferred, nil
+}
+
+// proposeMappings maps every inferred field to a snake_case column, nested
+// fields through their path. A nested document whose fields are described is
+// mapped field by field instead of as a whole.
+func proposeMappings(inferred schema.Schema) []proposedMapping {
+	parents := make(map[string]bool)
+	for _, field := range inferred.Fields {
+		if i := strings.LastIndex(field.Name, "."); i >= 0 {
+			parents[field.Name[:i]] = true
+		}
+	}
+	var mappings []proposedMapping
+	for _, field := range inferred.Fields {
+		if parents[field.Name] {
+			continue
+		}
+		mapping := proposedMapping{Source: field.Name, Destination: columnName(field.Name), Format: mappingFormat(field.Type)}
+		if top, _, nested := strings.Cut(field.Name, "."); nested {
+			mapping.Source, mapping.NestedPath = top, field.Name
+		}
+		mappings = append(mappings, mapping)
+	}
+	return mappings
+}
+
+// mappingFormat returns the field mapper format converting values of a field
+// type, if one is needed
+func mappingFormat(fieldType schema.FieldType) string {
+	switch fieldType {
+	case schema.Int:
+		return "int"
+	case schema.Float:
+		return "float"
+	case schema.Bool:
+		return "bool"
+	case schema.Timestamp:
+		return "date"
+	default:
+		return ""
+	}
+}
+
+// columnName proposes a PostgreSQL column name for a field, e.g. created_at
+// for createdAt and user_id for userID
+func columnName(field string) string {
+	runes := []rune(field)
+	var b strings.Builder
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'):
+			b.WriteRune(unicode.ToLower(r))
+		default:
+			b.WriteByte('_')
+		}
+	}
+	name := b.String()
+	if name == "" || unicode.IsDigit(rune(name[0])) {
+		name = "_" + name
+	}
+	return name
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// logFile is the -log-file the pipeline logs to. Reopen switches to a new
+// file at the same path after the old one has been rotated away.
+type logFile struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// openLogFile opens (or creates) a log file for appending
+func openLogFile(path string) (*logFile, error) {
+	f := &logFile{path: path}
+	if err := f.Reopen(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Write appends to the current file
+func (f *logFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Write(p)
+}
+
+// Reopen opens the path again and closes the previous file; on error the
+// previous file stays in use
+func (f *logFile) Reopen() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	f.mu.Lock()
+	previous := f.file
+	f.file = file
+	f.mu.Unlock()
+	if previous != nil {
+		previous.Close()
+	}
+	return nil
+}
+
+// Close closes the current file
+func (f *logFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
@@ -3,102 +3,1017 @@ package main
 import (
 	"context"
 	"encoding/json"
-	"flag"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"runtime/pprof"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/IEatCodeDaily/data-pipe/pkg/alert"
+	"github.com/IEatCodeDaily/data-pipe/pkg/audit"
+	"github.com/IEatCodeDaily/data-pipe/pkg/awsauth"
+	"github.com/IEatCodeDaily/data-pipe/pkg/checkpoint"
+	"github.com/IEatCodeDaily/data-pipe/pkg/compress"
 	"github.com/IEatCodeDaily/data-pipe/pkg/config"
+	"github.com/IEatCodeDaily/data-pipe/pkg/dlq"
 	"github.com/IEatCodeDaily/data-pipe/pkg/metrics"
 	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"github.com/IEatCodeDaily/data-pipe/pkg/plugin"
+	"github.com/IEatCodeDaily/data-pipe/pkg/schedule"
+	"github.com/IEatCodeDaily/data-pipe/pkg/schema"
 	"github.com/IEatCodeDaily/data-pipe/pkg/sink"
 	"github.com/IEatCodeDaily/data-pipe/pkg/source"
+	"github.com/IEatCodeDaily/data-pipe/pkg/spool"
 	"github.com/IEatCodeDaily/data-pipe/pkg/transform"
+	"github.com/IEatCodeDaily/data-pipe/pkg/vault"
+)
+
+// Exit codes of the sync-once command
+const (
+	exitSyncFailed      = 1 // the sync failed or reported errors
+	exitSyncInterrupted = 2 // the sync was stopped by a signal before completing
+	exitSyncRejected    = 3 // the sync completed but some documents were rejected
+)
+
+// Exit codes of the dlq replay command
+const (
+	exitReplayFailed       = 1 // the replay could not run or record its outcomes
+	exitReplayDeadLettered = 3 // some replayed events failed again
 )
 
 func main() {
-	configPath := flag.String("config", "config.json", "Path to configuration file")
-	flag.Parse()
+	opts := parseCommandLine(os.Args[1:])
+	switch opts.command {
+	case cmdInit:
+		os.Exit(initConfig(opts.scaffold))
+	case cmdInferSchema:
+		os.Exit(inferSchema(opts.infer))
+	}
+	start(opts)
+}
+
+// start runs a command; see commands.go
+func start(opts options) {
+	// "sync-once" and "resync" perform a single sync, print a summary and exit;
+	// "dlq replay" re-runs dead-lettered events
+	syncOnce := opts.command == cmdSyncOnce || opts.command == cmdResync
+	dlqReplay := opts.command == cmdDLQReplay
+	replayOpts := opts.replay
+
+	// Commands other than run keep stdout for their reports
+	logOutput := os.Stdout
+	if opts.command != cmdRun {
+		logOutput = os.Stderr
+	}
+	logger := log.New(logOutput, "[data-pipe] ", log.LstdFlags)
+	var logs *logFile
+	if opts.logFile != "" {
+		var err error
+		logs, err = openLogFile(opts.logFile)
+		if err != nil {
+			logger.Fatalf("Failed to open log file: %v", err)
+		}
+		defer logs.Close()
+		logger.SetOutput(logs)
+	}
 
-	logger := log.New(os.Stdout, "[data-pipe] ", log.LstdFlags)
+	if opts.command == cmdReplay && opts.replayInput == "" {
+		logger.Fatalf("replay requires -input, a file captured with run -capture")
+	}
+	var start *pipeline.StartPoint
+	if opts.startAt != "" && opts.startAfter != "" {
+		logger.Fatalf("-start-at and -start-after cannot be combined")
+	} else if opts.startAt != "" {
+		at, err := time.Parse(time.RFC3339, opts.startAt)
+		if err != nil {
+			logger.Fatalf("Invalid -start-at time: %v", err)
+		}
+		start = &pipeline.StartPoint{Time: at}
+	} else if opts.startAfter != "" {
+		start = &pipeline.StartPoint{Position: opts.startAfter}
+	}
+	end := pipeline.EndPoint{Idle: opts.stopWhenIdle}
+	if opts.stopAt != "" {
+		at, err := time.Parse(time.RFC3339, opts.stopAt)
+		if err != nil {
+			logger.Fatalf("Invalid -stop-at time: %v", err)
+		}
+		end.Time = at
+	}
 
 	// Load configuration
-	cfg, err := config.LoadFromFile(*configPath)
+	cfg, err := config.LoadProfile(opts.configPath, opts.profile)
 	if err != nil {
 		logger.Fatalf("Failed to load configuration: %v", err)
 	}
+	applyCommand(cfg, opts)
 
 	logger.Printf("Loaded configuration for pipeline: %s", cfg.Pipeline.Name)
+	if opts.profile != "" {
+		logger.Printf("Merged profile %s from %s", opts.profile, config.ProfilePath(opts.configPath, opts.profile))
+	}
+
+	// Resolve vault: secret references
+	var secrets *vault.Resolver
+	rawSinkConnStr := cfg.Sink.GetString("connection_string")
+	rawSourceURI := cfg.Source.GetString("uri")
+	rawAuditConnStr := cfg.Pipeline.Audit.ConnectionString
+	rawDLQConnStr := cfg.Pipeline.DLQ.ConnectionString
+	if cfg.Vault != nil {
+		secrets, err = buildVaultResolver(cfg, logger)
+		if err != nil {
+			logger.Fatalf("Failed to resolve secrets from Vault: %v", err)
+		}
+	}
+
+	// Recycle event allocations across stages if configured
+	pipeline.SetPooling(cfg.Pipeline.Pooling)
 
 	// Create source
 	var src pipeline.Source
+	var mongoSettings *mongoSourceSettings // nil unless the source is mongodb
 	switch cfg.Source.Type {
 	case "mongodb":
-		uri := cfg.Source.GetString("uri")
-		database := cfg.Source.GetString("database")
-		collection := cfg.Source.GetString("collection")
-		src = source.NewMongoDBSource(uri, database, collection, logger)
+		var settings mongoSourceSettings
+		if err := cfg.Source.Decode(&settings); err != nil {
+			logger.Fatalf("Invalid MongoDB source configuration: %v", err)
+		}
+		mongoSettings = &settings
+		if settings.Collection == "" && settings.GridFSBucket == "" {
+			logger.Fatalf("Invalid MongoDB source configuration: setting \"collection\" is required")
+		}
+		mongoSrc := source.NewMongoDBSource(settings.URI, settings.Database, settings.Collection, logger)
+		mongoSrc.SetGridFS(settings.GridFSBucket)
+		mongoSrc.SetIAMAuth(settings.IAMAuth)
+		mongoSrc.SetRewatchOnInvalidate(settings.rewatchOnInvalidate())
+		if err := mongoSrc.SetProjection(settings.IncludeFields, settings.ExcludeFields); err != nil {
+			logger.Fatalf("Invalid MongoDB source configuration: %v", err)
+		}
+		if err := mongoSrc.SetBatching(settings.BatchMaxEvents, settings.BatchWindowMs.Duration()); err != nil {
+			logger.Fatalf("Invalid MongoDB source configuration: %v", err)
+		}
+		if err := mongoSrc.SetHeartbeatInterval(settings.HeartbeatIntervalSeconds.Duration()); err != nil {
+			logger.Fatalf("Invalid MongoDB source configuration: %v", err)
+		}
+		idStrategy, err := source.ParseIDStrategy(settings.IDStrategy)
+		if err != nil {
+			logger.Fatalf("Invalid MongoDB source configuration: %v", err)
+		}
+		if err := mongoSrc.SetIDStrategy(idStrategy, settings.IDFields); err != nil {
+			logger.Fatalf("Invalid MongoDB source configuration: %v", err)
+		}
+		if err := mongoSrc.SetCollation(settings.Collation.collation()); err != nil {
+			logger.Fatalf("Invalid MongoDB source configuration: %v", err)
+		}
+		if secrets != nil && vault.HasReferences(rawSourceURI) {
+			// Re-resolve on every connect so rotated credentials are picked up
+			mongoSrc.SetURIProvider(func(ctx context.Context) (string, error) {
+				return secrets.Resolve(ctx, rawSourceURI)
+			})
+		}
+		src = mongoSrc
+	case "file":
+		var settings fileSourceSettings
+		if err := cfg.Source.Decode(&settings); err != nil {
+			logger.Fatalf("Invalid file source configuration: %v", err)
+		}
+		codec, err := compress.ParseCodec(settings.Compression)
+		if err != nil {
+			logger.Fatalf("Invalid file source configuration: %v", err)
+		}
+		src = source.NewFileSource(settings.Path, codec, logger)
+	case "generator":
+		var settings generatorSourceSettings
+		if err := cfg.Source.Decode(&settings); err != nil {
+			logger.Fatalf("Invalid generator source configuration: %v", err)
+		}
+		operations, err := source.ParseOperationMix(settings.Operations)
+		if err != nil {
+			logger.Fatalf("Invalid generator source configuration: %v", err)
+		}
+		src = source.NewGeneratorSource(source.GeneratorConfig{
+			Rate:        settings.Rate,
+			Count:       settings.Count,
+			Fields:      settings.Fields,
+			FieldSize:   settings.FieldSize,
+			Cardinality: settings.Cardinality,
+			Operations:  operations,
+			Seed:        settings.Seed,
+		}, logger)
+	case "outbox":
+		var settings outboxSourceSettings
+		if err := cfg.Source.Decode(&settings); err != nil {
+			logger.Fatalf("Invalid outbox source configuration: %v", err)
+		}
+		src = source.NewOutboxSource(source.OutboxConfig{
+			ConnectionString: settings.ConnectionString,
+			Table:            settings.Table,
+			IDColumn:         settings.IDColumn,
+			AggregateColumn:  settings.AggregateColumn,
+			PayloadColumn:    settings.PayloadColumn,
+			CreatedAtColumn:  settings.CreatedAtColumn,
+			ProcessedColumn:  settings.ProcessedColumn,
+			OperationColumn:  settings.OperationColumn,
+			BatchSize:        settings.BatchSize,
+			PollInterval:     settings.PollIntervalSeconds.Duration(),
+			Heartbeat:        settings.HeartbeatIntervalSeconds.Duration(),
+			AckOnCommit:      settings.AckOnCommit,
+		}, logger)
+	case "plugin":
+		var settings pluginSourceSettings
+		if err := cfg.Source.Decode(&settings); err != nil {
+			logger.Fatalf("Invalid plugin source configuration: %v", err)
+		}
+		src = plugin.NewSource(plugin.SourceConfig{
+			Plugin:       settings.Config,
+			MaxEvents:    settings.MaxEvents,
+			PollInterval: settings.PollIntervalMs.Duration(),
+		}, logger)
 	default:
 		logger.Fatalf("Unsupported source type: %s", cfg.Source.Type)
 	}
 
 	// Create sink
 	var snk pipeline.Sink
+	var sinkConnProvider sink.ConnectionStringProvider
+	var pgSettings *postgresSinkSettings // nil unless the sink is postgresql
 	switch cfg.Sink.Type {
 	case "postgresql":
-		connStr := cfg.Sink.GetString("connection_string")
-		table := cfg.Sink.GetString("table")
-		snk = sink.NewPostgreSQLSink(connStr, table, logger)
+		var settings postgresSinkSettings
+		if err := cfg.Sink.Decode(&settings); err != nil {
+			logger.Fatalf("Invalid PostgreSQL sink configuration: %v", err)
+		}
+		pgSettings = &settings
+		connStr := settings.ConnectionString
+		pgSink := sink.NewPostgreSQLSink(connStr, settings.Table, logger)
+		if settings.IAMAuth {
+			if secrets != nil && vault.HasReferences(rawSinkConnStr) {
+				logger.Fatalf("PostgreSQL iam_auth cannot be combined with vault references in connection_string")
+			}
+			rdsAuth, err := awsauth.NewRDSAuth(context.Background(), settings.AWSRegion)
+			if err != nil {
+				logger.Fatalf("Failed to set up IAM authentication: %v", err)
+			}
+			// A fresh token is signed for new connections before the old one expires
+			sinkConnProvider = func(ctx context.Context) (string, error) {
+				return rdsAuth.ConnectionString(ctx, connStr)
+			}
+			logger.Println("PostgreSQL IAM authentication enabled")
+		} else if secrets != nil && vault.HasReferences(rawSinkConnStr) {
+			// Re-resolve on every new connection so rotated credentials are picked up
+			sinkConnProvider = func(ctx context.Context) (string, error) {
+				return secrets.Resolve(ctx, rawSinkConnStr)
+			}
+		}
+		if sinkConnProvider != nil {
+			pgSink.SetConnectionStringProvider(sinkConnProvider)
+		}
+		isolation, err := sink.ParseErrorIsolation(settings.ErrorIsolation)
+		if err != nil {
+			logger.Fatalf("Invalid PostgreSQL sink configuration: %v", err)
+		}
+		pgSink.SetErrorIsolation(isolation)
+		if settings.WriteTimeoutSeconds > 0 {
+			pgSink.SetWriteTimeout(settings.WriteTimeoutSeconds.Duration())
+		}
+		columnMode, err := sink.ParseColumnPolicyMode(settings.ColumnPolicy)
+		if err != nil {
+			logger.Fatalf("Invalid PostgreSQL sink configuration: %v", err)
+		}
+		if err := pgSink.SetKeyColumns(cfg.Pipeline.KeyFields); err != nil {
+			logger.Fatalf("Invalid PostgreSQL sink configuration: %v", err)
+		}
+		if err := pgSink.SetColumnPolicy(sink.ColumnPolicy{
+			Allowed: settings.AllowedColumns,
+			Denied:  settings.DeniedColumns,
+			Mode:    columnMode,
+		}); err != nil {
+			logger.Fatalf("Invalid PostgreSQL sink configuration: %v", err)
+		}
+		valueColumns := settings.MetadataValueColumns
+		if field := cfg.Pipeline.Origin.Field; field != "" && !slices.Contains(valueColumns, field) {
+			// Rows carry the loop prevention marker
+			valueColumns = append(valueColumns, field)
+		}
+		if err := pgSink.SetMetadataColumns(sink.MetadataColumns{
+			SyncedAt:        settings.SyncedAtColumn,
+			SourceTimestamp: settings.SourceTSColumn,
+			IngestedAt:      settings.IngestedAtColumn,
+			Operation:       settings.OperationColumn,
+			Deleted:         settings.DeletedColumn,
+			RowHash:         settings.RowHashColumn,
+			Values:          valueColumns,
+		}); err != nil {
+			logger.Fatalf("Invalid PostgreSQL sink configuration: %v", err)
+		}
+		writeMode, err := sink.ParseWriteMode(settings.WriteMode)
+		if err != nil {
+			logger.Fatalf("Invalid PostgreSQL sink configuration: %v", err)
+		}
+		switch writeMode {
+		case sink.WriteHistory:
+			err = pgSink.SetHistoryMode(sink.HistoryColumns{
+				ValidFrom: settings.ValidFromColumn,
+				ValidTo:   settings.ValidToColumn,
+				Current:   settings.CurrentColumn,
+			})
+		case sink.WriteAppend:
+			err = pgSink.SetEventLogMode(sink.EventLogColumns{
+				ID:        settings.LogIDColumn,
+				Timestamp: settings.LogTimeColumn,
+				Operation: settings.LogOperationColumn,
+				Key:       settings.LogKeyColumn,
+				Payload:   settings.LogPayloadColumn,
+			})
+		}
+		if err != nil {
+			logger.Fatalf("Invalid PostgreSQL sink configuration: %v", err)
+		}
+		if err := pgSink.SetSessionVariables(settings.SessionVariables); err != nil {
+			logger.Fatalf("Invalid PostgreSQL sink configuration: %v", err)
+		}
+		strategy, err := sink.ParseConflictStrategy(settings.ConflictResolution)
+		if err != nil {
+			logger.Fatalf("Invalid PostgreSQL sink configuration: %v", err)
+		}
+		resolution := sink.ConflictResolution{
+			Strategy:     strategy,
+			Priority:     settings.ConflictPriority,
+			OriginColumn: cfg.Pipeline.Origin.Field,
+		}
+		if strategy == sink.ConflictMerge {
+			if settings.ConflictMerge.Type == "" {
+				logger.Fatalf("Invalid PostgreSQL sink configuration: conflict_resolution merge requires a conflict_merge transformer")
+			}
+			if resolution.Merge, err = buildTransformer(settings.ConflictMerge, logger); err != nil {
+				logger.Fatalf("Invalid PostgreSQL sink configuration: conflict_merge: %v", err)
+			}
+		}
+		if err := pgSink.SetConflictResolution(resolution); err != nil {
+			logger.Fatalf("Invalid PostgreSQL sink configuration: %v", err)
+		}
+		if settings.Timescale {
+			if err := pgSink.SetTimescale(sink.TimescaleConfig{
+				TimeColumn:    settings.TimescaleTimeColumn,
+				ChunkInterval: settings.TimescaleChunkIntervalSeconds.Duration(),
+				CompressAfter: settings.TimescaleCompressAfterSeconds.Duration(),
+				SegmentBy:     settings.TimescaleSegmentBy,
+				OrderBy:       settings.TimescaleOrderBy,
+			}); err != nil {
+				logger.Fatalf("Invalid PostgreSQL sink configuration: %v", err)
+			}
+		}
+		if settings.PartitionColumn != "" {
+			scheme, err := sink.ParsePartitionScheme(settings.PartitionScheme)
+			if err != nil {
+				logger.Fatalf("Invalid PostgreSQL sink configuration: %v", err)
+			}
+			if err := pgSink.SetPartitioning(sink.PartitionConfig{Column: settings.PartitionColumn, Scheme: scheme}); err != nil {
+				logger.Fatalf("Invalid PostgreSQL sink configuration: %v", err)
+			}
+		}
+		if len(settings.Indexes) > 0 {
+			if err := pgSink.SetIndexes(settings.Indexes); err != nil {
+				logger.Fatalf("Invalid PostgreSQL sink configuration: %v", err)
+			}
+			if cfg.Pipeline.Sync.InitialSync {
+				pgSink.DeferIndexes()
+			}
+		}
+		if settings.DeferForeignKeys > 0 {
+			// Held events are only in memory, so only a checkpoint committed
+			// with the batches can be kept before them
+			if cfg.Pipeline.Checkpoint.Store != "postgresql" {
+				logger.Fatalf("Invalid PostgreSQL sink configuration: defer_foreign_keys requires the postgresql checkpoint store")
+			}
+			if _, ok := src.(pipeline.Acknowledger); ok {
+				logger.Fatalf("Invalid PostgreSQL sink configuration: defer_foreign_keys cannot be used with a source that deletes acknowledged events")
+			}
+			if err := pgSink.SetForeignKeyDeferral(sink.ForeignKeyDeferral{
+				MaxEvents:     settings.DeferForeignKeys,
+				MaxAttempts:   settings.DeferForeignKeysAttempts,
+				RetryInterval: settings.DeferForeignKeysRetrySeconds.Duration(),
+			}); err != nil {
+				logger.Fatalf("Invalid PostgreSQL sink configuration: %v", err)
+			}
+		}
+		if settings.BreakerFailureThreshold > 0 {
+			openTimeout := settings.BreakerOpenTimeoutSeconds.Duration()
+			breaker := pipeline.NewCircuitBreaker(settings.BreakerFailureThreshold, openTimeout)
+			breaker.OnStateChange(func(from, to pipeline.BreakerState) {
+				logger.Printf("Sink circuit breaker: %s -> %s", from, to)
+			})
+			pgSink.SetCircuitBreaker(breaker)
+		}
+		snk = pgSink
+	case "file":
+		var settings fileSinkSettings
+		if err := cfg.Sink.Decode(&settings); err != nil {
+			logger.Fatalf("Invalid file sink configuration: %v", err)
+		}
+		codec, err := compress.ParseCodec(settings.Compression)
+		if err != nil {
+			logger.Fatalf("Invalid file sink configuration: %v", err)
+		}
+		snk = sink.NewFileSink(sink.FileSinkConfig{
+			Path:             settings.Path,
+			Compression:      codec,
+			CompressionLevel: settings.CompressionLevel,
+			Append:           settings.Append,
+			Tombstones:       settings.Tombstones,
+		}, logger)
+	case "s3":
+		var settings s3SinkSettings
+		if err := cfg.Sink.Decode(&settings); err != nil {
+			logger.Fatalf("Invalid S3 sink configuration: %v", err)
+		}
+		codec, err := compress.ParseCodec(settings.Compression)
+		if err != nil {
+			logger.Fatalf("Invalid S3 sink configuration: %v", err)
+		}
+		snk = sink.NewS3Sink(sink.S3SinkConfig{
+			Bucket:           settings.Bucket,
+			Prefix:           settings.Prefix,
+			Region:           settings.AWSRegion,
+			Endpoint:         settings.Endpoint,
+			Compression:      codec,
+			CompressionLevel: settings.CompressionLevel,
+			ObjectEvents:     settings.ObjectEvents,
+			Tombstones:       settings.Tombstones,
+		}, logger)
+	case "http_bulk":
+		var settings httpBulkSinkSettings
+		if err := cfg.Sink.Decode(&settings); err != nil {
+			logger.Fatalf("Invalid bulk HTTP sink configuration: %v", err)
+		}
+		codec, err := compress.ParseCodec(settings.Compression)
+		if err != nil {
+			logger.Fatalf("Invalid bulk HTTP sink configuration: %v", err)
+		}
+		bulkSink, err := sink.NewHTTPBulkSink(sink.HTTPBulkSinkConfig{
+			URL:              settings.URL,
+			Method:           settings.Method,
+			Headers:          settings.Headers,
+			ActionTemplate:   settings.ActionTemplate,
+			DeleteTemplate:   settings.DeleteTemplate,
+			ContentType:      settings.ContentType,
+			BatchEvents:      settings.BatchEvents,
+			FlushInterval:    settings.FlushIntervalMs.Duration(),
+			Compression:      codec,
+			CompressionLevel: settings.CompressionLevel,
+			Timeout:          settings.TimeoutSeconds.Duration(),
+		}, logger)
+		if err != nil {
+			logger.Fatalf("Invalid bulk HTTP sink configuration: %v", err)
+		}
+		snk = bulkSink
+	case "snowflake":
+		var settings snowflakeSinkSettings
+		if err := cfg.Sink.Decode(&settings); err != nil {
+			logger.Fatalf("Invalid Snowflake sink configuration: %v", err)
+		}
+		privateKey, err := os.ReadFile(settings.PrivateKeyPath)
+		if err != nil {
+			logger.Fatalf("Invalid Snowflake sink configuration: failed to read private key: %v", err)
+		}
+		stage := sink.NewS3Sink(sink.S3SinkConfig{
+			Bucket:   settings.StageBucket,
+			Prefix:   settings.StagePrefix,
+			Region:   settings.AWSRegion,
+			Endpoint: settings.StageEndpoint,
+		}, logger)
+		snowflakeSink, err := sink.NewSnowflakeSink(sink.SnowflakeSinkConfig{
+			Account:       settings.Account,
+			User:          settings.User,
+			PrivateKey:    privateKey,
+			Role:          settings.Role,
+			Warehouse:     settings.Warehouse,
+			Database:      settings.Database,
+			Schema:        settings.Schema,
+			Table:         settings.Table,
+			Stage:         settings.Stage,
+			Columns:       settings.Columns,
+			KeyColumns:    settings.KeyColumns,
+			BatchEvents:   settings.BatchEvents,
+			FlushInterval: settings.FlushIntervalSeconds.Duration(),
+			Endpoint:      settings.Endpoint,
+		}, stage, logger)
+		if err != nil {
+			logger.Fatalf("Invalid Snowflake sink configuration: %v", err)
+		}
+		snk = snowflakeSink
+	case "null":
+		snk = sink.NewNullSink(logger)
+	case "plugin":
+		var settings pluginSinkSettings
+		if err := cfg.Sink.Decode(&settings); err != nil {
+			logger.Fatalf("Invalid plugin sink configuration: %v", err)
+		}
+		snk = plugin.NewSink(plugin.SinkConfig{
+			Plugin:        settings.Config,
+			BatchEvents:   settings.BatchEvents,
+			FlushInterval: settings.FlushIntervalMs.Duration(),
+		}, logger)
 	default:
 		logger.Fatalf("Unsupported sink type: %s", cfg.Sink.Type)
 	}
+	if mongoSettings != nil && mongoSettings.GridFSContent {
+		// GridFS file content is stored next to the events
+		store, ok := snk.(source.ContentStore)
+		if !ok || mongoSettings.GridFSBucket == "" {
+			logger.Fatalf("Invalid MongoDB source configuration: gridfs_content requires gridfs_bucket and an s3 sink")
+		}
+		src.(*source.MongoDBSource).SetGridFSContent(store)
+	}
 
 	// Create transformer
-	var transformer pipeline.Transformer
+	transformer, err := buildTransformer(cfg.Transformer, logger)
+	if err != nil {
+		logger.Fatalf("Invalid transformer configuration: %v", err)
+	}
+
+	// Load the event schemas declared per collection
+	var schemas *schema.Registry
+	if len(cfg.Pipeline.Schemas.Collections) > 0 {
+		policy, err := schema.ParsePolicy(cfg.Pipeline.Schemas.Policy)
+		if err != nil {
+			logger.Fatalf("Invalid schemas configuration: %v", err)
+		}
+		var declared []schema.Schema
+		if err := json.Unmarshal(cfg.Pipeline.Schemas.Collections, &declared); err != nil {
+			logger.Fatalf("Invalid schemas configuration: %v", err)
+		}
+		if schemas, err = schema.NewRegistry(declared, policy, logger); err != nil {
+			logger.Fatalf("Invalid schemas configuration: %v", err)
+		}
+		logger.Printf("Event schemas declared for %d collections (policy: %s)", len(declared), policy)
+	}
+
+	if pgSink, ok := snk.(*sink.PostgreSQLSink); ok {
+		policy, err := sink.ParseSchemaPolicy(pgSettings.SchemaCheck)
+		if err != nil {
+			logger.Fatalf("Invalid PostgreSQL sink configuration: %v", err)
+		}
+		var declared *schema.Schema
+		if schemas != nil {
+			declared, _ = schemas.Lookup(mongoSettings.collection())
+		}
+		columns, complete := expectedColumns(transformer, declared)
+		if err := pgSink.SetSchemaCheck(sink.SchemaCheck{Policy: policy, Columns: columns, Complete: complete}); err != nil {
+			logger.Fatalf("Invalid PostgreSQL sink configuration: %v", err)
+		}
+	}
+
+	// Create pipeline
+	pipe := pipeline.New(cfg.Pipeline.Name, src, snk, transformer, logger)
+	// Events are validated as they are read, also by the initial sync
+	var validator pipeline.EventValidator
+	if schemas != nil {
+		validator = schemas
+		pipe.SetValidator(validator)
+	}
+
+	if pii := cfg.Pipeline.PII; len(pii.Fields) > 0 || pii.Default != "" {
+		if _, err := buildAnonymizer(pii); err != nil {
+			logger.Fatalf("Invalid pii configuration: %v", err)
+		}
+	}
 
-	if cfg.Transformer.Type != "" {
-		switch cfg.Transformer.Type {
-		case "fieldmapper":
-			// Parse field mapper configuration
-			if _, ok := cfg.Transformer.Settings["mappings"]; !ok {
-				logger.Fatalf("fieldmapper transformer requires 'mappings' configuration")
+	// sample only reads the source
+	if opts.command == cmdSample {
+		os.Exit(exportSample(cfg, mongoSettings, src, opts, logger))
+	}
+
+	// The repl only previews events, nothing is connected
+	if opts.command == cmdREPL {
+		pgSink, _ := snk.(*sink.PostgreSQLSink)
+		os.Exit(runREPL(os.Stdin, os.Stdout, &replSession{
+			configPath:  opts.configPath,
+			profile:     opts.profile,
+			collection:  mongoSettings.collection(),
+			operation:   "insert",
+			transformer: transformer,
+			validator:   validator,
+			pgSink:      pgSink,
+			logger:      logger,
+		}))
+	}
+
+	// Setup audit log if configured
+	if cfg.Pipeline.Audit.Path != "" && cfg.Pipeline.Audit.Table != "" {
+		logger.Fatalf("pipeline.audit accepts either a path or a table, not both")
+	}
+	var auditFile *audit.FileLog
+	if cfg.Pipeline.Audit.Path != "" {
+		auditFile, err = audit.NewFileLog(cfg.Pipeline.Audit.Path)
+		if err != nil {
+			logger.Fatalf("Failed to open audit log: %v", err)
+		}
+		defer auditFile.Close()
+		pipe.SetAuditLog(auditFile)
+		logger.Printf("Audit log enabled: %s", cfg.Pipeline.Audit.Path)
+	}
+	if cfg.Pipeline.Audit.Table != "" {
+		connStr := cfg.Pipeline.Audit.ConnectionString
+		var provider audit.ConnectionStringProvider
+		if secrets != nil && vault.HasReferences(rawAuditConnStr) {
+			// Re-resolve on every new connection so rotated credentials are picked up
+			provider = func(ctx context.Context) (string, error) {
+				return secrets.Resolve(ctx, rawAuditConnStr)
 			}
+		}
+		if connStr == "" && pgSettings != nil {
+			// Share the sink's credentials, including IAM tokens and rotated secrets
+			connStr = pgSettings.ConnectionString
+			if sinkConnProvider != nil {
+				provider = audit.ConnectionStringProvider(sinkConnProvider)
+			}
+		}
+		if connStr == "" {
+			logger.Fatalf("pipeline.audit.table requires a connection_string or a postgresql sink")
+		}
+		var auditLog *audit.TableLog
+		var err error
+		if provider != nil {
+			auditLog, err = audit.NewRotatingTableLog(context.Background(), provider, cfg.Pipeline.Audit.Table)
+		} else {
+			auditLog, err = audit.NewTableLog(context.Background(), connStr, cfg.Pipeline.Audit.Table)
+		}
+		if err != nil {
+			logger.Fatalf("Failed to open audit log: %v", err)
+		}
+		defer auditLog.Close()
+		pipe.SetAuditLog(auditLog)
+		logger.Printf("Audit log enabled: table %s", cfg.Pipeline.Audit.Table)
+	}
 
-			// Convert settings to JSON and parse into FieldMapperConfig
-			settingsJSON, err := json.Marshal(cfg.Transformer.Settings)
-			if err != nil {
-				logger.Fatalf("Failed to marshal transformer settings: %v", err)
+	// Setup dead-letter queue if configured
+	if cfg.Pipeline.DLQ.Path != "" && cfg.Pipeline.DLQ.Table != "" {
+		logger.Fatalf("pipeline.dlq accepts either a path or a table, not both")
+	}
+	if cfg.Pipeline.DLQ.ManagementAPI && cfg.Pipeline.DLQ.Path == "" {
+		logger.Fatalf("pipeline.dlq.management_api requires pipeline.dlq.path")
+	}
+	var deadLetters *dlq.FileQueue
+	var rejects pipeline.DeadLetterQueue // deadLetters or the quarantine table, nil if neither is configured
+	if cfg.Pipeline.DLQ.Path != "" {
+		deadLetters, err = dlq.NewFileQueue(cfg.Pipeline.DLQ.Path, cfg.Pipeline.Name)
+		if err != nil {
+			logger.Fatalf("Failed to open dead-letter queue: %v", err)
+		}
+		defer deadLetters.Close()
+		rejects = deadLetters
+		logger.Printf("Dead-letter queue enabled: %s", cfg.Pipeline.DLQ.Path)
+	}
+	if cfg.Pipeline.DLQ.Table != "" {
+		connStr := cfg.Pipeline.DLQ.ConnectionString
+		var provider dlq.ConnectionStringProvider
+		if secrets != nil && vault.HasReferences(rawDLQConnStr) {
+			// Re-resolve on every new connection so rotated credentials are picked up
+			provider = func(ctx context.Context) (string, error) {
+				return secrets.Resolve(ctx, rawDLQConnStr)
+			}
+		}
+		if connStr == "" && pgSettings != nil {
+			// Quarantine rejects next to the data, with the sink's credentials
+			connStr = pgSettings.ConnectionString
+			if sinkConnProvider != nil {
+				provider = dlq.ConnectionStringProvider(sinkConnProvider)
 			}
+		}
+		if connStr == "" {
+			logger.Fatalf("pipeline.dlq.table requires a connection_string or a postgresql sink")
+		}
+		var quarantine *dlq.TableQueue
+		var err error
+		if provider != nil {
+			quarantine, err = dlq.NewRotatingTableQueue(context.Background(), provider, cfg.Pipeline.DLQ.Table, cfg.Pipeline.Name)
+		} else {
+			quarantine, err = dlq.NewTableQueue(context.Background(), connStr, cfg.Pipeline.DLQ.Table, cfg.Pipeline.Name)
+		}
+		if err != nil {
+			logger.Fatalf("Failed to open quarantine table: %v", err)
+		}
+		defer quarantine.Close()
+		rejects = quarantine
+		logger.Printf("Quarantine enabled: table %s", cfg.Pipeline.DLQ.Table)
+	}
+	if rejects != nil {
+		pipe.SetDeadLetterQueue(rejects)
+		if pgSink, ok := snk.(*sink.PostgreSQLSink); ok {
+			pgSink.SetDeadLetterQueue(pipe.AuditedDeadLetterQueue(rejects))
+		}
+	}
+
+	if dlqReplay {
+		if deadLetters == nil {
+			logger.Fatalf("dlq replay requires pipeline.dlq.path")
+		}
+		if replayOpts.Pipeline == "" {
+			replayOpts.Pipeline = cfg.Pipeline.Name
+		}
+		if opts.replayTransform {
+			replayOpts.Transformer = transformer
+		}
+		os.Exit(replayDeadLetters(snk, deadLetters, replayOpts, logger))
+	}
+
+	// Setup delivery mode
+	checkpointPath := cfg.Pipeline.Checkpoint.Path
+	switch cfg.Pipeline.Mode {
+	case "", "direct":
+	case "store_and_forward":
+		if cfg.Pipeline.WAL.Path == "" {
+			logger.Fatalf("store_and_forward mode requires pipeline.wal.path")
+		}
+		if cfg.Pipeline.Buffer.Path != "" {
+			logger.Fatalf("pipeline.buffer cannot be combined with store_and_forward mode")
+		}
+		wal, err := spool.Open(cfg.Pipeline.WAL.Path, spool.Options{
+			MaxBytes:     int64(cfg.Pipeline.WAL.MaxBytes),
+			SegmentBytes: int64(cfg.Pipeline.WAL.SegmentBytes),
+			ManualCommit: true,
+		})
+		if err != nil {
+			logger.Fatalf("Failed to open write-ahead log: %v", err)
+		}
+		defer wal.Close()
+		pipe.SetWriteAheadLog(wal)
+		if checkpointPath == "" {
+			checkpointPath = filepath.Join(cfg.Pipeline.WAL.Path, "checkpoints.json")
+		}
+		logger.Printf("Store-and-forward mode enabled: %s (%d events pending delivery)", cfg.Pipeline.WAL.Path, wal.Len())
+	default:
+		logger.Fatalf("Unsupported pipeline mode: %s", cfg.Pipeline.Mode)
+	}
+
+	// Setup checkpointing if configured
+	switch cfg.Pipeline.Checkpoint.Store {
+	case "", "file":
+	case "postgresql":
+		pgSink, ok := snk.(*sink.PostgreSQLSink)
+		if !ok {
+			logger.Fatalf("postgresql checkpoint store requires a postgresql sink")
+		}
+		pgSink.EnableTransactionalCheckpoints(cfg.Pipeline.Name, cfg.Pipeline.Checkpoint.Table)
+		pipe.SetCheckpointStore(pgSink)
+		checkpointPath = ""
+		logger.Printf("Transactional checkpointing enabled in PostgreSQL sink")
+	default:
+		logger.Fatalf("Unsupported checkpoint store: %s", cfg.Pipeline.Checkpoint.Store)
+	}
+	if checkpointPath != "" {
+		store, err := checkpoint.NewFileStore(checkpointPath)
+		if err != nil {
+			logger.Fatalf("Failed to open checkpoint store: %v", err)
+		}
+		pipe.SetCheckpointStore(store)
+		logger.Printf("Checkpointing enabled: %s", checkpointPath)
+	}
+
+	// Setup run history if configured
+	runsPath := cfg.Pipeline.Checkpoint.RunsPath
+	if runsPath == "" && checkpointPath != "" {
+		runsPath = filepath.Join(filepath.Dir(checkpointPath), "runs.json")
+	}
+	var runStore *checkpoint.FileRunStore
+	if runsPath != "" {
+		runStore, err = checkpoint.NewFileRunStore(runsPath, cfg.Pipeline.Checkpoint.MaxRuns)
+		if err != nil {
+			logger.Fatalf("Failed to open run history: %v", err)
+		}
+		pipe.SetRunStore(runStore)
+		logger.Printf("Run history enabled: %s", runsPath)
+	}
 
-			var fmConfig transform.FieldMapperConfig
-			if err := json.Unmarshal(settingsJSON, &fmConfig); err != nil {
-				logger.Fatalf("Failed to parse fieldmapper configuration: %v", err)
+	// Setup spill-to-disk buffer if configured
+	if cfg.Pipeline.Buffer.Path != "" {
+		// The buffer only absorbs an outage if the sink holds failing batches
+		// (pausing consumption) instead of dropping them
+		switch cfg.Sink.Type {
+		case "postgresql":
+			if pgSettings.BreakerFailureThreshold <= 0 {
+				logger.Fatalf("pipeline.buffer requires the postgresql sink's breaker_failure_threshold")
 			}
+		case "s3", "http_bulk", "snowflake":
+			logger.Fatalf("pipeline.buffer is not supported with the %s sink, which does not retry failed batches", cfg.Sink.Type)
+		}
+		buffer, err := spool.Open(cfg.Pipeline.Buffer.Path, spool.Options{
+			MaxBytes:     int64(cfg.Pipeline.Buffer.MaxBytes),
+			SegmentBytes: int64(cfg.Pipeline.Buffer.SegmentBytes),
+			ManualCommit: true,
+		})
+		if err != nil {
+			logger.Fatalf("Failed to open spill buffer: %v", err)
+		}
+		defer buffer.Close()
+		pipe.SetBuffer(buffer)
+		logger.Printf("Spill buffer enabled: %s (%d events pending replay)", cfg.Pipeline.Buffer.Path, buffer.Len())
+	}
 
-			fm, err := transform.NewFieldMapperWithLogger(fmConfig, logger)
-			if err != nil {
-				logger.Fatalf("Failed to create field mapper: %v", err)
+	// Setup event-size guardrails
+	sizeLimit, err := buildSizeLimit(cfg.Pipeline.Limits, cfg.Pipeline.KeyFields)
+	if err != nil {
+		logger.Fatalf("Invalid limits configuration: %v", err)
+	}
+	pipe.SetSizeLimit(sizeLimit)
+	if cfg.Pipeline.Limits.MaxMemoryBytes > 0 {
+		budget := pipeline.NewMemoryBudget(int64(cfg.Pipeline.Limits.MaxMemoryBytes))
+		pipe.SetMemoryBudget(budget)
+		if pgSink, ok := snk.(*sink.PostgreSQLSink); ok {
+			pgSink.SetMemoryBudget(budget)
+		}
+		logger.Printf("Memory budget enabled: %d bytes", cfg.Pipeline.Limits.MaxMemoryBytes)
+	}
+
+	// Setup delivery ordering
+	ordering, err := pipeline.ParseOrdering(cfg.Pipeline.Ordering)
+	if err != nil {
+		logger.Fatalf("Invalid pipeline configuration: %v", err)
+	}
+	pipe.SetOrdering(ordering, cfg.Pipeline.Workers)
+	pipe.SetKeyFields(cfg.Pipeline.KeyFields)
+
+	// Setup handling of drop, rename and other unsupported operations
+	actions := make(map[string]pipeline.OperationAction, len(cfg.Pipeline.Operations))
+	for operation, name := range cfg.Pipeline.Operations {
+		action, err := pipeline.ParseOperationAction(name)
+		if err != nil {
+			logger.Fatalf("Invalid pipeline operations configuration: %v", err)
+		}
+		if action == pipeline.OperationResync {
+			if cfg.Source.Type != "mongodb" || cfg.Sink.Type != "postgresql" {
+				logger.Fatalf("resync on %s requires a mongodb source and a postgresql sink", operation)
+			}
+			if cfg.Pipeline.Buffer.Path != "" || cfg.Pipeline.Mode == "store_and_forward" {
+				logger.Fatalf("resync on %s cannot be combined with pipeline.buffer or store_and_forward mode", operation)
 			}
-			transformer = fm
-		case "passthrough":
-			transformer = transform.NewPassThroughTransformer()
-		default:
-			logger.Fatalf("Unsupported transformer type: %s", cfg.Transformer.Type)
 		}
-	} else {
-		// Default to passthrough if no transformer configured
-		transformer = transform.NewPassThroughTransformer()
+		actions[operation] = action
+	}
+	if mongoSettings.rewatchOnInvalidate() {
+		// The source carries on after an invalidate unless configured otherwise
+		if _, ok := actions["invalidate"]; !ok {
+			actions["invalidate"] = pipeline.OperationIgnore
+		}
 	}
+	pipe.SetOperationPolicy(actions)
 
-	// Create pipeline
-	pipe := pipeline.New(cfg.Pipeline.Name, src, snk, transformer, logger)
+	// Setup the reaction to failed events
+	transformAction, err := pipeline.ParseTransformErrorAction(cfg.Pipeline.Errors.OnTransformError)
+	if err != nil {
+		logger.Fatalf("Invalid pipeline errors configuration: %v", err)
+	}
+	sinkAction, err := pipeline.ParseSinkErrorAction(cfg.Pipeline.Errors.OnSinkError)
+	if err != nil {
+		logger.Fatalf("Invalid pipeline errors configuration: %v", err)
+	}
+	if (transformAction == pipeline.TransformErrorDLQ || sinkAction == pipeline.SinkErrorDLQ) && rejects == nil {
+		logger.Println("Warning: pipeline.errors dead-letters events, but no pipeline.dlq is configured, so they are dropped")
+	}
+	if err := pipe.SetErrorPolicy(pipeline.ErrorPolicy{
+		OnTransformError:         transformAction,
+		OnSinkError:              sinkAction,
+		MaxErrorRate:             cfg.Pipeline.Errors.MaxErrorRate,
+		ErrorRateWindow:          cfg.Pipeline.Errors.ErrorRateWindowSeconds.Duration(),
+		MaxConsecutiveSinkErrors: cfg.Pipeline.Errors.MaxConsecutiveSinkErrors,
+	}); err != nil {
+		logger.Fatalf("Invalid pipeline errors configuration: %v", err)
+	}
+	if cfg.Pipeline.DrainTimeoutSeconds < 0 {
+		logger.Fatalf("Invalid pipeline configuration: drain_timeout_seconds cannot be negative")
+	}
+	pipe.SetDrainTimeout(cfg.Pipeline.DrainTimeoutSeconds.Duration())
+	if cfg.Pipeline.Watchdog.WindowSeconds < 0 {
+		logger.Fatalf("Invalid pipeline configuration: watchdog.window_seconds cannot be negative")
+	}
+	if cfg.Pipeline.Watchdog.RestartSource && cfg.Pipeline.Watchdog.WindowSeconds == 0 {
+		logger.Fatalf("Invalid pipeline configuration: watchdog.restart_source requires window_seconds")
+	}
+	pipe.SetWatchdog(pipeline.Watchdog{
+		Window:        cfg.Pipeline.Watchdog.WindowSeconds.Duration(),
+		RestartSource: cfg.Pipeline.Watchdog.RestartSource,
+	})
+	if opts.captureFile != "" {
+		captureFile, err := compress.Create(opts.captureFile, "", 0, false)
+		if err != nil {
+			logger.Fatalf("Failed to create capture file: %v", err)
+		}
+		defer captureFile.Close()
+		pipe.SetCapture(pipeline.Capture{Writer: captureFile, Duration: opts.captureDuration, MaxEvents: opts.captureEvents})
+		logger.Printf("Capturing source events to %s", opts.captureFile)
+	}
+	if start != nil {
+		pipe.SetStartPoint(*start)
+	}
+	pipe.SetEndPoint(end)
+	if throttle := cfg.Pipeline.Throttle; throttle.MaxSinkLatencyMs > 0 || throttle.MaxQueueDepth > 0 {
+		if throttle.MaxDelayMs < 0 || throttle.MaxBatchWindowFactor < 0 || throttle.MaxQueueDepth < 0 {
+			logger.Fatalf("Invalid pipeline configuration: throttle settings cannot be negative")
+		}
+		pipe.SetThrottle(pipeline.Throttle{
+			MaxSinkLatency:       throttle.MaxSinkLatencyMs.Duration(),
+			MaxQueueDepth:        throttle.MaxQueueDepth,
+			MaxDelay:             throttle.MaxDelayMs.Duration(),
+			MaxBatchWindowFactor: throttle.MaxBatchWindowFactor,
+		})
+		logger.Printf("Adaptive throttling enabled: sink latency %v, queue depth %d", throttle.MaxSinkLatencyMs, throttle.MaxQueueDepth)
+	}
+	if chaos := cfg.Pipeline.Chaos; chaos.DelayRate > 0 || chaos.ErrorRate > 0 || chaos.DuplicateRate > 0 {
+		for name, rate := range map[string]float64{"delay_rate": chaos.DelayRate, "error_rate": chaos.ErrorRate, "duplicate_rate": chaos.DuplicateRate} {
+			if rate < 0 || rate > 1 {
+				logger.Fatalf("Invalid pipeline configuration: chaos.%s must be between 0 and 1", name)
+			}
+		}
+		pipe.SetChaos(pipeline.Chaos{
+			DelayRate:     chaos.DelayRate,
+			MaxDelay:      chaos.MaxDelayMs.Duration(),
+			ErrorRate:     chaos.ErrorRate,
+			DuplicateRate: chaos.DuplicateRate,
+			Seed:          chaos.Seed,
+		})
+		logger.Printf("Warning: chaos fault injection enabled (delays %.0f%%, errors %.0f%%, duplicates %.0f%%), do not use in production",
+			chaos.DelayRate*100, chaos.ErrorRate*100, chaos.DuplicateRate*100)
+	}
+	if origin := cfg.Pipeline.Origin; origin.Name != "" || origin.Field != "" {
+		if err := pipe.SetOrigin(pipeline.Origin{Name: origin.Name, Field: origin.Field}); err != nil {
+			logger.Fatalf("Invalid pipeline configuration: %v", err)
+		}
+		if mongoSrc, ok := src.(*source.MongoDBSource); ok {
+			mongoSrc.SetOriginField(origin.Field)
+		}
+		logger.Printf("Marking written changes with origin %s in %s, skipping changes of other origins", origin.Name, origin.Field)
+	}
+
+	var tracer *pipeline.Tracer
+	if trace := cfg.Pipeline.Trace; trace.ID != "" || trace.Field != "" || trace.FieldMatch != "" {
+		tracer, err = pipeline.NewTracer(pipeline.TraceConfig{
+			IDPattern:    trace.ID,
+			Field:        trace.Field,
+			FieldPattern: trace.FieldMatch,
+		}, logger)
+		if err != nil {
+			logger.Fatalf("Invalid trace configuration: %v", err)
+		}
+		logger.Println("Event tracing is enabled")
+		pipe.SetTracer(tracer)
+	}
+
+	// Initial sync progress is served on the health endpoint and reported as metrics
+	syncProgress := pipeline.NewSyncProgress(cfg.Pipeline.Name, logger)
+	var resources *pipeline.ResourceAccounting // nil unless metrics.resource_accounting is set
+
+	if _, err := sink.ParseRefreshMode(cfg.Pipeline.Sync.Refresh); err != nil {
+		logger.Fatalf("Invalid sync configuration: %v", err)
+	}
+	if _, err := source.ParseFilter(cfg.Pipeline.Sync.Filter); err != nil {
+		logger.Fatalf("Invalid sync configuration: %v", err)
+	}
+
+	// Scheduled syncs replace change data capture
+	var syncSchedule *schedule.Schedule
+	if cfg.Pipeline.Schedule.Cron != "" {
+		parsed, err := schedule.Parse(cfg.Pipeline.Schedule.Cron)
+		if err != nil {
+			logger.Fatalf("Invalid schedule configuration: %v", err)
+		}
+		if parsed.Next(time.Now()).IsZero() {
+			logger.Fatalf("Invalid schedule configuration: %q never fires", cfg.Pipeline.Schedule.Cron)
+		}
+		syncSchedule = parsed
+	}
+	scheduled := syncSchedule != nil || cfg.Pipeline.Schedule.Once
+	if scheduled && (cfg.Source.Type != "mongodb" || cfg.Sink.Type != "postgresql") {
+		logger.Fatalf("Scheduled syncs require a mongodb source and a postgresql sink")
+	}
+	if (syncOnce || opts.command == cmdCutover) && (cfg.Source.Type != "mongodb" || cfg.Sink.Type != "postgresql") {
+		logger.Fatalf("%s requires a mongodb source and a postgresql sink", opts.command)
+	}
+
+	if opts.command == cmdValidate {
+		fmt.Printf("Configuration %s is valid\n", opts.configPath)
+		return
+	}
+	if opts.command == cmdVerify {
+		verifyCtx, stopVerify := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		code := verifyCounts(verifyCtx, cfg, src, snk, logger)
+		stopVerify()
+		os.Exit(code)
+	}
 
 	// Setup metrics if enabled
 	var metricsServer *metrics.Server
+	pushFinalMetrics := func() {}
 	if cfg.Pipeline.Metrics.Enabled {
 		metricsPort := cfg.Pipeline.Metrics.Port
 		if metricsPort == 0 {
@@ -106,18 +1021,82 @@ func main() {
 		}
 		
 		// Create metrics recorder
-		metricsRecorder, err := metrics.NewMetrics(cfg.Pipeline.Name)
+		metricsRecorder, err := metrics.NewRecorder(cfg.Pipeline.Name, metrics.Options{
+			Backend: metrics.Backend(cfg.Pipeline.Metrics.Backend),
+			Address: cfg.Pipeline.Metrics.StatsDAddress,
+			Prefix:  cfg.Pipeline.Metrics.Prefix,
+			Tags:    cfg.Pipeline.Metrics.Tags,
+		}, logger)
 		if err != nil {
 			logger.Fatalf("Failed to create metrics: %v", err)
 		}
+		defer metricsRecorder.Close()
 		pipe.SetMetrics(metricsRecorder)
+		syncProgress.SetMetrics(metricsRecorder)
+		if cfg.Pipeline.Metrics.Latency {
+			pipe.SetLatencyTracking(pipeline.LatencyTracking{TraceIDField: cfg.Pipeline.Metrics.TraceIDField})
+		} else if cfg.Pipeline.Metrics.TraceIDField != "" {
+			logger.Fatalf("Invalid metrics configuration: trace_id_field requires latency")
+		}
+		if cfg.Pipeline.Metrics.ResourceAccounting {
+			resources = pipeline.NewResourceAccounting(cfg.Pipeline.Name)
+			resources.SetMetrics(metricsRecorder)
+			pipe.SetResourceAccounting(resources)
+		}
+
+		// Push final metrics on shutdown for runs that end before being scraped
+		pusher := metrics.NewPusher(cfg.Pipeline.Name, metrics.PushOptions{
+			PushGateway:  cfg.Pipeline.Metrics.PushGateway,
+			Job:          cfg.Pipeline.Metrics.PushJob,
+			OTLPEndpoint: cfg.Pipeline.Metrics.OTLPEndpoint,
+			OTLPHeaders:  cfg.Pipeline.Metrics.OTLPHeaders,
+		}, logger)
+		if pusher.Enabled() {
+			if _, ok := metricsRecorder.(*metrics.Metrics); !ok {
+				logger.Fatalf("Pushing metrics requires the prometheus metrics backend")
+			}
+			pushFinalMetrics = func() {
+				pushCtx, pushCancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer pushCancel()
+				if err := pusher.Push(pushCtx); err != nil {
+					logger.Printf("Failed to push final metrics: %v", err)
+				}
+			}
+		}
 		
 		// Create health adapter
-		healthAdapter := &pipelineHealthAdapter{pipe: pipe}
+		healthAdapter := &pipelineHealthAdapter{pipe: pipe, progress: syncProgress}
 		
 		// Create and start metrics server
 		addr := fmt.Sprintf(":%d", metricsPort)
 		metricsServer = metrics.NewServer(addr, healthAdapter, logger)
+		metricsServer.SetStateInspector(&pipelineStateAdapter{pipe: pipe})
+		if cfg.Pipeline.Debug {
+			metricsServer.SetDebugInspector(&pipelineDebugAdapter{pipe: pipe})
+		}
+		if cfg.Pipeline.Metrics.TLSCertFile != "" || cfg.Pipeline.Metrics.TLSKeyFile != "" {
+			if err := metricsServer.SetTLS(metrics.TLSOptions{
+				CertFile:     cfg.Pipeline.Metrics.TLSCertFile,
+				KeyFile:      cfg.Pipeline.Metrics.TLSKeyFile,
+				ClientCAFile: cfg.Pipeline.Metrics.TLSClientCAFile,
+			}); err != nil {
+				logger.Fatalf("Invalid metrics TLS configuration: %v", err)
+			}
+		} else if cfg.Pipeline.Metrics.TLSClientCAFile != "" {
+			logger.Fatalf("metrics tls_client_ca_file requires tls_cert_file and tls_key_file")
+		}
+		if err := metricsServer.SetAuth(metrics.AuthOptions{
+			BearerToken:  cfg.Pipeline.Metrics.AuthToken,
+			Username:     cfg.Pipeline.Metrics.AuthUsername,
+			Password:     cfg.Pipeline.Metrics.AuthPassword,
+			PublicProbes: cfg.Pipeline.Metrics.PublicProbes,
+		}); err != nil {
+			logger.Fatalf("Invalid metrics auth configuration: %v", err)
+		}
+		if cfg.Pipeline.DLQ.ManagementAPI {
+			// After SetAuth and SetTLS, which decide whether entries may be changed
+			metricsServer.SetDeadLetterManager(&pipelineDeadLetterAdapter{pipe: pipe, queue: deadLetters})
+		}
 		if err := metricsServer.Start(); err != nil {
 			logger.Fatalf("Failed to start metrics server: %v", err)
 		}
@@ -128,6 +1107,22 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
+	// Keep leased secrets renewed
+	if secrets != nil {
+		go secrets.Run(ctx)
+	}
+
+	// Setup alerting if configured
+	alerts := buildAlertMonitor(cfg, pipe, logger)
+	if alerts != nil {
+		go alerts.Run(ctx)
+	}
+	alertStopped := func(err error) {
+		if alerts != nil && ctx.Err() == nil {
+			alerts.Stopped(context.Background(), err)
+		}
+	}
+
 	// Handle shutdown signals
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
@@ -147,28 +1142,413 @@ func main() {
 		}
 	}()
 
+	// Restart with the configuration once it changes
+	var reconfigured atomic.Bool
+	if opts.command == cmdRun && opts.configRefresh > 0 {
+		go watchConfig(ctx, opts, func() {
+			reconfigured.Store(true)
+			cancel()
+		}, logger)
+	}
+
+	// Reopen log files on SIGHUP so they can be rotated, and with
+	// -reload-on-sighup restart with the configuration as well
+	if opts.command == cmdRun {
+		hupChan := make(chan os.Signal, 1)
+		signal.Notify(hupChan, syscall.SIGHUP)
+		go func() {
+			for range hupChan {
+				logger.Println("Received SIGHUP, reopening log files")
+				if logs != nil {
+					if err := logs.Reopen(); err != nil {
+						logger.Printf("Failed to reopen log file: %v", err)
+					}
+				}
+				if auditFile != nil {
+					if err := auditFile.Reopen(); err != nil {
+						logger.Printf("Failed to reopen audit log: %v", err)
+					}
+				}
+				if !opts.reloadOnSIGHUP {
+					continue
+				}
+				if _, err := config.LoadProfile(opts.configPath, opts.profile); err != nil {
+					logger.Printf("Configuration is invalid, keeping the running configuration: %v", err)
+					continue
+				}
+				logger.Println("Reloading configuration, restarting the pipeline")
+				reconfigured.Store(true)
+				cancel()
+				return
+			}
+		}()
+	}
+
+	// Dump internal state on SIGQUIT instead of exiting
+	if cfg.Pipeline.Debug {
+		quitChan := make(chan os.Signal, 1)
+		signal.Notify(quitChan, syscall.SIGQUIT)
+		go func() {
+			for range quitChan {
+				dumpDebugState(pipe, logger)
+			}
+		}()
+	}
+
+	// Perform an initial sync and record its snapshot stats
+	runSync := func(syncCfg *config.Config) (pipeline.SnapshotStats, error) {
+		stats := pipeline.SnapshotStats{Pipeline: cfg.Pipeline.Name, StartedAt: time.Now()}
+		err := performInitialSync(ctx, syncCfg, src, snk, validator, transformer, sizeLimit, rejects, &stats, syncProgress, resources, tracer, logger)
+		stats.CompletedAt = time.Now()
+		stats.Status = pipeline.RunCompleted
+		if err != nil {
+			stats.Status = pipeline.RunFailed
+			stats.Error = err.Error()
+		}
+		if runStore != nil {
+			if err := runStore.SaveSnapshot(context.Background(), stats); err != nil {
+				logger.Printf("Failed to save snapshot stats: %v", err)
+			}
+		}
+		pipe.InitialSyncFinished(stats)
+		return stats, err
+	}
+	initialSync := func(syncCfg *config.Config) error {
+		_, err := runSync(syncCfg)
+		return err
+	}
+
+	if syncOnce {
+		stats, err := runSync(cfg)
+		alertStopped(err)
+		pushFinalMetrics()
+		os.Exit(reportSync(os.Stdout, stats, ctx.Err() != nil, logger))
+	}
+
+	if opts.command == cmdBench {
+		os.Exit(runBenchmark(ctx, cfg, pipe, opts.benchEvents, logger))
+	}
+
+	if scheduled {
+		err := runScheduledSyncs(ctx, syncSchedule, cfg.Pipeline.Schedule.Once, cfg.Pipeline.Sync.InitialSync, func() error {
+			return initialSync(cfg)
+		}, logger)
+		alertStopped(err)
+		pushFinalMetrics()
+		if err != nil && !reconfigured.Load() {
+			logger.Fatalf("Scheduled sync failed: %v", err)
+		}
+		logger.Println("Pipeline stopped")
+		if reconfigured.Load() {
+			reexec(logger)
+		}
+		fmt.Println("Goodbye!")
+		return
+	}
+
 	// Handle initial sync if configured
 	if cfg.Pipeline.Sync.InitialSync {
 		logger.Println("Initial sync is enabled")
-
-		// Perform initial sync
-		if err := performInitialSync(ctx, cfg, src, snk, transformer, logger); err != nil {
+		if err := initialSync(cfg); err != nil {
+			alertStopped(err)
+			pushFinalMetrics()
 			logger.Fatalf("Initial sync failed: %v", err)
 		}
 	}
 
-	// Run CDC pipeline
-	logger.Println("Starting CDC pipeline...")
-	if err := pipe.Run(ctx); err != nil {
-		logger.Fatalf("Pipeline error: %v", err)
+	// A cutover runs change data capture until SIGUSR1
+	runCtx := ctx
+	if opts.command == cmdCutover {
+		var startCutover context.CancelFunc
+		runCtx, startCutover = context.WithCancel(ctx)
+		defer startCutover()
+		usr1Chan := make(chan os.Signal, 1)
+		signal.Notify(usr1Chan, syscall.SIGUSR1)
+		go func() {
+			select {
+			case <-usr1Chan:
+				logger.Println("Received SIGUSR1, starting the cutover")
+				startCutover()
+			case <-ctx.Done():
+			}
+		}()
+		logger.Printf("Cutover: send SIGUSR1 to process %d once writes to the source are stopped", os.Getpid())
+	}
+
+	// Run CDC pipeline, resyncing whenever an operation policy asks for it
+	for {
+		logger.Println("Starting CDC pipeline...")
+		err := pipe.Run(runCtx)
+		if errors.Is(err, pipeline.ErrResyncRequired) && ctx.Err() == nil {
+			logger.Printf("%v, performing a full resync", err)
+			resyncCfg := *cfg
+			resyncCfg.Pipeline.Sync.ForceInitialSync = true
+			if err = initialSync(&resyncCfg); err == nil {
+				err = pipe.ResetPosition(ctx)
+			}
+			if err == nil {
+				continue
+			}
+			err = fmt.Errorf("resync failed: %w", err)
+		}
+		if err != nil {
+			alertStopped(err)
+			pushFinalMetrics()
+			logger.Fatalf("Pipeline error: %v", err)
+		}
+		break
+	}
+	if opts.command == cmdCutover && runCtx.Err() != nil && ctx.Err() == nil {
+		code := finishCutover(ctx, cfg, src, snk, pipe, opts, logger)
+		alertStopped(nil)
+		pushFinalMetrics()
+		os.Exit(code)
 	}
+	alertStopped(nil)
+	pushFinalMetrics()
 
 	logger.Println("Pipeline stopped")
+	if reconfigured.Load() {
+		reexec(logger)
+	}
 	fmt.Println("Goodbye!")
 }
 
+// watchConfig fetches the configuration every opts.configRefresh and calls
+// restart once it has changed, unless the changed configuration is invalid
+func watchConfig(ctx context.Context, opts options, restart func(), logger *log.Logger) {
+	last, err := config.Fingerprint(ctx, opts.configPath, opts.profile)
+	if err != nil {
+		logger.Printf("Config refresh failed: %v", err)
+	}
+	ticker := time.NewTicker(opts.configRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		fingerprint, err := config.Fingerprint(ctx, opts.configPath, opts.profile)
+		if err != nil {
+			logger.Printf("Config refresh failed, keeping the running configuration: %v", err)
+			continue
+		}
+		if fingerprint == last {
+			continue
+		}
+		last = fingerprint
+		if _, err := config.LoadProfile(opts.configPath, opts.profile); err != nil {
+			logger.Printf("Configuration changed but is invalid, keeping the running configuration: %v", err)
+			continue
+		}
+		logger.Println("Configuration changed, restarting the pipeline")
+		restart()
+		return
+	}
+}
+
+// reexec replaces the process with a new run of the same command line, which
+// loads the changed configuration
+func reexec(logger *log.Logger) {
+	executable, err := os.Executable()
+	if err == nil {
+		// The start point was used by this process; the next one resumes from the checkpoint
+		err = syscall.Exec(executable, withoutFlags(os.Args, "start-at", "start-after"), os.Environ())
+	}
+	logger.Fatalf("Failed to restart with the new configuration: %v", err)
+}
+
+// withoutFlags returns args without the named flags and their values
+func withoutFlags(args []string, names ...string) []string {
+	out := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		name, _, hasValue := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if !strings.HasPrefix(args[i], "-") || !slices.Contains(names, name) {
+			out = append(out, args[i])
+		} else if !hasValue {
+			i++
+		}
+	}
+	return out
+}
+
+// syncSummary is the JSON report printed by the sync-once command
+type syncSummary struct {
+	pipeline.SnapshotStats
+	DurationSeconds float64 `json:"duration_seconds"`
+}
+
+// reportSync prints the summary of a sync-once run and returns its exit code
+func reportSync(w io.Writer, stats pipeline.SnapshotStats, interrupted bool, logger *log.Logger) int {
+	summary := syncSummary{
+		SnapshotStats:   stats,
+		DurationSeconds: stats.CompletedAt.Sub(stats.StartedAt).Seconds(),
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(summary); err != nil {
+		logger.Printf("Failed to print sync summary: %v", err)
+	}
+
+	switch {
+	case interrupted:
+		return exitSyncInterrupted
+	case stats.Status != pipeline.RunCompleted || stats.Errors > 0:
+		return exitSyncFailed
+	case stats.Rejected > 0:
+		return exitSyncRejected
+	default:
+		return 0
+	}
+}
+
+// replayDeadLetters runs the dlq replay command, printing a JSON summary, and returns its exit code
+func replayDeadLetters(snk pipeline.Sink, deadLetters *dlq.FileQueue, opts dlq.ReplayOptions, logger *log.Logger) int {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	if err := snk.Connect(ctx); err != nil {
+		logger.Printf("Failed to connect sink: %v", err)
+		return exitReplayFailed
+	}
+	defer snk.Close()
+
+	logger.Printf("Replaying dead-lettered events of pipeline %s", opts.Pipeline)
+	result, err := dlq.Replay(ctx, deadLetters, snk, opts, logger)
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(result); err != nil {
+		logger.Printf("Failed to print replay summary: %v", err)
+	}
+
+	switch {
+	case err != nil:
+		logger.Printf("DLQ replay failed: %v", err)
+		return exitReplayFailed
+	case result.DeadLettered > 0:
+		return exitReplayDeadLettered
+	default:
+		return 0
+	}
+}
+
+// runScheduledSyncs performs the incremental syncs of the scheduled mode. With
+// once set it performs a single sync and returns its error; otherwise it syncs
+// at every time the schedule fires (and first on start, if runOnStart is set)
+// until the context is cancelled, logging failed syncs and retrying at the
+// next scheduled time.
+func runScheduledSyncs(ctx context.Context, sched *schedule.Schedule, once, runOnStart bool, sync func() error, logger *log.Logger) error {
+	if once {
+		logger.Println("Performing a one-off scheduled sync")
+		return sync()
+	}
+
+	if runOnStart {
+		if err := sync(); err != nil && ctx.Err() == nil {
+			logger.Printf("Scheduled sync failed: %v", err)
+		}
+	}
+	for {
+		next := sched.Next(time.Now())
+		logger.Printf("Next scheduled sync at %s", next.Format(time.RFC3339))
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		if err := sync(); err != nil && ctx.Err() == nil {
+			logger.Printf("Scheduled sync failed: %v", err)
+		}
+	}
+}
+
+// buildVaultResolver logs in to Vault and replaces secret references in the configuration
+func buildVaultResolver(cfg *config.Config, logger *log.Logger) (*vault.Resolver, error) {
+	client, err := vault.NewClient(vault.Config{
+		Address:   cfg.Vault.Address,
+		Namespace: cfg.Vault.Namespace,
+		Auth:      cfg.Vault.Auth,
+		MountPath: cfg.Vault.MountPath,
+		Token:     cfg.Vault.Token,
+		RoleID:    cfg.Vault.RoleID,
+		SecretID:  cfg.Vault.SecretID,
+		Role:      cfg.Vault.Role,
+		JWTPath:   cfg.Vault.JWTPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := client.Login(ctx); err != nil {
+		return nil, err
+	}
+
+	resolver := vault.NewResolver(client, logger)
+	for name, settings := range map[string]map[string]interface{}{
+		"source":      cfg.Source.Settings,
+		"sink":        cfg.Sink.Settings,
+		"transformer": cfg.Transformer.Settings,
+	} {
+		if err := resolver.ResolveSettings(ctx, settings); err != nil {
+			return nil, fmt.Errorf("%s settings: %w", name, err)
+		}
+	}
+	for _, value := range []*string{
+		&cfg.Pipeline.Audit.ConnectionString,
+		&cfg.Pipeline.Alerts.WebhookURL,
+		&cfg.Pipeline.Alerts.SlackWebhookURL,
+		&cfg.Pipeline.Alerts.PagerDutyRoutingKey,
+		&cfg.Pipeline.Metrics.AuthToken,
+		&cfg.Pipeline.Metrics.AuthPassword,
+	} {
+		if *value, err = resolver.Resolve(ctx, *value); err != nil {
+			return nil, err
+		}
+	}
+	logger.Println("Resolved secrets from Vault")
+	return resolver, nil
+}
+
+// buildAlertMonitor creates the alert monitor, or returns nil if alerting is not configured
+func buildAlertMonitor(cfg *config.Config, pipe *pipeline.Pipeline, logger *log.Logger) *alert.Monitor {
+	alerts := cfg.Pipeline.Alerts
+	var notifiers []alert.Notifier
+	if alerts.WebhookURL != "" {
+		notifiers = append(notifiers, alert.NewWebhook(alerts.WebhookURL))
+	}
+	if alerts.SlackWebhookURL != "" {
+		notifiers = append(notifiers, alert.NewSlack(alerts.SlackWebhookURL))
+	}
+	if alerts.PagerDutyRoutingKey != "" {
+		notifiers = append(notifiers, alert.NewPagerDuty(alerts.PagerDutyRoutingKey, alerts.PagerDutyURL))
+	}
+
+	rules := alert.Rules{
+		ErrorRatePerMinute: alerts.ErrorRatePerMinute,
+		MaxLag:             alerts.MaxLagSeconds.Duration(),
+		OnStop:             alerts.OnStop,
+	}
+	hasRules := rules.ErrorRatePerMinute > 0 || rules.MaxLag > 0 || rules.OnStop
+	if !hasRules && len(notifiers) == 0 {
+		return nil
+	}
+	if !hasRules || len(notifiers) == 0 {
+		logger.Fatalf("pipeline.alerts requires at least one rule and one notification target")
+	}
+
+	interval := alerts.CheckIntervalSeconds.Duration()
+	logger.Printf("Alerting enabled with %d notification target(s)", len(notifiers))
+	return alert.NewMonitor(cfg.Pipeline.Name, pipe, rules, notifiers, interval, logger)
+}
+
 // performInitialSync handles the initial synchronization of data
-func performInitialSync(ctx context.Context, cfg *config.Config, src pipeline.Source, snk pipeline.Sink, transformer pipeline.Transformer, logger *log.Logger) error {
+func performInitialSync(ctx context.Context, cfg *config.Config, src pipeline.Source, snk pipeline.Sink, validator pipeline.EventValidator, transformer pipeline.Transformer, sizeLimit pipeline.SizeLimit, deadLetters pipeline.DeadLetterQueue, stats *pipeline.SnapshotStats, progress *pipeline.SyncProgress, resources *pipeline.ResourceAccounting, tracer *pipeline.Tracer, logger *log.Logger) error {
 	// Type assert to access MongoDB-specific methods
 	mongoSrc, ok := src.(*source.MongoDBSource)
 	if !ok {
@@ -191,9 +1571,25 @@ func performInitialSync(ctx context.Context, cfg *config.Config, src pipeline.So
 
 	// Determine initial sync strategy
 	var fromTimestamp interface{}
+	staged := false // loading a staging table that is swapped in on success
 
 	if cfg.Pipeline.Sync.ForceInitialSync {
 		logger.Println("Force initial sync is enabled, syncing all data")
+		refresh, err := sink.ParseRefreshMode(cfg.Pipeline.Sync.Refresh)
+		if err != nil {
+			return err
+		}
+		switch refresh {
+		case sink.RefreshTruncate:
+			if err := pgSink.Truncate(ctx); err != nil {
+				return err
+			}
+		case sink.RefreshSwap:
+			if err := pgSink.BeginStagedRefresh(ctx); err != nil {
+				return err
+			}
+			staged = true
+		}
 	} else if cfg.Pipeline.Sync.TimestampField != "" {
 		// Check if sink table is empty
 		isEmpty, err := pgSink.IsTableEmpty(ctx)
@@ -221,17 +1617,33 @@ func performInitialSync(ctx context.Context, cfg *config.Config, src pipeline.So
 	}
 
 	// Prepare initial sync config
+	filter, err := source.ParseFilter(cfg.Pipeline.Sync.Filter)
+	if err != nil {
+		return err
+	}
 	syncConfig := source.InitialSyncConfig{
 		Enabled:        true,
 		TimestampField: cfg.Pipeline.Sync.TimestampField,
 		FromTimestamp:  fromTimestamp,
 		BatchSize:      cfg.Pipeline.Sync.BatchSize,
+		Filter:         filter,
 	}
 
 	if syncConfig.BatchSize <= 0 {
 		syncConfig.BatchSize = 1000
 	}
 
+	// Estimate the size of the sync so progress can be reported against it
+	estimate, err := mongoSrc.EstimateInitialSync(ctx, syncConfig)
+	if err != nil {
+		logger.Printf("Warning: failed to estimate initial sync size: %v", err)
+		estimate = pipeline.SyncEstimate{}
+	} else {
+		logger.Printf("Initial sync expects about %d documents (%d MB)", estimate.Documents, estimate.Bytes>>20)
+	}
+	progress.Start(estimate)
+	defer progress.Finish()
+
 	// Perform initial sync
 	logger.Println("Starting initial sync...")
 	events, errors := mongoSrc.PerformInitialSync(ctx, syncConfig)
@@ -241,19 +1653,67 @@ func performInitialSync(ctx context.Context, cfg *config.Config, src pipeline.So
 	go func() {
 		defer close(transformedEvents)
 		for event := range events {
+			atomic.AddInt64(&stats.Read, 1)
+			progress.Advance(event.ID)
+			resources.Read(event)
+			tracer.Follow(event)
+			if validator != nil {
+				validated, err := validator.Validate(event)
+				if err != nil {
+					logger.Printf("Rejecting event %s during initial sync: %v", event.ID, err)
+					atomic.AddInt64(&stats.Rejected, 1)
+					tracer.Done(pipeline.TraceRejected, event, err.Error())
+					if deadLetters != nil {
+						if err := deadLetters.Send(ctx, pipeline.WithRejectionRule(event, "schema_violation"), err.Error()); err != nil {
+							logger.Printf("Failed to dead-letter event %s: %v", event.ID, err)
+						}
+					}
+					continue
+				}
+				event = validated
+			}
 			if transformer != nil {
-				transformed, err := transformer.Transform(event)
+				transformed, err := transformer.Transform(ctx, event)
 				if err != nil {
 					logger.Printf("Error transforming event during initial sync: %v", err)
+					atomic.AddInt64(&stats.Rejected, 1)
+					tracer.Done(pipeline.TraceRejected, event, err.Error())
 					continue
 				}
 				event = transformed
+				tracer.Record(pipeline.TraceTransformed, event, "")
+			}
+
+			limited, err := sizeLimit.Apply(event)
+			if err != nil {
+				logger.Printf("Rejecting oversized event %s during initial sync: %v", event.ID, err)
+				atomic.AddInt64(&stats.Rejected, 1)
+				tracer.Done(pipeline.TraceRejected, event, err.Error())
+				if deadLetters != nil {
+					if err := deadLetters.Send(ctx, pipeline.WithRejectionRule(event, "oversized_event"), err.Error()); err != nil {
+						logger.Printf("Failed to dead-letter event %s: %v", event.ID, err)
+					}
+				}
+				continue
+			}
+			for _, e := range limited {
+				tracer.Record(pipeline.TraceHandoff, e, "")
+				transformedEvents <- e
+				atomic.AddInt64(&stats.Documents, 1)
 			}
-			transformedEvents <- event
 		}
 	}()
 
-	// Write to sink
+	// Write to sink, counting committed events. The pipeline installs its own
+	// handler when it next runs.
+	pgSink.SetCommitHandler(func(events []pipeline.Event, err error) {
+		if err == nil {
+			atomic.AddInt64(&stats.Written, int64(len(events)))
+			resources.Written(events)
+		}
+		tracer.Committed(events, err)
+	})
+	defer pgSink.SetCommitHandler(nil)
 	sinkErrors := pgSink.Write(ctx, transformedEvents)
 
 	// Handle errors from both channels concurrently
@@ -265,6 +1725,7 @@ func performInitialSync(ctx context.Context, cfg *config.Config, src pipeline.So
 		defer wg.Done()
 		for err := range errors {
 			logger.Printf("Initial sync source error: %v", err)
+			atomic.AddInt64(&stats.Errors, 1)
 			errorOccurred = true
 		}
 	}()
@@ -273,23 +1734,200 @@ func performInitialSync(ctx context.Context, cfg *config.Config, src pipeline.So
 		defer wg.Done()
 		for err := range sinkErrors {
 			logger.Printf("Initial sync sink error: %v", err)
+			atomic.AddInt64(&stats.Errors, 1)
 			errorOccurred = true
 		}
 	}()
 
 	wg.Wait()
 
-	if errorOccurred {
+	// A cancelled sync is incomplete, so its staging table must not be swapped in
+	if errorOccurred || (staged && ctx.Err() != nil) {
+		if staged {
+			if err := pgSink.AbortStagedRefresh(context.Background()); err != nil {
+				logger.Printf("Failed to discard staging table: %v", err)
+			}
+		}
+		if !errorOccurred {
+			return fmt.Errorf("initial sync interrupted: %w", ctx.Err())
+		}
 		return fmt.Errorf("errors occurred during initial sync")
 	}
+	if staged {
+		if err := pgSink.FinishStagedRefresh(ctx); err != nil {
+			return err
+		}
+	}
+	if err := pgSink.CreateIndexes(ctx); err != nil {
+		return err
+	}
 
 	logger.Println("Initial sync completed successfully")
 	return nil
 }
 
+// buildTransformer creates the configured transformer, defaulting to passthrough
+func buildTransformer(cfg config.TransformerConfig, logger *log.Logger) (pipeline.Transformer, error) {
+	switch cfg.Type {
+	case "fieldmapper":
+		// Parse field mapper configuration
+		if _, ok := cfg.Settings["mappings"]; !ok {
+			return nil, fmt.Errorf("fieldmapper transformer requires 'mappings' configuration")
+		}
+
+		var fmConfig transform.FieldMapperConfig
+		if err := cfg.Decode(&fmConfig); err != nil {
+			return nil, fmt.Errorf("failed to parse fieldmapper configuration: %w", err)
+		}
+
+		fm, err := transform.NewFieldMapperWithLogger(fmConfig, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create field mapper: %w", err)
+		}
+		return fm, nil
+	case "router":
+		var routerConfig struct {
+			transform.RouterConfig
+			Transformer config.TransformerConfig `json:"transformer"`
+		}
+		if err := cfg.Decode(&routerConfig); err != nil {
+			return nil, fmt.Errorf("failed to parse router configuration: %w", err)
+		}
+		if routerConfig.Transformer.Type == "router" {
+			return nil, fmt.Errorf("router transformer cannot wrap another router")
+		}
+
+		next, err := buildTransformer(routerConfig.Transformer, logger)
+		if err != nil {
+			return nil, err
+		}
+		return transform.NewRouter(routerConfig.RouterConfig, next)
+	case "versioned":
+		var versionedConfig struct {
+			transform.VersionedConfig
+			Transformer config.TransformerConfig `json:"transformer"`
+		}
+		if err := cfg.Decode(&versionedConfig); err != nil {
+			return nil, fmt.Errorf("failed to parse versioned configuration: %w", err)
+		}
+
+		var next pipeline.Transformer
+		if versionedConfig.Transformer.Type != "" {
+			var err error
+			if next, err = buildTransformer(versionedConfig.Transformer, logger); err != nil {
+				return nil, err
+			}
+		}
+		return transform.NewVersioned(versionedConfig.VersionedConfig, next)
+	case "plugin":
+		var pluginConfig plugin.Config
+		if err := cfg.Decode(&pluginConfig); err != nil {
+			return nil, fmt.Errorf("failed to parse plugin configuration: %w", err)
+		}
+		return plugin.NewTransformer(pluginConfig, logger)
+	case "", "passthrough":
+		return transform.NewPassThroughTransformer(), nil
+	default:
+		return nil, fmt.Errorf("unsupported transformer type: %s", cfg.Type)
+	}
+}
+
+// expectedColumns returns the columns the transformer writes, typed by their
+// format or else by the source collection's schema, and whether they are all
+// the columns it writes. Without a field mapper they are the schema's
+// top-level fields.
+func expectedColumns(transformer pipeline.Transformer, declared *schema.Schema) ([]sink.ExpectedColumn, bool) {
+	fieldTypes := make(map[string]schema.FieldType)
+	if declared != nil {
+		for _, field := range declared.Fields {
+			fieldTypes[field.Name] = field.Type
+		}
+	}
+
+	fm, ok := transformer.(*transform.FieldMapper)
+	if !ok {
+		if declared == nil {
+			return nil, false
+		}
+		var columns []sink.ExpectedColumn
+		for _, field := range declared.Fields {
+			if !strings.Contains(field.Name, ".") {
+				columns = append(columns, sink.ExpectedColumn{Name: field.Name, Type: schemaColumnType(field.Type)})
+			}
+		}
+		return columns, declared.Strict
+	}
+
+	fields, includeAll := fm.Fields()
+	columns := make([]sink.ExpectedColumn, 0, len(fields))
+	for _, field := range fields {
+		column := sink.ExpectedColumn{Name: field.Destination}
+		switch field.Format {
+		case "string", "uppercase", "lowercase", "trim", "titlecase":
+			column.Type = sink.ColumnText
+		case "int":
+			column.Type = sink.ColumnInteger
+		case "float":
+			column.Type = sink.ColumnNumeric
+		case "bool", "boolean":
+			column.Type = sink.ColumnBoolean
+		case "date", "datetime":
+			column.Type = sink.ColumnTimestamp
+		case "":
+			source := field.Source
+			if field.NestedPath != "" {
+				source = field.NestedPath
+			}
+			column.Type = schemaColumnType(fieldTypes[source])
+		}
+		columns = append(columns, column)
+	}
+	sort.Slice(columns, func(i, j int) bool { return columns[i].Name < columns[j].Name })
+	return columns, !includeAll
+}
+
+// schemaColumnType returns the column type a schema field type is written as
+func schemaColumnType(fieldType schema.FieldType) sink.ColumnType {
+	switch fieldType {
+	case schema.String, schema.ObjectID:
+		return sink.ColumnText
+	case schema.Int:
+		return sink.ColumnInteger
+	case schema.Float:
+		return sink.ColumnNumeric
+	case schema.Bool:
+		return sink.ColumnBoolean
+	case schema.Timestamp:
+		return sink.ColumnTimestamp
+	case schema.Object, schema.Array:
+		return sink.ColumnJSON
+	default:
+		return sink.ColumnAny
+	}
+}
+
+// buildSizeLimit converts limits configuration into a pipeline size limit
+func buildSizeLimit(cfg config.LimitsConfig, keyFields []string) (pipeline.SizeLimit, error) {
+	policy, err := pipeline.ParseOversizePolicy(cfg.OversizedPolicy)
+	if err != nil {
+		return pipeline.SizeLimit{}, err
+	}
+	if cfg.SplitKeyField != "" {
+		keyFields = []string{cfg.SplitKeyField}
+	}
+	return pipeline.SizeLimit{
+		MaxBytes:       int(cfg.MaxEventSize),
+		Policy:         policy,
+		TruncateFields: cfg.TruncateFields,
+		TruncateLength: cfg.TruncateLength,
+		KeyFields:      keyFields,
+	}, nil
+}
+
 // pipelineHealthAdapter adapts pipeline.Pipeline to metrics.HealthChecker interface
 type pipelineHealthAdapter struct {
-	pipe *pipeline.Pipeline
+	pipe     *pipeline.Pipeline
+	progress *pipeline.SyncProgress
 }
 
 func (a *pipelineHealthAdapter) IsHealthy() bool {
@@ -305,5 +1943,184 @@ func (a *pipelineHealthAdapter) GetStatus() metrics.HealthStatus {
 		SinkConnected:   status.SinkConnected,
 		LastEventTime:   status.LastEventTime,
 		UptimeSeconds:   status.UptimeSeconds,
+		CircuitBreaker:  status.CircuitBreaker,
+		SourceStalled:   status.SourceStalled,
+		InitialSync:     a.initialSyncStatus(),
+	}
+}
+
+// initialSyncStatus reports the current or last initial sync, or nil if none has run
+func (a *pipelineHealthAdapter) initialSyncStatus() *metrics.InitialSyncStatus {
+	if a.progress == nil {
+		return nil
+	}
+	state := a.progress.State()
+	if state.StartedAt.IsZero() {
+		return nil
+	}
+	return &metrics.InitialSyncStatus{
+		Active:          state.Active,
+		StartedAt:       state.StartedAt.Format(time.RFC3339),
+		DocumentsCopied: state.Copied,
+		EstimatedTotal:  state.EstimatedTotal,
+		EstimatedBytes:  state.EstimatedBytes,
+		PercentComplete: state.Percent,
+		ETASeconds:      int64(state.ETA.Seconds()),
+		CurrentID:       state.CurrentID,
+	}
+}
+
+// pipelineDebugAdapter adapts pipeline.Pipeline to metrics.DebugInspector interface
+type pipelineDebugAdapter struct {
+	pipe *pipeline.Pipeline
+}
+
+func (a *pipelineDebugAdapter) DebugState() interface{} {
+	return a.pipe.DebugState()
+}
+
+// pipelineDeadLetterAdapter adapts the pipeline's dead-letter queue to the metrics.DeadLetterManager interface
+type pipelineDeadLetterAdapter struct {
+	pipe  *pipeline.Pipeline
+	queue *dlq.FileQueue
+}
+
+func (a *pipelineDeadLetterAdapter) DeadLetters(ctx context.Context, filter metrics.DeadLetterFilter) ([]metrics.DeadLetter, error) {
+	entries, err := a.queue.List(dlq.Filter{
+		Pipeline:        a.pipe.Name(),
+		EventID:         filter.EventID,
+		Reason:          filter.Reason,
+		IncludeResolved: filter.IncludeResolved,
+	})
+	if err != nil {
+		return nil, err
+	}
+	letters := make([]metrics.DeadLetter, 0, len(entries))
+	for _, entry := range entries {
+		letter := metrics.DeadLetter{
+			ID:       entry.ID,
+			Pipeline: entry.Pipeline,
+			Reason:   entry.Reason,
+			Attempts: entry.Attempts,
+			FailedAt: entry.FailedAt.Format(time.RFC3339),
+			Event:    entry.Event,
+		}
+		if entry.ResolvedAt != nil {
+			letter.ResolvedAt = entry.ResolvedAt.Format(time.RFC3339)
+		}
+		letters = append(letters, letter)
+	}
+	return letters, nil
+}
+
+func (a *pipelineDeadLetterAdapter) DeleteDeadLetter(ctx context.Context, id string) error {
+	if _, err := a.entry(id); err != nil {
+		return err
+	}
+	return a.notFound(a.queue.Delete(id))
+}
+
+func (a *pipelineDeadLetterAdapter) ResubmitDeadLetter(ctx context.Context, id string) error {
+	entry, err := a.entry(id)
+	if err != nil {
+		return err
+	}
+	if err := a.pipe.Resubmit(ctx, entry.Event); err != nil {
+		if errors.Is(err, pipeline.ErrNotRunning) {
+			return metrics.ErrPipelineNotRunning
+		}
+		return err
+	}
+	return a.notFound(a.queue.Resolve(id))
+}
+
+// entry returns an entry of this pipeline
+func (a *pipelineDeadLetterAdapter) entry(id string) (dlq.Entry, error) {
+	entry, err := a.queue.Get(id)
+	if err == nil && entry.Pipeline != a.pipe.Name() {
+		err = dlq.ErrNotFound
+	}
+	return entry, a.notFound(err)
+}
+
+// notFound maps the queue's not-found error to the one the API reports
+func (a *pipelineDeadLetterAdapter) notFound(err error) error {
+	if errors.Is(err, dlq.ErrNotFound) {
+		return metrics.ErrDeadLetterNotFound
+	}
+	return err
+}
+
+// dumpDebugState logs the pipeline's internal state and every goroutine's stack
+func dumpDebugState(pipe *pipeline.Pipeline, logger *log.Logger) {
+	state, err := json.MarshalIndent(pipe.DebugState(), "", "  ")
+	if err != nil {
+		logger.Printf("Failed to encode pipeline state: %v", err)
+	} else {
+		logger.Printf("Pipeline state:\n%s", state)
+	}
+	logger.Println("Goroutine stacks:")
+	if err := pprof.Lookup("goroutine").WriteTo(logger.Writer(), 2); err != nil {
+		logger.Printf("Failed to dump goroutine stacks: %v", err)
+	}
+}
+
+// pipelineStateAdapter adapts pipeline.Pipeline to metrics.StateInspector interface
+type pipelineStateAdapter struct {
+	pipe *pipeline.Pipeline
+}
+
+func (a *pipelineStateAdapter) PipelineName() string {
+	return a.pipe.Name()
+}
+
+func (a *pipelineStateAdapter) Checkpoint(ctx context.Context) (metrics.CheckpointState, error) {
+	state := metrics.CheckpointState{Pipeline: a.pipe.Name()}
+	cp, err := a.pipe.Checkpoint(ctx)
+	if err != nil {
+		return state, err
+	}
+	if cp != nil {
+		state.Position = cp.Position
+		state.UpdatedAt = cp.UpdatedAt.Format(time.RFC3339)
+	}
+	snapshot, err := a.pipe.Snapshot(ctx)
+	if err != nil {
+		return state, err
+	}
+	if snapshot != nil {
+		state.Snapshot = &metrics.SnapshotState{
+			Status:      snapshot.Status,
+			StartedAt:   snapshot.StartedAt.Format(time.RFC3339),
+			CompletedAt: snapshot.CompletedAt.Format(time.RFC3339),
+			Documents:   snapshot.Documents,
+			Errors:      snapshot.Errors,
+			Error:       snapshot.Error,
+		}
+	}
+	return state, nil
+}
+
+func (a *pipelineStateAdapter) Runs(ctx context.Context, limit int) ([]metrics.RunSummary, error) {
+	runs, err := a.pipe.Runs(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	summaries := make([]metrics.RunSummary, 0, len(runs))
+	for _, run := range runs {
+		summary := metrics.RunSummary{
+			ID:              run.ID,
+			Status:          run.Status,
+			StartedAt:       run.StartedAt.Format(time.RFC3339),
+			StartPosition:   run.StartPosition,
+			EventsProcessed: run.EventsProcessed,
+			EventsRejected:  run.EventsRejected,
+			Error:           run.Error,
+		}
+		if run.EndedAt != nil {
+			summary.EndedAt = run.EndedAt.Format(time.RFC3339)
+		}
+		summaries = append(summaries, summary)
 	}
+	return summaries, nil
 }
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/config"
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"github.com/IEatCodeDaily/data-pipe/pkg/sink"
+)
+
+// replSession holds what the repl runs pasted documents through
+type replSession struct {
+	configPath  string
+	profile     string
+	collection  string
+	operation   string
+	transformer pipeline.Transformer
+	validator   pipeline.EventValidator
+	pgSink      *sink.PostgreSQLSink // nil unless the sink is PostgreSQL
+	logger      *log.Logger
+}
+
+const replHelp = `Paste a JSON document (MongoDB extended JSON is accepted) to see it
+validated, transformed and the SQL written for it. Commands:
+  :op insert|update|replace|delete  operation of the following documents (default: insert)
+  :reload                           re-read the transformer from the configuration file
+  :help                             show this help
+  :quit                             exit`
+
+// runREPL reads documents from in until it ends or :quit, writing what the
+// pipeline would do with each to out, and returns the exit code
+func runREPL(in io.Reader, out io.Writer, s *replSession) int {
+	fmt.Fprintln(out, replHelp)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16<<20)
+	var pending strings.Builder
+	prompt := func() {
+		if pending.Len() == 0 {
+			fmt.Fprint(out, "> ")
+		} else {
+			fmt.Fprint(out, ". ")
+		}
+	}
+	for prompt(); scanner.Scan(); prompt() {
+		line := scanner.Text()
+		if pending.Len() == 0 {
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" {
+				continue
+			}
+			if strings.HasPrefix(trimmed, ":") {
+				if !s.command(out, trimmed) {
+					return 0
+				}
+				continue
+			}
+		}
+
+		// A document may span lines, it is read until it is complete
+		pending.WriteString(line)
+		pending.WriteByte('\n')
+		var raw json.RawMessage
+		if err := json.NewDecoder(strings.NewReader(pending.String())).Decode(&raw); err == io.ErrUnexpectedEOF {
+			continue
+		}
+		input := pending.String()
+		pending.Reset()
+		var doc bson.M
+		if err := bson.UnmarshalExtJSON([]byte(input), false, &doc); err != nil {
+			fmt.Fprintf(out, "Invalid document: %v\n", err)
+			continue
+		}
+		s.preview(out, doc)
+	}
+	fmt.Fprintln(out)
+	if err := scanner.Err(); err != nil {
+		s.logger.Printf("repl failed: %v", err)
+		return 1
+	}
+	return 0
+}
+
+// command runs a repl command, returning false when the session ends
+func (s *replSession) command(out io.Writer, line string) bool {
+	fields := strings.Fields(line)
+	switch fields[0] {
+	case ":quit", ":q", ":exit":
+		return false
+	case ":help":
+		fmt.Fprintln(out, replHelp)
+	case ":op":
+		if len(fields) != 2 {
+			fmt.Fprintf(out, "Operation: %s\n", s.operation)
+			break
+		}
+		switch fields[1] {
+		case "insert", "update", "replace", "delete":
+			s.operation = fields[1]
+			fmt.Fprintf(out, "Operation: %s\n", s.operation)
+		default:
+			fmt.Fprintf(out, "Unknown operation %q, expected insert, update, replace or delete\n", fields[1])
+		}
+	case ":reload":
+		if err := s.reload(); err != nil {
+			fmt.Fprintf(out, "Reload failed, keeping the previous transformer: %v\n", err)
+			break
+		}
+		fmt.Fprintf(out, "Reloaded the transformer from %s\n", s.configPath)
+	default:
+		fmt.Fprintf(out, "Unknown command %s, :help lists the commands\n", fields[0])
+	}
+	return true
+}
+
+// reload rebuilds the transformer from the configuration file, so mappings
+// can be edited while the session runs
+func (s *replSession) reload() error {
+	cfg, err := config.LoadProfile(s.configPath, s.profile)
+	if err != nil {
+		return err
+	}
+	transformer, err := buildTransformer(cfg.Transformer, s.logger)
+	if err != nil {
+		return err
+	}
+	if closer, ok := s.transformer.(io.Closer); ok {
+		closer.Close()
+	}
+	s.transformer = transformer
+	return nil
+}
+
+// preview validates and transforms a document as the pipeline would and
+// prints the result with the statements the sink would run for it
+func (s *replSession) preview(out io.Writer, doc bson.M) {
+	event := pipeline.Event{
+		ID:         fmt.Sprintf("%v", doc["_id"]),
+		Timestamp:  time.Now(),
+		Operation:  s.operation,
+		Source:     "repl",
+		Collection: s.collection,
+		Data:       map[string]interface{}(doc),
+	}
+	if s.validator != nil {
+		validated, err := s.validator.Validate(event)
+		if err != nil {
+			fmt.Fprintf(out, "Rejected by the schema: %v\n", err)
+			return
+		}
+		event = validated
+	}
+	if s.transformer != nil {
+		transformed, err := s.transformer.Transform(context.Background(), event)
+		if err != nil {
+			fmt.Fprintf(out, "Transform failed: %v\n", err)
+			return
+		}
+		event = transformed
+	}
+
+	output, err := json.MarshalIndent(event.Data, "", "  ")
+	if err != nil {
+		fmt.Fprintf(out, "Failed to print the transformed document: %v\n", err)
+		return
+	}
+	fmt.Fprintln(out, string(output))
+
+	if s.pgSink == nil {
+		return
+	}
+	statements, err := s.pgSink.Statements(event)
+	if err != nil {
+		fmt.Fprintf(out, "The sink would reject the document: %v\n", err)
+		return
+	}
+	for _, statement := range statements {
+		fmt.Fprintf(out, "%s;\n", statement.Query)
+		for i, arg := range statement.Args {
+			fmt.Fprintf(out, "  -- $%d = %v\n", i+1, arg)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/anonymize"
+	"github.com/IEatCodeDaily/data-pipe/pkg/compress"
+	"github.com/IEatCodeDaily/data-pipe/pkg/config"
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"github.com/IEatCodeDaily/data-pipe/pkg/source"
+)
+
+// buildAnonymizer creates the anonymizer of the pipeline.pii rules
+func buildAnonymizer(pii config.PIIConfig) (*anonymize.Anonymizer, error) {
+	fallback, err := anonymize.ParseAction(pii.Default)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]anonymize.Rule, len(pii.Fields))
+	for i, field := range pii.Fields {
+		rules[i] = anonymize.Rule{Field: field.Field, Action: anonymize.Action(field.Action)}
+	}
+	return anonymize.New(rules, fallback)
+}
+
+// exportSample writes sampled documents of the source as insert events, the
+// format replay and the file source read, and returns the exit code
+func exportSample(cfg *config.Config, settings *mongoSourceSettings, src pipeline.Source, opts options, logger *log.Logger) int {
+	mongoSrc, ok := src.(*source.MongoDBSource)
+	if !ok || settings == nil {
+		logger.Printf("sample requires a mongodb source")
+		return 1
+	}
+	var anonymizer *anonymize.Anonymizer
+	if opts.sampleAnonymize {
+		var err error
+		if anonymizer, err = buildAnonymizer(cfg.Pipeline.PII); err != nil {
+			logger.Printf("Invalid pii configuration: %v", err)
+			return 1
+		}
+	} else {
+		logger.Println("Warning: exporting documents as they are, -anonymize masks personal data")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), sampleTimeout)
+	defer cancel()
+	if err := mongoSrc.Connect(ctx); err != nil {
+		logger.Printf("Failed to connect to MongoDB: %v", err)
+		return 1
+	}
+	defer mongoSrc.Close()
+	documents, err := mongoSrc.Sample(ctx, opts.sampleCount)
+	if err != nil {
+		logger.Printf("Sample failed: %v", err)
+		return 1
+	}
+
+	var out io.Writer = os.Stdout
+	if opts.sampleOutput != "" {
+		file, err := compress.Create(opts.sampleOutput, "", 0, false)
+		if err != nil {
+			logger.Printf("Failed to create sample file: %v", err)
+			return 1
+		}
+		defer file.Close()
+		out = file
+	}
+	encoder := json.NewEncoder(out)
+	for _, document := range documents {
+		if anonymizer != nil {
+			document = anonymizer.Document(document)
+		}
+		event := pipeline.Event{
+			ID:         fmt.Sprintf("%v", document["_id"]),
+			Operation:  "insert",
+			Source:     "mongodb",
+			Database:   settings.Database,
+			Collection: settings.Collection,
+			Data:       document,
+			Key:        map[string]interface{}{"_id": document["_id"]},
+		}
+		if err := encoder.Encode(event); err != nil {
+			logger.Printf("Failed to write sample: %v", err)
+			return 1
+		}
+	}
+	logger.Printf("Exported %d sampled documents", len(documents))
+	return 0
+}
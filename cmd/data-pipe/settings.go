@@ -0,0 +1,222 @@
+package main
+
+import (
+	"github.com/IEatCodeDaily/data-pipe/pkg/config"
+	"github.com/IEatCodeDaily/data-pipe/pkg/plugin"
+	"github.com/IEatCodeDaily/data-pipe/pkg/sink"
+	mongooptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Typed settings of each source and sink, decoded with config.DecodeSettings;
+// see README.md for their meaning
+
+// mongoSourceSettings are the settings of the mongodb source
+type mongoSourceSettings struct {
+	URI                      string              `json:"uri" validate:"required"`
+	Database                 string              `json:"database" validate:"required"`
+	Collection               string              `json:"collection"`
+	IAMAuth                  bool                `json:"iam_auth"`
+	RewatchOnInvalidate      *bool               `json:"rewatch_on_invalidate"`
+	IncludeFields            []string            `json:"include_fields"`
+	ExcludeFields            []string            `json:"exclude_fields"`
+	BatchMaxEvents           int                 `json:"batch_max_events" validate:"min=0"`
+	BatchWindowMs            config.Milliseconds `json:"batch_window_ms" validate:"min=0"`
+	HeartbeatIntervalSeconds config.Seconds      `json:"heartbeat_interval_seconds" validate:"min=0"`
+	IDStrategy               string              `json:"id_strategy"`
+	IDFields                 []string            `json:"id_fields"`
+	Collation                *collationSettings  `json:"collation"`
+	GridFSBucket             string              `json:"gridfs_bucket"`
+	GridFSContent            bool                `json:"gridfs_content"`
+}
+
+// collection returns the source collection, or "" if the source is not mongodb
+func (s *mongoSourceSettings) collection() string {
+	if s == nil {
+		return ""
+	}
+	return s.Collection
+}
+
+// rewatchOnInvalidate reports whether the source re-opens an invalidated
+// change stream, which it does unless disabled
+func (s *mongoSourceSettings) rewatchOnInvalidate() bool {
+	return s != nil && (s.RewatchOnInvalidate == nil || *s.RewatchOnInvalidate)
+}
+
+// collationSettings are the collation of initial sync queries
+type collationSettings struct {
+	Locale          string `json:"locale"`
+	Strength        int    `json:"strength"`
+	CaseLevel       bool   `json:"case_level"`
+	NumericOrdering bool   `json:"numeric_ordering"`
+}
+
+// collation returns the driver collation, or nil if none is configured
+func (c *collationSettings) collation() *mongooptions.Collation {
+	if c == nil {
+		return nil
+	}
+	return &mongooptions.Collation{Locale: c.Locale, Strength: c.Strength, CaseLevel: c.CaseLevel, NumericOrdering: c.NumericOrdering}
+}
+
+// fileSourceSettings are the settings of the file source
+type fileSourceSettings struct {
+	Path        string `json:"path" validate:"required"`
+	Compression string `json:"compression"`
+}
+
+// generatorSourceSettings are the settings of the generator source
+type generatorSourceSettings struct {
+	Rate        int    `json:"rate" validate:"min=0"`
+	Count       int    `json:"count" validate:"min=0"`
+	Fields      int    `json:"fields" validate:"min=0"`
+	FieldSize   int    `json:"field_size" validate:"min=0"`
+	Cardinality int    `json:"cardinality" validate:"min=0"`
+	Operations  string `json:"operations"`
+	Seed        int64  `json:"seed"`
+}
+
+// outboxSourceSettings are the settings of the outbox source
+type outboxSourceSettings struct {
+	ConnectionString         string         `json:"connection_string" validate:"required"`
+	Table                    string         `json:"table"`
+	IDColumn                 string         `json:"id_column"`
+	AggregateColumn          string         `json:"aggregate_column"`
+	PayloadColumn            string         `json:"payload_column"`
+	CreatedAtColumn          string         `json:"created_at_column"`
+	ProcessedColumn          string         `json:"processed_column"`
+	OperationColumn          string         `json:"operation_column"`
+	BatchSize                int            `json:"batch_size" validate:"min=0"`
+	PollIntervalSeconds      config.Seconds `json:"poll_interval_seconds" validate:"min=0"`
+	HeartbeatIntervalSeconds config.Seconds `json:"heartbeat_interval_seconds" validate:"min=0"`
+	AckOnCommit              bool           `json:"ack_on_commit"`
+}
+
+// pluginSourceSettings are the settings of the plugin source
+type pluginSourceSettings struct {
+	plugin.Config
+	MaxEvents      int                 `json:"max_events" validate:"min=0"`
+	PollIntervalMs config.Milliseconds `json:"poll_interval_ms" validate:"min=0"`
+}
+
+// postgresSinkSettings are the settings of the postgresql sink
+type postgresSinkSettings struct {
+	ConnectionString string `json:"connection_string" validate:"required"`
+	Table            string `json:"table" validate:"required"`
+	IAMAuth          bool   `json:"iam_auth"`
+	AWSRegion        string `json:"aws_region"`
+
+	ErrorIsolation      string         `json:"error_isolation"`
+	WriteTimeoutSeconds config.Seconds `json:"write_timeout_seconds" validate:"min=0"`
+	ColumnPolicy        string         `json:"column_policy"`
+	AllowedColumns      []string       `json:"allowed_columns"`
+	DeniedColumns       []string       `json:"denied_columns"`
+
+	SyncedAtColumn       string   `json:"synced_at_column"`
+	SourceTSColumn       string   `json:"source_ts_column"`
+	IngestedAtColumn     string   `json:"ingested_at_column"`
+	OperationColumn      string   `json:"operation_column"`
+	DeletedColumn        string   `json:"deleted_column"`
+	RowHashColumn        string   `json:"row_hash_column"`
+	MetadataValueColumns []string `json:"metadata_value_columns"`
+
+	SessionVariables map[string]string `json:"session_variables"`
+
+	WriteMode          string `json:"write_mode"`
+	ValidFromColumn    string `json:"valid_from_column"`
+	ValidToColumn      string `json:"valid_to_column"`
+	CurrentColumn      string `json:"current_column"`
+	LogIDColumn        string `json:"log_id_column"`
+	LogTimeColumn      string `json:"log_time_column"`
+	LogOperationColumn string `json:"log_operation_column"`
+	LogKeyColumn       string `json:"log_key_column"`
+	LogPayloadColumn   string `json:"log_payload_column"`
+
+	ConflictResolution string                   `json:"conflict_resolution"`
+	ConflictPriority   []string                 `json:"conflict_priority"`
+	ConflictMerge      config.TransformerConfig `json:"conflict_merge"`
+
+	Timescale                     bool           `json:"timescale"`
+	TimescaleTimeColumn           string         `json:"timescale_time_column"`
+	TimescaleChunkIntervalSeconds config.Seconds `json:"timescale_chunk_interval_seconds" validate:"min=0"`
+	TimescaleCompressAfterSeconds config.Seconds `json:"timescale_compress_after_seconds" validate:"min=0"`
+	TimescaleSegmentBy            []string       `json:"timescale_segment_by"`
+	TimescaleOrderBy              string         `json:"timescale_order_by"`
+
+	PartitionColumn string                 `json:"partition_column"`
+	PartitionScheme string                 `json:"partition_scheme"`
+	Indexes         []sink.IndexDefinition `json:"indexes"`
+	SchemaCheck     string                 `json:"schema_check"`
+
+	DeferForeignKeys             int            `json:"defer_foreign_keys" validate:"min=0"`
+	DeferForeignKeysAttempts     int            `json:"defer_foreign_keys_attempts" validate:"min=0"`
+	DeferForeignKeysRetrySeconds config.Seconds `json:"defer_foreign_keys_retry_seconds" validate:"min=0"`
+
+	BreakerFailureThreshold   int            `json:"breaker_failure_threshold" validate:"min=0"`
+	BreakerOpenTimeoutSeconds config.Seconds `json:"breaker_open_timeout_seconds" validate:"min=0"`
+}
+
+// fileSinkSettings are the settings of the file sink
+type fileSinkSettings struct {
+	Path             string `json:"path" validate:"required"`
+	Compression      string `json:"compression"`
+	CompressionLevel int    `json:"compression_level"`
+	Append           bool   `json:"append"`
+	Tombstones       bool   `json:"tombstones"`
+}
+
+// s3SinkSettings are the settings of the s3 sink
+type s3SinkSettings struct {
+	Bucket           string `json:"bucket" validate:"required"`
+	Prefix           string `json:"prefix"`
+	AWSRegion        string `json:"aws_region"`
+	Endpoint         string `json:"endpoint"`
+	Compression      string `json:"compression"`
+	CompressionLevel int    `json:"compression_level"`
+	ObjectEvents     int    `json:"object_events" validate:"min=0"`
+	Tombstones       bool   `json:"tombstones"`
+}
+
+// httpBulkSinkSettings are the settings of the http_bulk sink
+type httpBulkSinkSettings struct {
+	URL              string              `json:"url" validate:"required"`
+	Method           string              `json:"method"`
+	Headers          []string            `json:"headers"`
+	ActionTemplate   string              `json:"action_template"`
+	DeleteTemplate   string              `json:"delete_template"`
+	ContentType      string              `json:"content_type"`
+	BatchEvents      int                 `json:"batch_events" validate:"min=0"`
+	FlushIntervalMs  config.Milliseconds `json:"flush_interval_ms" validate:"min=0"`
+	Compression      string              `json:"compression"`
+	CompressionLevel int                 `json:"compression_level"`
+	TimeoutSeconds   config.Seconds      `json:"timeout_seconds" validate:"min=0"`
+}
+
+// snowflakeSinkSettings are the settings of the snowflake sink
+type snowflakeSinkSettings struct {
+	Account              string         `json:"account" validate:"required"`
+	User                 string         `json:"user" validate:"required"`
+	PrivateKeyPath       string         `json:"private_key_path" validate:"required"`
+	Role                 string         `json:"role"`
+	Warehouse            string         `json:"warehouse"`
+	Database             string         `json:"database"`
+	Schema               string         `json:"schema"`
+	Table                string         `json:"table"`
+	Stage                string         `json:"stage"`
+	StageBucket          string         `json:"stage_bucket"`
+	StagePrefix          string         `json:"stage_prefix"`
+	StageEndpoint        string         `json:"stage_endpoint"`
+	AWSRegion            string         `json:"aws_region"`
+	Columns              []string       `json:"columns"`
+	KeyColumns           []string       `json:"key_columns"`
+	BatchEvents          int            `json:"batch_events" validate:"min=0"`
+	FlushIntervalSeconds config.Seconds `json:"flush_interval_seconds" validate:"min=0"`
+	Endpoint             string         `json:"endpoint"`
+}
+
+// pluginSinkSettings are the settings of the plugin sink
+type pluginSinkSettings struct {
+	plugin.Config
+	BatchEvents     int                 `json:"batch_events" validate:"min=0"`
+	FlushIntervalMs config.Milliseconds `json:"flush_interval_ms" validate:"min=0"`
+}
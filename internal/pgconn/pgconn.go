@@ -0,0 +1,56 @@
+// Package pgconn holds the PostgreSQL connection helpers shared by the sink,
+// the audit log and the quarantine table
+package pgconn
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// rotatingConnLifetime bounds how long a connection opened with rotating
+// credentials is reused, so new credentials are picked up promptly
+const rotatingConnLifetime = time.Minute
+
+// ValidTableName matches table and column names that are safe to use unquoted
+// (alphanumeric, underscore, max 63 chars for PostgreSQL)
+var ValidTableName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]{0,62}$`)
+
+// ConnectionStringProvider returns the connection string to open new connections with
+type ConnectionStringProvider func(ctx context.Context) (string, error)
+
+// OpenRotating returns a database handle that asks the provider for a fresh
+// connection string for every new connection, so rotated credentials are used
+func OpenRotating(provider ConnectionStringProvider) *sql.DB {
+	db := sql.OpenDB(providerConnector{provider: provider})
+	db.SetConnMaxLifetime(rotatingConnLifetime)
+	return db
+}
+
+// providerConnector opens connections with the provider's current connection string
+type providerConnector struct {
+	provider ConnectionStringProvider
+}
+
+// Connect opens a connection with the latest connection string
+func (c providerConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	connStr, err := c.provider(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get connection credentials: %w", err)
+	}
+	connector, err := pq.NewConnector(connStr)
+	if err != nil {
+		return nil, err
+	}
+	return connector.Connect(ctx)
+}
+
+// Driver returns the PostgreSQL driver
+func (c providerConnector) Driver() driver.Driver {
+	return &pq.Driver{}
+}
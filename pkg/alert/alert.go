@@ -0,0 +1,199 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// Alert conditions
+const (
+	ConditionErrorRate = "error_rate"
+	ConditionLag       = "lag"
+	ConditionStopped   = "stopped"
+)
+
+// Severities
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+)
+
+// Alert is a condition that started or stopped firing for a pipeline
+type Alert struct {
+	Pipeline  string    `json:"pipeline"`
+	Condition string    `json:"condition"`
+	Severity  string    `json:"severity"`
+	Summary   string    `json:"summary"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Resolved  bool      `json:"resolved"`
+	Time      time.Time `json:"time"`
+}
+
+// Notifier delivers alerts to an external system
+type Notifier interface {
+	// Notify sends an alert or its resolution
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// Rules are the thresholds that trigger alerts; zero values disable a rule
+type Rules struct {
+	ErrorRatePerMinute float64       // errors per minute above which an alert fires
+	MaxLag             time.Duration // source-to-pipeline delay above which an alert fires
+	OnStop             bool          // alert when the pipeline stops or disconnects unexpectedly
+}
+
+// StatsProvider exposes the pipeline counters the monitor evaluates
+type StatsProvider interface {
+	Stats() pipeline.Stats
+}
+
+// Monitor periodically evaluates alert rules against a pipeline and
+// notifies when a condition starts or stops firing
+type Monitor struct {
+	pipelineName string
+	stats        StatsProvider
+	rules        Rules
+	notifiers    []Notifier
+	interval     time.Duration
+	clock        pipeline.Clock
+	logger       *log.Logger
+
+	mu         sync.Mutex // protects the fields below
+	firing     map[string]bool
+	lastErrors int64
+	lastCheck  time.Time
+	wasRunning bool
+}
+
+// NewMonitor creates a monitor checking the rules every interval (default: 30s)
+func NewMonitor(pipelineName string, stats StatsProvider, rules Rules, notifiers []Notifier, interval time.Duration, logger *log.Logger) *Monitor {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &Monitor{
+		pipelineName: pipelineName,
+		stats:        stats,
+		rules:        rules,
+		notifiers:    notifiers,
+		interval:     interval,
+		clock:        pipeline.SystemClock,
+		logger:       logger,
+		firing:       make(map[string]bool),
+	}
+}
+
+// SetClock sets the time source used for check intervals and error rates
+func (m *Monitor) SetClock(clock pipeline.Clock) {
+	m.clock = clock
+}
+
+// Run checks the rules every interval until the context is cancelled
+func (m *Monitor) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.clock.After(m.interval):
+			m.Check(ctx)
+		}
+	}
+}
+
+// Check evaluates every rule once
+func (m *Monitor) Check(ctx context.Context) {
+	stats := m.stats.Stats()
+	now := m.clock.Now()
+
+	m.mu.Lock()
+	errors, elapsed := stats.Errors-m.lastErrors, now.Sub(m.lastCheck)
+	first := m.lastCheck.IsZero()
+	m.lastErrors, m.lastCheck = stats.Errors, now
+	wasRunning := m.wasRunning
+	m.wasRunning = stats.Running
+	m.mu.Unlock()
+
+	if m.rules.ErrorRatePerMinute > 0 && !first && elapsed > 0 {
+		rate := float64(errors) / elapsed.Minutes()
+		m.evaluate(ctx, Alert{
+			Condition: ConditionErrorRate,
+			Severity:  SeverityCritical,
+			Summary:   fmt.Sprintf("pipeline %s error rate is %.1f/min (threshold %.1f/min)", m.pipelineName, rate, m.rules.ErrorRatePerMinute),
+			Value:     rate,
+			Threshold: m.rules.ErrorRatePerMinute,
+		}, rate > m.rules.ErrorRatePerMinute)
+	}
+
+	if m.rules.MaxLag > 0 {
+		m.evaluate(ctx, Alert{
+			Condition: ConditionLag,
+			Severity:  SeverityWarning,
+			Summary:   fmt.Sprintf("pipeline %s is %s behind the source (threshold %s)", m.pipelineName, stats.Lag.Round(time.Second), m.rules.MaxLag),
+			Value:     stats.Lag.Seconds(),
+			Threshold: m.rules.MaxLag.Seconds(),
+		}, stats.Lag > m.rules.MaxLag)
+	}
+
+	if m.rules.OnStop && (wasRunning || m.isFiring(ConditionStopped)) {
+		m.evaluate(ctx, Alert{
+			Condition: ConditionStopped,
+			Severity:  SeverityCritical,
+			Summary:   fmt.Sprintf("pipeline %s lost its source or sink connection", m.pipelineName),
+		}, !stats.Running)
+	}
+}
+
+// Stopped reports that the pipeline exited unexpectedly, with the error that stopped it if any
+func (m *Monitor) Stopped(ctx context.Context, err error) {
+	if !m.rules.OnStop {
+		return
+	}
+	summary := fmt.Sprintf("pipeline %s stopped unexpectedly", m.pipelineName)
+	if err != nil {
+		summary = fmt.Sprintf("pipeline %s stopped: %v", m.pipelineName, err)
+	}
+	m.mu.Lock()
+	m.firing[ConditionStopped] = false // always notify, even if a disconnect already fired
+	m.mu.Unlock()
+	m.evaluate(ctx, Alert{Condition: ConditionStopped, Severity: SeverityCritical, Summary: summary}, true)
+}
+
+// evaluate notifies when a condition changes between firing and resolved
+func (m *Monitor) evaluate(ctx context.Context, alert Alert, breached bool) {
+	m.mu.Lock()
+	changed := m.firing[alert.Condition] != breached
+	m.firing[alert.Condition] = breached
+	m.mu.Unlock()
+	if !changed {
+		return
+	}
+
+	alert.Pipeline = m.pipelineName
+	alert.Resolved = !breached
+	alert.Time = m.clock.Now()
+	if alert.Resolved {
+		alert.Summary = fmt.Sprintf("resolved: %s", alert.Summary)
+	}
+	m.logger.Printf("Alert %s: %s", alert.Condition, alert.Summary)
+
+	for _, n := range m.notifiers {
+		if err := n.Notify(ctx, alert); err != nil {
+			m.logger.Printf("Failed to send %s alert: %v", alert.Condition, err)
+		}
+	}
+}
+
+// isFiring reports whether a condition is currently firing
+func (m *Monitor) isFiring(condition string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.firing[condition]
+}
@@ -0,0 +1,156 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// fakeStats is a StatsProvider returning settable stats
+type fakeStats struct {
+	mu    sync.Mutex
+	stats pipeline.Stats
+}
+
+func (f *fakeStats) Stats() pipeline.Stats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.stats
+}
+
+func (f *fakeStats) set(stats pipeline.Stats) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stats = stats
+}
+
+// recordingNotifier keeps the alerts it receives
+type recordingNotifier struct {
+	alerts []Alert
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, alert Alert) error {
+	r.alerts = append(r.alerts, alert)
+	return nil
+}
+
+// describe summarizes received alerts as condition:fired/resolved
+func (r *recordingNotifier) describe() []string {
+	var out []string
+	for _, a := range r.alerts {
+		state := "fired"
+		if a.Resolved {
+			state = "resolved"
+		}
+		out = append(out, a.Condition+":"+state)
+	}
+	return out
+}
+
+// TestMonitorRules tests that alerts fire once when a condition is breached and resolve when it clears
+func TestMonitorRules(t *testing.T) {
+	stats := &fakeStats{}
+	notifier := &recordingNotifier{}
+	clock := pipeline.NewManualClock(time.Unix(1700000000, 0))
+	m := NewMonitor("orders", stats, Rules{ErrorRatePerMinute: 5, MaxLag: time.Minute, OnStop: true}, []Notifier{notifier}, time.Minute, nil)
+	m.SetClock(clock)
+	ctx := context.Background()
+
+	steps := []pipeline.Stats{
+		{Running: true, Errors: 0},                        // baseline
+		{Running: true, Errors: 10},                       // 10 errors/min
+		{Running: true, Errors: 20, Lag: 2 * time.Minute}, // still erroring, now lagging
+		{Running: true, Errors: 21},                       // recovered
+		{Running: false, Errors: 21},                      // disconnected
+		{Running: true, Errors: 21},                       // reconnected
+	}
+	for _, s := range steps {
+		stats.set(s)
+		m.Check(ctx)
+		clock.Advance(time.Minute)
+	}
+
+	want := []string{
+		"error_rate:fired",
+		"lag:fired",
+		"error_rate:resolved",
+		"lag:resolved",
+		"stopped:fired",
+		"stopped:resolved",
+	}
+	if got := notifier.describe(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("expected alerts %v, got %v", want, got)
+	}
+	for _, a := range notifier.alerts {
+		if a.Pipeline != "orders" || a.Time.IsZero() {
+			t.Errorf("unexpected alert %+v", a)
+		}
+	}
+}
+
+// TestMonitorStopped tests the explicit stop alert
+func TestMonitorStopped(t *testing.T) {
+	notifier := &recordingNotifier{}
+	m := NewMonitor("orders", &fakeStats{}, Rules{OnStop: true}, []Notifier{notifier}, 0, nil)
+	m.Stopped(context.Background(), fmt.Errorf("failed to connect sink"))
+
+	if len(notifier.alerts) != 1 || notifier.alerts[0].Condition != ConditionStopped || notifier.alerts[0].Resolved {
+		t.Fatalf("expected one stopped alert, got %+v", notifier.alerts)
+	}
+
+	disabled := &recordingNotifier{}
+	NewMonitor("orders", &fakeStats{}, Rules{}, []Notifier{disabled}, 0, nil).Stopped(context.Background(), nil)
+	if len(disabled.alerts) != 0 {
+		t.Errorf("expected no alert without the on_stop rule, got %+v", disabled.alerts)
+	}
+}
+
+// TestNotifiers tests the payloads sent by the HTTP notifiers
+func TestNotifiers(t *testing.T) {
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("invalid JSON body: %v", err)
+		}
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	alert := Alert{Pipeline: "orders", Condition: ConditionLag, Severity: SeverityWarning, Summary: "behind", Resolved: true}
+	ctx := context.Background()
+
+	if err := NewWebhook(server.URL).Notify(ctx, alert); err != nil {
+		t.Fatalf("webhook Notify() error = %v", err)
+	}
+	if body["condition"] != ConditionLag || body["resolved"] != true {
+		t.Errorf("unexpected webhook body %v", body)
+	}
+
+	if err := NewSlack(server.URL).Notify(ctx, alert); err != nil {
+		t.Fatalf("slack Notify() error = %v", err)
+	}
+	if body["text"] != ":white_check_mark: [warning] behind" {
+		t.Errorf("unexpected slack body %v", body)
+	}
+
+	if err := NewPagerDuty("key", server.URL).Notify(ctx, alert); err != nil {
+		t.Fatalf("pagerduty Notify() error = %v", err)
+	}
+	if body["routing_key"] != "key" || body["event_action"] != "resolve" || body["dedup_key"] != "orders/lag" {
+		t.Errorf("unexpected pagerduty body %v", body)
+	}
+
+	if err := NewWebhook(server.URL+"/fail").Notify(ctx, alert); err == nil {
+		t.Error("expected an error for a failing endpoint")
+	}
+}
@@ -0,0 +1,116 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultPagerDutyURL is the PagerDuty Events API v2 endpoint
+const DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+// httpClient is shared by the HTTP notifiers
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Webhook posts alerts as JSON to a URL
+type Webhook struct {
+	url string
+}
+
+// NewWebhook creates a notifier posting alerts to a generic webhook
+func NewWebhook(url string) *Webhook {
+	return &Webhook{url: url}
+}
+
+// Notify posts the alert as JSON
+func (w *Webhook) Notify(ctx context.Context, alert Alert) error {
+	return postJSON(ctx, w.url, alert)
+}
+
+// Slack posts alerts to a Slack incoming webhook
+type Slack struct {
+	url string
+}
+
+// NewSlack creates a notifier posting to a Slack incoming webhook URL
+func NewSlack(url string) *Slack {
+	return &Slack{url: url}
+}
+
+// Notify posts the alert summary as a Slack message
+func (s *Slack) Notify(ctx context.Context, alert Alert) error {
+	icon := ":rotating_light:"
+	if alert.Resolved {
+		icon = ":white_check_mark:"
+	}
+	return postJSON(ctx, s.url, map[string]string{
+		"text": fmt.Sprintf("%s [%s] %s", icon, alert.Severity, alert.Summary),
+	})
+}
+
+// PagerDuty triggers and resolves incidents through the Events API v2
+type PagerDuty struct {
+	url        string
+	routingKey string
+}
+
+// NewPagerDuty creates a notifier for a PagerDuty service integration key.
+// An empty url uses DefaultPagerDutyURL.
+func NewPagerDuty(routingKey, url string) *PagerDuty {
+	if url == "" {
+		url = DefaultPagerDutyURL
+	}
+	return &PagerDuty{url: url, routingKey: routingKey}
+}
+
+// Notify triggers an incident, or resolves it once the condition clears.
+// Incidents are deduplicated per pipeline and condition.
+func (p *PagerDuty) Notify(ctx context.Context, alert Alert) error {
+	action := "trigger"
+	if alert.Resolved {
+		action = "resolve"
+	}
+	return postJSON(ctx, p.url, map[string]interface{}{
+		"routing_key":  p.routingKey,
+		"event_action": action,
+		"dedup_key":    alert.Pipeline + "/" + alert.Condition,
+		"payload": map[string]interface{}{
+			"summary":   alert.Summary,
+			"source":    alert.Pipeline,
+			"severity":  alert.Severity,
+			"timestamp": alert.Time.Format(time.RFC3339),
+			"custom_details": map[string]interface{}{
+				"condition": alert.Condition,
+				"value":     alert.Value,
+				"threshold": alert.Threshold,
+			},
+		},
+	})
+}
+
+// postJSON posts a JSON body and fails on non-2xx responses
+func postJSON(ctx context.Context, url string, body interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create alert request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send alert: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("alert endpoint returned %s", resp.Status)
+	}
+	return nil
+}
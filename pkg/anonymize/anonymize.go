@@ -0,0 +1,209 @@
+// Package anonymize masks personal data in documents, so samples of
+// production data can be shared in bug reports and used as test fixtures.
+package anonymize
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Action is what happens to the values of a field
+type Action string
+
+const (
+	// Keep leaves values as they are
+	Keep Action = "keep"
+	// Mask replaces letters with x and digits with 0, keeping the length and
+	// punctuation of strings, so formats such as emails survive, and sets
+	// numbers to 0
+	Mask Action = "mask"
+	// Hash replaces strings with a hex token and numbers with a number
+	// derived from a keyed hash, equal for equal values within an
+	// Anonymizer, so joins and duplicates survive
+	Hash Action = "hash"
+	// Redact replaces values with null
+	Redact Action = "redact"
+)
+
+// ParseAction parses an anonymization action; empty selects mask
+func ParseAction(name string) (Action, error) {
+	switch Action(name) {
+	case "":
+		return Mask, nil
+	case Keep, Mask, Hash, Redact:
+		return Action(name), nil
+	default:
+		return "", fmt.Errorf("unsupported anonymization action: %s", name)
+	}
+}
+
+// Rule sets the action for a field, dot-separated for nested fields. A rule
+// for a nested document applies to all of its fields that have no rule of
+// their own, and a rule for an array to its elements.
+type Rule struct {
+	Field  string
+	Action Action
+}
+
+// Anonymizer applies rules to documents. Booleans, dates, ObjectIDs and
+// other values that are neither strings nor numbers are only removed by
+// Redact.
+type Anonymizer struct {
+	rules    map[string]Action
+	fallback Action
+	key      []byte
+}
+
+// New creates an anonymizer applying fallback to fields without a rule. Hash
+// tokens are keyed with a random key, so they cannot be reversed by hashing
+// guessed values and differ between anonymizers.
+func New(rules []Rule, fallback Action) (*Anonymizer, error) {
+	a := &Anonymizer{rules: make(map[string]Action, len(rules)), fallback: fallback, key: make([]byte, 32)}
+	if _, err := ParseAction(string(fallback)); err != nil || fallback == "" {
+		return nil, fmt.Errorf("unsupported default anonymization action: %q", fallback)
+	}
+	for _, rule := range rules {
+		if rule.Field == "" {
+			return nil, fmt.Errorf("anonymization rule requires a field")
+		}
+		if _, err := ParseAction(string(rule.Action)); err != nil || rule.Action == "" {
+			return nil, fmt.Errorf("field %s: unsupported anonymization action: %q", rule.Field, rule.Action)
+		}
+		a.rules[rule.Field] = rule.Action
+	}
+	if _, err := rand.Read(a.key); err != nil {
+		return nil, fmt.Errorf("failed to generate hash key: %w", err)
+	}
+	return a, nil
+}
+
+// Document returns an anonymized copy of a document; the document is not modified
+func (a *Anonymizer) Document(document map[string]interface{}) map[string]interface{} {
+	return a.document(document, "", a.fallback)
+}
+
+// document anonymizes the fields of a document at path, applying inherited
+// to fields without a rule
+func (a *Anonymizer) document(document map[string]interface{}, path string, inherited Action) map[string]interface{} {
+	out := make(map[string]interface{}, len(document))
+	for key, value := range document {
+		fieldPath := key
+		if path != "" {
+			fieldPath = path + "." + key
+		}
+		out[key] = a.value(value, fieldPath, inherited)
+	}
+	return out
+}
+
+// value anonymizes the value of the field at path
+func (a *Anonymizer) value(value interface{}, path string, inherited Action) interface{} {
+	action := inherited
+	if rule, ok := a.rules[path]; ok {
+		action = rule
+	}
+	switch action {
+	case Keep:
+		if !a.hasRulesBelow(path) {
+			return value
+		}
+	case Redact:
+		return nil
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return a.document(v, path, action)
+	case bson.M:
+		return a.document(v, path, action)
+	case bson.D:
+		fields := make(map[string]interface{}, len(v))
+		for _, element := range v {
+			fields[element.Key] = element.Value
+		}
+		return a.document(fields, path, action)
+	case []interface{}:
+		return a.array(v, path, action)
+	case bson.A:
+		return a.array(v, path, action)
+	case string:
+		return a.text(v, action)
+	case int, int32, int64, float64:
+		return a.number(v, action)
+	}
+	return value
+}
+
+// array anonymizes the elements of an array, which share its path
+func (a *Anonymizer) array(values []interface{}, path string, action Action) []interface{} {
+	out := make([]interface{}, len(values))
+	for i, element := range values {
+		out[i] = a.value(element, path, action)
+	}
+	return out
+}
+
+// hasRulesBelow reports whether a field nested in path has a rule of its own
+func (a *Anonymizer) hasRulesBelow(path string) bool {
+	for field := range a.rules {
+		if strings.HasPrefix(field, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// text anonymizes a string
+func (a *Anonymizer) text(s string, action Action) string {
+	switch action {
+	case Mask:
+		return strings.Map(func(r rune) rune {
+			switch {
+			case unicode.IsLetter(r):
+				return 'x'
+			case unicode.IsDigit(r):
+				return '0'
+			}
+			return r
+		}, s)
+	case Hash:
+		return hex.EncodeToString(a.sum(s)[:8])
+	}
+	return s
+}
+
+// number anonymizes a number, keeping its type
+func (a *Anonymizer) number(value interface{}, action Action) interface{} {
+	var n int64
+	switch action {
+	case Mask:
+	case Hash:
+		n = int64(binary.BigEndian.Uint32(a.sum(fmt.Sprint(value))))
+	default:
+		return value
+	}
+	switch value.(type) {
+	case int:
+		return int(n)
+	case int32:
+		return int32(n >> 1)
+	case float64:
+		return float64(n)
+	}
+	return n
+}
+
+// sum is the keyed hash of a value
+func (a *Anonymizer) sum(s string) []byte {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(s))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,95 @@
+package anonymize
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TestParseAction tests parsing anonymization actions
+func TestParseAction(t *testing.T) {
+	for _, name := range []string{"keep", "mask", "hash", "redact"} {
+		if action, err := ParseAction(name); err != nil || string(action) != name {
+			t.Errorf("ParseAction(%q) = %q, %v", name, action, err)
+		}
+	}
+	if action, err := ParseAction(""); err != nil || action != Mask {
+		t.Errorf("ParseAction(\"\") = %q, %v, want mask", action, err)
+	}
+	if _, err := ParseAction("encrypt"); err == nil {
+		t.Error("expected error for an unknown action")
+	}
+}
+
+// TestDocument tests applying rules and the fallback to nested documents and arrays
+func TestDocument(t *testing.T) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	a, err := New([]Rule{
+		{Field: "status", Action: Keep},
+		{Field: "customer.email", Action: Hash},
+		{Field: "customer.notes", Action: Redact},
+		{Field: "address", Action: Keep},
+		{Field: "address.street", Action: Mask},
+	}, Mask)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	document := map[string]interface{}{
+		"status":  "shipped",
+		"total":   int64(1999),
+		"paid":    true,
+		"created": created,
+		"customer": bson.M{
+			"name":  "Jane Doe",
+			"email": "jane@example.com",
+			"notes": "VIP",
+			"phone": "+1 (555) 010-9999",
+		},
+		"address": map[string]interface{}{"city": "Springfield", "street": "742 Evergreen Terrace"},
+		"tags":    bson.A{"gift", "rush"},
+	}
+
+	got := a.Document(document)
+	customer := got["customer"].(map[string]interface{})
+	want := map[string]interface{}{
+		"status":  "shipped",
+		"total":   int64(0),
+		"paid":    true,
+		"created": created,
+		"customer": map[string]interface{}{
+			"name":  "xxxx xxx",
+			"email": customer["email"],
+			"notes": nil,
+			"phone": "+0 (000) 000-0000",
+		},
+		"address": map[string]interface{}{"city": "Springfield", "street": "000 xxxxxxxxx xxxxxxx"},
+		"tags":    []interface{}{"xxxx", "xxxx"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Document() = %v, want %v", got, want)
+	}
+	if email := customer["email"].(string); len(email) != 16 || email == "jane@example.com" {
+		t.Errorf("expected a hash token for the email, got %q", email)
+	}
+	if again := a.Document(document)["customer"].(map[string]interface{})["email"]; again != customer["email"] {
+		t.Errorf("expected equal values to hash alike, got %v and %v", again, customer["email"])
+	}
+	if document["status"] != "shipped" || document["customer"].(bson.M)["name"] != "Jane Doe" {
+		t.Error("expected the document to be left unmodified")
+	}
+}
+
+// TestNewRejectsInvalidRules tests rule validation
+func TestNewRejectsInvalidRules(t *testing.T) {
+	if _, err := New([]Rule{{Field: "email", Action: "scramble"}}, Mask); err == nil {
+		t.Error("expected error for an unknown action")
+	}
+	if _, err := New([]Rule{{Action: Hash}}, Mask); err == nil {
+		t.Error("expected error for a rule without a field")
+	}
+	if _, err := New(nil, ""); err == nil {
+		t.Error("expected error for an empty default action")
+	}
+}
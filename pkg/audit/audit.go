@@ -0,0 +1,199 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/IEatCodeDaily/data-pipe/internal/pgconn"
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// DefaultTable is the table audit records are written to by TableLog
+const DefaultTable = "datapipe_audit"
+
+// maxInsertRows keeps multi-row inserts well below PostgreSQL's parameter limit
+const maxInsertRows = 1000
+
+// FileLog is an audit log stored as a JSON-lines file
+type FileLog struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// NewFileLog opens (or creates) a file-backed audit log
+func NewFileLog(path string) (*FileLog, error) {
+	if path == "" {
+		return nil, fmt.Errorf("audit path is required")
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit file: %w", err)
+	}
+	return &FileLog{path: path, file: f}, nil
+}
+
+// Reopen opens the path again and closes the previous file, so records go
+// to a new file once the old one has been rotated away; on error the
+// previous file stays in use
+func (l *FileLog) Reopen() error {
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to reopen audit file: %w", err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		f.Close()
+		return fmt.Errorf("audit log is closed")
+	}
+	previous := l.file
+	l.file = f
+	return previous.Close()
+}
+
+// Record appends audit records to the file, one JSON object per line
+func (l *FileLog) Record(ctx context.Context, records []pipeline.AuditRecord) error {
+	var buf []byte
+	for _, record := range records {
+		line, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to encode audit record: %w", err)
+		}
+		buf = append(buf, line...)
+		buf = append(buf, '\n')
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return fmt.Errorf("audit log is closed")
+	}
+	if _, err := l.file.Write(buf); err != nil {
+		return fmt.Errorf("failed to write audit records: %w", err)
+	}
+	return nil
+}
+
+// Close closes the audit file
+func (l *FileLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	return err
+}
+
+// TableLog is an audit log stored in a PostgreSQL table
+type TableLog struct {
+	db    *sql.DB
+	table string
+}
+
+// ConnectionStringProvider returns the connection string to open new connections with
+type ConnectionStringProvider = pgconn.ConnectionStringProvider
+
+// NewTableLog connects to PostgreSQL and creates the audit table if needed
+func NewTableLog(ctx context.Context, connStr, table string) (*TableLog, error) {
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit database: %w", err)
+	}
+	return newTableLog(ctx, db, table)
+}
+
+// NewRotatingTableLog is like NewTableLog, but asks the provider for a fresh
+// connection string for every new connection so rotated credentials are used
+func NewRotatingTableLog(ctx context.Context, provider ConnectionStringProvider, table string) (*TableLog, error) {
+	return newTableLog(ctx, pgconn.OpenRotating(provider), table)
+}
+
+// newTableLog creates the audit table if needed
+func newTableLog(ctx context.Context, db *sql.DB, table string) (*TableLog, error) {
+	if table == "" {
+		table = DefaultTable
+	}
+	if !pgconn.ValidTableName.MatchString(table) {
+		db.Close()
+		return nil, fmt.Errorf("invalid audit table name: %s", table)
+	}
+
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	pipeline TEXT NOT NULL,
+	event_id TEXT NOT NULL,
+	operation TEXT NOT NULL,
+	collection TEXT NOT NULL,
+	outcome TEXT NOT NULL,
+	reason TEXT,
+	latency_ms DOUBLE PRECISION NOT NULL,
+	recorded_at TIMESTAMPTZ NOT NULL
+)`, table)
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create audit table: %w", err)
+	}
+
+	return &TableLog{db: db, table: table}, nil
+}
+
+// Record inserts audit records using multi-row statements
+func (l *TableLog) Record(ctx context.Context, records []pipeline.AuditRecord) error {
+	for len(records) > maxInsertRows {
+		if err := l.insert(ctx, records[:maxInsertRows]); err != nil {
+			return err
+		}
+		records = records[maxInsertRows:]
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	return l.insert(ctx, records)
+}
+
+// insert writes a group of audit records in one statement
+func (l *TableLog) insert(ctx context.Context, records []pipeline.AuditRecord) error {
+	const columns = 8
+	placeholders := make([]string, 0, len(records))
+	args := make([]interface{}, 0, len(records)*columns)
+	for i, r := range records {
+		n := i * columns
+		placeholders = append(placeholders, fmt.Sprintf("($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8))
+		args = append(args, r.Pipeline, r.EventID, r.Operation, r.Collection, r.Outcome, nullString(r.Reason), r.LatencyMs, r.RecordedAt)
+	}
+
+	query := fmt.Sprintf(
+		"INSERT INTO %s (pipeline, event_id, operation, collection, outcome, reason, latency_ms, recorded_at) VALUES %s",
+		l.table, strings.Join(placeholders, ", "),
+	)
+	if _, err := l.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to write audit records: %w", err)
+	}
+	return nil
+}
+
+// Close closes the audit database connection
+func (l *TableLog) Close() error {
+	return l.db.Close()
+}
+
+// nullString maps an empty string to NULL
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
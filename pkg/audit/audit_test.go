@@ -0,0 +1,106 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// TestFileLog tests that audit records are appended as JSON lines
+func TestFileLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog, err := NewFileLog(path)
+	if err != nil {
+		t.Fatalf("NewFileLog() error = %v", err)
+	}
+
+	records := []pipeline.AuditRecord{
+		{Pipeline: "p", EventID: "1", Operation: "insert", Collection: "users", Outcome: pipeline.AuditWritten, LatencyMs: 12.5, RecordedAt: time.Unix(1700000000, 0).UTC()},
+		{Pipeline: "p", EventID: "2", Operation: "delete", Collection: "users", Outcome: pipeline.AuditDeadLettered, Reason: "bad row"},
+	}
+	if err := auditLog.Record(context.Background(), records); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if err := auditLog.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := auditLog.Record(context.Background(), records); err == nil {
+		t.Error("expected an error recording to a closed log")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read audit file: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+	for i, line := range lines {
+		var got pipeline.AuditRecord
+		if err := json.Unmarshal([]byte(line), &got); err != nil {
+			t.Fatalf("line %d is not valid JSON: %v", i, err)
+		}
+		if got != records[i] {
+			t.Errorf("line %d: expected %+v, got %+v", i, records[i], got)
+		}
+	}
+}
+
+// TestFileLogReopen tests switching to a new file after rotation
+func TestFileLogReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	auditLog, err := NewFileLog(path)
+	if err != nil {
+		t.Fatalf("NewFileLog() error = %v", err)
+	}
+	defer auditLog.Close()
+
+	record := []pipeline.AuditRecord{{Pipeline: "p", EventID: "1", Operation: "insert", Outcome: pipeline.AuditWritten}}
+	if err := auditLog.Record(context.Background(), record); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := auditLog.Reopen(); err != nil {
+		t.Fatalf("Reopen() error = %v", err)
+	}
+	record[0].EventID = "2"
+	if err := auditLog.Record(context.Background(), record); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	for name, want := range map[string]string{path + ".1": `"event_id":"1"`, path: `"event_id":"2"`} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 1 || !strings.Contains(lines[0], want) {
+			t.Errorf("%s: expected one record with %s, got %s", name, want, data)
+		}
+	}
+}
+
+// TestNewTableLogRejectsInvalidTable tests table name validation
+func TestNewTableLogRejectsInvalidTable(t *testing.T) {
+	if _, err := NewTableLog(context.Background(), "postgres://localhost/db", "audit; DROP TABLE x"); err == nil {
+		t.Error("expected an error for an invalid table name")
+	}
+}
+
+func TestNewRotatingTableLogRejectsInvalidTable(t *testing.T) {
+	provider := func(ctx context.Context) (string, error) {
+		t.Error("expected no connection for an invalid table name")
+		return "", nil
+	}
+	if _, err := NewRotatingTableLog(context.Background(), provider, "audit; DROP TABLE x"); err == nil {
+		t.Error("expected an error for an invalid table name")
+	}
+}
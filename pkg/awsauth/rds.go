@@ -0,0 +1,236 @@
+package awsauth
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+const (
+	// tokenLifetime is how long RDS accepts an IAM authentication token
+	tokenLifetime = 15 * time.Minute
+	// tokenRefresh is how long a token is reused before a new one is generated
+	tokenRefresh = 10 * time.Minute
+	// emptyPayloadHash is the SHA-256 of an empty request body
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// Clock provides the current time; pipeline.Clock satisfies it
+type Clock interface {
+	Now() time.Time
+}
+
+// systemClock reads the system time
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// RDSAuth generates IAM authentication tokens for RDS and Aurora PostgreSQL,
+// used in place of a static password
+type RDSAuth struct {
+	credentials aws.CredentialsProvider
+	region      string
+	signer      *v4.Signer
+	clock       Clock
+
+	mu     sync.Mutex // protects tokens
+	tokens map[string]cachedToken
+}
+
+// cachedToken is a token together with when it was generated
+type cachedToken struct {
+	token     string
+	createdAt time.Time
+}
+
+// NewRDSAuth loads AWS credentials from the default chain (environment,
+// shared config, web identity, container or instance role). An empty
+// region uses the region from the environment.
+func NewRDSAuth(ctx context.Context, region string) (*RDSAuth, error) {
+	var opts []func(*config.LoadOptions) error
+	if region != "" {
+		opts = append(opts, config.WithRegion(region))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("AWS region is required for IAM authentication")
+	}
+	return NewRDSAuthWithCredentials(cfg.Credentials, cfg.Region), nil
+}
+
+// NewRDSAuthWithCredentials creates a token generator using the given credentials
+func NewRDSAuthWithCredentials(credentials aws.CredentialsProvider, region string) *RDSAuth {
+	return &RDSAuth{
+		credentials: aws.NewCredentialsCache(credentials),
+		region:      region,
+		signer:      v4.NewSigner(),
+		clock:       systemClock{},
+		tokens:      make(map[string]cachedToken),
+	}
+}
+
+// SetClock sets the time source used to sign and refresh tokens
+func (a *RDSAuth) SetClock(clock Clock) {
+	a.clock = clock
+}
+
+// Token returns an authentication token for a database user at host:port,
+// reusing a previous token until it is close to expiry
+func (a *RDSAuth) Token(ctx context.Context, endpoint, user string) (string, error) {
+	key := user + "@" + endpoint
+	now := a.clock.Now()
+
+	a.mu.Lock()
+	cached, ok := a.tokens[key]
+	a.mu.Unlock()
+	if ok && now.Sub(cached.createdAt) < tokenRefresh {
+		return cached.token, nil
+	}
+
+	creds, err := a.credentials.Retrieve(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+
+	query := url.Values{}
+	query.Set("Action", "connect")
+	query.Set("DBUser", user)
+	query.Set("X-Amz-Expires", fmt.Sprint(int(tokenLifetime.Seconds())))
+	req, err := http.NewRequest(http.MethodGet, "https://"+endpoint+"/?"+query.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to build token request: %w", err)
+	}
+
+	signed, _, err := a.signer.PresignHTTP(ctx, creds, req, emptyPayloadHash, "rds-db", a.region, now)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign authentication token: %w", err)
+	}
+	token := strings.TrimPrefix(signed, "https://")
+
+	a.mu.Lock()
+	a.tokens[key] = cachedToken{token: token, createdAt: now}
+	a.mu.Unlock()
+	return token, nil
+}
+
+// ConnectionString returns connStr with its password replaced by a fresh
+// authentication token. Both URL (postgres://user@host:port/db) and
+// key/value (host=... user=...) forms are supported.
+func (a *RDSAuth) ConnectionString(ctx context.Context, connStr string) (string, error) {
+	if strings.HasPrefix(connStr, "postgres://") || strings.HasPrefix(connStr, "postgresql://") {
+		u, err := url.Parse(connStr)
+		if err != nil {
+			return "", fmt.Errorf("invalid connection string: %w", err)
+		}
+		if u.User == nil || u.User.Username() == "" {
+			return "", fmt.Errorf("IAM authentication requires a user in the connection string")
+		}
+		port := u.Port()
+		if port == "" {
+			port = "5432"
+		}
+		token, err := a.Token(ctx, net.JoinHostPort(u.Hostname(), port), u.User.Username())
+		if err != nil {
+			return "", err
+		}
+		u.User = url.UserPassword(u.User.Username(), token)
+		return u.String(), nil
+	}
+
+	fields, err := parseKeyValues(connStr)
+	if err != nil {
+		return "", err
+	}
+	settings := map[string]string{"port": "5432"}
+	var kept []string
+	for _, field := range fields {
+		settings[field.key] = field.value
+		if field.key != "password" {
+			kept = append(kept, field.key+"="+quoteValue(field.value))
+		}
+	}
+	if settings["host"] == "" || settings["user"] == "" {
+		return "", fmt.Errorf("IAM authentication requires host and user in the connection string")
+	}
+	token, err := a.Token(ctx, net.JoinHostPort(settings["host"], settings["port"]), settings["user"])
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(append(kept, "password="+quoteValue(token)), " "), nil
+}
+
+// keyValue is one setting of a key/value connection string
+type keyValue struct {
+	key   string
+	value string
+}
+
+// parseKeyValues parses a libpq key/value connection string, where values
+// may be single-quoted (with \' and \\ escapes) to contain spaces
+func parseKeyValues(connStr string) ([]keyValue, error) {
+	var fields []keyValue
+	s := strings.TrimSpace(connStr)
+	for s != "" {
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("invalid connection string field: %s", s)
+		}
+		key := strings.TrimSpace(s[:eq])
+		if key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("invalid connection string field: %s", s)
+		}
+		s = strings.TrimLeft(s[eq+1:], " \t")
+
+		var value strings.Builder
+		if strings.HasPrefix(s, "'") {
+			i, closed := 1, false
+			for ; i < len(s); i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+					value.WriteByte(s[i])
+					continue
+				}
+				if s[i] == '\'' {
+					closed = true
+					break
+				}
+				value.WriteByte(s[i])
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated quoted value for %s", key)
+			}
+			s = s[i+1:]
+		} else {
+			i := 0
+			for ; i < len(s) && s[i] != ' ' && s[i] != '\t'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				value.WriteByte(s[i])
+			}
+			s = s[i:]
+		}
+		fields = append(fields, keyValue{key: key, value: value.String()})
+		s = strings.TrimLeft(s, " \t")
+	}
+	return fields, nil
+}
+
+// quoteValue single-quotes a connection string value, escaping quotes and backslashes
+func quoteValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
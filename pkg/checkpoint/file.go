@@ -0,0 +1,94 @@
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// FileStore persists checkpoints for any number of pipelines in a JSON file
+type FileStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileStore creates a checkpoint store backed by the given file
+func NewFileStore(path string) (*FileStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("checkpoint path is required")
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create checkpoint directory: %w", err)
+		}
+	}
+	return &FileStore{path: path}, nil
+}
+
+// Load returns the checkpoint for a pipeline, or nil if none was saved
+func (s *FileStore) Load(ctx context.Context, pipelineName string) (*pipeline.Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoints, err := s.readLocked()
+	if err != nil {
+		return nil, err
+	}
+	cp, ok := checkpoints[pipelineName]
+	if !ok {
+		return nil, nil
+	}
+	return &cp, nil
+}
+
+// Save stores the checkpoint for a pipeline, replacing the file atomically
+func (s *FileStore) Save(ctx context.Context, cp pipeline.Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoints, err := s.readLocked()
+	if err != nil {
+		return err
+	}
+	checkpoints[cp.Pipeline] = cp
+
+	data, err := json.MarshalIndent(checkpoints, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoints: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write checkpoints: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to save checkpoints: %w", err)
+	}
+	return nil
+}
+
+// readLocked reads all checkpoints from the file (caller must hold the lock)
+func (s *FileStore) readLocked() (map[string]pipeline.Checkpoint, error) {
+	checkpoints := make(map[string]pipeline.Checkpoint)
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return checkpoints, nil
+		}
+		return nil, fmt.Errorf("failed to read checkpoints: %w", err)
+	}
+	if len(data) == 0 {
+		return checkpoints, nil
+	}
+
+	if err := json.Unmarshal(data, &checkpoints); err != nil {
+		return nil, fmt.Errorf("failed to parse checkpoints: %w", err)
+	}
+	return checkpoints, nil
+}
@@ -0,0 +1,55 @@
+package checkpoint
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// TestFileStoreSaveLoad tests saving and loading checkpoints for several pipelines
+func TestFileStoreSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "checkpoints.json")
+	ctx := context.Background()
+
+	store, err := NewFileStore(path)
+	if err != nil {
+		t.Fatalf("NewFileStore() error = %v", err)
+	}
+
+	cp, err := store.Load(ctx, "orders")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cp != nil {
+		t.Fatalf("expected no checkpoint, got %+v", cp)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := store.Save(ctx, pipeline.Checkpoint{Pipeline: "orders", Position: "token-1", UpdatedAt: now}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := store.Save(ctx, pipeline.Checkpoint{Pipeline: "users", Position: "token-a", UpdatedAt: now}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := store.Save(ctx, pipeline.Checkpoint{Pipeline: "orders", Position: "token-2", UpdatedAt: now}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	// A fresh store reads the same file
+	reopened, _ := NewFileStore(path)
+	for name, want := range map[string]string{"orders": "token-2", "users": "token-a"} {
+		cp, err := reopened.Load(ctx, name)
+		if err != nil {
+			t.Fatalf("Load(%s) error = %v", name, err)
+		}
+		if cp == nil || cp.Position != want {
+			t.Errorf("Load(%s) = %+v, want position %s", name, cp, want)
+		}
+		if !cp.UpdatedAt.Equal(now) {
+			t.Errorf("Load(%s) UpdatedAt = %v, want %v", name, cp.UpdatedAt, now)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package checkpoint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// DefaultMaxRuns is the number of runs kept per pipeline when no limit is given
+const DefaultMaxRuns = 20
+
+// pipelineHistory is the run history of one pipeline as stored on disk
+type pipelineHistory struct {
+	Runs     []pipeline.RunSummary   `json:"runs"` // oldest first
+	Snapshot *pipeline.SnapshotStats `json:"snapshot,omitempty"`
+}
+
+// FileRunStore persists run history and snapshot statistics for any number of pipelines in a JSON file
+type FileRunStore struct {
+	path    string
+	maxRuns int
+	mu      sync.Mutex
+}
+
+// NewFileRunStore creates a run store backed by the given file, keeping the last maxRuns runs per pipeline
+func NewFileRunStore(path string, maxRuns int) (*FileRunStore, error) {
+	if path == "" {
+		return nil, fmt.Errorf("run history path is required")
+	}
+	if maxRuns <= 0 {
+		maxRuns = DefaultMaxRuns
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create run history directory: %w", err)
+		}
+	}
+	return &FileRunStore{path: path, maxRuns: maxRuns}, nil
+}
+
+// SaveRun creates or updates a run, dropping the oldest runs beyond the limit
+func (s *FileRunStore) SaveRun(ctx context.Context, run pipeline.RunSummary) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	histories, err := s.readLocked()
+	if err != nil {
+		return err
+	}
+	history := histories[run.Pipeline]
+
+	replaced := false
+	for i := range history.Runs {
+		if history.Runs[i].ID == run.ID {
+			history.Runs[i] = run
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		history.Runs = append(history.Runs, run)
+	}
+	if len(history.Runs) > s.maxRuns {
+		history.Runs = history.Runs[len(history.Runs)-s.maxRuns:]
+	}
+
+	histories[run.Pipeline] = history
+	return s.writeLocked(histories)
+}
+
+// Runs returns up to limit runs of a pipeline, newest first (all runs if limit is 0)
+func (s *FileRunStore) Runs(ctx context.Context, pipelineName string, limit int) ([]pipeline.RunSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	histories, err := s.readLocked()
+	if err != nil {
+		return nil, err
+	}
+	stored := histories[pipelineName].Runs
+
+	runs := make([]pipeline.RunSummary, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		if limit > 0 && len(runs) == limit {
+			break
+		}
+		runs = append(runs, stored[i])
+	}
+	return runs, nil
+}
+
+// SaveSnapshot stores the statistics of a pipeline's last snapshot
+func (s *FileRunStore) SaveSnapshot(ctx context.Context, stats pipeline.SnapshotStats) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	histories, err := s.readLocked()
+	if err != nil {
+		return err
+	}
+	history := histories[stats.Pipeline]
+	history.Snapshot = &stats
+	histories[stats.Pipeline] = history
+	return s.writeLocked(histories)
+}
+
+// LoadSnapshot returns the last snapshot statistics, or nil if none was saved
+func (s *FileRunStore) LoadSnapshot(ctx context.Context, pipelineName string) (*pipeline.SnapshotStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	histories, err := s.readLocked()
+	if err != nil {
+		return nil, err
+	}
+	return histories[pipelineName].Snapshot, nil
+}
+
+// readLocked reads all run histories from the file (caller must hold the lock)
+func (s *FileRunStore) readLocked() (map[string]pipelineHistory, error) {
+	histories := make(map[string]pipelineHistory)
+
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return histories, nil
+		}
+		return nil, fmt.Errorf("failed to read run history: %w", err)
+	}
+	if len(data) == 0 {
+		return histories, nil
+	}
+
+	if err := json.Unmarshal(data, &histories); err != nil {
+		return nil, fmt.Errorf("failed to parse run history: %w", err)
+	}
+	return histories, nil
+}
+
+// writeLocked replaces the file atomically (caller must hold the lock)
+func (s *FileRunStore) writeLocked(histories map[string]pipelineHistory) error {
+	data, err := json.MarshalIndent(histories, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run history: %w", err)
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write run history: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("failed to save run history: %w", err)
+	}
+	return nil
+}
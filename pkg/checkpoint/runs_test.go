@@ -0,0 +1,89 @@
+package checkpoint
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// TestFileRunStoreRuns tests updating runs in place, trimming and newest-first listing
+func TestFileRunStoreRuns(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "runs.json")
+	ctx := context.Background()
+
+	store, err := NewFileRunStore(path, 3)
+	if err != nil {
+		t.Fatalf("NewFileRunStore() error = %v", err)
+	}
+
+	for i := 1; i <= 4; i++ {
+		run := pipeline.RunSummary{ID: fmt.Sprintf("run-%d", i), Pipeline: "orders", Status: pipeline.RunRunning}
+		if err := store.SaveRun(ctx, run); err != nil {
+			t.Fatalf("SaveRun() error = %v", err)
+		}
+	}
+	if err := store.SaveRun(ctx, pipeline.RunSummary{ID: "run-4", Pipeline: "orders", Status: pipeline.RunCompleted, EventsProcessed: 7}); err != nil {
+		t.Fatalf("SaveRun() error = %v", err)
+	}
+	if err := store.SaveRun(ctx, pipeline.RunSummary{ID: "other", Pipeline: "users"}); err != nil {
+		t.Fatalf("SaveRun() error = %v", err)
+	}
+
+	runs, err := store.Runs(ctx, "orders", 0)
+	if err != nil {
+		t.Fatalf("Runs() error = %v", err)
+	}
+	var ids []string
+	for _, run := range runs {
+		ids = append(ids, run.ID)
+	}
+	if fmt.Sprint(ids) != "[run-4 run-3 run-2]" {
+		t.Errorf("expected newest three runs, got %v", ids)
+	}
+	if runs[0].Status != pipeline.RunCompleted || runs[0].EventsProcessed != 7 {
+		t.Errorf("expected run-4 to be updated in place, got %+v", runs[0])
+	}
+
+	limited, err := store.Runs(ctx, "orders", 1)
+	if err != nil {
+		t.Fatalf("Runs() error = %v", err)
+	}
+	if len(limited) != 1 || limited[0].ID != "run-4" {
+		t.Errorf("expected only run-4, got %+v", limited)
+	}
+}
+
+// TestFileRunStoreSnapshot tests saving and loading snapshot statistics
+func TestFileRunStoreSnapshot(t *testing.T) {
+	store, err := NewFileRunStore(filepath.Join(t.TempDir(), "runs.json"), 0)
+	if err != nil {
+		t.Fatalf("NewFileRunStore() error = %v", err)
+	}
+	ctx := context.Background()
+
+	stats, err := store.LoadSnapshot(ctx, "orders")
+	if err != nil || stats != nil {
+		t.Fatalf("expected no snapshot, got %+v, %v", stats, err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	want := pipeline.SnapshotStats{Pipeline: "orders", Status: pipeline.RunCompleted, StartedAt: now, CompletedAt: now, Documents: 1200}
+	if err := store.SaveSnapshot(ctx, want); err != nil {
+		t.Fatalf("SaveSnapshot() error = %v", err)
+	}
+	if err := store.SaveRun(ctx, pipeline.RunSummary{ID: "r", Pipeline: "orders"}); err != nil {
+		t.Fatalf("SaveRun() error = %v", err)
+	}
+
+	stats, err = store.LoadSnapshot(ctx, "orders")
+	if err != nil {
+		t.Fatalf("LoadSnapshot() error = %v", err)
+	}
+	if stats == nil || !stats.StartedAt.Equal(want.StartedAt) || stats.Documents != want.Documents {
+		t.Errorf("expected %+v, got %+v", want, stats)
+	}
+}
@@ -0,0 +1,203 @@
+package compress
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec identifies a compression format
+type Codec string
+
+const (
+	// None means data is read and written uncompressed
+	None Codec = "none"
+	// Gzip compresses data with gzip
+	Gzip Codec = "gzip"
+	// Zstd compresses data with Zstandard
+	Zstd Codec = "zstd"
+	// Snappy compresses data with Snappy in its framing format, trading ratio for speed
+	Snappy Codec = "snappy"
+)
+
+// ParseCodec parses a codec name from configuration.
+// An empty string or "auto" returns "" so the caller can detect by extension.
+func ParseCodec(name string) (Codec, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "auto":
+		return "", nil
+	case "none", "off":
+		return None, nil
+	case "gzip", "gz":
+		return Gzip, nil
+	case "zstd", "zst":
+		return Zstd, nil
+	case "snappy", "sz":
+		return Snappy, nil
+	default:
+		return "", fmt.Errorf("unsupported compression codec: %s", name)
+	}
+}
+
+// DetectCodec detects the compression codec from a file path extension
+func DetectCodec(path string) Codec {
+	lower := strings.ToLower(path)
+	switch {
+	case strings.HasSuffix(lower, ".gz"), strings.HasSuffix(lower, ".gzip"):
+		return Gzip
+	case strings.HasSuffix(lower, ".zst"), strings.HasSuffix(lower, ".zstd"):
+		return Zstd
+	case strings.HasSuffix(lower, ".sz"):
+		return Snappy
+	default:
+		return None
+	}
+}
+
+// NewReader wraps r with a decompressor for the given codec
+func NewReader(r io.Reader, codec Codec) (io.ReadCloser, error) {
+	switch codec {
+	case "", None:
+		return io.NopCloser(r), nil
+	case Gzip:
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+		}
+		return gr, nil
+	case Zstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd reader: %w", err)
+		}
+		return zr.IOReadCloser(), nil
+	case Snappy:
+		return io.NopCloser(snappy.NewReader(r)), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression codec: %s", codec)
+	}
+}
+
+// NewWriter wraps w with a compressor for the given codec.
+// A level of 0 selects the codec's default level.
+func NewWriter(w io.Writer, codec Codec, level int) (io.WriteCloser, error) {
+	switch codec {
+	case "", None:
+		return nopWriteCloser{w}, nil
+	case Gzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		gw, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip compression level %d: %w", level, err)
+		}
+		return gw, nil
+	case Zstd:
+		opts := []zstd.EOption{}
+		if level != 0 {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		zw, err := zstd.NewWriter(w, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd writer: %w", err)
+		}
+		return zw, nil
+	case Snappy:
+		// Snappy has no levels
+		return snappy.NewBufferedWriter(w), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression codec: %s", codec)
+	}
+}
+
+// Open opens a file for reading, transparently decompressing it.
+// If codec is empty the codec is detected from the file extension.
+func Open(path string, codec Codec) (io.ReadCloser, error) {
+	if codec == "" {
+		codec = DetectCodec(path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	r, err := NewReader(f, codec)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &fileReadCloser{ReadCloser: r, file: f}, nil
+}
+
+// Create creates (or appends to) a file for writing, transparently compressing it.
+// If codec is empty the codec is detected from the file extension.
+// Appending to a compressed file produces a multi-member stream, which both
+// gzip and zstd readers handle.
+func Create(path string, codec Codec, level int, appendMode bool) (io.WriteCloser, error) {
+	if codec == "" {
+		codec = DetectCodec(path)
+	}
+
+	flags := os.O_CREATE | os.O_WRONLY
+	if appendMode {
+		flags |= os.O_APPEND
+	} else {
+		flags |= os.O_TRUNC
+	}
+
+	f, err := os.OpenFile(path, flags, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+
+	w, err := NewWriter(f, codec, level)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &fileWriteCloser{WriteCloser: w, file: f}, nil
+}
+
+// nopWriteCloser adds a no-op Close to an io.Writer
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// fileReadCloser closes both the decompressor and the underlying file
+type fileReadCloser struct {
+	io.ReadCloser
+	file *os.File
+}
+
+func (f *fileReadCloser) Close() error {
+	err := f.ReadCloser.Close()
+	if cerr := f.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// fileWriteCloser flushes the compressor before closing the underlying file
+type fileWriteCloser struct {
+	io.WriteCloser
+	file *os.File
+}
+
+func (f *fileWriteCloser) Close() error {
+	err := f.WriteCloser.Close()
+	if cerr := f.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
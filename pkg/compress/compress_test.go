@@ -0,0 +1,146 @@
+package compress
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDetectCodec tests codec detection by file extension
+func TestDetectCodec(t *testing.T) {
+	tests := []struct {
+		path string
+		want Codec
+	}{
+		{"events.jsonl", None},
+		{"events.jsonl.gz", Gzip},
+		{"EVENTS.JSONL.GZ", Gzip},
+		{"events.jsonl.zst", Zstd},
+		{"events.jsonl.zstd", Zstd},
+		{"events.jsonl.sz", Snappy},
+		{"events", None},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := DetectCodec(tt.path); got != tt.want {
+				t.Errorf("DetectCodec(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseCodec tests parsing codec names from configuration
+func TestParseCodec(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Codec
+		wantErr bool
+	}{
+		{"", "", false},
+		{"auto", "", false},
+		{"none", None, false},
+		{"gzip", Gzip, false},
+		{"ZSTD", Zstd, false},
+		{"snappy", Snappy, false},
+		{"lz4", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseCodec(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCodec(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseCodec(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRoundTrip tests that data written with each codec reads back unchanged
+func TestRoundTrip(t *testing.T) {
+	payload := strings.Repeat(`{"id":"1","operation":"insert"}`+"\n", 100)
+
+	tests := []struct {
+		name  string
+		file  string
+		level int
+	}{
+		{"plain", "events.jsonl", 0},
+		{"gzip default", "events.jsonl.gz", 0},
+		{"gzip best", "events.jsonl.gz", 9},
+		{"zstd default", "events.jsonl.zst", 0},
+		{"zstd level 3", "events.jsonl.zst", 3},
+		{"snappy", "events.jsonl.sz", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+
+			w, err := Create(path, "", tt.level, false)
+			if err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			if _, err := io.WriteString(w, payload); err != nil {
+				t.Fatalf("Write() error = %v", err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			r, err := Open(path, "")
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer r.Close()
+
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if string(got) != payload {
+				t.Errorf("round trip mismatch: got %d bytes, want %d", len(got), len(payload))
+			}
+		})
+	}
+}
+
+// TestAppendMultiMember tests that appending to a compressed file stays readable
+func TestAppendMultiMember(t *testing.T) {
+	for _, file := range []string{"events.gz", "events.zst", "events.sz"} {
+		t.Run(file, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), file)
+
+			for _, chunk := range []string{"first\n", "second\n"} {
+				w, err := Create(path, "", 0, true)
+				if err != nil {
+					t.Fatalf("Create() error = %v", err)
+				}
+				if _, err := io.WriteString(w, chunk); err != nil {
+					t.Fatalf("Write() error = %v", err)
+				}
+				if err := w.Close(); err != nil {
+					t.Fatalf("Close() error = %v", err)
+				}
+			}
+
+			r, err := Open(path, "")
+			if err != nil {
+				t.Fatalf("Open() error = %v", err)
+			}
+			defer r.Close()
+
+			got, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("ReadAll() error = %v", err)
+			}
+			if string(got) != "first\nsecond\n" {
+				t.Errorf("expected both members, got %q", got)
+			}
+		})
+	}
+}
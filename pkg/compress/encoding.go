@@ -0,0 +1,92 @@
+package compress
+
+import (
+	"strconv"
+	"strings"
+)
+
+// snappyEncoding is the Content-Encoding of the Snappy framing format, which
+// has no registered HTTP token
+const snappyEncoding = "x-snappy-framed"
+
+// negotiationOrder is the order codecs are tried in when a server does not
+// accept the configured one: best ratio first
+var negotiationOrder = []Codec{Zstd, Snappy, Gzip}
+
+// ContentEncoding returns the HTTP Content-Encoding for a codec, or "" for none
+func ContentEncoding(codec Codec) string {
+	switch codec {
+	case Gzip:
+		return "gzip"
+	case Zstd:
+		return "zstd"
+	case Snappy:
+		return snappyEncoding
+	default:
+		return ""
+	}
+}
+
+// ParseContentEncoding returns the codec of an HTTP Content-Encoding, if supported
+func ParseContentEncoding(encoding string) (Codec, bool) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return None, true
+	case "gzip", "x-gzip":
+		return Gzip, true
+	case "zstd":
+		return Zstd, true
+	case snappyEncoding:
+		return Snappy, true
+	default:
+		return "", false
+	}
+}
+
+// Negotiate returns the codec to compress request bodies with, given the
+// Accept-Encoding a server advertised in a response (RFC 7694), typically
+// with a 415 Unsupported Media Type. The preferred codec is kept if the server
+// accepts it; otherwise the best codec it accepts is used, or none. Codings
+// with q=0 are refused.
+func Negotiate(preferred Codec, acceptEncoding string) Codec {
+	if preferred == "" || preferred == None {
+		return None
+	}
+	accepted := make(map[Codec]bool)
+	wildcard := false
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		if refused(params) {
+			continue
+		}
+		coding = strings.TrimSpace(coding)
+		if coding == "*" {
+			wildcard = true
+			continue
+		}
+		if codec, ok := ParseContentEncoding(coding); ok {
+			accepted[codec] = true
+		}
+	}
+	if accepted[preferred] || wildcard {
+		return preferred
+	}
+	for _, codec := range negotiationOrder {
+		if accepted[codec] {
+			return codec
+		}
+	}
+	return None
+}
+
+// refused reports whether the parameters of an Accept-Encoding coding give it q=0
+func refused(params string) bool {
+	for _, param := range strings.Split(params, ";") {
+		name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if ok && strings.EqualFold(name, "q") {
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			return err == nil && q == 0
+		}
+	}
+	return false
+}
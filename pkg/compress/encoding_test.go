@@ -0,0 +1,42 @@
+package compress
+
+import "testing"
+
+// TestContentEncoding tests that every codec's Content-Encoding parses back to it
+func TestContentEncoding(t *testing.T) {
+	for _, codec := range []Codec{None, Gzip, Zstd, Snappy} {
+		got, ok := ParseContentEncoding(ContentEncoding(codec))
+		if !ok || got != codec {
+			t.Errorf("ParseContentEncoding(ContentEncoding(%q)) = %q, %v", codec, got, ok)
+		}
+	}
+	if _, ok := ParseContentEncoding("br"); ok {
+		t.Error("expected br to be unsupported")
+	}
+}
+
+// TestNegotiate tests choosing a codec from a server's Accept-Encoding
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name      string
+		preferred Codec
+		accept    string
+		want      Codec
+	}{
+		{name: "preferred accepted", preferred: Zstd, accept: "gzip, zstd", want: Zstd},
+		{name: "fallback to best accepted", preferred: Zstd, accept: "gzip, x-snappy-framed", want: Snappy},
+		{name: "fallback to gzip", preferred: Snappy, accept: "GZIP", want: Gzip},
+		{name: "nothing accepted", preferred: Gzip, accept: "br", want: None},
+		{name: "identity only", preferred: Gzip, accept: "", want: None},
+		{name: "wildcard", preferred: Snappy, accept: "*", want: Snappy},
+		{name: "refused with q=0", preferred: Zstd, accept: "zstd;q=0, gzip;q=0.5", want: Gzip},
+		{name: "no compression configured", preferred: None, accept: "gzip", want: None},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Negotiate(tt.preferred, tt.accept); got != tt.want {
+				t.Errorf("Negotiate(%q, %q) = %q, want %q", tt.preferred, tt.accept, got, tt.want)
+			}
+		})
+	}
+}
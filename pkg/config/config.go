@@ -2,8 +2,10 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"reflect"
 )
 
 // Config represents the pipeline configuration
@@ -12,19 +14,232 @@ type Config struct {
 	Source      SourceConfig      `json:"source"`
 	Sink        SinkConfig        `json:"sink"`
 	Transformer TransformerConfig `json:"transformer,omitempty"`
+	Vault       *VaultConfig      `json:"vault,omitempty"`
+}
+
+// VaultConfig contains HashiCorp Vault settings used to resolve vault: references
+type VaultConfig struct {
+	Address   string `json:"address,omitempty"`    // Vault server URL (default: VAULT_ADDR)
+	Namespace string `json:"namespace,omitempty"`  // Vault Enterprise namespace
+	Auth      string `json:"auth,omitempty"`       // token (default), approle or kubernetes
+	MountPath string `json:"mount_path,omitempty"` // Auth method mount path (default: the auth method name)
+	Token     string `json:"token,omitempty"`      // Token auth (default: VAULT_TOKEN)
+	RoleID    string `json:"role_id,omitempty"`    // AppRole role ID (default: VAULT_ROLE_ID)
+	SecretID  string `json:"secret_id,omitempty"`  // AppRole secret ID (default: VAULT_SECRET_ID)
+	Role      string `json:"role,omitempty"`       // Kubernetes auth role
+	JWTPath   string `json:"jwt_path,omitempty"`   // Kubernetes service account token file
 }
 
 // PipelineConfig contains pipeline-level settings
 type PipelineConfig struct {
-	Name    string        `json:"name"`
-	Sync    SyncConfig    `json:"sync,omitempty"`
-	Metrics MetricsConfig `json:"metrics,omitempty"`
+	Name       string           `json:"name"`
+	Sync       SyncConfig       `json:"sync,omitempty"`
+	Metrics    MetricsConfig    `json:"metrics,omitempty"`
+	Limits     LimitsConfig     `json:"limits,omitempty"`
+	DLQ        DLQConfig        `json:"dlq,omitempty"`
+	Audit      AuditConfig      `json:"audit,omitempty"`
+	Alerts     AlertsConfig     `json:"alerts,omitempty"`
+	Buffer     BufferConfig     `json:"buffer,omitempty"`
+	Mode       string           `json:"mode,omitempty"` // Delivery mode: direct (default) or store_and_forward
+	WAL        BufferConfig     `json:"wal,omitempty"`
+	Checkpoint CheckpointConfig `json:"checkpoint,omitempty"`
+	Ordering   string           `json:"ordering,omitempty"` // "strict_global" (default), "strict_per_key" or "relaxed"
+	Workers    int              `json:"workers,omitempty"`  // Concurrent sink writers (default: 1)
+	Pooling    bool             `json:"pooling,omitempty"`  // Recycle event data maps and batches across stages
+
+	// Schedule replaces continuous change data capture with scheduled incremental syncs
+	Schedule ScheduleConfig `json:"schedule,omitempty"`
+
+	// KeyFields identify a document for ordering, splitting and sink upserts and deletes (default: _id)
+	KeyFields []string `json:"key_fields,omitempty"`
+
+	// Operations maps operations other than insert/update/replace/delete (or "default") to ignore, dlq, stop or resync
+	Operations map[string]string `json:"operations,omitempty"`
+
+	// Errors determines how the pipeline reacts to failed events
+	Errors ErrorsConfig `json:"errors,omitempty"`
+
+	// DrainTimeoutSeconds lets events already read be written on shutdown for up to this long
+	DrainTimeoutSeconds Seconds `json:"drain_timeout_seconds,omitempty"`
+
+	// Watchdog flags the pipeline unhealthy when the connected source stops delivering events
+	Watchdog WatchdogConfig `json:"watchdog,omitempty"`
+
+	// Throttle slows source reads down while the sink lags behind
+	Throttle ThrottleConfig `json:"throttle,omitempty"`
+
+	// Chaos injects faults at stage boundaries, for resilience testing only
+	Chaos ChaosConfig `json:"chaos,omitempty"`
+
+	// PII declares how personal data is anonymized in exported samples
+	PII PIIConfig `json:"pii,omitempty"`
+
+	// Origin marks written changes and skips those written by the opposite pipeline of a two-way sync
+	Origin OriginConfig `json:"origin,omitempty"`
+
+	// Debug serves /debug/pipeline and /debug/pprof on the metrics server and dumps state on SIGQUIT
+	Debug bool `json:"debug,omitempty"`
+
+	// Trace logs every stage of the events it selects
+	Trace TraceConfig `json:"trace,omitempty"`
+
+	// Schemas declare the expected fields of events per collection
+	Schemas SchemasConfig `json:"schemas,omitempty"`
+}
+
+// WatchdogConfig contains stalled source detection settings
+type WatchdogConfig struct {
+	WindowSeconds Seconds `json:"window_seconds,omitempty"` // Time waiting on the source without an event before it counts as stalled (0 disables)
+	RestartSource bool    `json:"restart_source,omitempty"` // Close and reconnect a stalled source, resuming after its last event
+}
+
+// ThrottleConfig contains adaptive flow control settings
+type ThrottleConfig struct {
+	MaxSinkLatencyMs     Milliseconds `json:"max_sink_latency_ms,omitempty"`     // Commit latency above which the sink counts as lagging (0 ignores latency)
+	MaxQueueDepth        int          `json:"max_queue_depth,omitempty"`         // Queued and in-flight events above which the sink counts as lagging (0 ignores depth)
+	MaxDelayMs           Milliseconds `json:"max_delay_ms,omitempty"`            // Longest pause before each read from the source (default: 100ms)
+	MaxBatchWindowFactor float64      `json:"max_batch_window_factor,omitempty"` // Most the source's batch window is stretched at max_delay_ms (default: 4)
+}
+
+// ChaosConfig contains fault injection settings; rates are fractions of events
+type ChaosConfig struct {
+	DelayRate     float64      `json:"delay_rate,omitempty"`     // Events held back before the transformer and before the sink
+	MaxDelayMs    Milliseconds `json:"max_delay_ms,omitempty"`   // Longest injected delay (default: 1s)
+	ErrorRate     float64      `json:"error_rate,omitempty"`     // Events failed in the transformer stage
+	DuplicateRate float64      `json:"duplicate_rate,omitempty"` // Events handed to the sink twice
+	Seed          int64        `json:"seed,omitempty"`           // Seed for reproducible faults (default: random)
+}
+
+// PIIConfig contains the anonymization rules of exported samples
+type PIIConfig struct {
+	Fields  []PIIField `json:"fields,omitempty"`  // Rules for individual fields
+	Default string     `json:"default,omitempty"` // Action for fields without a rule: keep, mask (default), hash or redact
+}
+
+// PIIField sets how the values of a field are anonymized
+type PIIField struct {
+	Field  string `json:"field"`  // Dot-separated for nested fields
+	Action string `json:"action"` // keep, mask, hash or redact
+}
+
+// OriginConfig contains the loop prevention settings of two-way sync
+type OriginConfig struct {
+	Name  string `json:"name,omitempty"`  // Tag of the changes this pipeline writes
+	Field string `json:"field,omitempty"` // Document field or column holding the marker
+}
+
+// ErrorsConfig contains error handling policy settings
+type ErrorsConfig struct {
+	OnTransformError         string  `json:"on_transform_error,omitempty"`          // skip (default), dlq or fail
+	OnSinkError              string  `json:"on_sink_error,omitempty"`               // retry (default), dlq or fail
+	MaxErrorRate             float64 `json:"max_error_rate,omitempty"`              // Stop once this fraction of a run's events is rejected (0 disables)
+	ErrorRateWindowSeconds   Seconds `json:"error_rate_window_seconds,omitempty"`   // Apply max_error_rate to a sliding window instead of the run
+	MaxConsecutiveSinkErrors int     `json:"max_consecutive_sink_errors,omitempty"` // Stop after this many sink errors without a commit (0 disables)
+}
+
+// SchemasConfig contains event schema settings
+type SchemasConfig struct {
+	Policy      string          `json:"policy,omitempty"`      // What happens to events violating their schema: warn (default) or dlq
+	Collections json.RawMessage `json:"collections,omitempty"` // Schemas per collection, see pkg/schema
+}
+
+// TraceConfig selects events whose full before and after are logged at each stage
+type TraceConfig struct {
+	ID         string `json:"id,omitempty"`          // Regular expression matched against the event ID
+	Field      string `json:"field,omitempty"`       // Source document field, dot-separated for nested fields
+	FieldMatch string `json:"field_match,omitempty"` // Regular expression matched against the field's value
+}
+
+// ScheduleConfig contains scheduled batch sync settings
+type ScheduleConfig struct {
+	Cron string `json:"cron,omitempty"` // Cron expression; the pipeline sleeps between syncs
+	Once bool   `json:"once,omitempty"` // Perform one sync and exit, for external schedulers
+}
+
+// CheckpointConfig contains source position checkpoint settings
+type CheckpointConfig struct {
+	Path  string `json:"path"`            // JSON file storing checkpoints (empty disables checkpointing)
+	Store string `json:"store,omitempty"` // "file" (default) or "postgresql" to commit checkpoints with each batch
+	Table string `json:"table,omitempty"` // Checkpoint table for the postgresql store
+
+	RunsPath string `json:"runs_path,omitempty"` // JSON file storing run history and snapshot stats (default: runs.json next to the checkpoints)
+	MaxRuns  int    `json:"max_runs,omitempty"`  // Runs kept per pipeline (default: 20)
+}
+
+// BufferConfig contains spill-to-disk buffer settings
+type BufferConfig struct {
+	Path         string `json:"path"`          // Directory for buffer segment files (empty disables the buffer)
+	MaxBytes     Size   `json:"max_bytes"`     // Maximum bytes buffered on disk (0 = unlimited)
+	SegmentBytes Size   `json:"segment_bytes"` // Segment file rotation size (default: 64MB)
+}
+
+// LimitsConfig contains event-size guardrail settings
+type LimitsConfig struct {
+	MaxEventSize    Size     `json:"max_event_size"`   // Maximum encoded event size in bytes (0 = unlimited)
+	OversizedPolicy string   `json:"oversized_policy"` // dlq, truncate, or split (default: dlq)
+	TruncateFields  []string `json:"truncate_fields"`  // Fields the truncate policy may shorten
+	TruncateLength  int      `json:"truncate_length"`  // Length truncated string fields are cut to (default: 1024)
+	MaxMemoryBytes  Size     `json:"max_memory_bytes"` // Soft budget for events awaiting the sink (0 = unlimited)
+	SplitKeyField   string   `json:"split_key_field"`  // Field copied into every split event (default: the pipeline key fields)
+}
+
+// DLQConfig contains dead-letter queue settings
+type DLQConfig struct {
+	Path             string `json:"path"`                        // JSON-lines file receiving rejected events (empty disables the DLQ)
+	Table            string `json:"table,omitempty"`             // PostgreSQL quarantine table receiving rejected events instead
+	ConnectionString string `json:"connection_string,omitempty"` // Database for the quarantine table (default: the postgresql sink's)
+	ManagementAPI    bool   `json:"management_api,omitempty"`    // Serve the DLQ endpoints on the metrics server
+}
+
+// AuditConfig contains per-event audit log settings
+type AuditConfig struct {
+	Path             string `json:"path,omitempty"`              // JSON-lines file receiving audit records
+	Table            string `json:"table,omitempty"`             // PostgreSQL table receiving audit records
+	ConnectionString string `json:"connection_string,omitempty"` // Database for the audit table (default: the postgresql sink's)
+}
+
+// AlertsConfig contains alerting thresholds and notification targets
+type AlertsConfig struct {
+	ErrorRatePerMinute   float64 `json:"error_rate_per_minute,omitempty"`  // Alert above this many errors per minute (0 disables)
+	MaxLagSeconds        Seconds `json:"max_lag_seconds,omitempty"`        // Alert when events are this far behind the source (0 disables)
+	OnStop               bool    `json:"on_stop,omitempty"`                // Alert when the pipeline stops or disconnects unexpectedly
+	CheckIntervalSeconds Seconds `json:"check_interval_seconds,omitempty"` // How often rules are evaluated (default: 30s)
+	WebhookURL           string  `json:"webhook_url,omitempty"`            // Generic JSON webhook
+	SlackWebhookURL      string  `json:"slack_webhook_url,omitempty"`      // Slack incoming webhook
+	PagerDutyRoutingKey  string  `json:"pagerduty_routing_key,omitempty"`  // PagerDuty Events API v2 integration key
+	PagerDutyURL         string  `json:"pagerduty_url,omitempty"`          // PagerDuty Events API endpoint override
 }
 
 // MetricsConfig contains metrics and monitoring settings
 type MetricsConfig struct {
-	Enabled bool `json:"enabled"` // Enable metrics endpoint
-	Port    int  `json:"port"`    // Port for metrics server (default: 2112)
+	Enabled       bool     `json:"enabled"`                  // Enable metrics endpoint
+	Port          int      `json:"port"`                     // Port for metrics server (default: 2112)
+	Backend       string   `json:"backend,omitempty"`        // prometheus (default), statsd or dogstatsd
+	StatsDAddress string   `json:"statsd_address,omitempty"` // StatsD server address (default: 127.0.0.1:8125)
+	Prefix        string   `json:"prefix,omitempty"`         // StatsD metric name prefix (default: datapipe)
+	Tags          []string `json:"tags,omitempty"`           // Extra DogStatsD tags, e.g. "env:prod"
+
+	// Final metrics are pushed on shutdown for short-lived runs (prometheus backend only)
+	PushGateway  string            `json:"push_gateway,omitempty"`  // Pushgateway URL
+	PushJob      string            `json:"push_job,omitempty"`      // Pushgateway job name (default: data-pipe)
+	OTLPEndpoint string            `json:"otlp_endpoint,omitempty"` // OTLP/HTTP metrics URL
+	OTLPHeaders  map[string]string `json:"otlp_headers,omitempty"`  // Extra OTLP request headers
+
+	// End-to-end latency, from the change at the source to the sink commit
+	Latency      bool   `json:"latency,omitempty"`        // Record datapipe_end_to_end_latency_seconds
+	TraceIDField string `json:"trace_id_field,omitempty"` // Event field with a trace ID, attached to latencies as exemplars
+
+	// Bytes read and written, documents scanned and sink storage growth, for cost attribution
+	ResourceAccounting bool `json:"resource_accounting,omitempty"`
+
+	// Securing the metrics/health/control HTTP server
+	TLSCertFile     string `json:"tls_cert_file,omitempty"`      // PEM certificate; enables HTTPS
+	TLSKeyFile      string `json:"tls_key_file,omitempty"`       // PEM private key
+	TLSClientCAFile string `json:"tls_client_ca_file,omitempty"` // PEM CA bundle; requires client certificates (mTLS)
+	AuthToken       string `json:"auth_token,omitempty"`         // Required bearer token
+	AuthUsername    string `json:"auth_username,omitempty"`      // Required basic auth username
+	AuthPassword    string `json:"auth_password,omitempty"`      // Required basic auth password
+	PublicProbes    bool   `json:"public_probes,omitempty"`      // Serve /health and /ready without authentication
 }
 
 // SyncConfig contains synchronization settings
@@ -33,6 +248,10 @@ type SyncConfig struct {
 	ForceInitialSync bool   `json:"force_initial_sync"` // Force initial sync even if data exists in sink
 	TimestampField   string `json:"timestamp_field"`    // Field name to use for timestamp-based sync
 	BatchSize        int    `json:"batch_size"`         // Batch size for initial sync (default: 1000)
+	Refresh          string `json:"refresh,omitempty"`  // How a forced full sync prepares the sink table: none (default) or truncate
+
+	// Filter is a MongoDB query (extended JSON) limiting the documents copied by the initial sync
+	Filter json.RawMessage `json:"filter,omitempty"`
 }
 
 // SourceConfig contains source configuration
@@ -59,9 +278,20 @@ func LoadFromFile(path string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
+	return parse(data)
+}
 
+// parse decodes a JSON configuration
+func parse(data []byte) (*Config, error) {
 	var config Config
 	if err := json.Unmarshal(data, &config); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		var raw map[string]interface{}
+		if errors.As(err, &typeErr) && typeErr.Field == "" && json.Unmarshal(data, &raw) == nil {
+			if field := failingField(raw, reflect.TypeOf(config), ""); field != "" {
+				return nil, fmt.Errorf("failed to parse config file: %s must be a %s, got a %s", field, typeName(typeErr.Type), typeErr.Value)
+			}
+		}
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
@@ -115,3 +345,51 @@ func (s SinkConfig) GetBool(key string) bool {
 	}
 	return false
 }
+
+// GetStringSlice safely retrieves a list of strings from settings
+func (s SourceConfig) GetStringSlice(key string) []string {
+	return getStringSlice(s.Settings, key)
+}
+
+// GetStringSlice safely retrieves a list of strings from settings
+func (s SinkConfig) GetStringSlice(key string) []string {
+	return getStringSlice(s.Settings, key)
+}
+
+// getStringSlice converts a JSON array setting to a list of strings, skipping other values
+func getStringSlice(settings map[string]interface{}, key string) []string {
+	list, ok := settings[key].([]interface{})
+	if !ok {
+		return nil
+	}
+	values := make([]string, 0, len(list))
+	for _, item := range list {
+		if val, ok := item.(string); ok {
+			values = append(values, val)
+		}
+	}
+	return values
+}
+
+// GetInt safely retrieves an int from settings
+func (s SourceConfig) GetInt(key string) int {
+	return getInt(s.Settings, key)
+}
+
+// GetInt safely retrieves an int from settings
+func (s SinkConfig) GetInt(key string) int {
+	return getInt(s.Settings, key)
+}
+
+// getInt converts a JSON number setting to an int
+func getInt(settings map[string]interface{}, key string) int {
+	switch val := settings[key].(type) {
+	case float64:
+		return int(val)
+	case int:
+		return val
+	case int64:
+		return int(val)
+	}
+	return 0
+}
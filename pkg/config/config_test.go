@@ -95,3 +95,29 @@ func TestGetString(t *testing.T) {
 		t.Errorf("Expected empty string for nonexistent key, got '%s'", val)
 	}
 }
+
+// TestGetStringSlice tests the GetStringSlice helper method
+func TestGetStringSlice(t *testing.T) {
+	sink := SinkConfig{
+		Type: "test",
+		Settings: map[string]interface{}{
+			"list":   []interface{}{"a", "b"},
+			"string": "a",
+		},
+	}
+
+	if val := sink.GetStringSlice("list"); len(val) != 2 || val[0] != "a" || val[1] != "b" {
+		t.Errorf("Expected [a b], got %v", val)
+	}
+	if val := sink.GetStringSlice("string"); val != nil {
+		t.Errorf("Expected nil for non-list value, got %v", val)
+	}
+	if val := sink.GetStringSlice("nonexistent"); val != nil {
+		t.Errorf("Expected nil for nonexistent key, got %v", val)
+	}
+
+	source := SourceConfig{Settings: map[string]interface{}{"list": []interface{}{"a"}}}
+	if val := source.GetStringSlice("list"); len(val) != 1 || val[0] != "a" {
+		t.Errorf("Expected [a], got %v", val)
+	}
+}
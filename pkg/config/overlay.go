@@ -0,0 +1,85 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ProfilePath returns the overlay file of a profile next to a base
+// configuration: config.json with profile prod becomes config.prod.json, as
+// does a remote location's path
+func ProfilePath(path, profile string) string {
+	if IsRemote(path) {
+		return profileURI(path, profile)
+	}
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + profile + ext
+}
+
+// LoadProfile loads a base configuration, from a file or a remote location
+// (see Fetch), and deep merges the overlay of a profile onto it; see Merge.
+// The overlay of a named profile must exist.
+func LoadProfile(path, profile string) (*Config, error) {
+	if profile == "" {
+		data, err := Fetch(context.Background(), path)
+		if err != nil {
+			return nil, err
+		}
+		return parse(data)
+	}
+	base, err := readObject(path)
+	if err != nil {
+		return nil, err
+	}
+	overlayPath := ProfilePath(path, profile)
+	overlay, err := readObject(overlayPath)
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %w", profile, err)
+	}
+	data, err := json.Marshal(Merge(base, overlay))
+	if err != nil {
+		return nil, err
+	}
+	return parse(data)
+}
+
+// Merge deep merges an overlay onto a base configuration, both decoded JSON
+// objects. Objects present in both are merged key by key; any other value of
+// the overlay, including a list, replaces the base's, and a null removes the
+// key. The base is modified and returned.
+func Merge(base, overlay map[string]interface{}) map[string]interface{} {
+	for key, value := range overlay {
+		if value == nil {
+			delete(base, key)
+			continue
+		}
+		overlayObject, ok := value.(map[string]interface{})
+		baseObject, baseOK := base[key].(map[string]interface{})
+		if ok && baseOK {
+			base[key] = Merge(baseObject, overlayObject)
+			continue
+		}
+		base[key] = value
+	}
+	return base
+}
+
+// readObject reads a JSON configuration as an object, keeping numbers exact
+// for the merged file
+func readObject(path string) (map[string]interface{}, error) {
+	data, err := Fetch(context.Background(), path)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return object, nil
+}
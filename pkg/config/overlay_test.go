@@ -0,0 +1,90 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// TestMerge tests deep merging an overlay onto a base configuration
+func TestMerge(t *testing.T) {
+	base := map[string]interface{}{
+		"name": "base",
+		"settings": map[string]interface{}{
+			"uri":    "mongodb://localhost",
+			"fields": []interface{}{"a", "b"},
+			"nested": map[string]interface{}{"keep": true, "drop": 1},
+		},
+		"metrics": map[string]interface{}{"enabled": true},
+	}
+	overlay := map[string]interface{}{
+		"settings": map[string]interface{}{
+			"uri":    "mongodb://prod",
+			"fields": []interface{}{"c"},
+			"nested": map[string]interface{}{"drop": nil},
+		},
+		"metrics": "off",
+		"extra":   1,
+	}
+	want := map[string]interface{}{
+		"name": "base",
+		"settings": map[string]interface{}{
+			"uri":    "mongodb://prod",
+			"fields": []interface{}{"c"},
+			"nested": map[string]interface{}{"keep": true},
+		},
+		"metrics": "off",
+		"extra":   1,
+	}
+	if got := Merge(base, overlay); !reflect.DeepEqual(got, want) {
+		t.Errorf("Merge() = %v, want %v", got, want)
+	}
+}
+
+// TestLoadProfile tests loading a base configuration with a profile overlay
+func TestLoadProfile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	write := func(name, data string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("config.json", `{
+		"pipeline": {"name": "orders", "workers": 2, "drain_timeout_seconds": 10},
+		"source": {"type": "mongodb", "settings": {"uri": "mongodb://localhost:27017", "database": "shop", "collection": "orders"}},
+		"sink": {"type": "postgresql", "settings": {"connection_string": "host=localhost", "table": "orders"}}
+	}`)
+	write("config.prod.json", `{
+		"pipeline": {"workers": 8, "drain_timeout_seconds": "1m"},
+		"source": {"settings": {"uri": "mongodb://prod:27017"}}
+	}`)
+
+	cfg, err := LoadProfile(path, "prod")
+	if err != nil {
+		t.Fatalf("LoadProfile() error = %v", err)
+	}
+	if cfg.Pipeline.Name != "orders" || cfg.Pipeline.Workers != 8 || cfg.Pipeline.DrainTimeoutSeconds.String() != "1m0s" {
+		t.Errorf("unexpected pipeline %+v", cfg.Pipeline)
+	}
+	if cfg.Source.GetString("uri") != "mongodb://prod:27017" || cfg.Source.GetString("database") != "shop" || cfg.Sink.GetString("table") != "orders" {
+		t.Errorf("unexpected settings %v %v", cfg.Source.Settings, cfg.Sink.Settings)
+	}
+
+	cfg, err = LoadProfile(path, "")
+	if err != nil {
+		t.Fatalf("LoadProfile() without a profile error = %v", err)
+	}
+	if cfg.Pipeline.Workers != 2 {
+		t.Errorf("expected the base configuration without a profile, got %+v", cfg.Pipeline)
+	}
+
+	if _, err := LoadProfile(path, "staging"); err == nil {
+		t.Error("expected error for a profile without an overlay")
+	}
+
+	if got := ProfilePath("/etc/data-pipe/config.json", "prod"); got != "/etc/data-pipe/config.prod.json" {
+		t.Errorf("ProfilePath() = %q", got)
+	}
+}
@@ -0,0 +1,202 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+const (
+	// fetchTimeout bounds reading a remote configuration
+	fetchTimeout = 30 * time.Second
+	// maxRemoteConfigBytes bounds the size of a remote configuration
+	maxRemoteConfigBytes = 16 << 20
+	// emptyPayloadHash is the SHA-256 of an empty request body
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	// defaultConsulAddress is the local Consul agent
+	defaultConsulAddress = "127.0.0.1:8500"
+)
+
+// IsRemote reports whether a configuration location is a URI rather than a file path
+func IsRemote(location string) bool {
+	scheme, _, ok := strings.Cut(location, "://")
+	if !ok {
+		return false
+	}
+	switch scheme {
+	case "http", "https", "s3", "consul":
+		return true
+	}
+	return false
+}
+
+// Fetch reads a configuration document from a file or from a URI:
+//   - http:// or https:// URLs are fetched with GET
+//   - s3://bucket/key is read with AWS credentials and region from the
+//     default chain; AWS_ENDPOINT_URL selects an S3-compatible endpoint
+//   - consul://host:port/key reads a key from Consul's KV store, with the
+//     token in CONSUL_HTTP_TOKEN, over HTTPS if CONSUL_HTTP_SSL is true;
+//     without a host, CONSUL_HTTP_ADDR or the local agent is used
+//
+// A Kubernetes ConfigMap is read as the file it is mounted as.
+func Fetch(ctx context.Context, location string) ([]byte, error) {
+	if !IsRemote(location) {
+		data, err := os.ReadFile(location)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+		return data, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config location %q: %w", location, err)
+	}
+	var req *http.Request
+	switch u.Scheme {
+	case "http", "https":
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	case "s3":
+		req, err = s3Request(ctx, u)
+	case "consul":
+		req, err = consulRequest(ctx, u)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch config from %s: %w", redact(u), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("failed to fetch config from %s: %s: %s", redact(u), resp.Status, strings.TrimSpace(string(msg)))
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteConfigBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch config from %s: %w", redact(u), err)
+	}
+	if len(data) > maxRemoteConfigBytes {
+		return nil, fmt.Errorf("config at %s exceeds %d bytes", redact(u), maxRemoteConfigBytes)
+	}
+	return data, nil
+}
+
+// Fingerprint hashes the documents a configuration is loaded from, the base
+// and the profile's overlay, so a refresh can tell whether they changed
+func Fingerprint(ctx context.Context, location, profile string) (string, error) {
+	hash := sha256.New()
+	locations := []string{location}
+	if profile != "" {
+		locations = append(locations, ProfilePath(location, profile))
+	}
+	for _, l := range locations {
+		data, err := Fetch(ctx, l)
+		if err != nil {
+			return "", err
+		}
+		hash.Write(data)
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// s3Request builds a signed GET of an s3://bucket/key object
+func s3Request(ctx context.Context, u *url.URL) (*http.Request, error) {
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return nil, fmt.Errorf("invalid S3 config location %q, expected s3://bucket/key", u.String())
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("AWS region is required to read config from S3")
+	}
+	escaped := (&url.URL{Path: key}).EscapedPath()
+	objectURL := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, cfg.Region, escaped)
+	if cfg.BaseEndpoint != nil && *cfg.BaseEndpoint != "" {
+		objectURL = strings.TrimSuffix(*cfg.BaseEndpoint, "/") + "/" + bucket + "/" + escaped
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, objectURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	if err := v4.NewSigner().SignHTTP(ctx, creds, req, emptyPayloadHash, "s3", cfg.Region, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to sign S3 request: %w", err)
+	}
+	return req, nil
+}
+
+// consulRequest builds a GET of a consul://host:port/key value
+func consulRequest(ctx context.Context, u *url.URL) (*http.Request, error) {
+	key := strings.TrimPrefix(u.Path, "/")
+	if key == "" {
+		return nil, fmt.Errorf("invalid Consul config location %q, expected consul://host:port/key", u.String())
+	}
+	address := u.Host
+	if address == "" {
+		address = os.Getenv("CONSUL_HTTP_ADDR")
+	}
+	if address == "" {
+		address = defaultConsulAddress
+	}
+	scheme := "http"
+	if os.Getenv("CONSUL_HTTP_SSL") == "true" {
+		scheme = "https"
+	}
+	if before, after, ok := strings.Cut(address, "://"); ok {
+		scheme, address = before, after
+	}
+	kvURL := url.URL{Scheme: scheme, Host: address, Path: "/v1/kv/" + key, RawQuery: "raw"}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, kvURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := os.Getenv("CONSUL_HTTP_TOKEN"); token != "" {
+		req.Header.Set("X-Consul-Token", token)
+	}
+	return req, nil
+}
+
+// profileURI returns the overlay of a profile next to a remote configuration
+func profileURI(location, profile string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+	ext := path.Ext(u.Path)
+	u.Path = strings.TrimSuffix(u.Path, ext) + "." + profile + ext
+	u.RawPath = ""
+	return u.String()
+}
+
+// redact drops credentials and query parameters, which may hold tokens, from
+// a location for messages
+func redact(u *url.URL) string {
+	clean := *u
+	clean.User = nil
+	clean.RawQuery = ""
+	return clean.String()
+}
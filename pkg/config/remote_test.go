@@ -0,0 +1,111 @@
+package config
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestFetchHTTP tests loading a configuration and its profile overlay over HTTP
+func TestFetchHTTP(t *testing.T) {
+	documents := map[string]string{
+		"/pipelines/orders.json":      `{"pipeline": {"name": "orders", "workers": 2}}`,
+		"/pipelines/orders.prod.json": `{"pipeline": {"workers": 8}}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		doc, ok := documents[r.URL.Path]
+		if !ok {
+			http.Error(w, "no such document", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(doc))
+	}))
+	defer server.Close()
+
+	location := server.URL + "/pipelines/orders.json"
+	if !IsRemote(location) {
+		t.Fatalf("IsRemote(%q) = false", location)
+	}
+	cfg, err := LoadProfile(location, "prod")
+	if err != nil {
+		t.Fatalf("LoadProfile() error = %v", err)
+	}
+	if cfg.Pipeline.Name != "orders" || cfg.Pipeline.Workers != 8 {
+		t.Errorf("unexpected pipeline %+v", cfg.Pipeline)
+	}
+
+	before, err := Fingerprint(context.Background(), location, "prod")
+	if err != nil {
+		t.Fatalf("Fingerprint() error = %v", err)
+	}
+	documents["/pipelines/orders.prod.json"] = `{"pipeline": {"workers": 4}}`
+	after, err := Fingerprint(context.Background(), location, "prod")
+	if err != nil {
+		t.Fatalf("Fingerprint() error = %v", err)
+	}
+	if before == after {
+		t.Error("expected the fingerprint to change with the overlay")
+	}
+
+	_, err = Fetch(context.Background(), server.URL+"/pipelines/missing.json?token=secret")
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected a 404 error, got %v", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "secret") {
+		t.Errorf("expected the query to be redacted, got %v", err)
+	}
+}
+
+// TestFetchConsul tests reading a configuration from Consul's KV store
+func TestFetchConsul(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/kv/data-pipe/orders.json" || !r.URL.Query().Has("raw") {
+			http.Error(w, "unexpected request "+r.URL.String(), http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("X-Consul-Token") != "acl-token" {
+			http.Error(w, "permission denied", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"pipeline": {"name": "orders"}}`))
+	}))
+	defer server.Close()
+
+	t.Setenv("CONSUL_HTTP_ADDR", server.URL)
+	t.Setenv("CONSUL_HTTP_TOKEN", "acl-token")
+	data, err := Fetch(context.Background(), "consul:///data-pipe/orders.json")
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if string(data) != `{"pipeline": {"name": "orders"}}` {
+		t.Errorf("Fetch() = %s", data)
+	}
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	if _, err := Fetch(context.Background(), "consul://"+host+"/data-pipe/orders.json"); err != nil {
+		t.Errorf("Fetch() with a host error = %v", err)
+	}
+	if _, err := Fetch(context.Background(), "consul://"+host+"/"); err == nil {
+		t.Error("expected error for a location without a key")
+	}
+}
+
+// TestProfileURI tests locating profile overlays next to remote configurations
+func TestProfileURI(t *testing.T) {
+	tests := []struct {
+		location string
+		want     string
+	}{
+		{"https://config.example.com/orders.json", "https://config.example.com/orders.prod.json"},
+		{"https://config.example.com/orders.json?version=3", "https://config.example.com/orders.prod.json?version=3"},
+		{"s3://configs/pipelines/orders.json", "s3://configs/pipelines/orders.prod.json"},
+		{"consul:///data-pipe/orders", "consul:///data-pipe/orders.prod"},
+	}
+	for _, tt := range tests {
+		if got := ProfilePath(tt.location, "prod"); got != tt.want {
+			t.Errorf("ProfilePath(%q) = %q, want %q", tt.location, got, tt.want)
+		}
+	}
+}
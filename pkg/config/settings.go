@@ -0,0 +1,227 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// DecodeSettings decodes component settings into a typed configuration
+// struct, whose fields name their setting with a json tag, and checks the
+// struct's validate tags. A setting of the wrong type is an error rather than
+// being read as its zero value, so a misconfigured component fails at
+// startup. Unknown settings are ignored.
+//
+// A validate tag holds comma-separated rules:
+//   - required: the setting must be set to a non-zero value
+//   - min=N, max=N: a number must lie within the bound, a duration within N
+//     nanoseconds
+//   - oneof=a b c: a non-empty string must be one of the values
+func DecodeSettings(settings map[string]interface{}, target interface{}) error {
+	settingsJSON, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	value := reflect.ValueOf(target)
+	if err := json.Unmarshal(settingsJSON, target); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			field := typeErr.Field
+			if field == "" && value.Kind() == reflect.Ptr {
+				field = failingField(settings, value.Elem().Type(), "")
+			}
+			if field != "" {
+				return fmt.Errorf("setting %q must be a %s, got a %s", field, typeName(typeErr.Type), typeErr.Value)
+			}
+		}
+		return err
+	}
+	if value.Kind() != reflect.Ptr || value.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	return validateStruct(value.Elem(), "")
+}
+
+// failingField finds the setting below prefix that fails to decode into its
+// field of struct type t. encoding/json does not name the field when a
+// type's own UnmarshalJSON rejects a value, as Seconds and Size do.
+func failingField(settings map[string]interface{}, t reflect.Type, prefix string) string {
+	if t.Kind() != reflect.Struct {
+		return ""
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if field.Anonymous && name == "" {
+			if found := failingField(settings, field.Type, prefix); found != "" {
+				return found
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		value, ok := settings[name]
+		if !ok || name == "-" {
+			continue
+		}
+		if nested, ok := value.(map[string]interface{}); ok && field.Type.Kind() == reflect.Struct {
+			if found := failingField(nested, field.Type, prefix+name+"."); found != "" {
+				return found
+			}
+			continue
+		}
+		valueJSON, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+		if err := json.Unmarshal(valueJSON, reflect.New(field.Type).Interface()); err != nil {
+			return prefix + name
+		}
+	}
+	return ""
+}
+
+// Decode decodes the source settings into a typed configuration; see DecodeSettings
+func (s SourceConfig) Decode(target interface{}) error {
+	return DecodeSettings(s.Settings, target)
+}
+
+// Decode decodes the sink settings into a typed configuration; see DecodeSettings
+func (s SinkConfig) Decode(target interface{}) error {
+	return DecodeSettings(s.Settings, target)
+}
+
+// Decode decodes the transformer settings into a typed configuration; see DecodeSettings
+func (t TransformerConfig) Decode(target interface{}) error {
+	return DecodeSettings(t.Settings, target)
+}
+
+// typeName describes a Go type as a JSON type
+func typeName(t reflect.Type) string {
+	switch t {
+	case reflect.TypeOf(Seconds(0)), reflect.TypeOf(Milliseconds(0)):
+		return `duration such as "30s"`
+	case reflect.TypeOf(Size(0)):
+		return `size such as "64MB"`
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "whole number"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "list"
+	default:
+		return "object"
+	}
+}
+
+// validateStruct checks the validate tags of a struct's fields and of its
+// nested structs, naming settings by their path below prefix
+func validateStruct(value reflect.Value, prefix string) error {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			// Settings of an embedded struct are decoded as the outer struct's
+			if err := validateStruct(value.Field(i), prefix); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		name = prefix + name
+		if rules := field.Tag.Get("validate"); rules != "" {
+			if err := validateField(value.Field(i), name, rules); err != nil {
+				return err
+			}
+		}
+		if value.Field(i).Kind() == reflect.Struct {
+			if err := validateStruct(value.Field(i), name+"."); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validateField checks a field against its validate rules
+func validateField(value reflect.Value, name, rules string) error {
+	for _, rule := range strings.Split(rules, ",") {
+		rule, arg, _ := strings.Cut(rule, "=")
+		switch rule {
+		case "required":
+			if value.IsZero() {
+				return fmt.Errorf("setting %q is required", name)
+			}
+		case "min", "max":
+			bound, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				return fmt.Errorf("setting %q: invalid %s rule %q", name, rule, arg)
+			}
+			number, ok := numberValue(value)
+			if !ok {
+				return fmt.Errorf("setting %q: %s rule on a non-numeric setting", name, rule)
+			}
+			if rule == "min" && number < bound {
+				return fmt.Errorf("setting %q must be at least %s, got %v", name, arg, value.Interface())
+			}
+			if rule == "max" && number > bound {
+				return fmt.Errorf("setting %q must be at most %s, got %v", name, arg, value.Interface())
+			}
+		case "oneof":
+			if value.Kind() != reflect.String {
+				return fmt.Errorf("setting %q: oneof rule on a non-string setting", name)
+			}
+			if s := value.String(); s != "" && !contains(strings.Fields(arg), s) {
+				return fmt.Errorf("setting %q must be one of %s, got %q", name, strings.Join(strings.Fields(arg), ", "), s)
+			}
+		default:
+			return fmt.Errorf("setting %q: unknown validation rule %q", name, rule)
+		}
+	}
+	return nil
+}
+
+// numberValue returns a numeric field's value
+func numberValue(value reflect.Value) (float64, bool) {
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return value.Float(), true
+	}
+	return 0, false
+}
+
+// contains reports whether values holds s
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+// testSettings is a typed configuration exercising the validation rules
+type testSettings struct {
+	URL       string   `json:"url" validate:"required"`
+	BatchSize int      `json:"batch_size" validate:"min=0,max=1000"`
+	Mode      string   `json:"mode" validate:"oneof=fast safe"`
+	Enabled   bool     `json:"enabled"`
+	Fields    []string `json:"fields"`
+	Retry     struct {
+		Attempts int `json:"attempts" validate:"min=1"`
+	} `json:"retry"`
+}
+
+// TestDecodeSettings tests decoding settings into a typed configuration and validating it
+func TestDecodeSettings(t *testing.T) {
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"url":        "http://localhost",
+			"batch_size": float64(100),
+			"mode":       "safe",
+			"enabled":    true,
+			"fields":     []interface{}{"a", "b"},
+			"retry":      map[string]interface{}{"attempts": float64(3)},
+			"unknown":    "ignored",
+		}
+	}
+	tests := []struct {
+		name    string
+		change  func(settings map[string]interface{})
+		wantErr string
+	}{
+		{"valid", func(map[string]interface{}) {}, ""},
+		{"missing required", func(s map[string]interface{}) { delete(s, "url") }, `setting "url" is required`},
+		{"wrong type", func(s map[string]interface{}) { s["batch_size"] = "100" }, `setting "batch_size" must be a whole number, got a string`},
+		{"fraction", func(s map[string]interface{}) { s["batch_size"] = 1.5 }, `setting "batch_size" must be a whole number`},
+		{"below min", func(s map[string]interface{}) { s["batch_size"] = float64(-1) }, `setting "batch_size" must be at least 0`},
+		{"above max", func(s map[string]interface{}) { s["batch_size"] = float64(5000) }, `setting "batch_size" must be at most 1000`},
+		{"not one of", func(s map[string]interface{}) { s["mode"] = "turbo" }, `setting "mode" must be one of fast, safe`},
+		{"wrong list type", func(s map[string]interface{}) { s["fields"] = "a" }, `setting "fields" must be a list`},
+		{"nested", func(s map[string]interface{}) { s["retry"] = map[string]interface{}{"attempts": float64(0)} }, `setting "retry.attempts" must be at least 1`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := valid()
+			tt.change(settings)
+			var target testSettings
+			err := SourceConfig{Settings: settings}.Decode(&target)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Decode() error = %v", err)
+				}
+				if target.URL != "http://localhost" || target.BatchSize != 100 || !target.Enabled || len(target.Fields) != 2 || target.Retry.Attempts != 3 {
+					t.Errorf("unexpected settings %+v", target)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Decode() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
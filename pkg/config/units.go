@@ -0,0 +1,146 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Seconds is a duration setting given either as a number of seconds or as a
+// string with units such as "30s", "5m" or "1h30m"
+type Seconds time.Duration
+
+// Milliseconds is a duration setting given either as a number of
+// milliseconds or as a string with units such as "250ms" or "2s"
+type Milliseconds time.Duration
+
+// Size is a byte size setting given either as a number of bytes or as a
+// string with units such as "512KB", "64MB" or "1.5GB". Units are binary: a
+// KB is 1024 bytes, and KiB, MiB, GiB and TiB are accepted as well.
+type Size int64
+
+// Duration returns the setting as a time.Duration
+func (s Seconds) Duration() time.Duration {
+	return time.Duration(s)
+}
+
+// String formats the setting as a duration
+func (s Seconds) String() string {
+	return time.Duration(s).String()
+}
+
+// UnmarshalJSON decodes a number of seconds or a duration string
+func (s *Seconds) UnmarshalJSON(data []byte) error {
+	d, err := parseDuration(data, time.Second, reflect.TypeOf(*s))
+	*s = Seconds(d)
+	return err
+}
+
+// Duration returns the setting as a time.Duration
+func (m Milliseconds) Duration() time.Duration {
+	return time.Duration(m)
+}
+
+// String formats the setting as a duration
+func (m Milliseconds) String() string {
+	return time.Duration(m).String()
+}
+
+// UnmarshalJSON decodes a number of milliseconds or a duration string
+func (m *Milliseconds) UnmarshalJSON(data []byte) error {
+	d, err := parseDuration(data, time.Millisecond, reflect.TypeOf(*m))
+	*m = Milliseconds(d)
+	return err
+}
+
+// UnmarshalJSON decodes a number of bytes or a size string
+func (s *Size) UnmarshalJSON(data []byte) error {
+	var number float64
+	if err := json.Unmarshal(data, &number); err == nil {
+		if number != math.Trunc(number) {
+			return &json.UnmarshalTypeError{Value: "fractional number " + string(data), Type: reflect.TypeOf(*s)}
+		}
+		*s = Size(number)
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return &json.UnmarshalTypeError{Value: jsonKind(data), Type: reflect.TypeOf(*s)}
+	}
+	size, err := ParseSize(text)
+	if err != nil {
+		return &json.UnmarshalTypeError{Value: "string " + strconv.Quote(text), Type: reflect.TypeOf(*s)}
+	}
+	*s = Size(size)
+	return nil
+}
+
+// sizeUnits are the multipliers of size suffixes, longest first per prefix
+var sizeUnits = []struct {
+	suffix     string
+	multiplier int64
+}{
+	{"KIB", 1 << 10}, {"MIB", 1 << 20}, {"GIB", 1 << 30}, {"TIB", 1 << 40},
+	{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30}, {"TB", 1 << 40},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"T", 1 << 40},
+	{"B", 1},
+}
+
+// ParseSize parses a byte size such as "64MB", "1.5GiB" or "4096"
+func ParseSize(text string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(text))
+	multiplier := int64(1)
+	for _, unit := range sizeUnits {
+		if strings.HasSuffix(s, unit.suffix) {
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			multiplier = unit.multiplier
+			break
+		}
+	}
+	number, err := strconv.ParseFloat(s, 64)
+	if err != nil || number < 0 || math.IsInf(number, 0) || math.IsNaN(number) {
+		return 0, fmt.Errorf("invalid size %q", text)
+	}
+	bytes := number * float64(multiplier)
+	if bytes > math.MaxInt64 {
+		return 0, fmt.Errorf("size %q is too large", text)
+	}
+	return int64(bytes), nil
+}
+
+// parseDuration decodes a duration given as a number of units or a string
+// with units, reporting a value of neither kind as a type error for t
+func parseDuration(data []byte, unit time.Duration, t reflect.Type) (time.Duration, error) {
+	var number float64
+	if err := json.Unmarshal(data, &number); err == nil {
+		return time.Duration(number * float64(unit)), nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return 0, &json.UnmarshalTypeError{Value: jsonKind(data), Type: t}
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(text))
+	if err != nil {
+		return 0, &json.UnmarshalTypeError{Value: "string " + strconv.Quote(text), Type: t}
+	}
+	return d, nil
+}
+
+// jsonKind names the kind of a JSON value for type errors
+func jsonKind(data []byte) string {
+	switch strings.TrimSpace(string(data))[0] {
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case 't', 'f':
+		return "bool"
+	case 'n':
+		return "null"
+	}
+	return "value"
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDurationSettings tests durations given as numbers in the setting's unit or as strings with units
+func TestDurationSettings(t *testing.T) {
+	tests := []struct {
+		name         string
+		value        string
+		wantSeconds  time.Duration
+		wantMillis   time.Duration
+		wantErr      bool
+		errSubstring string
+	}{
+		{name: "number", value: `30`, wantSeconds: 30 * time.Second, wantMillis: 30 * time.Millisecond},
+		{name: "fraction", value: `1.5`, wantSeconds: 1500 * time.Millisecond, wantMillis: 1500 * time.Microsecond},
+		{name: "seconds", value: `"30s"`, wantSeconds: 30 * time.Second, wantMillis: 30 * time.Second},
+		{name: "minutes", value: `"5m"`, wantSeconds: 5 * time.Minute, wantMillis: 5 * time.Minute},
+		{name: "compound", value: `"1h30m"`, wantSeconds: 90 * time.Minute, wantMillis: 90 * time.Minute},
+		{name: "milliseconds", value: `"250ms"`, wantSeconds: 250 * time.Millisecond, wantMillis: 250 * time.Millisecond},
+		{name: "no unit", value: `"30"`, wantErr: true, errSubstring: `string "30"`},
+		{name: "unknown unit", value: `"5 minutes"`, wantErr: true},
+		{name: "bool", value: `true`, wantErr: true, errSubstring: "bool"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seconds Seconds
+			err := json.Unmarshal([]byte(tt.value), &seconds)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Seconds error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !strings.Contains(err.Error(), tt.errSubstring) {
+					t.Errorf("error = %v, want it to mention %q", err, tt.errSubstring)
+				}
+				return
+			}
+			if seconds.Duration() != tt.wantSeconds {
+				t.Errorf("Seconds = %v, want %v", seconds.Duration(), tt.wantSeconds)
+			}
+
+			var millis Milliseconds
+			if err := json.Unmarshal([]byte(tt.value), &millis); err != nil {
+				t.Fatalf("Milliseconds error = %v", err)
+			}
+			if millis.Duration() != tt.wantMillis {
+				t.Errorf("Milliseconds = %v, want %v", millis.Duration(), tt.wantMillis)
+			}
+		})
+	}
+}
+
+// TestParseSize tests byte sizes with and without units
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    int64
+		wantErr bool
+	}{
+		{"4096", 4096, false},
+		{"512B", 512, false},
+		{"64KB", 64 << 10, false},
+		{"64MB", 64 << 20, false},
+		{"64 mb", 64 << 20, false},
+		{"1.5GB", 3 << 29, false},
+		{"2GiB", 2 << 30, false},
+		{"1T", 1 << 40, false},
+		{"", 0, true},
+		{"-1MB", 0, true},
+		{"64XB", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := ParseSize(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseSize() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestUnitsInConfig tests units in pipeline settings and in decoded component settings
+func TestUnitsInConfig(t *testing.T) {
+	var cfg Config
+	data := `{"pipeline": {
+		"drain_timeout_seconds": "1m",
+		"watchdog": {"window_seconds": 90},
+		"buffer": {"max_bytes": "1GB", "segment_bytes": 1048576},
+		"limits": {"max_event_size": "256KB"}
+	}}`
+	if err := json.Unmarshal([]byte(data), &cfg); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+	if cfg.Pipeline.DrainTimeoutSeconds.Duration() != time.Minute || cfg.Pipeline.Watchdog.WindowSeconds.Duration() != 90*time.Second {
+		t.Errorf("unexpected durations %+v", cfg.Pipeline)
+	}
+	if cfg.Pipeline.Buffer.MaxBytes != 1<<30 || cfg.Pipeline.Buffer.SegmentBytes != 1<<20 || cfg.Pipeline.Limits.MaxEventSize != 256<<10 {
+		t.Errorf("unexpected sizes %+v %+v", cfg.Pipeline.Buffer, cfg.Pipeline.Limits)
+	}
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"pipeline": {"watchdog": {"window_seconds": "soon"}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := LoadFromFile(path)
+	if err == nil || !strings.Contains(err.Error(), `pipeline.watchdog.window_seconds must be a duration`) {
+		t.Errorf("expected an error naming the setting, got %v", err)
+	}
+
+	var settings struct {
+		Interval Seconds `json:"interval_seconds" validate:"min=0"`
+		MaxSize  Size    `json:"max_size"`
+	}
+	err = DecodeSettings(map[string]interface{}{"interval_seconds": "5 minutes"}, &settings)
+	if err == nil || !strings.Contains(err.Error(), `setting "interval_seconds" must be a duration`) {
+		t.Errorf("expected an error naming the setting, got %v", err)
+	}
+	err = DecodeSettings(map[string]interface{}{"interval_seconds": "-5s"}, &settings)
+	if err == nil || !strings.Contains(err.Error(), `setting "interval_seconds" must be at least 0, got -5s`) {
+		t.Errorf("expected a negative duration to be rejected, got %v", err)
+	}
+	err = DecodeSettings(map[string]interface{}{"max_size": "lots"}, &settings)
+	if err == nil || !strings.Contains(err.Error(), `setting "max_size" must be a size`) {
+		t.Errorf("expected an error naming the setting, got %v", err)
+	}
+}
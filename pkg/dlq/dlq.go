@@ -0,0 +1,285 @@
+package dlq
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// Entry is a dead-lettered event together with the reason it was rejected
+type Entry struct {
+	ID         string         `json:"id"`
+	Pipeline   string         `json:"pipeline"`
+	Reason     string         `json:"reason"`
+	Attempts   int            `json:"attempts"`
+	FailedAt   time.Time      `json:"failed_at"`
+	ResolvedAt *time.Time     `json:"resolved_at,omitempty"` // set once a replay wrote the event
+	Event      pipeline.Event `json:"event"`
+}
+
+// FileQueue is a dead-letter queue stored as a JSON-lines file
+type FileQueue struct {
+	path     string
+	pipeline string
+	mu       sync.Mutex
+	file     *os.File
+}
+
+// NewFileQueue opens (or creates) a file-backed dead-letter queue
+func NewFileQueue(path, pipelineName string) (*FileQueue, error) {
+	if path == "" {
+		return nil, fmt.Errorf("dlq path is required")
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dlq file: %w", err)
+	}
+
+	return &FileQueue{
+		path:     path,
+		pipeline: pipelineName,
+		file:     f,
+	}, nil
+}
+
+// Send appends an event to the dead-letter queue
+func (q *FileQueue) Send(ctx context.Context, event pipeline.Event, reason string) error {
+	return q.Append(Entry{
+		Pipeline: q.pipeline,
+		Reason:   reason,
+		Attempts: 1,
+		FailedAt: time.Now(),
+		Event:    event,
+	})
+}
+
+// Append writes an entry to the dead-letter queue, assigning an ID if missing
+func (q *FileQueue) Append(entry Entry) error {
+	if entry.ID == "" {
+		entry.ID = newEntryID()
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode dlq entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.file == nil {
+		return fmt.Errorf("dlq is closed")
+	}
+	if _, err := q.file.Write(line); err != nil {
+		return fmt.Errorf("failed to write dlq entry: %w", err)
+	}
+	return nil
+}
+
+// Rewrite replaces the queue's entries with those returned by update, which
+// is given the entries currently in the file. The file is replaced atomically;
+// entries another process appends while it is rewritten may be lost.
+func (q *FileQueue) Rewrite(update func(entries []Entry) []Entry) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.file == nil {
+		return fmt.Errorf("dlq is closed")
+	}
+	entries, err := ReadEntries(q.path)
+	if err != nil {
+		return err
+	}
+	entries = update(entries)
+
+	tmp, err := os.CreateTemp(filepath.Dir(q.path), filepath.Base(q.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create dlq file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to encode dlq entry: %w", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write dlq file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write dlq file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), q.path); err != nil {
+		return fmt.Errorf("failed to replace dlq file: %w", err)
+	}
+
+	// Appends must go to the new file
+	f, err := os.OpenFile(q.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open dlq file: %w", err)
+	}
+	q.file.Close()
+	q.file = f
+	return nil
+}
+
+// ErrNotFound is returned for an entry ID that is not in the queue
+var ErrNotFound = errors.New("dlq entry not found")
+
+// Filter selects entries of a queue. Empty fields match every entry.
+type Filter struct {
+	Pipeline        string // Entries of this pipeline
+	EventID         string // Entries for this event ID
+	Reason          string // Entries whose reason contains this text
+	IncludeResolved bool   // Include entries already resolved by a replay or resubmission
+}
+
+// matches reports whether an entry is selected by the filter
+func (f Filter) matches(entry Entry) bool {
+	return (f.Pipeline == "" || entry.Pipeline == f.Pipeline) &&
+		(f.EventID == "" || entry.Event.ID == f.EventID) &&
+		(f.Reason == "" || strings.Contains(entry.Reason, f.Reason)) &&
+		(f.IncludeResolved || entry.ResolvedAt == nil)
+}
+
+// List returns the entries selected by a filter, oldest first
+func (q *FileQueue) List(filter Filter) ([]Entry, error) {
+	q.mu.Lock()
+	entries, err := ReadEntries(q.path)
+	q.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	selected := entries[:0]
+	for _, entry := range entries {
+		if filter.matches(entry) {
+			selected = append(selected, entry)
+		}
+	}
+	return selected, nil
+}
+
+// Get returns the entry with an ID
+func (q *FileQueue) Get(id string) (Entry, error) {
+	q.mu.Lock()
+	entries, err := ReadEntries(q.path)
+	q.mu.Unlock()
+	if err != nil {
+		return Entry{}, err
+	}
+	for _, entry := range entries {
+		if entry.ID == id {
+			return entry, nil
+		}
+	}
+	return Entry{}, ErrNotFound
+}
+
+// Delete removes the entry with an ID from the queue
+func (q *FileQueue) Delete(id string) error {
+	found := false
+	err := q.Rewrite(func(entries []Entry) []Entry {
+		kept := entries[:0]
+		for _, entry := range entries {
+			if entry.ID == id {
+				found = true
+				continue
+			}
+			kept = append(kept, entry)
+		}
+		return kept
+	})
+	if err == nil && !found {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Resolve marks the entry with an ID as resolved
+func (q *FileQueue) Resolve(id string) error {
+	found := false
+	now := time.Now()
+	err := q.Rewrite(func(entries []Entry) []Entry {
+		for i := range entries {
+			if entries[i].ID == id {
+				entries[i].ResolvedAt = &now
+				found = true
+			}
+		}
+		return entries
+	})
+	if err == nil && !found {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Close closes the dead-letter queue file
+func (q *FileQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.file == nil {
+		return nil
+	}
+	err := q.file.Close()
+	q.file = nil
+	return err
+}
+
+// ReadEntries reads all entries from a dead-letter queue file
+func ReadEntries(path string) ([]Entry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to open dlq file: %w", err)
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("failed to decode dlq entry: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dlq file: %w", err)
+	}
+
+	return entries, nil
+}
+
+// newEntryID generates a random identifier for a dlq entry
+func newEntryID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,109 @@
+package dlq
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// TestFileQueueSendAndRead tests writing entries and reading them back
+func TestFileQueueSendAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq.jsonl")
+
+	q, err := NewFileQueue(path, "test-pipeline")
+	if err != nil {
+		t.Fatalf("NewFileQueue() error = %v", err)
+	}
+
+	event := pipeline.Event{ID: "1", Operation: "insert", Data: map[string]interface{}{"name": "doc"}}
+	if err := q.Send(context.Background(), event, "too big"); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	entries, err := ReadEntries(path)
+	if err != nil {
+		t.Fatalf("ReadEntries() error = %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+
+	entry := entries[0]
+	if entry.ID == "" {
+		t.Error("expected entry ID to be assigned")
+	}
+	if entry.Pipeline != "test-pipeline" || entry.Reason != "too big" || entry.Attempts != 1 {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+	if entry.Event.ID != "1" || entry.Event.Data["name"] != "doc" {
+		t.Errorf("unexpected event: %+v", entry.Event)
+	}
+}
+
+// TestReadEntriesMissingFile tests that a missing DLQ file has no entries
+func TestReadEntriesMissingFile(t *testing.T) {
+	entries, err := ReadEntries(filepath.Join(t.TempDir(), "missing.jsonl"))
+	if err != nil {
+		t.Fatalf("ReadEntries() error = %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected no entries, got %d", len(entries))
+	}
+}
+
+// TestSendAfterClose tests that a closed queue rejects writes
+func TestSendAfterClose(t *testing.T) {
+	q, err := NewFileQueue(filepath.Join(t.TempDir(), "dlq.jsonl"), "p")
+	if err != nil {
+		t.Fatalf("NewFileQueue() error = %v", err)
+	}
+	q.Close()
+
+	if err := q.Send(context.Background(), pipeline.Event{}, "reason"); err == nil {
+		t.Error("expected error sending to a closed queue")
+	}
+}
+
+// TestFileQueueManage tests listing, resolving and deleting entries
+func TestFileQueueManage(t *testing.T) {
+	q, err := NewFileQueue(filepath.Join(t.TempDir(), "dlq.jsonl"), "p")
+	if err != nil {
+		t.Fatalf("NewFileQueue() error = %v", err)
+	}
+	defer q.Close()
+	for _, e := range []struct{ id, reason string }{{"1", "too big"}, {"2", "bad type"}, {"3", "bad type"}} {
+		if err := q.Send(context.Background(), pipeline.Event{ID: e.id}, e.reason); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+
+	entries, err := q.List(Filter{Reason: "bad"})
+	if err != nil || len(entries) != 2 || entries[0].Event.ID != "2" {
+		t.Fatalf("expected the two bad type entries, got %+v (%v)", entries, err)
+	}
+	if err := q.Resolve(entries[0].ID); err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if err := q.Delete(entries[1].ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := q.Delete(entries[1].ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound deleting twice, got %v", err)
+	}
+	if _, err := q.Get("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a missing entry, got %v", err)
+	}
+
+	if unresolved, _ := q.List(Filter{}); len(unresolved) != 1 || unresolved[0].Event.ID != "1" {
+		t.Errorf("expected only event 1 unresolved, got %+v", unresolved)
+	}
+	if all, _ := q.List(Filter{IncludeResolved: true, EventID: "2"}); len(all) != 1 || all[0].ResolvedAt == nil {
+		t.Errorf("expected resolved event 2, got %+v", all)
+	}
+}
@@ -0,0 +1,130 @@
+package dlq
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/internal/pgconn"
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// DefaultQuarantineTable is the table rejected events are written to by TableQueue
+const DefaultQuarantineTable = "datapipe_quarantine"
+
+// ConnectionStringProvider returns the connection string to open new connections with
+type ConnectionStringProvider = pgconn.ConnectionStringProvider
+
+// TableQueue is a dead-letter queue stored in a PostgreSQL quarantine table,
+// one row per rejected event with its original payload, the rule it failed
+// and the error, so rejects can be queried with SQL
+type TableQueue struct {
+	db       *sql.DB
+	table    string
+	pipeline string
+	clock    pipeline.Clock
+}
+
+// NewTableQueue connects to PostgreSQL and creates the quarantine table if needed
+func NewTableQueue(ctx context.Context, connStr, table, pipelineName string) (*TableQueue, error) {
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open quarantine database: %w", err)
+	}
+	return newTableQueue(ctx, db, table, pipelineName)
+}
+
+// NewRotatingTableQueue is like NewTableQueue, but asks the provider for a
+// fresh connection string for every new connection so rotated credentials are used
+func NewRotatingTableQueue(ctx context.Context, provider ConnectionStringProvider, table, pipelineName string) (*TableQueue, error) {
+	return newTableQueue(ctx, pgconn.OpenRotating(provider), table, pipelineName)
+}
+
+// newTableQueue creates the quarantine table if needed
+func newTableQueue(ctx context.Context, db *sql.DB, table, pipelineName string) (*TableQueue, error) {
+	if table == "" {
+		table = DefaultQuarantineTable
+	}
+	if !pgconn.ValidTableName.MatchString(table) {
+		db.Close()
+		return nil, fmt.Errorf("invalid quarantine table name: %s", table)
+	}
+
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	id BIGSERIAL PRIMARY KEY,
+	pipeline TEXT NOT NULL,
+	event_id TEXT NOT NULL,
+	operation TEXT NOT NULL,
+	collection TEXT NOT NULL,
+	rule TEXT,
+	error TEXT NOT NULL,
+	document_key JSONB,
+	payload JSONB,
+	quarantined_at TIMESTAMPTZ NOT NULL
+)`, table)
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create quarantine table: %w", err)
+	}
+
+	return &TableQueue{db: db, table: table, pipeline: pipelineName, clock: pipeline.SystemClock}, nil
+}
+
+// SetClock sets the time source used to stamp quarantined rows
+func (q *TableQueue) SetClock(clock pipeline.Clock) {
+	q.clock = clock
+}
+
+// Send inserts a rejected event into the quarantine table
+func (q *TableQueue) Send(ctx context.Context, event pipeline.Event, reason string) error {
+	values, err := quarantineRow(q.pipeline, event, reason, q.clock.Now())
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf(
+		"INSERT INTO %s (pipeline, event_id, operation, collection, rule, error, document_key, payload, quarantined_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)",
+		q.table,
+	)
+	if _, err := q.db.ExecContext(ctx, query, values...); err != nil {
+		return fmt.Errorf("failed to quarantine event %s: %w", event.ID, err)
+	}
+	return nil
+}
+
+// Close closes the quarantine database connection
+func (q *TableQueue) Close() error {
+	return q.db.Close()
+}
+
+// quarantineRow returns the column values of a rejected event: the rule is
+// taken from its rejection_rule metadata, and its key and data are stored as
+// JSON, NULL when absent
+func quarantineRow(pipelineName string, event pipeline.Event, reason string, now time.Time) ([]interface{}, error) {
+	key, err := jsonColumn(event.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key of event %s: %w", event.ID, err)
+	}
+	payload, err := jsonColumn(event.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload of event %s: %w", event.ID, err)
+	}
+	var rule interface{}
+	if r := event.Metadata[pipeline.MetadataRejectionRule]; r != "" {
+		rule = r
+	}
+	return []interface{}{pipelineName, event.ID, event.Operation, event.Collection, rule, reason, key, payload, now}, nil
+}
+
+// jsonColumn encodes a document for a JSONB column, or nil for an empty one
+func jsonColumn(document map[string]interface{}) (interface{}, error) {
+	if len(document) == 0 {
+		return nil, nil
+	}
+	encoded, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,96 @@
+package dlq
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// TestNewTableQueueRejectsInvalidTable tests table name validation
+func TestNewTableQueueRejectsInvalidTable(t *testing.T) {
+	provider := func(ctx context.Context) (string, error) {
+		t.Error("expected no connection for an invalid table name")
+		return "", nil
+	}
+	if _, err := NewRotatingTableQueue(context.Background(), provider, "quarantine; DROP TABLE x", "test-pipeline"); err == nil {
+		t.Error("expected an error for an invalid table name")
+	}
+}
+
+// TestQuarantineRow tests the columns written for a rejected event
+func TestQuarantineRow(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	event := pipeline.WithRejectionRule(pipeline.Event{
+		ID:         "1",
+		Operation:  "insert",
+		Collection: "orders",
+		Key:        map[string]interface{}{"_id": "1"},
+		Data:       map[string]interface{}{"_id": "1", "total": "abc"},
+	}, "schema_violation")
+
+	got, err := quarantineRow("test-pipeline", event, "total is string, expected number", now)
+	if err != nil {
+		t.Fatalf("quarantineRow() error = %v", err)
+	}
+	want := []interface{}{"test-pipeline", "1", "insert", "orders", "schema_violation", "total is string, expected number",
+		`{"_id":"1"}`, `{"_id":"1","total":"abc"}`, now}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("quarantineRow() = %v, want %v", got, want)
+	}
+
+	// Deletes carry no data, and events rejected outside the pipeline no rule
+	got, err = quarantineRow("test-pipeline", pipeline.Event{ID: "2", Operation: "delete"}, "dropped", now)
+	if err != nil {
+		t.Fatalf("quarantineRow() error = %v", err)
+	}
+	if got[4] != nil || got[6] != nil || got[7] != nil {
+		t.Errorf("expected NULL rule, key and payload, got %v", got)
+	}
+}
+
+// recordingConnector is a database/sql connector recording the arguments of
+// the statements it executes, without a database
+type recordingConnector struct {
+	inserts [][]driver.NamedValue
+}
+
+func (c *recordingConnector) Connect(ctx context.Context) (driver.Conn, error) { return c, nil }
+func (c *recordingConnector) Driver() driver.Driver                            { return nil }
+func (c *recordingConnector) Prepare(query string) (driver.Stmt, error)        { return nil, driver.ErrSkip }
+func (c *recordingConnector) Close() error                                     { return nil }
+func (c *recordingConnector) Begin() (driver.Tx, error)                        { return nil, driver.ErrSkip }
+
+func (c *recordingConnector) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if strings.HasPrefix(query, "INSERT") {
+		c.inserts = append(c.inserts, args)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+// TestTableQueueSend tests that rejected events are stamped with the queue's clock
+func TestTableQueueSend(t *testing.T) {
+	conn := &recordingConnector{}
+	q, err := newTableQueue(context.Background(), sql.OpenDB(conn), "", "test-pipeline")
+	if err != nil {
+		t.Fatalf("newTableQueue() error = %v", err)
+	}
+	defer q.Close()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	q.SetClock(pipeline.NewManualClock(now))
+
+	if err := q.Send(context.Background(), pipeline.Event{ID: "1", Operation: "insert"}, "rejected"); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if len(conn.inserts) != 1 {
+		t.Fatalf("expected 1 insert, got %d", len(conn.inserts))
+	}
+	if got := conn.inserts[0][8].Value; got != now {
+		t.Errorf("expected the row stamped %v, got %v", now, got)
+	}
+}
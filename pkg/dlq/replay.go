@@ -0,0 +1,155 @@
+package dlq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// ReplayOptions selects the entries a replay re-runs and how
+type ReplayOptions struct {
+	Pipeline    string               // Replay only this pipeline's entries; empty replays every pipeline
+	Filter      string               // Regular expression matched against the entry ID, event ID and reason
+	Transformer pipeline.Transformer // Re-run before the sink; nil sends events as stored
+}
+
+// ReplayResult summarizes a replay
+type ReplayResult struct {
+	Replayed     int `json:"replayed"`      // entries re-run
+	Resolved     int `json:"resolved"`      // entries written to the sink and marked resolved
+	DeadLettered int `json:"dead_lettered"` // entries that failed again, with their attempt count incremented
+}
+
+// deadLetterSetter is implemented by sinks that dead-letter the events of a
+// batch they cannot write instead of failing it
+type deadLetterSetter interface {
+	SetDeadLetterQueue(dlq pipeline.DeadLetterQueue)
+}
+
+// failureCapture is the dead-letter queue a sink reports failures to during a replay
+type failureCapture struct {
+	mu     sync.Mutex
+	reason string
+}
+
+func (c *failureCapture) Send(ctx context.Context, event pipeline.Event, reason string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reason = reason
+	return nil
+}
+
+// take returns and clears the last captured failure
+func (c *failureCapture) take() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	reason := c.reason
+	c.reason = ""
+	return reason
+}
+
+// Replay re-runs unresolved dead-lettered events through an optional
+// transformer and a connected sink, one event at a time so every outcome is
+// known. Entries that are written are marked resolved; entries that fail again
+// get the new reason and an incremented attempt count. The queue file is
+// rewritten once all selected entries are replayed.
+func Replay(ctx context.Context, q *FileQueue, snk pipeline.Sink, opts ReplayOptions, logger *log.Logger) (ReplayResult, error) {
+	if logger == nil {
+		logger = log.Default()
+	}
+	var filter *regexp.Regexp
+	if opts.Filter != "" {
+		var err error
+		if filter, err = regexp.Compile(opts.Filter); err != nil {
+			return ReplayResult{}, fmt.Errorf("invalid replay filter: %w", err)
+		}
+	}
+
+	entries, err := ReadEntries(q.path)
+	if err != nil {
+		return ReplayResult{}, err
+	}
+
+	// Failures the sink would dead-letter itself are counted against the entry instead
+	capture := &failureCapture{}
+	if setter, ok := snk.(deadLetterSetter); ok {
+		setter.SetDeadLetterQueue(capture)
+	}
+
+	var result ReplayResult
+	failures := make(map[string]string) // entry ID -> reason it failed again
+	resolved := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.ResolvedAt != nil || (opts.Pipeline != "" && entry.Pipeline != opts.Pipeline) {
+			continue
+		}
+		if filter != nil && !filter.MatchString(entry.ID) && !filter.MatchString(entry.Event.ID) && !filter.MatchString(entry.Reason) {
+			continue
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		result.Replayed++
+		err := replayEvent(ctx, entry.Event, snk, opts.Transformer)
+		reason := capture.take()
+		if err != nil {
+			reason = err.Error()
+		}
+		if reason != "" {
+			logger.Printf("Replay of dead-lettered event %s failed: %s", entry.Event.ID, reason)
+			failures[entry.ID] = reason
+		} else {
+			resolved[entry.ID] = true
+		}
+	}
+	result.Resolved = len(resolved)
+	result.DeadLettered = len(failures)
+
+	now := time.Now()
+	err = q.Rewrite(func(current []Entry) []Entry {
+		for i := range current {
+			if resolved[current[i].ID] {
+				current[i].ResolvedAt = &now
+			} else if reason, failed := failures[current[i].ID]; failed {
+				current[i].Reason = reason
+				current[i].Attempts++
+				current[i].FailedAt = now
+			}
+		}
+		return current
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to record replay outcomes: %w", err)
+	}
+	if ctx.Err() != nil {
+		return result, fmt.Errorf("replay interrupted: %w", ctx.Err())
+	}
+	return result, nil
+}
+
+// replayEvent transforms an event and writes it to the sink on its own
+func replayEvent(ctx context.Context, event pipeline.Event, snk pipeline.Sink, transformer pipeline.Transformer) error {
+	if transformer != nil {
+		transformed, err := transformer.Transform(ctx, event)
+		if err != nil {
+			return fmt.Errorf("transform failed: %w", err)
+		}
+		event = transformed
+	}
+
+	events := make(chan pipeline.Event, 1)
+	events <- event
+	close(events)
+	var errs []error
+	for err := range snk.Write(ctx, events) {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
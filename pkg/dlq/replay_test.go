@@ -0,0 +1,112 @@
+package dlq
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// replaySink fails events whose ID is in fail and dead-letters those in isolate
+type replaySink struct {
+	fail    map[string]bool
+	isolate map[string]bool
+	dlq     pipeline.DeadLetterQueue
+	written []pipeline.Event
+}
+
+func (s *replaySink) Connect(ctx context.Context) error { return nil }
+func (s *replaySink) Close() error                      { return nil }
+
+func (s *replaySink) SetDeadLetterQueue(dlq pipeline.DeadLetterQueue) {
+	s.dlq = dlq
+}
+
+func (s *replaySink) Write(ctx context.Context, events <-chan pipeline.Event) <-chan error {
+	errors := make(chan error, 1)
+	go func() {
+		defer close(errors)
+		for event := range events {
+			switch {
+			case s.fail[event.ID]:
+				errors <- fmt.Errorf("cannot write %s", event.ID)
+			case s.isolate[event.ID]:
+				s.dlq.Send(ctx, event, "constraint violation")
+			default:
+				s.written = append(s.written, event)
+			}
+		}
+	}()
+	return errors
+}
+
+// TestReplay tests that replayed entries are resolved or re-dead-lettered
+func TestReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dlq.jsonl")
+	q, err := NewFileQueue(path, "orders")
+	if err != nil {
+		t.Fatalf("NewFileQueue() error = %v", err)
+	}
+	defer q.Close()
+	for _, id := range []string{"ok", "fails", "isolated", "skipped"} {
+		if err := q.Send(context.Background(), pipeline.Event{ID: id}, "too big"); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	other := Entry{Pipeline: "users", Reason: "too big", Attempts: 1, Event: pipeline.Event{ID: "other"}}
+	if err := q.Append(other); err != nil {
+		t.Fatalf("Append() error = %v", err)
+	}
+
+	snk := &replaySink{fail: map[string]bool{"fails": true}, isolate: map[string]bool{"isolated": true}}
+	result, err := Replay(context.Background(), q, snk, ReplayOptions{Pipeline: "orders", Filter: "^(ok|fails|isolated)$"}, nil)
+	if err != nil {
+		t.Fatalf("Replay() error = %v", err)
+	}
+	if result != (ReplayResult{Replayed: 3, Resolved: 1, DeadLettered: 2}) {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if len(snk.written) != 1 || snk.written[0].ID != "ok" {
+		t.Errorf("expected only ok to be written, got %+v", snk.written)
+	}
+
+	entries, err := ReadEntries(path)
+	if err != nil {
+		t.Fatalf("ReadEntries() error = %v", err)
+	}
+	byEvent := make(map[string]Entry)
+	for _, entry := range entries {
+		byEvent[entry.Event.ID] = entry
+	}
+	if len(byEvent) != 5 {
+		t.Fatalf("expected every entry to be kept, got %d", len(byEvent))
+	}
+	if byEvent["ok"].ResolvedAt == nil {
+		t.Error("expected ok to be resolved")
+	}
+	if e := byEvent["fails"]; e.Attempts != 2 || e.Reason != "cannot write fails" || e.ResolvedAt != nil {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if e := byEvent["isolated"]; e.Attempts != 2 || e.Reason != "constraint violation" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	if e := byEvent["skipped"]; e.Attempts != 1 || e.ResolvedAt != nil {
+		t.Errorf("expected the filtered out entry to be untouched, got %+v", e)
+	}
+	if e := byEvent["other"]; e.Attempts != 1 || e.ResolvedAt != nil {
+		t.Errorf("expected the other pipeline's entry to be untouched, got %+v", e)
+	}
+
+	// Resolved entries are not replayed again, and the queue still accepts appends
+	if result, _ := Replay(context.Background(), q, snk, ReplayOptions{Filter: "^ok$"}, nil); result.Replayed != 0 {
+		t.Errorf("expected resolved entries to be skipped, got %+v", result)
+	}
+	if err := q.Send(context.Background(), pipeline.Event{ID: "new"}, "too big"); err != nil {
+		t.Fatalf("Send() after Rewrite error = %v", err)
+	}
+	if entries, _ := ReadEntries(path); len(entries) != 6 {
+		t.Errorf("expected 6 entries after an append, got %d", len(entries))
+	}
+}
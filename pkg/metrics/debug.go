@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// debugWriteTimeout bounds responses once debugging is enabled, long enough
+// for the default 30 second CPU profile
+const debugWriteTimeout = 2 * time.Minute
+
+// DebugInspector exposes a pipeline's internal state to the debug endpoints
+type DebugInspector interface {
+	// DebugState returns a JSON-encodable dump of the pipeline's internal state
+	DebugState() interface{}
+}
+
+// SetDebugInspector enables the /debug/pipeline and /debug/pprof endpoints.
+// They reveal internals and can be costly, so they are off unless set.
+func (s *Server) SetDebugInspector(debug DebugInspector) {
+	s.debug = debug
+	s.server.WriteTimeout = debugWriteTimeout
+}
+
+// registerDebugHandlers adds the debug endpoints to a mux
+func (s *Server) registerDebugHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("GET /debug/pipeline", s.debugOnly(func(w http.ResponseWriter, r *http.Request) {
+		s.writeJSON(w, s.debug.DebugState())
+	}))
+	mux.HandleFunc("/debug/pprof/", s.debugOnly(pprof.Index))
+	mux.HandleFunc("/debug/pprof/cmdline", s.debugOnly(pprof.Cmdline))
+	mux.HandleFunc("/debug/pprof/profile", s.debugOnly(pprof.Profile))
+	mux.HandleFunc("/debug/pprof/symbol", s.debugOnly(pprof.Symbol))
+	mux.HandleFunc("/debug/pprof/trace", s.debugOnly(pprof.Trace))
+}
+
+// debugOnly serves a handler only when debugging is enabled, and 404s otherwise
+func (s *Server) debugOnly(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.debug == nil {
+			http.NotFound(w, r)
+			return
+		}
+		handler(w, r)
+	}
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// staticDebug is a DebugInspector serving a fixed dump
+type staticDebug struct{}
+
+func (staticDebug) DebugState() interface{} {
+	return map[string]interface{}{"pipeline": "orders", "stage": "waiting for sink"}
+}
+
+// TestDebugEndpoints tests that the debug endpoints are only served once enabled
+func TestDebugEndpoints(t *testing.T) {
+	server := NewServer(":0", nil, nil)
+	for _, path := range []string{"/debug/pipeline", "/debug/pprof/"} {
+		rec := httptest.NewRecorder()
+		server.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: expected status 404 while disabled, got %d", path, rec.Code)
+		}
+	}
+
+	server.SetDebugInspector(staticDebug{})
+	for _, path := range []string{"/debug/pipeline", "/debug/pprof/", "/debug/pprof/goroutine?debug=1"} {
+		rec := httptest.NewRecorder()
+		server.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", path, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	server.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pipeline", nil))
+	var dump map[string]string
+	if err := json.NewDecoder(rec.Body).Decode(&dump); err != nil {
+		t.Fatalf("invalid debug response: %v", err)
+	}
+	if dump["stage"] != "waiting for sink" {
+		t.Errorf("unexpected debug response %v", dump)
+	}
+}
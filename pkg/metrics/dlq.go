@@ -0,0 +1,187 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// defaultDeadLettersLimit is the number of dead letters returned when no limit is requested
+const defaultDeadLettersLimit = 100
+
+// ErrDeadLetterNotFound is returned by a DeadLetterManager for an unknown entry ID
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// ErrPipelineNotRunning is returned by a DeadLetterManager that cannot
+// resubmit an event because the pipeline is not running
+var ErrPipelineNotRunning = errors.New("pipeline is not running")
+
+// DeadLetterManager exposes a pipeline's dead-letter queue to the management API
+type DeadLetterManager interface {
+	// DeadLetters returns the entries selected by a filter, oldest first
+	DeadLetters(ctx context.Context, filter DeadLetterFilter) ([]DeadLetter, error)
+	// DeleteDeadLetter removes an entry from the queue
+	DeleteDeadLetter(ctx context.Context, id string) error
+	// ResubmitDeadLetter sends an entry's event back through the running
+	// pipeline and marks the entry resolved
+	ResubmitDeadLetter(ctx context.Context, id string) error
+}
+
+// DeadLetterFilter selects dead letters; empty fields match every entry
+type DeadLetterFilter struct {
+	EventID         string
+	Reason          string // substring of the rejection reason
+	IncludeResolved bool
+}
+
+// DeadLetter describes one entry of a dead-letter queue
+type DeadLetter struct {
+	ID         string      `json:"id"`
+	Pipeline   string      `json:"pipeline"`
+	Reason     string      `json:"reason"`
+	Attempts   int         `json:"attempts"`
+	FailedAt   string      `json:"failed_at"`
+	ResolvedAt string      `json:"resolved_at,omitempty"`
+	Event      interface{} `json:"event"`
+}
+
+// DeadLetterList is a page of dead letters
+type DeadLetterList struct {
+	Total   int          `json:"total"` // entries matching the filter, before the limit
+	Entries []DeadLetter `json:"entries"`
+}
+
+// SetDeadLetterManager enables the /api/pipelines/{name}/dlq endpoints.
+// Deleting and resubmitting dead letters changes what the pipeline delivers,
+// so those endpoints are only enabled if the server authenticates clients:
+// call SetAuth or SetTLS with a client CA first.
+func (s *Server) SetDeadLetterManager(dlq DeadLetterManager) {
+	s.dlq = dlq
+	s.dlqWritable = s.authenticates()
+	if !s.dlqWritable {
+		s.logger.Printf("Dead letters can only be listed: deleting and resubmitting them requires metrics authentication or client certificates")
+	}
+}
+
+// registerDeadLetterHandlers adds the dead-letter queue endpoints to a mux
+func (s *Server) registerDeadLetterHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/pipelines/{name}/dlq", s.deadLettersHandler)
+	mux.HandleFunc("DELETE /api/pipelines/{name}/dlq/{id}", s.deleteDeadLetterHandler)
+	mux.HandleFunc("POST /api/pipelines/{name}/dlq/{id}/resubmit", s.resubmitDeadLetterHandler)
+}
+
+// deadLettersHandler serves a pipeline's dead letters; ?event_id=, ?reason=
+// and ?resolved=true filter them and ?limit=N bounds the count
+func (s *Server) deadLettersHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.servesDeadLetters(w, r) {
+		return
+	}
+	query := r.URL.Query()
+	filter := DeadLetterFilter{
+		EventID: query.Get("event_id"),
+		Reason:  query.Get("reason"),
+	}
+	if v := query.Get("resolved"); v != "" {
+		include, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "resolved must be true or false", http.StatusBadRequest)
+			return
+		}
+		filter.IncludeResolved = include
+	}
+	limit := defaultDeadLettersLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	entries, err := s.dlq.DeadLetters(r.Context(), filter)
+	if err != nil {
+		s.logger.Printf("Error loading dead letters: %v", err)
+		http.Error(w, "failed to load dead letters", http.StatusInternalServerError)
+		return
+	}
+	list := DeadLetterList{Total: len(entries), Entries: entries}
+	if len(list.Entries) > limit {
+		list.Entries = list.Entries[:limit]
+	}
+	if list.Entries == nil {
+		list.Entries = []DeadLetter{}
+	}
+	s.writeJSON(w, list)
+}
+
+// deleteDeadLetterHandler removes a dead letter
+func (s *Server) deleteDeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.servesDeadLetters(w, r) {
+		return
+	}
+	if !s.managesDeadLetters(w) {
+		return
+	}
+	id := r.PathValue("id")
+	if err := s.dlq.DeleteDeadLetter(r.Context(), id); err != nil {
+		s.writeDeadLetterError(w, "deleting", id, err)
+		return
+	}
+	s.logger.Printf("Deleted dead letter %s through the API", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// resubmitDeadLetterHandler sends a dead letter's event back through the pipeline
+func (s *Server) resubmitDeadLetterHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.servesDeadLetters(w, r) {
+		return
+	}
+	if !s.managesDeadLetters(w) {
+		return
+	}
+	id := r.PathValue("id")
+	if err := s.dlq.ResubmitDeadLetter(r.Context(), id); err != nil {
+		s.writeDeadLetterError(w, "resubmitting", id, err)
+		return
+	}
+	s.logger.Printf("Resubmitted dead letter %s through the API", id)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// servesDeadLetters reports whether the request names the inspected pipeline
+// and its dead-letter queue is managed, writing a 404 if not
+func (s *Server) servesDeadLetters(w http.ResponseWriter, r *http.Request) bool {
+	if !s.servesPipeline(w, r) {
+		return false
+	}
+	if s.dlq == nil {
+		http.Error(w, "pipeline has no dead-letter queue", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// managesDeadLetters reports whether dead letters may be deleted and
+// resubmitted, writing a 403 if not
+func (s *Server) managesDeadLetters(w http.ResponseWriter) bool {
+	if !s.dlqWritable {
+		http.Error(w, "dead-letter management requires metrics authentication or client certificates", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// writeDeadLetterError maps a DeadLetterManager error to a response
+func (s *Server) writeDeadLetterError(w http.ResponseWriter, action, id string, err error) {
+	switch {
+	case errors.Is(err, ErrDeadLetterNotFound):
+		http.Error(w, "dead letter not found", http.StatusNotFound)
+	case errors.Is(err, ErrPipelineNotRunning):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		s.logger.Printf("Error %s dead letter %s: %v", action, id, err)
+		http.Error(w, "failed to update dead letter", http.StatusInternalServerError)
+	}
+}
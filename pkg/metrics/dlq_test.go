@@ -0,0 +1,152 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// memoryDeadLetters is a DeadLetterManager over an in-memory list
+type memoryDeadLetters struct {
+	entries     []DeadLetter
+	lastFilter  DeadLetterFilter
+	resubmitted []string
+	running     bool
+}
+
+func (m *memoryDeadLetters) DeadLetters(ctx context.Context, filter DeadLetterFilter) ([]DeadLetter, error) {
+	m.lastFilter = filter
+	return m.entries, nil
+}
+
+func (m *memoryDeadLetters) DeleteDeadLetter(ctx context.Context, id string) error {
+	for i, entry := range m.entries {
+		if entry.ID == id {
+			m.entries = append(m.entries[:i], m.entries[i+1:]...)
+			return nil
+		}
+	}
+	return ErrDeadLetterNotFound
+}
+
+func (m *memoryDeadLetters) ResubmitDeadLetter(ctx context.Context, id string) error {
+	if !m.running {
+		return ErrPipelineNotRunning
+	}
+	for _, entry := range m.entries {
+		if entry.ID == id {
+			m.resubmitted = append(m.resubmitted, id)
+			return nil
+		}
+	}
+	return ErrDeadLetterNotFound
+}
+
+// TestDeadLetterEndpoints tests listing, filtering, deleting and resubmitting dead letters
+func TestDeadLetterEndpoints(t *testing.T) {
+	dlq := &memoryDeadLetters{
+		entries: []DeadLetter{{ID: "a", Reason: "too large"}, {ID: "b", Reason: "bad type"}, {ID: "c", Reason: "bad type"}},
+		running: true,
+	}
+	server := NewServer(":0", nil, nil)
+	server.SetStateInspector(&staticState{})
+	if err := server.SetAuth(AuthOptions{BearerToken: "secret"}); err != nil {
+		t.Fatalf("SetAuth() error = %v", err)
+	}
+	server.SetDeadLetterManager(dlq)
+	handler := server.server.Handler
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodGet, "/api/pipelines/orders/dlq?reason=bad&event_id=42&resolved=true&limit=2")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var list DeadLetterList
+	if err := json.NewDecoder(rec.Body).Decode(&list); err != nil {
+		t.Fatalf("invalid list response: %v", err)
+	}
+	if list.Total != 3 || len(list.Entries) != 2 || list.Entries[0].ID != "a" {
+		t.Errorf("expected 2 of 3 entries, got %+v", list)
+	}
+	want := DeadLetterFilter{EventID: "42", Reason: "bad", IncludeResolved: true}
+	if dlq.lastFilter != want {
+		t.Errorf("expected filter %+v, got %+v", want, dlq.lastFilter)
+	}
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		running  bool
+		wantCode int
+	}{
+		{name: "invalid limit", method: http.MethodGet, path: "/api/pipelines/orders/dlq?limit=0", wantCode: http.StatusBadRequest},
+		{name: "invalid resolved", method: http.MethodGet, path: "/api/pipelines/orders/dlq?resolved=maybe", wantCode: http.StatusBadRequest},
+		{name: "unknown pipeline", method: http.MethodGet, path: "/api/pipelines/users/dlq", wantCode: http.StatusNotFound},
+		{name: "resubmit stopped", method: http.MethodPost, path: "/api/pipelines/orders/dlq/b/resubmit", wantCode: http.StatusConflict},
+		{name: "resubmit", method: http.MethodPost, path: "/api/pipelines/orders/dlq/b/resubmit", running: true, wantCode: http.StatusAccepted},
+		{name: "resubmit unknown", method: http.MethodPost, path: "/api/pipelines/orders/dlq/x/resubmit", running: true, wantCode: http.StatusNotFound},
+		{name: "delete", method: http.MethodDelete, path: "/api/pipelines/orders/dlq/a", wantCode: http.StatusNoContent},
+		{name: "delete again", method: http.MethodDelete, path: "/api/pipelines/orders/dlq/a", wantCode: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dlq.running = tt.running
+			if rec := serve(tt.method, tt.path); rec.Code != tt.wantCode {
+				t.Errorf("expected status %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+		})
+	}
+	if len(dlq.entries) != 2 || len(dlq.resubmitted) != 1 || dlq.resubmitted[0] != "b" {
+		t.Errorf("unexpected queue state: entries %+v, resubmitted %v", dlq.entries, dlq.resubmitted)
+	}
+}
+
+// TestDeadLetterEndpointsDisabled tests that the DLQ endpoints are not served without a manager
+func TestDeadLetterEndpointsDisabled(t *testing.T) {
+	server := NewServer(":0", nil, nil)
+	server.SetStateInspector(&staticState{})
+	rec := httptest.NewRecorder()
+	server.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/pipelines/orders/dlq", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
+
+// TestDeadLetterEndpointsReadOnlyWithoutAuth tests that dead letters can only
+// be listed, not deleted or resubmitted, on a server that does not authenticate
+func TestDeadLetterEndpointsReadOnlyWithoutAuth(t *testing.T) {
+	dlq := &memoryDeadLetters{entries: []DeadLetter{{ID: "a", Reason: "bad type"}}, running: true}
+	server := NewServer(":0", nil, nil)
+	server.SetStateInspector(&staticState{})
+	server.SetDeadLetterManager(dlq)
+
+	tests := []struct {
+		method   string
+		path     string
+		wantCode int
+	}{
+		{method: http.MethodGet, path: "/api/pipelines/orders/dlq", wantCode: http.StatusOK},
+		{method: http.MethodDelete, path: "/api/pipelines/orders/dlq/a", wantCode: http.StatusForbidden},
+		{method: http.MethodPost, path: "/api/pipelines/orders/dlq/a/resubmit", wantCode: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		server.server.Handler.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.path, nil))
+		if rec.Code != tt.wantCode {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.wantCode, rec.Code)
+		}
+	}
+	if len(dlq.entries) != 1 || len(dlq.resubmitted) != 0 {
+		t.Errorf("expected the queue to be unchanged, got entries %+v, resubmitted %v", dlq.entries, dlq.resubmitted)
+	}
+}
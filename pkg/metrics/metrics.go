@@ -3,6 +3,8 @@ package metrics
 import (
 	"fmt"
 	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -22,8 +24,27 @@ type Metrics struct {
 	PipelineStatus     prometheus.Gauge
 	SourceConnected    prometheus.Gauge
 	SinkConnected      prometheus.Gauge
+	QueueDepth         *prometheus.GaugeVec
+	MemoryUsed         *prometheus.GaugeVec
+	Retries            *prometheus.CounterVec
+	BreakerState       *prometheus.GaugeVec
+	DLQWrites          *prometheus.CounterVec
+	LastCheckpoint     *prometheus.GaugeVec
+	SyncCopied         *prometheus.GaugeVec
+	SyncEstimated      *prometheus.GaugeVec
+	SyncPercent        *prometheus.GaugeVec
+	SyncETA            *prometheus.GaugeVec
+	EndToEndLatency    *prometheus.HistogramVec
+	BytesRead          *prometheus.CounterVec
+	DocumentsScanned   *prometheus.CounterVec
+	BytesWritten       *prometheus.CounterVec
+	StorageGrowth      *prometheus.GaugeVec
 }
 
+// latencyBuckets span a change captured within milliseconds to one held back
+// for minutes by a slow or failing sink
+var latencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600}
+
 // NewMetrics creates and registers all pipeline metrics
 // Returns an error if metrics for this pipeline name are already registered
 func NewMetrics(pipelineName string) (*Metrics, error) {
@@ -85,6 +106,112 @@ func NewMetrics(pipelineName string) (*Metrics, error) {
 				},
 			},
 		),
+		QueueDepth: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "datapipe_queue_depth",
+				Help: "Number of events waiting in a durable queue (buffer or wal)",
+			},
+			[]string{"pipeline", "queue"},
+		),
+		MemoryUsed: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "datapipe_memory_budget_used_bytes",
+				Help: "Approximate bytes of events held against the memory budget",
+			},
+			[]string{"pipeline"},
+		),
+		Retries: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "datapipe_retries_total",
+				Help: "Total number of retried operations",
+			},
+			[]string{"pipeline", "component"},
+		),
+		BreakerState: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "datapipe_circuit_breaker_state",
+				Help: "Circuit breaker state: 0 closed, 1 open, 2 half-open",
+			},
+			[]string{"pipeline", "component"},
+		),
+		DLQWrites: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "datapipe_dlq_events_total",
+				Help: "Total number of events written to the dead-letter queue",
+			},
+			[]string{"pipeline", "component"},
+		),
+		LastCheckpoint: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "datapipe_last_checkpoint_timestamp_seconds",
+				Help: "Unix time of the last saved checkpoint",
+			},
+			[]string{"pipeline"},
+		),
+		SyncCopied: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "datapipe_initial_sync_documents_copied",
+				Help: "Documents read from the source by the current or last initial sync",
+			},
+			[]string{"pipeline"},
+		),
+		SyncEstimated: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "datapipe_initial_sync_documents_estimated",
+				Help: "Estimated documents the current or last initial sync reads, 0 if unknown",
+			},
+			[]string{"pipeline"},
+		),
+		SyncPercent: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "datapipe_initial_sync_percent_complete",
+				Help: "Percentage of the estimated documents read by the current or last initial sync",
+			},
+			[]string{"pipeline"},
+		),
+		SyncETA: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "datapipe_initial_sync_eta_seconds",
+				Help: "Estimated seconds until the current initial sync completes, 0 if unknown",
+			},
+			[]string{"pipeline"},
+		),
+		EndToEndLatency: promauto.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "datapipe_end_to_end_latency_seconds",
+				Help:    "Time from an event's change at the source to its commit by the sink",
+				Buckets: latencyBuckets,
+			},
+			[]string{"pipeline", "operation"},
+		),
+		BytesRead: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "datapipe_source_bytes_read_total",
+				Help: "Approximate bytes of the changes read from the source",
+			},
+			[]string{"pipeline"},
+		),
+		DocumentsScanned: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "datapipe_source_documents_scanned_total",
+				Help: "Total number of changes and documents read from the source",
+			},
+			[]string{"pipeline"},
+		),
+		BytesWritten: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "datapipe_sink_bytes_written_total",
+				Help: "Approximate bytes of the events committed by the sink",
+			},
+			[]string{"pipeline"},
+		),
+		StorageGrowth: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "datapipe_sink_storage_growth_bytes",
+				Help: "Estimated growth of the data held by the sink since the pipeline started",
+			},
+			[]string{"pipeline"},
+		),
 	}
 
 	metricsRegistry[pipelineName] = true
@@ -132,3 +259,90 @@ func (m *Metrics) SetSinkConnected(connected bool) {
 		m.SinkConnected.Set(0)
 	}
 }
+
+// SetQueueDepth sets the number of events waiting in a queue
+func (m *Metrics) SetQueueDepth(pipelineName, queue string, depth int) {
+	m.QueueDepth.WithLabelValues(pipelineName, queue).Set(float64(depth))
+}
+
+// SetMemoryUsed sets the bytes held against the memory budget
+func (m *Metrics) SetMemoryUsed(pipelineName string, bytes int64) {
+	m.MemoryUsed.WithLabelValues(pipelineName).Set(float64(bytes))
+}
+
+// RecordRetry records a retried operation
+func (m *Metrics) RecordRetry(pipelineName, component string) {
+	m.Retries.WithLabelValues(pipelineName, component).Inc()
+}
+
+// SetCircuitBreakerState sets the circuit breaker state of a component
+func (m *Metrics) SetCircuitBreakerState(pipelineName, component string, state int) {
+	m.BreakerState.WithLabelValues(pipelineName, component).Set(float64(state))
+}
+
+// RecordDLQWrite records an event written to the dead-letter queue
+func (m *Metrics) RecordDLQWrite(pipelineName, component string) {
+	m.DLQWrites.WithLabelValues(pipelineName, component).Inc()
+}
+
+// SetLastCheckpoint records the time of the last saved checkpoint
+func (m *Metrics) SetLastCheckpoint(pipelineName string, t time.Time) {
+	m.LastCheckpoint.WithLabelValues(pipelineName).Set(float64(t.Unix()))
+}
+
+// SetInitialSyncProgress records the documents copied and estimated for an initial sync
+func (m *Metrics) SetInitialSyncProgress(pipelineName string, copied, estimated int64) {
+	m.SyncCopied.WithLabelValues(pipelineName).Set(float64(copied))
+	m.SyncEstimated.WithLabelValues(pipelineName).Set(float64(estimated))
+}
+
+// SetInitialSyncCompletion records the percentage complete and estimated time remaining of an initial sync
+func (m *Metrics) SetInitialSyncCompletion(pipelineName string, percent float64, eta time.Duration) {
+	m.SyncPercent.WithLabelValues(pipelineName).Set(percent)
+	m.SyncETA.WithLabelValues(pipelineName).Set(eta.Seconds())
+}
+
+// ObserveEndToEndLatency records the latency of a committed event. The
+// exemplar labels, such as a trace ID, are attached to the observation unless
+// they exceed the 128 characters exemplars are limited to.
+func (m *Metrics) ObserveEndToEndLatency(pipelineName, operation string, seconds float64, exemplar map[string]string) {
+	observer := m.EndToEndLatency.WithLabelValues(pipelineName, operation)
+	if len(exemplar) > 0 && validExemplar(exemplar) {
+		observer.(prometheus.ExemplarObserver).ObserveWithExemplar(seconds, exemplar)
+		return
+	}
+	observer.Observe(seconds)
+}
+
+// validExemplar reports whether exemplar labels can be recorded; invalid
+// ones make the Prometheus client panic
+func validExemplar(labels map[string]string) bool {
+	runes := 0
+	for name, value := range labels {
+		if name == "" || !utf8.ValidString(value) {
+			return false
+		}
+		runes += utf8.RuneCountInString(name) + utf8.RuneCountInString(value)
+	}
+	return runes <= prometheus.ExemplarMaxRunes
+}
+
+// AddBytesRead records bytes read from the source
+func (m *Metrics) AddBytesRead(pipelineName string, bytes int64) {
+	m.BytesRead.WithLabelValues(pipelineName).Add(float64(bytes))
+}
+
+// AddDocumentsScanned records documents read from the source
+func (m *Metrics) AddDocumentsScanned(pipelineName string, documents int64) {
+	m.DocumentsScanned.WithLabelValues(pipelineName).Add(float64(documents))
+}
+
+// AddBytesWritten records bytes committed by the sink
+func (m *Metrics) AddBytesWritten(pipelineName string, bytes int64) {
+	m.BytesWritten.WithLabelValues(pipelineName).Add(float64(bytes))
+}
+
+// AddStorageGrowth records a change in the estimated size of the sink's data
+func (m *Metrics) AddStorageGrowth(pipelineName string, bytes int64) {
+	m.StorageGrowth.WithLabelValues(pipelineName).Add(float64(bytes))
+}
@@ -1,10 +1,13 @@
 package metrics
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 )
 
 func TestNewMetrics(t *testing.T) {
@@ -235,3 +238,102 @@ func TestRecordProcessingDuration(t *testing.T) {
 		t.Error("Expected durations to be recorded")
 	}
 }
+
+func TestFailurePathMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	oldRegistry := prometheus.DefaultRegisterer
+	prometheus.DefaultRegisterer = reg
+	defer func() {
+		prometheus.DefaultRegisterer = oldRegistry
+		registryMu.Lock()
+		delete(metricsRegistry, "test-pipeline-failures")
+		registryMu.Unlock()
+	}()
+
+	m, err := NewMetrics("test-pipeline-failures")
+	if err != nil {
+		t.Fatalf("Failed to create metrics: %v", err)
+	}
+
+	name := "test-pipeline-failures"
+	m.SetQueueDepth(name, "buffer", 42)
+	m.SetMemoryUsed(name, 1024)
+	m.RecordRetry(name, "sink")
+	m.RecordRetry(name, "sink")
+	m.SetCircuitBreakerState(name, "sink", 1)
+	m.RecordDLQWrite(name, "pipeline")
+	m.SetLastCheckpoint(name, time.Unix(1700000000, 0))
+	m.SetInitialSyncProgress(name, 500, 2000)
+	m.SetInitialSyncCompletion(name, 25, 90*time.Second)
+
+	tests := []struct {
+		metric prometheus.Collector
+		want   float64
+	}{
+		{m.QueueDepth.WithLabelValues(name, "buffer"), 42},
+		{m.MemoryUsed.WithLabelValues(name), 1024},
+		{m.Retries.WithLabelValues(name, "sink"), 2},
+		{m.BreakerState.WithLabelValues(name, "sink"), 1},
+		{m.DLQWrites.WithLabelValues(name, "pipeline"), 1},
+		{m.LastCheckpoint.WithLabelValues(name), 1700000000},
+		{m.SyncCopied.WithLabelValues(name), 500},
+		{m.SyncEstimated.WithLabelValues(name), 2000},
+		{m.SyncPercent.WithLabelValues(name), 25},
+		{m.SyncETA.WithLabelValues(name), 90},
+	}
+	for i, tt := range tests {
+		if got := testutil.ToFloat64(tt.metric); got != tt.want {
+			t.Errorf("metric %d = %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+// TestObserveEndToEndLatency tests that latencies are observed with their exemplars
+func TestObserveEndToEndLatency(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	oldRegistry := prometheus.DefaultRegisterer
+	prometheus.DefaultRegisterer = reg
+	defer func() {
+		prometheus.DefaultRegisterer = oldRegistry
+		registryMu.Lock()
+		delete(metricsRegistry, "test-pipeline-latency")
+		registryMu.Unlock()
+	}()
+
+	m, err := NewMetrics("test-pipeline-latency")
+	if err != nil {
+		t.Fatalf("Failed to create metrics: %v", err)
+	}
+
+	name := "test-pipeline-latency"
+	m.ObserveEndToEndLatency(name, "insert", 0.2, map[string]string{"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736"})
+	m.ObserveEndToEndLatency(name, "insert", 3, nil)
+	// Too long for an exemplar, observed without one
+	m.ObserveEndToEndLatency(name, "insert", 3, map[string]string{"event_id": strings.Repeat("x", 200)})
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	var histogram *dto.Histogram
+	for _, family := range families {
+		if family.GetName() == "datapipe_end_to_end_latency_seconds" {
+			histogram = family.GetMetric()[0].GetHistogram()
+		}
+	}
+	if histogram == nil {
+		t.Fatal("expected datapipe_end_to_end_latency_seconds to be registered")
+	}
+	if histogram.GetSampleCount() != 3 {
+		t.Errorf("expected 3 observations, got %d", histogram.GetSampleCount())
+	}
+	var exemplars []*dto.Exemplar
+	for _, bucket := range histogram.GetBucket() {
+		if bucket.GetExemplar() != nil {
+			exemplars = append(exemplars, bucket.GetExemplar())
+		}
+	}
+	if len(exemplars) != 1 || exemplars[0].GetValue() != 0.2 || exemplars[0].GetLabel()[0].GetValue() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("expected one exemplar with the trace ID, got %v", exemplars)
+	}
+}
@@ -0,0 +1,230 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// PushOptions configures pushing the final metrics of a run, for short-lived
+// jobs that exit before Prometheus scrapes them
+type PushOptions struct {
+	PushGateway  string            // Pushgateway URL, e.g. http://pushgateway:9091
+	Job          string            // Pushgateway job name (default: data-pipe)
+	OTLPEndpoint string            // OTLP/HTTP metrics URL, e.g. http://collector:4318/v1/metrics
+	OTLPHeaders  map[string]string // Extra OTLP request headers, e.g. for authentication
+}
+
+// Pusher pushes gathered metrics to a Pushgateway and/or an OTLP endpoint
+type Pusher struct {
+	pipeline string
+	opts     PushOptions
+	gatherer prometheus.Gatherer
+	client   *http.Client
+	logger   *log.Logger
+}
+
+// NewPusher creates a pusher for the metrics in the default Prometheus registry
+func NewPusher(pipelineName string, opts PushOptions, logger *log.Logger) *Pusher {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if opts.Job == "" {
+		opts.Job = "data-pipe"
+	}
+	return &Pusher{
+		pipeline: pipelineName,
+		opts:     opts,
+		gatherer: prometheus.DefaultGatherer,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		logger:   logger,
+	}
+}
+
+// Enabled reports whether any push target is configured
+func (p *Pusher) Enabled() bool {
+	return p.opts.PushGateway != "" || p.opts.OTLPEndpoint != ""
+}
+
+// Push sends the current metrics to every configured target
+func (p *Pusher) Push(ctx context.Context) error {
+	if p.opts.PushGateway != "" {
+		err := push.New(p.opts.PushGateway, p.opts.Job).
+			Gatherer(p.gatherer).
+			Grouping("pipeline", p.pipeline).
+			Client(p.client).
+			PushContext(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to push metrics to pushgateway: %w", err)
+		}
+		p.logger.Printf("Pushed metrics to %s", p.opts.PushGateway)
+	}
+
+	if p.opts.OTLPEndpoint != "" {
+		if err := p.pushOTLP(ctx); err != nil {
+			return fmt.Errorf("failed to push metrics to OTLP endpoint: %w", err)
+		}
+		p.logger.Printf("Pushed metrics to %s", p.opts.OTLPEndpoint)
+	}
+	return nil
+}
+
+// pushOTLP posts the gathered metrics as an OTLP/HTTP JSON export request
+func (p *Pusher) pushOTLP(ctx context.Context) error {
+	families, err := p.gatherer.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	body, err := json.Marshal(otlpRequest(p.pipeline, families, time.Now()))
+	if err != nil {
+		return fmt.Errorf("failed to encode metrics: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.opts.OTLPEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range p.opts.OTLPHeaders {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// OTLP JSON encoding (opentelemetry-proto ExportMetricsServiceRequest).
+// 64-bit integers are encoded as strings, as the protobuf JSON mapping requires.
+type (
+	otlpKeyValue struct {
+		Key   string            `json:"key"`
+		Value map[string]string `json:"value"`
+	}
+	otlpNumberPoint struct {
+		Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+		TimeUnixNano string         `json:"timeUnixNano"`
+		AsDouble     float64        `json:"asDouble"`
+	}
+	otlpHistogramPoint struct {
+		Attributes     []otlpKeyValue `json:"attributes,omitempty"`
+		TimeUnixNano   string         `json:"timeUnixNano"`
+		Count          string         `json:"count"`
+		Sum            float64        `json:"sum"`
+		BucketCounts   []string       `json:"bucketCounts"`
+		ExplicitBounds []float64      `json:"explicitBounds"`
+	}
+	otlpMetric struct {
+		Name        string                 `json:"name"`
+		Description string                 `json:"description,omitempty"`
+		Sum         map[string]interface{} `json:"sum,omitempty"`
+		Gauge       map[string]interface{} `json:"gauge,omitempty"`
+		Histogram   map[string]interface{} `json:"histogram,omitempty"`
+	}
+)
+
+// otlpCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE
+const otlpCumulative = 2
+
+// otlpRequest converts Prometheus metric families into an OTLP export request
+func otlpRequest(pipelineName string, families []*dto.MetricFamily, now time.Time) map[string]interface{} {
+	ts := strconv.FormatInt(now.UnixNano(), 10)
+	metrics := make([]otlpMetric, 0, len(families))
+
+	for _, family := range families {
+		m := otlpMetric{Name: family.GetName(), Description: family.GetHelp()}
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			points := make([]otlpNumberPoint, 0, len(family.Metric))
+			for _, metric := range family.Metric {
+				points = append(points, otlpNumberPoint{otlpAttributes(metric), ts, metric.GetCounter().GetValue()})
+			}
+			m.Sum = map[string]interface{}{"dataPoints": points, "aggregationTemporality": otlpCumulative, "isMonotonic": true}
+		case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+			points := make([]otlpNumberPoint, 0, len(family.Metric))
+			for _, metric := range family.Metric {
+				value := metric.GetGauge().GetValue()
+				if family.GetType() == dto.MetricType_UNTYPED {
+					value = metric.GetUntyped().GetValue()
+				}
+				points = append(points, otlpNumberPoint{otlpAttributes(metric), ts, value})
+			}
+			m.Gauge = map[string]interface{}{"dataPoints": points}
+		case dto.MetricType_HISTOGRAM:
+			points := make([]otlpHistogramPoint, 0, len(family.Metric))
+			for _, metric := range family.Metric {
+				points = append(points, otlpHistogram(metric, ts))
+			}
+			m.Histogram = map[string]interface{}{"dataPoints": points, "aggregationTemporality": otlpCumulative}
+		default:
+			// Summaries have no direct OTLP equivalent
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+
+	return map[string]interface{}{
+		"resourceMetrics": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": []otlpKeyValue{
+						{Key: "service.name", Value: map[string]string{"stringValue": "data-pipe"}},
+						{Key: "pipeline", Value: map[string]string{"stringValue": pipelineName}},
+					},
+				},
+				"scopeMetrics": []interface{}{
+					map[string]interface{}{
+						"scope":   map[string]string{"name": "github.com/IEatCodeDaily/data-pipe"},
+						"metrics": metrics,
+					},
+				},
+			},
+		},
+	}
+}
+
+// otlpHistogram converts cumulative Prometheus buckets into OTLP per-bucket counts
+func otlpHistogram(metric *dto.Metric, ts string) otlpHistogramPoint {
+	h := metric.GetHistogram()
+	point := otlpHistogramPoint{
+		Attributes:   otlpAttributes(metric),
+		TimeUnixNano: ts,
+		Count:        strconv.FormatUint(h.GetSampleCount(), 10),
+		Sum:          h.GetSampleSum(),
+	}
+
+	var previous uint64
+	for _, bucket := range h.GetBucket() {
+		point.ExplicitBounds = append(point.ExplicitBounds, bucket.GetUpperBound())
+		point.BucketCounts = append(point.BucketCounts, strconv.FormatUint(bucket.GetCumulativeCount()-previous, 10))
+		previous = bucket.GetCumulativeCount()
+	}
+	// The final OTLP bucket holds observations above the last bound
+	point.BucketCounts = append(point.BucketCounts, strconv.FormatUint(h.GetSampleCount()-previous, 10))
+	return point
+}
+
+// otlpAttributes converts Prometheus labels into OTLP attributes
+func otlpAttributes(metric *dto.Metric) []otlpKeyValue {
+	attrs := make([]otlpKeyValue, 0, len(metric.Label))
+	for _, label := range metric.Label {
+		attrs = append(attrs, otlpKeyValue{Key: label.GetName(), Value: map[string]string{"stringValue": label.GetValue()}})
+	}
+	return attrs
+}
@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// testGatherer returns a registry with one counter, gauge and histogram
+func testGatherer(t *testing.T) prometheus.Gatherer {
+	t.Helper()
+	reg := prometheus.NewRegistry()
+	counter := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "datapipe_events_processed_total", Help: "events"}, []string{"operation"})
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: "datapipe_pipeline_status", Help: "status"})
+	histogram := prometheus.NewHistogram(prometheus.HistogramOpts{Name: "datapipe_duration_seconds", Help: "duration", Buckets: []float64{0.1, 1}})
+	reg.MustRegister(counter, gauge, histogram)
+
+	counter.WithLabelValues("insert").Add(3)
+	gauge.Set(1)
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(5)
+	return reg
+}
+
+func TestPushOTLP(t *testing.T) {
+	var body map[string]interface{}
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("invalid OTLP body: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	pusher := NewPusher("orders", PushOptions{
+		OTLPEndpoint: server.URL + "/v1/metrics",
+		OTLPHeaders:  map[string]string{"Authorization": "Bearer secret"},
+	}, nil)
+	pusher.gatherer = testGatherer(t)
+	if err := pusher.Push(context.Background()); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+
+	if auth != "Bearer secret" {
+		t.Errorf("expected auth header to be forwarded, got %q", auth)
+	}
+	encoded, _ := json.Marshal(body)
+	for _, want := range []string{
+		`"datapipe_events_processed_total"`,
+		`"isMonotonic":true`,
+		`"asDouble":3`,
+		`"bucketCounts":["1","1","1"]`,
+		`"explicitBounds":[0.1,1]`,
+		`"stringValue":"orders"`,
+	} {
+		if !strings.Contains(string(encoded), want) {
+			t.Errorf("expected OTLP request to contain %s, got %s", want, encoded)
+		}
+	}
+}
+
+func TestPushGateway(t *testing.T) {
+	var path, payload string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		b, _ := io.ReadAll(r.Body)
+		payload = string(b)
+	}))
+	defer server.Close()
+
+	pusher := NewPusher("orders", PushOptions{PushGateway: server.URL}, nil)
+	pusher.gatherer = testGatherer(t)
+	if err := pusher.Push(context.Background()); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+
+	if path != "/metrics/job/data-pipe/pipeline/orders" {
+		t.Errorf("unexpected push path %q", path)
+	}
+	if !strings.Contains(payload, "datapipe_events_processed_total") {
+		t.Error("expected pushed payload to contain pipeline metrics")
+	}
+}
+
+func TestPushOTLPError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	pusher := NewPusher("orders", PushOptions{OTLPEndpoint: server.URL}, nil)
+	pusher.gatherer = testGatherer(t)
+	if err := pusher.Push(context.Background()); err == nil {
+		t.Error("expected an error for a rejected push")
+	}
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// Backend selects where pipeline metrics are sent
+type Backend string
+
+const (
+	// BackendPrometheus exposes metrics on the /metrics endpoint (default)
+	BackendPrometheus Backend = "prometheus"
+	// BackendStatsD sends metrics to a StatsD server, encoding labels in metric names
+	BackendStatsD Backend = "statsd"
+	// BackendDogStatsD sends metrics to a DogStatsD agent, encoding labels as tags
+	BackendDogStatsD Backend = "dogstatsd"
+)
+
+// Recorder records pipeline metrics to a backend
+type Recorder interface {
+	RecordEventProcessed(pipelineName, operation string)
+	RecordEventError(pipelineName, component, errorType string)
+	RecordProcessingDuration(pipelineName, component string, duration float64)
+	SetPipelineRunning(running bool)
+	SetSourceConnected(connected bool)
+	SetSinkConnected(connected bool)
+	SetQueueDepth(pipelineName, queue string, depth int)
+	SetMemoryUsed(pipelineName string, bytes int64)
+	RecordRetry(pipelineName, component string)
+	SetCircuitBreakerState(pipelineName, component string, state int)
+	RecordDLQWrite(pipelineName, component string)
+	SetLastCheckpoint(pipelineName string, t time.Time)
+	SetInitialSyncProgress(pipelineName string, copied, estimated int64)
+	SetInitialSyncCompletion(pipelineName string, percent float64, eta time.Duration)
+	ObserveEndToEndLatency(pipelineName, operation string, seconds float64, exemplar map[string]string)
+	AddBytesRead(pipelineName string, bytes int64)
+	AddDocumentsScanned(pipelineName string, documents int64)
+	AddBytesWritten(pipelineName string, bytes int64)
+	AddStorageGrowth(pipelineName string, bytes int64)
+	// Close flushes and releases the backend
+	Close() error
+}
+
+// Options configures the metrics backend
+type Options struct {
+	Backend Backend  // prometheus (default), statsd or dogstatsd
+	Address string   // StatsD server address (default: 127.0.0.1:8125)
+	Prefix  string   // StatsD metric name prefix (default: datapipe)
+	Tags    []string // Extra DogStatsD tags, e.g. "env:prod"
+}
+
+// NewRecorder creates a metrics recorder for the configured backend
+func NewRecorder(pipelineName string, opts Options, logger *log.Logger) (Recorder, error) {
+	switch opts.Backend {
+	case "", BackendPrometheus:
+		return NewMetrics(pipelineName)
+	case BackendStatsD, BackendDogStatsD:
+		return NewStatsD(pipelineName, opts, logger)
+	default:
+		return nil, fmt.Errorf("unsupported metrics backend: %s", opts.Backend)
+	}
+}
+
+// Close is a no-op for Prometheus metrics, which are scraped
+func (m *Metrics) Close() error {
+	return nil
+}
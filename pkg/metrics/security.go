@@ -0,0 +1,123 @@
+package metrics
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// TLSOptions configures HTTPS for the server
+type TLSOptions struct {
+	CertFile     string // PEM server certificate
+	KeyFile      string // PEM server private key
+	ClientCAFile string // PEM CA bundle; when set, clients must present a certificate it signed (mTLS)
+}
+
+// AuthOptions configures request authentication; set either a bearer token or a username and password
+type AuthOptions struct {
+	BearerToken  string
+	Username     string
+	Password     string
+	PublicProbes bool // serve /health and /ready without authentication, for orchestrator probes
+}
+
+// SetTLS makes the server listen with HTTPS, optionally requiring client certificates
+func (s *Server) SetTLS(opts TLSOptions) error {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return fmt.Errorf("TLS requires a certificate and a key file")
+	}
+	cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if opts.ClientCAFile != "" {
+		pem, err := os.ReadFile(opts.ClientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in client CA file %s", opts.ClientCAFile)
+		}
+		cfg.ClientCAs = pool
+		// Certificates are verified during the handshake; requiring one is left to
+		// authorize so that public probes can connect without a certificate
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+		s.requireClientCert = true
+	}
+
+	s.server.TLSConfig = cfg
+	return nil
+}
+
+// SetAuth requires a bearer token or basic auth credentials on every request
+func (s *Server) SetAuth(opts AuthOptions) error {
+	hasToken := opts.BearerToken != ""
+	hasBasic := opts.Username != "" || opts.Password != ""
+	if hasToken && hasBasic {
+		return fmt.Errorf("configure either a bearer token or basic auth, not both")
+	}
+	if hasBasic && (opts.Username == "" || opts.Password == "") {
+		return fmt.Errorf("basic auth requires both a username and a password")
+	}
+	s.auth = opts
+	return nil
+}
+
+// authorize wraps a handler with the configured client certificate and credential checks
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.auth.PublicProbes && (r.URL.Path == "/health" || r.URL.Path == "/ready") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if s.requireClientCert && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+			http.Error(w, "client certificate required", http.StatusUnauthorized)
+			return
+		}
+		if !s.authenticated(r) {
+			if s.auth.BearerToken != "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="data-pipe"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", `Basic realm="data-pipe"`)
+			}
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticated reports whether the request carries the configured credentials
+func (s *Server) authenticated(r *http.Request) bool {
+	switch {
+	case s.auth.BearerToken != "":
+		return secureEqual(r.Header.Get("Authorization"), "Bearer "+s.auth.BearerToken)
+	case s.auth.Username != "":
+		user, password, ok := r.BasicAuth()
+		// Evaluate both comparisons to avoid leaking which one failed through timing
+		userOK := secureEqual(user, s.auth.Username)
+		passwordOK := secureEqual(password, s.auth.Password)
+		return ok && userOK && passwordOK
+	default:
+		return true
+	}
+}
+
+// authenticates reports whether the server requires credentials or a client certificate
+func (s *Server) authenticates() bool {
+	return s.auth.BearerToken != "" || s.auth.Username != "" || s.requireClientCert
+}
+
+// secureEqual compares two strings in constant time
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
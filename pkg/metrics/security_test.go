@@ -0,0 +1,185 @@
+package metrics
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestServerAuth tests bearer token and basic auth, with and without public probes
+func TestServerAuth(t *testing.T) {
+	tests := []struct {
+		name     string
+		auth     AuthOptions
+		path     string
+		setup    func(r *http.Request)
+		wantCode int
+	}{
+		{name: "no auth configured", path: "/api/pipelines/x/runs", wantCode: http.StatusNotFound},
+		{name: "missing token", auth: AuthOptions{BearerToken: "s3cret"}, path: "/metrics", wantCode: http.StatusUnauthorized},
+		{name: "wrong token", auth: AuthOptions{BearerToken: "s3cret"}, path: "/metrics", setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer nope") }, wantCode: http.StatusUnauthorized},
+		{name: "valid token", auth: AuthOptions{BearerToken: "s3cret"}, path: "/metrics", setup: func(r *http.Request) { r.Header.Set("Authorization", "Bearer s3cret") }, wantCode: http.StatusOK},
+		{name: "valid basic", auth: AuthOptions{Username: "ops", Password: "pw"}, path: "/metrics", setup: func(r *http.Request) { r.SetBasicAuth("ops", "pw") }, wantCode: http.StatusOK},
+		{name: "wrong basic", auth: AuthOptions{Username: "ops", Password: "pw"}, path: "/metrics", setup: func(r *http.Request) { r.SetBasicAuth("ops", "x") }, wantCode: http.StatusUnauthorized},
+		{name: "protected probe", auth: AuthOptions{BearerToken: "s3cret"}, path: "/ready", wantCode: http.StatusUnauthorized},
+		{name: "public probe", auth: AuthOptions{BearerToken: "s3cret", PublicProbes: true}, path: "/ready", wantCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(":0", nil, nil)
+			if err := server.SetAuth(tt.auth); err != nil {
+				t.Fatalf("SetAuth() error = %v", err)
+			}
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.setup != nil {
+				tt.setup(req)
+			}
+			rec := httptest.NewRecorder()
+			server.server.Handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Errorf("expected status %d, got %d", tt.wantCode, rec.Code)
+			}
+		})
+	}
+}
+
+// TestSetAuthValidation tests rejected auth combinations
+func TestSetAuthValidation(t *testing.T) {
+	server := NewServer(":0", nil, nil)
+	if err := server.SetAuth(AuthOptions{BearerToken: "t", Username: "u", Password: "p"}); err == nil {
+		t.Error("expected an error for token and basic auth together")
+	}
+	if err := server.SetAuth(AuthOptions{Username: "u"}); err == nil {
+		t.Error("expected an error for basic auth without a password")
+	}
+}
+
+// TestServerMutualTLS tests that client certificates are required except for public probes
+func TestServerMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	caCert, caKey := newCertificate(t, nil, nil, true)
+	serverCert, serverKey := newCertificate(t, caCert, caKey, false)
+	clientCert, clientKey := newCertificate(t, caCert, caKey, false)
+	writePEM(t, filepath.Join(dir, "ca.pem"), "CERTIFICATE", caCert.Raw)
+	writePEM(t, filepath.Join(dir, "server.pem"), "CERTIFICATE", serverCert.Raw)
+	writeKey(t, filepath.Join(dir, "server-key.pem"), serverKey)
+
+	server := NewServer(":0", &staticHealth{}, nil)
+	if err := server.SetTLS(TLSOptions{
+		CertFile:     filepath.Join(dir, "server.pem"),
+		KeyFile:      filepath.Join(dir, "server-key.pem"),
+		ClientCAFile: filepath.Join(dir, "ca.pem"),
+	}); err != nil {
+		t.Fatalf("SetTLS() error = %v", err)
+	}
+	if err := server.SetAuth(AuthOptions{PublicProbes: true}); err != nil {
+		t.Fatalf("SetAuth() error = %v", err)
+	}
+
+	ts := httptest.NewUnstartedServer(server.server.Handler)
+	ts.TLS = server.server.TLSConfig
+	ts.StartTLS()
+	defer ts.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+	anonymous := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}}
+	withCert := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{{Certificate: [][]byte{clientCert.Raw}, PrivateKey: clientKey}},
+	}}}
+
+	tests := []struct {
+		name     string
+		client   *http.Client
+		path     string
+		wantCode int
+	}{
+		{name: "metrics without certificate", client: anonymous, path: "/metrics", wantCode: http.StatusUnauthorized},
+		{name: "metrics with certificate", client: withCert, path: "/metrics", wantCode: http.StatusOK},
+		{name: "public probe without certificate", client: anonymous, path: "/health", wantCode: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := tt.client.Get(ts.URL + tt.path)
+			if err != nil {
+				t.Fatalf("request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantCode {
+				t.Errorf("expected status %d, got %d", tt.wantCode, resp.StatusCode)
+			}
+		})
+	}
+}
+
+// staticHealth is a HealthChecker that always reports healthy
+type staticHealth struct{}
+
+func (staticHealth) IsHealthy() bool { return true }
+func (staticHealth) GetStatus() HealthStatus {
+	return HealthStatus{Healthy: true, SourceConnected: true, SinkConnected: true}
+}
+
+// newCertificate creates a CA certificate (parent nil) or a certificate signed by parent,
+// valid for localhost and usable by both servers and clients
+func newCertificate(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, isCA bool) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "data-pipe-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	return cert, key
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func writeKey(t *testing.T, path string, key *ecdsa.PrivateKey) {
+	t.Helper()
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to encode key: %v", err)
+	}
+	writePEM(t, path, "EC PRIVATE KEY", der)
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -16,6 +17,13 @@ type Server struct {
 	server *http.Server
 	logger *log.Logger
 	health HealthChecker
+	state  StateInspector
+	debug  DebugInspector
+	dlq    DeadLetterManager
+
+	auth              AuthOptions
+	requireClientCert bool
+	dlqWritable       bool // dead letters may be deleted and resubmitted
 }
 
 // HealthChecker interface for checking pipeline health
@@ -26,12 +34,27 @@ type HealthChecker interface {
 
 // HealthStatus represents the health status of the pipeline
 type HealthStatus struct {
-	Healthy          bool   `json:"healthy"`
-	PipelineRunning  bool   `json:"pipeline_running"`
-	SourceConnected  bool   `json:"source_connected"`
-	SinkConnected    bool   `json:"sink_connected"`
-	LastEventTime    string `json:"last_event_time,omitempty"`
-	UptimeSeconds    int64  `json:"uptime_seconds"`
+	Healthy          bool               `json:"healthy"`
+	PipelineRunning  bool               `json:"pipeline_running"`
+	SourceConnected  bool               `json:"source_connected"`
+	SinkConnected    bool               `json:"sink_connected"`
+	LastEventTime    string             `json:"last_event_time,omitempty"`
+	UptimeSeconds    int64              `json:"uptime_seconds"`
+	CircuitBreaker   string             `json:"circuit_breaker,omitempty"`
+	SourceStalled    bool               `json:"source_stalled,omitempty"`
+	InitialSync      *InitialSyncStatus `json:"initial_sync,omitempty"`
+}
+
+// InitialSyncStatus represents the progress of the current or last initial sync
+type InitialSyncStatus struct {
+	Active          bool    `json:"active"`
+	StartedAt       string  `json:"started_at"`
+	DocumentsCopied int64   `json:"documents_copied"`
+	EstimatedTotal  int64   `json:"estimated_total,omitempty"`
+	EstimatedBytes  int64   `json:"estimated_bytes,omitempty"`
+	PercentComplete float64 `json:"percent_complete,omitempty"`
+	ETASeconds      int64   `json:"eta_seconds,omitempty"`
+	CurrentID       string  `json:"current_id,omitempty"`
 }
 
 // NewServer creates a new metrics HTTP server
@@ -45,7 +68,6 @@ func NewServer(addr string, health HealthChecker, logger *log.Logger) *Server {
 	s := &Server{
 		server: &http.Server{
 			Addr:         addr,
-			Handler:      mux,
 			ReadTimeout:  10 * time.Second,
 			WriteTimeout: 10 * time.Second,
 		},
@@ -54,10 +76,17 @@ func NewServer(addr string, health HealthChecker, logger *log.Logger) *Server {
 	}
 
 	// Register handlers
-	mux.Handle("/metrics", promhttp.Handler())
+	// OpenMetrics is offered to scrapers asking for it, as it carries exemplars
+	mux.Handle("/metrics", promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true})))
 	mux.HandleFunc("/health", s.healthHandler)
 	mux.HandleFunc("/ready", s.readinessHandler)
+	mux.HandleFunc("GET /api/pipelines/{name}/checkpoints", s.checkpointsHandler)
+	mux.HandleFunc("GET /api/pipelines/{name}/runs", s.runsHandler)
+	s.registerDeadLetterHandlers(mux)
+	s.registerDebugHandlers(mux)
 	mux.HandleFunc("/", s.rootHandler)
+	s.server.Handler = s.authorize(mux)
 
 	return s
 }
@@ -76,7 +105,13 @@ func (s *Server) Start() error {
 	errChan := make(chan error, 1)
 	
 	go func() {
-		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if s.server.TLSConfig != nil {
+			err = s.server.ListenAndServeTLS("", "")
+		} else {
+			err = s.server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			errChan <- err
 		}
 	}()
@@ -157,6 +192,9 @@ func (s *Server) rootHandler(w http.ResponseWriter, r *http.Request) {
         <li><a href="/metrics">Metrics (Prometheus format)</a></li>
         <li><a href="/health">Health Check (JSON)</a></li>
         <li><a href="/ready">Readiness Probe</a></li>
+        <li>/api/pipelines/{name}/checkpoints - Resume position and last snapshot (JSON)</li>
+        <li>/api/pipelines/{name}/runs - Recent run summaries (JSON)</li>
+        <li>/debug/pipeline, /debug/pprof/ - Internal state and profiles (when pipeline.debug is enabled)</li>
     </ul>
 </body>
 </html>
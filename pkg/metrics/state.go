@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// defaultRunsLimit is the number of runs returned when no limit is requested
+const defaultRunsLimit = 10
+
+// StateInspector exposes a pipeline's durable state to the inspection API
+type StateInspector interface {
+	// PipelineName returns the name the pipeline is served under
+	PipelineName() string
+	// Checkpoint returns the current resume position and last snapshot
+	Checkpoint(ctx context.Context) (CheckpointState, error)
+	// Runs returns up to limit recent runs, newest first
+	Runs(ctx context.Context, limit int) ([]RunSummary, error)
+}
+
+// CheckpointState describes where a pipeline will resume from
+type CheckpointState struct {
+	Pipeline  string         `json:"pipeline"`
+	Position  string         `json:"position,omitempty"` // source resume token
+	UpdatedAt string         `json:"updated_at,omitempty"`
+	Snapshot  *SnapshotState `json:"snapshot,omitempty"`
+}
+
+// SnapshotState describes a pipeline's last initial sync
+type SnapshotState struct {
+	Status      string `json:"status"`
+	StartedAt   string `json:"started_at"`
+	CompletedAt string `json:"completed_at"`
+	Documents   int64  `json:"documents"`
+	Errors      int64  `json:"errors"`
+	Error       string `json:"error,omitempty"`
+}
+
+// RunSummary describes one run of a pipeline
+type RunSummary struct {
+	ID              string `json:"id"`
+	Status          string `json:"status"`
+	StartedAt       string `json:"started_at"`
+	EndedAt         string `json:"ended_at,omitempty"`
+	StartPosition   string `json:"start_position,omitempty"`
+	EventsProcessed int64  `json:"events_processed"`
+	EventsRejected  int64  `json:"events_rejected"`
+	Error           string `json:"error,omitempty"`
+}
+
+// SetStateInspector enables the /api/pipelines endpoints for a pipeline
+func (s *Server) SetStateInspector(state StateInspector) {
+	s.state = state
+}
+
+// checkpointsHandler serves the current checkpoint and snapshot of a pipeline
+func (s *Server) checkpointsHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.servesPipeline(w, r) {
+		return
+	}
+	state, err := s.state.Checkpoint(r.Context())
+	if err != nil {
+		s.logger.Printf("Error loading checkpoint: %v", err)
+		http.Error(w, "failed to load checkpoint", http.StatusInternalServerError)
+		return
+	}
+	s.writeJSON(w, state)
+}
+
+// runsHandler serves recent run summaries of a pipeline; ?limit=N bounds the count
+func (s *Server) runsHandler(w http.ResponseWriter, r *http.Request) {
+	if !s.servesPipeline(w, r) {
+		return
+	}
+	limit := defaultRunsLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	runs, err := s.state.Runs(r.Context(), limit)
+	if err != nil {
+		s.logger.Printf("Error loading runs: %v", err)
+		http.Error(w, "failed to load runs", http.StatusInternalServerError)
+		return
+	}
+	if runs == nil {
+		runs = []RunSummary{}
+	}
+	s.writeJSON(w, runs)
+}
+
+// servesPipeline reports whether the request names the inspected pipeline, writing a 404 if not
+func (s *Server) servesPipeline(w http.ResponseWriter, r *http.Request) bool {
+	if s.state == nil || r.PathValue("name") != s.state.PipelineName() {
+		http.Error(w, "pipeline not found", http.StatusNotFound)
+		return false
+	}
+	return true
+}
+
+// writeJSON encodes a response body as JSON
+func (s *Server) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		s.logger.Printf("Error encoding response: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// staticState is a StateInspector serving fixed data
+type staticState struct {
+	runs      []RunSummary
+	lastLimit int
+}
+
+func (s *staticState) PipelineName() string { return "orders" }
+
+func (s *staticState) Checkpoint(ctx context.Context) (CheckpointState, error) {
+	return CheckpointState{
+		Pipeline: "orders",
+		Position: "token-1",
+		Snapshot: &SnapshotState{Status: "completed", Documents: 42},
+	}, nil
+}
+
+func (s *staticState) Runs(ctx context.Context, limit int) ([]RunSummary, error) {
+	s.lastLimit = limit
+	return s.runs, nil
+}
+
+// TestStateEndpoints tests the checkpoint and run inspection endpoints
+func TestStateEndpoints(t *testing.T) {
+	state := &staticState{runs: []RunSummary{{ID: "run-2", Status: "running"}, {ID: "run-1", Status: "completed"}}}
+	server := NewServer(":0", nil, nil)
+	server.SetStateInspector(state)
+	handler := server.server.Handler
+
+	tests := []struct {
+		name      string
+		path      string
+		wantCode  int
+		wantLimit int
+	}{
+		{name: "checkpoints", path: "/api/pipelines/orders/checkpoints", wantCode: http.StatusOK},
+		{name: "runs default limit", path: "/api/pipelines/orders/runs", wantCode: http.StatusOK, wantLimit: defaultRunsLimit},
+		{name: "runs with limit", path: "/api/pipelines/orders/runs?limit=1", wantCode: http.StatusOK, wantLimit: 1},
+		{name: "invalid limit", path: "/api/pipelines/orders/runs?limit=x", wantCode: http.StatusBadRequest},
+		{name: "unknown pipeline", path: "/api/pipelines/users/runs", wantCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state.lastLimit = 0
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			if rec.Code != tt.wantCode {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantCode, rec.Code, rec.Body.String())
+			}
+			if state.lastLimit != tt.wantLimit {
+				t.Errorf("expected limit %d, got %d", tt.wantLimit, state.lastLimit)
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/pipelines/orders/checkpoints", nil))
+	var cp CheckpointState
+	if err := json.NewDecoder(rec.Body).Decode(&cp); err != nil {
+		t.Fatalf("invalid checkpoint response: %v", err)
+	}
+	if cp.Position != "token-1" || cp.Snapshot == nil || cp.Snapshot.Documents != 42 {
+		t.Errorf("unexpected checkpoint response %+v", cp)
+	}
+}
+
+// TestStateEndpointsDisabled tests that the API is not served without an inspector
+func TestStateEndpointsDisabled(t *testing.T) {
+	server := NewServer(":0", nil, nil)
+	rec := httptest.NewRecorder()
+	server.server.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/pipelines/orders/runs", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,222 @@
+package metrics
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StatsD sends pipeline metrics to a StatsD or DogStatsD server over UDP.
+// Metric names mirror the Prometheus ones without the datapipe_ prefix.
+type StatsD struct {
+	pipeline  string
+	prefix    string
+	tags      []string
+	dogstatsd bool
+	conn      net.Conn
+	errOnce   sync.Once
+	logger    *log.Logger
+}
+
+// NewStatsD creates a StatsD metrics recorder
+func NewStatsD(pipelineName string, opts Options, logger *log.Logger) (*StatsD, error) {
+	if logger == nil {
+		logger = log.Default()
+	}
+	address := opts.Address
+	if address == "" {
+		address = "127.0.0.1:8125"
+	}
+	prefix := opts.Prefix
+	if prefix == "" {
+		prefix = "datapipe"
+	}
+
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to statsd: %w", err)
+	}
+
+	return &StatsD{
+		pipeline:  pipelineName,
+		prefix:    prefix,
+		tags:      opts.Tags,
+		dogstatsd: opts.Backend == BackendDogStatsD,
+		conn:      conn,
+		logger:    logger,
+	}, nil
+}
+
+// RecordEventProcessed records a successfully processed event
+func (s *StatsD) RecordEventProcessed(pipelineName, operation string) {
+	s.send("events_processed", "1|c", "pipeline", pipelineName, "operation", operation)
+}
+
+// RecordEventError records an event processing error
+func (s *StatsD) RecordEventError(pipelineName, component, errorType string) {
+	s.send("events_errored", "1|c", "pipeline", pipelineName, "component", component, "error_type", errorType)
+}
+
+// RecordProcessingDuration records the duration of event processing as a timer
+func (s *StatsD) RecordProcessingDuration(pipelineName, component string, duration float64) {
+	ms := strconv.FormatFloat(duration*1000, 'f', 3, 64)
+	s.send("event_processing_duration", ms+"|ms", "pipeline", pipelineName, "component", component)
+}
+
+// SetPipelineRunning sets the pipeline status to running (1) or stopped (0)
+func (s *StatsD) SetPipelineRunning(running bool) {
+	s.send("pipeline_status", gaugeBool(running), "pipeline", s.pipeline)
+}
+
+// SetSourceConnected sets the source connection status
+func (s *StatsD) SetSourceConnected(connected bool) {
+	s.send("source_connected", gaugeBool(connected), "pipeline", s.pipeline)
+}
+
+// SetSinkConnected sets the sink connection status
+func (s *StatsD) SetSinkConnected(connected bool) {
+	s.send("sink_connected", gaugeBool(connected), "pipeline", s.pipeline)
+}
+
+// SetQueueDepth sets the number of events waiting in a queue
+func (s *StatsD) SetQueueDepth(pipelineName, queue string, depth int) {
+	s.send("queue_depth", strconv.Itoa(depth)+"|g", "pipeline", pipelineName, "queue", queue)
+}
+
+// SetMemoryUsed sets the bytes held against the memory budget
+func (s *StatsD) SetMemoryUsed(pipelineName string, bytes int64) {
+	s.send("memory_budget_used_bytes", strconv.FormatInt(bytes, 10)+"|g", "pipeline", pipelineName)
+}
+
+// RecordRetry records a retried operation
+func (s *StatsD) RecordRetry(pipelineName, component string) {
+	s.send("retries", "1|c", "pipeline", pipelineName, "component", component)
+}
+
+// SetCircuitBreakerState sets the circuit breaker state of a component
+func (s *StatsD) SetCircuitBreakerState(pipelineName, component string, state int) {
+	s.send("circuit_breaker_state", strconv.Itoa(state)+"|g", "pipeline", pipelineName, "component", component)
+}
+
+// RecordDLQWrite records an event written to the dead-letter queue
+func (s *StatsD) RecordDLQWrite(pipelineName, component string) {
+	s.send("dlq_events", "1|c", "pipeline", pipelineName, "component", component)
+}
+
+// SetLastCheckpoint records the time of the last saved checkpoint
+func (s *StatsD) SetLastCheckpoint(pipelineName string, t time.Time) {
+	s.send("last_checkpoint_timestamp_seconds", strconv.FormatInt(t.Unix(), 10)+"|g", "pipeline", pipelineName)
+}
+
+// SetInitialSyncProgress records the documents copied and estimated for an initial sync
+func (s *StatsD) SetInitialSyncProgress(pipelineName string, copied, estimated int64) {
+	s.send("initial_sync_documents_copied", strconv.FormatInt(copied, 10)+"|g", "pipeline", pipelineName)
+	s.send("initial_sync_documents_estimated", strconv.FormatInt(estimated, 10)+"|g", "pipeline", pipelineName)
+}
+
+// SetInitialSyncCompletion records the percentage complete and estimated time remaining of an initial sync
+func (s *StatsD) SetInitialSyncCompletion(pipelineName string, percent float64, eta time.Duration) {
+	s.send("initial_sync_percent_complete", strconv.FormatFloat(percent, 'f', 1, 64)+"|g", "pipeline", pipelineName)
+	s.send("initial_sync_eta_seconds", strconv.FormatInt(int64(eta.Seconds()), 10)+"|g", "pipeline", pipelineName)
+}
+
+// ObserveEndToEndLatency records the latency of a committed event as a
+// timer. StatsD has no exemplars, so the exemplar labels are dropped.
+func (s *StatsD) ObserveEndToEndLatency(pipelineName, operation string, seconds float64, exemplar map[string]string) {
+	ms := strconv.FormatFloat(seconds*1000, 'f', 3, 64)
+	s.send("end_to_end_latency", ms+"|ms", "pipeline", pipelineName, "operation", operation)
+}
+
+// AddBytesRead records bytes read from the source
+func (s *StatsD) AddBytesRead(pipelineName string, bytes int64) {
+	s.send("source_bytes_read", strconv.FormatInt(bytes, 10)+"|c", "pipeline", pipelineName)
+}
+
+// AddDocumentsScanned records documents read from the source
+func (s *StatsD) AddDocumentsScanned(pipelineName string, documents int64) {
+	s.send("source_documents_scanned", strconv.FormatInt(documents, 10)+"|c", "pipeline", pipelineName)
+}
+
+// AddBytesWritten records bytes committed by the sink
+func (s *StatsD) AddBytesWritten(pipelineName string, bytes int64) {
+	s.send("sink_bytes_written", strconv.FormatInt(bytes, 10)+"|c", "pipeline", pipelineName)
+}
+
+// AddStorageGrowth records a change in the estimated size of the sink's data
+// as a relative gauge update
+func (s *StatsD) AddStorageGrowth(pipelineName string, bytes int64) {
+	value := strconv.FormatInt(bytes, 10)
+	if bytes >= 0 {
+		value = "+" + value
+	}
+	s.send("sink_storage_growth_bytes", value+"|g", "pipeline", pipelineName)
+}
+
+// Close closes the UDP connection
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
+
+// send writes one metric. labels are name/value pairs, sent as DogStatsD tags
+// or appended to the metric name for plain StatsD.
+func (s *StatsD) send(name, value string, labels ...string) {
+	var b strings.Builder
+	b.WriteString(s.prefix)
+	b.WriteByte('.')
+	b.WriteString(name)
+
+	if !s.dogstatsd {
+		for i := 1; i < len(labels); i += 2 {
+			b.WriteByte('.')
+			b.WriteString(sanitizeStatsD(labels[i]))
+		}
+		b.WriteByte(':')
+		b.WriteString(value)
+	} else {
+		b.WriteByte(':')
+		b.WriteString(value)
+		b.WriteString("|#")
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(labels[i])
+			b.WriteByte(':')
+			b.WriteString(labels[i+1])
+		}
+		for _, tag := range s.tags {
+			b.WriteByte(',')
+			b.WriteString(tag)
+		}
+	}
+
+	// StatsD is fire-and-forget; report the first failure only
+	if _, err := s.conn.Write([]byte(b.String())); err != nil {
+		s.errOnce.Do(func() {
+			s.logger.Printf("Failed to send StatsD metrics: %v", err)
+		})
+	}
+}
+
+// gaugeBool formats a boolean as a 0/1 gauge value
+func gaugeBool(v bool) string {
+	if v {
+		return "1|g"
+	}
+	return "0|g"
+}
+
+// sanitizeStatsD replaces characters with special meaning in StatsD names
+func sanitizeStatsD(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', ':', '|', '@', '#', ' ':
+			return '_'
+		}
+		return r
+	}, s)
+}
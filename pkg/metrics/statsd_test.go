@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// listenStatsD starts a UDP listener and returns its address and a read function
+func listenStatsD(t *testing.T) (string, func() string) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	read := func() string {
+		buf := make([]byte, 1024)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("failed to read metric: %v", err)
+		}
+		return string(buf[:n])
+	}
+	return conn.LocalAddr().String(), read
+}
+
+func TestStatsDFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend Backend
+		record  func(r Recorder)
+		want    string
+	}{
+		{
+			name:    "statsd counter",
+			backend: BackendStatsD,
+			record:  func(r Recorder) { r.RecordEventProcessed("orders", "insert") },
+			want:    "datapipe.events_processed.orders.insert:1|c",
+		},
+		{
+			name:    "statsd sanitizes label values",
+			backend: BackendStatsD,
+			record:  func(r Recorder) { r.SetQueueDepth("my.pipe", "buffer", 12) },
+			want:    "datapipe.queue_depth.my_pipe.buffer:12|g",
+		},
+		{
+			name:    "dogstatsd tags",
+			backend: BackendDogStatsD,
+			record:  func(r Recorder) { r.RecordEventError("orders", "sink", "write_error") },
+			want:    "datapipe.events_errored:1|c|#pipeline:orders,component:sink,error_type:write_error,env:test",
+		},
+		{
+			name:    "dogstatsd timer",
+			backend: BackendDogStatsD,
+			record:  func(r Recorder) { r.RecordProcessingDuration("orders", "transform", 0.25) },
+			want:    "datapipe.event_processing_duration:250.000|ms|#pipeline:orders,component:transform,env:test",
+		},
+		{
+			name:    "gauge without labels uses the pipeline name",
+			backend: BackendDogStatsD,
+			record:  func(r Recorder) { r.SetSinkConnected(true) },
+			want:    "datapipe.sink_connected:1|g|#pipeline:orders,env:test",
+		},
+		{
+			name:    "relative gauge",
+			backend: BackendStatsD,
+			record:  func(r Recorder) { r.AddStorageGrowth("orders", 512) },
+			want:    "datapipe.sink_storage_growth_bytes.orders:+512|g",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, read := listenStatsD(t)
+			r, err := NewRecorder("orders", Options{Backend: tt.backend, Address: addr, Tags: []string{"env:test"}}, nil)
+			if err != nil {
+				t.Fatalf("NewRecorder() error = %v", err)
+			}
+			defer r.Close()
+
+			tt.record(r)
+			if got := read(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewRecorderUnknownBackend(t *testing.T) {
+	if _, err := NewRecorder("orders", Options{Backend: "graphite"}, nil); err == nil {
+		t.Error("expected an error for an unknown backend")
+	}
+}
@@ -0,0 +1,60 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+)
+
+// onAck is called by sinks that report the outcome of every event after each
+// batch. A retryable failure fails the whole batch, as a batch error passed
+// to onCommit does, so it is held for replay with every later batch. Events
+// the sink rejected permanently are dead-lettered on their own, and the rest
+// of the batch commits past them.
+func (p *Pipeline) onAck(acks []Ack) {
+	events := make([]Event, len(acks))
+	var retryable error
+	permanent := 0
+	for i, ack := range acks {
+		events[i] = ack.Event
+		switch ack.Status {
+		case AckRetryable:
+			if retryable == nil {
+				retryable = ack.err()
+			}
+		case AckPermanent:
+			permanent++
+		}
+	}
+	if retryable != nil || permanent == 0 {
+		p.settleBatch(events, events, retryable)
+		return
+	}
+
+	written := make([]Event, 0, len(acks)-permanent)
+	for _, ack := range acks {
+		if ack.Status == AckPermanent {
+			p.recordError("sink", "rejected_event", ack.err())
+			p.deadLetter(context.Background(), ack.Event, "rejected_event", ack.err().Error())
+			continue
+		}
+		written = append(written, ack.Event)
+	}
+	p.settleBatch(events, written, nil)
+}
+
+// reportsCommits reports whether a sink tells the pipeline when batches are written
+func reportsCommits(sink Sink) bool {
+	switch sink.(type) {
+	case AckNotifier, CommitNotifier:
+		return true
+	}
+	return false
+}
+
+// err returns why an event failed, describing the failure if the sink did not
+func (a Ack) err() error {
+	if a.Err != nil {
+		return a.Err
+	}
+	return fmt.Errorf("sink reported a %s failure for event %s", a.Status, a.Event.ID)
+}
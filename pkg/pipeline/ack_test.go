@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+// ackSink is a mock sink that acknowledges all its events as one batch, with
+// the status set for their ID or else success
+type ackSink struct {
+	MockSink
+	statuses map[string]AckStatus
+	onAck    AckHandler
+}
+
+func (a *ackSink) SetAckHandler(handler AckHandler) {
+	a.onAck = handler
+}
+
+func (a *ackSink) Write(ctx context.Context, events <-chan Event) <-chan error {
+	errors := make(chan error)
+	go func() {
+		defer close(errors)
+		var acks []Ack
+		for event := range events {
+			status, ok := a.statuses[event.ID]
+			if !ok {
+				status = AckSuccess
+			}
+			if status == AckSuccess {
+				a.received = append(a.received, event)
+			}
+			acks = append(acks, Ack{Event: event, Status: status})
+		}
+		if len(acks) > 0 {
+			a.onAck(acks)
+		}
+	}()
+	return errors
+}
+
+// TestPipelineAcks tests that per-event acknowledgments dead-letter permanent failures and hold retryable ones
+func TestPipelineAcks(t *testing.T) {
+	events := []Event{
+		{ID: "1", Operation: "insert", Position: "p1"},
+		{ID: "2", Operation: "insert", Position: "p2"},
+		{ID: "3", Operation: "insert", Position: "p3"},
+	}
+	tests := []struct {
+		name           string
+		statuses       map[string]AckStatus
+		wantDLQ        int
+		wantCheckpoint string
+		wantCommitted  int
+	}{
+		{"all written", nil, 0, "p3", 3},
+		{"permanent failure", map[string]AckStatus{"2": AckPermanent}, 1, "p3", 2},
+		{"retryable failure", map[string]AckStatus{"2": AckRetryable, "3": AckPermanent}, 0, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &ackSink{statuses: tt.statuses}
+			dlq := &collectingDLQ{}
+			store := newMemoryCheckpointStore()
+			var committed int
+			p := New("test", NewMockSource(events), sink, nil, nil)
+			p.SetDeadLetterQueue(dlq)
+			p.SetCheckpointStore(store)
+			p.SetHooks(Hooks{OnBatchCommitted: func(events []Event) { committed += len(events) }})
+			if err := p.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if len(dlq.events) != tt.wantDLQ {
+				t.Errorf("expected %d dead-lettered events, got %d", tt.wantDLQ, len(dlq.events))
+			}
+			if got := store.saved["test"].Position; got != tt.wantCheckpoint {
+				t.Errorf("checkpoint = %q, want %q", got, tt.wantCheckpoint)
+			}
+			if committed != tt.wantCommitted {
+				t.Errorf("expected %d events reported committed, got %d", tt.wantCommitted, committed)
+			}
+		})
+	}
+}
+
+// ackingSource is a mock source that records the positions it is acknowledged up to
+type ackingSource struct {
+	MockSource
+	mu    sync.Mutex
+	acked []string
+}
+
+func (a *ackingSource) Ack(ctx context.Context, position string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.acked = append(a.acked, position)
+	return nil
+}
+
+// TestPipelineSourceAcks tests that the source is acknowledged up to each committed batch and never for a failed one
+func TestPipelineSourceAcks(t *testing.T) {
+	events := []Event{
+		{ID: "1", Operation: "insert", Position: "p1"},
+		{ID: "2", Operation: "insert", Position: "p2"},
+		{ID: "3", Operation: "insert", Position: "p3"},
+	}
+	tests := []struct {
+		name      string
+		failFrom  int
+		wantAcked []string
+	}{
+		{"committed", 0, []string{"p2", "p3"}},
+		{"failed", 1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &ackingSource{MockSource: *NewMockSource(events)}
+			p := New("test", source, &commitSink{batchSize: 2, failFrom: tt.failFrom}, nil, nil)
+			p.Run(context.Background())
+
+			source.mu.Lock()
+			defer source.mu.Unlock()
+			if len(source.acked) != len(tt.wantAcked) {
+				t.Fatalf("acked = %v, want %v", source.acked, tt.wantAcked)
+			}
+			for i, position := range tt.wantAcked {
+				if source.acked[i] != position {
+					t.Errorf("acked = %v, want %v", source.acked, tt.wantAcked)
+				}
+			}
+		})
+	}
+}
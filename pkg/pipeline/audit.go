@@ -0,0 +1,118 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+)
+
+// Audit outcomes
+const (
+	AuditWritten        = "written"         // committed by the sink
+	AuditFailed         = "failed"          // part of a batch the sink could not write
+	AuditSent           = "sent"            // handed to a sink that does not report commits
+	AuditTransformError = "transform_error" // rejected by the transformer
+	AuditDeadLettered   = "dead_lettered"   // routed to the dead-letter queue
+	AuditDropped        = "dropped"         // rejected with no dead-letter queue configured
+)
+
+// SetAuditLog sets the log receiving an audit record for every processed event
+func (p *Pipeline) SetAuditLog(audit AuditLog) {
+	p.audit = audit
+}
+
+// auditEvents writes one record per event. Audit failures are logged but never stop the pipeline.
+func (p *Pipeline) auditEvents(ctx context.Context, outcome, reason string, events []Event) {
+	if p.audit == nil || len(events) == 0 {
+		return
+	}
+
+	now := p.clock.Now()
+	records := make([]AuditRecord, 0, len(events))
+	for _, e := range events {
+		if outcome == AuditWritten && p.auditRejected.take(e.ID) {
+			// Dead-lettered by the sink while isolating a failed batch
+			continue
+		}
+		var latency float64
+		if !e.Timestamp.IsZero() {
+			latency = float64(now.Sub(e.Timestamp).Microseconds()) / 1000
+		}
+		records = append(records, AuditRecord{
+			Pipeline:   p.name,
+			EventID:    e.ID,
+			Operation:  e.Operation,
+			Collection: e.Collection,
+			Outcome:    outcome,
+			Reason:     reason,
+			LatencyMs:  latency,
+			RecordedAt: now,
+		})
+	}
+	if len(records) == 0 {
+		return
+	}
+
+	if err := p.audit.Record(ctx, records); err != nil {
+		p.logger.Printf("Failed to write %d audit records: %v", len(records), err)
+		p.recordError("audit", "write_error", err)
+	}
+}
+
+// AuditedDeadLetterQueue wraps a dead-letter queue handed to the sink so that
+// events it rejects are audited as dead-lettered rather than written. It
+// returns dlq unchanged when no audit log is set.
+func (p *Pipeline) AuditedDeadLetterQueue(dlq DeadLetterQueue) DeadLetterQueue {
+	if p.audit == nil || dlq == nil {
+		return dlq
+	}
+	return &auditedDLQ{dlq: dlq, pipeline: p}
+}
+
+// auditedDLQ records sink-side rejections in the audit log
+type auditedDLQ struct {
+	dlq      DeadLetterQueue
+	pipeline *Pipeline
+}
+
+// Send forwards the event to the wrapped queue and audits it
+func (a *auditedDLQ) Send(ctx context.Context, event Event, reason string) error {
+	if err := a.dlq.Send(ctx, event, reason); err != nil {
+		return err
+	}
+	a.pipeline.auditRejected.add(event.ID)
+	a.pipeline.auditEvents(ctx, AuditDeadLettered, reason, []Event{event})
+	return nil
+}
+
+// rejectedSet counts event IDs the sink dead-lettered inside a batch that
+// later committed, so the commit is not audited as written for them
+type rejectedSet struct {
+	mu  sync.Mutex
+	ids map[string]int
+}
+
+// add marks an event ID as rejected
+func (s *rejectedSet) add(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ids == nil {
+		s.ids = make(map[string]int)
+	}
+	s.ids[id]++
+}
+
+// take reports whether an event ID was rejected, consuming one mark
+func (s *rejectedSet) take(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.ids[id]
+	if n == 0 {
+		return false
+	}
+	if n == 1 {
+		delete(s.ids, id)
+	} else {
+		s.ids[id] = n - 1
+	}
+	return true
+}
@@ -0,0 +1,146 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryAuditLog keeps audit records for inspection
+type memoryAuditLog struct {
+	mu      sync.Mutex
+	records []AuditRecord
+}
+
+func (m *memoryAuditLog) Record(ctx context.Context, records []AuditRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records = append(m.records, records...)
+	return nil
+}
+
+// outcomes returns the recorded outcome for every event ID
+func (m *memoryAuditLog) outcomes() map[string]string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	outcomes := make(map[string]string)
+	for _, r := range m.records {
+		outcomes[r.EventID] = r.Outcome
+	}
+	return outcomes
+}
+
+// rejectTransformer fails events with the given ID
+type rejectTransformer struct {
+	id string
+}
+
+func (r rejectTransformer) Transform(ctx context.Context, event Event) (Event, error) {
+	if event.ID == r.id {
+		return event, fmt.Errorf("cannot transform %s", event.ID)
+	}
+	return event, nil
+}
+
+// isolatingSink dead-letters events with the given ID and commits the rest of the batch
+type isolatingSink struct {
+	commitSink
+	rejectID string
+	dlq      DeadLetterQueue
+}
+
+func (s *isolatingSink) Write(ctx context.Context, events <-chan Event) <-chan error {
+	errors := make(chan error)
+	go func() {
+		defer close(errors)
+		var batch []Event
+		for event := range events {
+			if event.ID == s.rejectID {
+				s.dlq.Send(ctx, event, "bad row")
+			}
+			batch = append(batch, event)
+		}
+		s.onCommit(batch, nil)
+	}()
+	return errors
+}
+
+// TestPipelineAuditOutcomes tests that every event gets an audit record with its outcome
+func TestPipelineAuditOutcomes(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	clock := NewManualClock(start.Add(250 * time.Millisecond))
+	events := []Event{
+		{ID: "ok", Operation: "insert", Collection: "users", Timestamp: start, Data: map[string]interface{}{"v": "small"}},
+		{ID: "bad", Operation: "update", Collection: "users", Timestamp: start, Data: map[string]interface{}{"v": "small"}},
+		{ID: "big", Operation: "insert", Collection: "users", Timestamp: start, Data: map[string]interface{}{"v": "this value is far too large"}},
+	}
+	audit := &memoryAuditLog{}
+
+	p := New("test", NewMockSource(events), &commitSink{batchSize: 10}, rejectTransformer{id: "bad"}, nil)
+	p.SetClock(clock)
+	p.SetAuditLog(audit)
+	p.SetDeadLetterQueue(memoryDLQ{})
+	p.SetSizeLimit(SizeLimit{MaxBytes: 20, Policy: OversizeDLQ})
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	want := map[string]string{"ok": AuditWritten, "bad": AuditTransformError, "big": AuditDeadLettered}
+	got := audit.outcomes()
+	for id, outcome := range want {
+		if got[id] != outcome {
+			t.Errorf("event %s: expected outcome %q, got %q", id, outcome, got[id])
+		}
+	}
+	for _, r := range audit.records {
+		if r.Pipeline != "test" || r.Collection != "users" {
+			t.Errorf("unexpected record %+v", r)
+		}
+		if r.LatencyMs != 250 {
+			t.Errorf("event %s: expected latency 250ms, got %v", r.EventID, r.LatencyMs)
+		}
+	}
+}
+
+// TestPipelineAuditsSinkRejections tests that events dead-lettered by the sink are not audited as written
+func TestPipelineAuditsSinkRejections(t *testing.T) {
+	events := []Event{{ID: "1"}, {ID: "2"}, {ID: "3"}}
+	audit := &memoryAuditLog{}
+	snk := &isolatingSink{rejectID: "2"}
+
+	p := New("test", NewMockSource(events), snk, nil, nil)
+	p.SetAuditLog(audit)
+	snk.dlq = p.AuditedDeadLetterQueue(memoryDLQ{})
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if len(audit.records) != 3 {
+		t.Fatalf("expected 3 audit records, got %d", len(audit.records))
+	}
+	want := map[string]string{"1": AuditWritten, "2": AuditDeadLettered, "3": AuditWritten}
+	got := audit.outcomes()
+	for id, outcome := range want {
+		if got[id] != outcome {
+			t.Errorf("event %s: expected outcome %q, got %q", id, outcome, got[id])
+		}
+	}
+}
+
+// TestPipelineAuditWithoutNotifier tests that events are audited on handoff when the sink does not report commits
+func TestPipelineAuditWithoutNotifier(t *testing.T) {
+	audit := &memoryAuditLog{}
+
+	p := New("test", NewMockSource([]Event{{ID: "1"}, {ID: "2"}}), NewMockSink(), nil, nil)
+	p.SetAuditLog(audit)
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	got := audit.outcomes()
+	if len(got) != 2 || got["1"] != AuditSent || got["2"] != AuditSent {
+		t.Errorf("expected both events audited as sent, got %v", got)
+	}
+}
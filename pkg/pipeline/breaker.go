@@ -0,0 +1,154 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// BreakerState is the state of a circuit breaker
+type BreakerState int
+
+const (
+	// BreakerClosed lets all calls through
+	BreakerClosed BreakerState = iota
+	// BreakerOpen rejects calls until the open timeout elapses
+	BreakerOpen
+	// BreakerHalfOpen lets a single probe call through to test recovery
+	BreakerHalfOpen
+)
+
+// String returns the name of the breaker state
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half_open"
+	default:
+		return "closed"
+	}
+}
+
+// BreakerReporter is implemented by components guarded by a circuit breaker
+type BreakerReporter interface {
+	// BreakerState returns the current circuit breaker state
+	BreakerState() BreakerState
+}
+
+// CircuitBreaker stops calls to a failing dependency after a number of
+// consecutive failures and periodically probes it until it recovers
+type CircuitBreaker struct {
+	failureThreshold int
+	openTimeout      time.Duration
+	mu               sync.Mutex // protects the fields below
+	state            BreakerState
+	failures         int
+	openedAt         time.Time
+	onStateChange    func(from, to BreakerState)
+	clock            Clock
+}
+
+// NewCircuitBreaker creates a circuit breaker that opens after failureThreshold
+// consecutive failures and probes again after openTimeout
+func NewCircuitBreaker(failureThreshold int, openTimeout time.Duration) *CircuitBreaker {
+	if failureThreshold <= 0 {
+		failureThreshold = 1
+	}
+	if openTimeout <= 0 {
+		openTimeout = 30 * time.Second
+	}
+	return &CircuitBreaker{
+		failureThreshold: failureThreshold,
+		openTimeout:      openTimeout,
+		clock:            SystemClock,
+	}
+}
+
+// SetClock sets the time source used for the open timeout
+func (b *CircuitBreaker) SetClock(clock Clock) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clock = clock
+}
+
+// OnStateChange registers a callback invoked on every state transition.
+// The callback runs while the breaker is locked and must not call back into it.
+func (b *CircuitBreaker) OnStateChange(fn func(from, to BreakerState)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onStateChange = fn
+}
+
+// State returns the current breaker state
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Allow reports whether a call may proceed, moving an open breaker to
+// half-open once the open timeout has elapsed
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen {
+		if b.clock.Since(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.setStateLocked(BreakerHalfOpen)
+	}
+	return true
+}
+
+// Wait blocks until the breaker allows a call or the context is cancelled
+func (b *CircuitBreaker) Wait(ctx context.Context) error {
+	for !b.Allow() {
+		b.mu.Lock()
+		remaining := b.openTimeout - b.clock.Since(b.openedAt)
+		clock := b.clock
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clock.After(remaining):
+		}
+	}
+	return nil
+}
+
+// RecordSuccess records a successful call and closes the breaker
+func (b *CircuitBreaker) RecordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	b.setStateLocked(BreakerClosed)
+}
+
+// RecordFailure records a failed call, opening the breaker once the
+// threshold is reached or when a half-open probe fails
+func (b *CircuitBreaker) RecordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= b.failureThreshold {
+		b.openedAt = b.clock.Now()
+		b.setStateLocked(BreakerOpen)
+	}
+}
+
+// setStateLocked transitions the breaker (caller must hold the lock)
+func (b *CircuitBreaker) setStateLocked(state BreakerState) {
+	if b.state == state {
+		return
+	}
+	from := b.state
+	b.state = state
+	if b.onStateChange != nil {
+		b.onStateChange(from, state)
+	}
+}
@@ -0,0 +1,156 @@
+package pipeline
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// TestCircuitBreakerOpensAfterThreshold tests that consecutive failures open the breaker
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	b := NewCircuitBreaker(3, time.Hour)
+
+	b.RecordFailure()
+	b.RecordFailure()
+	if b.State() != BreakerClosed {
+		t.Fatalf("expected closed below threshold, got %s", b.State())
+	}
+	if !b.Allow() {
+		t.Fatal("closed breaker should allow calls")
+	}
+
+	b.RecordFailure()
+	if b.State() != BreakerOpen {
+		t.Fatalf("expected open at threshold, got %s", b.State())
+	}
+	if b.Allow() {
+		t.Error("open breaker should reject calls before the timeout")
+	}
+}
+
+// TestCircuitBreakerSuccessResets tests that a success resets the failure count
+func TestCircuitBreakerSuccessResets(t *testing.T) {
+	b := NewCircuitBreaker(2, time.Hour)
+
+	b.RecordFailure()
+	b.RecordSuccess()
+	b.RecordFailure()
+	if b.State() != BreakerClosed {
+		t.Errorf("expected closed after success reset, got %s", b.State())
+	}
+}
+
+// TestCircuitBreakerHalfOpenProbe tests probing after the open timeout
+func TestCircuitBreakerHalfOpenProbe(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	b := NewCircuitBreaker(1, 10*time.Second)
+	b.SetClock(clock)
+
+	var transitions []string
+	b.OnStateChange(func(from, to BreakerState) {
+		transitions = append(transitions, from.String()+"->"+to.String())
+	})
+
+	b.RecordFailure()
+	clock.Advance(5 * time.Second)
+	if b.Allow() {
+		t.Fatal("expected calls to be rejected before the timeout")
+	}
+	clock.Advance(5 * time.Second)
+
+	if !b.Allow() {
+		t.Fatal("expected probe to be allowed after timeout")
+	}
+	if b.State() != BreakerHalfOpen {
+		t.Fatalf("expected half_open, got %s", b.State())
+	}
+
+	// A failed probe re-opens immediately
+	b.RecordFailure()
+	if b.State() != BreakerOpen {
+		t.Fatalf("expected open after failed probe, got %s", b.State())
+	}
+
+	clock.Advance(10 * time.Second)
+	b.Allow()
+	b.RecordSuccess()
+	if b.State() != BreakerClosed {
+		t.Fatalf("expected closed after successful probe, got %s", b.State())
+	}
+
+	want := []string{"closed->open", "open->half_open", "half_open->open", "open->half_open", "half_open->closed"}
+	if len(transitions) != len(want) {
+		t.Fatalf("transitions = %v, want %v", transitions, want)
+	}
+	for i := range want {
+		if transitions[i] != want[i] {
+			t.Errorf("transition %d = %s, want %s", i, transitions[i], want[i])
+		}
+	}
+}
+
+// TestCircuitBreakerWait tests that Wait blocks until the breaker allows a call
+func TestCircuitBreakerWait(t *testing.T) {
+	clock := NewManualClock(time.Unix(0, 0))
+	b := NewCircuitBreaker(1, time.Minute)
+	b.SetClock(clock)
+	b.RecordFailure()
+
+	done := make(chan error, 1)
+	go func() { done <- b.Wait(context.Background()) }()
+	for clock.Waiters() == 0 {
+		runtime.Gosched()
+	}
+	select {
+	case <-done:
+		t.Fatal("Wait() returned before the open timeout elapsed")
+	default:
+	}
+
+	clock.Advance(time.Minute)
+	if err := <-done; err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+
+	b.RecordFailure()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := b.Wait(ctx); err == nil {
+		t.Error("expected Wait() to fail on a cancelled context")
+	}
+}
+
+// breakerSink is a mock sink reporting a circuit breaker state
+type breakerSink struct {
+	MockSink
+	state BreakerState
+}
+
+func (b *breakerSink) BreakerState() BreakerState {
+	return b.state
+}
+
+// TestHealthReflectsOpenBreaker tests that an open sink breaker makes the pipeline unhealthy
+func TestHealthReflectsOpenBreaker(t *testing.T) {
+	sink := &breakerSink{state: BreakerOpen}
+	p := New("test", NewMockSource(nil), sink, nil, nil)
+	p.sourceConnected = true
+	p.sinkConnected = true
+
+	status := p.GetStatus()
+	if status.Healthy {
+		t.Error("expected unhealthy status with open breaker")
+	}
+	if !status.PipelineRunning {
+		t.Error("expected pipeline to still be running")
+	}
+	if status.CircuitBreaker != "open" {
+		t.Errorf("expected circuit_breaker=open, got %q", status.CircuitBreaker)
+	}
+
+	sink.state = BreakerClosed
+	if !p.IsHealthy() {
+		t.Error("expected healthy once the breaker closes")
+	}
+}
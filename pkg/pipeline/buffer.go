@@ -0,0 +1,202 @@
+package pipeline
+
+import (
+	"context"
+)
+
+// runBuffer forwards events to the sink, spilling them into the event buffer
+// whenever the sink is not ready to receive. Buffered events are always
+// delivered before newer ones, so ordering is preserved. Events left in the
+// buffer at shutdown, and with a CommittableBuffer those the sink had not yet
+// committed, are replayed first on the next run.
+func (p *Pipeline) runBuffer(ctx context.Context, in <-chan Event) <-chan Event {
+	out := make(chan Event)
+
+	go func() {
+		defer close(out)
+		// Keep events that arrive during shutdown for the next run
+		defer p.drainToBuffer(in)
+
+		for {
+			head, ok, err := p.buffer.Peek()
+			if err != nil {
+				p.logger.Printf("Spill buffer read failed, bypassing buffer: %v", err)
+				p.recordError("buffer", "read_error", err)
+				p.forward(ctx, in, out)
+				return
+			}
+
+			if !ok {
+				// Buffer is empty: pass events straight through unless the sink is busy
+				if in == nil {
+					return
+				}
+				var event Event
+				var more bool
+				select {
+				case <-ctx.Done():
+					return
+				case event, more = <-in:
+				}
+				if !more {
+					in = nil
+					continue
+				}
+
+				p.trackHandoff(false)
+				select {
+				case out <- event:
+					continue
+				default:
+					p.untrackHandoff()
+				}
+				if !p.spill(ctx, event, out) {
+					return
+				}
+				continue
+			}
+
+			// Drain the buffer while still accepting new events into it
+			p.trackHandoff(true)
+			select {
+			case <-ctx.Done():
+				p.untrackHandoff()
+				return
+			case out <- head:
+				if err := p.buffer.Advance(); err != nil {
+					p.logger.Printf("Failed to advance spill buffer: %v", err)
+				}
+			case event, more := <-in:
+				p.untrackHandoff()
+				if !more {
+					in = nil
+					continue
+				}
+				if !p.spill(ctx, event, out) {
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+// spill appends an event to the buffer. If the buffer cannot accept it, the
+// buffered events and then the event itself are delivered synchronously,
+// applying backpressure to the source. It returns false if ctx was cancelled.
+func (p *Pipeline) spill(ctx context.Context, event Event, out chan<- Event) bool {
+	err := p.buffer.Append(event)
+	if err == nil {
+		return true
+	}
+	p.logger.Printf("Spill buffer unavailable, applying backpressure: %v", err)
+
+	for {
+		head, ok, err := p.buffer.Peek()
+		if err != nil || !ok {
+			break
+		}
+		p.trackHandoff(true)
+		select {
+		case <-ctx.Done():
+			p.untrackHandoff()
+			p.logger.Printf("Dropping event %s during shutdown, spill buffer unavailable", event.ID)
+			return false
+		case out <- head:
+			if err := p.buffer.Advance(); err != nil {
+				p.logger.Printf("Failed to advance spill buffer: %v", err)
+			}
+		}
+	}
+
+	p.trackHandoff(false)
+	select {
+	case <-ctx.Done():
+		p.untrackHandoff()
+		p.logger.Printf("Dropping event %s during shutdown, spill buffer unavailable", event.ID)
+		return false
+	case out <- event:
+		return true
+	}
+}
+
+// drainToBuffer stores any events still arriving on in so they survive shutdown
+func (p *Pipeline) drainToBuffer(in <-chan Event) {
+	if in == nil {
+		return
+	}
+	for event := range in {
+		if err := p.buffer.Append(event); err != nil {
+			p.logger.Printf("Dropping event %s during shutdown, spill buffer unavailable: %v", event.ID, err)
+		}
+	}
+}
+
+// forward passes events straight from in to out until in is closed
+func (p *Pipeline) forward(ctx context.Context, in <-chan Event, out chan<- Event) {
+	if in == nil {
+		return
+	}
+	for event := range in {
+		p.trackHandoff(false)
+		select {
+		case <-ctx.Done():
+			p.untrackHandoff()
+			continue
+		case out <- event:
+		}
+	}
+}
+
+// trackHandoff records that the next event handed to the sink did or did not
+// come from a committable buffer. It is called before the send, since the sink
+// may commit the event as soon as it receives it.
+func (p *Pipeline) trackHandoff(fromBuffer bool) {
+	if _, ok := p.buffer.(CommittableBuffer); !ok {
+		return
+	}
+	p.bufferMu.Lock()
+	p.bufferInflight = append(p.bufferInflight, fromBuffer)
+	p.bufferMu.Unlock()
+}
+
+// untrackHandoff forgets the last tracked handoff after its send did not happen
+func (p *Pipeline) untrackHandoff() {
+	if _, ok := p.buffer.(CommittableBuffer); !ok {
+		return
+	}
+	p.bufferMu.Lock()
+	p.bufferInflight = p.bufferInflight[:len(p.bufferInflight)-1]
+	p.bufferMu.Unlock()
+}
+
+// commitBuffer releases the buffer entries of a committed batch unless
+// commits are held after a failed batch
+func (p *Pipeline) commitBuffer(count int, held bool) {
+	buffer, ok := p.buffer.(CommittableBuffer)
+	if !ok {
+		return
+	}
+
+	p.bufferMu.Lock()
+	if count > len(p.bufferInflight) {
+		count = len(p.bufferInflight)
+	}
+	buffered := 0
+	for _, fromBuffer := range p.bufferInflight[:count] {
+		if fromBuffer {
+			buffered++
+		}
+	}
+	p.bufferInflight = p.bufferInflight[count:]
+	p.bufferMu.Unlock()
+
+	if held || buffered == 0 {
+		return
+	}
+	if err := buffer.Commit(buffered); err != nil {
+		p.logger.Printf("Failed to commit spill buffer: %v", err)
+		p.recordError("buffer", "commit_error", err)
+	}
+}
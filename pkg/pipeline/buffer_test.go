@@ -0,0 +1,153 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryBuffer is an in-memory EventBuffer for testing
+type memoryBuffer struct {
+	mu      sync.Mutex
+	events  []Event
+	max     int
+	appends int
+}
+
+func (m *memoryBuffer) Append(event Event) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.max > 0 && len(m.events) >= m.max {
+		return fmt.Errorf("buffer full")
+	}
+	m.events = append(m.events, event)
+	m.appends++
+	return nil
+}
+
+func (m *memoryBuffer) Peek() (Event, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.events) == 0 {
+		return Event{}, false, nil
+	}
+	return m.events[0], true, nil
+}
+
+func (m *memoryBuffer) Advance() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = m.events[1:]
+	return nil
+}
+
+func (m *memoryBuffer) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.events)
+}
+
+// slowSink is a mock sink that takes time to write each event
+type slowSink struct {
+	MockSink
+	delay time.Duration
+}
+
+func (s *slowSink) Write(ctx context.Context, events <-chan Event) <-chan error {
+	errors := make(chan error)
+	go func() {
+		defer close(errors)
+		for event := range events {
+			time.Sleep(s.delay)
+			s.received = append(s.received, event)
+		}
+	}()
+	return errors
+}
+
+// TestPipelineSpillsToBuffer tests that a slow sink causes events to spill, preserving order
+func TestPipelineSpillsToBuffer(t *testing.T) {
+	for _, max := range []int{0, 3} {
+		t.Run(fmt.Sprintf("max=%d", max), func(t *testing.T) {
+			var events []Event
+			for i := 0; i < 20; i++ {
+				events = append(events, Event{ID: fmt.Sprint(i), Operation: "insert"})
+			}
+
+			sink := &slowSink{delay: time.Millisecond}
+			buffer := &memoryBuffer{max: max}
+			p := New("test", NewMockSource(events), sink, nil, nil)
+			p.SetBuffer(buffer)
+
+			if err := p.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if len(sink.received) != len(events) {
+				t.Fatalf("expected %d events, got %d", len(events), len(sink.received))
+			}
+			for i, event := range sink.received {
+				if event.ID != fmt.Sprint(i) {
+					t.Fatalf("event %d out of order: got %s", i, event.ID)
+				}
+			}
+			if buffer.appends == 0 {
+				t.Error("expected slow sink to cause events to spill")
+			}
+			if buffer.Len() != 0 {
+				t.Errorf("expected buffer drained, %d events left", buffer.Len())
+			}
+		})
+	}
+}
+
+// TestPipelineReplaysBuffer tests that events left in the buffer are delivered first
+func TestPipelineReplaysBuffer(t *testing.T) {
+	buffer := &memoryBuffer{events: []Event{{ID: "old1"}, {ID: "old2"}}}
+	sink := NewMockSink()
+	p := New("test", NewMockSource([]Event{{ID: "new"}}), sink, nil, nil)
+	p.SetBuffer(buffer)
+
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var ids []string
+	for _, event := range sink.received {
+		ids = append(ids, event.ID)
+	}
+	if fmt.Sprint(ids) != "[old1 old2 new]" {
+		t.Errorf("got %v, want [old1 old2 new]", ids)
+	}
+}
+
+// TestPipelineCommitsBufferOnSinkCommit tests that buffered events are only
+// released once committed, and are held for replay after a failed batch
+func TestPipelineCommitsBufferOnSinkCommit(t *testing.T) {
+	buffer := &memoryWAL{}
+	p := New("test", NewMockSource(nil), NewMockSink(), nil, nil)
+	p.SetBuffer(buffer)
+
+	// Two spilled events and one passed straight through, then two more spilled
+	for _, fromBuffer := range []bool{true, true, false, true, true} {
+		if fromBuffer {
+			buffer.Append(Event{})
+			buffer.Advance()
+		}
+		p.trackHandoff(fromBuffer)
+	}
+
+	p.onCommit(make([]Event, 3), nil)
+	if buffer.inflight != 2 {
+		t.Fatalf("expected 2 of 4 buffered events released, %d still in flight", buffer.inflight)
+	}
+
+	p.onCommit(make([]Event, 1), errors.New("batch failed"))
+	p.onCommit(make([]Event, 1), nil)
+	if buffer.inflight != 2 {
+		t.Errorf("expected nothing released after a failed batch, %d in flight", buffer.inflight)
+	}
+}
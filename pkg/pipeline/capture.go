@@ -0,0 +1,66 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Capture tees the raw events read from the source, before the operation
+// policy, validation and the transformer, to Writer as JSON lines, the format
+// the file source reads. A production stream captured this way can be
+// replayed through any configuration to reproduce transformation bugs.
+type Capture struct {
+	Writer    io.Writer     // receives one event per line
+	Duration  time.Duration // how long to capture once the pipeline runs (0: until it stops)
+	MaxEvents int           // most events captured (0: no limit)
+}
+
+// capturer writes the events of a Capture; it is only used by the event stage
+type capturer struct {
+	Capture
+	encoder  *json.Encoder
+	until    time.Time
+	captured int
+	done     bool
+}
+
+// SetCapture tees raw source events to capture.Writer until the capture is
+// complete. Heartbeats and resubmitted events are not captured.
+func (p *Pipeline) SetCapture(capture Capture) {
+	p.capture = &capturer{Capture: capture}
+}
+
+// captureEvent writes a raw source event to the capture, if one is running
+func (p *Pipeline) captureEvent(event Event) {
+	c := p.capture
+	if c == nil || c.done {
+		return
+	}
+	now := p.clock.Now()
+	if c.encoder == nil {
+		c.encoder = json.NewEncoder(c.Writer)
+		if c.Duration > 0 {
+			c.until = now.Add(c.Duration)
+		}
+	}
+	if !c.until.IsZero() && now.After(c.until) {
+		c.finish(p, "its duration has passed")
+		return
+	}
+	if err := c.encoder.Encode(event); err != nil {
+		p.logger.Printf("Stopping capture: %v", err)
+		c.done = true
+		return
+	}
+	c.captured++
+	if c.MaxEvents > 0 && c.captured >= c.MaxEvents {
+		c.finish(p, "it holds the maximum number of events")
+	}
+}
+
+// finish stops capturing
+func (c *capturer) finish(p *Pipeline, reason string) {
+	p.logger.Printf("Capture complete, %s: %d events captured", reason, c.captured)
+	c.done = true
+}
@@ -0,0 +1,57 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// TestPipelineCapture tests that raw source events are captured up to the limit
+func TestPipelineCapture(t *testing.T) {
+	events := []Event{
+		{ID: "1", Operation: "insert", Position: "p1", Data: map[string]interface{}{"name": "a"}},
+		{ID: "2", Operation: "update", Position: "p2", Data: map[string]interface{}{"name": "b"}},
+		{Operation: OperationHeartbeat, Position: "p3"},
+		{ID: "3", Operation: "delete", Position: "p4"},
+	}
+	tests := []struct {
+		name      string
+		maxEvents int
+		wantIDs   []string
+	}{
+		{name: "unlimited", wantIDs: []string{"1", "2", "3"}},
+		{name: "max events", maxEvents: 2, wantIDs: []string{"1", "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			sink := NewMockSink()
+			p := New("test", NewMockSource(events), sink, NewMockTransformer("t-"), nil)
+			p.SetCapture(Capture{Writer: &buf, MaxEvents: tt.maxEvents})
+			if err := p.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if len(sink.received) != 3 {
+				t.Errorf("expected capturing to leave the pipeline alone, got %d events written", len(sink.received))
+			}
+
+			positions := map[string]string{"1": "p1", "2": "p2", "3": "p4"}
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+			if len(lines) != len(tt.wantIDs) {
+				t.Fatalf("expected %d captured events, got %d: %s", len(tt.wantIDs), len(lines), buf.String())
+			}
+			for i, line := range lines {
+				var got Event
+				if err := json.Unmarshal([]byte(line), &got); err != nil {
+					t.Fatalf("line %d is not an event: %v", i, err)
+				}
+				// Captured before the transformer and before ingestion is stamped
+				if got.ID != tt.wantIDs[i] || got.Position != positions[got.ID] || !got.IngestedAt.IsZero() {
+					t.Errorf("line %d: unexpected captured event %+v", i, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// ErrInjectedFault is the error of events failed by Chaos
+var ErrInjectedFault = errors.New("injected fault")
+
+// Chaos injects faults at the pipeline's stage boundaries, so retry,
+// dead-letter and ordering settings can be checked before production. It is
+// meant for test environments only. Rates are fractions of events, from 0 to 1.
+type Chaos struct {
+	// DelayRate is the fraction of events held back before the transformer
+	// and, independently, before the sink
+	DelayRate float64
+	// MaxDelay is the longest injected delay, each delay is random up to it
+	// (default: 1s)
+	MaxDelay time.Duration
+	// ErrorRate is the fraction of events failed with ErrInjectedFault in the
+	// transformer stage, then handled by the transform error policy
+	ErrorRate float64
+	// DuplicateRate is the fraction of events handed to the sink twice
+	DuplicateRate float64
+	// Seed makes the injected faults reproducible (0: a random seed)
+	Seed int64
+}
+
+// defaultChaosMaxDelay is the longest injected delay if none is configured
+const defaultChaosMaxDelay = time.Second
+
+// chaos decides which faults to inject; it is only used by the event stage
+type chaos struct {
+	Chaos
+	random *rand.Rand
+}
+
+// SetChaos enables fault injection
+func (p *Pipeline) SetChaos(config Chaos) {
+	if config.MaxDelay <= 0 {
+		config.MaxDelay = defaultChaosMaxDelay
+	}
+	seed := config.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	p.chaos = &chaos{Chaos: config, random: rand.New(rand.NewSource(seed))}
+}
+
+// delay holds an event back for a random time, with probability DelayRate.
+// It returns false if ctx was cancelled first.
+func (c *chaos) delay(ctx context.Context, clock Clock) bool {
+	if c == nil || c.random.Float64() >= c.DelayRate {
+		return true
+	}
+	select {
+	case <-ctx.Done():
+		return false
+	case <-clock.After(time.Duration(c.random.Int63n(int64(c.MaxDelay)) + 1)):
+		return true
+	}
+}
+
+// fail returns ErrInjectedFault with probability ErrorRate
+func (c *chaos) fail() error {
+	if c == nil || c.random.Float64() >= c.ErrorRate {
+		return nil
+	}
+	return ErrInjectedFault
+}
+
+// duplicate follows each event with a copy of it with probability
+// DuplicateRate. Copies get their own Data map, since the sink may recycle it.
+func (c *chaos) duplicate(events []Event) []Event {
+	if c == nil || c.DuplicateRate <= 0 {
+		return events
+	}
+	var out []Event
+	for i, e := range events {
+		if c.random.Float64() >= c.DuplicateRate {
+			if out != nil {
+				out = append(out, e)
+			}
+			continue
+		}
+		if out == nil {
+			out = append(make([]Event, 0, len(events)+1), events[:i]...)
+		}
+		dup := e
+		if e.Data != nil {
+			dup.Data = NewData()
+			for k, v := range e.Data {
+				dup.Data[k] = v
+			}
+		}
+		out = append(out, e, dup)
+	}
+	if out == nil {
+		return events
+	}
+	return out
+}
+
+// transform runs the transformer, unless an error is injected instead
+func (p *Pipeline) transform(ctx context.Context, event Event) (Event, error) {
+	if err := p.chaos.fail(); err != nil {
+		return event, err
+	}
+	if p.transformer == nil {
+		return event, nil
+	}
+	return p.transformer.Transform(ctx, event)
+}
@@ -0,0 +1,70 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// TestPipelineChaos tests that injected faults go through the pipeline's error handling
+func TestPipelineChaos(t *testing.T) {
+	tests := []struct {
+		name         string
+		chaos        Chaos
+		wantDLQ      int
+		wantReceived int
+	}{
+		{name: "none", chaos: Chaos{}, wantReceived: 10},
+		{name: "delays", chaos: Chaos{DelayRate: 1, MaxDelay: time.Millisecond}, wantReceived: 10},
+		{name: "errors", chaos: Chaos{ErrorRate: 1}, wantDLQ: 10},
+		{name: "duplicates", chaos: Chaos{DuplicateRate: 1}, wantReceived: 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := make([]Event, 10)
+			for i := range events {
+				events[i] = Event{ID: fmt.Sprint(i), Operation: "insert", Data: map[string]interface{}{"n": i}}
+			}
+			sink := NewMockSink()
+			dlq := &collectingDLQ{}
+			p := New("test", NewMockSource(events), sink, nil, nil)
+			p.SetDeadLetterQueue(dlq)
+			if err := p.SetErrorPolicy(ErrorPolicy{OnTransformError: TransformErrorDLQ}); err != nil {
+				t.Fatalf("SetErrorPolicy() error = %v", err)
+			}
+			p.SetChaos(tt.chaos)
+
+			if err := p.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if len(dlq.events) != tt.wantDLQ {
+				t.Errorf("expected %d dead-lettered events, got %d", tt.wantDLQ, len(dlq.events))
+			}
+			if len(sink.received) != tt.wantReceived {
+				t.Fatalf("expected %d events written, got %d", tt.wantReceived, len(sink.received))
+			}
+			// Faults never reorder events
+			step := tt.wantReceived / len(events)
+			for i, e := range sink.received {
+				if want := fmt.Sprint(i / max(step, 1)); e.ID != want {
+					t.Fatalf("event %d has ID %s, want %s", i, e.ID, want)
+				}
+			}
+		})
+	}
+}
+
+// TestChaosDuplicateCopiesData tests that duplicates do not share their Data map
+func TestChaosDuplicateCopiesData(t *testing.T) {
+	p := New("test", NewMockSource(nil), NewMockSink(), nil, nil)
+	p.SetChaos(Chaos{DuplicateRate: 1, Seed: 1})
+	events := p.chaos.duplicate([]Event{{ID: "1", Data: map[string]interface{}{"a": 1}}})
+	if len(events) != 2 {
+		t.Fatalf("expected the event and its duplicate, got %d events", len(events))
+	}
+	events[1].Data["a"] = 2
+	if events[0].Data["a"] != 1 {
+		t.Error("expected the duplicate to have its own Data map")
+	}
+}
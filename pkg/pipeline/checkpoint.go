@@ -0,0 +1,256 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SetCheckpointStore sets the store used to persist the source position after sink commits
+func (p *Pipeline) SetCheckpointStore(store CheckpointStore) {
+	p.checkpoints = store
+}
+
+// StartPoint is where an operator makes the source start, for example to
+// backfill exactly the changes made since an outage began. Set Position or Time.
+type StartPoint struct {
+	Position string    // position to start after, as reported in Event.Position
+	Time     time.Time // start with the changes made at or after this time; requires a TimeSeeker source
+}
+
+// SetStartPoint makes the next run start at start instead of the position
+// saved in the write-ahead log or checkpoint. It applies once: later runs,
+// such as those after a reconnect, resume from the saved position again.
+func (p *Pipeline) SetStartPoint(start StartPoint) {
+	p.start = &start
+}
+
+// seekStart points the source at the operator's start point
+func (p *Pipeline) seekStart() error {
+	start := *p.start
+	p.start = nil
+	if start.Position != "" {
+		resumable, ok := p.source.(Resumable)
+		if !ok {
+			return fmt.Errorf("source cannot start at a position")
+		}
+		p.resumedFrom = start.Position
+		p.logger.Printf("Starting source after operator-supplied position %s", start.Position)
+		if err := resumable.SetStartPosition(start.Position); err != nil {
+			return fmt.Errorf("failed to set start position: %w", err)
+		}
+		return nil
+	}
+	seeker, ok := p.source.(TimeSeeker)
+	if !ok {
+		return fmt.Errorf("source cannot start at a point in time")
+	}
+	p.logger.Printf("Starting source at %s", start.Time.Format(time.RFC3339))
+	if err := seeker.SetStartTime(start.Time); err != nil {
+		return fmt.Errorf("failed to set start time: %w", err)
+	}
+	return nil
+}
+
+// restorePosition points a resumable source at the position to continue from:
+// the operator's start point if one is set, otherwise the tail of the
+// write-ahead log if it holds events, otherwise the last checkpoint
+func (p *Pipeline) restorePosition(ctx context.Context) error {
+	if p.start != nil {
+		return p.seekStart()
+	}
+	resumable, ok := p.source.(Resumable)
+	if !ok {
+		return nil
+	}
+
+	var position, origin string
+	if p.wal != nil {
+		if last, ok := p.wal.Last(); ok && last.Position != "" {
+			position, origin = last.Position, "write-ahead log"
+		}
+	}
+	if position == "" && p.checkpoints != nil {
+		cp, err := p.checkpoints.Load(ctx, p.name)
+		if err != nil {
+			return fmt.Errorf("failed to load checkpoint: %w", err)
+		}
+		if cp != nil && cp.Position != "" {
+			position, origin = cp.Position, "checkpoint"
+		}
+	}
+	if position == "" {
+		return nil
+	}
+
+	p.resumedFrom = position
+	p.logger.Printf("Resuming source from %s position", origin)
+	if err := resumable.SetStartPosition(position); err != nil {
+		return fmt.Errorf("failed to resume source: %w", err)
+	}
+	return nil
+}
+
+// ResetPosition forgets the saved source position, so the next run captures
+// changes from the current point in time. Used after a full resync.
+func (p *Pipeline) ResetPosition(ctx context.Context) error {
+	if p.checkpoints != nil {
+		cp := Checkpoint{Pipeline: p.name, UpdatedAt: p.clock.Now()}
+		if err := p.checkpoints.Save(ctx, cp); err != nil {
+			return fmt.Errorf("failed to reset checkpoint: %w", err)
+		}
+	}
+	if resumable, ok := p.source.(Resumable); ok {
+		if err := resumable.SetStartPosition(""); err != nil {
+			return fmt.Errorf("failed to reset source position: %w", err)
+		}
+	}
+	return nil
+}
+
+// onCommit is called by the sink after every batch. The batch's events are
+// audited and released from the memory budget; once committed they are also
+// released from the write-ahead log and the checkpoint advances to the last
+// event's position. A failed batch releases nothing: it and every later batch
+// stay in the log, and the checkpoint stays before it, so they are replayed
+// on the next run, unless the error policy dead-letters failed batches.
+func (p *Pipeline) onCommit(events []Event, err error) {
+	p.settleBatch(events, events, err)
+}
+
+// settleBatch handles the outcome of a batch, of which the sink wrote the
+// events in written unless err is set
+func (p *Pipeline) settleBatch(events, written []Event, err error) {
+	p.releaseCommitted(len(events))
+	p.committed.Add(int64(len(events)))
+	// Dead-lettered events are done with, so commits carry on past them.
+	// Batches abandoned at shutdown are replayed instead.
+	deadLettered := err != nil && p.errorPolicy.OnSinkError == SinkErrorDLQ && !errors.Is(err, context.Canceled)
+	if deadLettered {
+		p.deadLetterBatch(events, err)
+		err = nil
+	}
+	if err == nil {
+		p.resetSinkErrors()
+	}
+	held := p.holdCommits(err)
+	p.commitBuffer(len(events), held)
+
+	if !deadLettered {
+		if err != nil {
+			p.tracer.Committed(events, err)
+			p.logger.Printf("Batch of %d events failed, holding it for replay: %v", len(events), err)
+			p.auditEvents(context.Background(), AuditFailed, err.Error(), events)
+		} else {
+			p.tracer.Committed(written, nil)
+			p.auditEvents(context.Background(), AuditWritten, "", written)
+			p.observeLatency(written)
+			p.observeSinkLatency(written)
+			p.resources.Written(written)
+			if p.hooks.OnBatchCommitted != nil {
+				p.hooks.OnBatchCommitted(written)
+			}
+		}
+	}
+	if held {
+		return
+	}
+
+	if p.wal != nil {
+		if err := p.wal.Commit(len(events)); err != nil {
+			p.logger.Printf("Failed to commit write-ahead log: %v", err)
+			p.recordError("wal", "commit_error", err)
+		}
+	}
+
+	var position string
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Position != "" {
+			position = events[i].Position
+			break
+		}
+	}
+	if position != "" {
+		p.ackSource(position)
+	}
+
+	if p.checkpoints == nil {
+		return
+	}
+	if p.transactionalCheckpoints() != nil {
+		// Already committed atomically with the batch
+		if p.opMetrics != nil {
+			p.opMetrics.SetLastCheckpoint(p.name, p.clock.Now())
+		}
+		return
+	}
+	if position != "" {
+		p.saveCheckpoint(position)
+	}
+}
+
+// ackSource acknowledges a committed position to the source, if it wants to know
+func (p *Pipeline) ackSource(position string) {
+	acknowledger, ok := p.source.(Acknowledger)
+	if !ok {
+		return
+	}
+	if err := acknowledger.Ack(context.Background(), position); err != nil {
+		p.logger.Printf("Failed to acknowledge position %s to the source: %v", position, err)
+		p.recordError("source", "ack_error", err)
+	}
+}
+
+// saveCheckpoint saves the source position to continue from
+func (p *Pipeline) saveCheckpoint(position string) {
+	cp := Checkpoint{Pipeline: p.name, Position: position, UpdatedAt: p.clock.Now()}
+	if err := p.checkpoints.Save(context.Background(), cp); err != nil {
+		p.logger.Printf("Failed to save checkpoint: %v", err)
+		p.recordError("checkpoint", "save_error", err)
+		return
+	}
+	if p.opMetrics != nil {
+		p.opMetrics.SetLastCheckpoint(p.name, cp.UpdatedAt)
+	}
+}
+
+// holdCommits reports whether commits are held for the rest of the run because
+// this or an earlier batch failed. Releasing a later batch would move the
+// checkpoint, the write-ahead log and the spill buffer past the failed events.
+func (p *Pipeline) holdCommits(err error) bool {
+	p.commitMu.Lock()
+	defer p.commitMu.Unlock()
+	if err != nil && !p.commitsHeld {
+		p.logger.Printf("Holding commits after a failed batch, undelivered events are replayed on restart")
+		p.commitsHeld = true
+		if tx := p.transactionalCheckpoints(); tx != nil {
+			// Later batches commit in the sink's own transactions, which must
+			// not move the checkpoint past the failed events either
+			tx.HoldCheckpoints(true)
+		}
+	}
+	return p.commitsHeld
+}
+
+// transactionalCheckpoints returns the checkpoint store if the sink commits
+// the checkpoint with each batch, or nil
+func (p *Pipeline) transactionalCheckpoints() TransactionalCheckpointStore {
+	if tx, ok := p.checkpoints.(TransactionalCheckpointStore); ok && tx.CheckpointsInTransaction() {
+		return tx
+	}
+	return nil
+}
+
+// resetCommits clears the commit tracking left over from a previous run
+func (p *Pipeline) resetCommits() {
+	p.commitMu.Lock()
+	p.commitsHeld = false
+	if tx := p.transactionalCheckpoints(); tx != nil {
+		tx.HoldCheckpoints(false)
+	}
+	p.commitMu.Unlock()
+	p.bufferMu.Lock()
+	p.bufferInflight = nil
+	p.bufferMu.Unlock()
+}
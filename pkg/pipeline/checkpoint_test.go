@@ -0,0 +1,253 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryCheckpointStore is an in-memory CheckpointStore for testing
+type memoryCheckpointStore struct {
+	mu     sync.Mutex
+	saved  map[string]Checkpoint
+	writes int
+}
+
+func newMemoryCheckpointStore() *memoryCheckpointStore {
+	return &memoryCheckpointStore{saved: make(map[string]Checkpoint)}
+}
+
+func (m *memoryCheckpointStore) Load(ctx context.Context, pipeline string) (*Checkpoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	cp, ok := m.saved[pipeline]
+	if !ok {
+		return nil, nil
+	}
+	return &cp, nil
+}
+
+func (m *memoryCheckpointStore) Save(ctx context.Context, cp Checkpoint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.saved[cp.Pipeline] = cp
+	m.writes++
+	return nil
+}
+
+// resumableSource is a mock source that records its start position
+type resumableSource struct {
+	MockSource
+	startPosition string
+}
+
+func (r *resumableSource) SetStartPosition(position string) error {
+	r.startPosition = position
+	return nil
+}
+
+// commitSink is a mock sink that writes in batches and reports commits
+type commitSink struct {
+	MockSink
+	batchSize int
+	onCommit  CommitHandler
+	failFrom  int // batches from this one (1-based) on are reported as failed; 0 never fails
+	batches   int
+}
+
+func (c *commitSink) SetCommitHandler(handler CommitHandler) {
+	c.onCommit = handler
+}
+
+func (c *commitSink) Write(ctx context.Context, events <-chan Event) <-chan error {
+	errors := make(chan error)
+	go func() {
+		defer close(errors)
+		var batch []Event
+		flush := func() {
+			if len(batch) == 0 {
+				return
+			}
+			c.batches++
+			var err error
+			if c.failFrom > 0 && c.batches >= c.failFrom {
+				err = fmt.Errorf("batch %d failed", c.batches)
+			} else {
+				c.received = append(c.received, batch...)
+			}
+			if c.onCommit != nil {
+				c.onCommit(batch, err)
+			}
+			batch = nil
+		}
+		for event := range events {
+			batch = append(batch, event)
+			if len(batch) >= c.batchSize {
+				flush()
+			}
+		}
+		flush()
+	}()
+	return errors
+}
+
+// TestPipelineCheckpointsAfterCommit tests that the checkpoint follows sink commits
+func TestPipelineCheckpointsAfterCommit(t *testing.T) {
+	events := []Event{
+		{ID: "1", Operation: "insert", Position: "p1"},
+		{ID: "2", Operation: "insert", Position: "p2"},
+		{ID: "3", Operation: "insert", Position: "p3"},
+	}
+	source := &resumableSource{MockSource: *NewMockSource(events)}
+	sink := &commitSink{batchSize: 2}
+	store := newMemoryCheckpointStore()
+	store.saved["test"] = Checkpoint{Pipeline: "test", Position: "p0"}
+
+	p := New("test", source, sink, nil, nil)
+	p.SetCheckpointStore(store)
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if source.startPosition != "p0" {
+		t.Errorf("expected source to resume from p0, got %q", source.startPosition)
+	}
+	if got := store.saved["test"].Position; got != "p3" {
+		t.Errorf("expected checkpoint p3, got %q", got)
+	}
+	if store.writes != 2 {
+		t.Errorf("expected one checkpoint write per batch (2), got %d", store.writes)
+	}
+}
+
+// seekableSource is a mock source that records its start time
+type seekableSource struct {
+	resumableSource
+	startTime time.Time
+}
+
+func (s *seekableSource) SetStartTime(t time.Time) error {
+	s.startTime = t
+	return nil
+}
+
+// TestPipelineStartPoint tests that an operator's start point replaces the
+// checkpoint for one run only
+func TestPipelineStartPoint(t *testing.T) {
+	at := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		start        StartPoint
+		wantPosition string
+		wantTime     time.Time
+	}{
+		{name: "position", start: StartPoint{Position: "p5"}, wantPosition: "p5"},
+		{name: "time", start: StartPoint{Time: at}, wantTime: at},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &seekableSource{resumableSource: resumableSource{MockSource: *NewMockSource(nil)}}
+			store := newMemoryCheckpointStore()
+			store.saved["test"] = Checkpoint{Pipeline: "test", Position: "p0"}
+			p := New("test", source, &commitSink{batchSize: 1}, nil, nil)
+			p.SetCheckpointStore(store)
+			p.SetStartPoint(tt.start)
+
+			if err := p.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if source.startPosition != tt.wantPosition || !source.startTime.Equal(tt.wantTime) {
+				t.Errorf("expected start at %q/%v, got %q/%v", tt.wantPosition, tt.wantTime, source.startPosition, source.startTime)
+			}
+
+			// The next run resumes from the checkpoint again
+			if err := p.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if source.startPosition != "p0" {
+				t.Errorf("expected the second run to resume from p0, got %q", source.startPosition)
+			}
+		})
+	}
+
+	// A start time requires a source that can seek by time
+	p := New("test", &resumableSource{MockSource: *NewMockSource(nil)}, NewMockSink(), nil, nil)
+	p.SetStartPoint(StartPoint{Time: at})
+	if err := p.Run(context.Background()); err == nil {
+		t.Error("expected error for a source that cannot start at a time")
+	}
+}
+
+// TestPipelineCheckpointWithoutNotifier tests that a non-reporting sink still runs
+func TestPipelineCheckpointWithoutNotifier(t *testing.T) {
+	store := newMemoryCheckpointStore()
+	sink := NewMockSink()
+	p := New("test", NewMockSource([]Event{{ID: "1", Position: "p1"}}), sink, nil, nil)
+	p.SetCheckpointStore(store)
+
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(sink.received) != 1 {
+		t.Errorf("expected 1 event, got %d", len(sink.received))
+	}
+	if store.writes != 0 {
+		t.Errorf("expected no checkpoint writes, got %d", store.writes)
+	}
+}
+
+// transactionalStore is a checkpoint store whose checkpoints commit with sink batches
+type transactionalStore struct {
+	*memoryCheckpointStore
+	held bool
+}
+
+func (t *transactionalStore) CheckpointsInTransaction() bool { return true }
+
+func (t *transactionalStore) HoldCheckpoints(held bool) { t.held = held }
+
+// TestPipelineSkipsSaveForTransactionalStore tests that successful batches are not checkpointed twice
+func TestPipelineSkipsSaveForTransactionalStore(t *testing.T) {
+	store := &transactionalStore{memoryCheckpointStore: newMemoryCheckpointStore()}
+	p := New("test", NewMockSource(nil), NewMockSink(), nil, nil)
+	p.SetCheckpointStore(store)
+
+	p.onCommit([]Event{{ID: "1", Position: "p1"}}, nil)
+	if store.writes != 0 {
+		t.Errorf("expected no separate checkpoint write, got %d", store.writes)
+	}
+
+	p.onCommit([]Event{{ID: "2", Position: "p2"}}, errors.New("batch failed"))
+	if store.writes != 0 {
+		t.Errorf("expected failed batch not to be checkpointed, got %d writes", store.writes)
+	}
+}
+
+// TestPipelineNeverCheckpointsFailedBatchInTransaction tests that a failed
+// batch never reaches the transactional store's separate Save, which would
+// move the checkpoint past rows that were rolled back
+func TestPipelineNeverCheckpointsFailedBatchInTransaction(t *testing.T) {
+	events := []Event{
+		{ID: "1", Position: "p1"},
+		{ID: "2", Position: "p2"},
+		{ID: "3", Position: "p3"},
+	}
+	store := &transactionalStore{memoryCheckpointStore: newMemoryCheckpointStore()}
+	sink := &commitSink{batchSize: 1, failFrom: 2}
+
+	p := New("test", NewMockSource(events), sink, nil, nil)
+	p.SetCheckpointStore(store)
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if store.writes != 0 {
+		t.Errorf("expected no checkpoint saved outside the batch transaction, got %d (position %q)", store.writes, store.saved["test"].Position)
+	}
+	if !store.held {
+		t.Error("expected the sink to stop committing checkpoints after the failed batch")
+	}
+}
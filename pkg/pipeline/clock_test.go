@@ -0,0 +1,49 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+)
+
+// TestManualClock tests that timers fire only once the clock is advanced past them
+func TestManualClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewManualClock(start)
+
+	short := clock.After(time.Second)
+	long := clock.After(time.Minute)
+	if clock.Waiters() != 2 {
+		t.Fatalf("expected 2 waiters, got %d", clock.Waiters())
+	}
+
+	clock.Advance(30 * time.Second)
+	select {
+	case fired := <-short:
+		if !fired.Equal(start.Add(30 * time.Second)) {
+			t.Errorf("timer fired at %v", fired)
+		}
+	default:
+		t.Fatal("expected short timer to fire")
+	}
+	select {
+	case <-long:
+		t.Fatal("long timer fired early")
+	default:
+	}
+
+	clock.Advance(30 * time.Second)
+	select {
+	case <-long:
+	default:
+		t.Fatal("expected long timer to fire")
+	}
+
+	if got := clock.Since(start); got != time.Minute {
+		t.Errorf("Since() = %v, want 1m", got)
+	}
+	select {
+	case <-clock.After(0):
+	default:
+		t.Error("expected zero-duration timer to fire immediately")
+	}
+}
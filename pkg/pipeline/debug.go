@@ -0,0 +1,125 @@
+package pipeline
+
+import (
+	"sync"
+	"time"
+)
+
+// debugHistory is how many recent events and errors the debug dump keeps
+const debugHistory = 20
+
+// Stage states of the goroutine moving events from the source to the sink
+const (
+	StageStopped      = "stopped"
+	StageReading      = "waiting for source"
+	StageTransforming = "transforming"
+	StageReserving    = "waiting for memory budget"
+	StageThrottled    = "throttled"
+	StageHandoff      = "waiting for sink"
+)
+
+// EventSummary identifies an event seen by the pipeline, without its data
+type EventSummary struct {
+	ID         string    `json:"id"`
+	Operation  string    `json:"operation"`
+	Collection string    `json:"collection,omitempty"`
+	SourceTime time.Time `json:"source_time,omitempty"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+// ErrorRecord is a runtime error recorded by the pipeline
+type ErrorRecord struct {
+	Time      time.Time `json:"time"`
+	Component string    `json:"component"`
+	Type      string    `json:"type"`
+	Message   string    `json:"message"`
+}
+
+// DebugState is a dump of a pipeline's internal state for diagnosing a stuck
+// or misbehaving pipeline
+type DebugState struct {
+	Pipeline   string         `json:"pipeline"`
+	Running    bool           `json:"running"`
+	Stage      string         `json:"stage"`  // what the event-moving goroutine is doing
+	Queues     map[string]int `json:"queues"` // events waiting between stages
+	Processed  int64          `json:"processed"`
+	Committed  int64          `json:"committed"`
+	Rejected   int64          `json:"rejected"`
+	Errors     int64          `json:"errors"`
+	Resources  *ResourceUsage `json:"resources,omitempty"`
+	LastEvents []EventSummary `json:"last_events"` // oldest first
+	LastErrors []ErrorRecord  `json:"last_errors"` // oldest first
+}
+
+// debugLog keeps the most recent events and errors for DebugState
+type debugLog struct {
+	mu     sync.Mutex
+	events []EventSummary
+	errors []ErrorRecord
+}
+
+// addEvent records an event received from the source
+func (d *debugLog) addEvent(event Event, receivedAt time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.events) == debugHistory {
+		d.events = append(d.events[:0], d.events[1:]...)
+	}
+	d.events = append(d.events, EventSummary{
+		ID:         event.ID,
+		Operation:  event.Operation,
+		Collection: event.Collection,
+		SourceTime: event.Timestamp,
+		ReceivedAt: receivedAt,
+	})
+}
+
+// addError records a runtime error
+func (d *debugLog) addError(record ErrorRecord) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.errors) == debugHistory {
+		d.errors = append(d.errors[:0], d.errors[1:]...)
+	}
+	d.errors = append(d.errors, record)
+}
+
+// snapshot returns copies of the recorded events and errors
+func (d *debugLog) snapshot() ([]EventSummary, []ErrorRecord) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]EventSummary{}, d.events...), append([]ErrorRecord{}, d.errors...)
+}
+
+// setStage records what the event-moving goroutine is doing
+func (p *Pipeline) setStage(stage string) {
+	p.stage.Store(stage)
+}
+
+// DebugState returns a dump of the pipeline's internal state
+func (p *Pipeline) DebugState() DebugState {
+	stage, _ := p.stage.Load().(string)
+	if stage == "" {
+		stage = StageStopped
+	}
+	events, errors := p.debug.snapshot()
+	stats := p.Stats()
+	var resources *ResourceUsage
+	if p.resources != nil {
+		usage := p.resources.Usage()
+		resources = &usage
+	}
+	return DebugState{
+		Pipeline:   p.name,
+		Running:    stats.Running,
+		Stage:      stage,
+		Queues:     p.queueDepths(),
+		Processed:  p.processed.Load(),
+		Committed:  p.committed.Load(),
+		Rejected:   p.rejected.Load(),
+		Errors:     stats.Errors,
+		Resources:  resources,
+		LastEvents: events,
+		LastErrors: errors,
+	}
+}
@@ -0,0 +1,33 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// TestPipelineDebugState tests that the debug dump records recent events and errors
+func TestPipelineDebugState(t *testing.T) {
+	var events []Event
+	for i := 0; i < debugHistory+5; i++ {
+		events = append(events, Event{ID: fmt.Sprint(i), Operation: "insert"})
+	}
+	p := New("test", NewMockSource(events), NewMockSink(), rejectTransformer{id: "3"}, nil)
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	state := p.DebugState()
+	if state.Stage != StageStopped || state.Running {
+		t.Errorf("expected a stopped pipeline, got stage %q, running %v", state.Stage, state.Running)
+	}
+	if len(state.LastEvents) != debugHistory || state.LastEvents[0].ID != "5" || state.LastEvents[debugHistory-1].ID != fmt.Sprint(debugHistory+4) {
+		t.Errorf("expected the last %d events oldest first, got %+v", debugHistory, state.LastEvents)
+	}
+	if len(state.LastErrors) != 1 || state.LastErrors[0].Component != "transformer" || state.LastErrors[0].Message != "cannot transform 3" {
+		t.Errorf("unexpected errors %+v", state.LastErrors)
+	}
+	if state.Rejected != 1 || state.Errors != 1 {
+		t.Errorf("expected 1 rejected event and 1 error, got %d and %d", state.Rejected, state.Errors)
+	}
+}
@@ -0,0 +1,65 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// Shutdown order
+//
+// Cancelling the context passed to Run stops the pipeline in stream order:
+//
+//  1. The source stops reading. It selects on ctx.Done whenever it sends, so
+//     it never blocks on a stage that has stopped receiving, and closes its
+//     events and errors channels when done.
+//  2. The stages between source and sink (transform, spill buffer or WAL,
+//     sink worker dispatch) stop taking new events, hand on or store what they
+//     hold, and close their output.
+//  3. The sink keeps receiving until its input is closed, so no stage blocks
+//     on it. Events it has not written are reported as failed through the
+//     commit handler, so they are not checkpointed and are replayed on the
+//     next run, then it closes its errors channel.
+//  4. Run returns once the source and sink errors channels are closed, after
+//     which both are closed.
+//
+// Without a drain timeout the stages see the same cancelled context as the
+// source, so in-flight events are left for the next run. With one, they run
+// on until the source's channel is closed and everything it had read has been
+// written, or the timeout expires.
+
+// SetDrainTimeout lets the stages after the source finish writing the events
+// already read when Run's context is cancelled, for up to d (0 disables). A run
+// stopped by an operation or error policy does not drain.
+func (p *Pipeline) SetDrainTimeout(d time.Duration) {
+	p.drainTimeout = d
+}
+
+// drainContext returns the context of the stages after the source. It is
+// cancelled with ctx when the run fails or nothing is drained, and otherwise
+// once the drain timeout expires after parent is cancelled.
+func (p *Pipeline) drainContext(parent, ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.drainTimeout <= 0 {
+		return ctx, func() {}
+	}
+	stages, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	go func() {
+		select {
+		case <-stages.Done():
+			return
+		case <-ctx.Done():
+		}
+		if parent.Err() == nil {
+			// Stopped by a policy, not shut down
+			cancel()
+			return
+		}
+		p.logger.Printf("Draining in-flight events for up to %s", p.drainTimeout)
+		select {
+		case <-stages.Done():
+		case <-p.clock.After(p.drainTimeout):
+			p.logger.Printf("Drain timed out after %s, unwritten events are replayed on the next run", p.drainTimeout)
+			cancel()
+		}
+	}()
+	return stages, cancel
+}
@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// holdingSource sends its events, then holds the stream open until ctx is
+// cancelled, like a change stream waiting for changes
+type holdingSource struct {
+	MockSource
+	closeEvents bool // false leaves the events channel open after stopping
+}
+
+func (h *holdingSource) Read(ctx context.Context) (<-chan Event, <-chan error) {
+	events := make(chan Event)
+	errors := make(chan error)
+	go func() {
+		defer close(errors)
+		if h.closeEvents {
+			defer close(events)
+		}
+		for _, event := range h.events {
+			select {
+			case <-ctx.Done():
+				return
+			case events <- event:
+			}
+		}
+		<-ctx.Done()
+	}()
+	return events, errors
+}
+
+// shutdownSink writes the events it receives in a single batch once its input
+// is closed, and abandons them if ctx was cancelled by then
+type shutdownSink struct {
+	MockSink
+	want    int
+	arrived chan struct{} // closed once want events have been received
+}
+
+func (s *shutdownSink) Write(ctx context.Context, events <-chan Event) <-chan error {
+	errors := make(chan error)
+	go func() {
+		defer close(errors)
+		var batch []Event
+		for event := range events {
+			batch = append(batch, event)
+			if len(batch) == s.want {
+				close(s.arrived)
+			}
+		}
+		if ctx.Err() == nil {
+			s.received = batch
+		}
+	}()
+	return errors
+}
+
+// TestPipelineShutdown tests that Run returns once cancelled, writing the
+// events already read only while draining
+func TestPipelineShutdown(t *testing.T) {
+	tests := []struct {
+		name        string
+		closeEvents bool
+		drain       time.Duration
+		wantWritten int
+	}{
+		{name: "abandoned without drain", closeEvents: true},
+		{name: "drained", closeEvents: true, drain: time.Minute, wantWritten: 3},
+		{name: "source left open", closeEvents: false},
+		{name: "drain timeout", closeEvents: false, drain: 50 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &holdingSource{MockSource: MockSource{events: []Event{{ID: "1"}, {ID: "2"}, {ID: "3"}}}, closeEvents: tt.closeEvents}
+			sink := &shutdownSink{want: 3, arrived: make(chan struct{})}
+			p := New("test", source, sink, nil, nil)
+			p.SetDrainTimeout(tt.drain)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			done := make(chan error, 1)
+			go func() { done <- p.Run(ctx) }()
+			select {
+			case <-sink.arrived:
+			case <-time.After(5 * time.Second):
+				t.Fatal("events did not reach the sink")
+			}
+			cancel()
+
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("Run() error = %v", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Run did not return after its context was cancelled")
+			}
+			if len(sink.received) != tt.wantWritten {
+				t.Errorf("expected %d events written, got %d", tt.wantWritten, len(sink.received))
+			}
+		})
+	}
+}
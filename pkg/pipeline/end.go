@@ -0,0 +1,35 @@
+package pipeline
+
+import "time"
+
+// EndPoint bounds a change data capture run, such as the final catch-up pass
+// of a migration cutover. Once either bound is reached the pipeline stops
+// reading, writes the events already read, and Run returns nil.
+type EndPoint struct {
+	Time time.Time     // stop at the first change made at or after this time, which is not processed
+	Idle time.Duration // stop once no change has arrived for this long, i.e. the source has caught up
+}
+
+// SetEndPoint makes runs end at end; the zero EndPoint runs until stopped
+func (p *Pipeline) SetEndPoint(end EndPoint) {
+	p.end = end
+}
+
+// pastEnd reports whether an event from the source lies past the end time.
+// Heartbeats count, so an idle source still notices the time has come.
+func (p *Pipeline) pastEnd(event Event) bool {
+	if p.end.Time.IsZero() || event.Timestamp.IsZero() || event.Timestamp.Before(p.end.Time) {
+		return false
+	}
+	p.logger.Printf("Source reached the end time %s, ending the run", p.end.Time.Format(time.RFC3339))
+	return true
+}
+
+// idleTimeout returns a channel receiving once no change has arrived for the
+// idle bound since lastChange, or nil without one
+func (p *Pipeline) idleTimeout(lastChange time.Time) <-chan time.Time {
+	if p.end.Idle <= 0 {
+		return nil
+	}
+	return p.clock.After(p.end.Idle - p.clock.Since(lastChange))
+}
@@ -0,0 +1,58 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestPipelineEndPoint tests that a run ends without error at the end time or
+// once the source is idle, after writing what it read before
+func TestPipelineEndPoint(t *testing.T) {
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	events := []Event{
+		{ID: "1", Operation: "insert", Timestamp: base},
+		{ID: "2", Operation: "insert", Timestamp: base.Add(time.Minute)},
+		{Operation: OperationHeartbeat, Timestamp: base.Add(2 * time.Minute)},
+		{ID: "3", Operation: "insert", Timestamp: base.Add(3 * time.Minute)},
+	}
+	tests := []struct {
+		name    string
+		end     EndPoint
+		wantIDs []string
+	}{
+		{name: "change past the end", end: EndPoint{Time: base.Add(time.Minute)}, wantIDs: []string{"1"}},
+		{name: "heartbeat past the end", end: EndPoint{Time: base.Add(90 * time.Second)}, wantIDs: []string{"1", "2"}},
+		{name: "idle", end: EndPoint{Idle: 20 * time.Millisecond}, wantIDs: []string{"1", "2", "3"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The source never ends by itself
+			source := &stallingSource{batches: [][]Event{events}}
+			sink := NewMockSink()
+			p := New("test", source, sink, nil, nil)
+			p.SetEndPoint(tt.end)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := p.Run(ctx); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if ctx.Err() != nil {
+				t.Fatal("expected the run to end before the timeout")
+			}
+			var got []string
+			for _, event := range sink.received {
+				got = append(got, event.ID)
+			}
+			if len(got) != len(tt.wantIDs) {
+				t.Fatalf("expected events %v, got %v", tt.wantIDs, got)
+			}
+			for i := range got {
+				if got[i] != tt.wantIDs[i] {
+					t.Errorf("expected events %v, got %v", tt.wantIDs, got)
+				}
+			}
+		})
+	}
+}
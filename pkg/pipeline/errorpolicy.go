@@ -0,0 +1,249 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrErrorBudgetExhausted is returned by Run when the error policy's
+// thresholds stop the pipeline
+var ErrErrorBudgetExhausted = errors.New("error budget exhausted")
+
+// TransformErrorAction determines what happens to an event the transformer fails
+type TransformErrorAction string
+
+const (
+	// TransformErrorSkip logs and drops the event (default)
+	TransformErrorSkip TransformErrorAction = "skip"
+	// TransformErrorDLQ routes the event to the dead-letter queue
+	TransformErrorDLQ TransformErrorAction = "dlq"
+	// TransformErrorFail stops the pipeline with the error
+	TransformErrorFail TransformErrorAction = "fail"
+)
+
+// ParseTransformErrorAction parses a transform error action from configuration
+func ParseTransformErrorAction(name string) (TransformErrorAction, error) {
+	switch TransformErrorAction(name) {
+	case "":
+		return TransformErrorSkip, nil
+	case TransformErrorSkip, TransformErrorDLQ, TransformErrorFail:
+		return TransformErrorAction(name), nil
+	default:
+		return "", fmt.Errorf("unsupported transform error action: %s", name)
+	}
+}
+
+// SinkErrorAction determines what happens when the sink fails to write a batch
+type SinkErrorAction string
+
+const (
+	// SinkErrorRetry leaves retries to the sink and holds a failed batch for
+	// replay on restart, carrying on with later events (default)
+	SinkErrorRetry SinkErrorAction = "retry"
+	// SinkErrorDLQ routes the events of a failed batch to the dead-letter
+	// queue, so later batches are committed and checkpointed past them
+	SinkErrorDLQ SinkErrorAction = "dlq"
+	// SinkErrorFail stops the pipeline with the first sink error
+	SinkErrorFail SinkErrorAction = "fail"
+)
+
+// ParseSinkErrorAction parses a sink error action from configuration
+func ParseSinkErrorAction(name string) (SinkErrorAction, error) {
+	switch SinkErrorAction(name) {
+	case "":
+		return SinkErrorRetry, nil
+	case SinkErrorRetry, SinkErrorDLQ, SinkErrorFail:
+		return SinkErrorAction(name), nil
+	default:
+		return "", fmt.Errorf("unsupported sink error action: %s", name)
+	}
+}
+
+// minErrorRateEvents is how many events a run (or window) must have seen
+// before its error rate is checked, so a few early failures do not stop it
+const minErrorRateEvents = 100
+
+// errorRateBuckets is how many buckets the error rate window is divided into
+const errorRateBuckets = 10
+
+// ErrorPolicy determines how the pipeline reacts to failed events, trading
+// availability for correctness
+type ErrorPolicy struct {
+	OnTransformError TransformErrorAction
+	OnSinkError      SinkErrorAction
+	// MaxErrorRate stops the pipeline once more than this fraction (0 to 1) of
+	// a run's events have been rejected (0 disables)
+	MaxErrorRate float64
+	// ErrorRateWindow limits MaxErrorRate to the events of a sliding window,
+	// e.g. the last 5 minutes, rather than the whole run
+	ErrorRateWindow time.Duration
+	// MaxConsecutiveSinkErrors stops the pipeline after this many sink errors
+	// without a batch committed in between (0 disables)
+	MaxConsecutiveSinkErrors int
+}
+
+// SetErrorPolicy sets how the pipeline reacts to failed events. Without one,
+// transform failures are skipped and sink failures are left to the sink.
+func (p *Pipeline) SetErrorPolicy(policy ErrorPolicy) error {
+	var err error
+	if policy.OnTransformError, err = ParseTransformErrorAction(string(policy.OnTransformError)); err != nil {
+		return err
+	}
+	if policy.OnSinkError, err = ParseSinkErrorAction(string(policy.OnSinkError)); err != nil {
+		return err
+	}
+	if policy.MaxErrorRate < 0 || policy.MaxErrorRate > 1 {
+		return fmt.Errorf("max error rate must be between 0 and 1, got %v", policy.MaxErrorRate)
+	}
+	if policy.ErrorRateWindow < 0 || policy.MaxConsecutiveSinkErrors < 0 {
+		return fmt.Errorf("error rate window and max consecutive sink errors cannot be negative")
+	}
+	p.errorPolicy = policy
+	return nil
+}
+
+// errorBudget tracks failures against the error policy's thresholds
+type errorBudget struct {
+	mu          sync.Mutex
+	buckets     []errorBucket // oldest first, covering the error rate window
+	consecutive int           // sink errors since the last committed batch
+	exhausted   string        // why the budget ran out, empty while it has not
+}
+
+// errorBucket counts the events of a slice of the error rate window
+type errorBucket struct {
+	start    time.Time
+	events   int64
+	rejected int64
+}
+
+// reset clears the budget at the start of a run
+func (b *errorBudget) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buckets = nil
+	b.consecutive = 0
+	b.exhausted = ""
+}
+
+// record counts an event in the window ending now and returns the window's
+// rejected and total events
+func (b *errorBudget) record(now time.Time, window time.Duration, rejected bool) (int64, int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	expired := 0
+	for expired < len(b.buckets) && now.Sub(b.buckets[expired].start) >= window {
+		expired++
+	}
+	b.buckets = b.buckets[expired:]
+	if n := len(b.buckets); n == 0 || now.Sub(b.buckets[n-1].start) >= window/errorRateBuckets {
+		b.buckets = append(b.buckets, errorBucket{start: now})
+	}
+	last := &b.buckets[len(b.buckets)-1]
+	last.events++
+	if rejected {
+		last.rejected++
+	}
+
+	var events, rejectedEvents int64
+	for _, bucket := range b.buckets {
+		events += bucket.events
+		rejectedEvents += bucket.rejected
+	}
+	return rejectedEvents, events
+}
+
+// exhaustedReason returns why the budget ran out, or an empty string
+func (b *errorBudget) exhaustedReason() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.exhausted
+}
+
+// countRejected counts a rejected event and stops the run once the error rate
+// exceeds the policy's maximum
+func (p *Pipeline) countRejected() {
+	rejected := p.rejected.Add(1)
+	if p.errorPolicy.MaxErrorRate == 0 {
+		return
+	}
+	if p.errorPolicy.ErrorRateWindow > 0 {
+		p.checkErrorRate(p.budget.record(p.clock.Now(), p.errorPolicy.ErrorRateWindow, true))
+		return
+	}
+	p.checkErrorRate(rejected, rejected+p.processed.Load())
+}
+
+// countProcessed counts an event handed to the sink
+func (p *Pipeline) countProcessed() {
+	p.processed.Add(1)
+	if p.errorPolicy.MaxErrorRate > 0 && p.errorPolicy.ErrorRateWindow > 0 {
+		p.budget.record(p.clock.Now(), p.errorPolicy.ErrorRateWindow, false)
+	}
+}
+
+// checkErrorRate stops the run if too many of the events seen were rejected
+func (p *Pipeline) checkErrorRate(rejected, seen int64) {
+	if seen < minErrorRateEvents {
+		return
+	}
+	if rate := float64(rejected) / float64(seen); rate > p.errorPolicy.MaxErrorRate {
+		over := "of the run"
+		if p.errorPolicy.ErrorRateWindow > 0 {
+			over = "over " + p.errorPolicy.ErrorRateWindow.String()
+		}
+		p.exhaustBudget(fmt.Errorf("%w: error rate %.1f%% %s exceeds the maximum of %.1f%% (%d of %d events rejected)",
+			ErrErrorBudgetExhausted, rate*100, over, p.errorPolicy.MaxErrorRate*100, rejected, seen))
+	}
+}
+
+// countSinkError counts a sink error and stops the run after too many in a row
+func (p *Pipeline) countSinkError() {
+	if p.errorPolicy.MaxConsecutiveSinkErrors == 0 {
+		return
+	}
+	p.budget.mu.Lock()
+	p.budget.consecutive++
+	consecutive := p.budget.consecutive
+	p.budget.mu.Unlock()
+	if consecutive >= p.errorPolicy.MaxConsecutiveSinkErrors {
+		p.exhaustBudget(fmt.Errorf("%w: %d consecutive sink errors", ErrErrorBudgetExhausted, consecutive))
+	}
+}
+
+// resetSinkErrors restarts the count of consecutive sink errors after a commit
+func (p *Pipeline) resetSinkErrors() {
+	p.budget.mu.Lock()
+	p.budget.consecutive = 0
+	p.budget.mu.Unlock()
+}
+
+// exhaustBudget stops the run and reports the pipeline unhealthy
+func (p *Pipeline) exhaustBudget(err error) {
+	p.budget.mu.Lock()
+	first := p.budget.exhausted == ""
+	if first {
+		p.budget.exhausted = err.Error()
+	}
+	p.budget.mu.Unlock()
+	if !first {
+		return
+	}
+	p.logger.Printf("Stopping pipeline: %v", err)
+	p.recordError("pipeline", "error_budget", err)
+	if p.stopRun != nil {
+		p.stopRun(err)
+	}
+}
+
+// deadLetterBatch routes the events of a batch the sink failed to write to the
+// dead-letter queue
+func (p *Pipeline) deadLetterBatch(events []Event, cause error) {
+	p.logger.Printf("Batch of %d events failed, dead-lettering it: %v", len(events), cause)
+	for _, event := range events {
+		p.deadLetter(context.Background(), event, "write_error", cause.Error())
+	}
+}
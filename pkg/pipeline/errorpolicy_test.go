@@ -0,0 +1,237 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// prefixRejectTransformer fails events whose ID has the given prefix
+type prefixRejectTransformer struct {
+	prefix string
+}
+
+func (r prefixRejectTransformer) Transform(ctx context.Context, event Event) (Event, error) {
+	if strings.HasPrefix(event.ID, r.prefix) {
+		return event, fmt.Errorf("cannot transform %s", event.ID)
+	}
+	return event, nil
+}
+
+// failingSink reports an error for every event it receives
+type failingSink struct {
+	MockSink
+}
+
+func (f *failingSink) Write(ctx context.Context, events <-chan Event) <-chan error {
+	errors := make(chan error)
+	go func() {
+		defer close(errors)
+		for event := range events {
+			select {
+			case errors <- fmt.Errorf("cannot write %s", event.ID):
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return errors
+}
+
+// TestSetErrorPolicy tests validation of error policies
+func TestSetErrorPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  ErrorPolicy
+		wantErr bool
+	}{
+		{name: "defaults", policy: ErrorPolicy{}},
+		{name: "all set", policy: ErrorPolicy{OnTransformError: TransformErrorDLQ, OnSinkError: SinkErrorFail, MaxErrorRate: 0.05}},
+		{name: "unsupported transform action", policy: ErrorPolicy{OnTransformError: "retry"}, wantErr: true},
+		{name: "unsupported sink action", policy: ErrorPolicy{OnSinkError: "skip"}, wantErr: true},
+		{name: "rate above one", policy: ErrorPolicy{MaxErrorRate: 5}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New("test", NewMockSource(nil), NewMockSink(), nil, nil)
+			if err := p.SetErrorPolicy(tt.policy); (err != nil) != tt.wantErr {
+				t.Errorf("SetErrorPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestPipelineTransformErrorPolicy tests the actions taken on transform failures
+func TestPipelineTransformErrorPolicy(t *testing.T) {
+	tests := []struct {
+		action       TransformErrorAction
+		wantErr      bool
+		wantDLQ      int
+		wantReceived int
+	}{
+		{action: TransformErrorSkip, wantReceived: 2},
+		{action: TransformErrorDLQ, wantDLQ: 1, wantReceived: 2},
+		{action: TransformErrorFail, wantErr: true, wantReceived: 1},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.action), func(t *testing.T) {
+			events := []Event{{ID: "1"}, {ID: "bad"}, {ID: "2"}}
+			sink := NewMockSink()
+			dlq := &collectingDLQ{}
+			p := New("test", NewMockSource(events), sink, prefixRejectTransformer{prefix: "bad"}, nil)
+			p.SetDeadLetterQueue(dlq)
+			if err := p.SetErrorPolicy(ErrorPolicy{OnTransformError: tt.action}); err != nil {
+				t.Fatalf("SetErrorPolicy() error = %v", err)
+			}
+
+			err := p.Run(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(dlq.events) != tt.wantDLQ {
+				t.Errorf("expected %d dead-lettered events, got %d", tt.wantDLQ, len(dlq.events))
+			}
+			if len(sink.received) != tt.wantReceived {
+				t.Errorf("expected %d events written, got %d", tt.wantReceived, len(sink.received))
+			}
+		})
+	}
+}
+
+// TestPipelineSinkErrorDLQ tests that failed batches are dead-lettered and the
+// checkpoint moves past them
+func TestPipelineSinkErrorDLQ(t *testing.T) {
+	events := []Event{
+		{ID: "1", Position: "p1"},
+		{ID: "2", Position: "p2"},
+		{ID: "3", Position: "p3"},
+	}
+	store := newMemoryCheckpointStore()
+	dlq := &collectingDLQ{}
+	p := New("test", NewMockSource(events), &commitSink{batchSize: 1, failFrom: 2}, nil, nil)
+	p.SetCheckpointStore(store)
+	p.SetDeadLetterQueue(dlq)
+	if err := p.SetErrorPolicy(ErrorPolicy{OnSinkError: SinkErrorDLQ}); err != nil {
+		t.Fatalf("SetErrorPolicy() error = %v", err)
+	}
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if len(dlq.events) != 2 {
+		t.Errorf("expected the 2 failed events to be dead-lettered, got %d", len(dlq.events))
+	}
+	if got := store.saved["test"].Position; got != "p3" {
+		t.Errorf("expected the checkpoint to reach p3, got %q", got)
+	}
+}
+
+// TestPipelineSinkErrorFail tests that a sink error stops the pipeline
+func TestPipelineSinkErrorFail(t *testing.T) {
+	p := New("test", NewMockSource([]Event{{ID: "1"}, {ID: "2"}}), &failingSink{}, nil, nil)
+	if err := p.SetErrorPolicy(ErrorPolicy{OnSinkError: SinkErrorFail}); err != nil {
+		t.Fatalf("SetErrorPolicy() error = %v", err)
+	}
+	if err := p.Run(context.Background()); err == nil || !strings.Contains(err.Error(), "cannot write 1") {
+		t.Errorf("expected the sink error to stop the pipeline, got %v", err)
+	}
+
+	// Requires commits to dead-letter batches
+	p = New("test", NewMockSource(nil), NewMockSink(), nil, nil)
+	p.SetErrorPolicy(ErrorPolicy{OnSinkError: SinkErrorDLQ})
+	if err := p.Run(context.Background()); err == nil {
+		t.Error("expected an error for a sink that does not report commits")
+	}
+}
+
+// TestPipelineMaxErrorRate tests that the pipeline stops once too many events are rejected
+func TestPipelineMaxErrorRate(t *testing.T) {
+	tests := []struct {
+		name    string
+		every   int // every nth event fails
+		wantErr bool
+	}{
+		{name: "below the maximum", every: 10},
+		{name: "above the maximum", every: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []Event
+			for i := 0; i < 300; i++ {
+				id := fmt.Sprint(i)
+				if i%tt.every == 0 {
+					id = "bad" + id
+				}
+				events = append(events, Event{ID: id})
+			}
+			p := New("test", NewMockSource(events), NewMockSink(), prefixRejectTransformer{prefix: "bad"}, nil)
+			if err := p.SetErrorPolicy(ErrorPolicy{MaxErrorRate: 0.2}); err != nil {
+				t.Fatalf("SetErrorPolicy() error = %v", err)
+			}
+			err := p.Run(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestPipelineConsecutiveSinkErrors tests that the pipeline stops and reports
+// unhealthy after too many sink errors in a row
+func TestPipelineConsecutiveSinkErrors(t *testing.T) {
+	var events []Event
+	for i := 0; i < 10; i++ {
+		events = append(events, Event{ID: fmt.Sprint(i)})
+	}
+	p := New("test", NewMockSource(events), &failingSink{}, nil, nil)
+	if err := p.SetErrorPolicy(ErrorPolicy{MaxConsecutiveSinkErrors: 3}); err != nil {
+		t.Fatalf("SetErrorPolicy() error = %v", err)
+	}
+	err := p.Run(context.Background())
+	if !errors.Is(err, ErrErrorBudgetExhausted) || !strings.Contains(err.Error(), "3 consecutive sink errors") {
+		t.Errorf("expected the error budget to stop the pipeline, got %v", err)
+	}
+	if p.IsHealthy() {
+		t.Error("expected the pipeline to report unhealthy once its error budget is exhausted")
+	}
+}
+
+// TestErrorBudgetWindow tests that the error rate only counts events of the window
+func TestErrorBudgetWindow(t *testing.T) {
+	var budget errorBudget
+	start := time.Unix(1700000000, 0)
+	for i := 0; i < 10; i++ {
+		budget.record(start.Add(time.Duration(i)*time.Second), time.Minute, true)
+	}
+	if rejected, events := budget.record(start.Add(30*time.Second), time.Minute, false); rejected != 10 || events != 11 {
+		t.Errorf("expected 10 of 11 events rejected, got %d of %d", rejected, events)
+	}
+	if rejected, events := budget.record(start.Add(70*time.Second), time.Minute, false); rejected != 0 || events != 2 {
+		t.Errorf("expected the early rejections to leave the window, got %d of %d", rejected, events)
+	}
+	if len(budget.buckets) > errorRateBuckets+1 {
+		t.Errorf("expected at most %d buckets, got %d", errorRateBuckets+1, len(budget.buckets))
+	}
+}
+
+// TestPipelineErrorRateWindow tests the error rate over a window
+func TestPipelineErrorRateWindow(t *testing.T) {
+	var events []Event
+	for i := 0; i < 300; i++ {
+		id := fmt.Sprint(i)
+		if i%2 == 0 {
+			id = "bad" + id
+		}
+		events = append(events, Event{ID: id})
+	}
+	p := New("test", NewMockSource(events), NewMockSink(), prefixRejectTransformer{prefix: "bad"}, nil)
+	p.SetClock(NewManualClock(time.Unix(1700000000, 0)))
+	if err := p.SetErrorPolicy(ErrorPolicy{MaxErrorRate: 0.05, ErrorRateWindow: 5 * time.Minute}); err != nil {
+		t.Fatalf("SetErrorPolicy() error = %v", err)
+	}
+	if err := p.Run(context.Background()); !errors.Is(err, ErrErrorBudgetExhausted) || !strings.Contains(err.Error(), "over 5m0s") {
+		t.Errorf("expected the error rate over the window to stop the pipeline, got %v", err)
+	}
+}
@@ -0,0 +1,30 @@
+package pipeline
+
+// heartbeat handles a heartbeat from an idle source. Its source time becomes
+// the lag reference, and its position is checkpointed when no event is
+// awaiting a commit, and acknowledged if the source asks for it; otherwise
+// the next commit moves the checkpoint.
+func (p *Pipeline) heartbeat(event Event) {
+	if !event.Timestamp.IsZero() {
+		p.mu.Lock()
+		p.lastSourceTime = event.Timestamp
+		p.mu.Unlock()
+	}
+	_, acknowledges := p.source.(Acknowledger)
+	if event.Position == "" || (p.checkpoints == nil && !acknowledges) || !p.commitsReported.Load() {
+		return
+	}
+	if p.processed.Load() != p.committed.Load() || (p.wal != nil && p.wal.Len() > 0) || (p.buffer != nil && p.buffer.Len() > 0) {
+		return
+	}
+	p.commitMu.Lock()
+	held := p.commitsHeld
+	p.commitMu.Unlock()
+	if held {
+		return
+	}
+	p.ackSource(event.Position)
+	if p.checkpoints != nil {
+		p.saveCheckpoint(event.Position)
+	}
+}
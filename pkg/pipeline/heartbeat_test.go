@@ -0,0 +1,87 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// feedSource is a mock source that emits the events sent on its feed until it is closed
+type feedSource struct {
+	MockSource
+	feed chan Event
+}
+
+func (f *feedSource) Read(ctx context.Context) (<-chan Event, <-chan error) {
+	events := make(chan Event)
+	errors := make(chan error)
+	go func() {
+		defer close(events)
+		defer close(errors)
+		for event := range f.feed {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return events, errors
+}
+
+// TestPipelineHeartbeats tests that heartbeats move the lag and checkpoint without reaching the sink
+func TestPipelineHeartbeats(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	heartbeat := Event{Operation: OperationHeartbeat, Position: "p2", Timestamp: start.Add(-time.Second)}
+
+	tests := []struct {
+		name           string
+		batchSize      int
+		wantCheckpoint string
+	}{
+		// The event is committed before the heartbeat arrives
+		{"idle", 1, "p2"},
+		// The event awaits its batch, so the heartbeat must not skip past it
+		{"event in flight", 2, "p1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &feedSource{feed: make(chan Event)}
+			sink := &commitSink{batchSize: tt.batchSize}
+			store := newMemoryCheckpointStore()
+			p := New("test", source, sink, nil, nil)
+			p.SetClock(NewManualClock(start))
+			p.SetCheckpointStore(store)
+
+			done := make(chan error)
+			go func() { done <- p.Run(context.Background()) }()
+			source.feed <- Event{ID: "1", Operation: "insert", Position: "p1", Timestamp: start.Add(-time.Minute)}
+			if tt.batchSize == 1 {
+				for deadline := time.Now().Add(5 * time.Second); p.committed.Load() == 0; {
+					if time.Now().After(deadline) {
+						t.Fatal("event was not committed")
+					}
+					time.Sleep(time.Millisecond)
+				}
+			}
+			source.feed <- heartbeat
+			for deadline := time.Now().Add(5 * time.Second); p.Stats().Lag != time.Second; {
+				if time.Now().After(deadline) {
+					t.Fatalf("expected the heartbeat to set the lag to 1s, got %v", p.Stats().Lag)
+				}
+				time.Sleep(time.Millisecond)
+			}
+			close(source.feed)
+			if err := <-done; err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			if len(sink.received) != 1 || sink.received[0].ID != "1" {
+				t.Errorf("expected only the event to be written, got %+v", sink.received)
+			}
+			if got := store.saved["test"].Position; got != tt.wantCheckpoint {
+				t.Errorf("checkpoint = %q, want %q", got, tt.wantCheckpoint)
+			}
+		})
+	}
+}
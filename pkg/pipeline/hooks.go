@@ -0,0 +1,35 @@
+package pipeline
+
+// Hooks are callbacks run at points of a pipeline's lifecycle, so library
+// users can react to them, e.g. notifying a downstream service once a
+// snapshot finishes. Any hook may be nil. Hooks run synchronously on the
+// pipeline's goroutines, possibly concurrently, so they must return quickly
+// and must not block on the pipeline.
+type Hooks struct {
+	// OnStart is called once the source and sink are connected, before the
+	// first event is read
+	OnStart func()
+	// OnInitialSyncComplete is called when an initial sync reported with
+	// InitialSyncFinished completed
+	OnInitialSyncComplete func(stats SnapshotStats)
+	// OnBatchCommitted is called after the sink durably writes a batch, in
+	// order. With pooling, the events' data must not be retained.
+	OnBatchCommitted func(events []Event)
+	// OnError is called for every runtime error, with the component that failed
+	OnError func(component string, err error)
+	// OnStop is called when Run returns, with its error
+	OnStop func(err error)
+}
+
+// SetHooks sets the pipeline's lifecycle callbacks
+func (p *Pipeline) SetHooks(hooks Hooks) {
+	p.hooks = hooks
+}
+
+// InitialSyncFinished reports an initial sync performed outside Run, calling
+// the OnInitialSyncComplete hook if it completed
+func (p *Pipeline) InitialSyncFinished(stats SnapshotStats) {
+	if stats.Status == RunCompleted && p.hooks.OnInitialSyncComplete != nil {
+		p.hooks.OnInitialSyncComplete(stats)
+	}
+}
@@ -0,0 +1,61 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// TestPipelineHooks tests that lifecycle hooks are called as the pipeline runs
+func TestPipelineHooks(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	record := func(call string) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call)
+	}
+
+	events := []Event{{ID: "1"}, {ID: "bad"}, {ID: "2"}}
+	p := New("test", NewMockSource(events), &commitSink{batchSize: 1}, prefixRejectTransformer{prefix: "bad"}, nil)
+	p.SetHooks(Hooks{
+		OnStart: func() { record("start") },
+		OnBatchCommitted: func(events []Event) {
+			record("committed " + events[0].ID)
+		},
+		OnError: func(component string, err error) { record("error " + component) },
+		OnStop: func(err error) {
+			if err != nil {
+				t.Errorf("OnStop() error = %v", err)
+			}
+			record("stop")
+		},
+	})
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// The transform error and the commits happen on different goroutines
+	got := strings.Join(calls, ", ")
+	if len(calls) != 5 || calls[0] != "start" || calls[4] != "stop" ||
+		!strings.Contains(got, "committed 1") || strings.Index(got, "committed 1") > strings.Index(got, "committed 2") ||
+		!strings.Contains(got, "error transformer") {
+		t.Errorf("expected start, both commits in order and the transform error, then stop, got %q", got)
+	}
+}
+
+// TestInitialSyncFinished tests that only completed initial syncs call the hook
+func TestInitialSyncFinished(t *testing.T) {
+	var completed []SnapshotStats
+	p := New("test", NewMockSource(nil), NewMockSink(), nil, nil)
+	p.InitialSyncFinished(SnapshotStats{Status: RunCompleted})
+
+	p.SetHooks(Hooks{OnInitialSyncComplete: func(stats SnapshotStats) { completed = append(completed, stats) }})
+	p.InitialSyncFinished(SnapshotStats{Status: RunFailed, Error: "boom"})
+	p.InitialSyncFinished(SnapshotStats{Status: RunCompleted, Documents: 10})
+
+	if len(completed) != 1 || completed[0].Documents != 10 {
+		t.Errorf("expected the completed sync to be reported once, got %+v", completed)
+	}
+}
@@ -0,0 +1,42 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultKeyFields identify a document by its MongoDB _id
+var defaultKeyFields = []string{"_id"}
+
+// SetKeyFields sets the fields that together identify a document, for
+// collections with a composite or natural key. They are used to keep a
+// document's changes on one writer and are copied into every part of a split
+// event. The default is _id.
+func (p *Pipeline) SetKeyFields(fields []string) {
+	p.keyFields = fields
+}
+
+// eventKey returns the key of the document an event changes: its key field
+// values, taken from the data or else the source document key, or the event
+// ID if both lack any of them. Source event IDs
+// (such as MongoDB resume tokens) differ for every change and cannot be used
+// to keep a document's changes together.
+func (p *Pipeline) eventKey(event Event) string {
+	fields := p.keyFields
+	if len(fields) == 0 {
+		fields = defaultKeyFields
+	}
+
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		value, ok := event.Data[field]
+		if !ok {
+			value, ok = event.Key[field]
+		}
+		if !ok || value == nil {
+			return event.ID
+		}
+		parts[i] = fmt.Sprint(value)
+	}
+	return strings.Join(parts, "\x00")
+}
@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+)
+
+// LatencyRecorder is implemented by metrics recorders that record the
+// end-to-end latency of events. The pipeline uses it when the recorder passed
+// to SetMetrics supports it and latency tracking is enabled.
+type LatencyRecorder interface {
+	ObserveEndToEndLatency(pipelineName, operation string, seconds float64, exemplar map[string]string)
+}
+
+// LatencyTracking configures the end-to-end latency recorded per event, from
+// its change at the source (Event.Timestamp) to its commit by the sink
+type LatencyTracking struct {
+	// TraceIDField is the event field, dot-separated for nested fields,
+	// holding the trace ID of the change, attached to observations as a
+	// trace_id exemplar. A W3C traceparent value is reduced to its trace ID.
+	// A trace_id set in Event.Metadata takes precedence. Events without
+	// either are linked by their event_id instead.
+	TraceIDField string
+}
+
+// SetLatencyTracking records the end-to-end latency of every event once the
+// sink commits it, or hands it off for sinks that do not report commits
+func (p *Pipeline) SetLatencyTracking(tracking LatencyTracking) {
+	p.latency = &tracking
+}
+
+// observeLatency records the end-to-end latency of written events
+func (p *Pipeline) observeLatency(events []Event) {
+	if p.latency == nil || p.latencyMetrics == nil {
+		return
+	}
+	now := p.clock.Now()
+	for _, e := range events {
+		if e.Timestamp.IsZero() {
+			continue
+		}
+		seconds := now.Sub(e.Timestamp).Seconds()
+		if seconds < 0 {
+			// The source's clock is ahead of ours
+			seconds = 0
+		}
+		exemplar := map[string]string{"event_id": e.ID}
+		if traceID := p.latency.traceID(e); traceID != "" {
+			exemplar = map[string]string{"trace_id": traceID}
+		}
+		p.latencyMetrics.ObserveEndToEndLatency(p.name, e.Operation, seconds, exemplar)
+	}
+}
+
+// traceID returns the trace ID an event carries, if any: its trace_id
+// metadata, or else its trace ID field
+func (t *LatencyTracking) traceID(event Event) string {
+	if traceID := event.Metadata[MetadataTraceID]; traceID != "" {
+		return traceID
+	}
+	if t.TraceIDField == "" {
+		return ""
+	}
+	var current interface{} = event.Data
+	for _, part := range strings.Split(t.TraceIDField, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		if current, ok = m[part]; !ok || current == nil {
+			return ""
+		}
+	}
+	value := fmt.Sprintf("%v", current)
+	// traceparent: version-traceid-parentid-flags
+	if parts := strings.Split(value, "-"); len(parts) == 4 && len(parts[1]) == 32 {
+		return parts[1]
+	}
+	return value
+}
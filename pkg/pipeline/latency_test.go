@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// latencyObservation is an end-to-end latency recorded by latencyMetrics
+type latencyObservation struct {
+	operation string
+	seconds   float64
+	exemplar  map[string]string
+}
+
+// latencyMetrics is a MetricsRecorder that keeps end-to-end latencies for inspection
+type latencyMetrics struct {
+	basicMetrics
+	mu           sync.Mutex
+	observations []latencyObservation
+}
+
+func (l *latencyMetrics) ObserveEndToEndLatency(pipelineName, operation string, seconds float64, exemplar map[string]string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.observations = append(l.observations, latencyObservation{operation, seconds, exemplar})
+}
+
+// TestPipelineLatencyTracking tests that committed events are observed with their trace ID as exemplar
+func TestPipelineLatencyTracking(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	events := []Event{
+		{ID: "1", Operation: "insert", Timestamp: start, Data: map[string]interface{}{"meta": map[string]interface{}{"trace": "4bf92f3577b34da6a3ce929d0e0e4736"}}},
+		{ID: "2", Operation: "update", Timestamp: start, Data: map[string]interface{}{"meta": map[string]interface{}{"trace": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}}},
+		{ID: "3", Operation: "delete", Timestamp: start},
+		{ID: "4", Operation: "insert"}, // no source time, not observed
+		{ID: "5", Operation: "replace", Timestamp: start, Metadata: map[string]string{MetadataTraceID: "metadata-trace"}, Data: map[string]interface{}{"meta": map[string]interface{}{"trace": "field-trace"}}},
+	}
+
+	tests := []struct {
+		name string
+		sink Sink
+	}{
+		{"on commit", &commitSink{batchSize: 2}},
+		{"on handoff without commits", &MockSink{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := &latencyMetrics{}
+			p := New("test", NewMockSource(events), tt.sink, nil, nil)
+			p.SetClock(NewManualClock(start.Add(1500 * time.Millisecond)))
+			p.SetMetrics(metrics)
+			p.SetLatencyTracking(LatencyTracking{TraceIDField: "meta.trace"})
+			if err := p.Run(context.Background()); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+
+			want := []latencyObservation{
+				{"insert", 1.5, map[string]string{"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736"}},
+				{"update", 1.5, map[string]string{"trace_id": "0af7651916cd43dd8448eb211c80319c"}},
+				{"delete", 1.5, map[string]string{"event_id": "3"}},
+				{"replace", 1.5, map[string]string{"trace_id": "metadata-trace"}},
+			}
+			if len(metrics.observations) != len(want) {
+				t.Fatalf("expected %d observations, got %+v", len(want), metrics.observations)
+			}
+			for i, w := range want {
+				got := metrics.observations[i]
+				if got.operation != w.operation || got.seconds != w.seconds || len(got.exemplar) != len(w.exemplar) {
+					t.Errorf("observation %d = %+v, want %+v", i, got, w)
+					continue
+				}
+				for k, v := range w.exemplar {
+					if got.exemplar[k] != v {
+						t.Errorf("observation %d exemplar = %v, want %v", i, got.exemplar, w.exemplar)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestPipelineLatencyTrackingDisabled tests that no latency is recorded unless tracking is enabled
+func TestPipelineLatencyTrackingDisabled(t *testing.T) {
+	metrics := &latencyMetrics{}
+	p := New("test", NewMockSource([]Event{{ID: "1", Operation: "insert", Timestamp: time.Now()}}), &commitSink{batchSize: 1}, nil, nil)
+	p.SetMetrics(metrics)
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(metrics.observations) != 0 {
+		t.Errorf("expected no observations, got %+v", metrics.observations)
+	}
+}
@@ -0,0 +1,192 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryBudget is a soft limit on the approximate bytes of events held in
+// memory between the transformer and the sink. Producers block while the
+// budget is exhausted and sinks shrink their batches as it fills up.
+type MemoryBudget struct {
+	max     int64
+	mu      sync.Mutex // protects the fields below
+	used    int64
+	changed chan struct{} // closed and replaced on every release
+	blocked chan struct{} // closed while a producer waits for memory
+	waiting bool          // whether blocked has been closed
+}
+
+// NewMemoryBudget creates a memory budget of maxBytes
+func NewMemoryBudget(maxBytes int64) *MemoryBudget {
+	return &MemoryBudget{
+		max:     maxBytes,
+		changed: make(chan struct{}),
+		blocked: make(chan struct{}),
+	}
+}
+
+// Acquire reserves n bytes, blocking while the budget is exhausted. A single
+// event larger than the whole budget is admitted once nothing else is held,
+// so oversized documents slow the pipeline down instead of stalling it.
+func (b *MemoryBudget) Acquire(ctx context.Context, n int64) error {
+	for {
+		b.mu.Lock()
+		if b.used == 0 || b.used+n <= b.max {
+			b.used += n
+			b.mu.Unlock()
+			return nil
+		}
+		changed := b.changed
+		if !b.waiting {
+			close(b.blocked)
+			b.waiting = true
+		}
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Release returns n bytes to the budget
+func (b *MemoryBudget) Release(n int64) {
+	if n == 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.used -= n
+	if b.used < 0 {
+		b.used = 0
+	}
+	close(b.changed)
+	b.changed = make(chan struct{})
+	if b.waiting {
+		b.blocked = make(chan struct{})
+		b.waiting = false
+	}
+}
+
+// Exhausted returns a channel that is closed once a producer is waiting for
+// memory. Sinks holding a partial batch should flush it when it fires, since
+// the events they hold may be what the producer is waiting on. A nil budget
+// returns a nil channel, which never fires.
+func (b *MemoryBudget) Exhausted() <-chan struct{} {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.blocked
+}
+
+// Used returns the bytes currently reserved
+func (b *MemoryBudget) Used() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used
+}
+
+// Max returns the size of the budget in bytes
+func (b *MemoryBudget) Max() int64 {
+	return b.max
+}
+
+// BatchSize scales a preferred batch size down in proportion to the budget
+// still free, so sinks commit (and release memory) sooner under pressure
+func (b *MemoryBudget) BatchSize(preferred int) int {
+	if b == nil || b.max <= 0 {
+		return preferred
+	}
+	b.mu.Lock()
+	free := b.max - b.used
+	b.mu.Unlock()
+
+	size := int(int64(preferred) * free / b.max)
+	if size < 1 {
+		return 1
+	}
+	if size > preferred {
+		return preferred
+	}
+	return size
+}
+
+// SetMemoryBudget sets the soft memory budget for events awaiting the sink
+func (p *Pipeline) SetMemoryBudget(budget *MemoryBudget) {
+	p.memory = budget
+}
+
+// reserveMemory blocks until the event fits the memory budget and returns the bytes reserved
+func (p *Pipeline) reserveMemory(ctx context.Context, event Event) (int64, error) {
+	if p.memory == nil {
+		return 0, nil
+	}
+	n := int64(EventSize(event))
+	if n == 0 {
+		return 0, nil
+	}
+	if err := p.memory.Acquire(ctx, n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// trackMemory records bytes reserved for an event about to be handed to the
+// sink stage, to be released when the sink commits it. It is called before the
+// handoff, since the sink may commit the event as soon as it receives it.
+func (p *Pipeline) trackMemory(n int64) {
+	if p.memory == nil || n == 0 || !p.releaseOnCommit {
+		return
+	}
+	p.memoryMu.Lock()
+	p.inflightBytes = append(p.inflightBytes, n)
+	p.memoryMu.Unlock()
+}
+
+// releaseHandedOff releases an event's bytes once the next stage has received
+// it, when nothing reports commits for it
+func (p *Pipeline) releaseHandedOff(n int64) {
+	if p.memory == nil || n == 0 || p.releaseOnCommit {
+		return
+	}
+	p.memory.Release(n)
+}
+
+// releaseUnsent releases the bytes of an event that was never handed off,
+// because the run stopped first
+func (p *Pipeline) releaseUnsent(n int64) {
+	if p.memory == nil || n == 0 {
+		return
+	}
+	if p.releaseOnCommit {
+		p.memoryMu.Lock()
+		p.inflightBytes = p.inflightBytes[:len(p.inflightBytes)-1]
+		p.memoryMu.Unlock()
+	}
+	p.memory.Release(n)
+}
+
+// releaseCommitted releases the memory of the oldest count in-flight events
+func (p *Pipeline) releaseCommitted(count int) {
+	if p.memory == nil || !p.releaseOnCommit {
+		return
+	}
+	p.memoryMu.Lock()
+	if count > len(p.inflightBytes) {
+		count = len(p.inflightBytes)
+	}
+	var total int64
+	for _, n := range p.inflightBytes[:count] {
+		total += n
+	}
+	p.inflightBytes = p.inflightBytes[count:]
+	p.memoryMu.Unlock()
+
+	p.memory.Release(total)
+}
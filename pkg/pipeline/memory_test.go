@@ -0,0 +1,195 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestMemoryBudgetAcquireBlocks tests that acquiring past the budget waits for a release
+func TestMemoryBudgetAcquireBlocks(t *testing.T) {
+	budget := NewMemoryBudget(100)
+	ctx := context.Background()
+
+	if err := budget.Acquire(ctx, 80); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() { acquired <- budget.Acquire(ctx, 40) }()
+	select {
+	case <-acquired:
+		t.Fatal("Acquire() should block while the budget is exhausted")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	budget.Release(80)
+	if err := <-acquired; err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if budget.Used() != 40 {
+		t.Errorf("expected 40 bytes used, got %d", budget.Used())
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := budget.Acquire(cancelled, 80); err == nil {
+		t.Error("expected Acquire() to fail on a cancelled context")
+	}
+}
+
+// TestMemoryBudgetAdmitsOversizedEvent tests that one event larger than the budget still passes
+func TestMemoryBudgetAdmitsOversizedEvent(t *testing.T) {
+	budget := NewMemoryBudget(10)
+	if err := budget.Acquire(context.Background(), 50); err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+	if budget.Used() != 50 {
+		t.Errorf("expected 50 bytes used, got %d", budget.Used())
+	}
+}
+
+// TestMemoryBudgetBatchSize tests that batch sizes shrink as the budget fills
+func TestMemoryBudgetBatchSize(t *testing.T) {
+	var unlimited *MemoryBudget
+	if got := unlimited.BatchSize(100); got != 100 {
+		t.Errorf("nil budget BatchSize() = %d, want 100", got)
+	}
+
+	budget := NewMemoryBudget(1000)
+	tests := []struct {
+		used int64
+		want int
+	}{
+		{0, 100},
+		{500, 50},
+		{900, 10},
+		{1000, 1},
+		{5000, 1},
+	}
+	for _, tt := range tests {
+		budget.used = tt.used
+		if got := budget.BatchSize(100); got != tt.want {
+			t.Errorf("BatchSize() with %d used = %d, want %d", tt.used, got, tt.want)
+		}
+	}
+}
+
+// TestPipelineReleasesMemoryOnCommit tests that the budget is returned as the sink commits
+func TestPipelineReleasesMemoryOnCommit(t *testing.T) {
+	var events []Event
+	for i := 0; i < 50; i++ {
+		events = append(events, Event{ID: "1", Operation: "insert", Data: map[string]interface{}{"v": strings.Repeat("x", 100)}})
+	}
+	sink := &commitSink{batchSize: 5}
+	budget := NewMemoryBudget(1000)
+
+	p := New("test", NewMockSource(events), sink, nil, nil)
+	p.SetMemoryBudget(budget)
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if len(sink.received) != len(events) {
+		t.Errorf("expected %d events, got %d", len(events), len(sink.received))
+	}
+	if budget.Used() != 0 {
+		t.Errorf("expected all memory released, %d bytes still used", budget.Used())
+	}
+}
+
+// TestMemoryBudgetExhausted tests that sinks are signalled while a producer waits
+func TestMemoryBudgetExhausted(t *testing.T) {
+	var unlimited *MemoryBudget
+	if unlimited.Exhausted() != nil {
+		t.Error("expected nil channel for a nil budget")
+	}
+
+	budget := NewMemoryBudget(100)
+	ctx := context.Background()
+	budget.Acquire(ctx, 60)
+
+	exhausted := budget.Exhausted()
+	select {
+	case <-exhausted:
+		t.Fatal("budget should not be exhausted without a waiting producer")
+	default:
+	}
+
+	acquired := make(chan error, 1)
+	go func() { acquired <- budget.Acquire(ctx, 60) }()
+	<-exhausted
+
+	budget.Release(60)
+	<-acquired
+	select {
+	case <-budget.Exhausted():
+		t.Error("expected a fresh signal after the producer was admitted")
+	default:
+	}
+}
+
+// gatedSink is a mock sink that does not read events until its gate is opened
+type gatedSink struct {
+	MockSink
+	gate chan struct{}
+}
+
+func (g *gatedSink) Write(ctx context.Context, events <-chan Event) <-chan error {
+	<-g.gate
+	return g.MockSink.Write(ctx, events)
+}
+
+// TestPipelineHoldsMemoryUntilHandoff tests that, for a sink that does not
+// report commits, an event's memory stays reserved until the sink receives it
+func TestPipelineHoldsMemoryUntilHandoff(t *testing.T) {
+	events := []Event{{ID: "1", Operation: "insert", Data: map[string]interface{}{"v": strings.Repeat("x", 100)}}}
+	sink := &gatedSink{gate: make(chan struct{})}
+	budget := NewMemoryBudget(1000)
+
+	p := New("test", NewMockSource(events), sink, nil, nil)
+	p.SetMemoryBudget(budget)
+	done := make(chan error, 1)
+	go func() { done <- p.Run(context.Background()) }()
+
+	deadline := time.After(5 * time.Second)
+	for budget.Used() == 0 {
+		select {
+		case <-deadline:
+			t.Fatal("expected memory reserved while the sink has not received the event")
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	close(sink.gate)
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if budget.Used() != 0 {
+		t.Errorf("expected memory released once the sink received the event, %d bytes used", budget.Used())
+	}
+}
+
+// TestPipelineRejectsEventWithoutMemory tests that an event that cannot reserve
+// memory before shutdown goes through the reject path instead of vanishing
+func TestPipelineRejectsEventWithoutMemory(t *testing.T) {
+	budget := NewMemoryBudget(100)
+	budget.Acquire(context.Background(), 100) // exhausted, never released
+
+	ctx, cancel := context.WithCancel(context.Background())
+	source := NewMockSource([]Event{{ID: "1", Data: map[string]interface{}{"v": "x"}}})
+	p := New("test", source, NewMockSink(), nil, nil)
+	p.SetMemoryBudget(budget)
+	done := make(chan error, 1)
+	go func() { done <- p.Run(ctx) }()
+
+	<-budget.Exhausted()
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got := p.rejected.Load(); got != 1 {
+		t.Errorf("expected the event counted as rejected, got %d", got)
+	}
+}
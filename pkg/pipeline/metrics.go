@@ -0,0 +1,70 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// metricsSampleInterval is how often queue depths and breaker state are reported
+const metricsSampleInterval = 5 * time.Second
+
+// sampleMetrics periodically reports gauges that are cheaper to sample than
+// to update on every event
+func (p *Pipeline) sampleMetrics(ctx context.Context) {
+	for {
+		p.recordGauges()
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.clock.After(metricsSampleInterval):
+		}
+	}
+}
+
+// recordGauges reports the current queue depths, memory use and breaker state
+func (p *Pipeline) recordGauges() {
+	for queue, depth := range p.queueDepths() {
+		p.opMetrics.SetQueueDepth(p.name, queue, depth)
+	}
+	if p.memory != nil {
+		p.opMetrics.SetMemoryUsed(p.name, p.memory.Used())
+	}
+	if reporter, ok := p.sink.(BreakerReporter); ok {
+		p.opMetrics.SetCircuitBreakerState(p.name, "sink", int(reporter.BreakerState()))
+	}
+}
+
+// queueDepths returns the number of events waiting in each queue between stages.
+// Queues that are not in use are left out.
+func (p *Pipeline) queueDepths() map[string]int {
+	depths := make(map[string]int)
+	p.mu.RLock()
+	sourceQueue, transformQueue := p.sourceQueue, p.transformQueue
+	p.mu.RUnlock()
+	if sourceQueue != nil {
+		depths["source"] = len(sourceQueue)
+		depths["transformer"] = len(transformQueue)
+	}
+	if p.commitsReported.Load() {
+		inflight := p.processed.Load() - p.committed.Load()
+		if inflight < 0 {
+			inflight = 0
+		}
+		depths["sink"] = int(inflight)
+	}
+	if p.buffer != nil {
+		depths["buffer"] = p.buffer.Len()
+	}
+	if p.wal != nil {
+		depths["wal"] = p.wal.Len()
+	}
+	return depths
+}
+
+// setStageQueues records the channels between stages so their depth can be sampled
+func (p *Pipeline) setStageQueues(source <-chan Event, transform chan Event) {
+	p.mu.Lock()
+	p.sourceQueue = source
+	p.transformQueue = transform
+	p.mu.Unlock()
+}
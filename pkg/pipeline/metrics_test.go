@@ -0,0 +1,163 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingMetrics is a MetricsRecorder that keeps failure-path metrics for inspection
+type recordingMetrics struct {
+	mu             sync.Mutex
+	queueDepth     map[string]int
+	breakerState   int
+	dlqWrites      map[string]int
+	retries        map[string]int
+	lastCheckpoint time.Time
+}
+
+func newRecordingMetrics() *recordingMetrics {
+	return &recordingMetrics{
+		queueDepth:   make(map[string]int),
+		breakerState: -1,
+		dlqWrites:    make(map[string]int),
+		retries:      make(map[string]int),
+	}
+}
+
+func (r *recordingMetrics) RecordEventProcessed(pipelineName, operation string)                {}
+func (r *recordingMetrics) RecordEventError(pipelineName, component, errorType string)         {}
+func (r *recordingMetrics) RecordProcessingDuration(pipelineName, component string, d float64) {}
+func (r *recordingMetrics) SetPipelineRunning(running bool)                                    {}
+func (r *recordingMetrics) SetSourceConnected(connected bool)                                  {}
+func (r *recordingMetrics) SetSinkConnected(connected bool)                                    {}
+func (r *recordingMetrics) SetMemoryUsed(pipelineName string, bytes int64)                     {}
+
+func (r *recordingMetrics) SetQueueDepth(pipelineName, queue string, depth int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queueDepth[queue] = depth
+}
+
+func (r *recordingMetrics) RecordRetry(pipelineName, component string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.retries[component]++
+}
+
+func (r *recordingMetrics) SetCircuitBreakerState(pipelineName, component string, state int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.breakerState = state
+}
+
+func (r *recordingMetrics) RecordDLQWrite(pipelineName, component string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dlqWrites[component]++
+}
+
+func (r *recordingMetrics) SetLastCheckpoint(pipelineName string, t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.lastCheckpoint = t
+}
+
+// memoryDLQ is a DeadLetterQueue that discards events
+type memoryDLQ struct{}
+
+func (memoryDLQ) Send(ctx context.Context, event Event, reason string) error { return nil }
+
+// TestPipelineFailurePathMetrics tests that DLQ writes and checkpoints are reported
+func TestPipelineFailurePathMetrics(t *testing.T) {
+	events := []Event{
+		{ID: "1", Operation: "insert", Position: "p1", Data: map[string]interface{}{"v": "small"}},
+		{ID: "2", Operation: "insert", Position: "p2", Data: map[string]interface{}{"v": "this value is far too large"}},
+	}
+	recorder := newRecordingMetrics()
+	store := newMemoryCheckpointStore()
+	clock := NewManualClock(time.Unix(1700000000, 0))
+
+	p := New("test", &resumableSource{MockSource: *NewMockSource(events)}, &commitSink{batchSize: 10}, nil, nil)
+	p.SetClock(clock)
+	p.SetMetrics(recorder)
+	p.SetCheckpointStore(store)
+	p.SetDeadLetterQueue(memoryDLQ{})
+	p.SetSizeLimit(SizeLimit{MaxBytes: 20, Policy: OversizeDLQ})
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if recorder.dlqWrites["pipeline"] != 1 {
+		t.Errorf("expected 1 DLQ write, got %v", recorder.dlqWrites)
+	}
+	if !recorder.lastCheckpoint.Equal(clock.Now()) {
+		t.Errorf("expected checkpoint time %v, got %v", clock.Now(), recorder.lastCheckpoint)
+	}
+}
+
+// TestRecordGauges tests sampling of queue depth and breaker state
+func TestRecordGauges(t *testing.T) {
+	recorder := newRecordingMetrics()
+	buffer := &memoryBuffer{}
+	buffer.Append(Event{ID: "1"})
+	buffer.Append(Event{ID: "2"})
+
+	p := New("test", NewMockSource(nil), &breakerSink{state: BreakerOpen}, nil, nil)
+	p.SetMetrics(recorder)
+	p.SetBuffer(buffer)
+	p.recordGauges()
+
+	if recorder.queueDepth["buffer"] != 2 {
+		t.Errorf("expected buffer depth 2, got %d", recorder.queueDepth["buffer"])
+	}
+	if recorder.breakerState != int(BreakerOpen) {
+		t.Errorf("expected breaker state %d, got %d", BreakerOpen, recorder.breakerState)
+	}
+}
+
+// TestRecordGaugesStageQueues tests sampling of the queues between stages
+func TestRecordGaugesStageQueues(t *testing.T) {
+	recorder := newRecordingMetrics()
+	p := New("test", NewMockSource(nil), NewMockSink(), nil, nil)
+	p.SetMetrics(recorder)
+
+	source := make(chan Event, 5)
+	source <- Event{ID: "1"}
+	source <- Event{ID: "2"}
+	p.setStageQueues(source, make(chan Event))
+	p.commitsReported.Store(true)
+	p.processed.Store(7)
+	p.committed.Store(4)
+	p.recordGauges()
+
+	if recorder.queueDepth["source"] != 2 || recorder.queueDepth["transformer"] != 0 {
+		t.Errorf("expected source depth 2 and transformer depth 0, got %v", recorder.queueDepth)
+	}
+	if recorder.queueDepth["sink"] != 3 {
+		t.Errorf("expected 3 events awaiting commit, got %d", recorder.queueDepth["sink"])
+	}
+}
+
+// basicMetrics implements only the core MetricsRecorder methods
+type basicMetrics struct{}
+
+func (basicMetrics) RecordEventProcessed(pipelineName, operation string)                {}
+func (basicMetrics) RecordEventError(pipelineName, component, errorType string)         {}
+func (basicMetrics) RecordProcessingDuration(pipelineName, component string, d float64) {}
+func (basicMetrics) SetPipelineRunning(running bool)                                    {}
+func (basicMetrics) SetSourceConnected(connected bool)                                  {}
+func (basicMetrics) SetSinkConnected(connected bool)                                    {}
+
+// TestPipelineBasicMetricsRecorder tests that recorders without operational metrics still work
+func TestPipelineBasicMetricsRecorder(t *testing.T) {
+	events := []Event{{ID: "1", Operation: "insert", Position: "p1"}}
+	p := New("test", NewMockSource(events), &commitSink{batchSize: 10}, nil, nil)
+	p.SetMetrics(basicMetrics{})
+	p.SetCheckpointStore(newMemoryCheckpointStore())
+	p.SetDeadLetterQueue(memoryDLQ{})
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+}
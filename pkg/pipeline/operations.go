@@ -0,0 +1,94 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// OperationAction determines how events with an operation other than insert,
+// update, replace or delete are handled
+type OperationAction string
+
+const (
+	// OperationIgnore drops the event
+	OperationIgnore OperationAction = "ignore"
+	// OperationDLQ routes the event to the dead-letter queue
+	OperationDLQ OperationAction = "dlq"
+	// OperationStop stops the pipeline with an error
+	OperationStop OperationAction = "stop"
+	// OperationResync stops the pipeline with ErrResyncRequired
+	OperationResync OperationAction = "resync"
+)
+
+// DefaultOperation is the policy key for operations without their own entry
+const DefaultOperation = "default"
+
+// ErrResyncRequired is returned by Run when an event's operation policy asks
+// for a full resync, e.g. because the source collection was dropped
+var ErrResyncRequired = errors.New("source requires a full resync")
+
+// defaultOperationActions stop the pipeline on changes that end the change
+// stream or leave the destination out of step with the source. Other
+// operations are ignored.
+var defaultOperationActions = map[string]OperationAction{
+	"drop":           OperationStop,
+	"rename":         OperationStop,
+	"dropDatabase":   OperationStop,
+	"invalidate":     OperationStop,
+	DefaultOperation: OperationIgnore,
+}
+
+// ParseOperationAction parses an operation action from configuration
+func ParseOperationAction(name string) (OperationAction, error) {
+	switch OperationAction(name) {
+	case OperationIgnore, OperationDLQ, OperationStop, OperationResync:
+		return OperationAction(name), nil
+	default:
+		return "", fmt.Errorf("unsupported operation action: %s", name)
+	}
+}
+
+// SetOperationPolicy sets how operations other than insert, update, replace
+// and delete are handled, keyed by operation name or DefaultOperation. Entries
+// override the defaults, which stop on drop, rename, dropDatabase and
+// invalidate and ignore everything else.
+func (p *Pipeline) SetOperationPolicy(actions map[string]OperationAction) {
+	p.operations = actions
+}
+
+// operationAction returns the action for an operation
+func (p *Pipeline) operationAction(operation string) OperationAction {
+	if action, ok := p.operations[operation]; ok {
+		return action
+	}
+	if action, ok := defaultOperationActions[operation]; ok {
+		return action
+	}
+	if action, ok := p.operations[DefaultOperation]; ok {
+		return action
+	}
+	return defaultOperationActions[DefaultOperation]
+}
+
+// handleOperation applies the operation policy to an event. It reports whether
+// the event should continue to the sink, or returns an error if the pipeline
+// must stop. Events without an operation pass through unchanged.
+func (p *Pipeline) handleOperation(ctx context.Context, event Event) (bool, error) {
+	switch event.Operation {
+	case "", "insert", "update", "replace", "delete":
+		return true, nil
+	}
+
+	switch p.operationAction(event.Operation) {
+	case OperationDLQ:
+		p.deadLetter(ctx, event, "unsupported_operation", fmt.Sprintf("unsupported operation: %s", event.Operation))
+	case OperationStop:
+		return false, fmt.Errorf("source emitted a %s event, stopping pipeline", event.Operation)
+	case OperationResync:
+		return false, fmt.Errorf("source emitted a %s event: %w", event.Operation, ErrResyncRequired)
+	default:
+		p.logger.Printf("Ignoring %s event %s", event.Operation, event.ID)
+	}
+	return false, nil
+}
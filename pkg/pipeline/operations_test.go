@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// collectingDLQ is a dead-letter queue that keeps the events it receives
+type collectingDLQ struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (d *collectingDLQ) Send(ctx context.Context, event Event, reason string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.events = append(d.events, event)
+	return nil
+}
+
+// TestParseOperationAction tests parsing operation actions
+func TestParseOperationAction(t *testing.T) {
+	for _, name := range []string{"ignore", "dlq", "stop", "resync"} {
+		if action, err := ParseOperationAction(name); err != nil || string(action) != name {
+			t.Errorf("ParseOperationAction(%q) = %q, %v", name, action, err)
+		}
+	}
+	for _, name := range []string{"", "skip"} {
+		if _, err := ParseOperationAction(name); err == nil {
+			t.Errorf("ParseOperationAction(%q) expected an error", name)
+		}
+	}
+}
+
+// TestPipelineOperationPolicy tests how events with unsupported operations are handled
+func TestPipelineOperationPolicy(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation string
+		policy    map[string]OperationAction
+		wantErr   error // nil for no error, errAny for any error
+		wantSent  int
+		wantDLQ   int
+	}{
+		{name: "unknown operation ignored by default", operation: "shardCollection", wantSent: 2},
+		{name: "drop stops by default", operation: "drop", wantErr: errAny, wantSent: 1},
+		{name: "default entry", operation: "shardCollection", policy: map[string]OperationAction{DefaultOperation: OperationDLQ}, wantSent: 2, wantDLQ: 1},
+		{name: "default entry does not override built-in defaults", operation: "drop", policy: map[string]OperationAction{DefaultOperation: OperationIgnore}, wantErr: errAny, wantSent: 1},
+		{name: "drop ignored", operation: "drop", policy: map[string]OperationAction{"drop": OperationIgnore}, wantSent: 2},
+		{name: "rename resyncs", operation: "rename", policy: map[string]OperationAction{"rename": OperationResync}, wantErr: ErrResyncRequired, wantSent: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events := []Event{
+				{ID: "1", Operation: "insert"},
+				{ID: "2", Operation: tt.operation},
+				{ID: "3", Operation: "delete"},
+			}
+			sink := NewMockSink()
+			dlq := &collectingDLQ{}
+			p := New("test", NewMockSource(events), sink, nil, nil)
+			p.SetDeadLetterQueue(dlq)
+			p.SetOperationPolicy(tt.policy)
+
+			err := p.Run(context.Background())
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("Run() error = %v", err)
+			case tt.wantErr == errAny && err == nil:
+				t.Fatal("expected Run() to fail")
+			case tt.wantErr != nil && tt.wantErr != errAny && !errors.Is(err, tt.wantErr):
+				t.Fatalf("Run() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(sink.received) != tt.wantSent {
+				t.Errorf("expected %d events written, got %d", tt.wantSent, len(sink.received))
+			}
+			if len(dlq.events) != tt.wantDLQ {
+				t.Errorf("expected %d dead-lettered events, got %d", tt.wantDLQ, len(dlq.events))
+			}
+		})
+	}
+}
+
+// errAny matches any error in TestPipelineOperationPolicy
+var errAny = errors.New("any error")
+
+// TestPipelineResetPosition tests that a reset makes the next run start without a resume position
+func TestPipelineResetPosition(t *testing.T) {
+	store := newMemoryCheckpointStore()
+	store.Save(context.Background(), Checkpoint{Pipeline: "test", Position: "p1"})
+	src := &resumableSource{MockSource: *NewMockSource(nil), startPosition: "p1"}
+	p := New("test", src, NewMockSink(), nil, nil)
+	p.SetCheckpointStore(store)
+
+	if err := p.ResetPosition(context.Background()); err != nil {
+		t.Fatalf("ResetPosition() error = %v", err)
+	}
+	if cp, _ := store.Load(context.Background(), "test"); cp == nil || cp.Position != "" {
+		t.Errorf("expected an empty checkpoint, got %+v", cp)
+	}
+	if src.startPosition != "" {
+		t.Errorf("expected the source position to be cleared, got %q", src.startPosition)
+	}
+}
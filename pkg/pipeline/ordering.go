@@ -0,0 +1,117 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// Ordering is the delivery ordering guarantee a pipeline enforces
+type Ordering string
+
+const (
+	// OrderingStrictGlobal delivers every event in source order through a single writer (default)
+	OrderingStrictGlobal Ordering = "strict_global"
+	// OrderingStrictPerKey keeps events for the same document (see SetKeyFields) in order, spreading documents across writers
+	OrderingStrictPerKey Ordering = "strict_per_key"
+	// OrderingRelaxed spreads events across writers with no ordering guarantee
+	OrderingRelaxed Ordering = "relaxed"
+)
+
+// ParseOrdering parses an ordering name, defaulting to strict_global
+func ParseOrdering(s string) (Ordering, error) {
+	switch Ordering(s) {
+	case "", OrderingStrictGlobal:
+		return OrderingStrictGlobal, nil
+	case OrderingStrictPerKey, OrderingRelaxed:
+		return Ordering(s), nil
+	default:
+		return "", fmt.Errorf("unknown ordering %q (expected strict_global, strict_per_key or relaxed)", s)
+	}
+}
+
+// SetOrdering sets the ordering guarantee and the number of concurrent sink writers
+func (p *Pipeline) SetOrdering(ordering Ordering, workers int) {
+	p.ordering = ordering
+	p.workers = workers
+}
+
+// validateOrdering rejects configurations that cannot honour the ordering guarantee
+func (p *Pipeline) validateOrdering() error {
+	if p.workers <= 1 {
+		return nil
+	}
+	if p.ordering == "" || p.ordering == OrderingStrictGlobal {
+		return fmt.Errorf("strict_global ordering requires a single sink worker, got %d", p.workers)
+	}
+	if _, ok := p.sink.(ConcurrentSink); !ok {
+		return fmt.Errorf("sink does not support concurrent writes, %d workers requested", p.workers)
+	}
+	if p.wal != nil || p.checkpoints != nil || p.buffer != nil {
+		// Commits from parallel writers complete out of order, so a single
+		// position cannot describe what has been delivered
+		return fmt.Errorf("multiple sink workers cannot be combined with checkpoints, a spill buffer or store-and-forward mode")
+	}
+	return nil
+}
+
+// writeSink starts the sink writers. With more than one worker, events are
+// partitioned by key (strict_per_key) or round-robin (relaxed) and the
+// writers' errors are merged.
+func (p *Pipeline) writeSink(ctx context.Context, events <-chan Event) <-chan error {
+	if p.workers <= 1 {
+		return p.sink.Write(ctx, events)
+	}
+
+	p.logger.Printf("Writing with %d sink workers (%s ordering)", p.workers, p.ordering)
+	inputs := make([]chan Event, p.workers)
+	errors := make(chan error)
+	var wg sync.WaitGroup
+	for i := range inputs {
+		inputs[i] = make(chan Event)
+		wg.Add(1)
+		go func(workerErrors <-chan error) {
+			defer wg.Done()
+			for err := range workerErrors {
+				errors <- err
+			}
+		}(p.sink.Write(ctx, inputs[i]))
+	}
+
+	go func() {
+		defer func() {
+			for _, input := range inputs {
+				close(input)
+			}
+		}()
+		next := 0
+		for event := range events {
+			worker := next
+			if p.ordering == OrderingStrictPerKey {
+				worker = partitionFor(p.eventKey(event), p.workers)
+			} else {
+				next = (next + 1) % p.workers
+			}
+			select {
+			case inputs[worker] <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(errors)
+	}()
+
+	return errors
+}
+
+// partitionFor maps an event key to a worker
+func partitionFor(key string, workers int) int {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return int(h.Sum32() % uint32(workers))
+}
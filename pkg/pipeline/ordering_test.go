@@ -0,0 +1,187 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// concurrentSink is a mock sink that records which writer received each event
+type concurrentSink struct {
+	MockSink
+	mu      sync.Mutex
+	writers [][]Event
+}
+
+func (c *concurrentSink) SupportsConcurrentWrites() {}
+
+func (c *concurrentSink) Write(ctx context.Context, events <-chan Event) <-chan error {
+	c.mu.Lock()
+	writer := len(c.writers)
+	c.writers = append(c.writers, nil)
+	c.mu.Unlock()
+
+	errors := make(chan error)
+	go func() {
+		defer close(errors)
+		for event := range events {
+			c.mu.Lock()
+			c.writers[writer] = append(c.writers[writer], event)
+			c.mu.Unlock()
+		}
+	}()
+	return errors
+}
+
+// TestParseOrdering tests parsing of ordering names
+func TestParseOrdering(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    Ordering
+		wantErr bool
+	}{
+		{"", OrderingStrictGlobal, false},
+		{"strict_global", OrderingStrictGlobal, false},
+		{"strict_per_key", OrderingStrictPerKey, false},
+		{"relaxed", OrderingRelaxed, false},
+		{"fifo", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseOrdering(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseOrdering(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseOrdering(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestValidateOrdering tests that incompatible ordering configurations are rejected
+func TestValidateOrdering(t *testing.T) {
+	tests := []struct {
+		name        string
+		ordering    Ordering
+		workers     int
+		sink        Sink
+		checkpoints bool
+		wantErr     bool
+	}{
+		{"single worker", OrderingStrictGlobal, 1, NewMockSink(), true, false},
+		{"strict global with workers", OrderingStrictGlobal, 4, &concurrentSink{}, false, true},
+		{"per key with workers", OrderingStrictPerKey, 4, &concurrentSink{}, false, false},
+		{"relaxed with workers", OrderingRelaxed, 4, &concurrentSink{}, false, false},
+		{"sequential sink", OrderingRelaxed, 4, NewMockSink(), false, true},
+		{"workers with checkpoints", OrderingStrictPerKey, 4, &concurrentSink{}, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New("test", NewMockSource(nil), tt.sink, nil, nil)
+			p.SetOrdering(tt.ordering, tt.workers)
+			if tt.checkpoints {
+				p.SetCheckpointStore(newMemoryCheckpointStore())
+			}
+			if err := p.validateOrdering(); (err != nil) != tt.wantErr {
+				t.Errorf("validateOrdering() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestPipelineStrictPerKeyOrdering tests that each key stays on one writer in source order
+func TestPipelineStrictPerKeyOrdering(t *testing.T) {
+	var events []Event
+	for i := 0; i < 100; i++ {
+		events = append(events, Event{ID: fmt.Sprintf("key-%d", i%10), Data: map[string]interface{}{"seq": i}})
+	}
+	sink := &concurrentSink{}
+	p := New("test", NewMockSource(events), sink, nil, nil)
+	p.SetOrdering(OrderingStrictPerKey, 4)
+
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if len(sink.writers) != 4 {
+		t.Fatalf("expected 4 writers, got %d", len(sink.writers))
+	}
+	owner := make(map[string]int)
+	last := make(map[string]int)
+	total := 0
+	for w, received := range sink.writers {
+		total += len(received)
+		for _, event := range received {
+			if prev, ok := owner[event.ID]; ok && prev != w {
+				t.Errorf("key %s written by workers %d and %d", event.ID, prev, w)
+			}
+			owner[event.ID] = w
+			seq := event.Data["seq"].(int)
+			if prev, ok := last[event.ID]; ok && seq < prev {
+				t.Errorf("key %s out of order: %d after %d", event.ID, seq, prev)
+			}
+			last[event.ID] = seq
+		}
+	}
+	if total != len(events) {
+		t.Errorf("expected %d events, got %d", len(events), total)
+	}
+}
+
+// TestPipelineStrictPerKeyUsesDocumentKey tests that changes to the same
+// document stay on one writer even though every event has its own ID
+func TestPipelineStrictPerKeyUsesDocumentKey(t *testing.T) {
+	var events []Event
+	for i := 0; i < 100; i++ {
+		events = append(events, Event{
+			ID:   fmt.Sprintf("token-%d", i), // unique per change, like a resume token
+			Data: map[string]interface{}{"_id": fmt.Sprintf("doc-%d", i%5), "seq": i},
+		})
+	}
+	sink := &concurrentSink{}
+	p := New("test", NewMockSource(events), sink, nil, nil)
+	p.SetOrdering(OrderingStrictPerKey, 4)
+
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	owner := make(map[interface{}]int)
+	last := make(map[interface{}]int)
+	for w, received := range sink.writers {
+		for _, event := range received {
+			doc := event.Data["_id"]
+			if prev, ok := owner[doc]; ok && prev != w {
+				t.Errorf("document %v written by workers %d and %d", doc, prev, w)
+			}
+			owner[doc] = w
+			seq := event.Data["seq"].(int)
+			if prev, ok := last[doc]; ok && seq < prev {
+				t.Errorf("document %v out of order: %d after %d", doc, seq, prev)
+			}
+			last[doc] = seq
+		}
+	}
+}
+
+// TestEventKey tests document keys built from single and composite key fields
+func TestEventKey(t *testing.T) {
+	p := New("test", NewMockSource(nil), NewMockSink(), nil, nil)
+	event := Event{ID: "token", Data: map[string]interface{}{"_id": 7, "tenant": "a", "order": 1}}
+	if got := p.eventKey(event); got != "7" {
+		t.Errorf("eventKey() = %q, want %q", got, "7")
+	}
+
+	p.SetKeyFields([]string{"tenant", "order"})
+	other := Event{ID: "token", Data: map[string]interface{}{"tenant": "a", "order": 2}}
+	if p.eventKey(event) == p.eventKey(other) {
+		t.Error("expected documents with different composite keys to differ")
+	}
+	if got := p.eventKey(Event{ID: "token", Data: map[string]interface{}{"tenant": "a"}}); got != "token" {
+		t.Errorf("eventKey() = %q, want the event ID when a key field is missing", got)
+	}
+}
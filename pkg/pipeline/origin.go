@@ -0,0 +1,62 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Origin prevents loops between two pipelines replicating in opposite
+// directions, such as MongoDB to PostgreSQL and back during a gradual
+// migration. Each pipeline stamps the events it hands to its sink with a
+// marker, "<Name>:<event ID>", in the metadata value Field, which the sink
+// stores with the document (the postgresql sink as a column). When the
+// opposite pipeline reads those writes back, it skips every change whose
+// marker names another origin. The event ID makes the marker change with
+// every write, so sources reporting changed fields can tell replicated
+// updates from later application updates that leave an old marker in place.
+type Origin struct {
+	Name  string // tag of the changes this pipeline writes; must not contain ':'
+	Field string // document field or column holding the marker
+}
+
+// SetOrigin makes the pipeline stamp and skip changes as origin describes
+func (p *Pipeline) SetOrigin(origin Origin) error {
+	if origin.Name == "" || origin.Field == "" || strings.Contains(origin.Name, ":") {
+		return fmt.Errorf("origin requires a name without ':' and a field")
+	}
+	p.origin = origin
+	return nil
+}
+
+// foreignOrigin returns the origin of a change another pipeline wrote, or ""
+// for changes made by applications or by this pipeline. The marker is looked
+// up in the event's metadata, where sources that know which fields changed
+// put it, then in its data.
+func (p *Pipeline) foreignOrigin(event Event) string {
+	if p.origin.Field == "" {
+		return ""
+	}
+	marker, ok := event.Metadata[p.origin.Field]
+	if !ok {
+		marker, _ = event.Data[p.origin.Field].(string)
+	}
+	name, _, _ := strings.Cut(marker, ":")
+	if name == p.origin.Name {
+		return ""
+	}
+	return name
+}
+
+// stampOrigin sets the marker of an event handed to the sink. The metadata
+// is copied, as earlier stages may still hold the event.
+func (p *Pipeline) stampOrigin(event *Event) {
+	if p.origin.Name == "" {
+		return
+	}
+	metadata := make(map[string]string, len(event.Metadata)+1)
+	for k, v := range event.Metadata {
+		metadata[k] = v
+	}
+	metadata[p.origin.Field] = p.origin.Name + ":" + event.ID
+	event.Metadata = metadata
+}
@@ -0,0 +1,49 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+)
+
+// TestPipelineOrigin tests that changes of other origins are skipped and
+// written changes are marked
+func TestPipelineOrigin(t *testing.T) {
+	events := []Event{
+		{ID: "1", Operation: "insert", Data: map[string]interface{}{"name": "a"}},
+		{ID: "2", Operation: "insert", Data: map[string]interface{}{"name": "b", "sync_origin": "mongodb:r1"}},
+		{ID: "3", Operation: "update", Data: map[string]interface{}{"name": "c", "sync_origin": "postgresql:r2"}},
+		// An application update to a replicated document keeps its old marker
+		{ID: "4", Operation: "update", Data: map[string]interface{}{"name": "d", "sync_origin": "mongodb:r3"}, Metadata: map[string]string{"sync_origin": ""}},
+		{ID: "5", Operation: "update", Data: map[string]interface{}{"name": "e"}, Metadata: map[string]string{"sync_origin": "mongodb:r4"}},
+	}
+	sink := NewMockSink()
+	p := New("test", NewMockSource(events), sink, nil, nil)
+	if err := p.SetOrigin(Origin{Name: "postgresql", Field: "sync_origin"}); err != nil {
+		t.Fatalf("SetOrigin() error = %v", err)
+	}
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	wantIDs := []string{"1", "3", "4"}
+	if len(sink.received) != len(wantIDs) {
+		t.Fatalf("expected %d events, got %d", len(wantIDs), len(sink.received))
+	}
+	for i, event := range sink.received {
+		if event.ID != wantIDs[i] {
+			t.Errorf("event %d: expected ID %s, got %s", i, wantIDs[i], event.ID)
+		}
+		if want := "postgresql:" + event.ID; event.Metadata["sync_origin"] != want {
+			t.Errorf("event %s: expected marker %q, got %q", event.ID, want, event.Metadata["sync_origin"])
+		}
+	}
+	if events[3].Metadata["sync_origin"] != "" {
+		t.Error("expected the source event's metadata to be left unmodified")
+	}
+
+	for _, origin := range []Origin{{Field: "sync_origin"}, {Name: "postgresql"}, {Name: "a:b", Field: "sync_origin"}} {
+		if err := p.SetOrigin(origin); err == nil {
+			t.Errorf("SetOrigin(%+v): expected error", origin)
+		}
+	}
+}
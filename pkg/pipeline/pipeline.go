@@ -4,7 +4,9 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -18,6 +20,25 @@ type MetricsRecorder interface {
 	SetSinkConnected(connected bool)
 }
 
+// OperationalMetricsRecorder is implemented by metrics recorders that also
+// report failure-path and capacity metrics. The pipeline and its sink use
+// these methods when the recorder passed to SetMetrics supports them.
+type OperationalMetricsRecorder interface {
+	SetQueueDepth(pipelineName, queue string, depth int)
+	SetMemoryUsed(pipelineName string, bytes int64)
+	RecordRetry(pipelineName, component string)
+	SetCircuitBreakerState(pipelineName, component string, state int)
+	RecordDLQWrite(pipelineName, component string)
+	SetLastCheckpoint(pipelineName string, t time.Time)
+}
+
+// MetricsAware is implemented by components that report their own metrics
+// (retries, dead-lettered events). The pipeline passes its recorder to its
+// sink before running.
+type MetricsAware interface {
+	SetMetrics(metrics MetricsRecorder, pipelineName string)
+}
+
 // Pipeline represents a data pipeline from source to sink
 type Pipeline struct {
 	name            string
@@ -26,11 +47,66 @@ type Pipeline struct {
 	transformer     Transformer
 	logger          *log.Logger
 	metrics         MetricsRecorder
+	opMetrics       OperationalMetricsRecorder // metrics, if it reports operational metrics
+	dlq             DeadLetterQueue
+	sizeLimit       SizeLimit
+	validator       EventValidator
+	buffer          EventBuffer
+	wal             WriteAheadLog
+	checkpoints     CheckpointStore
+	operations      map[string]OperationAction
+	errorPolicy     ErrorPolicy
+	budget          errorBudget
+	stopRun         context.CancelCauseFunc
+	drainTimeout    time.Duration
+	hooks           Hooks
+	keyFields       []string
+	tracer          *Tracer
+	resources       *ResourceAccounting
+	latency         *LatencyTracking // end-to-end latency is recorded, see SetLatencyTracking
+	latencyMetrics  LatencyRecorder  // metrics, if it records end-to-end latency
+	watchdog        Watchdog
+	ordering        Ordering
+	workers         int
+	clock           Clock
+	memory          *MemoryBudget
+	chaos           *chaos       // fault injection, see SetChaos
+	capture         *capturer    // raw source events are teed to a capture, see SetCapture
+	throttle        Throttle     // adaptive flow control, see SetThrottle
+	readDelay       atomic.Int64 // pause before each read from the source, in nanoseconds
+	peakSinkLatency atomic.Int64 // longest commit latency since the throttle last adjusted, in nanoseconds
+	audit           AuditLog
+	auditRejected   rejectedSet // event IDs dead-lettered by the sink, see AuditedDeadLetterQueue
+	auditOnCommit   bool        // written outcomes are audited when the sink commits
+	runs            RunStore
+	start           *StartPoint  // where the next run starts instead of the saved position, see SetStartPoint
+	end             EndPoint     // where runs stop reading, see SetEndPoint
+	origin          Origin       // marks written changes and skips those of other pipelines, see SetOrigin
+	resumedFrom     string       // source position the current run resumed from
+	processed       atomic.Int64 // events handed to the sink in the current run
+	committed       atomic.Int64 // events the sink reported as finished in the current run
+	commitsReported atomic.Bool  // the sink reports commits in the current run
+	rejected        atomic.Int64 // events rejected in the current run
+	errorCount      atomic.Int64 // runtime errors since the pipeline was created
+	stage           atomic.Value // what the event-moving goroutine is doing, see DebugState
+	debug           debugLog     // recent events and errors, see DebugState
+	resubmissions   chan Event   // dead-lettered events sent back by Resubmit
+	releaseOnCommit bool         // memory is released when the sink commits rather than on handoff
+	memoryMu        sync.Mutex   // protects inflightBytes
+	inflightBytes   []int64      // bytes reserved per event handed to the sink, oldest first
+	bufferMu        sync.Mutex   // protects bufferInflight
+	bufferInflight  []bool       // whether each event handed to the sink came from the buffer, oldest first
+	commitMu        sync.Mutex   // protects commitsHeld
+	commitsHeld     bool         // a batch failed, so nothing more is released or checkpointed this run
 	startTime       time.Time
 	mu              sync.RWMutex // protects the fields below
 	lastEventTime   time.Time
+	lastSourceTime  time.Time // when the last processed event's change happened at the source
+	readingSince    time.Time // when the event stage last began waiting on the source, see Watchdog
 	sourceConnected bool
 	sinkConnected   bool
+	sourceQueue     <-chan Event // source output awaiting the transformer
+	transformQueue  chan Event   // transformer output awaiting the sink stage
 }
 
 // New creates a new pipeline
@@ -39,18 +115,63 @@ func New(name string, source Source, sink Sink, transformer Transformer, logger
 		logger = log.Default()
 	}
 	return &Pipeline{
-		name:        name,
-		source:      source,
-		sink:        sink,
-		transformer: transformer,
-		logger:      logger,
-		startTime:   time.Now(),
+		name:          name,
+		source:        source,
+		sink:          sink,
+		transformer:   transformer,
+		logger:        logger,
+		clock:         SystemClock,
+		startTime:     time.Now(),
+		resubmissions: make(chan Event),
 	}
 }
 
 // SetMetrics sets the metrics recorder for the pipeline
 func (p *Pipeline) SetMetrics(metrics MetricsRecorder) {
 	p.metrics = metrics
+	p.opMetrics, _ = metrics.(OperationalMetricsRecorder)
+	p.latencyMetrics, _ = metrics.(LatencyRecorder)
+}
+
+// SetClock sets the time source used for event times, uptime and retries
+func (p *Pipeline) SetClock(clock Clock) {
+	p.clock = clock
+	p.startTime = clock.Now()
+}
+
+// SetDeadLetterQueue sets the queue that receives events the pipeline rejects
+func (p *Pipeline) SetDeadLetterQueue(dlq DeadLetterQueue) {
+	p.dlq = dlq
+}
+
+// SetSizeLimit configures the event-size guardrail applied before the sink
+func (p *Pipeline) SetSizeLimit(limit SizeLimit) {
+	p.sizeLimit = limit
+}
+
+// SetValidator sets the validator source events are checked with before
+// they are transformed; rejected events go to the dead-letter queue
+func (p *Pipeline) SetValidator(validator EventValidator) {
+	p.validator = validator
+}
+
+// SetBuffer sets a durable buffer that absorbs events while the sink is slow or
+// down. A CommittableBuffer only releases events once the sink commits them.
+func (p *Pipeline) SetBuffer(buffer EventBuffer) {
+	p.buffer = buffer
+}
+
+// SourceReady returns a channel closed once the source is capturing changes.
+// Sources that do not report readiness are ready as soon as they connect.
+func (p *Pipeline) SourceReady() <-chan struct{} {
+	if notifier, ok := p.source.(ReadyNotifier); ok {
+		if ready := notifier.Ready(); ready != nil {
+			return ready
+		}
+	}
+	ready := make(chan struct{})
+	close(ready)
+	return ready
 }
 
 // IsHealthy returns true if the pipeline is healthy
@@ -62,6 +183,15 @@ func (p *Pipeline) IsHealthy() bool {
 
 // isHealthyLocked returns true if the pipeline is healthy (caller must hold read lock)
 func (p *Pipeline) isHealthyLocked() bool {
+	if p.budget.exhaustedReason() != "" {
+		return false
+	}
+	if reporter, ok := p.sink.(BreakerReporter); ok && reporter.BreakerState() == BreakerOpen {
+		return false
+	}
+	if _, stalled := p.sourceStalledLocked(); stalled {
+		return false
+	}
 	return p.sourceConnected && p.sinkConnected
 }
 
@@ -70,7 +200,7 @@ func (p *Pipeline) GetStatus() HealthStatus {
 	p.mu.RLock()
 	defer p.mu.RUnlock()
 	
-	uptime := time.Since(p.startTime).Seconds()
+	uptime := p.clock.Since(p.startTime).Seconds()
 	
 	var lastEventTimeStr string
 	if !p.lastEventTime.IsZero() {
@@ -78,14 +208,23 @@ func (p *Pipeline) GetStatus() HealthStatus {
 	}
 	
 	healthy := p.isHealthyLocked()
+	running := p.sourceConnected && p.sinkConnected
+
+	var breakerState string
+	if reporter, ok := p.sink.(BreakerReporter); ok {
+		breakerState = reporter.BreakerState().String()
+	}
+	_, stalled := p.sourceStalledLocked()
 	
 	return HealthStatus{
 		Healthy:          healthy,
-		PipelineRunning:  healthy,
+		PipelineRunning:  running,
 		SourceConnected:  p.sourceConnected,
 		SinkConnected:    p.sinkConnected,
 		LastEventTime:    lastEventTimeStr,
 		UptimeSeconds:    int64(uptime),
+		CircuitBreaker:   breakerState,
+		SourceStalled:    stalled,
 	}
 }
 
@@ -97,11 +236,27 @@ type HealthStatus struct {
 	SinkConnected    bool   `json:"sink_connected"`
 	LastEventTime    string `json:"last_event_time,omitempty"`
 	UptimeSeconds    int64  `json:"uptime_seconds"`
+	CircuitBreaker   string `json:"circuit_breaker,omitempty"`
+	SourceStalled    bool   `json:"source_stalled,omitempty"`
 }
 
 // Run starts the pipeline
 func (p *Pipeline) Run(ctx context.Context) error {
+	finish := p.startRun(ctx)
+	err := p.run(ctx)
+	finish(err)
+	if p.hooks.OnStop != nil {
+		p.hooks.OnStop(err)
+	}
+	return err
+}
+
+// run connects the source and sink and moves events until the source ends
+func (p *Pipeline) run(ctx context.Context) error {
 	p.logger.Printf("Starting pipeline: %s", p.name)
+	if err := p.validateOrdering(); err != nil {
+		return err
+	}
 	
 	// Set pipeline status to running
 	if p.metrics != nil {
@@ -110,7 +265,7 @@ func (p *Pipeline) Run(ctx context.Context) error {
 	}
 
 	// Connect source
-	startTime := time.Now()
+	startTime := p.clock.Now()
 	if err := p.source.Connect(ctx); err != nil {
 		if p.metrics != nil {
 			p.metrics.RecordEventError(p.name, "source", "connection_error")
@@ -123,7 +278,7 @@ func (p *Pipeline) Run(ctx context.Context) error {
 	p.mu.Unlock()
 	if p.metrics != nil {
 		p.metrics.SetSourceConnected(true)
-		p.metrics.RecordProcessingDuration(p.name, "source_connect", time.Since(startTime).Seconds())
+		p.metrics.RecordProcessingDuration(p.name, "source_connect", p.clock.Since(startTime).Seconds())
 	}
 	defer func() {
 		p.source.Close()
@@ -136,7 +291,7 @@ func (p *Pipeline) Run(ctx context.Context) error {
 	}()
 
 	// Connect sink
-	startTime = time.Now()
+	startTime = p.clock.Now()
 	if err := p.sink.Connect(ctx); err != nil {
 		if p.metrics != nil {
 			p.metrics.RecordEventError(p.name, "sink", "connection_error")
@@ -149,7 +304,7 @@ func (p *Pipeline) Run(ctx context.Context) error {
 	p.mu.Unlock()
 	if p.metrics != nil {
 		p.metrics.SetSinkConnected(true)
-		p.metrics.RecordProcessingDuration(p.name, "sink_connect", time.Since(startTime).Seconds())
+		p.metrics.RecordProcessingDuration(p.name, "sink_connect", p.clock.Since(startTime).Seconds())
 	}
 	defer func() {
 		p.sink.Close()
@@ -161,45 +316,268 @@ func (p *Pipeline) Run(ctx context.Context) error {
 		}
 	}()
 
-	// Start reading from source
-	events, sourceErrors := p.source.Read(ctx)
+	// Report queue depths and component state while running
+	if p.metrics != nil {
+		if aware, ok := p.sink.(MetricsAware); ok {
+			aware.SetMetrics(p.metrics, p.name)
+		}
+	}
+	if p.opMetrics != nil {
+		sampleCtx, stopSampling := context.WithCancel(ctx)
+		defer stopSampling()
+		go p.sampleMetrics(sampleCtx)
+	}
+
+	// Resume from the last durable position and track sink commits
+	if err := p.restorePosition(ctx); err != nil {
+		return err
+	}
+	p.releaseOnCommit = false
+	if p.memory != nil && p.wal == nil && p.buffer == nil {
+		// With a disk buffer or WAL, events leave memory once that stage has taken them
+		p.releaseOnCommit = reportsCommits(p.sink)
+	}
+	// Events still awaiting a commit at shutdown are re-read on restart
+	defer p.releaseCommitted(math.MaxInt)
+	p.resetCommits()
+	_, bufferCommits := p.buffer.(CommittableBuffer)
+	_, acknowledges := p.source.(Acknowledger)
+	if p.wal != nil || p.checkpoints != nil || p.releaseOnCommit || p.audit != nil || p.tracer != nil || bufferCommits ||
+		p.errorPolicy.OnSinkError == SinkErrorDLQ || p.hooks.OnBatchCommitted != nil || p.latency != nil || acknowledges ||
+		p.throttle.MaxSinkLatency > 0 {
+		if acker, ok := p.sink.(AckNotifier); ok {
+			acker.SetAckHandler(p.onAck)
+		} else if notifier, ok := p.sink.(CommitNotifier); ok {
+			notifier.SetCommitHandler(p.onCommit)
+		}
+		if reportsCommits(p.sink) {
+			p.commitsReported.Store(true)
+			p.auditOnCommit = p.audit != nil
+		} else if p.wal != nil {
+			return fmt.Errorf("store-and-forward mode requires a sink that reports commits")
+		} else if p.errorPolicy.OnSinkError == SinkErrorDLQ {
+			return fmt.Errorf("dead-lettering failed batches requires a sink that reports commits")
+		} else if bufferCommits {
+			return fmt.Errorf("spill buffer requires a sink that reports commits")
+		} else if p.checkpoints != nil {
+			p.logger.Println("Warning: sink does not report commits, checkpoints will not advance")
+		} else if acknowledges {
+			p.logger.Println("Warning: sink does not report commits, the source is never acknowledged")
+		} else if p.throttle.MaxSinkLatency > 0 {
+			p.logger.Println("Warning: sink does not report commits, reads are only throttled on queue depth")
+		} else if p.hooks.OnBatchCommitted != nil {
+			p.logger.Println("Warning: sink does not report commits, the OnBatchCommitted hook is never called")
+		}
+	}
+
+	// An operation or error policy may stop the run with an error
+	parent := ctx
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+	p.stopRun = stop
+	p.budget.reset()
+
+	// The stages after the source may outlive it to drain in-flight events
+	stageCtx, stopStages := p.drainContext(parent, ctx)
+	defer stopStages()
+
+	// Slow reads down while the sink lags
+	if p.throttled() {
+		throttleCtx, stopThrottle := context.WithCancel(stageCtx)
+		defer stopThrottle()
+		go p.runThrottle(throttleCtx)
+	}
+
+	if p.hooks.OnStart != nil {
+		p.hooks.OnStart()
+	}
+
+	// Start reading from source; reaching the end point stops only the source
+	sourceCtx, stopSource := context.WithCancel(ctx)
+	defer stopSource()
+	events, sourceErrors := p.readSource(sourceCtx)
 
 	// Transform events if transformer is provided
 	transformedEvents := make(chan Event)
+	p.setStageQueues(events, transformedEvents)
+	defer p.setStageQueues(nil, nil)
 	go func() {
 		defer close(transformedEvents)
-		for event := range events {
-			eventStartTime := time.Now()
+		defer p.setStage(StageStopped)
+		lastChange := p.clock.Now()
+		for {
+			if !p.pauseRead(stageCtx) {
+				return
+			}
+			p.setStage(StageReading)
+			p.waitForSource()
+			var event Event
+			resubmitted := false
+			select {
+			case <-stageCtx.Done():
+				return
+			case <-p.idleTimeout(lastChange):
+				p.logger.Printf("No changes for %s, ending the run", p.end.Idle)
+				stopSource()
+				return
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				event = e
+			case event = <-p.resubmissions:
+				// Already transformed and forwarded before it was dead-lettered
+				resubmitted = true
+			}
+			if !resubmitted && p.pastEnd(event) {
+				stopSource()
+				return
+			}
+			if event.Operation == OperationHeartbeat && !resubmitted {
+				p.heartbeat(event)
+				continue
+			}
+			if !resubmitted {
+				p.captureEvent(event)
+				p.resources.Read(event)
+				lastChange = p.clock.Now()
+			}
+			eventStartTime := p.clock.Now()
+			if event.IngestedAt.IsZero() {
+				event.IngestedAt = eventStartTime
+			}
+			p.debug.addEvent(event, eventStartTime)
+			p.tracer.Follow(event)
 			p.mu.Lock()
 			p.lastEventTime = eventStartTime
+			if !event.Timestamp.IsZero() && !resubmitted {
+				p.lastSourceTime = event.Timestamp
+			}
 			p.mu.Unlock()
+
+			if origin := p.foreignOrigin(event); origin != "" && !resubmitted {
+				p.tracer.Done(TraceRejected, event, "written by the "+origin+" pipeline")
+				continue
+			}
+
+			if !resubmitted {
+				forward, err := p.handleOperation(stageCtx, event)
+				if err != nil {
+					p.logger.Printf("Stopping pipeline: %v", err)
+					stop(err)
+					return
+				}
+				if !forward {
+					p.tracer.Done(TraceRejected, event, "not forwarded by the operation policy")
+					continue
+				}
+			}
+
+			if p.validator != nil && !resubmitted {
+				validated, err := p.validator.Validate(event)
+				if err != nil {
+					p.logger.Printf("Rejecting event %s: %v", event.ID, err)
+					p.recordError("pipeline", "schema_violation", err)
+					p.deadLetter(stageCtx, event, "schema_violation", err.Error())
+					continue
+				}
+				event = validated
+			}
 			
-			if p.transformer != nil {
-				transformed, err := p.transformer.Transform(event)
+			if !p.chaos.delay(stageCtx, p.clock) {
+				return
+			}
+			if (p.transformer != nil || p.chaos != nil) && !resubmitted {
+				p.setStage(StageTransforming)
+				transformed, err := p.transform(stageCtx, event)
 				if err != nil {
 					p.logger.Printf("Error transforming event: %v", err)
-					if p.metrics != nil {
-						p.metrics.RecordEventError(p.name, "transformer", "transform_error")
+					p.recordError("transformer", "transform_error", err)
+					switch p.errorPolicy.OnTransformError {
+					case TransformErrorDLQ:
+						p.deadLetter(stageCtx, event, "transform_error", err.Error())
+					case TransformErrorFail:
+						p.tracer.Done(TraceRejected, event, err.Error())
+						stop(fmt.Errorf("failed to transform event %s: %w", event.ID, err))
+						return
+					default:
+						p.countRejected()
+						p.auditEvents(stageCtx, AuditTransformError, err.Error(), []Event{event})
+						p.tracer.Done(TraceRejected, event, err.Error())
 					}
 					continue
 				}
 				event = transformed
+				p.tracer.Record(TraceTransformed, event, "")
 				if p.metrics != nil {
-					p.metrics.RecordProcessingDuration(p.name, "transform", time.Since(eventStartTime).Seconds())
+					p.metrics.RecordProcessingDuration(p.name, "transform", p.clock.Since(eventStartTime).Seconds())
 				}
 			}
 			
-			// Record event processed by operation type
-			if p.metrics != nil {
-				p.metrics.RecordEventProcessed(p.name, event.Operation)
+			// Enforce the event-size guardrail
+			limited, err := p.sizeLimit.Apply(event)
+			if err != nil {
+				p.logger.Printf("Rejecting oversized event %s: %v", event.ID, err)
+				p.recordError("pipeline", "oversized_event", err)
+				p.deadLetter(stageCtx, event, "oversized_event", err.Error())
+				continue
+			}
+
+			for _, e := range p.chaos.duplicate(limited) {
+				p.stampOrigin(&e)
+
+				// Record event processed by operation type
+				if p.metrics != nil {
+					p.metrics.RecordEventProcessed(p.name, e.Operation)
+				}
+
+				// Hold the event back while the memory budget is exhausted
+				p.setStage(StageReserving)
+				n, err := p.reserveMemory(stageCtx, e)
+				if err != nil {
+					p.logger.Printf("Rejecting event %s, no memory could be reserved: %v", e.ID, err)
+					p.recordError("pipeline", "memory_budget", err)
+					p.deadLetter(stageCtx, e, "memory_budget", err.Error())
+					continue
+				}
+				p.trackMemory(n)
+
+				p.setStage(StageHandoff)
+				p.tracer.Record(TraceHandoff, e, "")
+				if !p.chaos.delay(stageCtx, p.clock) {
+					p.releaseUnsent(n)
+					return
+				}
+				select {
+				case transformedEvents <- e:
+				case <-stageCtx.Done():
+					// Not checkpointed, so it is read again on the next run
+					p.releaseUnsent(n)
+					return
+				}
+				p.releaseHandedOff(n)
+				p.countProcessed()
+				if p.audit != nil && !p.auditOnCommit {
+					p.auditEvents(stageCtx, AuditSent, "", []Event{e})
+				}
+				if !p.commitsReported.Load() {
+					p.observeLatency([]Event{e})
+					p.resources.Written([]Event{e})
+				}
 			}
-			
-			transformedEvents <- event
 		}
 	}()
 
+	// Spill to the buffer when the sink falls behind
+	var sinkInput <-chan Event = transformedEvents
+	if p.wal != nil {
+		sinkInput = p.runWAL(stageCtx, transformedEvents)
+	} else if p.buffer != nil {
+		sinkInput = p.runBuffer(stageCtx, transformedEvents)
+	}
+
 	// Write to sink
-	sinkErrors := p.sink.Write(ctx, transformedEvents)
+	sinkErrors := p.writeSink(stageCtx, sinkInput)
 
 	// Handle errors
 	var wg sync.WaitGroup
@@ -209,9 +587,7 @@ func (p *Pipeline) Run(ctx context.Context) error {
 		defer wg.Done()
 		for err := range sourceErrors {
 			p.logger.Printf("Source error: %v", err)
-			if p.metrics != nil {
-				p.metrics.RecordEventError(p.name, "source", "read_error")
-			}
+			p.recordError("source", "read_error", err)
 		}
 	}()
 
@@ -219,13 +595,42 @@ func (p *Pipeline) Run(ctx context.Context) error {
 		defer wg.Done()
 		for err := range sinkErrors {
 			p.logger.Printf("Sink error: %v", err)
-			if p.metrics != nil {
-				p.metrics.RecordEventError(p.name, "sink", "write_error")
+			p.recordError("sink", "write_error", err)
+			if p.errorPolicy.OnSinkError == SinkErrorFail {
+				stop(fmt.Errorf("sink error: %w", err))
 			}
+			p.countSinkError()
 		}
 	}()
 
 	wg.Wait()
 	p.logger.Printf("Pipeline stopped: %s", p.name)
+	if parent.Err() == nil {
+		if err := context.Cause(ctx); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// deadLetter routes a rejected event to the dead-letter queue, or drops it if none is configured
+func (p *Pipeline) deadLetter(ctx context.Context, event Event, rule, reason string) {
+	event = WithRejectionRule(event, rule)
+	p.countRejected()
+	p.tracer.Done(TraceRejected, event, reason)
+	if p.dlq == nil {
+		p.logger.Printf("No dead-letter queue configured, dropping event %s", event.ID)
+		p.auditEvents(ctx, AuditDropped, reason, []Event{event})
+		return
+	}
+	if err := p.dlq.Send(ctx, event, reason); err != nil {
+		p.logger.Printf("Failed to dead-letter event %s: %v", event.ID, err)
+		p.recordError("dlq", "write_error", err)
+		p.auditEvents(ctx, AuditDropped, reason, []Event{event})
+		return
+	}
+	if p.opMetrics != nil {
+		p.opMetrics.RecordDLQWrite(p.name, "pipeline")
+	}
+	p.auditEvents(ctx, AuditDeadLettered, reason, []Event{event})
+}
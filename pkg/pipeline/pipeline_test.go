@@ -2,6 +2,7 @@ package pipeline
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 )
@@ -83,7 +84,7 @@ func NewMockTransformer(prefix string) *MockTransformer {
 	return &MockTransformer{prefix: prefix}
 }
 
-func (m *MockTransformer) Transform(event Event) (Event, error) {
+func (m *MockTransformer) Transform(ctx context.Context, event Event) (Event, error) {
 	event.ID = m.prefix + event.ID
 	return event, nil
 }
@@ -119,16 +120,8 @@ func TestPipelineBasic(t *testing.T) {
 	// Create pipeline
 	pipeline := New("test-pipeline", source, sink, nil, nil)
 
-	// Run pipeline
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	go func() {
-		time.Sleep(100 * time.Millisecond)
-		cancel()
-	}()
-
-	err := pipeline.Run(ctx)
+	// Run pipeline; it stops on its own once the source is exhausted
+	err := pipeline.Run(context.Background())
 	if err != nil {
 		t.Fatalf("Pipeline.Run() error = %v", err)
 	}
@@ -139,6 +132,30 @@ func TestPipelineBasic(t *testing.T) {
 	}
 }
 
+// TestPipelineIngestedAt tests that events are stamped with their ingestion time unless the source did
+func TestPipelineIngestedAt(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	read := now.Add(-time.Second)
+	sink := NewMockSink()
+	p := New("test", NewMockSource([]Event{
+		{ID: "1", Operation: "insert", Timestamp: now.Add(-time.Hour)},
+		{ID: "2", Operation: "insert", IngestedAt: read},
+	}), sink, nil, nil)
+	p.SetClock(NewManualClock(now))
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(sink.received) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(sink.received))
+	}
+	if got := sink.received[0]; !got.IngestedAt.Equal(now) || !got.Timestamp.Equal(now.Add(-time.Hour)) {
+		t.Errorf("expected the change time kept and the ingestion time set, got %+v", got)
+	}
+	if got := sink.received[1]; !got.IngestedAt.Equal(read) || !got.Timestamp.IsZero() {
+		t.Errorf("expected the source's ingestion time kept and no change time, got %+v", got)
+	}
+}
+
 // TestPipelineWithTransformer tests pipeline with transformer
 func TestPipelineWithTransformer(t *testing.T) {
 	// Create mock events
@@ -159,16 +176,8 @@ func TestPipelineWithTransformer(t *testing.T) {
 	// Create pipeline
 	pipeline := New("test-pipeline", source, sink, transformer, nil)
 
-	// Run pipeline
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	go func() {
-		time.Sleep(100 * time.Millisecond)
-		cancel()
-	}()
-
-	err := pipeline.Run(ctx)
+	// Run pipeline; it stops on its own once the source is exhausted
+	err := pipeline.Run(context.Background())
 	if err != nil {
 		t.Fatalf("Pipeline.Run() error = %v", err)
 	}
@@ -182,3 +191,117 @@ func TestPipelineWithTransformer(t *testing.T) {
 		t.Errorf("Expected ID 'PREFIX_1', got '%s'", sink.received[0].ID)
 	}
 }
+
+// readySource is a mock source that reports when it is capturing changes
+type readySource struct {
+	MockSource
+	ready chan struct{}
+}
+
+func (r *readySource) Ready() <-chan struct{} {
+	return r.ready
+}
+
+// legacyPrefixTransformer is a transformer without a context
+type legacyPrefixTransformer struct{}
+
+func (legacyPrefixTransformer) Transform(event Event) (Event, error) {
+	event.ID = "legacy_" + event.ID
+	return event, nil
+}
+
+// blockingTransformer blocks until its context is cancelled
+type blockingTransformer struct {
+	started chan struct{}
+}
+
+func (b blockingTransformer) Transform(ctx context.Context, event Event) (Event, error) {
+	close(b.started)
+	<-ctx.Done()
+	return event, ctx.Err()
+}
+
+// TestPipelineTransformerContext tests that legacy transformers can be adapted and that
+// transformers are cancelled when the pipeline stops
+func TestPipelineTransformerContext(t *testing.T) {
+	sink := NewMockSink()
+	p := New("test", NewMockSource([]Event{{ID: "1", Operation: "insert"}}), sink, AdaptTransformer(legacyPrefixTransformer{}), nil)
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(sink.received) != 1 || sink.received[0].ID != "legacy_1" {
+		t.Errorf("expected the adapted transformer to run, got %+v", sink.received)
+	}
+
+	transformer := blockingTransformer{started: make(chan struct{})}
+	p = New("test", NewMockSource([]Event{{ID: "1", Operation: "insert"}}), NewMockSink(), transformer, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- p.Run(ctx) }()
+	<-transformer.started
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("pipeline did not stop while the transformer was waiting")
+	}
+}
+
+// TestPipelineSourceReady tests that readiness follows the source's signal when it has one
+func TestPipelineSourceReady(t *testing.T) {
+	p := New("test", NewMockSource(nil), NewMockSink(), nil, nil)
+	select {
+	case <-p.SourceReady():
+	default:
+		t.Error("expected a source without readiness reporting to be ready")
+	}
+
+	source := &readySource{ready: make(chan struct{})}
+	p = New("test", source, NewMockSink(), nil, nil)
+	select {
+	case <-p.SourceReady():
+		t.Fatal("expected source not ready before it signals")
+	default:
+	}
+	close(source.ready)
+	select {
+	case <-p.SourceReady():
+	default:
+		t.Error("expected source ready after it signals")
+	}
+}
+
+// validatorFunc adapts a function to EventValidator
+type validatorFunc func(event Event) (Event, error)
+
+func (f validatorFunc) Validate(event Event) (Event, error) {
+	return f(event)
+}
+
+// TestPipelineValidator tests that events failing validation are dead-lettered before the transformer
+func TestPipelineValidator(t *testing.T) {
+	events := []Event{
+		{ID: "1", Operation: "insert", Data: map[string]interface{}{"age": 30}},
+		{ID: "2", Operation: "insert", Data: map[string]interface{}{"age": "thirty"}},
+	}
+	sink := NewMockSink()
+	dlq := &collectingDLQ{}
+	p := New("test", NewMockSource(events), sink, NewMockTransformer("T_"), nil)
+	p.SetDeadLetterQueue(dlq)
+	p.SetValidator(validatorFunc(func(event Event) (Event, error) {
+		if _, ok := event.Data["age"].(int); !ok {
+			return event, fmt.Errorf("age is not an integer")
+		}
+		return event, nil
+	}))
+
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(sink.received) != 1 || sink.received[0].ID != "T_1" {
+		t.Errorf("expected only the valid event to be transformed and written, got %v", sink.received)
+	}
+	if len(dlq.events) != 1 || dlq.events[0].ID != "2" {
+		t.Errorf("expected the invalid event to be dead-lettered untransformed, got %v", dlq.events)
+	}
+}
@@ -0,0 +1,80 @@
+package pipeline
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// pooling controls whether pipeline stages return Event.Data maps and batch
+// slices to the shared pools once they are done with them
+var pooling atomic.Bool
+
+// dataPool recycles Event.Data maps
+var dataPool = sync.Pool{
+	New: func() interface{} { return make(map[string]interface{}, 16) },
+}
+
+// batchPool recycles sink batch slices
+var batchPool = sync.Pool{
+	New: func() interface{} { return new([]Event) },
+}
+
+// SetPooling enables or disables recycling of event data maps and batches
+// across stages. With pooling enabled, a sink owns the events it receives and
+// their Data maps must not be retained after the sink has written them.
+func SetPooling(enabled bool) {
+	pooling.Store(enabled)
+}
+
+// PoolingEnabled reports whether event pooling is enabled
+func PoolingEnabled() bool {
+	return pooling.Load()
+}
+
+// NewData returns an empty map for Event.Data, reusing a released one if available
+func NewData() map[string]interface{} {
+	return dataPool.Get().(map[string]interface{})
+}
+
+// ReleaseData returns a Data map to the pool. Only call it once nothing
+// references the map any more.
+func ReleaseData(data map[string]interface{}) {
+	if data == nil {
+		return
+	}
+	clear(data)
+	dataPool.Put(data)
+}
+
+// ReleaseEvents returns the Data maps of written events to the pool if
+// pooling is enabled
+func ReleaseEvents(events []Event) {
+	if !pooling.Load() {
+		return
+	}
+	for i := range events {
+		ReleaseData(events[i].Data)
+		events[i].Data = nil
+	}
+}
+
+// GetBatch returns an empty batch slice with at least the given capacity
+func GetBatch(capacity int) []Event {
+	if pooling.Load() {
+		batch := *batchPool.Get().(*[]Event)
+		if cap(batch) >= capacity {
+			return batch[:0]
+		}
+	}
+	return make([]Event, 0, capacity)
+}
+
+// PutBatch returns a batch slice to the pool if pooling is enabled
+func PutBatch(batch []Event) {
+	if !pooling.Load() || batch == nil {
+		return
+	}
+	clear(batch[:cap(batch)])
+	batch = batch[:0]
+	batchPool.Put(&batch)
+}
@@ -0,0 +1,79 @@
+package pipeline
+
+import (
+	"strconv"
+	"testing"
+)
+
+// TestReleaseEventsRequiresPooling tests that stages only recycle data when pooling is on
+func TestReleaseEventsRequiresPooling(t *testing.T) {
+	defer SetPooling(false)
+
+	events := []Event{{ID: "1", Data: map[string]interface{}{"a": 1}}}
+	ReleaseEvents(events)
+	if events[0].Data == nil || events[0].Data["a"] != 1 {
+		t.Fatal("expected data to be kept with pooling disabled")
+	}
+
+	SetPooling(true)
+	data := events[0].Data
+	ReleaseEvents(events)
+	if events[0].Data != nil {
+		t.Error("expected released event data to be cleared from the event")
+	}
+	if len(data) != 0 {
+		t.Errorf("expected released map to be emptied, got %v", data)
+	}
+}
+
+// TestGetBatchReuse tests that pooled batches come back empty with enough capacity
+func TestGetBatchReuse(t *testing.T) {
+	defer SetPooling(false)
+	SetPooling(true)
+
+	batch := GetBatch(10)
+	batch = append(batch, Event{ID: "1"}, Event{ID: "2"})
+	PutBatch(batch)
+
+	for i := 0; i < 3; i++ {
+		reused := GetBatch(10)
+		if len(reused) != 0 || cap(reused) < 10 {
+			t.Fatalf("expected empty batch with capacity >= 10, got len %d cap %d", len(reused), cap(reused))
+		}
+		PutBatch(reused)
+	}
+
+	if big := GetBatch(1000); cap(big) < 1000 {
+		t.Errorf("expected capacity >= 1000, got %d", cap(big))
+	}
+}
+
+// benchmarkStages simulates a source filling Data maps and a sink writing them in batches
+func benchmarkStages(b *testing.B, enabled bool) {
+	SetPooling(enabled)
+	defer SetPooling(false)
+
+	keys := make([]string, 10)
+	for i := range keys {
+		keys[i] = "field_" + strconv.Itoa(i)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	batch := GetBatch(100)
+	for i := 0; i < b.N; i++ {
+		event := Event{ID: "doc", Operation: "insert", Data: NewData()}
+		for _, key := range keys {
+			event.Data[key] = key
+		}
+		batch = append(batch, event)
+		if len(batch) == 100 {
+			ReleaseEvents(batch)
+			PutBatch(batch)
+			batch = GetBatch(100)
+		}
+	}
+}
+
+func BenchmarkEventStagesPooled(b *testing.B)   { benchmarkStages(b, true) }
+func BenchmarkEventStagesUnpooled(b *testing.B) { benchmarkStages(b, false) }
@@ -0,0 +1,142 @@
+package pipeline
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// progressReportInterval is how many copied documents pass between progress
+// logs and metric updates, so a fast snapshot does not flood push-based backends
+const progressReportInterval = 1000
+
+// SyncProgressRecorder is implemented by metrics recorders that report
+// initial sync progress
+type SyncProgressRecorder interface {
+	SetInitialSyncProgress(pipelineName string, copied, estimated int64)
+	SetInitialSyncCompletion(pipelineName string, percent float64, eta time.Duration)
+}
+
+// SyncEstimate is the expected size of an initial sync
+type SyncEstimate struct {
+	Documents int64 // documents the sync is expected to read; 0 if unknown
+	Bytes     int64 // uncompressed size of those documents; 0 if unknown
+}
+
+// SyncProgressState is a point-in-time view of an initial sync
+type SyncProgressState struct {
+	Active         bool          // a sync is in progress
+	StartedAt      time.Time     // when the current or last sync started
+	Copied         int64         // documents read from the source so far
+	EstimatedTotal int64         // documents the sync is expected to read; 0 if unknown
+	EstimatedBytes int64         // uncompressed size of those documents; 0 if unknown
+	CurrentID      string        // _id of the last document read
+	Percent        float64       // percentage complete, capped at 100; 0 if unknown
+	ETA            time.Duration // estimated time remaining; 0 if unknown
+}
+
+// SyncProgress tracks the progress of initial syncs so it can be logged,
+// served by the health endpoint and reported as metrics. It is safe for
+// concurrent use.
+type SyncProgress struct {
+	name    string
+	clock   Clock
+	logger  *log.Logger
+	metrics SyncProgressRecorder
+
+	mu    sync.Mutex
+	state SyncProgressState
+}
+
+// NewSyncProgress creates a progress tracker for a pipeline's initial syncs
+func NewSyncProgress(pipelineName string, logger *log.Logger) *SyncProgress {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &SyncProgress{name: pipelineName, clock: SystemClock, logger: logger}
+}
+
+// SetClock sets the time source used for start times and estimates
+func (s *SyncProgress) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+// SetMetrics reports progress to the recorder if it supports SyncProgressRecorder
+func (s *SyncProgress) SetMetrics(metrics MetricsRecorder) {
+	s.metrics, _ = metrics.(SyncProgressRecorder)
+}
+
+// Start begins tracking a sync of the estimated size
+func (s *SyncProgress) Start(estimate SyncEstimate) {
+	s.mu.Lock()
+	s.state = SyncProgressState{
+		Active:         true,
+		StartedAt:      s.clock.Now(),
+		EstimatedTotal: estimate.Documents,
+		EstimatedBytes: estimate.Bytes,
+	}
+	s.mu.Unlock()
+	s.report(s.State())
+}
+
+// Advance records a document read from the source, logging and reporting
+// progress every progressReportInterval documents
+func (s *SyncProgress) Advance(id string) {
+	s.mu.Lock()
+	s.state.Copied++
+	s.state.CurrentID = id
+	copied := s.state.Copied
+	s.mu.Unlock()
+	if copied%progressReportInterval != 0 {
+		return
+	}
+
+	state := s.State()
+	s.report(state)
+	switch {
+	case state.EstimatedTotal == 0:
+		s.logger.Printf("Initial sync progress: %d documents synced", state.Copied)
+	case state.ETA > 0:
+		s.logger.Printf("Initial sync progress: %d/%d documents (%.1f%%), about %s remaining",
+			state.Copied, state.EstimatedTotal, state.Percent, state.ETA.Round(time.Second))
+	default:
+		s.logger.Printf("Initial sync progress: %d/%d documents (%.1f%%)", state.Copied, state.EstimatedTotal, state.Percent)
+	}
+}
+
+// Finish ends tracking of the current sync, keeping its final counts
+func (s *SyncProgress) Finish() {
+	s.mu.Lock()
+	s.state.Active = false
+	s.mu.Unlock()
+	s.report(s.State())
+}
+
+// State returns the progress of the current or last sync. The ETA assumes
+// the remaining documents are read at the average rate so far.
+func (s *SyncProgress) State() SyncProgressState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state := s.state
+	if state.EstimatedTotal > 0 {
+		state.Percent = float64(state.Copied) / float64(state.EstimatedTotal) * 100
+		if state.Percent > 100 {
+			state.Percent = 100
+		}
+	}
+	if state.Active && state.Copied > 0 && state.EstimatedTotal > state.Copied {
+		elapsed := s.clock.Since(state.StartedAt)
+		remaining := state.EstimatedTotal - state.Copied
+		state.ETA = time.Duration(float64(elapsed) / float64(state.Copied) * float64(remaining))
+	}
+	return state
+}
+
+// report sends a progress state to the metrics recorder, if any
+func (s *SyncProgress) report(state SyncProgressState) {
+	if s.metrics != nil {
+		s.metrics.SetInitialSyncProgress(s.name, state.Copied, state.EstimatedTotal)
+		s.metrics.SetInitialSyncCompletion(s.name, state.Percent, state.ETA)
+	}
+}
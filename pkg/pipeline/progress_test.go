@@ -0,0 +1,83 @@
+package pipeline
+
+import (
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+// progressMetrics records the last initial sync progress it was sent
+type progressMetrics struct {
+	recordingMetrics
+	copied, estimated int64
+	percent           float64
+	eta               time.Duration
+	reports           int
+}
+
+func (m *progressMetrics) SetInitialSyncProgress(pipelineName string, copied, estimated int64) {
+	m.copied, m.estimated = copied, estimated
+	m.reports++
+}
+
+func (m *progressMetrics) SetInitialSyncCompletion(pipelineName string, percent float64, eta time.Duration) {
+	m.percent, m.eta = percent, eta
+}
+
+// TestSyncProgress tests progress counts, the completion estimate and metric reporting
+func TestSyncProgress(t *testing.T) {
+	clock := NewManualClock(time.Unix(1700000000, 0))
+	recorder := &progressMetrics{}
+	progress := NewSyncProgress("test", log.New(io.Discard, "", 0))
+	progress.SetClock(clock)
+	progress.SetMetrics(recorder)
+
+	progress.Start(SyncEstimate{Documents: 4000, Bytes: 4 << 20})
+	for i := 0; i < 1000; i++ {
+		progress.Advance("doc")
+	}
+	clock.Advance(10 * time.Second)
+
+	state := progress.State()
+	if !state.Active || state.Copied != 1000 || state.EstimatedTotal != 4000 || state.EstimatedBytes != 4<<20 || state.CurrentID != "doc" {
+		t.Errorf("unexpected state %+v", state)
+	}
+	if state.Percent != 25 || state.ETA != 30*time.Second {
+		t.Errorf("completion = %v%%, ETA %v, want 25%%, 30s", state.Percent, state.ETA)
+	}
+	if recorder.copied != 1000 || recorder.estimated != 4000 || recorder.reports != 2 {
+		t.Errorf("metrics = %d/%d after %d reports, want 1000/4000 after 2", recorder.copied, recorder.estimated, recorder.reports)
+	}
+
+	progress.Advance("last")
+	progress.Finish()
+	state = progress.State()
+	if state.Active || state.Copied != 1001 || state.ETA != 0 {
+		t.Errorf("unexpected state after finish %+v", state)
+	}
+	if recorder.copied != 1001 || recorder.eta != 0 {
+		t.Errorf("expected the final count without an ETA to be reported, got %d, %v", recorder.copied, recorder.eta)
+	}
+}
+
+// TestSyncProgressUnknownTotal tests that no ETA is given without an estimate
+func TestSyncProgressUnknownTotal(t *testing.T) {
+	progress := NewSyncProgress("test", log.New(io.Discard, "", 0))
+	progress.Start(SyncEstimate{})
+	progress.Advance("1")
+	if state := progress.State(); state.ETA != 0 || state.Percent != 0 || state.Copied != 1 {
+		t.Errorf("unexpected state %+v", state)
+	}
+}
+
+// TestSyncProgressOverEstimate tests that completion is capped when the estimate was low
+func TestSyncProgressOverEstimate(t *testing.T) {
+	progress := NewSyncProgress("test", log.New(io.Discard, "", 0))
+	progress.Start(SyncEstimate{Documents: 1})
+	progress.Advance("1")
+	progress.Advance("2")
+	if state := progress.State(); state.Percent != 100 || state.ETA != 0 {
+		t.Errorf("unexpected state %+v", state)
+	}
+}
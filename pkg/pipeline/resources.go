@@ -0,0 +1,112 @@
+package pipeline
+
+import "sync/atomic"
+
+// ResourceRecorder is implemented by metrics recorders that report the
+// resources a pipeline uses, so infrastructure cost can be attributed to the
+// owners of its data
+type ResourceRecorder interface {
+	AddBytesRead(pipelineName string, bytes int64)
+	AddDocumentsScanned(pipelineName string, documents int64)
+	AddBytesWritten(pipelineName string, bytes int64)
+	AddStorageGrowth(pipelineName string, bytes int64)
+}
+
+// ResourceUsage is the resources a pipeline used since it started. Sizes are
+// those of the documents encoded as JSON, an approximation of what the source
+// sends and the sink stores.
+type ResourceUsage struct {
+	BytesRead        int64 `json:"bytes_read"`        // size of the changes read from the source
+	DocumentsScanned int64 `json:"documents_scanned"` // changes read from the source, including those later rejected
+	BytesWritten     int64 `json:"bytes_written"`     // size of the events the sink committed
+	StorageGrowth    int64 `json:"storage_growth"`    // estimated growth of the data held by the sink; negative if it shrank
+}
+
+// ResourceAccounting tracks the resources used by a pipeline and its initial
+// syncs and reports them as metrics. It is safe for concurrent use, and its
+// methods do nothing on a nil ResourceAccounting.
+type ResourceAccounting struct {
+	name    string
+	metrics ResourceRecorder
+
+	bytesRead        atomic.Int64
+	documentsScanned atomic.Int64
+	bytesWritten     atomic.Int64
+	storageGrowth    atomic.Int64
+}
+
+// NewResourceAccounting creates resource accounting for a pipeline
+func NewResourceAccounting(pipelineName string) *ResourceAccounting {
+	return &ResourceAccounting{name: pipelineName}
+}
+
+// SetMetrics reports usage to the recorder if it supports ResourceRecorder
+func (r *ResourceAccounting) SetMetrics(metrics MetricsRecorder) {
+	r.metrics, _ = metrics.(ResourceRecorder)
+}
+
+// Read accounts for a change read from the source
+func (r *ResourceAccounting) Read(event Event) {
+	if r == nil {
+		return
+	}
+	size := int64(EventSize(event))
+	r.bytesRead.Add(size)
+	r.documentsScanned.Add(1)
+	if r.metrics != nil {
+		r.metrics.AddBytesRead(r.name, size)
+		r.metrics.AddDocumentsScanned(r.name, 1)
+	}
+}
+
+// Written accounts for events committed by the sink. Inserts grow the stored
+// data by their size and deletes shrink it by the size of the deleted
+// document; updates change it by the difference with the previous version.
+// Deletes and updates without a previous version are not counted as growth.
+func (r *ResourceAccounting) Written(events []Event) {
+	if r == nil || len(events) == 0 {
+		return
+	}
+	var written, growth int64
+	for _, e := range events {
+		size := int64(EventSize(e))
+		written += size
+		switch e.Operation {
+		case "insert":
+			growth += size
+		case "update", "replace":
+			if e.Before != nil {
+				growth += size - int64(EventSize(Event{Data: e.Before}))
+			}
+		case "delete":
+			if e.Before != nil {
+				growth -= int64(EventSize(Event{Data: e.Before}))
+			}
+		}
+	}
+	r.bytesWritten.Add(written)
+	r.storageGrowth.Add(growth)
+	if r.metrics != nil {
+		r.metrics.AddBytesWritten(r.name, written)
+		r.metrics.AddStorageGrowth(r.name, growth)
+	}
+}
+
+// Usage returns the resources used so far
+func (r *ResourceAccounting) Usage() ResourceUsage {
+	if r == nil {
+		return ResourceUsage{}
+	}
+	return ResourceUsage{
+		BytesRead:        r.bytesRead.Load(),
+		DocumentsScanned: r.documentsScanned.Load(),
+		BytesWritten:     r.bytesWritten.Load(),
+		StorageGrowth:    r.storageGrowth.Load(),
+	}
+}
+
+// SetResourceAccounting accounts for the changes the pipeline reads and the
+// events its sink commits, or hands off for sinks that do not report commits
+func (p *Pipeline) SetResourceAccounting(resources *ResourceAccounting) {
+	p.resources = resources
+}
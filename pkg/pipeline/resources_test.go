@@ -0,0 +1,92 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+)
+
+// resourceMetrics is a MetricsRecorder that totals resource usage for inspection
+type resourceMetrics struct {
+	basicMetrics
+	usage ResourceUsage
+}
+
+func (r *resourceMetrics) AddBytesRead(pipelineName string, bytes int64) {
+	r.usage.BytesRead += bytes
+}
+
+func (r *resourceMetrics) AddDocumentsScanned(pipelineName string, documents int64) {
+	r.usage.DocumentsScanned += documents
+}
+
+func (r *resourceMetrics) AddBytesWritten(pipelineName string, bytes int64) {
+	r.usage.BytesWritten += bytes
+}
+
+func (r *resourceMetrics) AddStorageGrowth(pipelineName string, bytes int64) {
+	r.usage.StorageGrowth += bytes
+}
+
+// TestResourceAccountingWritten tests the estimated storage growth of each operation
+func TestResourceAccountingWritten(t *testing.T) {
+	small := map[string]interface{}{"a": 1}         // {"a":1}, 7 bytes
+	large := map[string]interface{}{"a": 1, "b": 2} // {"a":1,"b":2}, 13 bytes
+	tests := []struct {
+		name       string
+		event      Event
+		wantGrowth int64
+	}{
+		{"insert", Event{Operation: "insert", Data: large}, 13},
+		{"update", Event{Operation: "update", Data: large, Before: small}, 6},
+		{"update without before", Event{Operation: "update", Data: large}, 0},
+		{"replace shrinking", Event{Operation: "replace", Data: small, Before: large}, -6},
+		{"delete", Event{Operation: "delete", Before: large}, -13},
+		{"delete without before", Event{Operation: "delete"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewResourceAccounting("test")
+			r.Written([]Event{tt.event})
+			if got := r.Usage(); got.StorageGrowth != tt.wantGrowth || got.BytesWritten != int64(EventSize(tt.event)) {
+				t.Errorf("Usage() = %+v, want storage growth %d and %d bytes written", got, tt.wantGrowth, EventSize(tt.event))
+			}
+		})
+	}
+
+	var disabled *ResourceAccounting
+	disabled.Read(Event{})
+	disabled.Written([]Event{{Operation: "insert"}})
+	if got := disabled.Usage(); got != (ResourceUsage{}) {
+		t.Errorf("expected no usage without accounting, got %+v", got)
+	}
+}
+
+// TestPipelineResourceAccounting tests that read and committed events are accounted for and reported
+func TestPipelineResourceAccounting(t *testing.T) {
+	events := []Event{
+		{ID: "1", Operation: "insert", Data: map[string]interface{}{"name": "a"}},
+		{Operation: OperationHeartbeat},
+		{ID: "2", Operation: "insert", Data: map[string]interface{}{"name": "b"}},
+	}
+	metrics := &resourceMetrics{}
+	resources := NewResourceAccounting("test")
+	resources.SetMetrics(metrics)
+	p := New("test", NewMockSource(events), NewMockSink(), nil, nil)
+	p.SetResourceAccounting(resources)
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// {"name":"a"} is 12 bytes
+	want := ResourceUsage{BytesRead: 24, DocumentsScanned: 2, BytesWritten: 24, StorageGrowth: 24}
+	if got := resources.Usage(); got != want {
+		t.Errorf("Usage() = %+v, want %+v", got, want)
+	}
+	if metrics.usage != want {
+		t.Errorf("expected %+v reported, got %+v", want, metrics.usage)
+	}
+	if got := p.DebugState().Resources; got == nil || *got != want {
+		t.Errorf("DebugState().Resources = %v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,30 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrNotRunning is returned by Resubmit while the pipeline is not running
+var ErrNotRunning = errors.New("pipeline is not running")
+
+// Resubmit sends a dead-lettered event back through the running pipeline. It
+// skips the operation policy and the transformer, which the event passed
+// before it was dead-lettered, and goes through the size limit, memory budget
+// and sink like any other event. If the sink rejects it again it is
+// dead-lettered as a new entry. Resubmit returns once the event is accepted,
+// not once it is written.
+func (p *Pipeline) Resubmit(ctx context.Context, event Event) error {
+	if !p.Stats().Running {
+		return ErrNotRunning
+	}
+	// The position belongs to an earlier point of the stream; committing it
+	// would move the checkpoint backwards
+	event.Position = ""
+	select {
+	case p.resubmissions <- event:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
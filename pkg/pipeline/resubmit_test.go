@@ -0,0 +1,76 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// idleSource is a mock source that stays open without events until cancelled
+type idleSource struct {
+	MockSource
+}
+
+func (s *idleSource) Read(ctx context.Context) (<-chan Event, <-chan error) {
+	events := make(chan Event)
+	errors := make(chan error)
+	go func() {
+		<-ctx.Done()
+		close(events)
+		close(errors)
+	}()
+	return events, errors
+}
+
+// channelSink is a mock sink that forwards written events to a channel
+type channelSink struct {
+	MockSink
+	written chan Event
+}
+
+func (s *channelSink) Write(ctx context.Context, events <-chan Event) <-chan error {
+	errors := make(chan error)
+	go func() {
+		defer close(errors)
+		for event := range events {
+			s.written <- event
+		}
+	}()
+	return errors
+}
+
+// TestPipelineResubmit tests that a resubmitted event skips the transformer and loses its position
+func TestPipelineResubmit(t *testing.T) {
+	sink := &channelSink{written: make(chan Event, 1)}
+	p := New("test", &idleSource{}, sink, NewMockTransformer("PREFIX_"), nil)
+	if err := p.Resubmit(context.Background(), Event{ID: "1"}); !errors.Is(err, ErrNotRunning) {
+		t.Fatalf("expected ErrNotRunning before Run, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- p.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	for deadline := time.Now().Add(5 * time.Second); !p.Stats().Running; {
+		if time.Now().After(deadline) {
+			t.Fatal("pipeline did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := p.Resubmit(ctx, Event{ID: "1", Operation: "insert", Position: "old"}); err != nil {
+		t.Fatalf("Resubmit() error = %v", err)
+	}
+	select {
+	case event := <-sink.written:
+		if event.ID != "1" || event.Position != "" {
+			t.Errorf("expected the untransformed event without a position, got %+v", event)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("resubmitted event was not written")
+	}
+}
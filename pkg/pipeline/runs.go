@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+)
+
+// Run statuses
+const (
+	RunRunning   = "running"
+	RunCompleted = "completed"
+	RunStopped   = "stopped"
+	RunFailed    = "failed"
+)
+
+// SetRunStore sets the store recording a summary of every run
+func (p *Pipeline) SetRunStore(store RunStore) {
+	p.runs = store
+}
+
+// Name returns the pipeline name
+func (p *Pipeline) Name() string {
+	return p.name
+}
+
+// Checkpoint returns the pipeline's last saved checkpoint, or nil if there is none
+func (p *Pipeline) Checkpoint(ctx context.Context) (*Checkpoint, error) {
+	if p.checkpoints == nil {
+		return nil, nil
+	}
+	return p.checkpoints.Load(ctx, p.name)
+}
+
+// Snapshot returns the statistics of the pipeline's last initial sync, or nil if unknown
+func (p *Pipeline) Snapshot(ctx context.Context) (*SnapshotStats, error) {
+	if p.runs == nil {
+		return nil, nil
+	}
+	return p.runs.LoadSnapshot(ctx, p.name)
+}
+
+// Runs returns up to limit recent runs of the pipeline, newest first
+func (p *Pipeline) Runs(ctx context.Context, limit int) ([]RunSummary, error) {
+	if p.runs == nil {
+		return nil, nil
+	}
+	return p.runs.Runs(ctx, p.name, limit)
+}
+
+// startRun records the start of a run and returns the function recording its end.
+// A run left in the running state was interrupted without a clean shutdown.
+func (p *Pipeline) startRun(ctx context.Context) func(err error) {
+	p.processed.Store(0)
+	p.committed.Store(0)
+	p.commitsReported.Store(false)
+	p.rejected.Store(0)
+	p.resumedFrom = ""
+	if p.runs == nil {
+		return func(error) {}
+	}
+
+	started := p.clock.Now()
+	run := RunSummary{
+		ID:        fmt.Sprintf("%s-%d", p.name, started.UnixNano()),
+		Pipeline:  p.name,
+		Status:    RunRunning,
+		StartedAt: started,
+	}
+	p.saveRun(run)
+
+	return func(err error) {
+		ended := p.clock.Now()
+		run.EndedAt = &ended
+		run.StartPosition = p.resumedFrom
+		run.EventsProcessed = p.processed.Load()
+		run.EventsRejected = p.rejected.Load()
+		switch {
+		case err != nil:
+			run.Status = RunFailed
+			run.Error = err.Error()
+		case ctx.Err() != nil:
+			run.Status = RunStopped
+		default:
+			run.Status = RunCompleted
+		}
+		p.saveRun(run)
+	}
+}
+
+// saveRun stores a run summary; failures are logged but never stop the pipeline
+func (p *Pipeline) saveRun(run RunSummary) {
+	if err := p.runs.SaveRun(context.Background(), run); err != nil {
+		p.logger.Printf("Failed to save run summary: %v", err)
+	}
+}
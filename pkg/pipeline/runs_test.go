@@ -0,0 +1,102 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryRunStore is an in-memory RunStore
+type memoryRunStore struct {
+	mu       sync.Mutex
+	runs     []RunSummary
+	snapshot *SnapshotStats
+}
+
+func (m *memoryRunStore) SaveRun(ctx context.Context, run RunSummary) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range m.runs {
+		if m.runs[i].ID == run.ID {
+			m.runs[i] = run
+			return nil
+		}
+	}
+	m.runs = append(m.runs, run)
+	return nil
+}
+
+func (m *memoryRunStore) Runs(ctx context.Context, pipeline string, limit int) ([]RunSummary, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var runs []RunSummary
+	for i := len(m.runs) - 1; i >= 0 && (limit == 0 || len(runs) < limit); i-- {
+		runs = append(runs, m.runs[i])
+	}
+	return runs, nil
+}
+
+func (m *memoryRunStore) SaveSnapshot(ctx context.Context, stats SnapshotStats) error {
+	m.snapshot = &stats
+	return nil
+}
+
+func (m *memoryRunStore) LoadSnapshot(ctx context.Context, pipeline string) (*SnapshotStats, error) {
+	return m.snapshot, nil
+}
+
+// failingSource is a source that cannot connect
+type failingSource struct {
+	MockSource
+}
+
+func (f *failingSource) Connect(ctx context.Context) error {
+	return fmt.Errorf("connection refused")
+}
+
+// TestPipelineRecordsRuns tests that each run is summarized in the run store
+func TestPipelineRecordsRuns(t *testing.T) {
+	store := &memoryRunStore{}
+	checkpoints := newMemoryCheckpointStore()
+	checkpoints.Save(context.Background(), Checkpoint{Pipeline: "test", Position: "p0"})
+	clock := NewManualClock(time.Unix(1700000000, 0))
+	events := []Event{{ID: "1", Position: "p1"}, {ID: "2", Position: "p2"}, {ID: "bad", Position: "p3"}}
+
+	p := New("test", &resumableSource{MockSource: *NewMockSource(events)}, &commitSink{batchSize: 10}, rejectTransformer{id: "bad"}, nil)
+	p.SetClock(clock)
+	p.SetRunStore(store)
+	p.SetCheckpointStore(checkpoints)
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	p = New("test", &failingSource{}, NewMockSink(), nil, nil)
+	p.SetClock(clock)
+	p.SetRunStore(store)
+	if err := p.Run(context.Background()); err == nil {
+		t.Fatal("expected Run() to fail")
+	}
+
+	runs, _ := p.Runs(context.Background(), 0)
+	if len(runs) != 2 {
+		t.Fatalf("expected 2 runs, got %d", len(runs))
+	}
+	failed, completed := runs[0], runs[1]
+	if completed.Status != RunCompleted || completed.EventsProcessed != 2 || completed.EventsRejected != 1 {
+		t.Errorf("unexpected completed run %+v", completed)
+	}
+	if completed.StartPosition != "p0" || completed.EndedAt == nil {
+		t.Errorf("expected start position p0 and an end time, got %+v", completed)
+	}
+	if failed.Status != RunFailed || failed.Error == "" {
+		t.Errorf("unexpected failed run %+v", failed)
+	}
+
+	cp, err := New("test", NewMockSource(nil), NewMockSink(), nil, nil).Checkpoint(context.Background())
+	if err != nil || cp != nil {
+		t.Errorf("expected no checkpoint without a store, got %+v, %v", cp, err)
+	}
+}
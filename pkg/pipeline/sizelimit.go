@@ -0,0 +1,190 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// OversizePolicy determines how events larger than the size limit are handled
+type OversizePolicy string
+
+const (
+	// OversizeDLQ routes oversized events to the dead-letter queue
+	OversizeDLQ OversizePolicy = "dlq"
+	// OversizeTruncate truncates the configured fields until the event fits
+	OversizeTruncate OversizePolicy = "truncate"
+	// OversizeSplit splits the event's fields across several partial update events
+	OversizeSplit OversizePolicy = "split"
+)
+
+// defaultTruncateLength is the length string fields are cut to by the truncate policy
+const defaultTruncateLength = 1024
+
+// SizeLimit configures event-size guardrails
+type SizeLimit struct {
+	MaxBytes       int            // Maximum encoded size of Event.Data; 0 disables the limit
+	Policy         OversizePolicy // What to do with oversized events (default: dlq)
+	TruncateFields []string       // Fields the truncate policy may shorten, in order
+	TruncateLength int            // Length string fields are truncated to (default: 1024)
+	KeyFields      []string       // Fields copied into every split event (default: _id)
+}
+
+// ParseOversizePolicy parses an oversize policy name from configuration
+func ParseOversizePolicy(name string) (OversizePolicy, error) {
+	switch OversizePolicy(name) {
+	case "":
+		return OversizeDLQ, nil
+	case OversizeDLQ, OversizeTruncate, OversizeSplit:
+		return OversizePolicy(name), nil
+	default:
+		return "", fmt.Errorf("unsupported oversized event policy: %s", name)
+	}
+}
+
+// EventSize returns the approximate size in bytes of an event's data when encoded as JSON
+func EventSize(event Event) int {
+	b, err := json.Marshal(event.Data)
+	if err != nil {
+		return 0
+	}
+	return len(b)
+}
+
+// Apply enforces the size limit on an event. It returns the events to forward
+// downstream; an error means the event could not be made to fit and should be
+// rejected.
+func (l SizeLimit) Apply(event Event) ([]Event, error) {
+	if l.MaxBytes <= 0 {
+		return []Event{event}, nil
+	}
+
+	size := EventSize(event)
+	if size <= l.MaxBytes {
+		return []Event{event}, nil
+	}
+
+	switch l.Policy {
+	case OversizeTruncate:
+		return l.truncate(event, size)
+	case OversizeSplit:
+		return l.split(event, size)
+	default:
+		return nil, fmt.Errorf("event size %d bytes exceeds limit of %d bytes", size, l.MaxBytes)
+	}
+}
+
+// truncate shortens the configured fields until the event fits
+func (l SizeLimit) truncate(event Event, size int) ([]Event, error) {
+	maxLen := l.TruncateLength
+	if maxLen <= 0 {
+		maxLen = defaultTruncateLength
+	}
+
+	data := make(map[string]interface{}, len(event.Data))
+	for k, v := range event.Data {
+		data[k] = v
+	}
+	event.Data = data
+
+	for _, field := range l.TruncateFields {
+		value, ok := data[field]
+		if !ok {
+			continue
+		}
+		if s, isString := value.(string); isString {
+			if len(s) > maxLen {
+				data[field] = truncateString(s, maxLen)
+			}
+		} else {
+			// Non-string values cannot be shortened meaningfully, so they are dropped
+			data[field] = nil
+		}
+
+		if size = EventSize(event); size <= l.MaxBytes {
+			return []Event{event}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("event size %d bytes still exceeds limit of %d bytes after truncation", size, l.MaxBytes)
+}
+
+// truncateString cuts s to at most maxLen bytes without splitting a
+// multi-byte character, which would leave invalid UTF-8 for the sink to reject
+func truncateString(s string, maxLen int) string {
+	cut := maxLen
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut]
+}
+
+// split distributes the event's fields across several events that each fit the
+// limit. Every part carries the key fields; parts after the first are updates
+// so upserting sinks merge them into the same row.
+func (l SizeLimit) split(event Event, size int) ([]Event, error) {
+	if event.Operation == "delete" {
+		return nil, fmt.Errorf("event size %d bytes exceeds limit of %d bytes and deletes cannot be split", size, l.MaxBytes)
+	}
+
+	keyFields := l.KeyFields
+	if len(keyFields) == 0 {
+		keyFields = defaultKeyFields
+	}
+	key := make(map[string]interface{}, len(keyFields))
+	for _, field := range keyFields {
+		value, ok := event.Data[field]
+		if !ok {
+			return nil, fmt.Errorf("event size %d bytes exceeds limit of %d bytes and has no %s to split on", size, l.MaxBytes, field)
+		}
+		key[field] = value
+	}
+	newPart := func() Event {
+		data := make(map[string]interface{}, len(key)+1)
+		for k, v := range key {
+			data[k] = v
+		}
+		return Event{Data: data}
+	}
+
+	fields := make([]string, 0, len(event.Data))
+	for k := range event.Data {
+		if _, isKey := key[k]; !isKey {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+
+	var parts []Event
+	current := newPart()
+	for _, field := range fields {
+		current.Data[field] = event.Data[field]
+		if EventSize(current) <= l.MaxBytes {
+			continue
+		}
+
+		if len(current.Data) == len(key)+1 {
+			return nil, fmt.Errorf("field %s alone exceeds the event size limit of %d bytes", field, l.MaxBytes)
+		}
+		delete(current.Data, field)
+		parts = append(parts, current)
+		current = newPart()
+		current.Data[field] = event.Data[field]
+		if EventSize(current) > l.MaxBytes {
+			return nil, fmt.Errorf("field %s alone exceeds the event size limit of %d bytes", field, l.MaxBytes)
+		}
+	}
+	parts = append(parts, current)
+
+	result := make([]Event, len(parts))
+	for i, part := range parts {
+		e := event
+		e.Data = part.Data
+		if i > 0 {
+			e.Operation = "update"
+		}
+		result[i] = e
+	}
+	return result, nil
+}
@@ -0,0 +1,188 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// TestSizeLimitDisabled tests that a zero limit passes events through
+func TestSizeLimitDisabled(t *testing.T) {
+	event := Event{ID: "1", Data: map[string]interface{}{"body": strings.Repeat("x", 10000)}}
+
+	out, err := SizeLimit{}.Apply(event)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(out) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(out))
+	}
+}
+
+// TestSizeLimitDLQ tests that the default policy rejects oversized events
+func TestSizeLimitDLQ(t *testing.T) {
+	limit := SizeLimit{MaxBytes: 100}
+
+	small := Event{ID: "1", Data: map[string]interface{}{"name": "ok"}}
+	if out, err := limit.Apply(small); err != nil || len(out) != 1 {
+		t.Fatalf("expected small event to pass, got %v, %v", out, err)
+	}
+
+	big := Event{ID: "2", Data: map[string]interface{}{"body": strings.Repeat("x", 200)}}
+	if _, err := limit.Apply(big); err == nil {
+		t.Error("expected oversized event to be rejected")
+	}
+}
+
+// TestSizeLimitTruncate tests truncating configured fields
+func TestSizeLimitTruncate(t *testing.T) {
+	limit := SizeLimit{
+		MaxBytes:       200,
+		Policy:         OversizeTruncate,
+		TruncateFields: []string{"missing", "body"},
+		TruncateLength: 50,
+	}
+
+	event := Event{ID: "1", Data: map[string]interface{}{
+		"_id":  "abc",
+		"body": strings.Repeat("x", 500),
+	}}
+
+	out, err := limit.Apply(event)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if got := out[0].Data["body"].(string); len(got) != 50 {
+		t.Errorf("expected body truncated to 50 bytes, got %d", len(got))
+	}
+	if len(event.Data["body"].(string)) != 500 {
+		t.Error("original event data should not be modified")
+	}
+
+	// A field that is not configured for truncation keeps the event oversized
+	limit.TruncateFields = []string{"other"}
+	if _, err := limit.Apply(event); err == nil {
+		t.Error("expected error when truncation cannot shrink the event")
+	}
+}
+
+// TestSizeLimitTruncateMultiByte tests that truncation never splits a multi-byte character
+func TestSizeLimitTruncateMultiByte(t *testing.T) {
+	limit := SizeLimit{
+		MaxBytes:       100,
+		Policy:         OversizeTruncate,
+		TruncateFields: []string{"body"},
+		TruncateLength: 10,
+	}
+	// Each "é" is two bytes, so a 10-byte cut after "a" would land mid-character
+	event := Event{ID: "1", Data: map[string]interface{}{"body": "a" + strings.Repeat("é", 100)}}
+
+	out, err := limit.Apply(event)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	body := out[0].Data["body"].(string)
+	if !utf8.ValidString(body) {
+		t.Errorf("truncated body is not valid UTF-8: %q", body)
+	}
+	if body != "a"+strings.Repeat("é", 4) {
+		t.Errorf("expected body cut to 9 bytes, got %q", body)
+	}
+}
+
+// TestSizeLimitSplit tests splitting an event into partial updates
+func TestSizeLimitSplit(t *testing.T) {
+	limit := SizeLimit{MaxBytes: 120, Policy: OversizeSplit}
+
+	event := Event{ID: "1", Operation: "insert", Data: map[string]interface{}{
+		"_id": "abc",
+		"a":   strings.Repeat("a", 60),
+		"b":   strings.Repeat("b", 60),
+		"c":   strings.Repeat("c", 60),
+	}}
+
+	out, err := limit.Apply(event)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(out) < 2 {
+		t.Fatalf("expected event to be split, got %d parts", len(out))
+	}
+
+	seen := map[string]bool{}
+	for i, part := range out {
+		if part.Data["_id"] != "abc" {
+			t.Errorf("part %d missing key field", i)
+		}
+		if EventSize(part) > limit.MaxBytes {
+			t.Errorf("part %d exceeds limit: %d bytes", i, EventSize(part))
+		}
+		wantOp := "update"
+		if i == 0 {
+			wantOp = "insert"
+		}
+		if part.Operation != wantOp {
+			t.Errorf("part %d operation = %s, want %s", i, part.Operation, wantOp)
+		}
+		for k := range part.Data {
+			seen[k] = true
+		}
+	}
+	for _, field := range []string{"a", "b", "c"} {
+		if !seen[field] {
+			t.Errorf("field %s lost during split", field)
+		}
+	}
+}
+
+// TestSizeLimitSplitCompositeKey tests that every part carries all key fields
+func TestSizeLimitSplitCompositeKey(t *testing.T) {
+	limit := SizeLimit{MaxBytes: 120, Policy: OversizeSplit, KeyFields: []string{"tenant", "order"}}
+	event := Event{Operation: "update", Data: map[string]interface{}{
+		"tenant": "t1",
+		"order":  "o1",
+		"a":      strings.Repeat("a", 60),
+		"b":      strings.Repeat("b", 60),
+	}}
+
+	out, err := limit.Apply(event)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if len(out) < 2 {
+		t.Fatalf("expected event to be split, got %d parts", len(out))
+	}
+	for i, part := range out {
+		if part.Data["tenant"] != "t1" || part.Data["order"] != "o1" {
+			t.Errorf("part %d missing key fields: %v", i, part.Data)
+		}
+	}
+
+	delete(event.Data, "order")
+	if _, err := limit.Apply(event); err == nil {
+		t.Error("expected error for an event missing a key field")
+	}
+}
+
+// TestSizeLimitSplitFieldTooLarge tests that a single oversized field cannot be split
+func TestSizeLimitSplitFieldTooLarge(t *testing.T) {
+	limit := SizeLimit{MaxBytes: 50, Policy: OversizeSplit}
+	event := Event{Operation: "update", Data: map[string]interface{}{
+		"_id":  "abc",
+		"body": strings.Repeat("x", 100),
+	}}
+
+	if _, err := limit.Apply(event); err == nil {
+		t.Error("expected error for a single field larger than the limit")
+	}
+}
+
+// TestParseOversizePolicy tests parsing policy names
+func TestParseOversizePolicy(t *testing.T) {
+	if p, err := ParseOversizePolicy(""); err != nil || p != OversizeDLQ {
+		t.Errorf("expected default dlq policy, got %q, %v", p, err)
+	}
+	if _, err := ParseOversizePolicy("explode"); err == nil {
+		t.Error("expected error for unknown policy")
+	}
+}
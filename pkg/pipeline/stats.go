@@ -0,0 +1,42 @@
+package pipeline
+
+import "time"
+
+// Stats is a point-in-time view of pipeline progress, used for alerting
+type Stats struct {
+	Running bool          // source and sink are connected
+	Healthy bool          // see IsHealthy
+	Errors  int64         // runtime errors since the pipeline was created
+	Lag     time.Duration // time since the source change of the last processed event
+}
+
+// Stats returns the pipeline's current progress counters
+func (p *Pipeline) Stats() Stats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	// Lag keeps growing while no events arrive, so a stalled stream is noticed
+	var lag time.Duration
+	if !p.lastSourceTime.IsZero() {
+		lag = p.clock.Since(p.lastSourceTime)
+	}
+	return Stats{
+		Running: p.sourceConnected && p.sinkConnected,
+		Healthy: p.isHealthyLocked(),
+		Errors:  p.errorCount.Load(),
+		Lag:     lag,
+	}
+}
+
+// recordError counts a runtime error, keeps it for DebugState and reports it
+// to the metrics recorder
+func (p *Pipeline) recordError(component, errorType string, err error) {
+	p.errorCount.Add(1)
+	p.debug.addError(ErrorRecord{Time: p.clock.Now(), Component: component, Type: errorType, Message: err.Error()})
+	if p.metrics != nil {
+		p.metrics.RecordEventError(p.name, component, errorType)
+	}
+	if p.hooks.OnError != nil {
+		p.hooks.OnError(component, err)
+	}
+}
@@ -0,0 +1,37 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// TestPipelineStats tests that runtime errors and source lag are tracked
+func TestPipelineStats(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	clock := NewManualClock(start.Add(90 * time.Second))
+	events := []Event{{ID: "1", Timestamp: start}, {ID: "bad", Timestamp: start}}
+
+	p := New("test", NewMockSource(events), NewMockSink(), rejectTransformer{id: "bad"}, nil)
+	p.SetClock(clock)
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	stats := p.Stats()
+	if stats.Errors != 1 {
+		t.Errorf("expected 1 error, got %d", stats.Errors)
+	}
+	if stats.Lag != 90*time.Second {
+		t.Errorf("expected lag 90s, got %v", stats.Lag)
+	}
+	if stats.Running {
+		t.Error("expected pipeline not to be running after Run returns")
+	}
+
+	// Without new events the lag keeps growing
+	clock.Advance(time.Minute)
+	if lag := p.Stats().Lag; lag != 150*time.Second {
+		t.Errorf("expected lag 150s after a minute without events, got %v", lag)
+	}
+}
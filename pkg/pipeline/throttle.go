@@ -0,0 +1,159 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// Throttle configures adaptive flow control: while the sink lags behind, the
+// pipeline slows down its reads from the source, smoothing load spikes out
+// instead of piling events up in front of the sink
+type Throttle struct {
+	// MaxSinkLatency is the time from an event's ingestion to its commit by
+	// the sink above which the sink counts as lagging (0 ignores latency).
+	// It requires a sink that reports commits.
+	MaxSinkLatency time.Duration
+	// MaxQueueDepth is the number of events waiting between the stages or in
+	// flight in the sink above which the sink counts as lagging (0 ignores
+	// queue depth)
+	MaxQueueDepth int
+	// MaxDelay is the longest pause before each read from the source
+	// (default: 100ms)
+	MaxDelay time.Duration
+	// MaxBatchWindowFactor is the most a source's batch window is stretched
+	// at MaxDelay, see BatchWindowScaler (default: 4)
+	MaxBatchWindowFactor float64
+}
+
+// BatchWindowScaler is implemented by sources that group events over a time
+// window before handing them on. While reads are throttled the pipeline
+// stretches the window by factor, and restores it with a factor of 1, so the
+// sink receives fewer, larger batches.
+type BatchWindowScaler interface {
+	ScaleBatchWindow(factor float64)
+}
+
+const (
+	// throttleInterval is how often the read delay is adjusted
+	throttleInterval = time.Second
+	// minThrottleDelay is the read delay once the sink starts lagging
+	minThrottleDelay = time.Millisecond
+
+	defaultThrottleMaxDelay     = 100 * time.Millisecond
+	defaultMaxBatchWindowFactor = 4
+)
+
+// SetThrottle enables adaptive flow control. Every second the pipeline checks
+// the sink's commit latency and the queue depth against the thresholds: while
+// either is exceeded, the pause before each read from the source doubles up
+// to MaxDelay, and once both are back below them it halves until reads are no
+// longer paused.
+func (p *Pipeline) SetThrottle(throttle Throttle) {
+	if throttle.MaxDelay <= 0 {
+		throttle.MaxDelay = defaultThrottleMaxDelay
+	}
+	if throttle.MaxBatchWindowFactor < 1 {
+		throttle.MaxBatchWindowFactor = defaultMaxBatchWindowFactor
+	}
+	p.throttle = throttle
+}
+
+// throttled reports whether flow control is enabled
+func (p *Pipeline) throttled() bool {
+	return p.throttle.MaxSinkLatency > 0 || p.throttle.MaxQueueDepth > 0
+}
+
+// ReadDelay returns the current pause before each read from the source
+func (p *Pipeline) ReadDelay() time.Duration {
+	return time.Duration(p.readDelay.Load())
+}
+
+// runThrottle adjusts the read delay until ctx is done, then stops throttling
+func (p *Pipeline) runThrottle(ctx context.Context) {
+	defer p.setReadDelay(0)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.clock.After(throttleInterval):
+		}
+		p.adjustThrottle()
+	}
+}
+
+// adjustThrottle doubles the read delay while the sink lags and halves it
+// otherwise
+func (p *Pipeline) adjustThrottle() {
+	latency := time.Duration(p.peakSinkLatency.Swap(0))
+	depth := 0
+	for _, n := range p.queueDepths() {
+		depth += n
+	}
+	lagging := (p.throttle.MaxSinkLatency > 0 && latency > p.throttle.MaxSinkLatency) ||
+		(p.throttle.MaxQueueDepth > 0 && depth > p.throttle.MaxQueueDepth)
+
+	delay := p.ReadDelay()
+	switch {
+	case lagging && delay == 0:
+		p.logger.Printf("Sink is lagging (commit latency %v, queue depth %d), throttling source reads", latency, depth)
+		delay = minThrottleDelay
+	case lagging:
+		delay = min(2*delay, p.throttle.MaxDelay)
+	case delay > 0:
+		delay /= 2
+		if delay < minThrottleDelay {
+			p.logger.Println("Sink caught up, no longer throttling source reads")
+			delay = 0
+		}
+	}
+	p.setReadDelay(delay)
+}
+
+// setReadDelay sets the read delay and stretches the source's batch window
+// in proportion to it
+func (p *Pipeline) setReadDelay(delay time.Duration) {
+	if time.Duration(p.readDelay.Swap(int64(delay))) == delay {
+		return
+	}
+	if scaler, ok := p.source.(BatchWindowScaler); ok {
+		factor := 1 + (p.throttle.MaxBatchWindowFactor-1)*float64(delay)/float64(p.throttle.MaxDelay)
+		scaler.ScaleBatchWindow(factor)
+	}
+}
+
+// pauseRead waits out the read delay before the next read from the source.
+// It returns false if ctx was cancelled first.
+func (p *Pipeline) pauseRead(ctx context.Context) bool {
+	delay := p.ReadDelay()
+	if delay <= 0 {
+		return true
+	}
+	p.setStage(StageThrottled)
+	select {
+	case <-ctx.Done():
+		return false
+	case <-p.clock.After(delay):
+		return true
+	}
+}
+
+// observeSinkLatency records the longest time committed events took from
+// their ingestion to their commit
+func (p *Pipeline) observeSinkLatency(events []Event) {
+	if p.throttle.MaxSinkLatency <= 0 {
+		return
+	}
+	now := p.clock.Now()
+	for _, e := range events {
+		if e.IngestedAt.IsZero() {
+			continue
+		}
+		latency := int64(now.Sub(e.IngestedAt))
+		for {
+			peak := p.peakSinkLatency.Load()
+			if latency <= peak || p.peakSinkLatency.CompareAndSwap(peak, latency) {
+				break
+			}
+		}
+	}
+}
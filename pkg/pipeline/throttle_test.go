@@ -0,0 +1,105 @@
+package pipeline
+
+import (
+	"context"
+	"io"
+	"log"
+	"testing"
+	"time"
+)
+
+// scalingSource is a mock source recording the batch window factor it is given
+type scalingSource struct {
+	MockSource
+	factors []float64
+}
+
+func (s *scalingSource) ScaleBatchWindow(factor float64) {
+	s.factors = append(s.factors, factor)
+}
+
+// TestThrottleOnSinkLatency tests that reads slow down while commits lag and recover afterwards
+func TestThrottleOnSinkLatency(t *testing.T) {
+	clock := NewManualClock(time.Unix(1700000000, 0))
+	source := &scalingSource{}
+	p := New("test", source, NewMockSink(), nil, log.New(io.Discard, "", 0))
+	p.SetClock(clock)
+	p.SetThrottle(Throttle{MaxSinkLatency: time.Second, MaxDelay: 4 * time.Millisecond})
+
+	lagging := []Event{{ID: "1", IngestedAt: clock.Now().Add(-3 * time.Second)}}
+	wantDelays := []time.Duration{time.Millisecond, 2 * time.Millisecond, 4 * time.Millisecond, 4 * time.Millisecond}
+	for i, want := range wantDelays {
+		p.observeSinkLatency(lagging)
+		p.adjustThrottle()
+		if got := p.ReadDelay(); got != want {
+			t.Fatalf("adjustment %d: read delay = %v, want %v", i, got, want)
+		}
+	}
+
+	// Commits within the threshold let the delay decay to nothing
+	p.observeSinkLatency([]Event{{ID: "2", IngestedAt: clock.Now().Add(-100 * time.Millisecond)}})
+	for _, want := range []time.Duration{2 * time.Millisecond, time.Millisecond, 0} {
+		p.adjustThrottle()
+		if got := p.ReadDelay(); got != want {
+			t.Fatalf("read delay = %v, want %v", got, want)
+		}
+	}
+
+	wantFactors := []float64{1.75, 2.5, 4, 2.5, 1.75, 1}
+	if len(source.factors) != len(wantFactors) {
+		t.Fatalf("batch window factors = %v, want %v", source.factors, wantFactors)
+	}
+	for i, want := range wantFactors {
+		if source.factors[i] != want {
+			t.Errorf("batch window factors = %v, want %v", source.factors, wantFactors)
+			break
+		}
+	}
+}
+
+// TestThrottleOnQueueDepth tests that events in flight in the sink throttle reads
+func TestThrottleOnQueueDepth(t *testing.T) {
+	p := New("test", NewMockSource(nil), NewMockSink(), nil, log.New(io.Discard, "", 0))
+	p.SetThrottle(Throttle{MaxQueueDepth: 10})
+	p.commitsReported.Store(true)
+
+	p.processed.Store(50)
+	p.committed.Store(45)
+	p.adjustThrottle()
+	if got := p.ReadDelay(); got != 0 {
+		t.Fatalf("read delay = %v with a short queue, want 0", got)
+	}
+
+	p.processed.Store(100)
+	p.adjustThrottle()
+	if got := p.ReadDelay(); got != minThrottleDelay {
+		t.Fatalf("read delay = %v with a long queue, want %v", got, minThrottleDelay)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if p.pauseRead(ctx) {
+		t.Error("expected pauseRead() to give up on a cancelled context")
+	}
+}
+
+// TestPipelineThrottled tests that a throttled pipeline still delivers every event
+func TestPipelineThrottled(t *testing.T) {
+	events := make([]Event, 20)
+	for i := range events {
+		events[i] = Event{ID: string(rune('a' + i)), Operation: "insert"}
+	}
+	sink := NewMockSink()
+	p := New("test", NewMockSource(events), sink, nil, log.New(io.Discard, "", 0))
+	p.SetThrottle(Throttle{MaxQueueDepth: 1})
+	p.readDelay.Store(int64(time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := p.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(sink.received) != len(events) {
+		t.Errorf("expected %d events written, got %d", len(events), len(sink.received))
+	}
+}
@@ -0,0 +1,157 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Trace stages
+const (
+	TraceSource      = "source"      // as read from the source, before any change
+	TraceTransformed = "transformed" // after the transformer
+	TraceRejected    = "rejected"    // dropped, dead-lettered or failed by a stage
+	TraceHandoff     = "sink"        // handed to the sink, after the size limit
+	TraceCommitted   = "committed"   // reported written by the sink
+)
+
+// TraceConfig selects the events a Tracer follows. An event is followed if
+// its ID matches IDPattern or the value of Field in its source document
+// matches FieldPattern.
+type TraceConfig struct {
+	IDPattern    string // Regular expression matched against the event ID
+	Field        string // Source document field, dot-separated for nested fields
+	FieldPattern string // Regular expression matched against the field's value
+}
+
+// Tracer logs every stage of selected events, so the path of a single
+// document through the pipeline can be followed in production. Events are
+// selected when read from the source and followed by ID from then on, since
+// the transformer may rename or drop the matched field.
+type Tracer struct {
+	id       *regexp.Regexp
+	field    []string
+	value    *regexp.Regexp
+	logger   *log.Logger
+	followed sync.Map // IDs of events being followed
+}
+
+// NewTracer creates a tracer for the events selected by config
+func NewTracer(config TraceConfig, logger *log.Logger) (*Tracer, error) {
+	if logger == nil {
+		logger = log.Default()
+	}
+	t := &Tracer{logger: logger}
+	if config.IDPattern != "" {
+		re, err := regexp.Compile(config.IDPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trace ID pattern: %w", err)
+		}
+		t.id = re
+	}
+	if (config.Field == "") != (config.FieldPattern == "") {
+		return nil, fmt.Errorf("trace field and field pattern must be set together")
+	}
+	if config.Field != "" {
+		re, err := regexp.Compile(config.FieldPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trace field pattern: %w", err)
+		}
+		t.field = strings.Split(config.Field, ".")
+		t.value = re
+	}
+	if t.id == nil && t.value == nil {
+		return nil, fmt.Errorf("trace requires an ID pattern or a field and field pattern")
+	}
+	return t, nil
+}
+
+// Follow reports whether a source event is selected, and if so logs it and
+// follows its ID through the later stages
+func (t *Tracer) Follow(event Event) bool {
+	if t == nil || !t.matches(event) {
+		return false
+	}
+	t.followed.Store(event.ID, true)
+	t.Record(TraceSource, event, "")
+	return true
+}
+
+// Record logs a stage of a followed event, with an optional note such as the
+// reason it was rejected. Events that are not followed are ignored.
+func (t *Tracer) Record(stage string, event Event, note string) {
+	if t == nil {
+		return
+	}
+	if _, ok := t.followed.Load(event.ID); ok {
+		t.log(stage, event, note)
+	}
+}
+
+// Done logs the last stage of a followed event, once it is committed or
+// rejected, and stops following it
+func (t *Tracer) Done(stage string, event Event, note string) {
+	if t == nil {
+		return
+	}
+	if _, ok := t.followed.LoadAndDelete(event.ID); ok {
+		t.log(stage, event, note)
+	}
+}
+
+// Committed ends the trace of the followed events in a sink batch, noting
+// the error if the batch failed
+func (t *Tracer) Committed(events []Event, err error) {
+	if t == nil {
+		return
+	}
+	note := ""
+	if err != nil {
+		note = fmt.Sprintf("batch failed: %v", err)
+	}
+	for _, event := range events {
+		t.Done(TraceCommitted, event, note)
+	}
+}
+
+// log writes a trace line with the full event
+func (t *Tracer) log(stage string, event Event, note string) {
+	encoded, err := json.Marshal(event)
+	if err != nil {
+		encoded = []byte(fmt.Sprintf("<unencodable: %v>", err))
+	}
+	if note != "" {
+		t.logger.Printf("Trace %s [%s] %s: %s", event.ID, stage, note, encoded)
+	} else {
+		t.logger.Printf("Trace %s [%s]: %s", event.ID, stage, encoded)
+	}
+}
+
+// matches reports whether a source event is selected
+func (t *Tracer) matches(event Event) bool {
+	if t.id != nil && t.id.MatchString(event.ID) {
+		return true
+	}
+	if t.value == nil {
+		return false
+	}
+	var current interface{} = event.Data
+	for _, part := range t.field {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if current, ok = m[part]; !ok {
+			return false
+		}
+	}
+	return t.value.MatchString(fmt.Sprintf("%v", current))
+}
+
+// SetTracer logs every stage of the events selected by the tracer
+func (p *Pipeline) SetTracer(tracer *Tracer) {
+	p.tracer = tracer
+}
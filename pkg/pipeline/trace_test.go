@@ -0,0 +1,62 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"strings"
+	"testing"
+)
+
+// TestNewTracerValidation tests that incomplete or invalid selections are rejected
+func TestNewTracerValidation(t *testing.T) {
+	for _, config := range []TraceConfig{
+		{},
+		{IDPattern: "("},
+		{Field: "customer.email"},
+		{FieldPattern: "x"},
+		{Field: "status", FieldPattern: "["},
+	} {
+		if _, err := NewTracer(config, nil); err == nil {
+			t.Errorf("NewTracer(%+v) expected an error", config)
+		}
+	}
+}
+
+// TestPipelineTrace tests that followed events are logged at every stage
+func TestPipelineTrace(t *testing.T) {
+	var out bytes.Buffer
+	tracer, err := NewTracer(TraceConfig{IDPattern: "^2$", Field: "customer.email", FieldPattern: "@example\\.com$"}, log.New(&out, "", 0))
+	if err != nil {
+		t.Fatalf("NewTracer() error = %v", err)
+	}
+	events := []Event{
+		{ID: "1", Operation: "insert", Data: map[string]interface{}{"customer": map[string]interface{}{"email": "a@other.org"}}},
+		{ID: "2", Operation: "insert"},
+		{ID: "3", Operation: "update", Data: map[string]interface{}{"customer": map[string]interface{}{"email": "b@example.com"}}},
+		{ID: "bad", Operation: "insert", Data: map[string]interface{}{"customer": map[string]interface{}{"email": "c@example.com"}}},
+	}
+	p := New("test", NewMockSource(events), &commitSink{batchSize: 10}, rejectTransformer{id: "bad"}, nil)
+	p.SetTracer(tracer)
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var stages []string
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		fields := strings.Fields(line)
+		stages = append(stages, fields[1]+" "+fields[2])
+	}
+	want := []string{
+		"2 [source]:", "2 [transformed]:", "2 [sink]:",
+		"3 [source]:", "3 [transformed]:", "3 [sink]:",
+		"bad [source]:", "bad [rejected]",
+		"2 [committed]:", "3 [committed]:",
+	}
+	if strings.Join(stages, ",") != strings.Join(want, ",") {
+		t.Errorf("traced stages = %v, want %v", stages, want)
+	}
+	if _, followed := tracer.followed.Load("2"); followed {
+		t.Error("expected committed events to no longer be followed")
+	}
+}
@@ -7,21 +7,62 @@ import (
 
 // Event represents a change data capture event
 type Event struct {
-	ID         string                 `json:"id"`
-	Timestamp  time.Time              `json:"timestamp"`
-	Operation  string                 `json:"operation"` // insert, update, delete
-	Source     string                 `json:"source"`
-	Database   string                 `json:"database"`
-	Collection string                 `json:"collection"`
-	Data       map[string]interface{} `json:"data"`
-	Before     map[string]interface{} `json:"before,omitempty"` // for updates
+	ID          string                 `json:"id"`
+	Timestamp   time.Time              `json:"timestamp"`   // when the change happened at the source; zero if the source does not know
+	IngestedAt  time.Time              `json:"ingested_at"` // when the pipeline read the event from the source
+	Operation   string                 `json:"operation"`   // insert, update, delete
+	Source      string                 `json:"source"`
+	Database    string                 `json:"database"`
+	Collection  string                 `json:"collection"`
+	Data        map[string]interface{} `json:"data"`
+	Before      map[string]interface{} `json:"before,omitempty"`      // for updates
+	Key         map[string]interface{} `json:"key,omitempty"`         // source document key (e.g. MongoDB documentKey), set even when Data is empty
+	Destination string                 `json:"destination,omitempty"` // routing hint (e.g. table name) for routing-aware sinks; empty uses the sink's default
+	Position    string                 `json:"position,omitempty"`    // opaque source position (e.g. resume token) for checkpointing
+	Metadata    map[string]string      `json:"metadata,omitempty"`    // extension values about the event that are not document fields
 }
 
+// Well-known Event.Metadata keys. Sources, transformers and sinks may add
+// their own, such as "tenant" or "source_shard".
+const (
+	MetadataTraceID       = "trace_id"       // trace ID of the change, used as the latency exemplar
+	MetadataSchemaVersion = "schema_version" // schema version the document was written under
+	MetadataRejectionRule = "rejection_rule" // check a dead-lettered event failed, such as schema_violation
+)
+
+// SetMetadata sets an Event.Metadata value, creating the map if needed
+func (e *Event) SetMetadata(key, value string) {
+	if e.Metadata == nil {
+		e.Metadata = make(map[string]string)
+	}
+	e.Metadata[key] = value
+}
+
+// WithRejectionRule returns a copy of an event, about to be dead-lettered,
+// naming the check it failed in its metadata. The metadata map is copied, as
+// it may be shared with other copies of the event.
+func WithRejectionRule(event Event, rule string) Event {
+	metadata := make(map[string]string, len(event.Metadata)+1)
+	for key, value := range event.Metadata {
+		metadata[key] = value
+	}
+	metadata[MetadataRejectionRule] = rule
+	event.Metadata = metadata
+	return event
+}
+
+// OperationHeartbeat is the operation of the events a source emits while idle
+// to report its current position and source time. Heartbeats keep the lag
+// and the watchdog current and, once every earlier event is committed, move
+// the checkpoint forward. They are never transformed or written.
+const OperationHeartbeat = "heartbeat"
+
 // Source defines the interface for data sources
 type Source interface {
 	// Connect establishes connection to the source
 	Connect(ctx context.Context) error
-	// Read returns a channel that emits change events
+	// Read returns a channel that emits change events. Once ctx is cancelled
+	// it must not block on a send, and closes both channels when it stops.
 	Read(ctx context.Context) (<-chan Event, <-chan error)
 	// Close closes the source connection
 	Close() error
@@ -31,7 +72,8 @@ type Source interface {
 type Sink interface {
 	// Connect establishes connection to the sink
 	Connect(ctx context.Context) error
-	// Write writes events to the sink
+	// Write writes events to the sink. It keeps receiving until events is
+	// closed, even after ctx is cancelled, then closes the errors channel.
 	Write(ctx context.Context, events <-chan Event) <-chan error
 	// Close closes the sink connection
 	Close() error
@@ -39,6 +81,233 @@ type Sink interface {
 
 // Transformer defines the interface for data transformation
 type Transformer interface {
-	// Transform transforms an event
+	// Transform transforms an event. ctx is cancelled when the pipeline
+	// stops, so transformers calling out to other services should pass it on.
+	Transform(ctx context.Context, event Event) (Event, error)
+}
+
+// LegacyTransformer is a transformer written before Transformer took a
+// context. AdaptTransformer turns it into a Transformer.
+type LegacyTransformer interface {
 	Transform(event Event) (Event, error)
 }
+
+// AdaptTransformer returns a Transformer calling a transformer that takes no
+// context
+func AdaptTransformer(t LegacyTransformer) Transformer {
+	return legacyTransformer{t}
+}
+
+// legacyTransformer adapts a LegacyTransformer, ignoring the context
+type legacyTransformer struct {
+	LegacyTransformer
+}
+
+func (l legacyTransformer) Transform(ctx context.Context, event Event) (Event, error) {
+	return l.LegacyTransformer.Transform(event)
+}
+
+// EventValidator checks events read from the source against a contract
+// before they are transformed
+type EventValidator interface {
+	// Validate returns the event to process, or an error if it is rejected
+	Validate(event Event) (Event, error)
+}
+
+// DeadLetterQueue receives events that could not be processed
+type DeadLetterQueue interface {
+	// Send stores a rejected event together with the reason it was rejected
+	Send(ctx context.Context, event Event, reason string) error
+}
+
+// EventBuffer is a durable overflow buffer between the transformer and the sink
+type EventBuffer interface {
+	// Append adds an event to the tail of the buffer; an error means it cannot accept more
+	Append(event Event) error
+	// Peek returns the event at the head of the buffer without removing it
+	Peek() (Event, bool, error)
+	// Advance removes the event returned by the last Peek
+	Advance() error
+	// Len returns the number of buffered events
+	Len() int
+}
+
+// CommittableBuffer is an event buffer that keeps delivered events until the
+// sink commits them, so events lost in flight are replayed after a restart
+type CommittableBuffer interface {
+	EventBuffer
+	// Commit releases the oldest n events handed to the sink
+	Commit(n int) error
+}
+
+// Checkpoint records how far a pipeline has durably delivered events
+type Checkpoint struct {
+	Pipeline  string    `json:"pipeline"`
+	Position  string    `json:"position"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CheckpointStore persists pipeline checkpoints
+type CheckpointStore interface {
+	// Load returns the checkpoint for a pipeline, or nil if none was saved
+	Load(ctx context.Context, pipeline string) (*Checkpoint, error)
+	// Save stores the checkpoint for a pipeline
+	Save(ctx context.Context, checkpoint Checkpoint) error
+}
+
+// ConcurrentSink is implemented by sinks whose Write may be called from
+// several goroutines at once, each with its own input channel
+type ConcurrentSink interface {
+	Sink
+	// SupportsConcurrentWrites marks the sink as safe for concurrent Write calls
+	SupportsConcurrentWrites()
+}
+
+// TransactionalCheckpointStore is a CheckpointStore whose checkpoints are
+// written by the sink inside each batch transaction, so the pipeline does
+// not need to save them separately after a successful commit
+type TransactionalCheckpointStore interface {
+	CheckpointStore
+	// CheckpointsInTransaction reports whether batches carry their checkpoint
+	CheckpointsInTransaction() bool
+	// HoldCheckpoints stops batches from carrying their checkpoint while held,
+	// because an earlier batch of the run failed and must be replayed
+	HoldCheckpoints(held bool)
+}
+
+// ReadyNotifier is implemented by sources that report when they begin
+// capturing changes, which can be later than Connect returns
+type ReadyNotifier interface {
+	// Ready returns a channel closed once changes made from now on will be read
+	Ready() <-chan struct{}
+}
+
+// Acknowledger is implemented by sources that must learn when their events
+// are durably written, such as queues that delete or commit consumed
+// messages. The pipeline acknowledges positions in order once the sink
+// commits the events up to them, which requires a sink that reports commits.
+type Acknowledger interface {
+	// Ack reports that every event up to and including the one at position
+	// has been durably written
+	Ack(ctx context.Context, position string) error
+}
+
+// Resumable is implemented by sources that can start reading from a saved position
+type Resumable interface {
+	// SetStartPosition makes the next Read start after the given position
+	SetStartPosition(position string) error
+}
+
+// TimeSeeker is implemented by sources that can start reading at a point in time
+type TimeSeeker interface {
+	// SetStartTime makes the next Read start with the changes made at or after t
+	SetStartTime(t time.Time) error
+}
+
+// CommitHandler is called by a sink after it finishes a batch, in order.
+// err is non-nil if the batch could not be written.
+type CommitHandler func(events []Event, err error)
+
+// CommitNotifier is implemented by sinks that report when batches are durably written
+type CommitNotifier interface {
+	// SetCommitHandler registers the handler called after every batch
+	SetCommitHandler(handler CommitHandler)
+}
+
+// AckStatus is the outcome of writing one event
+type AckStatus string
+
+const (
+	// AckSuccess means the event was durably written
+	AckSuccess AckStatus = "success"
+	// AckRetryable means the event failed but may succeed if written again,
+	// e.g. because the destination was overloaded
+	AckRetryable AckStatus = "retryable"
+	// AckPermanent means the destination rejected the event and writing it
+	// again would fail the same way
+	AckPermanent AckStatus = "permanent"
+)
+
+// Ack is a sink's result for one event of a batch
+type Ack struct {
+	Event  Event
+	Status AckStatus
+	Err    error // why the event failed, if it did
+}
+
+// AckHandler is called by a sink after it finishes a batch, in order, with a
+// result for every event of the batch in the order they were received
+type AckHandler func(acks []Ack)
+
+// AckNotifier is implemented by sinks that report the outcome of every event
+// rather than of whole batches. The pipeline prefers it to CommitNotifier.
+type AckNotifier interface {
+	// SetAckHandler registers the handler called after every batch
+	SetAckHandler(handler AckHandler)
+}
+
+// WriteAheadLog is a durable queue that holds events until the sink commits them
+type WriteAheadLog interface {
+	EventBuffer
+	// Commit releases the oldest n events handed to the sink
+	Commit(n int) error
+	// Last returns the most recently appended event
+	Last() (Event, bool)
+}
+
+// AuditRecord is a compact trace of what happened to a single event
+type AuditRecord struct {
+	Pipeline   string    `json:"pipeline"`
+	EventID    string    `json:"event_id"`
+	Operation  string    `json:"operation"`
+	Collection string    `json:"collection"`
+	Outcome    string    `json:"outcome"`
+	Reason     string    `json:"reason,omitempty"`
+	LatencyMs  float64   `json:"latency_ms"` // time from the source change to the outcome
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// AuditLog receives an audit record for every processed event, independent of the sink
+type AuditLog interface {
+	// Record stores a group of audit records
+	Record(ctx context.Context, records []AuditRecord) error
+}
+
+// RunSummary describes one execution of a pipeline
+type RunSummary struct {
+	ID              string     `json:"id"`
+	Pipeline        string     `json:"pipeline"`
+	Status          string     `json:"status"` // running, completed, stopped or failed
+	StartedAt       time.Time  `json:"started_at"`
+	EndedAt         *time.Time `json:"ended_at,omitempty"`
+	StartPosition   string     `json:"start_position,omitempty"`
+	EventsProcessed int64      `json:"events_processed"`
+	EventsRejected  int64      `json:"events_rejected"`
+	Error           string     `json:"error,omitempty"`
+}
+
+// SnapshotStats describes the last initial sync (snapshot) of a pipeline
+type SnapshotStats struct {
+	Pipeline    string    `json:"pipeline"`
+	Status      string    `json:"status"` // completed or failed
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+	Read        int64     `json:"read"`      // documents read from the source
+	Documents   int64     `json:"documents"` // events handed to the sink
+	Written     int64     `json:"written"`   // events the sink committed
+	Rejected    int64     `json:"rejected"`  // documents dropped by the transformer or size limit
+	Errors      int64     `json:"errors"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// RunStore persists pipeline run history and snapshot statistics
+type RunStore interface {
+	// SaveRun creates or updates a run, keyed by its ID
+	SaveRun(ctx context.Context, run RunSummary) error
+	// Runs returns up to limit runs of a pipeline, newest first
+	Runs(ctx context.Context, pipeline string, limit int) ([]RunSummary, error)
+	// SaveSnapshot stores the statistics of a pipeline's last snapshot
+	SaveSnapshot(ctx context.Context, stats SnapshotStats) error
+	// LoadSnapshot returns the last snapshot statistics, or nil if none was saved
+	LoadSnapshot(ctx context.Context, pipeline string) (*SnapshotStats, error)
+}
@@ -0,0 +1,107 @@
+package pipeline
+
+import (
+	"context"
+	"time"
+)
+
+// walRetryInterval is how long the WAL writer waits before retrying a full log
+const walRetryInterval = 100 * time.Millisecond
+
+// SetWriteAheadLog enables store-and-forward mode: every event is appended to
+// the log before being handed to the sink, and is only released (and the
+// source checkpoint advanced) once the sink has committed it. The source keeps
+// reading while the sink is unavailable until the log is full.
+func (p *Pipeline) SetWriteAheadLog(wal WriteAheadLog) {
+	p.wal = wal
+}
+
+// runWAL appends incoming events to the write-ahead log and forwards logged
+// events to the sink independently, decoupling source and sink
+func (p *Pipeline) runWAL(ctx context.Context, in <-chan Event) <-chan Event {
+	out := make(chan Event)
+	appended := make(chan struct{}, 1)
+	writerDone := make(chan struct{})
+
+	// Writer: source side
+	go func() {
+		defer close(writerDone)
+		for event := range in {
+			p.appendToWAL(ctx, event)
+			select {
+			case appended <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	// Forwarder: sink side
+	go func() {
+		defer close(out)
+		for {
+			event, ok, err := p.wal.Peek()
+			if err != nil {
+				p.logger.Printf("Failed to read write-ahead log: %v", err)
+				p.recordError("wal", "read_error", err)
+				return
+			}
+
+			if ok {
+				// Advance before handing the event over so the sink can never
+				// commit an event the log does not yet consider in flight. An
+				// event not delivered due to shutdown stays uncommitted and is
+				// read again on the next run.
+				if err := p.wal.Advance(); err != nil {
+					p.logger.Printf("Failed to advance write-ahead log: %v", err)
+					return
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case out <- event:
+				}
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-appended:
+			case <-writerDone:
+				if p.wal.Len() == 0 {
+					return
+				}
+			}
+		}
+	}()
+
+	return out
+}
+
+// appendToWAL appends an event, waiting for space while the log is full.
+// An event dropped at shutdown is re-read on the next run, because the source
+// resumes from the last event actually in the log.
+func (p *Pipeline) appendToWAL(ctx context.Context, event Event) {
+	warned := false
+	for {
+		err := p.wal.Append(event)
+		if err == nil {
+			return
+		}
+		if !warned {
+			p.logger.Printf("Write-ahead log unavailable, pausing source: %v", err)
+			warned = true
+		}
+
+		if p.opMetrics != nil {
+			p.opMetrics.RecordRetry(p.name, "wal")
+		}
+
+		select {
+		case <-ctx.Done():
+			p.logger.Printf("Not logging event %s during shutdown, it will be re-read on restart", event.ID)
+			return
+		case <-p.clock.After(walRetryInterval):
+		}
+	}
+}
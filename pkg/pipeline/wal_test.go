@@ -0,0 +1,150 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// memoryWAL is an in-memory WriteAheadLog for testing
+type memoryWAL struct {
+	memoryBuffer
+	inflight  int
+	committed []Event
+	last      *Event
+}
+
+func (m *memoryWAL) Append(event Event) error {
+	if err := m.memoryBuffer.Append(event); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.last = &event
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memoryWAL) Advance() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.committed = append(m.committed, m.events[0])
+	m.events = m.events[1:]
+	m.inflight++
+	return nil
+}
+
+func (m *memoryWAL) Commit(n int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if n > m.inflight {
+		return fmt.Errorf("commit %d > inflight %d", n, m.inflight)
+	}
+	m.inflight -= n
+	return nil
+}
+
+func (m *memoryWAL) Last() (Event, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.last == nil {
+		return Event{}, false
+	}
+	return *m.last, true
+}
+
+// TestPipelineStoreAndForward tests that events flow through the WAL and are committed
+func TestPipelineStoreAndForward(t *testing.T) {
+	var events []Event
+	for i := 0; i < 5; i++ {
+		events = append(events, Event{ID: fmt.Sprint(i), Position: fmt.Sprint("p", i)})
+	}
+
+	source := &resumableSource{MockSource: *NewMockSource(events)}
+	sink := &commitSink{batchSize: 2}
+	wal := &memoryWAL{}
+	store := newMemoryCheckpointStore()
+
+	p := New("test", source, sink, nil, nil)
+	p.SetWriteAheadLog(wal)
+	p.SetCheckpointStore(store)
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if len(sink.received) != 5 {
+		t.Fatalf("expected 5 events at sink, got %d", len(sink.received))
+	}
+	for i, event := range sink.received {
+		if event.ID != fmt.Sprint(i) {
+			t.Errorf("event %d out of order: %s", i, event.ID)
+		}
+	}
+	if wal.Len() != 0 || wal.inflight != 0 {
+		t.Errorf("expected WAL fully committed, len=%d inflight=%d", wal.Len(), wal.inflight)
+	}
+	if got := store.saved["test"].Position; got != "p4" {
+		t.Errorf("expected checkpoint p4, got %q", got)
+	}
+}
+
+// TestPipelineStoreAndForwardKeepsFailedBatch tests that a failed batch and
+// everything after it stay in the WAL and behind the checkpoint for replay
+func TestPipelineStoreAndForwardKeepsFailedBatch(t *testing.T) {
+	var events []Event
+	for i := 0; i < 6; i++ {
+		events = append(events, Event{ID: fmt.Sprint(i), Position: fmt.Sprint("p", i)})
+	}
+
+	sink := &commitSink{batchSize: 2, failFrom: 2}
+	wal := &memoryWAL{}
+	store := newMemoryCheckpointStore()
+
+	p := New("test", NewMockSource(events), sink, nil, nil)
+	p.SetWriteAheadLog(wal)
+	p.SetCheckpointStore(store)
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if wal.inflight != 4 {
+		t.Errorf("expected the failed batch and the one after it left in the WAL, %d events in flight", wal.inflight)
+	}
+	if got := store.saved["test"].Position; got != "p1" {
+		t.Errorf("expected checkpoint to stay at p1, got %q", got)
+	}
+}
+
+// TestPipelineStoreAndForwardResume tests that the source resumes from the WAL tail
+func TestPipelineStoreAndForwardResume(t *testing.T) {
+	wal := &memoryWAL{}
+	wal.Append(Event{ID: "pending", Position: "wal-tail"})
+
+	store := newMemoryCheckpointStore()
+	store.saved["test"] = Checkpoint{Pipeline: "test", Position: "older"}
+
+	source := &resumableSource{MockSource: *NewMockSource([]Event{{ID: "new", Position: "p9"}})}
+	sink := &commitSink{batchSize: 10}
+
+	p := New("test", source, sink, nil, nil)
+	p.SetWriteAheadLog(wal)
+	p.SetCheckpointStore(store)
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if source.startPosition != "wal-tail" {
+		t.Errorf("expected source to resume from WAL tail, got %q", source.startPosition)
+	}
+	if len(sink.received) != 2 || sink.received[0].ID != "pending" {
+		t.Errorf("expected pending WAL event delivered first, got %+v", sink.received)
+	}
+}
+
+// TestPipelineStoreAndForwardRequiresNotifier tests that a sink without commit reporting is rejected
+func TestPipelineStoreAndForwardRequiresNotifier(t *testing.T) {
+	p := New("test", NewMockSource(nil), NewMockSink(), nil, nil)
+	p.SetWriteAheadLog(&memoryWAL{})
+	if err := p.Run(context.Background()); err == nil {
+		t.Error("expected error for sink without commit reporting")
+	}
+}
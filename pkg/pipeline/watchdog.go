@@ -0,0 +1,188 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Watchdog detects a source that stays connected but stops delivering
+// events, such as a change stream silently dropped by a proxy. The source
+// counts as stalled once the pipeline has waited on it for longer than Window
+// without receiving an event; time spent waiting on the transformer or sink
+// does not count.
+type Watchdog struct {
+	Window        time.Duration // how long the source may be silent (0 disables the watchdog)
+	RestartSource bool          // close and reconnect a stalled source, resuming after its last event
+}
+
+// minWatchdogInterval bounds how often the watchdog checks the source
+const minWatchdogInterval = time.Second
+
+// SetWatchdog flags the pipeline unhealthy, and optionally restarts its
+// source, when the source stalls
+func (p *Pipeline) SetWatchdog(watchdog Watchdog) {
+	p.watchdog = watchdog
+}
+
+// waitForSource notes that the event stage begins waiting on the source
+func (p *Pipeline) waitForSource() {
+	if p.watchdog.Window <= 0 {
+		return
+	}
+	p.mu.Lock()
+	p.readingSince = p.clock.Now()
+	p.mu.Unlock()
+}
+
+// sourceStalledLocked returns how long the pipeline has waited on the source
+// and whether that makes it stalled (caller must hold read lock)
+func (p *Pipeline) sourceStalledLocked() (time.Duration, bool) {
+	if p.watchdog.Window <= 0 || !p.sourceConnected || p.readingSince.IsZero() {
+		return 0, false
+	}
+	if stage, _ := p.stage.Load().(string); stage != StageReading {
+		return 0, false
+	}
+	silence := p.clock.Since(p.readingSince)
+	return silence, silence > p.watchdog.Window
+}
+
+// readSource starts reading the source. With a watchdog, the source is
+// watched, and if it is to be restarted its events are passed through a
+// reader that reopens it on a stall.
+func (p *Pipeline) readSource(ctx context.Context) (<-chan Event, <-chan error) {
+	if p.watchdog.Window <= 0 {
+		return p.source.Read(ctx)
+	}
+	if !p.watchdog.RestartSource {
+		go p.watchSource(ctx, nil)
+		return p.source.Read(ctx)
+	}
+
+	restart := make(chan struct{}, 1)
+	go p.watchSource(ctx, restart)
+	out := make(chan Event)
+	outErrors := make(chan error)
+	go func() {
+		defer close(out)
+		defer close(outErrors)
+		var position string
+		for {
+			readCtx, cancel := context.WithCancel(ctx)
+			events, sourceErrors := p.source.Read(readCtx)
+			var restarting bool
+			position, restarting = p.forwardSource(ctx, events, sourceErrors, out, outErrors, restart, position)
+			cancel()
+			// A cancelled source closes its channels without blocking
+			for range events {
+			}
+			for range sourceErrors {
+			}
+			if !restarting || ctx.Err() != nil {
+				return
+			}
+			if err := p.reopenSource(ctx, position); err != nil {
+				p.stopRun(err)
+				return
+			}
+		}
+	}()
+	return out, outErrors
+}
+
+// forwardSource passes a source's events and errors on until it ends or the
+// watchdog asks for a restart, returning the position of the last event
+// passed on and whether to restart
+func (p *Pipeline) forwardSource(ctx context.Context, events <-chan Event, sourceErrors <-chan error, out chan<- Event, outErrors chan<- error, restart <-chan struct{}, position string) (string, bool) {
+	for events != nil || sourceErrors != nil {
+		select {
+		case <-ctx.Done():
+			return position, false
+		case <-restart:
+			return position, true
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			select {
+			case out <- event:
+				if event.Position != "" {
+					position = event.Position
+				}
+			case <-ctx.Done():
+				return position, false
+			}
+		case err, ok := <-sourceErrors:
+			if !ok {
+				sourceErrors = nil
+				continue
+			}
+			select {
+			case outErrors <- err:
+			case <-ctx.Done():
+				return position, false
+			}
+		}
+	}
+	return position, false
+}
+
+// reopenSource closes and reconnects the source, resuming after position if
+// the source is resumable and an event was read
+func (p *Pipeline) reopenSource(ctx context.Context, position string) error {
+	p.logger.Printf("Watchdog: restarting stalled source")
+	p.source.Close()
+	if err := p.source.Connect(ctx); err != nil {
+		return fmt.Errorf("failed to reconnect stalled source: %w", err)
+	}
+	if resumable, ok := p.source.(Resumable); ok && position != "" {
+		if err := resumable.SetStartPosition(position); err != nil {
+			return fmt.Errorf("failed to resume restarted source: %w", err)
+		}
+	}
+	if p.opMetrics != nil {
+		p.opMetrics.RecordRetry(p.name, "source")
+	}
+	p.waitForSource()
+	return nil
+}
+
+// watchSource checks the source until ctx ends, reporting each stall once
+// and asking for a restart if restart is set
+func (p *Pipeline) watchSource(ctx context.Context, restart chan<- struct{}) {
+	interval := p.watchdog.Window / 4
+	if interval < minWatchdogInterval {
+		interval = minWatchdogInterval
+	}
+	reported := false
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-p.clock.After(interval):
+		}
+
+		p.mu.RLock()
+		silence, stalled := p.sourceStalledLocked()
+		p.mu.RUnlock()
+		if !stalled {
+			reported = false
+			continue
+		}
+		if reported {
+			continue
+		}
+		reported = true
+		err := fmt.Errorf("no event from the connected source for %s", silence.Round(time.Second))
+		p.logger.Printf("Watchdog: %v", err)
+		p.recordError("source", "stalled", err)
+		if restart != nil {
+			select {
+			case restart <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
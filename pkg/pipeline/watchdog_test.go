@@ -0,0 +1,152 @@
+package pipeline
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stallingSource is a mock source that emits the next batch of events on
+// every Read and then goes silent until cancelled
+type stallingSource struct {
+	MockSource
+	mu            sync.Mutex
+	batches       [][]Event
+	connects      int
+	closes        int
+	startPosition string
+}
+
+func (s *stallingSource) Connect(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connects++
+	return nil
+}
+
+func (s *stallingSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closes++
+	return nil
+}
+
+func (s *stallingSource) SetStartPosition(position string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.startPosition = position
+	return nil
+}
+
+func (s *stallingSource) Read(ctx context.Context) (<-chan Event, <-chan error) {
+	s.mu.Lock()
+	var batch []Event
+	if len(s.batches) > 0 {
+		batch, s.batches = s.batches[0], s.batches[1:]
+	}
+	s.mu.Unlock()
+
+	events := make(chan Event)
+	errors := make(chan error)
+	go func() {
+		defer close(events)
+		defer close(errors)
+		for _, event := range batch {
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		}
+		<-ctx.Done()
+	}()
+	return events, errors
+}
+
+// advanceUntil advances the clock past the watchdog window until cond holds
+func advanceUntil(t *testing.T, clock *ManualClock, window time.Duration, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met")
+		}
+		if clock.Waiters() > 0 {
+			clock.Advance(window + time.Second)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestPipelineWatchdogFlagsStalledSource tests that a silent source makes the pipeline unhealthy
+func TestPipelineWatchdogFlagsStalledSource(t *testing.T) {
+	clock := NewManualClock(time.Unix(1700000000, 0))
+	source := &stallingSource{batches: [][]Event{{{ID: "1", Operation: "insert"}}}}
+	sink := &channelSink{written: make(chan Event, 1)}
+	p := New("test", source, sink, nil, nil)
+	p.SetClock(clock)
+	p.SetWatchdog(Watchdog{Window: 10 * time.Second})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- p.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	<-sink.written
+	if !p.IsHealthy() {
+		t.Fatal("expected a healthy pipeline within the watchdog window")
+	}
+
+	advanceUntil(t, clock, 10*time.Second, func() bool { return p.Stats().Errors > 0 })
+	status := p.GetStatus()
+	if status.Healthy || !status.SourceStalled {
+		t.Errorf("expected an unhealthy pipeline with a stalled source, got %+v", status)
+	}
+	source.mu.Lock()
+	defer source.mu.Unlock()
+	if source.connects != 1 {
+		t.Errorf("expected the source not to be restarted, got %d connects", source.connects)
+	}
+}
+
+// TestPipelineWatchdogRestartsStalledSource tests that a stalled source is reopened after its last event
+func TestPipelineWatchdogRestartsStalledSource(t *testing.T) {
+	clock := NewManualClock(time.Unix(1700000000, 0))
+	source := &stallingSource{batches: [][]Event{
+		{{ID: "1", Operation: "insert", Position: "p1"}},
+		{{ID: "2", Operation: "insert", Position: "p2"}},
+	}}
+	sink := &channelSink{written: make(chan Event, 2)}
+	p := New("test", source, sink, nil, nil)
+	p.SetClock(clock)
+	p.SetWatchdog(Watchdog{Window: 10 * time.Second, RestartSource: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- p.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	<-sink.written
+
+	var second Event
+	advanceUntil(t, clock, 10*time.Second, func() bool {
+		select {
+		case second = <-sink.written:
+			return true
+		default:
+			return false
+		}
+	})
+	if second.ID != "2" {
+		t.Errorf("expected event 2 from the restarted source, got %+v", second)
+	}
+	source.mu.Lock()
+	defer source.mu.Unlock()
+	if source.connects != 2 || source.closes != 1 || source.startPosition != "p1" {
+		t.Errorf("expected one reconnect resuming after p1, got %d connects, %d closes, position %q", source.connects, source.closes, source.startPosition)
+	}
+}
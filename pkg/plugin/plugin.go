@@ -0,0 +1,203 @@
+// Package plugin runs sources, sinks and transformers shipped as separate
+// executables, so data-pipe can be extended without recompiling it.
+//
+// A plugin is started as a child process and speaks JSON-RPC 1.0 (as
+// implemented by net/rpc/jsonrpc) over its standard input and output; what it
+// writes to standard error is logged by the pipeline. It serves a service
+// named "Plugin" with the methods:
+//
+//	Handshake(HandshakeArgs) HandshakeReply  first call, passing the plugin's settings
+//	Transform(Event) Event                   transformers
+//	Connect(Empty) Empty                     sources and sinks
+//	Read(ReadArgs) ReadReply                 sources
+//	Write(WriteArgs) Empty                   sinks
+//	Close(Empty) Empty                       sources and sinks, before the process is stopped
+//
+// Events are pipeline.Event encoded as JSON, so BSON values in their data
+// arrive as their JSON representations. Plugins written in Go implement
+// EventReader, BatchWriter or pipeline.Transformer and call Serve; plugins in
+// other languages implement the protocol above. The plugin exits once its standard
+// input is closed.
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// ProtocolVersion is the version of the plugin protocol, checked on handshake
+const ProtocolVersion = 1
+
+// Kinds of component a plugin can provide
+const (
+	KindSource      = "source"
+	KindSink        = "sink"
+	KindTransformer = "transformer"
+)
+
+// handshakeTimeout bounds how long a plugin may take to start and answer the handshake
+const handshakeTimeout = 30 * time.Second
+
+// closeTimeout is how long a plugin is given to exit before it is killed
+const closeTimeout = 5 * time.Second
+
+// Empty is the argument or reply of calls that carry none
+type Empty struct{}
+
+// HandshakeArgs are sent to a plugin when it starts
+type HandshakeArgs struct {
+	ProtocolVersion int                    `json:"protocol_version"`
+	Settings        map[string]interface{} `json:"settings"`
+}
+
+// HandshakeReply describes the plugin
+type HandshakeReply struct {
+	ProtocolVersion int      `json:"protocol_version"`
+	Kinds           []string `json:"kinds"` // source, sink and/or transformer
+}
+
+// Config describes how to start a plugin
+type Config struct {
+	Path     string                 `json:"path"`     // Plugin executable
+	Args     []string               `json:"args"`     // Command-line arguments
+	Env      []string               `json:"env"`      // Extra environment variables as "NAME=value"
+	Settings map[string]interface{} `json:"settings"` // Passed to the plugin on handshake
+}
+
+// Client is a running plugin process
+type Client struct {
+	name   string
+	cmd    *exec.Cmd
+	rpc    *rpc.Client
+	kinds  []string
+	logger *log.Logger
+}
+
+// Start launches a plugin and performs the handshake
+func Start(config Config, logger *log.Logger) (*Client, error) {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if config.Path == "" {
+		return nil, fmt.Errorf("plugin requires a path")
+	}
+	cmd := exec.Command(config.Path, config.Args...)
+	cmd.Env = append(os.Environ(), config.Env...)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", config.Path, err)
+	}
+
+	c := &Client{
+		name:   filepath.Base(config.Path),
+		cmd:    cmd,
+		rpc:    jsonrpc.NewClient(stdio{ReadCloser: stdout, WriteCloser: stdin}),
+		logger: logger,
+	}
+	go c.logStderr(stderr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), handshakeTimeout)
+	defer cancel()
+	var reply HandshakeReply
+	err = c.call(ctx, "Plugin.Handshake", HandshakeArgs{ProtocolVersion: ProtocolVersion, Settings: config.Settings}, &reply)
+	if err == nil && reply.ProtocolVersion != ProtocolVersion {
+		err = fmt.Errorf("plugin speaks protocol version %d, expected %d", reply.ProtocolVersion, ProtocolVersion)
+	}
+	if err != nil {
+		c.stop()
+		return nil, fmt.Errorf("plugin %s handshake failed: %w", c.name, err)
+	}
+	c.kinds = reply.Kinds
+	logger.Printf("Started plugin %s (%v)", c.name, c.kinds)
+	return c, nil
+}
+
+// Provides reports whether the plugin provides a kind of component
+func (c *Client) Provides(kind string) bool {
+	for _, k := range c.kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// call invokes a plugin method, giving up when ctx is done
+func (c *Client) call(ctx context.Context, method string, args, reply interface{}) error {
+	call := c.rpc.Go(method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close asks the plugin to close, then stops its process
+func (c *Client) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	err := c.call(ctx, "Plugin.Close", Empty{}, &Empty{})
+	c.stop()
+	return err
+}
+
+// stop closes the plugin's input so it exits, killing it if it does not
+func (c *Client) stop() {
+	c.rpc.Close()
+	exited := make(chan struct{})
+	go func() {
+		c.cmd.Wait()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(closeTimeout):
+		c.logger.Printf("Plugin %s did not exit, killing it", c.name)
+		c.cmd.Process.Kill()
+		<-exited
+	}
+}
+
+// logStderr logs what the plugin writes to standard error, line by line
+func (c *Client) logStderr(stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		c.logger.Printf("[plugin %s] %s", c.name, scanner.Text())
+	}
+}
+
+// stdio joins a process's output and input into one connection
+type stdio struct {
+	io.ReadCloser
+	io.WriteCloser
+}
+
+// Close closes both directions
+func (s stdio) Close() error {
+	err := s.WriteCloser.Close()
+	if rerr := s.ReadCloser.Close(); err == nil {
+		err = rerr
+	}
+	return err
+}
@@ -0,0 +1,192 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// testPluginEnv makes the test binary serve testPlugin instead of running tests
+const testPluginEnv = "DATA_PIPE_TEST_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(testPluginEnv) != "" {
+		if err := Serve(&testPlugin{}); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// testPlugin is a source of numbered events, a sink appending to a file and
+// a transformer upper-casing the name field
+type testPlugin struct {
+	count int
+	path  string
+}
+
+func (t *testPlugin) Configure(settings map[string]interface{}) error {
+	if count, ok := settings["count"].(float64); ok {
+		t.count = int(count)
+	}
+	t.path, _ = settings["path"].(string)
+	return nil
+}
+
+func (t *testPlugin) Transform(event pipeline.Event) (pipeline.Event, error) {
+	if event.ID == "bad" {
+		return event, fmt.Errorf("cannot transform %s", event.ID)
+	}
+	name, _ := event.Data["name"].(string)
+	event.Data["name"] = strings.ToUpper(name)
+	return event, nil
+}
+
+func (t *testPlugin) Connect(ctx context.Context) error {
+	return nil
+}
+
+func (t *testPlugin) Read(ctx context.Context, position string, max int) ([]pipeline.Event, bool, error) {
+	next := 1
+	if position != "" {
+		last, err := strconv.Atoi(position)
+		if err != nil {
+			return nil, false, err
+		}
+		next = last + 1
+	}
+	var events []pipeline.Event
+	for ; next <= t.count && len(events) < max; next++ {
+		events = append(events, pipeline.Event{ID: strconv.Itoa(next), Operation: "insert", Position: strconv.Itoa(next)})
+	}
+	return events, next > t.count, nil
+}
+
+func (t *testPlugin) Write(ctx context.Context, events []pipeline.Event) error {
+	f, err := os.OpenFile(t.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	for _, event := range events {
+		if event.ID == "bad" {
+			return fmt.Errorf("cannot write %s", event.ID)
+		}
+		fmt.Fprintln(f, event.ID)
+	}
+	return nil
+}
+
+func (t *testPlugin) Close() error {
+	return nil
+}
+
+// testConfig starts the test binary as a plugin
+func testConfig(settings map[string]interface{}) Config {
+	return Config{Path: os.Args[0], Env: []string{testPluginEnv + "=1"}, Settings: settings}
+}
+
+// TestStart tests the handshake and failures to start
+func TestStart(t *testing.T) {
+	client, err := Start(testConfig(nil), nil)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	for _, kind := range []string{KindSource, KindSink, KindTransformer} {
+		if !client.Provides(kind) {
+			t.Errorf("expected the plugin to provide a %s", kind)
+		}
+	}
+	if err := client.Close(); err != nil {
+		t.Errorf("Close() error = %v", err)
+	}
+
+	if _, err := Start(Config{Path: filepath.Join(t.TempDir(), "missing")}, nil); err == nil {
+		t.Error("expected an error for a missing plugin")
+	}
+	if _, err := Start(Config{}, nil); err == nil {
+		t.Error("expected an error without a path")
+	}
+}
+
+// TestTransformer tests transforming events in a plugin
+func TestTransformer(t *testing.T) {
+	transformer, err := NewTransformer(testConfig(nil), nil)
+	if err != nil {
+		t.Fatalf("NewTransformer() error = %v", err)
+	}
+	defer transformer.Close()
+
+	event, err := transformer.Transform(context.Background(), pipeline.Event{ID: "1", Data: map[string]interface{}{"name": "jane"}})
+	if err != nil {
+		t.Fatalf("Transform() error = %v", err)
+	}
+	if event.Data["name"] != "JANE" {
+		t.Errorf("expected the plugin to transform the event, got %v", event.Data)
+	}
+	if _, err := transformer.Transform(context.Background(), pipeline.Event{ID: "bad"}); err == nil || !strings.Contains(err.Error(), "cannot transform bad") {
+		t.Errorf("expected the plugin's error, got %v", err)
+	}
+}
+
+// TestSourceAndSink tests a pipeline between a source plugin and a sink plugin
+func TestSourceAndSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "written.txt")
+	src := NewSource(SourceConfig{Plugin: testConfig(map[string]interface{}{"count": 7}), MaxEvents: 3}, nil)
+	if err := src.SetStartPosition("2"); err != nil {
+		t.Fatalf("SetStartPosition() error = %v", err)
+	}
+	snk := NewSink(SinkConfig{Plugin: testConfig(map[string]interface{}{"path": path}), BatchEvents: 2}, nil)
+
+	var commits []int
+	p := pipeline.New("test", src, snk, nil, nil)
+	p.SetHooks(pipeline.Hooks{OnBatchCommitted: func(events []pipeline.Event) { commits = append(commits, len(events)) }})
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := p.Run(ctx); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read written events: %v", err)
+	}
+	if got := strings.Fields(string(written)); strings.Join(got, ",") != "3,4,5,6,7" {
+		t.Errorf("expected events 3 to 7 written, got %v", got)
+	}
+	if encoded, _ := json.Marshal(commits); string(encoded) != "[2,2,1]" {
+		t.Errorf("expected batches of 2, 2 and 1 committed, got %s", encoded)
+	}
+}
+
+// TestSinkWriteError tests that a failed plugin write fails its batch
+func TestSinkWriteError(t *testing.T) {
+	snk := NewSink(SinkConfig{Plugin: testConfig(map[string]interface{}{"path": filepath.Join(t.TempDir(), "written.txt")})}, nil)
+	if err := snk.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer snk.Close()
+
+	var failed error
+	snk.SetCommitHandler(func(events []pipeline.Event, err error) { failed = err })
+	events := make(chan pipeline.Event, 1)
+	events <- pipeline.Event{ID: "bad"}
+	close(events)
+	var errs []error
+	for err := range snk.Write(context.Background(), events) {
+		errs = append(errs, err)
+	}
+	if len(errs) != 1 || failed == nil || !strings.Contains(failed.Error(), "cannot write bad") {
+		t.Errorf("expected the batch to fail with the plugin's error, got %v and %v", errs, failed)
+	}
+}
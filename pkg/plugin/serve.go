@@ -0,0 +1,177 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// EventReader is implemented by source plugins
+type EventReader interface {
+	// Connect establishes connection to the source
+	Connect(ctx context.Context) error
+	// Read returns up to max events following position (empty for the
+	// beginning), and done once the source has no more events to give. It
+	// may wait briefly for events before returning none.
+	Read(ctx context.Context, position string, max int) (events []pipeline.Event, done bool, err error)
+	// Close closes the source connection
+	Close() error
+}
+
+// BatchWriter is implemented by sink plugins
+type BatchWriter interface {
+	// Connect establishes connection to the sink
+	Connect(ctx context.Context) error
+	// Write durably writes a batch of events, all or none
+	Write(ctx context.Context, events []pipeline.Event) error
+	// Close closes the sink connection
+	Close() error
+}
+
+// Configurable is implemented by plugins that take settings
+type Configurable interface {
+	// Configure receives the plugin's settings before any other call
+	Configure(settings map[string]interface{}) error
+}
+
+// ReadArgs ask a source plugin for events
+type ReadArgs struct {
+	Position  string `json:"position"`
+	MaxEvents int    `json:"max_events"`
+}
+
+// ReadReply carries the events a source plugin read
+type ReadReply struct {
+	Events []pipeline.Event `json:"events"`
+	Done   bool             `json:"done"`
+}
+
+// WriteArgs carry a batch of events to a sink plugin
+type WriteArgs struct {
+	Events []pipeline.Event `json:"events"`
+}
+
+// Serve serves a plugin over standard input and output until its input is
+// closed. The component must implement EventReader, BatchWriter or
+// pipeline.Transformer (or pipeline.LegacyTransformer), and may implement
+// Configurable. Plugins must not
+// write to standard output themselves, and should log to standard error.
+func Serve(component interface{}) error {
+	return serveConn(component, stdio{ReadCloser: os.Stdin, WriteCloser: os.Stdout})
+}
+
+// serveConn serves a plugin over a connection
+func serveConn(component interface{}, conn io.ReadWriteCloser) error {
+	s := &service{component: component}
+	switch transformer := component.(type) {
+	case pipeline.Transformer:
+		s.transformer = transformer
+	case pipeline.LegacyTransformer:
+		s.transformer = pipeline.AdaptTransformer(transformer)
+	}
+	if s.transformer != nil {
+		s.kinds = append(s.kinds, KindTransformer)
+	}
+	if _, ok := component.(EventReader); ok {
+		s.kinds = append(s.kinds, KindSource)
+	}
+	if _, ok := component.(BatchWriter); ok {
+		s.kinds = append(s.kinds, KindSink)
+	}
+	if len(s.kinds) == 0 {
+		return fmt.Errorf("%T is not a source, sink or transformer", component)
+	}
+
+	server := rpc.NewServer()
+	if err := server.RegisterName("Plugin", s); err != nil {
+		return err
+	}
+	server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	return nil
+}
+
+// service exposes a component over RPC
+type service struct {
+	component   interface{}
+	transformer pipeline.Transformer // the component as a transformer, if it is one
+	kinds       []string
+}
+
+// Handshake configures the component and describes it
+func (s *service) Handshake(args HandshakeArgs, reply *HandshakeReply) error {
+	if args.ProtocolVersion != ProtocolVersion {
+		return fmt.Errorf("unsupported protocol version %d, this plugin speaks %d", args.ProtocolVersion, ProtocolVersion)
+	}
+	if configurable, ok := s.component.(Configurable); ok {
+		if err := configurable.Configure(args.Settings); err != nil {
+			return err
+		}
+	}
+	reply.ProtocolVersion = ProtocolVersion
+	reply.Kinds = s.kinds
+	return nil
+}
+
+// Transform transforms an event
+func (s *service) Transform(event pipeline.Event, reply *pipeline.Event) error {
+	if s.transformer == nil {
+		return fmt.Errorf("plugin is not a transformer")
+	}
+	transformed, err := s.transformer.Transform(context.Background(), event)
+	if err != nil {
+		return err
+	}
+	*reply = transformed
+	return nil
+}
+
+// Connect connects a source or sink
+func (s *service) Connect(args Empty, reply *Empty) error {
+	switch component := s.component.(type) {
+	case EventReader:
+		return component.Connect(context.Background())
+	case BatchWriter:
+		return component.Connect(context.Background())
+	}
+	return nil
+}
+
+// Read reads events from a source
+func (s *service) Read(args ReadArgs, reply *ReadReply) error {
+	source, ok := s.component.(EventReader)
+	if !ok {
+		return fmt.Errorf("plugin is not a source")
+	}
+	events, done, err := source.Read(context.Background(), args.Position, args.MaxEvents)
+	if err != nil {
+		return err
+	}
+	reply.Events = events
+	reply.Done = done
+	return nil
+}
+
+// Write writes a batch of events to a sink
+func (s *service) Write(args WriteArgs, reply *Empty) error {
+	sink, ok := s.component.(BatchWriter)
+	if !ok {
+		return fmt.Errorf("plugin is not a sink")
+	}
+	return sink.Write(context.Background(), args.Events)
+}
+
+// Close closes a source or sink
+func (s *service) Close(args Empty, reply *Empty) error {
+	switch component := s.component.(type) {
+	case EventReader:
+		return component.Close()
+	case BatchWriter:
+		return component.Close()
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// SinkConfig contains configuration for a sink plugin
+type SinkConfig struct {
+	Plugin        Config
+	BatchEvents   int           // Events per write (default: 500)
+	FlushInterval time.Duration // Longest a partial batch waits before it is written (default: 1s)
+}
+
+// Sink implements the Sink interface with a plugin, started on Connect and
+// stopped on Close. Events are written in batches, each committed once the
+// plugin's write returns.
+type Sink struct {
+	config   SinkConfig
+	client   *Client
+	clock    pipeline.Clock
+	logger   *log.Logger
+	onCommit pipeline.CommitHandler
+}
+
+// NewSink creates a sink backed by a plugin
+func NewSink(config SinkConfig, logger *log.Logger) *Sink {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if config.BatchEvents <= 0 {
+		config.BatchEvents = 500
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	return &Sink{config: config, clock: pipeline.SystemClock, logger: logger}
+}
+
+// SetClock sets the time source used for flushing partial batches
+func (s *Sink) SetClock(clock pipeline.Clock) {
+	s.clock = clock
+}
+
+// SetCommitHandler registers a handler called after every batch.
+// The events slice is only valid for the duration of the call.
+func (s *Sink) SetCommitHandler(handler pipeline.CommitHandler) {
+	s.onCommit = handler
+}
+
+// Connect starts the plugin and connects it to its sink
+func (s *Sink) Connect(ctx context.Context) error {
+	client, err := Start(s.config.Plugin, s.logger)
+	if err != nil {
+		return err
+	}
+	if !client.Provides(KindSink) {
+		client.stop()
+		return fmt.Errorf("plugin %s is not a sink", client.name)
+	}
+	if err := client.call(ctx, "Plugin.Connect", Empty{}, &Empty{}); err != nil {
+		client.stop()
+		return fmt.Errorf("plugin %s failed to connect: %w", client.name, err)
+	}
+	s.client = client
+	return nil
+}
+
+// Write collects events into batches and writes each one once it holds the
+// configured number of events, once the flush interval has passed since its
+// first event, and when the input closes. Once the context is cancelled,
+// batches fail without reaching the plugin, so they are replayed.
+func (s *Sink) Write(ctx context.Context, events <-chan pipeline.Event) <-chan error {
+	errors := make(chan error)
+
+	go func() {
+		defer close(errors)
+
+		pending := pipeline.GetBatch(s.config.BatchEvents)
+		defer func() { pipeline.PutBatch(pending) }()
+
+		send := func() {
+			if len(pending) == 0 {
+				return
+			}
+			err := ctx.Err()
+			if err == nil {
+				err = s.client.call(ctx, "Plugin.Write", WriteArgs{Events: pending}, &Empty{})
+				if err != nil && ctx.Err() == nil {
+					err = fmt.Errorf("plugin %s failed to write %d events: %w", s.client.name, len(pending), err)
+					select {
+					case errors <- err:
+					case <-ctx.Done():
+					}
+				}
+			}
+			if s.onCommit != nil {
+				s.onCommit(pending, err)
+			}
+			pipeline.ReleaseEvents(pending)
+			pending = pending[:0]
+		}
+
+		// flush fires once a partial batch has waited for the flush interval
+		var flush <-chan time.Time
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					send()
+					return
+				}
+				pending = append(pending, event)
+				if len(pending) == 1 {
+					flush = s.clock.After(s.config.FlushInterval)
+				}
+				if len(pending) >= s.config.BatchEvents {
+					flush = nil
+					send()
+				}
+			case <-flush:
+				flush = nil
+				send()
+			}
+		}
+	}()
+
+	return errors
+}
+
+// Close closes the plugin's sink and stops it
+func (s *Sink) Close() error {
+	if s.client == nil {
+		return nil
+	}
+	err := s.client.Close()
+	s.client = nil
+	return err
+}
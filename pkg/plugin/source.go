@@ -0,0 +1,128 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// SourceConfig contains configuration for a source plugin
+type SourceConfig struct {
+	Plugin       Config
+	MaxEvents    int           // Events asked for per read (default: 500)
+	PollInterval time.Duration // Wait after a read returns no events (default: 1s)
+}
+
+// Source implements the Source interface with a plugin, started on Connect
+// and stopped on Close. It reads repeatedly from the position of the last
+// event it emitted until the plugin reports it is done or the context is
+// cancelled.
+type Source struct {
+	config   SourceConfig
+	client   *Client
+	position string
+	clock    pipeline.Clock
+	logger   *log.Logger
+}
+
+// NewSource creates a source backed by a plugin
+func NewSource(config SourceConfig, logger *log.Logger) *Source {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if config.MaxEvents <= 0 {
+		config.MaxEvents = 500
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = time.Second
+	}
+	return &Source{config: config, clock: pipeline.SystemClock, logger: logger}
+}
+
+// SetClock sets the time source used between reads
+func (s *Source) SetClock(clock pipeline.Clock) {
+	s.clock = clock
+}
+
+// SetStartPosition makes the next Read start after the given position
+func (s *Source) SetStartPosition(position string) error {
+	s.position = position
+	return nil
+}
+
+// Connect starts the plugin and connects it to its source
+func (s *Source) Connect(ctx context.Context) error {
+	client, err := Start(s.config.Plugin, s.logger)
+	if err != nil {
+		return err
+	}
+	if !client.Provides(KindSource) {
+		client.stop()
+		return fmt.Errorf("plugin %s is not a source", client.name)
+	}
+	if err := client.call(ctx, "Plugin.Connect", Empty{}, &Empty{}); err != nil {
+		client.stop()
+		return fmt.Errorf("plugin %s failed to connect: %w", client.name, err)
+	}
+	s.client = client
+	return nil
+}
+
+// Read reads from the plugin until it is done or the context is cancelled
+func (s *Source) Read(ctx context.Context) (<-chan pipeline.Event, <-chan error) {
+	events := make(chan pipeline.Event)
+	errors := make(chan error)
+
+	go func() {
+		defer close(events)
+		defer close(errors)
+
+		for ctx.Err() == nil {
+			var reply ReadReply
+			err := s.client.call(ctx, "Plugin.Read", ReadArgs{Position: s.position, MaxEvents: s.config.MaxEvents}, &reply)
+			if err != nil && ctx.Err() == nil {
+				select {
+				case errors <- fmt.Errorf("plugin %s read failed: %w", s.client.name, err):
+				case <-ctx.Done():
+					return
+				}
+			}
+			for _, event := range reply.Events {
+				select {
+				case events <- event:
+				case <-ctx.Done():
+					return
+				}
+				if event.Position != "" {
+					s.position = event.Position
+				}
+			}
+			if reply.Done {
+				return
+			}
+			if len(reply.Events) > 0 && err == nil {
+				continue // more events are likely waiting
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.clock.After(s.config.PollInterval):
+			}
+		}
+	}()
+
+	return events, errors
+}
+
+// Close closes the plugin's source and stops it
+func (s *Source) Close() error {
+	if s.client == nil {
+		return nil
+	}
+	err := s.client.Close()
+	s.client = nil
+	return err
+}
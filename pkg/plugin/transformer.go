@@ -0,0 +1,42 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// Transformer implements the Transformer interface with a plugin. The plugin
+// is started when the transformer is created and exits with the pipeline.
+type Transformer struct {
+	client *Client
+}
+
+// NewTransformer starts a transformer plugin
+func NewTransformer(config Config, logger *log.Logger) (*Transformer, error) {
+	client, err := Start(config, logger)
+	if err != nil {
+		return nil, err
+	}
+	if !client.Provides(KindTransformer) {
+		client.stop()
+		return nil, fmt.Errorf("plugin %s is not a transformer", client.name)
+	}
+	return &Transformer{client: client}, nil
+}
+
+// Transform transforms an event in the plugin
+func (t *Transformer) Transform(ctx context.Context, event pipeline.Event) (pipeline.Event, error) {
+	var transformed pipeline.Event
+	if err := t.client.call(ctx, "Plugin.Transform", event, &transformed); err != nil {
+		return event, fmt.Errorf("plugin %s failed to transform event %s: %w", t.client.name, event.ID, err)
+	}
+	return transformed, nil
+}
+
+// Close stops the plugin
+func (t *Transformer) Close() error {
+	return t.client.Close()
+}
@@ -0,0 +1,112 @@
+package schema
+
+import (
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Infer proposes a schema for a collection from sample documents. A field's
+// type is the one its non-null values share, integers and floats widening to
+// float, or any when they differ. A field is required when every document
+// has it and nullable when any of its values is null. The fields of nested
+// documents are described too, as dot-separated names, down to depth levels
+// of nesting; 0 describes only top-level fields.
+func Infer(collection string, documents []map[string]interface{}, depth int) Schema {
+	type observed struct {
+		count    int
+		nullable bool
+		typ      FieldType // "" until a non-null value is seen
+	}
+	fields := make(map[string]*observed)
+	var observe func(prefix string, document map[string]interface{}, level int)
+	observe = func(prefix string, document map[string]interface{}, level int) {
+		for name, value := range document {
+			name = prefix + name
+			field, ok := fields[name]
+			if !ok {
+				field = &observed{}
+				fields[name] = field
+			}
+			field.count++
+			if isNull(value) {
+				field.nullable = true
+				continue
+			}
+			field.typ = widen(field.typ, valueType(value))
+			if nested, ok := documentOf(value); ok && level < depth {
+				observe(name+".", nested, level+1)
+			}
+		}
+	}
+	for _, document := range documents {
+		observe("", document, 0)
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	// The document key comes first, the other fields by name
+	sort.Slice(names, func(i, j int) bool {
+		if names[i] == "_id" || names[j] == "_id" {
+			return names[i] == "_id"
+		}
+		return names[i] < names[j]
+	})
+
+	s := Schema{Collection: collection, Fields: make([]Field, 0, len(names))}
+	for _, name := range names {
+		field := fields[name]
+		typ := field.typ
+		if typ == "" {
+			typ = Any
+		}
+		s.Fields = append(s.Fields, Field{
+			Name:     name,
+			Type:     typ,
+			Required: field.count == len(documents),
+			Nullable: field.nullable,
+		})
+	}
+	return s
+}
+
+// valueType returns the field type of a non-null value, any if it has none
+func valueType(value interface{}) FieldType {
+	for _, t := range []FieldType{String, Int, Float, Bool, Timestamp, ObjectID, Object, Array} {
+		if t.accepts(value) {
+			return t
+		}
+	}
+	return Any
+}
+
+// documentOf returns the fields of a nested document
+func documentOf(value interface{}) (map[string]interface{}, bool) {
+	switch doc := value.(type) {
+	case map[string]interface{}:
+		return doc, true
+	case bson.M:
+		return doc, true
+	case bson.D:
+		fields := make(map[string]interface{}, len(doc))
+		for _, element := range doc {
+			fields[element.Key] = element.Value
+		}
+		return fields, true
+	}
+	return nil, false
+}
+
+// widen returns a type accepting the values of both types
+func widen(a, b FieldType) FieldType {
+	switch {
+	case a == "" || a == b:
+		return b
+	case (a == Int && b == Float) || (a == Float && b == Int):
+		return Float
+	default:
+		return Any
+	}
+}
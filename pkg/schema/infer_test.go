@@ -0,0 +1,66 @@
+package schema
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TestInfer tests proposing a schema from sample documents
+func TestInfer(t *testing.T) {
+	id := primitive.NewObjectID()
+	documents := []map[string]interface{}{
+		{"_id": id, "name": "jane", "age": int32(30), "score": 1.5, "joined": primitive.NewDateTimeFromTime(time.Now()), "address": bson.M{"city": "Oslo"}},
+		{"_id": id, "name": "john", "age": int64(41), "score": int32(2), "tags": bson.A{"a"}, "nickname": nil},
+		{"_id": id, "name": nil, "age": "unknown", "score": nil},
+	}
+
+	got := Infer("users", documents, 0)
+	want := Schema{Collection: "users", Fields: []Field{
+		{Name: "_id", Type: ObjectID, Required: true},
+		{Name: "address", Type: Object},
+		{Name: "age", Type: Any, Required: true},
+		{Name: "joined", Type: Timestamp},
+		{Name: "name", Type: String, Required: true, Nullable: true},
+		{Name: "nickname", Type: Any, Nullable: true},
+		{Name: "score", Type: Float, Required: true, Nullable: true},
+		{Name: "tags", Type: Array},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Infer() =\n%+v\nwant\n%+v", got, want)
+	}
+
+	// The inferred schema accepts the documents it was inferred from
+	for i, document := range documents {
+		if violations := got.Check(document, false); len(violations) > 0 {
+			t.Errorf("document %d violates the inferred schema: %v", i, violations)
+		}
+	}
+
+	// Nested documents are described down to the given depth
+	nested := []map[string]interface{}{
+		{"address": bson.M{"city": "Oslo", "geo": bson.D{{Key: "lat", Value: 59.9}}}},
+		{"address": map[string]interface{}{"city": "Bergen", "zip": nil}},
+		{},
+	}
+	got = Infer("users", nested, 1)
+	want = Schema{Collection: "users", Fields: []Field{
+		{Name: "address", Type: Object},
+		{Name: "address.city", Type: String},
+		{Name: "address.geo", Type: Object},
+		{Name: "address.zip", Type: Any, Nullable: true},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Infer() with depth 1 =\n%+v\nwant\n%+v", got, want)
+	}
+	if got := Infer("users", nested, 2); len(got.Fields) != 5 || got.Fields[3].Name != "address.geo.lat" || got.Fields[3].Type != Float {
+		t.Errorf("expected depth 2 to describe address.geo.lat, got %+v", got.Fields)
+	}
+
+	if empty := Infer("users", nil, 0); len(empty.Fields) != 0 {
+		t.Errorf("expected no fields without documents, got %+v", empty.Fields)
+	}
+}
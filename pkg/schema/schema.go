@@ -0,0 +1,367 @@
+// Package schema declares the expected shape of events per collection, so
+// events can be validated as they are read and sinks can map field types to
+// destination types from one place.
+package schema
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FieldType is the type of a field's values
+type FieldType string
+
+const (
+	String    FieldType = "string"
+	Int       FieldType = "int"
+	Float     FieldType = "float" // also accepts integers and decimals
+	Bool      FieldType = "bool"
+	Timestamp FieldType = "timestamp"
+	ObjectID  FieldType = "objectid"
+	Object    FieldType = "object"
+	Array     FieldType = "array"
+	Any       FieldType = "any"
+)
+
+// Field declares a field of an event's data
+type Field struct {
+	Name     string    `json:"name"` // Dot-separated for nested fields, e.g. "address.city"
+	Type     FieldType `json:"type"` // Default: any
+	Required bool      `json:"required"`
+	Nullable bool      `json:"nullable"` // Null values are allowed
+}
+
+// Schema declares the events of a collection. A collection can have a schema
+// per version, each document being checked against the version it names in
+// its version field.
+type Schema struct {
+	Collection   string  `json:"collection"`
+	Version      int     `json:"version"`       // 0 for a collection with a single, unversioned schema
+	VersionField string  `json:"version_field"` // Field naming a document's version; documents without it have the oldest version
+	Fields       []Field `json:"fields"`
+	Strict       bool    `json:"strict"` // Fields that are not declared are violations
+}
+
+// Policy determines what happens to an event that violates its schema
+type Policy string
+
+const (
+	// PolicyWarn logs each kind of violation once and passes the event on
+	PolicyWarn Policy = "warn"
+	// PolicyDLQ rejects the event, sending it to the dead-letter queue
+	PolicyDLQ Policy = "dlq"
+)
+
+// ParsePolicy parses a schema policy from configuration
+func ParsePolicy(name string) (Policy, error) {
+	switch Policy(name) {
+	case "":
+		return PolicyWarn, nil
+	case PolicyWarn, PolicyDLQ:
+		return Policy(name), nil
+	default:
+		return "", fmt.Errorf("unsupported schema policy: %s", name)
+	}
+}
+
+// Violation is a way an event does not match its schema
+type Violation struct {
+	Field   string
+	Problem string
+}
+
+// String describes the violation
+func (v Violation) String() string {
+	return fmt.Sprintf("field %s %s", v.Field, v.Problem)
+}
+
+// Registry holds the schemas of a pipeline's collections and validates events
+// against them
+type Registry struct {
+	schemas map[string][]*Schema // per collection, oldest version first
+	policy  Policy
+	logger  *log.Logger
+	warned  sync.Map // collection and violation already logged under the warn policy
+}
+
+// NewRegistry creates a registry of schemas, one per collection
+func NewRegistry(schemas []Schema, policy Policy, logger *log.Logger) (*Registry, error) {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if _, err := ParsePolicy(string(policy)); err != nil {
+		return nil, err
+	}
+	r := &Registry{schemas: make(map[string][]*Schema, len(schemas)), policy: policy, logger: logger}
+	for i := range schemas {
+		s := schemas[i]
+		if s.Collection == "" {
+			return nil, fmt.Errorf("schema %d has no collection", i+1)
+		}
+		if s.Version < 0 || (s.Version > 0 && s.VersionField == "") {
+			return nil, fmt.Errorf("schema version %d for %s requires a positive version and a version field", s.Version, s.Collection)
+		}
+		for _, other := range r.schemas[s.Collection] {
+			switch {
+			case other.Version == s.Version:
+				return nil, fmt.Errorf("duplicate schema for collection %s", s.Collection)
+			case other.Version == 0 || s.Version == 0:
+				return nil, fmt.Errorf("collection %s has both versioned and unversioned schemas", s.Collection)
+			case other.VersionField != s.VersionField:
+				return nil, fmt.Errorf("schemas for %s name different version fields", s.Collection)
+			}
+		}
+		seen := make(map[string]bool, len(s.Fields))
+		for j, field := range s.Fields {
+			if field.Name == "" || strings.HasPrefix(field.Name, ".") || strings.HasSuffix(field.Name, ".") {
+				return nil, fmt.Errorf("invalid field name %q in schema for %s", field.Name, s.Collection)
+			}
+			if seen[field.Name] {
+				return nil, fmt.Errorf("duplicate field %s in schema for %s", field.Name, s.Collection)
+			}
+			seen[field.Name] = true
+			switch field.Type {
+			case "":
+				s.Fields[j].Type = Any
+			case String, Int, Float, Bool, Timestamp, ObjectID, Object, Array, Any:
+			default:
+				return nil, fmt.Errorf("unsupported type %s of field %s in schema for %s", field.Type, field.Name, s.Collection)
+			}
+		}
+		r.schemas[s.Collection] = append(r.schemas[s.Collection], &s)
+	}
+	for _, versions := range r.schemas {
+		sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	}
+	return r, nil
+}
+
+// Lookup returns the schema of a collection, its latest version if it has several
+func (r *Registry) Lookup(collection string) (*Schema, bool) {
+	versions, ok := r.schemas[collection]
+	if !ok {
+		return nil, false
+	}
+	return versions[len(versions)-1], true
+}
+
+// schemaFor returns the schema a document is checked against
+func (r *Registry) schemaFor(versions []*Schema, data map[string]interface{}) (*Schema, *Violation) {
+	oldest := versions[0]
+	if oldest.Version == 0 {
+		return oldest, nil
+	}
+	value, ok := data[oldest.VersionField]
+	if !ok || isNull(value) {
+		return oldest, nil
+	}
+	version, ok := versionOf(value)
+	if ok {
+		for _, s := range versions {
+			if s.Version == version {
+				return s, nil
+			}
+		}
+	}
+	return nil, &Violation{Field: oldest.VersionField, Problem: fmt.Sprintf("names version %v, which has no schema", value)}
+}
+
+// versionOf reads a version number
+func versionOf(value interface{}) (int, bool) {
+	switch n := value.(type) {
+	case int:
+		return n, true
+	case int32:
+		return int(n), true
+	case int64:
+		return int(n), true
+	case float64:
+		return int(n), n == float64(int(n))
+	case string:
+		parsed, err := strconv.Atoi(n)
+		return parsed, err == nil
+	}
+	return 0, false
+}
+
+// Validate checks an event against its collection's schema. Under the dlq
+// policy a violating event fails; under the warn policy each kind of
+// violation is logged once per collection and the event passes. Events of
+// collections without a schema always pass.
+func (r *Registry) Validate(event pipeline.Event) (pipeline.Event, error) {
+	versions, ok := r.schemas[event.Collection]
+	if !ok {
+		return event, nil
+	}
+	var violations []Violation
+	s, unknown := r.schemaFor(versions, event.Data)
+	if unknown != nil {
+		s = versions[0]
+		violations = []Violation{*unknown}
+	} else {
+		violations = s.Check(event.Data, event.Operation == "delete")
+	}
+	if len(violations) == 0 {
+		return event, nil
+	}
+	if r.policy == PolicyDLQ {
+		descriptions := make([]string, len(violations))
+		for i, v := range violations {
+			descriptions[i] = v.String()
+		}
+		return event, fmt.Errorf("event %s violates the schema for %s: %s", event.ID, s.Collection, strings.Join(descriptions, "; "))
+	}
+	for _, v := range violations {
+		if _, logged := r.warned.LoadOrStore(s.Collection+"\x00"+v.String(), true); !logged {
+			r.logger.Printf("Event %s violates the schema for %s: %s (further events with this violation are not logged)", event.ID, s.Collection, v)
+		}
+	}
+	return event, nil
+}
+
+// Check returns the ways a document does not match the schema. Deletes carry
+// only the document key, so required fields are not enforced on them.
+func (s *Schema) Check(data map[string]interface{}, delete bool) []Violation {
+	var violations []Violation
+	for _, field := range s.Fields {
+		value, exists := lookup(data, field.Name)
+		if !exists {
+			if field.Required && !delete {
+				violations = append(violations, Violation{Field: field.Name, Problem: "is required"})
+			}
+			continue
+		}
+		if isNull(value) {
+			if !field.Nullable {
+				violations = append(violations, Violation{Field: field.Name, Problem: "is null"})
+			}
+			continue
+		}
+		if !field.Type.accepts(value) {
+			violations = append(violations, Violation{Field: field.Name, Problem: fmt.Sprintf("is %s, expected %s", typeName(value), field.Type)})
+		}
+	}
+	if s.Strict {
+		declared := make(map[string]bool, len(s.Fields))
+		for _, field := range s.Fields {
+			declared[strings.SplitN(field.Name, ".", 2)[0]] = true
+		}
+		var undeclared []string
+		for name := range data {
+			if !declared[name] {
+				undeclared = append(undeclared, name)
+			}
+		}
+		sort.Strings(undeclared)
+		for _, name := range undeclared {
+			violations = append(violations, Violation{Field: name, Problem: "is not declared"})
+		}
+	}
+	return violations
+}
+
+// lookup finds a dot-separated field in nested documents
+func lookup(data map[string]interface{}, name string) (interface{}, bool) {
+	var current interface{} = data
+	for _, part := range strings.Split(name, ".") {
+		switch doc := current.(type) {
+		case map[string]interface{}:
+			value, ok := doc[part]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case bson.M:
+			value, ok := doc[part]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case bson.D:
+			found := false
+			for _, element := range doc {
+				if element.Key == part {
+					current, found = element.Value, true
+					break
+				}
+			}
+			if !found {
+				return nil, false
+			}
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// isNull reports whether a value is null
+func isNull(value interface{}) bool {
+	switch value.(type) {
+	case nil, primitive.Null, primitive.Undefined:
+		return true
+	}
+	return false
+}
+
+// accepts reports whether a value has the field type
+func (t FieldType) accepts(value interface{}) bool {
+	switch t {
+	case Any, "":
+		return true
+	case String:
+		_, ok := value.(string)
+		return ok
+	case Int:
+		switch value.(type) {
+		case int, int32, int64:
+			return true
+		}
+	case Float:
+		switch value.(type) {
+		case float32, float64, int, int32, int64, primitive.Decimal128:
+			return true
+		}
+	case Bool:
+		_, ok := value.(bool)
+		return ok
+	case Timestamp:
+		switch value.(type) {
+		case time.Time, primitive.DateTime, primitive.Timestamp:
+			return true
+		}
+	case ObjectID:
+		_, ok := value.(primitive.ObjectID)
+		return ok
+	case Object:
+		switch value.(type) {
+		case map[string]interface{}, bson.M, bson.D:
+			return true
+		}
+	case Array:
+		switch value.(type) {
+		case []interface{}, bson.A:
+			return true
+		}
+	}
+	return false
+}
+
+// typeName describes a value's type in the terms of field types
+func typeName(value interface{}) string {
+	for _, t := range []FieldType{String, Int, Float, Bool, Timestamp, ObjectID, Object, Array} {
+		if t.accepts(value) {
+			return string(t)
+		}
+	}
+	return fmt.Sprintf("%T", value)
+}
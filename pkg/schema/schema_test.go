@@ -0,0 +1,163 @@
+package schema
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// users is the schema used by the tests
+var users = Schema{
+	Collection: "users",
+	Fields: []Field{
+		{Name: "_id", Type: ObjectID, Required: true},
+		{Name: "name", Type: String, Required: true},
+		{Name: "age", Type: Int},
+		{Name: "score", Type: Float, Nullable: true},
+		{Name: "address.city", Type: String},
+		{Name: "joined", Type: Timestamp},
+	},
+}
+
+// TestNewRegistry tests schema validation
+func TestNewRegistry(t *testing.T) {
+	tests := []struct {
+		name    string
+		schemas []Schema
+		policy  Policy
+	}{
+		{name: "missing collection", schemas: []Schema{{}}, policy: PolicyWarn},
+		{name: "duplicate collection", schemas: []Schema{{Collection: "a"}, {Collection: "a"}}, policy: PolicyWarn},
+		{name: "duplicate field", schemas: []Schema{{Collection: "a", Fields: []Field{{Name: "x"}, {Name: "x"}}}}, policy: PolicyWarn},
+		{name: "invalid field name", schemas: []Schema{{Collection: "a", Fields: []Field{{Name: "x."}}}}, policy: PolicyWarn},
+		{name: "unsupported type", schemas: []Schema{{Collection: "a", Fields: []Field{{Name: "x", Type: "decimal"}}}}, policy: PolicyWarn},
+		{name: "unsupported policy", schemas: []Schema{{Collection: "a"}}, policy: "drop"},
+		{name: "version without field", schemas: []Schema{{Collection: "a", Version: 1}}, policy: PolicyWarn},
+		{name: "duplicate version", schemas: []Schema{{Collection: "a", Version: 1, VersionField: "v"}, {Collection: "a", Version: 1, VersionField: "v"}}, policy: PolicyWarn},
+		{name: "versioned and unversioned", schemas: []Schema{{Collection: "a", Version: 1, VersionField: "v"}, {Collection: "a"}}, policy: PolicyWarn},
+		{name: "different version fields", schemas: []Schema{{Collection: "a", Version: 1, VersionField: "v"}, {Collection: "a", Version: 2, VersionField: "w"}}, policy: PolicyWarn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewRegistry(tt.schemas, tt.policy, nil); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+
+	r, err := NewRegistry([]Schema{{Collection: "a", Fields: []Field{{Name: "x"}}}}, PolicyWarn, nil)
+	if err != nil {
+		t.Fatalf("NewRegistry() error = %v", err)
+	}
+	if s, ok := r.Lookup("a"); !ok || s.Fields[0].Type != Any {
+		t.Errorf("expected an untyped field to accept any value, got %+v", s)
+	}
+}
+
+// TestSchemaCheck tests the violations found in documents
+func TestSchemaCheck(t *testing.T) {
+	id := primitive.NewObjectID()
+	tests := []struct {
+		name   string
+		data   map[string]interface{}
+		delete bool
+		want   []string
+	}{
+		{
+			name: "valid",
+			data: map[string]interface{}{
+				"_id": id, "name": "Ann", "age": int32(30), "score": nil,
+				"address": bson.M{"city": "Oslo"}, "joined": primitive.NewDateTimeFromTime(time.Now()),
+			},
+		},
+		{name: "integer is a float", data: map[string]interface{}{"_id": id, "name": "Ann", "score": int64(3)}},
+		{name: "missing required", data: map[string]interface{}{"_id": id}, want: []string{"field name is required"}},
+		{name: "delete without required", data: map[string]interface{}{"_id": id}, delete: true},
+		{
+			name: "wrong types and null",
+			data: map[string]interface{}{"_id": id.Hex(), "name": nil, "age": 30.5, "address": map[string]interface{}{"city": 7}},
+			want: []string{"field _id is string, expected objectid", "field name is null", "field age is float, expected int", "field address.city is int, expected string"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range users.Check(tt.data, tt.delete) {
+				got = append(got, v.String())
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("Check() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	strict := Schema{Collection: "users", Fields: []Field{{Name: "_id"}, {Name: "address.city"}}, Strict: true}
+	violations := strict.Check(map[string]interface{}{"_id": 1, "address": bson.D{{Key: "city", Value: "Oslo"}}, "extra": true}, false)
+	if len(violations) != 1 || violations[0].String() != "field extra is not declared" {
+		t.Errorf("expected only the undeclared field, got %v", violations)
+	}
+}
+
+// TestRegistryValidate tests the warn and dlq policies
+func TestRegistryValidate(t *testing.T) {
+	invalid := pipeline.Event{ID: "1", Collection: "users", Operation: "insert", Data: map[string]interface{}{"_id": primitive.NewObjectID()}}
+	other := pipeline.Event{ID: "2", Collection: "orders", Operation: "insert", Data: map[string]interface{}{}}
+
+	r, _ := NewRegistry([]Schema{users}, PolicyDLQ, nil)
+	if _, err := r.Validate(invalid); err == nil || !strings.Contains(err.Error(), "field name is required") {
+		t.Errorf("expected the invalid event to be rejected, got %v", err)
+	}
+	if _, err := r.Validate(other); err != nil {
+		t.Errorf("expected events of collections without a schema to pass, got %v", err)
+	}
+
+	var logs bytes.Buffer
+	r, _ = NewRegistry([]Schema{users}, PolicyWarn, log.New(&logs, "", 0))
+	for i := 0; i < 3; i++ {
+		if _, err := r.Validate(invalid); err != nil {
+			t.Fatalf("Validate() error = %v", err)
+		}
+	}
+	if n := strings.Count(logs.String(), "violates the schema"); n != 1 {
+		t.Errorf("expected the violation to be logged once, got %d times:\n%s", n, logs.String())
+	}
+}
+
+// TestRegistryVersions tests that documents are checked against the schema of their version
+func TestRegistryVersions(t *testing.T) {
+	v1 := Schema{Collection: "users", Version: 1, VersionField: "schema_version", Fields: []Field{{Name: "fullname", Type: String, Required: true}}}
+	v2 := Schema{Collection: "users", Version: 2, VersionField: "schema_version", Fields: []Field{{Name: "name", Type: String, Required: true}}}
+	r, err := NewRegistry([]Schema{v2, v1}, PolicyDLQ, nil)
+	if err != nil {
+		t.Fatalf("NewRegistry() error = %v", err)
+	}
+	if s, _ := r.Lookup("users"); s.Version != 2 {
+		t.Errorf("expected Lookup to return the latest version, got %d", s.Version)
+	}
+
+	tests := []struct {
+		name    string
+		data    map[string]interface{}
+		wantErr bool
+	}{
+		{name: "without version", data: map[string]interface{}{"fullname": "Ann"}},
+		{name: "version 1", data: map[string]interface{}{"schema_version": int32(1), "fullname": "Ann"}},
+		{name: "version 2", data: map[string]interface{}{"schema_version": 2.0, "name": "Ann"}},
+		{name: "version 2 with version 1 fields", data: map[string]interface{}{"schema_version": 2, "fullname": "Ann"}, wantErr: true},
+		{name: "unknown version", data: map[string]interface{}{"schema_version": "3", "name": "Ann"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := pipeline.Event{ID: "1", Collection: "users", Operation: "insert", Data: tt.data}
+			if _, err := r.Validate(event); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package sink
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ColumnPolicyMode determines what happens to columns a column policy does not allow
+type ColumnPolicyMode string
+
+const (
+	// ColumnsDrop writes the event without the disallowed columns (default)
+	ColumnsDrop ColumnPolicyMode = "drop"
+	// ColumnsReject fails the event, so it is isolated and dead-lettered like any bad event
+	ColumnsReject ColumnPolicyMode = "reject"
+)
+
+// ParseColumnPolicyMode parses a column policy mode from configuration
+func ParseColumnPolicyMode(name string) (ColumnPolicyMode, error) {
+	switch ColumnPolicyMode(name) {
+	case "":
+		return ColumnsDrop, nil
+	case ColumnsDrop, ColumnsReject:
+		return ColumnPolicyMode(name), nil
+	default:
+		return "", fmt.Errorf("unsupported column policy: %s", name)
+	}
+}
+
+// ColumnPolicy restricts the columns a sink writes, independently of the
+// transformer, so a mistake in the mapping cannot leak columns such as PII
+// into the destination. _id and the sink's key columns are always allowed.
+type ColumnPolicy struct {
+	Allowed []string         // Only these columns are written; empty allows every column not denied
+	Denied  []string         // These columns are never written
+	Mode    ColumnPolicyMode // What happens to other columns (default: drop)
+}
+
+// columnFilter enforces a column policy
+type columnFilter struct {
+	allowed map[string]bool // nil allows every column not denied
+	denied  map[string]bool
+	mode    ColumnPolicyMode
+	warned  sync.Map // columns already logged as dropped
+}
+
+// newColumnFilter builds the filter for a policy, or returns nil if the policy allows every column
+func newColumnFilter(policy ColumnPolicy) (*columnFilter, error) {
+	if len(policy.Allowed) == 0 && len(policy.Denied) == 0 {
+		return nil, nil
+	}
+	mode, err := ParseColumnPolicyMode(string(policy.Mode))
+	if err != nil {
+		return nil, err
+	}
+
+	f := &columnFilter{denied: make(map[string]bool), mode: mode}
+	if len(policy.Allowed) > 0 {
+		f.allowed = map[string]bool{"_id": true}
+		for _, column := range policy.Allowed {
+			f.allowed[column] = true
+		}
+	}
+	for _, column := range policy.Denied {
+		if column == "_id" {
+			return nil, fmt.Errorf("_id cannot be denied")
+		}
+		f.denied[column] = true
+	}
+	return f, nil
+}
+
+// permits reports whether a column may be written
+func (f *columnFilter) permits(column string) bool {
+	if f.denied[column] {
+		return false
+	}
+	return f.allowed == nil || f.allowed[column]
+}
+
+// checkKeyColumns rejects a column policy that denies a key column
+func checkKeyColumns(keys []string, f *columnFilter) error {
+	if f == nil {
+		return nil
+	}
+	for _, key := range keys {
+		if f.denied[key] {
+			return fmt.Errorf("key column %s cannot be denied", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package sink
+
+import "testing"
+
+// TestParseColumnPolicyMode tests parsing column policy modes
+func TestParseColumnPolicyMode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    ColumnPolicyMode
+		wantErr bool
+	}{
+		{"", ColumnsDrop, false},
+		{"drop", ColumnsDrop, false},
+		{"reject", ColumnsReject, false},
+		{"ignore", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseColumnPolicyMode(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseColumnPolicyMode(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseColumnPolicyMode(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+// TestColumnPolicy tests which columns allow and deny lists permit
+func TestColumnPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  ColumnPolicy
+		permits map[string]bool
+	}{
+		{
+			name:    "allow list",
+			policy:  ColumnPolicy{Allowed: []string{"name", "email"}},
+			permits: map[string]bool{"_id": true, "name": true, "email": true, "ssn": false},
+		},
+		{
+			name:    "deny list",
+			policy:  ColumnPolicy{Denied: []string{"ssn"}},
+			permits: map[string]bool{"_id": true, "name": true, "ssn": false},
+		},
+		{
+			name:    "deny overrides allow",
+			policy:  ColumnPolicy{Allowed: []string{"name", "ssn"}, Denied: []string{"ssn"}},
+			permits: map[string]bool{"_id": true, "name": true, "ssn": false},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := newColumnFilter(tt.policy)
+			if err != nil {
+				t.Fatalf("newColumnFilter() error = %v", err)
+			}
+			for column, want := range tt.permits {
+				if got := filter.permits(column); got != want {
+					t.Errorf("permits(%q) = %v, want %v", column, got, want)
+				}
+			}
+		})
+	}
+
+	if filter, err := newColumnFilter(ColumnPolicy{}); filter != nil || err != nil {
+		t.Errorf("expected no filter for an empty policy, got %v, %v", filter, err)
+	}
+	if _, err := newColumnFilter(ColumnPolicy{Denied: []string{"_id"}}); err == nil {
+		t.Error("expected an error when denying _id")
+	}
+	if _, err := newColumnFilter(ColumnPolicy{Allowed: []string{"name"}, Mode: "ignore"}); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+}
@@ -0,0 +1,150 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/compress"
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// FileSinkConfig contains configuration for the file sink
+type FileSinkConfig struct {
+	Path             string         // Output file path (.gz/.zst enable compression)
+	Compression      compress.Codec // Explicit codec; empty detects from extension
+	CompressionLevel int            // Codec-specific level; 0 uses the default
+	Append           bool           // Append to an existing file instead of truncating
+	Tombstones       bool           // Write deletes as tombstones: the document key with null data
+}
+
+// FileSink implements the Sink interface by writing events as JSON lines
+type FileSink struct {
+	config   FileSinkConfig
+	file     io.WriteCloser
+	writer   *bufio.Writer
+	logger   *log.Logger
+	onCommit pipeline.CommitHandler
+}
+
+// fileCommitBatch is the maximum number of events written between flushes
+const fileCommitBatch = 100
+
+// NewFileSink creates a new file sink
+func NewFileSink(config FileSinkConfig, logger *log.Logger) *FileSink {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &FileSink{
+		config: config,
+		logger: logger,
+	}
+}
+
+// Connect opens the output file
+func (f *FileSink) Connect(ctx context.Context) error {
+	if f.config.Path == "" {
+		return fmt.Errorf("file sink requires a path")
+	}
+
+	f.logger.Printf("Opening file sink: %s", f.config.Path)
+	file, err := compress.Create(f.config.Path, f.config.Compression, f.config.CompressionLevel, f.config.Append)
+	if err != nil {
+		return fmt.Errorf("failed to open file sink: %w", err)
+	}
+
+	f.file = file
+	f.writer = bufio.NewWriter(file)
+	return nil
+}
+
+// SetCommitHandler registers a handler called after every flush.
+// The events slice is only valid for the duration of the call.
+func (f *FileSink) SetCommitHandler(handler pipeline.CommitHandler) {
+	f.onCommit = handler
+}
+
+// Write writes events to the file, one JSON document per line. The file is
+// flushed every 100 events, whenever no more events are immediately waiting
+// and when the context is cancelled, after which the events still to come
+// are reported as failed.
+func (f *FileSink) Write(ctx context.Context, events <-chan pipeline.Event) <-chan error {
+	errors := make(chan error)
+
+	go func() {
+		defer close(errors)
+
+		encoder := json.NewEncoder(f.writer)
+		pending := pipeline.GetBatch(fileCommitBatch)
+		defer func() { pipeline.PutBatch(pending) }()
+		var abandoned error // set once the context is cancelled
+		for {
+			var event pipeline.Event
+			var ok bool
+			select {
+			case event, ok = <-events:
+			case <-ctx.Done():
+				abandoned = ctx.Err()
+			}
+			if !ok {
+				break
+			}
+
+			record := event
+			if f.config.Tombstones {
+				record = tombstone(event)
+			}
+			if err := encoder.Encode(record); err != nil {
+				sendError(ctx, errors, fmt.Errorf("failed to write event: %w", err))
+			}
+			pending = append(pending, event)
+
+			if len(pending) >= fileCommitBatch || len(events) == 0 {
+				if err := f.flush(pending); err != nil {
+					sendError(ctx, errors, err)
+				}
+				pending = pending[:0]
+			}
+		}
+
+		if err := f.flush(pending); err != nil {
+			sendError(ctx, errors, err)
+		}
+		if abandoned != nil {
+			abandon(f.logger, nil, events, abandoned, f.onCommit)
+		}
+	}()
+
+	return errors
+}
+
+// flush flushes buffered output and notifies the commit handler
+func (f *FileSink) flush(events []pipeline.Event) error {
+	err := f.writer.Flush()
+	if err != nil {
+		err = fmt.Errorf("failed to flush file sink: %w", err)
+	}
+	if f.onCommit != nil && len(events) > 0 {
+		f.onCommit(events, err)
+	}
+	pipeline.ReleaseEvents(events)
+	return err
+}
+
+// Close flushes and closes the output file
+func (f *FileSink) Close() error {
+	if f.file == nil {
+		return nil
+	}
+	f.logger.Println("Closing file sink")
+	if err := f.writer.Flush(); err != nil {
+		f.file.Close()
+		return fmt.Errorf("failed to flush file sink: %w", err)
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
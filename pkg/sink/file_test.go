@@ -0,0 +1,129 @@
+package sink
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"github.com/IEatCodeDaily/data-pipe/pkg/source"
+)
+
+// TestFileSinkRoundTrip tests that events written by the file sink can be read back by the file source
+func TestFileSinkRoundTrip(t *testing.T) {
+	for _, name := range []string{"events.jsonl", "events.jsonl.gz", "events.jsonl.zst"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			ctx := context.Background()
+
+			snk := NewFileSink(FileSinkConfig{Path: path}, nil)
+			if err := snk.Connect(ctx); err != nil {
+				t.Fatalf("Connect() error = %v", err)
+			}
+
+			events := make(chan pipeline.Event, 3)
+			for _, id := range []string{"1", "2", "3"} {
+				events <- pipeline.Event{
+					ID:        id,
+					Timestamp: time.Now().UTC(),
+					Operation: "insert",
+					Data:      map[string]interface{}{"name": "doc" + id},
+				}
+			}
+			close(events)
+
+			for err := range snk.Write(ctx, events) {
+				t.Errorf("Write() error = %v", err)
+			}
+			if err := snk.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			src := source.NewFileSource(path, "", nil)
+			if err := src.Connect(ctx); err != nil {
+				t.Fatalf("source Connect() error = %v", err)
+			}
+			defer src.Close()
+
+			out, errs := src.Read(ctx)
+			go func() {
+				for err := range errs {
+					t.Errorf("Read() error = %v", err)
+				}
+			}()
+
+			var ids []string
+			for event := range out {
+				ids = append(ids, event.ID)
+			}
+			if len(ids) != 3 || ids[0] != "1" || ids[2] != "3" {
+				t.Errorf("expected events 1..3, got %v", ids)
+			}
+		})
+	}
+}
+
+// TestFileSinkDrainsOnShutdown tests that Write flushes on cancellation and
+// keeps receiving until its input closes, failing the events it did not write
+func TestFileSinkDrainsOnShutdown(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	snk := NewFileSink(FileSinkConfig{Path: path}, nil)
+	if err := snk.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer snk.Close()
+	testWriteDrainsOnShutdown(t, snk)
+}
+
+// drain returns a channel closed once errs is closed
+func drain(errs <-chan error) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		for range errs {
+		}
+		close(done)
+	}()
+	return done
+}
+
+// TestFileSinkTombstones tests that deletes are written as the document key with null data
+func TestFileSinkTombstones(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	ctx := context.Background()
+	snk := NewFileSink(FileSinkConfig{Path: path, Tombstones: true}, nil)
+	if err := snk.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	events := make(chan pipeline.Event, 3)
+	events <- pipeline.Event{ID: "1", Operation: "insert", Data: map[string]interface{}{"_id": "a", "name": "doc"}}
+	events <- pipeline.Event{ID: "2", Operation: "delete", Data: map[string]interface{}{"_id": "a"}, Key: map[string]interface{}{"_id": "a"}}
+	events <- pipeline.Event{ID: "3", Operation: "delete", Data: map[string]interface{}{"_id": "b"}}
+	close(events)
+	for err := range snk.Write(ctx, events) {
+		t.Errorf("Write() error = %v", err)
+	}
+	if err := snk.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %d", len(lines))
+	}
+	if !strings.Contains(lines[0], `"name":"doc"`) {
+		t.Errorf("expected insert to be written unchanged, got %s", lines[0])
+	}
+	for _, line := range lines[1:] {
+		if !strings.Contains(line, `"data":null`) || !strings.Contains(line, `"key":{"_id":`) {
+			t.Errorf("expected a tombstone, got %s", line)
+		}
+	}
+}
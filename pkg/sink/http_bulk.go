@@ -0,0 +1,489 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/compress"
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// defaultBulkEvents is the default number of events sent in each bulk request
+const defaultBulkEvents = 500
+
+// HTTPBulkSinkConfig contains configuration for the bulk HTTP sink
+type HTTPBulkSinkConfig struct {
+	URL              string         // Bulk endpoint, e.g. http://localhost:9200/_bulk
+	Method           string         // HTTP method (default: POST)
+	Headers          []string       // Extra request headers as "Name: value", e.g. for authentication
+	ActionTemplate   string         // Template of the action line written before each document; empty writes documents only
+	DeleteTemplate   string         // Template of the line written for a delete; empty skips deletes
+	ContentType      string         // Request content type (default: application/x-ndjson)
+	BatchEvents      int            // Events per request (default: 500)
+	FlushInterval    time.Duration  // Longest a partial batch waits before it is sent (default: 1s)
+	Compression      compress.Codec // Request body codec (default: none)
+	CompressionLevel int            // Codec-specific level; 0 uses the default
+	Timeout          time.Duration  // Request timeout (default: 1m)
+}
+
+// HTTPBulkSink implements the Sink interface by sending batches of events as
+// NDJSON to a bulk HTTP API, such as those of Elasticsearch, OpenSearch or
+// Meilisearch. Each event becomes an optional action line rendered from a
+// template, followed by its document; deletes become a single line. Events
+// are committed once their request succeeds.
+type HTTPBulkSink struct {
+	config   HTTPBulkSinkConfig
+	action   *template.Template
+	delete   *template.Template
+	headers  http.Header
+	codec    compress.Codec // current request codec, lowered when the server refuses it
+	client   *http.Client
+	clock    pipeline.Clock
+	logger   *log.Logger
+	onCommit pipeline.CommitHandler
+	onAck    pipeline.AckHandler
+	skipped  bool // a delete was skipped for lack of a delete template
+}
+
+// bulkTemplateFuncs are the functions available to action and delete templates
+var bulkTemplateFuncs = template.FuncMap{
+	// json encodes a value as JSON, e.g. {{json .Collection}}
+	"json": func(v interface{}) (string, error) {
+		encoded, err := json.Marshal(v)
+		return string(encoded), err
+	},
+	// docID returns the event's document _id, from its key or else its data
+	"docID": func(event pipeline.Event) interface{} {
+		if id, ok := event.Key["_id"]; ok {
+			return id
+		}
+		return event.Data["_id"]
+	},
+}
+
+// NewHTTPBulkSink creates a new bulk HTTP sink. Templates are executed with
+// the event, e.g. {"index":{"_index":"{{.Collection}}","_id":{{json (docID .)}}}}.
+func NewHTTPBulkSink(config HTTPBulkSinkConfig, logger *log.Logger) (*HTTPBulkSink, error) {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if config.URL == "" {
+		return nil, fmt.Errorf("bulk HTTP sink requires a url")
+	}
+	if config.Method == "" {
+		config.Method = http.MethodPost
+	}
+	if config.ContentType == "" {
+		config.ContentType = "application/x-ndjson"
+	}
+	if config.BatchEvents <= 0 {
+		config.BatchEvents = defaultBulkEvents
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	if config.Compression == "" {
+		config.Compression = compress.None
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = time.Minute
+	}
+
+	s := &HTTPBulkSink{
+		config:  config,
+		headers: make(http.Header),
+		codec:   config.Compression,
+		client:  &http.Client{Timeout: config.Timeout},
+		clock:   pipeline.SystemClock,
+		logger:  logger,
+	}
+	var err error
+	if s.action, err = parseBulkTemplate("action", config.ActionTemplate); err != nil {
+		return nil, err
+	}
+	if s.delete, err = parseBulkTemplate("delete", config.DeleteTemplate); err != nil {
+		return nil, err
+	}
+	for _, header := range config.Headers {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid header %q, expected \"Name: value\"", header)
+		}
+		s.headers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return s, nil
+}
+
+// parseBulkTemplate parses a line template, or returns nil for an empty one
+func parseBulkTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New(name).Funcs(bulkTemplateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// SetClock sets the time source used for flushing partial batches
+func (s *HTTPBulkSink) SetClock(clock pipeline.Clock) {
+	s.clock = clock
+}
+
+// SetCommitHandler registers a handler called after every bulk request.
+// The events slice is only valid for the duration of the call.
+func (s *HTTPBulkSink) SetCommitHandler(handler pipeline.CommitHandler) {
+	s.onCommit = handler
+}
+
+// SetAckHandler registers a handler called after every bulk request with the
+// outcome of each event, taken from the items of an Elasticsearch-style bulk
+// response. Items rejected with a 429 or 5xx status are retryable, others
+// permanent. Permanent failures are left to the handler and not reported as
+// write errors. The acks are only valid for the duration of the call.
+func (s *HTTPBulkSink) SetAckHandler(handler pipeline.AckHandler) {
+	s.onAck = handler
+}
+
+// Connect is a no-op; the endpoint is first contacted with the first batch
+func (s *HTTPBulkSink) Connect(ctx context.Context) error {
+	s.logger.Printf("Writing to bulk HTTP endpoint %s", s.config.URL)
+	return nil
+}
+
+// Write collects events into batches and sends each one once it holds the
+// configured number of events, once the flush interval has passed since its
+// first event, and when the input closes or the context is cancelled, after
+// which the events still to come are reported as failed
+func (s *HTTPBulkSink) Write(ctx context.Context, events <-chan pipeline.Event) <-chan error {
+	errors := make(chan error)
+
+	go func() {
+		defer close(errors)
+
+		var body bytes.Buffer
+		pending := pipeline.GetBatch(s.config.BatchEvents)
+		defer func() { pipeline.PutBatch(pending) }()
+		var items []int // indexes of the pending events written to the body, one bulk item each
+
+		send := func() {
+			if len(pending) == 0 {
+				return
+			}
+			if s.onAck != nil {
+				var response []byte
+				var err error
+				if body.Len() > 0 {
+					response, err = s.request(context.WithoutCancel(ctx), body.Bytes())
+				}
+				acks := bulkAcks(pending, items, response, err)
+				for _, ack := range acks {
+					if ack.Status == pipeline.AckRetryable {
+						sendError(ctx, errors, ack.Err)
+						break
+					}
+				}
+				s.onAck(acks)
+			} else {
+				var err error
+				if body.Len() > 0 {
+					err = s.send(context.WithoutCancel(ctx), body.Bytes())
+				}
+				if err != nil {
+					sendError(ctx, errors, err)
+				}
+				if s.onCommit != nil {
+					s.onCommit(pending, err)
+				}
+			}
+			pipeline.ReleaseEvents(pending)
+			pending = pending[:0]
+			items = items[:0]
+			body.Reset()
+		}
+
+		// flush fires once a partial batch has waited for the flush interval
+		var flush <-chan time.Time
+		var abandoned error // set once the context is cancelled
+		for {
+			var event pipeline.Event
+			ok := true
+			select {
+			case event, ok = <-events:
+			case <-ctx.Done():
+				ok, abandoned = false, ctx.Err()
+			case <-flush:
+				flush = nil
+				send()
+				continue
+			}
+			if !ok {
+				break
+			}
+
+			size := body.Len()
+			if err := s.encode(&body, event); err != nil {
+				sendError(ctx, errors, err)
+				continue
+			}
+			if body.Len() > size {
+				items = append(items, len(pending))
+			}
+			pending = append(pending, event)
+			if len(pending) == 1 {
+				flush = s.clock.After(s.config.FlushInterval)
+			}
+			if len(pending) >= s.config.BatchEvents {
+				flush = nil
+				send()
+			}
+		}
+		send()
+		if abandoned != nil {
+			abandon(s.logger, nil, events, abandoned, s.reportAbandoned)
+		}
+	}()
+
+	return errors
+}
+
+// reportAbandoned reports events given up on at shutdown as failed, to the
+// ack handler as retryable so they are replayed
+func (s *HTTPBulkSink) reportAbandoned(events []pipeline.Event, err error) {
+	if s.onAck == nil {
+		if s.onCommit != nil {
+			s.onCommit(events, err)
+		}
+		return
+	}
+	acks := make([]pipeline.Ack, len(events))
+	for i, event := range events {
+		acks[i] = pipeline.Ack{Event: event, Status: pipeline.AckRetryable, Err: err}
+	}
+	s.onAck(acks)
+}
+
+// encode appends an event's lines to the request body
+func (s *HTTPBulkSink) encode(body *bytes.Buffer, event pipeline.Event) error {
+	if event.Operation == "delete" {
+		if s.delete == nil {
+			if !s.skipped {
+				s.logger.Printf("Skipping deletes: the bulk HTTP sink has no delete template")
+				s.skipped = true
+			}
+			return nil
+		}
+		return s.renderLine(body, s.delete, event)
+	}
+
+	document, err := json.Marshal(event.Data)
+	if err != nil {
+		return fmt.Errorf("failed to encode event %s: %w", event.ID, err)
+	}
+	if s.action != nil {
+		if err := s.renderLine(body, s.action, event); err != nil {
+			return err
+		}
+	}
+	body.Write(document)
+	body.WriteByte('\n')
+	return nil
+}
+
+// renderLine appends a template's output for an event as one line
+func (s *HTTPBulkSink) renderLine(body *bytes.Buffer, tmpl *template.Template, event pipeline.Event) error {
+	var line bytes.Buffer
+	if err := tmpl.Execute(&line, event); err != nil {
+		return fmt.Errorf("failed to render %s line for event %s: %w", tmpl.Name(), event.ID, err)
+	}
+	if bytes.ContainsAny(line.Bytes(), "\r\n") {
+		return fmt.Errorf("%s line for event %s spans several lines", tmpl.Name(), event.ID)
+	}
+	body.Write(line.Bytes())
+	body.WriteByte('\n')
+	return nil
+}
+
+// send posts a batch, failing if the request or any of its items failed
+func (s *HTTPBulkSink) send(ctx context.Context, ndjson []byte) error {
+	response, err := s.request(ctx, ndjson)
+	if err != nil {
+		return err
+	}
+	return bulkItemsError(response)
+}
+
+// bulkStatusError is a bulk request answered with an unsuccessful status
+type bulkStatusError struct {
+	url     string
+	status  int
+	message string
+}
+
+func (e *bulkStatusError) Error() string {
+	return fmt.Sprintf("bulk request to %s failed: %d %s: %s", e.url, e.status, http.StatusText(e.status), e.message)
+}
+
+// request posts a batch and returns the response body. If the server refuses
+// the body's encoding and advertises the ones it accepts, the batch is sent
+// again with one of those, which is then used for later batches.
+func (s *HTTPBulkSink) request(ctx context.Context, ndjson []byte) ([]byte, error) {
+	for {
+		resp, err := s.post(ctx, ndjson, s.codec)
+		if err != nil {
+			return nil, err
+		}
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusUnsupportedMediaType && s.codec != compress.None {
+			if accepted, ok := resp.Header["Accept-Encoding"]; ok {
+				if codec := compress.Negotiate(s.codec, strings.Join(accepted, ",")); codec != s.codec {
+					s.logger.Printf("Bulk HTTP endpoint refused %s bodies, switching to %s", s.codec, codec)
+					s.codec = codec
+					continue
+				}
+			}
+		}
+		if resp.StatusCode/100 != 2 {
+			return nil, &bulkStatusError{url: s.config.URL, status: resp.StatusCode, message: strings.TrimSpace(string(msg))}
+		}
+		return msg, nil
+	}
+}
+
+// post sends an NDJSON body compressed with a codec
+func (s *HTTPBulkSink) post(ctx context.Context, ndjson []byte, codec compress.Codec) (*http.Response, error) {
+	var body bytes.Buffer
+	writer, err := compress.NewWriter(&body, codec, s.config.CompressionLevel)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(ndjson); err != nil {
+		return nil, fmt.Errorf("failed to compress bulk request: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress bulk request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, s.config.Method, s.config.URL, &body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build bulk request: %w", err)
+	}
+	for name, values := range s.headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", s.config.ContentType)
+	if encoding := compress.ContentEncoding(codec); encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send bulk request to %s: %w", s.config.URL, err)
+	}
+	return resp, nil
+}
+
+// bulkItemOutcome is the outcome of one item of a bulk response
+type bulkItemOutcome struct {
+	Status int             `json:"status"`
+	Error  json.RawMessage `json:"error"`
+}
+
+// bulkItemsError reports the failed items of an Elasticsearch-style bulk
+// response, which succeeds as a whole with "errors": true when some items fail
+func bulkItemsError(response []byte) error {
+	var result struct {
+		Errors bool                         `json:"errors"`
+		Items  []map[string]bulkItemOutcome `json:"items"`
+	}
+	if json.Unmarshal(response, &result) != nil || !result.Errors {
+		return nil
+	}
+	failed := 0
+	var first string
+	for _, item := range result.Items {
+		for action, outcome := range item {
+			if outcome.Status/100 == 2 || (action == "delete" && outcome.Status == http.StatusNotFound) {
+				continue
+			}
+			failed++
+			if first == "" {
+				first = fmt.Sprintf("%s: %d %s", action, outcome.Status, outcome.Error)
+			}
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("bulk request failed for %d of %d items, first: %s", failed, len(result.Items), first)
+}
+
+// retryableStatus reports whether a request or item rejected with a status
+// may succeed later
+func retryableStatus(status int) bool {
+	return status == http.StatusTooManyRequests || status/100 == 5
+}
+
+// bulkAcks returns the outcome of every pending event of a bulk request, of
+// which the events at the items indexes were sent as bulk items. Events sent
+// without a line, such as skipped deletes, succeed.
+func bulkAcks(pending []pipeline.Event, items []int, response []byte, err error) []pipeline.Ack {
+	acks := make([]pipeline.Ack, len(pending))
+	for i, event := range pending {
+		acks[i] = pipeline.Ack{Event: event, Status: pipeline.AckSuccess}
+	}
+	if err != nil {
+		// The request as a whole failed
+		status := pipeline.AckRetryable
+		var statusErr *bulkStatusError
+		if errors.As(err, &statusErr) && !retryableStatus(statusErr.status) {
+			status = pipeline.AckPermanent
+		}
+		for _, i := range items {
+			acks[i].Status, acks[i].Err = status, err
+		}
+		return acks
+	}
+
+	var result struct {
+		Errors bool                         `json:"errors"`
+		Items  []map[string]bulkItemOutcome `json:"items"`
+	}
+	if json.Unmarshal(response, &result) != nil || !result.Errors {
+		return acks
+	}
+	for n, item := range result.Items {
+		if n >= len(items) {
+			break
+		}
+		for action, outcome := range item {
+			if outcome.Status/100 == 2 || (action == "delete" && outcome.Status == http.StatusNotFound) {
+				continue
+			}
+			ack := &acks[items[n]]
+			ack.Status = pipeline.AckPermanent
+			if retryableStatus(outcome.Status) {
+				ack.Status = pipeline.AckRetryable
+			}
+			ack.Err = fmt.Errorf("bulk %s of event %s failed: %d %s", action, ack.Event.ID, outcome.Status, outcome.Error)
+		}
+	}
+	return acks
+}
+
+// Close is a no-op; every batch is sent by Write
+func (s *HTTPBulkSink) Close() error {
+	return nil
+}
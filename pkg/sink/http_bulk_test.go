@@ -0,0 +1,296 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/compress"
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// Elasticsearch bulk action and delete lines
+const (
+	elasticAction = `{"index":{"_index":"{{.Collection}}","_id":{{json (docID .)}}}}`
+	elasticDelete = `{"delete":{"_index":"{{.Collection}}","_id":{{json (docID .)}}}}`
+)
+
+// TestNewHTTPBulkSinkValidation tests configuration validation
+func TestNewHTTPBulkSinkValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		config HTTPBulkSinkConfig
+	}{
+		{name: "missing url", config: HTTPBulkSinkConfig{}},
+		{name: "invalid action template", config: HTTPBulkSinkConfig{URL: "http://localhost", ActionTemplate: "{{.ID"}},
+		{name: "invalid header", config: HTTPBulkSinkConfig{URL: "http://localhost", Headers: []string{"Authorization"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewHTTPBulkSink(tt.config, nil); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+// TestHTTPBulkSinkEncode tests the NDJSON lines written for each operation
+func TestHTTPBulkSinkEncode(t *testing.T) {
+	s, err := NewHTTPBulkSink(HTTPBulkSinkConfig{URL: "http://localhost", ActionTemplate: elasticAction, DeleteTemplate: elasticDelete}, nil)
+	if err != nil {
+		t.Fatalf("NewHTTPBulkSink() error = %v", err)
+	}
+	var body bytes.Buffer
+	for _, event := range []pipeline.Event{
+		{ID: "e1", Operation: "insert", Collection: "users", Data: map[string]interface{}{"_id": "u1", "name": "Ann"}},
+		{ID: "e2", Operation: "delete", Collection: "users", Key: map[string]interface{}{"_id": "u2"}},
+	} {
+		if err := s.encode(&body, event); err != nil {
+			t.Fatalf("encode() error = %v", err)
+		}
+	}
+	want := `{"index":{"_index":"users","_id":"u1"}}` + "\n" +
+		`{"_id":"u1","name":"Ann"}` + "\n" +
+		`{"delete":{"_index":"users","_id":"u2"}}` + "\n"
+	if got := body.String(); got != want {
+		t.Errorf("encode() = %q, want %q", got, want)
+	}
+
+	// Without a delete template deletes are skipped, and documents stand alone
+	s, _ = NewHTTPBulkSink(HTTPBulkSinkConfig{URL: "http://localhost"}, nil)
+	body.Reset()
+	s.encode(&body, pipeline.Event{Operation: "delete", Key: map[string]interface{}{"_id": "u2"}})
+	s.encode(&body, pipeline.Event{Operation: "update", Data: map[string]interface{}{"_id": "u1"}})
+	if got := body.String(); got != `{"_id":"u1"}`+"\n" {
+		t.Errorf("encode() without templates = %q", got)
+	}
+}
+
+// TestHTTPBulkSinkWrite tests that batches are sent with headers and committed
+func TestHTTPBulkSinkWrite(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "ApiKey secret" || r.Header.Get("Content-Type") != "application/x-ndjson" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, string(body))
+		w.Write([]byte(`{"errors":false,"items":[]}`))
+	}))
+	defer server.Close()
+
+	snk, err := NewHTTPBulkSink(HTTPBulkSinkConfig{
+		URL:            server.URL,
+		Headers:        []string{"Authorization: ApiKey secret"},
+		ActionTemplate: elasticAction,
+		BatchEvents:    2,
+	}, nil)
+	if err != nil {
+		t.Fatalf("NewHTTPBulkSink() error = %v", err)
+	}
+	var committed int
+	snk.SetCommitHandler(func(events []pipeline.Event, err error) {
+		if err != nil {
+			t.Errorf("commit error = %v", err)
+		}
+		committed += len(events)
+	})
+
+	events := make(chan pipeline.Event, 3)
+	for _, id := range []string{"1", "2", "3"} {
+		events <- pipeline.Event{ID: id, Operation: "insert", Collection: "users", Data: map[string]interface{}{"_id": id}}
+	}
+	close(events)
+	for err := range snk.Write(context.Background(), events) {
+		t.Errorf("Write() error = %v", err)
+	}
+
+	if committed != 3 || len(requests) != 2 {
+		t.Fatalf("expected 3 events committed in 2 requests, got %d in %d", committed, len(requests))
+	}
+	if lines := strings.Count(requests[0], "\n"); lines != 4 {
+		t.Errorf("expected 4 lines in the first request, got %d: %q", lines, requests[0])
+	}
+}
+
+// TestHTTPBulkSinkFlushInterval tests that a partial batch is sent once the flush interval passes
+func TestHTTPBulkSinkFlushInterval(t *testing.T) {
+	sent := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent <- struct{}{}
+	}))
+	defer server.Close()
+
+	snk, _ := NewHTTPBulkSink(HTTPBulkSinkConfig{URL: server.URL, BatchEvents: 10, FlushInterval: time.Second}, nil)
+	clock := pipeline.NewManualClock(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	snk.SetClock(clock)
+
+	events := make(chan pipeline.Event)
+	errs := snk.Write(context.Background(), events)
+	events <- pipeline.Event{ID: "1", Operation: "insert", Data: map[string]interface{}{"_id": "1"}}
+
+	for clock.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	select {
+	case <-sent:
+		t.Fatal("expected the partial batch to wait for the flush interval")
+	default:
+	}
+	clock.Advance(time.Second)
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the partial batch to be sent after the flush interval")
+	}
+	close(events)
+	for err := range errs {
+		t.Errorf("Write() error = %v", err)
+	}
+}
+
+// TestHTTPBulkSinkDrainsOnShutdown tests that Write sends what it holds on
+// cancellation and keeps receiving until its input closes, failing the rest
+func TestHTTPBulkSinkDrainsOnShutdown(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"errors":false,"items":[]}`))
+	}))
+	defer server.Close()
+
+	snk, _ := NewHTTPBulkSink(HTTPBulkSinkConfig{URL: server.URL, BatchEvents: 100, FlushInterval: time.Hour}, nil)
+	testWriteDrainsOnShutdown(t, snk)
+
+	// With an ack handler the abandoned events are retryable, as are those
+	// sent to the endpoint, which is gone
+	server.Close()
+	var retryable int
+	snk.SetAckHandler(func(acks []pipeline.Ack) {
+		for _, ack := range acks {
+			if ack.Status == pipeline.AckRetryable {
+				retryable++
+			}
+		}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	events := make(chan pipeline.Event, 1)
+	errs := snk.Write(ctx, events)
+	events <- pipeline.Event{ID: "1", Operation: "insert", Data: map[string]interface{}{"_id": "1"}}
+	close(events)
+	<-drain(errs)
+	if retryable != 1 {
+		t.Errorf("expected the abandoned event to be retryable, got %d", retryable)
+	}
+}
+
+// TestHTTPBulkSinkNegotiatesCompression tests falling back to an encoding the server accepts
+func TestHTTPBulkSinkNegotiatesCompression(t *testing.T) {
+	var encodings []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			w.Header().Set("Accept-Encoding", "gzip")
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		reader, err := compress.NewReader(r.Body, compress.Gzip)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if body, _ := io.ReadAll(reader); string(body) != `{"_id":"1"}`+"\n" {
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	snk, _ := NewHTTPBulkSink(HTTPBulkSinkConfig{URL: server.URL, Compression: compress.Zstd}, nil)
+	if err := snk.send(context.Background(), []byte(`{"_id":"1"}`+"\n")); err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+	if strings.Join(encodings, ",") != "zstd,gzip" || snk.codec != compress.Gzip {
+		t.Errorf("expected a retry with gzip, got %v and codec %s", encodings, snk.codec)
+	}
+}
+
+// TestBulkItemsError tests detection of failed items in bulk responses
+func TestBulkItemsError(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		wantErr  bool
+	}{
+		{name: "no errors", response: `{"errors":false,"items":[{"index":{"status":201}}]}`},
+		{name: "not json", response: `ok`},
+		{name: "failed item", response: `{"errors":true,"items":[{"index":{"status":201}},{"index":{"status":400,"error":{"type":"mapper_parsing_exception"}}}]}`, wantErr: true},
+		{name: "missing delete", response: `{"errors":true,"items":[{"delete":{"status":404}}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := bulkItemsError([]byte(tt.response))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("bulkItemsError() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestBulkAcks tests that bulk responses are turned into an outcome per event
+func TestBulkAcks(t *testing.T) {
+	pending := []pipeline.Event{{ID: "1"}, {ID: "skipped delete"}, {ID: "2"}, {ID: "3"}}
+	items := []int{0, 2, 3}
+	tests := []struct {
+		name     string
+		response string
+		err      error
+		want     []pipeline.AckStatus
+	}{
+		{
+			name:     "all written",
+			response: `{"errors":false,"items":[{"index":{"status":201}},{"index":{"status":200}},{"delete":{"status":200}}]}`,
+			want:     []pipeline.AckStatus{pipeline.AckSuccess, pipeline.AckSuccess, pipeline.AckSuccess, pipeline.AckSuccess},
+		},
+		{
+			name:     "failed items",
+			response: `{"errors":true,"items":[{"index":{"status":400,"error":{"type":"mapper_parsing_exception"}}},{"index":{"status":429}},{"delete":{"status":404}}]}`,
+			want:     []pipeline.AckStatus{pipeline.AckPermanent, pipeline.AckSuccess, pipeline.AckRetryable, pipeline.AckSuccess},
+		},
+		{
+			name: "request rejected",
+			err:  &bulkStatusError{url: "http://es", status: http.StatusRequestEntityTooLarge},
+			want: []pipeline.AckStatus{pipeline.AckPermanent, pipeline.AckSuccess, pipeline.AckPermanent, pipeline.AckPermanent},
+		},
+		{
+			name: "server unavailable",
+			err:  &bulkStatusError{url: "http://es", status: http.StatusServiceUnavailable},
+			want: []pipeline.AckStatus{pipeline.AckRetryable, pipeline.AckSuccess, pipeline.AckRetryable, pipeline.AckRetryable},
+		},
+		{
+			name: "connection failed",
+			err:  io.ErrUnexpectedEOF,
+			want: []pipeline.AckStatus{pipeline.AckRetryable, pipeline.AckSuccess, pipeline.AckRetryable, pipeline.AckRetryable},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			acks := bulkAcks(pending, items, []byte(tt.response), tt.err)
+			if len(acks) != len(tt.want) {
+				t.Fatalf("expected %d acks, got %d", len(tt.want), len(acks))
+			}
+			for i, ack := range acks {
+				if ack.Event.ID != pending[i].ID || ack.Status != tt.want[i] {
+					t.Errorf("ack %d = %s %s, want %s %s", i, ack.Event.ID, ack.Status, pending[i].ID, tt.want[i])
+				}
+				if (ack.Status == pipeline.AckSuccess) != (ack.Err == nil) {
+					t.Errorf("ack %d has status %s and error %v", i, ack.Status, ack.Err)
+				}
+			}
+		})
+	}
+}
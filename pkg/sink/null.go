@@ -0,0 +1,67 @@
+package sink
+
+import (
+	"context"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// NullSink implements the Sink interface by discarding events. It counts
+// what it receives and reports throughput on close, for benchmarking.
+type NullSink struct {
+	count   atomic.Int64
+	started time.Time
+	logger  *log.Logger
+}
+
+// NewNullSink creates a new null sink
+func NewNullSink(logger *log.Logger) *NullSink {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &NullSink{logger: logger}
+}
+
+// Connect starts the throughput timer
+func (n *NullSink) Connect(ctx context.Context) error {
+	n.started = time.Now()
+	return nil
+}
+
+// SupportsConcurrentWrites marks the sink as safe for concurrent Write calls
+func (n *NullSink) SupportsConcurrentWrites() {}
+
+// Write discards events
+func (n *NullSink) Write(ctx context.Context, events <-chan pipeline.Event) <-chan error {
+	errors := make(chan error)
+
+	go func() {
+		defer close(errors)
+		for event := range events {
+			n.count.Add(1)
+			if pipeline.PoolingEnabled() {
+				pipeline.ReleaseData(event.Data)
+			}
+		}
+	}()
+
+	return errors
+}
+
+// Count returns the number of events received
+func (n *NullSink) Count() int64 {
+	return n.count.Load()
+}
+
+// Close logs the number of events discarded and the average rate
+func (n *NullSink) Close() error {
+	elapsed := time.Since(n.started).Seconds()
+	count := n.Count()
+	if elapsed > 0 {
+		n.logger.Printf("Null sink discarded %d events in %.1fs (%.0f events/s)", count, elapsed, float64(count)/elapsed)
+	}
+	return nil
+}
@@ -3,25 +3,110 @@ package sink
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
 	"log"
-	"regexp"
+	"net"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/IEatCodeDaily/data-pipe/internal/pgconn"
 	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 // Valid table name pattern (alphanumeric, underscore, max 63 chars for PostgreSQL)
-var validTableName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]{0,62}$`)
+var validTableName = pgconn.ValidTableName
+
+// ErrorIsolation determines how a failed batch is broken down to find bad events
+type ErrorIsolation string
+
+const (
+	// IsolationNone fails the whole batch (default)
+	IsolationNone ErrorIsolation = "none"
+	// IsolationBisect recursively splits a failed batch in half to find bad events
+	IsolationBisect ErrorIsolation = "bisect"
+	// IsolationIndividual retries every event of a failed batch on its own
+	IsolationIndividual ErrorIsolation = "individual"
+)
+
+// ParseErrorIsolation parses an error isolation mode from configuration
+func ParseErrorIsolation(name string) (ErrorIsolation, error) {
+	switch ErrorIsolation(name) {
+	case "":
+		return IsolationNone, nil
+	case IsolationNone, IsolationBisect, IsolationIndividual:
+		return ErrorIsolation(name), nil
+	default:
+		return "", fmt.Errorf("unsupported error isolation mode: %s", name)
+	}
+}
+
+// WriteMode determines how events change the destination table
+type WriteMode string
+
+const (
+	// WriteUpsert keeps one row per key, overwritten by every change (default)
+	WriteUpsert WriteMode = "upsert"
+	// WriteHistory keeps every version of a row as a slowly changing dimension
+	// (type 2): a change closes the current version and inserts a new one
+	WriteHistory WriteMode = "history"
+	// WriteAppend appends every event as a new row of an event log
+	WriteAppend WriteMode = "append"
+)
+
+// ParseWriteMode parses a write mode from configuration
+func ParseWriteMode(name string) (WriteMode, error) {
+	switch WriteMode(name) {
+	case "":
+		return WriteUpsert, nil
+	case WriteUpsert, WriteHistory, WriteAppend:
+		return WriteMode(name), nil
+	default:
+		return "", fmt.Errorf("unsupported write mode: %s", name)
+	}
+}
 
 // PostgreSQLSink implements the Sink interface for PostgreSQL
 type PostgreSQLSink struct {
-	connStr   string
-	table     string
-	db        *sql.DB
-	logger    *log.Logger
-	batchSize int
+	connStr        string
+	table          string
+	db             *sql.DB
+	logger         *log.Logger
+	batchSize      int
+	errorIsolation ErrorIsolation
+	writeTimeout   time.Duration // 0 leaves batch transactions unbounded
+	dlq            pipeline.DeadLetterQueue
+	breaker        *pipeline.CircuitBreaker
+	onCommit       pipeline.CommitHandler
+
+	checkpointPipeline string // pipeline whose checkpoint is written in each batch transaction
+	checkpointTable    string
+	checkpointsHeld    atomic.Bool // an earlier batch failed, so batches no longer carry the checkpoint
+	clock              pipeline.Clock
+	memory             *pipeline.MemoryBudget
+	metrics            pipeline.OperationalMetricsRecorder
+	pipelineName       string // label for metrics
+	connProvider       ConnectionStringProvider
+	keyColumns         []string      // columns identifying a row, matching the table's primary key
+	columns            *columnFilter // nil writes every column
+	refreshTarget      string        // table a staged refresh replaces; writes go to its staging table
+	metadata           MetadataColumns
+	deferred           *deferredEvents // nil fails batches that violate a foreign key
+	writeMode          WriteMode
+	history            HistoryColumns   // version columns in history mode
+	eventLog           EventLogColumns  // event columns in append mode
+	timescale          *TimescaleConfig // nil leaves the table as it is
+	indexes            []IndexDefinition
+	indexesDeferred    bool             // missing indexes wait for CreateIndexes
+	partitions         *PartitionConfig // nil fails batches that need a missing partition
+	partitionMu        sync.Mutex
+	schema             *SchemaCheck // nil leaves the table unchecked
+	conflicts          ConflictResolution
+	session            []sessionVariable // set from event metadata before each write
 }
 
 // NewPostgreSQLSink creates a new PostgreSQL sink
@@ -30,13 +115,120 @@ func NewPostgreSQLSink(connStr, table string, logger *log.Logger) *PostgreSQLSin
 		logger = log.Default()
 	}
 	return &PostgreSQLSink{
-		connStr:   connStr,
-		table:     table,
-		logger:    logger,
-		batchSize: 100,
+		connStr:        connStr,
+		table:          table,
+		logger:         logger,
+		batchSize:      100,
+		errorIsolation: IsolationNone,
+		writeMode:      WriteUpsert,
+		clock:          pipeline.SystemClock,
+		keyColumns:     []string{"_id"},
+	}
+}
+
+// SetClock sets the time source used for checkpoint timestamps and the circuit breaker
+func (p *PostgreSQLSink) SetClock(clock pipeline.Clock) {
+	p.clock = clock
+	if p.breaker != nil {
+		p.breaker.SetClock(clock)
 	}
 }
 
+// SetMemoryBudget makes the sink shrink its batches as the pipeline's memory budget fills up
+func (p *PostgreSQLSink) SetMemoryBudget(budget *pipeline.MemoryBudget) {
+	p.memory = budget
+}
+
+// SetMetrics sets the recorder used for retry and dead-letter metrics. Recorders
+// that do not report operational metrics are ignored.
+func (p *PostgreSQLSink) SetMetrics(metrics pipeline.MetricsRecorder, pipelineName string) {
+	p.metrics, _ = metrics.(pipeline.OperationalMetricsRecorder)
+	p.pipelineName = pipelineName
+}
+
+// SetErrorIsolation sets how failed batches are broken down to isolate bad events
+func (p *PostgreSQLSink) SetErrorIsolation(mode ErrorIsolation) {
+	p.errorIsolation = mode
+}
+
+// SetCircuitBreaker guards batch writes with a circuit breaker. While the
+// breaker is open the failing batch is held and the sink stops consuming
+// events, which pauses the source through backpressure.
+func (p *PostgreSQLSink) SetCircuitBreaker(breaker *pipeline.CircuitBreaker) {
+	p.breaker = breaker
+}
+
+// BreakerState returns the state of the sink's circuit breaker
+func (p *PostgreSQLSink) BreakerState() pipeline.BreakerState {
+	if p.breaker == nil {
+		return pipeline.BreakerClosed
+	}
+	return p.breaker.State()
+}
+
+// SupportsConcurrentWrites marks the sink as safe for concurrent Write calls.
+// Each call batches and commits its own events independently.
+func (p *PostgreSQLSink) SupportsConcurrentWrites() {}
+
+// SetCommitHandler registers a handler called after every batch is written or fails.
+// The events slice is only valid for the duration of the call.
+func (p *PostgreSQLSink) SetCommitHandler(handler pipeline.CommitHandler) {
+	p.onCommit = handler
+}
+
+// SetKeyColumns sets the columns that identify a row, for tables with a
+// composite or natural primary key. They must match a unique constraint on the
+// table, as they are the upsert conflict target; deletes match on them. The
+// default is _id.
+func (p *PostgreSQLSink) SetKeyColumns(columns []string) error {
+	if len(columns) == 0 {
+		columns = []string{"_id"}
+	}
+	seen := make(map[string]bool, len(columns))
+	for _, column := range columns {
+		if !validTableName.MatchString(column) {
+			return fmt.Errorf("invalid key column name: %s", column)
+		}
+		if seen[column] {
+			return fmt.Errorf("duplicate key column: %s", column)
+		}
+		seen[column] = true
+	}
+	if err := checkKeyColumns(columns, p.columns); err != nil {
+		return err
+	}
+	p.keyColumns = columns
+	return nil
+}
+
+// SetColumnPolicy restricts the columns the sink writes. Key columns are always written.
+func (p *PostgreSQLSink) SetColumnPolicy(policy ColumnPolicy) error {
+	filter, err := newColumnFilter(policy)
+	if err != nil {
+		return err
+	}
+	if err := checkKeyColumns(p.keyColumns, filter); err != nil {
+		return err
+	}
+	p.columns = filter
+	return nil
+}
+
+// isKeyColumn reports whether a column is one of the key columns
+func (p *PostgreSQLSink) isKeyColumn(column string) bool {
+	for _, key := range p.keyColumns {
+		if key == column {
+			return true
+		}
+	}
+	return false
+}
+
+// SetDeadLetterQueue sets the queue that receives events isolated as bad
+func (p *PostgreSQLSink) SetDeadLetterQueue(dlq pipeline.DeadLetterQueue) {
+	p.dlq = dlq
+}
+
 // Connect establishes connection to PostgreSQL
 func (p *PostgreSQLSink) Connect(ctx context.Context) error {
 	p.logger.Println("Connecting to PostgreSQL")
@@ -46,9 +238,15 @@ func (p *PostgreSQLSink) Connect(ctx context.Context) error {
 		return fmt.Errorf("invalid table name: %s (must be alphanumeric with underscores, starting with letter or underscore)", p.table)
 	}
 
-	db, err := sql.Open("postgres", p.connStr)
-	if err != nil {
-		return fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+	var db *sql.DB
+	if p.connProvider != nil {
+		db = pgconn.OpenRotating(p.connProvider)
+	} else {
+		var err error
+		db, err = sql.Open("postgres", p.connStr)
+		if err != nil {
+			return fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+		}
 	}
 
 	// Verify connection
@@ -57,6 +255,26 @@ func (p *PostgreSQLSink) Connect(ctx context.Context) error {
 	}
 
 	p.db = db
+	if p.checkpointPipeline != "" {
+		if err := p.ensureCheckpointTable(ctx); err != nil {
+			return err
+		}
+	}
+	if p.schema != nil {
+		if err := p.checkSchema(ctx); err != nil {
+			return err
+		}
+	}
+	if p.timescale != nil {
+		if err := p.ensureHypertable(ctx); err != nil {
+			return err
+		}
+	}
+	if len(p.indexes) > 0 {
+		if err := p.verifyIndexes(ctx); err != nil {
+			return err
+		}
+	}
 	p.logger.Println("Successfully connected to PostgreSQL")
 	return nil
 }
@@ -68,23 +286,70 @@ func (p *PostgreSQLSink) Write(ctx context.Context, events <-chan pipeline.Event
 	go func() {
 		defer close(errors)
 
-		batch := make([]pipeline.Event, 0, p.batchSize)
+		batch := pipeline.GetBatch(p.batchSize)
+		defer func() { pipeline.PutBatch(batch) }()
 
-		for event := range events {
-			batch = append(batch, event)
+		flush := func() {
+			if err := p.commitBatch(ctx, batch); err != nil {
+				sendError(ctx, errors, err)
+			}
+			batch = batch[:0]
+			if p.deferred != nil {
+				if err := p.retryDeferred(ctx); err != nil {
+					sendError(ctx, errors, err)
+				}
+			}
+		}
 
-			if len(batch) >= p.batchSize {
-				if err := p.writeBatch(ctx, batch); err != nil {
-					errors <- err
+		// pressure fires when the memory budget is exhausted while a partial batch is held
+		var pressure <-chan struct{}
+		// retry fires when events held back for a foreign key are due for a retry
+		var retry <-chan time.Time
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					// Write remaining events
+					if len(batch) > 0 {
+						flush()
+					}
+					if p.deferred != nil {
+						if err := p.releaseDeferred(ctx); err != nil {
+							sendError(ctx, errors, err)
+						}
+					}
+					return
+				}
+				batch = append(batch, event)
+				if len(batch) >= p.memory.BatchSize(p.batchSize) {
+					flush()
 				}
+			case <-ctx.Done():
+				abandon(p.logger, batch, events, ctx.Err(), p.onCommit)
 				batch = batch[:0]
+				if p.deferred != nil {
+					// Held events are replayed on the next run, or dead-lettered
+					// without transactional checkpoints
+					if err := p.releaseDeferred(ctx); err != nil {
+						sendError(ctx, errors, err)
+					}
+				}
+				return
+			case <-pressure:
+				flush()
+			case <-retry:
+				retry = nil
+				if err := p.retryDeferred(ctx); err != nil {
+					sendError(ctx, errors, err)
+				}
 			}
-		}
 
-		// Write remaining events
-		if len(batch) > 0 {
-			if err := p.writeBatch(ctx, batch); err != nil {
-				errors <- err
+			pressure = nil
+			if len(batch) > 0 {
+				pressure = p.memory.Exhausted()
+			}
+			if retry == nil && p.deferred != nil && p.deferred.pending() {
+				retry = p.clock.After(p.deferred.config.RetryInterval)
 			}
 		}
 	}()
@@ -92,38 +357,211 @@ func (p *PostgreSQLSink) Write(ctx context.Context, events <-chan pipeline.Event
 	return errors
 }
 
+// commitBatch writes a batch, notifies the commit handler of the outcome and
+// releases the events' data to the pool
+func (p *PostgreSQLSink) commitBatch(ctx context.Context, events []pipeline.Event) error {
+	err := p.guardedFlush(ctx, events)
+	if p.onCommit != nil {
+		p.onCommit(events, err)
+	}
+	pipeline.ReleaseEvents(events)
+	return err
+}
+
+// guardedFlush writes a batch through the circuit breaker, if one is set.
+// Transient failures are retried until the batch is written, the breaker
+// pausing between attempts once it opens.
+func (p *PostgreSQLSink) guardedFlush(ctx context.Context, events []pipeline.Event) error {
+	if p.breaker == nil {
+		return p.flushBatch(ctx, events)
+	}
+
+	for {
+		if err := p.breaker.Wait(ctx); err != nil {
+			return fmt.Errorf("gave up writing batch of %d events: %w", len(events), err)
+		}
+
+		err := p.flushBatch(ctx, events)
+		if err == nil || !isTransientError(err) {
+			// The database answered, so it is reachable even if the data was rejected
+			p.breaker.RecordSuccess()
+			return err
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+
+		p.breaker.RecordFailure()
+		p.logger.Printf("Transient batch write failure (circuit %s): %v", p.breaker.State(), err)
+		if p.metrics != nil {
+			p.metrics.RecordRetry(p.pipelineName, "sink")
+		}
+	}
+}
+
+// flushBatch writes a batch, isolating bad events if the batch fails and isolation is enabled
+func (p *PostgreSQLSink) flushBatch(ctx context.Context, events []pipeline.Event) error {
+	err := p.writeBatch(ctx, events)
+	if err == nil || p.errorIsolation == IsolationNone || isTransientError(err) {
+		return err
+	}
+
+	p.logger.Printf("Batch of %d events failed, isolating bad events (%s): %v", len(events), p.errorIsolation, err)
+	return p.isolateBatch(ctx, events, err)
+}
+
+// isolateBatch commits the good events of a failed batch and rejects the bad ones
+func (p *PostgreSQLSink) isolateBatch(ctx context.Context, events []pipeline.Event, batchErr error) error {
+	if len(events) == 1 {
+		return p.rejectEvent(ctx, events[0], batchErr)
+	}
+
+	var groups [][]pipeline.Event
+	if p.errorIsolation == IsolationIndividual {
+		for i := range events {
+			groups = append(groups, events[i:i+1])
+		}
+	} else {
+		mid := len(events) / 2
+		groups = [][]pipeline.Event{events[:mid], events[mid:]}
+	}
+
+	var errs []error
+	for _, group := range groups {
+		err := p.writeBatch(ctx, group)
+		if err == nil {
+			continue
+		}
+		if isTransientError(err) {
+			return err
+		}
+		if err := p.isolateBatch(ctx, group, err); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// rejectEvent routes an event that cannot be written to the dead-letter queue
+func (p *PostgreSQLSink) rejectEvent(ctx context.Context, event pipeline.Event, cause error) error {
+	if p.dlq == nil {
+		return fmt.Errorf("dropping event %s: %w", event.ID, cause)
+	}
+	if err := p.dlq.Send(ctx, pipeline.WithRejectionRule(event, "write_error"), cause.Error()); err != nil {
+		return fmt.Errorf("failed to dead-letter event %s: %w", event.ID, err)
+	}
+	p.logger.Printf("Dead-lettered event %s: %v", event.ID, cause)
+	if p.metrics != nil {
+		p.metrics.RecordDLQWrite(p.pipelineName, "sink")
+	}
+	return nil
+}
+
+// isTransientError reports whether an error is caused by the connection or
+// cancellation rather than the data, in which case isolating events is pointless
+func isTransientError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Class() {
+		case "08", "53", "57": // connection exception, insufficient resources, operator intervention
+			return true
+		}
+	}
+	return false
+}
+
 // writeBatch writes a batch of events to PostgreSQL
 func (p *PostgreSQLSink) writeBatch(ctx context.Context, events []pipeline.Event) error {
 	if len(events) == 0 {
 		return nil
 	}
 
-	tx, err := p.db.BeginTx(ctx, nil)
+	err := p.writeBatchTx(ctx, events, false)
+	if p.partitions != nil && isMissingPartition(err) {
+		// Create the partitions the batch needs and write it again
+		if err = p.createPartitions(ctx, events); err == nil {
+			err = p.writeBatchTx(ctx, events, false)
+		}
+	}
+	if p.deferred != nil && isForeignKeyViolation(err) {
+		// Write it again event by event, holding back the events that violate a foreign key
+		err = p.writeBatchTx(ctx, events, true)
+	}
+	return err
+}
+
+// writeBatchTx writes a batch of events in one transaction. With foreign key
+// deferral, savepoints let the events that violate a foreign key be held back
+// while the rest of the batch is committed.
+func (p *PostgreSQLSink) writeBatchTx(ctx context.Context, events []pipeline.Event, savepoints bool) (err error) {
+	defer func(parent context.Context) { err = p.timedOut(parent, err, len(events)) }(ctx)
+	ctx, tx, done, err := p.beginBatch(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+		return err
 	}
-	defer func() {
-		if rbErr := tx.Rollback(); rbErr != nil && rbErr != sql.ErrTxDone {
-			p.logger.Printf("Warning: failed to rollback transaction: %v", rbErr)
-		}
-	}()
+	defer done()
 
-	for _, event := range events {
-		if err := p.writeEvent(ctx, tx, event); err != nil {
+	var held heldBatch
+	checkpointed := events
+	if p.deferred != nil && p.deferred.holding() {
+		// Keep the checkpoint before the events held by earlier batches
+		checkpointed = nil
+	}
+	for i, event := range events {
+		var err error
+		if p.deferred != nil {
+			err = p.writeOrHold(ctx, tx, i, event, savepoints, &held)
+		} else {
+			err = p.writeEvent(ctx, tx, event)
+		}
+		if err != nil {
 			return fmt.Errorf("failed to write event: %w", err)
 		}
 	}
 
+	if len(held.events) > 0 && len(checkpointed) > held.first {
+		checkpointed = events[:held.first]
+	}
+	if err := p.checkpointInTx(ctx, tx, checkpointed); err != nil {
+		return err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	if len(held.events) > 0 {
+		p.deferred.hold(held.events)
+		p.logger.Printf("Wrote %d events to PostgreSQL, holding back %d for a foreign key", len(events)-len(held.events), len(held.events))
+		return nil
+	}
 	p.logger.Printf("Wrote %d events to PostgreSQL", len(events))
 	return nil
 }
 
 // writeEvent writes a single event to PostgreSQL
-func (p *PostgreSQLSink) writeEvent(ctx context.Context, tx *sql.Tx, event pipeline.Event) error {
+func (p *PostgreSQLSink) writeEvent(ctx context.Context, tx execer, event pipeline.Event) error {
+	if err := p.setSession(ctx, tx, event.Metadata); err != nil {
+		return err
+	}
+	switch p.writeMode {
+	case WriteHistory:
+		switch event.Operation {
+		case "insert", "update", "replace", "delete":
+			return p.writeVersion(ctx, tx, event)
+		}
+	case WriteAppend:
+		return p.appendEvent(ctx, tx, event)
+	}
 	switch event.Operation {
 	case "insert":
 		return p.insertEvent(ctx, tx, event)
@@ -138,59 +576,211 @@ func (p *PostgreSQLSink) writeEvent(ctx context.Context, tx *sql.Tx, event pipel
 }
 
 // insertEvent inserts a new record
-func (p *PostgreSQLSink) insertEvent(ctx context.Context, tx *sql.Tx, event pipeline.Event) error {
+func (p *PostgreSQLSink) insertEvent(ctx context.Context, tx execer, event pipeline.Event) error {
 	if len(event.Data) == 0 {
 		return nil
 	}
+	return p.writeRow(ctx, tx, event, false)
+}
 
-	columns := make([]string, 0, len(event.Data))
-	placeholders := make([]string, 0, len(event.Data))
-	values := make([]interface{}, 0, len(event.Data))
+// writeRow upserts the row for an event, resolving conflicts with a newer row
+func (p *PostgreSQLSink) writeRow(ctx context.Context, tx execer, event pipeline.Event, deleted bool) error {
+	written, err := p.upsertRow(ctx, tx, event, deleted, true)
+	if err != nil || written || deleted || p.conflicts.Strategy != ConflictMerge {
+		return err
+	}
+	return p.mergeConflict(ctx, tx, event)
+}
+
+// upsertRow upserts the row for an event, leaving newer rows alone if guarded,
+// and reports whether a row was written
+func (p *PostgreSQLSink) upsertRow(ctx context.Context, tx execer, event pipeline.Event, deleted, guarded bool) (bool, error) {
+	columns, values, err := p.rowColumns(event, deleted)
+	if err != nil || len(columns) == 0 {
+		return false, err
+	}
+
+	placeholders := make([]string, len(columns))
+	for i := range columns {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	table, err := p.eventTable(event)
+	if err != nil {
+		return false, err
+	}
+	conflict := "DO NOTHING"
+	if updates := p.buildUpdateClause(columns); updates != "" {
+		conflict = "DO UPDATE SET " + updates
+		var conditions []string
+		if p.metadata.RowHash != "" {
+			// Leave rows whose data would not change untouched
+			conditions = append(conditions, fmt.Sprintf("%s.%s IS DISTINCT FROM EXCLUDED.%s", table, p.metadata.RowHash, p.metadata.RowHash))
+		}
+		if guard := p.conflictGuard(table, "EXCLUDED."+p.metadata.SourceTimestamp, "EXCLUDED."+p.conflicts.OriginColumn); guarded && guard != "" {
+			conditions = append(conditions, guard)
+		}
+		if len(conditions) > 0 {
+			conflict += " WHERE " + strings.Join(conditions, " AND ")
+		}
+	}
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) %s",
+		table,
+		strings.Join(columns, ", "),
+		strings.Join(placeholders, ", "),
+		strings.Join(p.keyColumns, ", "),
+		conflict,
+	)
+
+	result, err := tx.ExecContext(ctx, query, values...)
+	if err != nil {
+		return false, err
+	}
+	written, err := result.RowsAffected()
+	return written > 0, err
+}
+
+// rowColumns returns the columns and values written for an event: its data
+// as allowed by the column policy, followed by the metadata columns
+func (p *PostgreSQLSink) rowColumns(event pipeline.Event, deleted bool) ([]string, []interface{}, error) {
+	columns := make([]string, 0, len(event.Data)+5)
+	values := make([]interface{}, 0, len(event.Data)+5)
 
-	i := 1
 	for key, value := range event.Data {
 		// Validate column name to prevent SQL injection
 		if !validTableName.MatchString(key) {
-			return fmt.Errorf("invalid column name: %s", key)
+			return nil, nil, fmt.Errorf("invalid column name: %s", key)
+		}
+		if p.metadata.has(key) {
+			continue
+		}
+		if permitted, err := p.permitColumn(key); err != nil {
+			return nil, nil, err
+		} else if !permitted {
+			continue
 		}
 		columns = append(columns, key)
-		placeholders = append(placeholders, fmt.Sprintf("$%d", i))
 		values = append(values, value)
-		i++
+	}
+	if len(columns) == 0 {
+		return nil, nil, nil
 	}
 
-	query := fmt.Sprintf(
-		"INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (_id) DO UPDATE SET %s",
-		p.table,
-		strings.Join(columns, ", "),
-		strings.Join(placeholders, ", "),
-		p.buildUpdateClause(columns),
-	)
+	var hash string
+	if p.metadata.RowHash != "" {
+		var err error
+		if hash, err = rowHash(columns, values, deleted); err != nil {
+			return nil, nil, err
+		}
+	}
+	metaColumns, metaValues := p.metadata.values(event, deleted, p.clock.Now())
+	columns, values = append(columns, metaColumns...), append(values, metaValues...)
+	if p.metadata.RowHash != "" {
+		columns, values = append(columns, p.metadata.RowHash), append(values, hash)
+	}
+	return columns, values, nil
+}
 
-	_, err := tx.ExecContext(ctx, query, values...)
-	return err
+// permitColumn reports whether the column policy lets a column be written,
+// logging each dropped column once. Rejected columns fail the event.
+func (p *PostgreSQLSink) permitColumn(column string) (bool, error) {
+	if p.columns == nil || p.isKeyColumn(column) || p.columns.permits(column) {
+		return true, nil
+	}
+	if p.columns.mode == ColumnsReject {
+		return false, fmt.Errorf("column %s is not allowed by the column policy", column)
+	}
+	if _, warned := p.columns.warned.LoadOrStore(column, true); !warned {
+		p.logger.Printf("Dropping column %s, which is not allowed by the column policy", column)
+	}
+	return false, nil
 }
 
 // upsertEvent updates or inserts a record
-func (p *PostgreSQLSink) upsertEvent(ctx context.Context, tx *sql.Tx, event pipeline.Event) error {
+func (p *PostgreSQLSink) upsertEvent(ctx context.Context, tx execer, event pipeline.Event) error {
 	return p.insertEvent(ctx, tx, event) // Same as insert with upsert logic
 }
 
-// deleteEvent deletes a record
-func (p *PostgreSQLSink) deleteEvent(ctx context.Context, tx *sql.Tx, event pipeline.Event) error {
-	if id, ok := event.Data["_id"]; ok {
-		query := fmt.Sprintf("DELETE FROM %s WHERE _id = $1", p.table)
-		_, err := tx.ExecContext(ctx, query, id)
+// deleteEvent deletes a record, or marks it deleted if a deleted column is configured
+func (p *PostgreSQLSink) deleteEvent(ctx context.Context, tx execer, event pipeline.Event) error {
+	key, err := p.eventKey(event)
+	if err != nil {
 		return err
 	}
-	return nil
+	if p.metadata.Deleted != "" {
+		event.Data = key
+		return p.writeRow(ctx, tx, event, true)
+	}
+
+	table, err := p.eventTable(event)
+	if err != nil {
+		return err
+	}
+	match, values := p.keyCondition(key)
+	if !event.Timestamp.IsZero() {
+		// Leave rows that are newer than the delete
+		ts, origin := fmt.Sprintf("$%d", len(values)+1), fmt.Sprintf("$%d", len(values)+2)
+		if guard := p.conflictGuard(table, ts, origin); guard != "" {
+			match += " AND " + guard
+			values = append(values, event.Timestamp)
+			if p.conflicts.Strategy == ConflictSourcePriority {
+				values = append(values, event.Metadata[p.conflicts.OriginColumn])
+			}
+		}
+	}
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s", table, match)
+	_, err = tx.ExecContext(ctx, query, values...)
+	return err
+}
+
+// keyCondition returns a WHERE condition matching a row's key columns, as
+// the first placeholders, and the values they take
+func (p *PostgreSQLSink) keyCondition(key map[string]interface{}) (string, []interface{}) {
+	conditions := make([]string, len(p.keyColumns))
+	values := make([]interface{}, len(p.keyColumns))
+	for i, column := range p.keyColumns {
+		conditions[i] = fmt.Sprintf("%s = $%d", column, i+1)
+		values[i] = key[column]
+	}
+	return strings.Join(conditions, " AND "), values
+}
+
+// eventTable returns the table an event is written to: its destination hint,
+// if a router transformer set one, or the sink's table
+func (p *PostgreSQLSink) eventTable(event pipeline.Event) (string, error) {
+	if event.Destination == "" {
+		return p.table, nil
+	}
+	if !validTableName.MatchString(event.Destination) {
+		return "", fmt.Errorf("invalid destination table name: %s", event.Destination)
+	}
+	return event.Destination, nil
+}
+
+// eventKey returns the key column values of an event. Columns missing from
+// the data are taken from the source document key; an event that cannot be
+// matched to a row fails, so it is isolated and dead-lettered rather than
+// silently lost.
+func (p *PostgreSQLSink) eventKey(event pipeline.Event) (map[string]interface{}, error) {
+	key := make(map[string]interface{}, len(p.keyColumns))
+	for _, column := range p.keyColumns {
+		value, ok := event.Data[column]
+		if !ok {
+			value, ok = event.Key[column]
+		}
+		if !ok || value == nil {
+			return nil, fmt.Errorf("%s event %s has no %s", event.Operation, event.ID, column)
+		}
+		key[column] = value
+	}
+	return key, nil
 }
 
 // buildUpdateClause builds the SET clause for upsert
 func (p *PostgreSQLSink) buildUpdateClause(columns []string) string {
 	updates := make([]string, 0, len(columns))
 	for _, col := range columns {
-		if col != "_id" {
+		if !p.isKeyColumn(col) {
 			updates = append(updates, fmt.Sprintf("%s = EXCLUDED.%s", col, col))
 		}
 	}
@@ -244,3 +834,17 @@ func (p *PostgreSQLSink) IsTableEmpty(ctx context.Context) (bool, error) {
 
 	return count == 0, nil
 }
+
+// CountRows counts the rows of the target table, leaving out soft-deleted rows
+func (p *PostgreSQLSink) CountRows(ctx context.Context) (int64, error) {
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s", p.table)
+	if p.metadata.Deleted != "" {
+		query += fmt.Sprintf(" WHERE %s IS NOT TRUE", p.metadata.Deleted)
+	}
+
+	var count int64
+	if err := p.db.QueryRowContext(ctx, query).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count rows: %w", err)
+	}
+	return count, nil
+}
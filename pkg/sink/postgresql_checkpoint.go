@@ -0,0 +1,113 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// defaultCheckpointTable is the table transactional checkpoints are stored in
+const defaultCheckpointTable = "datapipe_checkpoints"
+
+// EnableTransactionalCheckpoints makes every batch transaction also record the
+// pipeline's checkpoint, so row data and source position commit atomically.
+// The sink then also serves as the pipeline's CheckpointStore.
+func (p *PostgreSQLSink) EnableTransactionalCheckpoints(pipelineName, table string) {
+	if table == "" {
+		table = defaultCheckpointTable
+	}
+	p.checkpointPipeline = pipelineName
+	p.checkpointTable = table
+}
+
+// CheckpointsInTransaction reports whether checkpoints are committed with each batch
+func (p *PostgreSQLSink) CheckpointsInTransaction() bool {
+	return p.checkpointPipeline != ""
+}
+
+// HoldCheckpoints stops batches from carrying the checkpoint while held. The
+// pipeline holds them once a batch fails, so later batches cannot move the
+// checkpoint past the failed events, which are then replayed on the next run.
+func (p *PostgreSQLSink) HoldCheckpoints(held bool) {
+	p.checkpointsHeld.Store(held)
+}
+
+// ensureCheckpointTable creates the checkpoint table if it does not exist
+func (p *PostgreSQLSink) ensureCheckpointTable(ctx context.Context) error {
+	if !validTableName.MatchString(p.checkpointTable) {
+		return fmt.Errorf("invalid checkpoint table name: %s", p.checkpointTable)
+	}
+
+	query := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	pipeline TEXT PRIMARY KEY,
+	position TEXT NOT NULL,
+	updated_at TIMESTAMPTZ NOT NULL
+)`, p.checkpointTable)
+	if _, err := p.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to create checkpoint table: %w", err)
+	}
+	return nil
+}
+
+// saveCheckpoint upserts a checkpoint using the given transaction or database handle
+func (p *PostgreSQLSink) saveCheckpoint(ctx context.Context, exec execer, cp pipeline.Checkpoint) error {
+	query := fmt.Sprintf(
+		"INSERT INTO %s (pipeline, position, updated_at) VALUES ($1, $2, $3) "+
+			"ON CONFLICT (pipeline) DO UPDATE SET position = EXCLUDED.position, updated_at = EXCLUDED.updated_at",
+		p.checkpointTable,
+	)
+	if _, err := exec.ExecContext(ctx, query, cp.Pipeline, cp.Position, cp.UpdatedAt); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
+}
+
+// checkpointInTx records the position of the batch's last event inside its transaction
+func (p *PostgreSQLSink) checkpointInTx(ctx context.Context, tx *sql.Tx, events []pipeline.Event) error {
+	if p.checkpointPipeline == "" || p.checkpointsHeld.Load() {
+		return nil
+	}
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Position != "" {
+			return p.saveCheckpoint(ctx, tx, pipeline.Checkpoint{
+				Pipeline:  p.checkpointPipeline,
+				Position:  events[i].Position,
+				UpdatedAt: p.clock.Now(),
+			})
+		}
+	}
+	return nil
+}
+
+// Load returns the checkpoint for a pipeline, or nil if none was saved
+func (p *PostgreSQLSink) Load(ctx context.Context, pipelineName string) (*pipeline.Checkpoint, error) {
+	if p.checkpointTable == "" {
+		return nil, fmt.Errorf("transactional checkpoints are not enabled")
+	}
+
+	query := fmt.Sprintf("SELECT position, updated_at FROM %s WHERE pipeline = $1", p.checkpointTable)
+	cp := pipeline.Checkpoint{Pipeline: pipelineName}
+	err := p.db.QueryRowContext(ctx, query, pipelineName).Scan(&cp.Position, &cp.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+	return &cp, nil
+}
+
+// Save stores a checkpoint outside of a batch transaction
+func (p *PostgreSQLSink) Save(ctx context.Context, cp pipeline.Checkpoint) error {
+	if p.checkpointTable == "" {
+		return fmt.Errorf("transactional checkpoints are not enabled")
+	}
+	return p.saveCheckpoint(ctx, p.db, cp)
+}
+
+// execer is satisfied by *sql.DB, *sql.Tx and the recorder previewing statements
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
@@ -0,0 +1,197 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"github.com/lib/pq"
+)
+
+// fakeDatabase is a database/sql driver that accepts every statement except
+// those with a "bad" or an orphan argument, and keeps the checkpoints of
+// committed transactions, so batch transactions can be tested without PostgreSQL
+type fakeDatabase struct {
+	mu          sync.Mutex
+	checkpoints map[string]string // committed position per pipeline
+	orphans     map[string]bool   // arguments violating a foreign key
+}
+
+func newFakeDatabase() *fakeDatabase {
+	return &fakeDatabase{checkpoints: make(map[string]string), orphans: make(map[string]bool)}
+}
+
+// setOrphan makes statements with the argument violate a foreign key, or not
+func (d *fakeDatabase) setOrphan(arg string, orphan bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.orphans[arg] = orphan
+}
+
+// open returns a handle to the database
+func (d *fakeDatabase) open() *sql.DB {
+	return sql.OpenDB(d)
+}
+
+// checkpoint returns the committed position of a pipeline
+func (d *fakeDatabase) checkpoint(pipelineName string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.checkpoints[pipelineName]
+}
+
+func (d *fakeDatabase) Connect(ctx context.Context) (driver.Conn, error) {
+	return &fakeConn{db: d}, nil
+}
+
+func (d *fakeDatabase) Driver() driver.Driver { return nil }
+
+// fakeConn runs statements against a fakeDatabase
+type fakeConn struct {
+	db      *fakeDatabase
+	pending map[string]string // checkpoints written by the open transaction
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, driver.ErrSkip
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.pending = make(map[string]string)
+	return c, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	for _, arg := range args {
+		if arg.Value == "bad" {
+			return nil, &pq.Error{Code: "23502", Message: "null value in column"}
+		}
+		if s, ok := arg.Value.(string); ok && c.db.orphan(s) {
+			return nil, &pq.Error{Code: "23503", Message: "violates foreign key constraint"}
+		}
+	}
+	if strings.HasPrefix(query, "INSERT INTO "+defaultCheckpointTable) {
+		c.pending[args[0].Value.(string)] = args[1].Value.(string)
+	}
+	return driverResult{}, nil
+}
+
+func (d *fakeDatabase) orphan(arg string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.orphans[arg]
+}
+
+func (c *fakeConn) Commit() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+	for pipelineName, position := range c.pending {
+		c.db.checkpoints[pipelineName] = position
+	}
+	c.pending = nil
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.pending = nil
+	return nil
+}
+
+// TestCheckpointHeldAfterFailedBatch tests that once a batch fails, later
+// batches no longer move the checkpoint committed with them past it
+func TestCheckpointHeldAfterFailedBatch(t *testing.T) {
+	database := newFakeDatabase()
+	sink := NewPostgreSQLSink("", "users", nil)
+	sink.db = database.open()
+	sink.batchSize = 1
+	sink.EnableTransactionalCheckpoints("test", "")
+	// Like the pipeline, hold checkpoints once a batch fails
+	sink.SetCommitHandler(func(events []pipeline.Event, err error) {
+		if err != nil {
+			sink.HoldCheckpoints(true)
+		}
+	})
+
+	events := make(chan pipeline.Event)
+	errs := sink.Write(context.Background(), events)
+	go func() {
+		defer close(events)
+		events <- pipeline.Event{ID: "1", Operation: "insert", Position: "p1", Data: map[string]interface{}{"_id": "1"}}
+		events <- pipeline.Event{ID: "2", Operation: "insert", Position: "p2", Data: map[string]interface{}{"_id": "bad"}}
+		events <- pipeline.Event{ID: "3", Operation: "insert", Position: "p3", Data: map[string]interface{}{"_id": "3"}}
+	}()
+	var failed int
+	for range errs {
+		failed++
+	}
+
+	if failed != 1 {
+		t.Errorf("expected 1 failed batch, got %d", failed)
+	}
+	if got := database.checkpoint("test"); got != "p1" {
+		t.Errorf("expected the checkpoint to stay before the failed batch at p1, got %q", got)
+	}
+}
+
+// TestCheckpointBeforeHeldEvents tests that the checkpoint committed with the
+// batches stays before events held back for a foreign key, so a restart while
+// they are held replays them
+func TestCheckpointBeforeHeldEvents(t *testing.T) {
+	database := newFakeDatabase()
+	database.setOrphan("child", true)
+	run := func(events ...pipeline.Event) *recordingDLQ {
+		t.Helper()
+		sink := NewPostgreSQLSink("", "users", nil)
+		sink.db = database.open()
+		sink.batchSize = 3
+		sink.EnableTransactionalCheckpoints("test", "")
+		if err := sink.SetForeignKeyDeferral(ForeignKeyDeferral{MaxEvents: 10, RetryInterval: time.Hour}); err != nil {
+			t.Fatalf("SetForeignKeyDeferral() error = %v", err)
+		}
+		dlq := &recordingDLQ{}
+		sink.SetDeadLetterQueue(dlq)
+
+		in := make(chan pipeline.Event)
+		errs := sink.Write(context.Background(), in)
+		go func() {
+			defer close(in)
+			for _, event := range events {
+				in <- event
+			}
+		}()
+		for err := range errs {
+			t.Errorf("Write() error = %v", err)
+		}
+		return dlq
+	}
+	event := func(id, position string) pipeline.Event {
+		return pipeline.Event{ID: id, Operation: "insert", Position: position, Data: map[string]interface{}{"_id": id}}
+	}
+
+	// The child arrives before its parent and the process stops while it is held
+	dlq := run(event("1", "p1"), event("child", "p2"), event("3", "p3"), event("4", "p4"))
+	if got := database.checkpoint("test"); got != "p1" {
+		t.Errorf("expected the checkpoint to stay before the held event at p1, got %q", got)
+	}
+	if len(dlq.events) != 0 {
+		t.Errorf("expected the held event left for replay, got %d dead-lettered", len(dlq.events))
+	}
+
+	// Once the parent exists, the next run replays from the checkpoint and
+	// writes the held event
+	database.setOrphan("child", false)
+	dlq = run(event("child", "p2"), event("3", "p3"), event("4", "p4"))
+	if got := database.checkpoint("test"); got != "p4" {
+		t.Errorf("expected the checkpoint to move on to p4 once nothing is held, got %q", got)
+	}
+	if len(dlq.events) != 0 {
+		t.Errorf("expected nothing dead-lettered, got %d", len(dlq.events))
+	}
+}
@@ -0,0 +1,200 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"github.com/lib/pq"
+)
+
+// ConflictStrategy decides what happens when an event reaches a row that
+// changed at its source after the event did, as happens when more than one
+// system writes the table (two-way or multi-writer sync)
+type ConflictStrategy string
+
+const (
+	// ConflictOverwrite writes every event, whatever the row holds
+	ConflictOverwrite ConflictStrategy = "overwrite"
+	// ConflictLastWriteWins keeps rows that are newer than the event
+	ConflictLastWriteWins ConflictStrategy = "last_write_wins"
+	// ConflictSourcePriority keeps newer rows unless the event comes from an
+	// origin ranked above the origin of the row
+	ConflictSourcePriority ConflictStrategy = "source_priority"
+	// ConflictMerge writes what a merge transformer makes of the event and
+	// the newer row
+	ConflictMerge ConflictStrategy = "merge"
+)
+
+// ParseConflictStrategy parses a conflict strategy from configuration; empty selects overwrite
+func ParseConflictStrategy(name string) (ConflictStrategy, error) {
+	switch ConflictStrategy(name) {
+	case "":
+		return ConflictOverwrite, nil
+	case ConflictOverwrite, ConflictLastWriteWins, ConflictSourcePriority, ConflictMerge:
+		return ConflictStrategy(name), nil
+	default:
+		return "", fmt.Errorf("unsupported conflict resolution: %s", name)
+	}
+}
+
+// ConflictResolution configures how events are written to rows that are
+// newer than they are. A row is newer when its source timestamp metadata
+// column is later than the event's Timestamp; rows and events without a
+// source time are never in conflict.
+type ConflictResolution struct {
+	Strategy ConflictStrategy
+	// Priority lists origin names, highest first, for source priority.
+	// Origins that are not listed rank below every listed one.
+	Priority []string
+	// OriginColumn is the metadata value column holding the origin marker
+	// (name:event ID) of the change that last wrote the row, for source priority
+	OriginColumn string
+	// Merge is called for merge with the event, whose Before is set to the
+	// newer row; the Data it returns is written in place of the row
+	Merge pipeline.Transformer
+}
+
+// SetConflictResolution sets how events are written to rows that are newer
+// than they are. It applies to the upsert write mode and needs the source
+// timestamp metadata column, so it is set after SetMetadataColumns.
+func (p *PostgreSQLSink) SetConflictResolution(resolution ConflictResolution) error {
+	if resolution.Strategy == "" || resolution.Strategy == ConflictOverwrite {
+		p.conflicts = ConflictResolution{}
+		return nil
+	}
+	if _, err := ParseConflictStrategy(string(resolution.Strategy)); err != nil {
+		return err
+	}
+	if p.writeMode != WriteUpsert {
+		return fmt.Errorf("conflict resolution %s requires the upsert write mode", resolution.Strategy)
+	}
+	if p.metadata.SourceTimestamp == "" {
+		return fmt.Errorf("conflict resolution %s requires a source timestamp column", resolution.Strategy)
+	}
+	switch resolution.Strategy {
+	case ConflictSourcePriority:
+		if len(resolution.Priority) == 0 {
+			return fmt.Errorf("conflict resolution source_priority requires a priority list")
+		}
+		found := false
+		for _, column := range p.metadata.Values {
+			found = found || column == resolution.OriginColumn
+		}
+		if resolution.OriginColumn == "" || !found {
+			return fmt.Errorf("conflict resolution source_priority requires the origin column to be a metadata value column")
+		}
+	case ConflictMerge:
+		if resolution.Merge == nil {
+			return fmt.Errorf("conflict resolution merge requires a merge transformer")
+		}
+	}
+	p.conflicts = resolution
+	return nil
+}
+
+// conflictGuard returns the condition under which an event may replace the
+// existing row of table, or "" when events always win. ts and origin are the
+// expressions of the event's source time and origin marker in the statement.
+func (p *PostgreSQLSink) conflictGuard(table, ts, origin string) string {
+	if p.conflicts.Strategy == "" {
+		return ""
+	}
+	column := table + "." + p.metadata.SourceTimestamp
+	guard := fmt.Sprintf("%s IS NULL OR %s IS NULL OR %s <= %s", column, ts, column, ts)
+	if p.conflicts.Strategy == ConflictSourcePriority {
+		guard += fmt.Sprintf(" OR %s < %s", p.originRank(origin), p.originRank(table+"."+p.conflicts.OriginColumn))
+	}
+	return "(" + guard + ")"
+}
+
+// originRank returns an expression ranking the origin marker in column by
+// the priority list, lower ranking higher
+func (p *PostgreSQLSink) originRank(column string) string {
+	var rank strings.Builder
+	fmt.Fprintf(&rank, "CASE split_part(%s, ':', 1)", column)
+	for i, name := range p.conflicts.Priority {
+		fmt.Fprintf(&rank, " WHEN %s THEN %d", pq.QuoteLiteral(name), i)
+	}
+	fmt.Fprintf(&rank, " ELSE %d END", len(p.conflicts.Priority))
+	return rank.String()
+}
+
+// querier is satisfied by *sql.Tx; the recorder previewing statements cannot
+// read rows, so previews leave merges out
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// mergeConflict writes the merge of an event that was not written because its
+// row is newer. The row is locked until the batch commits and keeps its source
+// time, so older events keep losing to it.
+func (p *PostgreSQLSink) mergeConflict(ctx context.Context, tx execer, event pipeline.Event) error {
+	q, ok := tx.(querier)
+	if !ok || event.Timestamp.IsZero() {
+		return nil
+	}
+	key, err := p.eventKey(event)
+	if err != nil {
+		return err
+	}
+	table, err := p.eventTable(event)
+	if err != nil {
+		return err
+	}
+	match, values := p.keyCondition(key)
+	query := fmt.Sprintf("SELECT * FROM %s WHERE %s AND %s > $%d FOR UPDATE", table, match, p.metadata.SourceTimestamp, len(values)+1)
+	current, err := queryRow(ctx, q, query, append(values, event.Timestamp)...)
+	if err != nil || current == nil {
+		// No newer row: the upsert left the row alone as its data is unchanged
+		return err
+	}
+
+	event.Before = current
+	merged, err := p.conflicts.Merge.Transform(ctx, event)
+	if err != nil {
+		return fmt.Errorf("failed to merge event %s into a newer row: %w", event.ID, err)
+	}
+	if ts, ok := current[p.metadata.SourceTimestamp].(time.Time); ok && ts.After(merged.Timestamp) {
+		merged.Timestamp = ts
+	}
+	_, err = p.upsertRow(ctx, tx, merged, false, false)
+	return err
+}
+
+// queryRow returns the first row of a query as a map of its columns, or nil
+// if there is none
+func queryRow(ctx context.Context, q querier, query string, args ...interface{}) (map[string]interface{}, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	if err := rows.Scan(pointers...); err != nil {
+		return nil, err
+	}
+	row := make(map[string]interface{}, len(columns))
+	for i, column := range columns {
+		if b, ok := values[i].([]byte); ok {
+			row[column] = string(b)
+		} else {
+			row[column] = values[i]
+		}
+	}
+	return row, rows.Err()
+}
@@ -0,0 +1,148 @@
+package sink
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"github.com/IEatCodeDaily/data-pipe/pkg/transform"
+)
+
+// TestParseConflictStrategy tests parsing conflict strategies
+func TestParseConflictStrategy(t *testing.T) {
+	for _, name := range []string{"overwrite", "last_write_wins", "source_priority", "merge"} {
+		if strategy, err := ParseConflictStrategy(name); err != nil || string(strategy) != name {
+			t.Errorf("ParseConflictStrategy(%q) = %q, %v", name, strategy, err)
+		}
+	}
+	if strategy, err := ParseConflictStrategy(""); err != nil || strategy != ConflictOverwrite {
+		t.Errorf("ParseConflictStrategy(\"\") = %q, %v, want overwrite", strategy, err)
+	}
+	if _, err := ParseConflictStrategy("first_write_wins"); err == nil {
+		t.Error("expected error for an unknown strategy")
+	}
+}
+
+// TestSetConflictResolution tests conflict resolution validation
+func TestSetConflictResolution(t *testing.T) {
+	tests := []struct {
+		name       string
+		metadata   MetadataColumns
+		history    bool
+		resolution ConflictResolution
+		wantErr    bool
+	}{
+		{"overwrite", MetadataColumns{}, false, ConflictResolution{Strategy: ConflictOverwrite}, false},
+		{"last write wins", MetadataColumns{SourceTimestamp: "_source_ts"}, false, ConflictResolution{Strategy: ConflictLastWriteWins}, false},
+		{"no source timestamp", MetadataColumns{}, false, ConflictResolution{Strategy: ConflictLastWriteWins}, true},
+		{"history mode", MetadataColumns{SourceTimestamp: "_source_ts"}, true, ConflictResolution{Strategy: ConflictLastWriteWins}, true},
+		{"unknown", MetadataColumns{SourceTimestamp: "_source_ts"}, false, ConflictResolution{Strategy: "newest"}, true},
+		{"priority", MetadataColumns{SourceTimestamp: "_source_ts", Values: []string{"_origin"}}, false,
+			ConflictResolution{Strategy: ConflictSourcePriority, Priority: []string{"crm"}, OriginColumn: "_origin"}, false},
+		{"priority without list", MetadataColumns{SourceTimestamp: "_source_ts", Values: []string{"_origin"}}, false,
+			ConflictResolution{Strategy: ConflictSourcePriority, OriginColumn: "_origin"}, true},
+		{"priority without origin column", MetadataColumns{SourceTimestamp: "_source_ts"}, false,
+			ConflictResolution{Strategy: ConflictSourcePriority, Priority: []string{"crm"}, OriginColumn: "_origin"}, true},
+		{"merge", MetadataColumns{SourceTimestamp: "_source_ts"}, false, ConflictResolution{Strategy: ConflictMerge, Merge: transform.NewPassThroughTransformer()}, false},
+		{"merge without transformer", MetadataColumns{SourceTimestamp: "_source_ts"}, false, ConflictResolution{Strategy: ConflictMerge}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewPostgreSQLSink("", "users", nil)
+			if err := s.SetMetadataColumns(tt.metadata); err != nil {
+				t.Fatalf("SetMetadataColumns() error = %v", err)
+			}
+			if tt.history {
+				if err := s.SetHistoryMode(HistoryColumns{}); err != nil {
+					t.Fatalf("SetHistoryMode() error = %v", err)
+				}
+			}
+			err := s.SetConflictResolution(tt.resolution)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SetConflictResolution() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestConflictStatements tests that writes leave rows newer than the event alone
+func TestConflictStatements(t *testing.T) {
+	changed := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	newSink := func(resolution ConflictResolution) *PostgreSQLSink {
+		s := NewPostgreSQLSink("", "users", nil)
+		if err := s.SetMetadataColumns(MetadataColumns{SourceTimestamp: "_source_ts", Values: []string{"_origin"}}); err != nil {
+			t.Fatalf("SetMetadataColumns() error = %v", err)
+		}
+		if err := s.SetConflictResolution(resolution); err != nil {
+			t.Fatalf("SetConflictResolution() error = %v", err)
+		}
+		return s
+	}
+	update := pipeline.Event{ID: "1", Operation: "update", Timestamp: changed, Data: map[string]interface{}{"_id": "1"},
+		Metadata: map[string]string{"_origin": "crm:1"}}
+	remove := pipeline.Event{ID: "1", Operation: "delete", Timestamp: changed, Key: map[string]interface{}{"_id": "1"},
+		Metadata: map[string]string{"_origin": "crm:1"}}
+	const rank = "CASE split_part(%s, ':', 1) WHEN 'crm' THEN 0 WHEN 'app' THEN 1 ELSE 2 END"
+
+	tests := []struct {
+		name       string
+		resolution ConflictResolution
+		event      pipeline.Event
+		want       Statement
+	}{
+		{
+			name:       "overwrite",
+			resolution: ConflictResolution{Strategy: ConflictOverwrite},
+			event:      update,
+			want: Statement{Query: "INSERT INTO users (_id, _source_ts, _origin) VALUES ($1, $2, $3) ON CONFLICT (_id) DO UPDATE SET _source_ts = EXCLUDED._source_ts, _origin = EXCLUDED._origin",
+				Args: []interface{}{"1", changed, "crm:1"}},
+		},
+		{
+			name:       "last write wins",
+			resolution: ConflictResolution{Strategy: ConflictLastWriteWins},
+			event:      update,
+			want: Statement{Query: "INSERT INTO users (_id, _source_ts, _origin) VALUES ($1, $2, $3) ON CONFLICT (_id) DO UPDATE SET _source_ts = EXCLUDED._source_ts, _origin = EXCLUDED._origin" +
+				" WHERE (users._source_ts IS NULL OR EXCLUDED._source_ts IS NULL OR users._source_ts <= EXCLUDED._source_ts)",
+				Args: []interface{}{"1", changed, "crm:1"}},
+		},
+		{
+			name:       "last write wins delete",
+			resolution: ConflictResolution{Strategy: ConflictLastWriteWins},
+			event:      remove,
+			want: Statement{Query: "DELETE FROM users WHERE _id = $1 AND (users._source_ts IS NULL OR $2 IS NULL OR users._source_ts <= $2)",
+				Args: []interface{}{"1", changed}},
+		},
+		{
+			name:       "source priority",
+			resolution: ConflictResolution{Strategy: ConflictSourcePriority, Priority: []string{"crm", "app"}, OriginColumn: "_origin"},
+			event:      update,
+			want: Statement{Query: "INSERT INTO users (_id, _source_ts, _origin) VALUES ($1, $2, $3) ON CONFLICT (_id) DO UPDATE SET _source_ts = EXCLUDED._source_ts, _origin = EXCLUDED._origin" +
+				" WHERE (users._source_ts IS NULL OR EXCLUDED._source_ts IS NULL OR users._source_ts <= EXCLUDED._source_ts" +
+				" OR " + fmt.Sprintf(rank, "EXCLUDED._origin") + " < " + fmt.Sprintf(rank, "users._origin") + ")",
+				Args: []interface{}{"1", changed, "crm:1"}},
+		},
+		{
+			name:       "source priority delete",
+			resolution: ConflictResolution{Strategy: ConflictSourcePriority, Priority: []string{"crm", "app"}, OriginColumn: "_origin"},
+			event:      remove,
+			want: Statement{Query: "DELETE FROM users WHERE _id = $1 AND (users._source_ts IS NULL OR $2 IS NULL OR users._source_ts <= $2" +
+				" OR " + fmt.Sprintf(rank, "$3") + " < " + fmt.Sprintf(rank, "users._origin") + ")",
+				Args: []interface{}{"1", changed, "crm:1"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statements, err := newSink(tt.resolution).Statements(tt.event)
+			if err != nil {
+				t.Fatalf("Statements() error = %v", err)
+			}
+			if want := []Statement{tt.want}; !reflect.DeepEqual(statements, want) {
+				t.Errorf("Statements() = %+v, want %+v", statements, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+package sink
+
+import "github.com/IEatCodeDaily/data-pipe/internal/pgconn"
+
+// ConnectionStringProvider returns the connection string to open new connections with
+type ConnectionStringProvider = pgconn.ConnectionStringProvider
+
+// SetConnectionStringProvider makes the sink ask the provider for a fresh
+// connection string whenever it opens a database connection, so rotating
+// credentials (Vault leases, IAM tokens) are used without a restart
+func (p *PostgreSQLSink) SetConnectionStringProvider(provider ConnectionStringProvider) {
+	p.connProvider = provider
+}
@@ -0,0 +1,125 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// EventLogColumns names the columns of an event log table. Empty names take
+// the defaults event_id, event_time, operation, document_key and payload.
+type EventLogColumns struct {
+	ID        string // TEXT; the event's ID (change stream resume token, or document _id during an initial sync)
+	Timestamp string // TIMESTAMPTZ; time of the change at the source
+	Operation string // TEXT; insert, update, replace or delete
+	Key       string // JSONB; the key columns of the changed row
+	Payload   string // JSONB; the document as written, NULL for deletes
+}
+
+// SetEventLogMode makes the sink append every event as a new row of an event
+// log instead of writing the row's state. Rows are inserted with ON CONFLICT
+// DO NOTHING, so a unique index on the ID and time columns makes redelivered
+// events idempotent. Call it after SetKeyColumns and SetMetadataColumns.
+func (p *PostgreSQLSink) SetEventLogMode(columns EventLogColumns) error {
+	if columns.ID == "" {
+		columns.ID = "event_id"
+	}
+	if columns.Timestamp == "" {
+		columns.Timestamp = "event_time"
+	}
+	if columns.Operation == "" {
+		columns.Operation = "operation"
+	}
+	if columns.Key == "" {
+		columns.Key = "document_key"
+	}
+	if columns.Payload == "" {
+		columns.Payload = "payload"
+	}
+	seen := make(map[string]bool)
+	for _, name := range []string{columns.ID, columns.Timestamp, columns.Operation, columns.Key, columns.Payload} {
+		if !validTableName.MatchString(name) || p.metadata.has(name) {
+			return fmt.Errorf("invalid event log column name: %s", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("event log column %s is configured twice", name)
+		}
+		seen[name] = true
+	}
+	if p.metadata.Deleted != "" || p.metadata.RowHash != "" {
+		return fmt.Errorf("append mode cannot be combined with a deleted or row hash column")
+	}
+	p.writeMode = WriteAppend
+	p.eventLog = columns
+	return nil
+}
+
+// appendEvent inserts an event as a new row of the event log
+func (p *PostgreSQLSink) appendEvent(ctx context.Context, tx execer, event pipeline.Event) error {
+	table, err := p.eventTable(event)
+	if err != nil {
+		return err
+	}
+	key, err := p.eventKey(event)
+	if err != nil {
+		return err
+	}
+	encodedKey, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode key of event %s: %w", event.ID, err)
+	}
+
+	payload, err := p.eventPayload(event)
+	if err != nil {
+		return err
+	}
+
+	var changedAt interface{}
+	if !event.Timestamp.IsZero() {
+		changedAt = event.Timestamp
+	}
+	columns := []string{p.eventLog.ID, p.eventLog.Timestamp, p.eventLog.Operation, p.eventLog.Key, p.eventLog.Payload}
+	values := []interface{}{event.ID, changedAt, event.Operation, string(encodedKey), payload}
+	metaColumns, metaValues := p.metadata.values(event, false, p.clock.Now())
+	columns, values = append(columns, metaColumns...), append(values, metaValues...)
+
+	placeholders := make([]string, len(columns))
+	for i := range columns {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	query := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s) ON CONFLICT DO NOTHING",
+		table,
+		strings.Join(columns, ", "),
+		strings.Join(placeholders, ", "),
+	)
+	_, err = tx.ExecContext(ctx, query, values...)
+	return err
+}
+
+// eventPayload returns the JSON payload logged for an event: its data as
+// allowed by the column policy, or nil for deletes, which carry only the key
+func (p *PostgreSQLSink) eventPayload(event pipeline.Event) (interface{}, error) {
+	if event.Operation == "delete" {
+		return nil, nil
+	}
+	data := make(map[string]interface{}, len(event.Data))
+	for column, value := range event.Data {
+		if p.metadata.has(column) {
+			continue
+		}
+		if permitted, err := p.permitColumn(column); err != nil {
+			return nil, err
+		} else if permitted {
+			data[column] = value
+		}
+	}
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode payload of event %s: %w", event.ID, err)
+	}
+	return string(encoded), nil
+}
@@ -0,0 +1,52 @@
+package sink
+
+import (
+	"testing"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// TestSetEventLogMode tests event log column defaults and validation
+func TestSetEventLogMode(t *testing.T) {
+	s := NewPostgreSQLSink("", "events", nil)
+	if err := s.SetEventLogMode(EventLogColumns{ID: "id", Key: "id"}); err == nil {
+		t.Error("expected error for an event log column configured twice")
+	}
+	if err := s.SetEventLogMode(EventLogColumns{Payload: "doc"}); err != nil {
+		t.Fatalf("SetEventLogMode() error = %v", err)
+	}
+	want := EventLogColumns{ID: "event_id", Timestamp: "event_time", Operation: "operation", Key: "document_key", Payload: "doc"}
+	if s.writeMode != WriteAppend || s.eventLog != want {
+		t.Errorf("expected append mode with %+v, got %s with %+v", want, s.writeMode, s.eventLog)
+	}
+
+	s = NewPostgreSQLSink("", "events", nil)
+	if err := s.SetMetadataColumns(MetadataColumns{RowHash: "_hash"}); err != nil {
+		t.Fatalf("SetMetadataColumns() error = %v", err)
+	}
+	if err := s.SetEventLogMode(EventLogColumns{}); err == nil {
+		t.Error("expected error combining append mode with a row hash column")
+	}
+}
+
+// TestEventPayload tests that the logged payload follows the column policy and is empty for deletes
+func TestEventPayload(t *testing.T) {
+	s := NewPostgreSQLSink("", "events", nil)
+	if err := s.SetColumnPolicy(ColumnPolicy{Denied: []string{"ssn"}}); err != nil {
+		t.Fatalf("SetColumnPolicy() error = %v", err)
+	}
+
+	event := pipeline.Event{Operation: "insert", Data: map[string]interface{}{"_id": "1", "name": "a", "ssn": "123"}}
+	payload, err := s.eventPayload(event)
+	if err != nil {
+		t.Fatalf("eventPayload() error = %v", err)
+	}
+	if payload != `{"_id":"1","name":"a"}` {
+		t.Errorf("unexpected payload %v", payload)
+	}
+
+	event.Operation = "delete"
+	if payload, _ := s.eventPayload(event); payload != nil {
+		t.Errorf("expected no payload for a delete, got %v", payload)
+	}
+}
@@ -0,0 +1,310 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"github.com/lib/pq"
+)
+
+// ForeignKeyDeferral holds back events that violate a foreign key, typically a
+// child row whose parent has not been synced yet, and retries them after later
+// batches instead of failing their batch
+type ForeignKeyDeferral struct {
+	MaxEvents     int           // events held at once; once full the oldest is dead-lettered
+	MaxAttempts   int           // retries before a held event is dead-lettered (default 10)
+	RetryInterval time.Duration // how often held events are retried while no batch is written (default 1s)
+}
+
+// foreignKeyViolation is the SQLSTATE of a foreign key violation
+const foreignKeyViolation = "23503"
+
+// isForeignKeyViolation reports whether an error is a foreign key violation
+func isForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation
+}
+
+// SetForeignKeyDeferral enables holding back events that violate a foreign key.
+// Held events are kept in memory: they are dead-lettered when they run out of
+// attempts, when the held set is full, and when the sink stops writing. With
+// transactional checkpoints the checkpoint committed with a batch stays before
+// the oldest held event instead, so events still held when the sink stops or
+// the process dies are replayed on the next run.
+func (p *PostgreSQLSink) SetForeignKeyDeferral(config ForeignKeyDeferral) error {
+	if config.MaxEvents <= 0 {
+		return fmt.Errorf("foreign key deferral requires a positive maximum of held events")
+	}
+	if config.MaxAttempts < 0 || config.RetryInterval < 0 {
+		return fmt.Errorf("foreign key deferral attempts and retry interval must not be negative")
+	}
+	if config.MaxAttempts == 0 {
+		config.MaxAttempts = 10
+	}
+	if config.RetryInterval == 0 {
+		config.RetryInterval = time.Second
+	}
+	p.deferred = &deferredEvents{config: config, keys: make(map[string]int)}
+	return nil
+}
+
+// deferredEvent is an event held back by foreign key deferral
+type deferredEvent struct {
+	event    pipeline.Event
+	key      string // table and key of the row, see rowKey
+	attempts int
+	cause    error // last foreign key violation
+}
+
+// deferredEvents is the set of events held back by foreign key deferral,
+// shared by concurrent writers. A row's key stays registered while any of its
+// events is held or being retried, so newer changes of the row are held
+// behind it and rows are never written out of order.
+type deferredEvents struct {
+	config ForeignKeyDeferral
+
+	mu      sync.Mutex
+	held    []deferredEvent // oldest first
+	expired []deferredEvent // pushed out of a full set, awaiting rejection
+	keys    map[string]int  // held or retrying events per row key
+}
+
+// holds reports whether an event of a row is held or being retried
+func (d *deferredEvents) holds(key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.keys[key] > 0
+}
+
+// holding reports whether any events are held, being retried or awaiting
+// rejection, so the checkpoint must not move past them
+func (d *deferredEvents) holding() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.keys) > 0
+}
+
+// pending reports whether any events are held or awaiting rejection
+func (d *deferredEvents) pending() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.held) > 0 || len(d.expired) > 0
+}
+
+// hold adds events to the set, pushing the oldest out once it is full
+func (d *deferredEvents) hold(events []deferredEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, e := range events {
+		d.keys[e.key]++
+	}
+	d.held = append(d.held, events...)
+	if over := len(d.held) - d.config.MaxEvents; over > 0 {
+		d.expired = append(d.expired, d.held[:over]...)
+		d.held = append([]deferredEvent{}, d.held[over:]...)
+	}
+}
+
+// take removes every held and expired event for a retry, keeping their row keys registered
+func (d *deferredEvents) take() (held, expired []deferredEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	held, expired = d.held, d.expired
+	d.held, d.expired = nil, nil
+	return held, expired
+}
+
+// putBack returns events that are still held ahead of any held since they were taken
+func (d *deferredEvents) putBack(events []deferredEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.held = append(append([]deferredEvent{}, events...), d.held...)
+}
+
+// done unregisters the row keys of events that were written or rejected
+func (d *deferredEvents) done(events []deferredEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, e := range events {
+		if d.keys[e.key]--; d.keys[e.key] <= 0 {
+			delete(d.keys, e.key)
+		}
+	}
+}
+
+// heldBatch collects the events of a batch held back by foreign key deferral
+type heldBatch struct {
+	events []deferredEvent
+	keys   map[string]bool
+	first  int // index in the batch of the first held event
+}
+
+// add holds back the i-th event of the batch. With pooling the batch's data
+// maps are released once it is written, so a held event keeps its own copy.
+func (h *heldBatch) add(i int, event pipeline.Event, key string, cause error) {
+	if pipeline.PoolingEnabled() {
+		event.Data = maps.Clone(event.Data)
+	}
+	if h.keys == nil {
+		h.keys = make(map[string]bool)
+		h.first = i
+	}
+	h.keys[key] = true
+	h.events = append(h.events, deferredEvent{event: event, key: key, cause: cause})
+}
+
+// rowKey identifies the row an event writes. Events without a complete key
+// share one, so they are held in order with each other.
+func (p *PostgreSQLSink) rowKey(event pipeline.Event) string {
+	table, _ := p.eventTable(event)
+	key, err := p.eventKey(event)
+	if err != nil {
+		return table
+	}
+	return fmt.Sprintf("%s%v", table, key)
+}
+
+// writeOrHold writes an event of a batch, or holds it back if an earlier change
+// of its row is held or, when writing with savepoints, if it violates a foreign key
+func (p *PostgreSQLSink) writeOrHold(ctx context.Context, tx *sql.Tx, i int, event pipeline.Event, savepoints bool, held *heldBatch) error {
+	key := p.rowKey(event)
+	if held.keys[key] || p.deferred.holds(key) {
+		held.add(i, event, key, fmt.Errorf("an earlier change of the row is held back"))
+		return nil
+	}
+	if !savepoints {
+		return p.writeEvent(ctx, tx, event)
+	}
+	err := p.writeEventSavepoint(ctx, tx, event)
+	if isForeignKeyViolation(err) {
+		held.add(i, event, key, err)
+		return nil
+	}
+	return err
+}
+
+// writeEventSavepoint writes an event within a savepoint, so that a failure
+// rolls back only this event and leaves the transaction usable
+func (p *PostgreSQLSink) writeEventSavepoint(ctx context.Context, tx *sql.Tx, event pipeline.Event) error {
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT datapipe_event"); err != nil {
+		return fmt.Errorf("failed to create savepoint: %w", err)
+	}
+	if err := p.writeEvent(ctx, tx, event); err != nil {
+		if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT datapipe_event"); rbErr != nil {
+			return fmt.Errorf("failed to roll back to savepoint: %w", rbErr)
+		}
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT datapipe_event"); err != nil {
+		return fmt.Errorf("failed to release savepoint: %w", err)
+	}
+	return nil
+}
+
+// retryDeferred writes the held events again in one transaction, oldest first.
+// Events that still violate a foreign key stay held until they run out of
+// attempts; events that fail otherwise, and events pushed out of a full set,
+// are rejected.
+func (p *PostgreSQLSink) retryDeferred(ctx context.Context) error {
+	if !p.deferred.pending() {
+		return nil
+	}
+	held, expired := p.deferred.take()
+	var written, still, rejected []deferredEvent
+
+	err := func() error {
+		if len(held) == 0 {
+			return nil
+		}
+		ctx, tx, done, err := p.beginBatch(ctx)
+		if err != nil {
+			return err
+		}
+		defer done()
+
+		blocked := make(map[string]bool) // rows with an earlier change still held
+		for _, e := range held {
+			if blocked[e.key] {
+				still = append(still, e)
+				continue
+			}
+			err := p.writeEventSavepoint(ctx, tx, e.event)
+			switch {
+			case err == nil:
+				written = append(written, e)
+			case isTransientError(err):
+				return err
+			case isForeignKeyViolation(err):
+				e.attempts++
+				e.cause = err
+				if e.attempts >= p.deferred.config.MaxAttempts {
+					rejected = append(rejected, e)
+				} else {
+					still = append(still, e)
+					blocked[e.key] = true
+				}
+			default:
+				e.cause = err
+				rejected = append(rejected, e)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit transaction: %w", err)
+		}
+		return nil
+	}()
+	if err != nil {
+		// Nothing was written; keep everything for the next retry
+		p.deferred.putBack(held)
+		return errors.Join(
+			fmt.Errorf("failed to retry %d held events: %w", len(held), err),
+			p.rejectDeferred(ctx, expired, "the held events limit was reached"),
+		)
+	}
+
+	p.deferred.putBack(still)
+	p.deferred.done(written)
+	if len(written) > 0 {
+		p.logger.Printf("Wrote %d events held back for a foreign key, %d still held", len(written), len(still))
+	}
+	return errors.Join(
+		p.rejectDeferred(ctx, rejected, fmt.Sprintf("the foreign key was still violated after %d attempts", p.deferred.config.MaxAttempts)),
+		p.rejectDeferred(ctx, expired, "the held events limit was reached"),
+	)
+}
+
+// rejectDeferred dead-letters held events that will not be retried again
+func (p *PostgreSQLSink) rejectDeferred(ctx context.Context, events []deferredEvent, why string) error {
+	defer p.deferred.done(events)
+	var errs []error
+	for _, e := range events {
+		if err := p.rejectEvent(ctx, e.event, fmt.Errorf("held back for a foreign key until %s: %w", why, e.cause)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// releaseDeferred retries the held events a last time when the sink stops
+// writing. Those that still cannot be written are left to be replayed on the
+// next run when the checkpoint is committed with the batches, as it has not
+// moved past them, and dead-lettered otherwise.
+func (p *PostgreSQLSink) releaseDeferred(ctx context.Context) error {
+	err := p.retryDeferred(ctx)
+	held, expired := p.deferred.take()
+	if p.CheckpointsInTransaction() && len(held) > 0 {
+		p.logger.Printf("Stopping with %d events held back for a foreign key, they are replayed on the next run", len(held))
+		p.deferred.done(held)
+		held = nil
+	}
+	return errors.Join(err,
+		p.rejectDeferred(ctx, held, "the sink stopped writing"),
+		p.rejectDeferred(ctx, expired, "the held events limit was reached"),
+	)
+}
@@ -0,0 +1,122 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"github.com/lib/pq"
+)
+
+// TestSetForeignKeyDeferral tests deferral validation and defaults
+func TestSetForeignKeyDeferral(t *testing.T) {
+	s := NewPostgreSQLSink("", "orders", nil)
+	if err := s.SetForeignKeyDeferral(ForeignKeyDeferral{}); err == nil {
+		t.Error("expected error without a maximum of held events")
+	}
+	if err := s.SetForeignKeyDeferral(ForeignKeyDeferral{MaxEvents: 10, MaxAttempts: -1}); err == nil {
+		t.Error("expected error for negative attempts")
+	}
+	if err := s.SetForeignKeyDeferral(ForeignKeyDeferral{MaxEvents: 10}); err != nil {
+		t.Fatalf("SetForeignKeyDeferral() error = %v", err)
+	}
+	if config := s.deferred.config; config.MaxAttempts != 10 || config.RetryInterval != time.Second {
+		t.Errorf("expected default attempts and interval, got %+v", config)
+	}
+}
+
+// TestIsForeignKeyViolation tests foreign key violation detection
+func TestIsForeignKeyViolation(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "foreign key violation", err: fmt.Errorf("failed to write event: %w", &pq.Error{Code: "23503"}), want: true},
+		{name: "unique violation", err: &pq.Error{Code: "23505"}, want: false},
+		{name: "other error", err: fmt.Errorf("boom"), want: false},
+		{name: "nil", err: nil, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isForeignKeyViolation(tt.err); got != tt.want {
+				t.Errorf("isForeignKeyViolation() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRowKey tests that row keys tell rows of different tables apart
+func TestRowKey(t *testing.T) {
+	s := NewPostgreSQLSink("", "orders", nil)
+	a := s.rowKey(pipeline.Event{Data: map[string]interface{}{"_id": "1", "total": 5}})
+	b := s.rowKey(pipeline.Event{Data: map[string]interface{}{"_id": "1"}})
+	c := s.rowKey(pipeline.Event{Data: map[string]interface{}{"_id": "1"}, Destination: "lines"})
+	d := s.rowKey(pipeline.Event{Operation: "delete", Key: map[string]interface{}{"_id": "1"}})
+	if a != b || a != d {
+		t.Errorf("expected one key for a row, got %q, %q and %q", a, b, d)
+	}
+	if a == c {
+		t.Errorf("expected different keys for different tables, got %q", a)
+	}
+}
+
+// TestDeferredEvents tests holding, retrying and releasing deferred events
+func TestDeferredEvents(t *testing.T) {
+	d := &deferredEvents{config: ForeignKeyDeferral{MaxEvents: 2}, keys: make(map[string]int)}
+	d.hold([]deferredEvent{{key: "a"}, {key: "b"}, {key: "a"}})
+	if !d.holds("a") || !d.holds("b") || d.holds("c") {
+		t.Errorf("unexpected held keys %v", d.keys)
+	}
+
+	held, expired := d.take()
+	if len(held) != 2 || len(expired) != 1 || expired[0].key != "a" {
+		t.Fatalf("expected the oldest event pushed out of a full set, got held %+v, expired %+v", held, expired)
+	}
+	if !d.holds("a") || d.pending() {
+		t.Error("expected keys kept and nothing pending while events are retried")
+	}
+
+	d.hold([]deferredEvent{{key: "c"}})
+	d.putBack(held[1:])
+	d.done(append(expired, held[0]))
+	if d.holds("b") || !d.holds("a") || !d.holds("c") {
+		t.Errorf("unexpected held keys after retry %v", d.keys)
+	}
+	if held, _ := d.take(); len(held) != 2 || held[0].key != "a" || held[1].key != "c" {
+		t.Errorf("expected retried events ahead of newer ones, got %+v", held)
+	}
+}
+
+// TestReleaseDeferredExpired tests that events pushed out of a full set are dead-lettered
+func TestReleaseDeferredExpired(t *testing.T) {
+	s := NewPostgreSQLSink("", "orders", nil)
+	if err := s.SetForeignKeyDeferral(ForeignKeyDeferral{MaxEvents: 1}); err != nil {
+		t.Fatalf("SetForeignKeyDeferral() error = %v", err)
+	}
+	dlq := &recordingDLQ{}
+	s.SetDeadLetterQueue(dlq)
+
+	cause := &pq.Error{Code: "23503", Message: "violates foreign key constraint"}
+	s.deferred.hold([]deferredEvent{
+		{event: pipeline.Event{ID: "1"}, key: "a", cause: cause},
+		{event: pipeline.Event{ID: "2"}, key: "b", cause: cause},
+	})
+	// Keep only the event pushed out of the full set, since retrying needs a database
+	held, expired := s.deferred.take()
+	s.deferred.done(held)
+	s.deferred.expired = expired
+
+	if err := s.releaseDeferred(context.Background()); err != nil {
+		t.Fatalf("releaseDeferred() error = %v", err)
+	}
+	if len(dlq.events) != 1 || dlq.events[0].ID != "1" || !strings.Contains(dlq.reasons[0], "limit was reached") {
+		t.Errorf("expected event 1 dead-lettered for the limit, got %+v %v", dlq.events, dlq.reasons)
+	}
+	if s.deferred.holds("a") || s.deferred.pending() {
+		t.Error("expected nothing held after release")
+	}
+}
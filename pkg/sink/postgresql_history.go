@@ -0,0 +1,112 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// HistoryColumns names the version columns of a history table. Empty names
+// take the defaults valid_from, valid_to and is_current.
+type HistoryColumns struct {
+	ValidFrom string // TIMESTAMPTZ; time of the change that created the version
+	ValidTo   string // TIMESTAMPTZ; time of the change that closed the version, NULL while current
+	Current   string // BOOLEAN; true for the current version of each key
+}
+
+// SetHistoryMode makes the sink keep every version of a row instead of
+// overwriting it. The table needs a unique index on the key columns limited
+// to current versions, e.g. CREATE UNIQUE INDEX ON users (_id) WHERE is_current.
+// Call it after SetKeyColumns and SetMetadataColumns.
+func (p *PostgreSQLSink) SetHistoryMode(columns HistoryColumns) error {
+	if columns.ValidFrom == "" {
+		columns.ValidFrom = "valid_from"
+	}
+	if columns.ValidTo == "" {
+		columns.ValidTo = "valid_to"
+	}
+	if columns.Current == "" {
+		columns.Current = "is_current"
+	}
+	seen := make(map[string]bool)
+	for _, name := range []string{columns.ValidFrom, columns.ValidTo, columns.Current} {
+		if !validTableName.MatchString(name) || p.isKeyColumn(name) || p.metadata.has(name) {
+			return fmt.Errorf("invalid history column name: %s", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("history column %s is configured twice", name)
+		}
+		seen[name] = true
+	}
+	if p.metadata.Deleted != "" {
+		return fmt.Errorf("history mode cannot be combined with a deleted column; deletes close the current version")
+	}
+	p.writeMode = WriteHistory
+	p.history = columns
+	return nil
+}
+
+// writeVersion closes the current version of an event's row and, unless the
+// event is a delete, inserts a new current version valid from the time of the
+// change. A version is only closed by a later change, so a redelivered or
+// out-of-order event leaves the current version in place and its insert hits
+// the unique index on current versions and does nothing. With a row hash
+// column, a change that leaves the data as it is keeps the current version.
+func (p *PostgreSQLSink) writeVersion(ctx context.Context, tx execer, event pipeline.Event) error {
+	deleted := event.Operation == "delete"
+	var columns []string
+	var values []interface{}
+	if !deleted {
+		var err error
+		if columns, values, err = p.rowColumns(event, false); err != nil || len(columns) == 0 {
+			return err
+		}
+	}
+
+	table, err := p.eventTable(event)
+	if err != nil {
+		return err
+	}
+	key, err := p.eventKey(event)
+	if err != nil {
+		return err
+	}
+	changedAt := event.Timestamp
+	if changedAt.IsZero() {
+		changedAt = p.clock.Now()
+	}
+
+	match, args := p.keyCondition(key)
+	args = append(args, changedAt)
+	closeQuery := fmt.Sprintf("UPDATE %s SET %s = $%d, %s = FALSE WHERE %s AND %s AND %s < $%d",
+		table, p.history.ValidTo, len(args), p.history.Current, match, p.history.Current, p.history.ValidFrom, len(args))
+	if p.metadata.RowHash != "" && !deleted {
+		args = append(args, values[len(values)-1])
+		closeQuery += fmt.Sprintf(" AND %s IS DISTINCT FROM $%d", p.metadata.RowHash, len(args))
+	}
+	if _, err := tx.ExecContext(ctx, closeQuery, args...); err != nil {
+		return err
+	}
+	if deleted {
+		return nil
+	}
+
+	columns = append(columns, p.history.ValidFrom, p.history.Current)
+	values = append(values, changedAt, true)
+	placeholders := make([]string, len(columns))
+	for i := range columns {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	insertQuery := fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) WHERE %s DO NOTHING",
+		table,
+		strings.Join(columns, ", "),
+		strings.Join(placeholders, ", "),
+		strings.Join(p.keyColumns, ", "),
+		p.history.Current,
+	)
+	_, err = tx.ExecContext(ctx, insertQuery, values...)
+	return err
+}
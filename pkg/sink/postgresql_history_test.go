@@ -0,0 +1,29 @@
+package sink
+
+import "testing"
+
+// TestSetHistoryMode tests history column defaults and validation
+func TestSetHistoryMode(t *testing.T) {
+	s := NewPostgreSQLSink("", "users", nil)
+	if err := s.SetHistoryMode(HistoryColumns{ValidTo: "_id"}); err == nil {
+		t.Error("expected error for a history column that is a key column")
+	}
+	if err := s.SetHistoryMode(HistoryColumns{ValidFrom: "changed", ValidTo: "changed"}); err == nil {
+		t.Error("expected error for a history column configured twice")
+	}
+	if err := s.SetHistoryMode(HistoryColumns{ValidFrom: "started"}); err != nil {
+		t.Fatalf("SetHistoryMode() error = %v", err)
+	}
+	want := HistoryColumns{ValidFrom: "started", ValidTo: "valid_to", Current: "is_current"}
+	if s.writeMode != WriteHistory || s.history != want {
+		t.Errorf("expected history mode with %+v, got %s with %+v", want, s.writeMode, s.history)
+	}
+
+	s = NewPostgreSQLSink("", "users", nil)
+	if err := s.SetMetadataColumns(MetadataColumns{Deleted: "_deleted"}); err != nil {
+		t.Fatalf("SetMetadataColumns() error = %v", err)
+	}
+	if err := s.SetHistoryMode(HistoryColumns{}); err == nil {
+		t.Error("expected error combining history mode with a deleted column")
+	}
+}
@@ -0,0 +1,193 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// IndexDefinition declares an index the sink maintains on its table
+type IndexDefinition struct {
+	Name    string   `json:"name"`    // Default: <table>_<columns>_idx
+	Columns []string `json:"columns"` // Indexed columns, in order
+	Unique  bool     `json:"unique"`
+	Where   string   `json:"where"` // Predicate of a partial index, e.g. "deleted = false"
+}
+
+// existingIndex is an index found on the table
+type existingIndex struct {
+	name    string
+	unique  bool
+	partial bool
+	columns []string
+}
+
+// SetIndexes declares indexes the sink creates when they are missing. They
+// are checked on every Connect: an index with a declared name but a different
+// definition fails the connection, and a missing one is created unless
+// DeferIndexes was called.
+func (p *PostgreSQLSink) SetIndexes(indexes []IndexDefinition) error {
+	seen := make(map[string]bool, len(indexes))
+	for i, index := range indexes {
+		if len(index.Columns) == 0 {
+			return fmt.Errorf("index %d has no columns", i+1)
+		}
+		for _, column := range index.Columns {
+			if !validTableName.MatchString(column) {
+				return fmt.Errorf("invalid index column name: %s", column)
+			}
+		}
+		if index.Name == "" {
+			index.Name = fmt.Sprintf("%s_%s_idx", p.table, strings.Join(index.Columns, "_"))
+		}
+		if !validTableName.MatchString(index.Name) {
+			return fmt.Errorf("invalid index name: %s (set a name of at most 63 characters)", index.Name)
+		}
+		if seen[index.Name] {
+			return fmt.Errorf("duplicate index name: %s", index.Name)
+		}
+		seen[index.Name] = true
+		if strings.Contains(index.Where, ";") {
+			return fmt.Errorf("invalid predicate for index %s: %s", index.Name, index.Where)
+		}
+		indexes[i] = index
+	}
+	p.indexes = indexes
+	return nil
+}
+
+// DeferIndexes leaves missing indexes to CreateIndexes, so an initial sync
+// loads the table without maintaining them
+func (p *PostgreSQLSink) DeferIndexes() {
+	p.indexesDeferred = true
+}
+
+// CreateIndexes creates the declared indexes that are missing, typically once
+// an initial sync has completed, and stops deferring them
+func (p *PostgreSQLSink) CreateIndexes(ctx context.Context) error {
+	p.indexesDeferred = false
+	if len(p.indexes) == 0 {
+		return nil
+	}
+	missing, err := p.missingIndexes(ctx)
+	if err != nil {
+		return err
+	}
+	for _, index := range missing {
+		started := time.Now()
+		if _, err := p.db.ExecContext(ctx, p.createIndexStatement(index)); err != nil {
+			return fmt.Errorf("failed to create index %s on %s: %w", index.Name, p.table, err)
+		}
+		p.logger.Printf("Created index %s on %s in %s", index.Name, p.table, time.Since(started).Round(time.Millisecond))
+	}
+	return nil
+}
+
+// verifyIndexes checks the declared indexes on Connect, creating missing ones
+// unless they are deferred
+func (p *PostgreSQLSink) verifyIndexes(ctx context.Context) error {
+	if !p.indexesDeferred {
+		return p.CreateIndexes(ctx)
+	}
+	missing, err := p.missingIndexes(ctx)
+	if err != nil {
+		return err
+	}
+	for _, index := range missing {
+		p.logger.Printf("Index %s on %s is missing and will be created after the initial sync", index.Name, p.table)
+	}
+	return nil
+}
+
+// missingIndexes returns the declared indexes the table does not have
+func (p *PostgreSQLSink) missingIndexes(ctx context.Context) ([]IndexDefinition, error) {
+	existing, err := p.loadIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return planIndexes(p.indexes, existing)
+}
+
+// loadIndexes reads the indexes on the table
+func (p *PostgreSQLSink) loadIndexes(ctx context.Context) ([]existingIndex, error) {
+	rows, err := p.db.QueryContext(ctx, `SELECT c.relname, i.indisunique, i.indpred IS NOT NULL,
+		ARRAY(SELECT a.attname FROM unnest(i.indkey::int2[]) WITH ORDINALITY AS k(attnum, n)
+			JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = k.attnum ORDER BY k.n)
+		FROM pg_index i JOIN pg_class c ON c.oid = i.indexrelid WHERE i.indrelid = $1::regclass`, p.table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read indexes of %s: %w", p.table, err)
+	}
+	defer rows.Close()
+
+	var indexes []existingIndex
+	for rows.Next() {
+		var index existingIndex
+		if err := rows.Scan(&index.name, &index.unique, &index.partial, pq.Array(&index.columns)); err != nil {
+			return nil, fmt.Errorf("failed to read indexes of %s: %w", p.table, err)
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, rows.Err()
+}
+
+// planIndexes compares the declared indexes with the existing ones. An index
+// with a declared name must match its declaration; an index of the same shape
+// under another name, such as one copied by a staged refresh, also satisfies it.
+func planIndexes(declared []IndexDefinition, existing []existingIndex) ([]IndexDefinition, error) {
+	var missing []IndexDefinition
+	for _, index := range declared {
+		found := false
+		for _, e := range existing {
+			if e.name == index.Name {
+				if !index.matches(e) {
+					return nil, fmt.Errorf("index %s differs from its declaration (unique %v, partial %v, columns %v); drop it to have it recreated",
+						index.Name, e.unique, e.partial, e.columns)
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			for _, e := range existing {
+				if index.matches(e) {
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			missing = append(missing, index)
+		}
+	}
+	return missing, nil
+}
+
+// matches reports whether an existing index has the declared shape. The
+// predicate of a partial index is not compared, as PostgreSQL rewrites it.
+func (d IndexDefinition) matches(e existingIndex) bool {
+	if e.unique != d.Unique || e.partial != (d.Where != "") || len(e.columns) != len(d.Columns) {
+		return false
+	}
+	for i, column := range d.Columns {
+		if e.columns[i] != column {
+			return false
+		}
+	}
+	return true
+}
+
+// createIndexStatement builds the statement creating a declared index
+func (p *PostgreSQLSink) createIndexStatement(index IndexDefinition) string {
+	unique := ""
+	if index.Unique {
+		unique = "UNIQUE "
+	}
+	query := fmt.Sprintf("CREATE %sINDEX IF NOT EXISTS %s ON %s (%s)", unique, index.Name, p.table, strings.Join(index.Columns, ", "))
+	if index.Where != "" {
+		query += " WHERE " + index.Where
+	}
+	return query
+}
@@ -0,0 +1,77 @@
+package sink
+
+import "testing"
+
+// TestSetIndexes tests index name defaults and validation
+func TestSetIndexes(t *testing.T) {
+	s := NewPostgreSQLSink("", "users", nil)
+	tests := []struct {
+		name    string
+		indexes []IndexDefinition
+	}{
+		{name: "no columns", indexes: []IndexDefinition{{Name: "users_idx"}}},
+		{name: "invalid column", indexes: []IndexDefinition{{Columns: []string{"email; DROP TABLE users"}}}},
+		{name: "duplicate name", indexes: []IndexDefinition{{Columns: []string{"email"}}, {Columns: []string{"email"}, Unique: true}}},
+		{name: "invalid predicate", indexes: []IndexDefinition{{Columns: []string{"email"}, Where: "true; DROP TABLE users"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.SetIndexes(tt.indexes); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+
+	if err := s.SetIndexes([]IndexDefinition{{Columns: []string{"org_id", "email"}, Unique: true, Where: "deleted = false"}}); err != nil {
+		t.Fatalf("SetIndexes() error = %v", err)
+	}
+	if s.indexes[0].Name != "users_org_id_email_idx" {
+		t.Errorf("unexpected default name %s", s.indexes[0].Name)
+	}
+	want := "CREATE UNIQUE INDEX IF NOT EXISTS users_org_id_email_idx ON users (org_id, email) WHERE deleted = false"
+	if got := s.createIndexStatement(s.indexes[0]); got != want {
+		t.Errorf("createIndexStatement() = %q, want %q", got, want)
+	}
+}
+
+// TestPlanIndexes tests matching declared indexes against the existing ones
+func TestPlanIndexes(t *testing.T) {
+	declared := []IndexDefinition{
+		{Name: "users_email_idx", Columns: []string{"email"}, Unique: true},
+		{Name: "users_created_idx", Columns: []string{"created_at"}},
+		{Name: "users_active_idx", Columns: []string{"org_id"}, Where: "deleted = false"},
+	}
+	tests := []struct {
+		name     string
+		existing []existingIndex
+		missing  int
+		wantErr  bool
+	}{
+		{name: "none exist", missing: 3},
+		{name: "all exist", existing: []existingIndex{
+			{name: "users_email_idx", unique: true, columns: []string{"email"}},
+			{name: "users_created_idx", columns: []string{"created_at"}},
+			{name: "users_active_idx", partial: true, columns: []string{"org_id"}},
+		}},
+		{name: "same shape under another name", existing: []existingIndex{
+			{name: "users_new_email_idx", unique: true, columns: []string{"email"}},
+		}, missing: 2},
+		{name: "partial index does not satisfy a full one", existing: []existingIndex{
+			{name: "users_created_at_idx", partial: true, columns: []string{"created_at"}},
+		}, missing: 3},
+		{name: "declared name with another definition", existing: []existingIndex{
+			{name: "users_email_idx", columns: []string{"email"}},
+		}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			missing, err := planIndexes(declared, tt.existing)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("planIndexes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(missing) != tt.missing {
+				t.Errorf("expected %d missing indexes, got %+v", tt.missing, missing)
+			}
+		})
+	}
+}
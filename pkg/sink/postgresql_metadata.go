@@ -0,0 +1,118 @@
+package sink
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// MetadataColumns names columns the sink fills in itself on every write,
+// without a transformer mapping. Empty names are not written.
+type MetadataColumns struct {
+	SyncedAt        string   // Time the sink wrote the row
+	SourceTimestamp string   // Time of the change at the source (Event.Timestamp)
+	IngestedAt      string   // Time the pipeline read the change from the source (Event.IngestedAt)
+	Operation       string   // Operation of the last change: insert, update, replace or delete
+	Deleted         string   // Boolean; deletes keep the row and set it to true (soft delete)
+	RowHash         string   // Hash of the written data; updates that would not change it are skipped
+	Values          []string // Event.Metadata keys written to TEXT columns of the same name, NULL when unset
+}
+
+// SetMetadataColumns sets the metadata columns filled in on every write
+func (p *PostgreSQLSink) SetMetadataColumns(columns MetadataColumns) error {
+	seen := make(map[string]bool)
+	names := append([]string{columns.SyncedAt, columns.SourceTimestamp, columns.IngestedAt, columns.Operation, columns.Deleted, columns.RowHash}, columns.Values...)
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if !validTableName.MatchString(name) || name == "_id" {
+			return fmt.Errorf("invalid metadata column name: %s", name)
+		}
+		if seen[name] {
+			return fmt.Errorf("metadata column %s is configured twice", name)
+		}
+		seen[name] = true
+	}
+	p.metadata = columns
+	return nil
+}
+
+// has reports whether a column is one of the metadata columns
+func (m MetadataColumns) has(column string) bool {
+	if column == m.SyncedAt || column == m.SourceTimestamp || column == m.IngestedAt || column == m.Operation || column == m.Deleted || column == m.RowHash {
+		return true
+	}
+	for _, value := range m.Values {
+		if column == value {
+			return true
+		}
+	}
+	return false
+}
+
+// values returns the metadata columns and their values for an event
+func (m MetadataColumns) values(event pipeline.Event, deleted bool, now time.Time) ([]string, []interface{}) {
+	var columns []string
+	var values []interface{}
+	if m.SyncedAt != "" {
+		columns = append(columns, m.SyncedAt)
+		values = append(values, now)
+	}
+	if m.SourceTimestamp != "" {
+		columns = append(columns, m.SourceTimestamp)
+		if event.Timestamp.IsZero() {
+			values = append(values, nil)
+		} else {
+			values = append(values, event.Timestamp)
+		}
+	}
+	if m.IngestedAt != "" {
+		columns = append(columns, m.IngestedAt)
+		if event.IngestedAt.IsZero() {
+			values = append(values, nil)
+		} else {
+			values = append(values, event.IngestedAt)
+		}
+	}
+	if m.Operation != "" {
+		columns = append(columns, m.Operation)
+		values = append(values, event.Operation)
+	}
+	if m.Deleted != "" {
+		columns = append(columns, m.Deleted)
+		values = append(values, deleted)
+	}
+	for _, key := range m.Values {
+		columns = append(columns, key)
+		if value, ok := event.Metadata[key]; ok {
+			values = append(values, value)
+		} else {
+			values = append(values, nil)
+		}
+	}
+	return columns, values
+}
+
+// rowHash returns the hash stored in the row hash column: a SHA-256 of the
+// written data columns and whether the row is soft-deleted. JSON encoding sorts
+// the columns, so the hash does not depend on their order.
+func rowHash(columns []string, values []interface{}, deleted bool) (string, error) {
+	row := make(map[string]interface{}, len(columns)+1)
+	for i, column := range columns {
+		row[column] = values[i]
+	}
+	encoded, err := json.Marshal(struct {
+		Row     map[string]interface{} `json:"row"`
+		Deleted bool                   `json:"deleted"`
+	}{row, deleted})
+	if err != nil {
+		return "", fmt.Errorf("failed to hash row: %w", err)
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,126 @@
+package sink
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// TestSetMetadataColumnsValidation tests that metadata column names are validated
+func TestSetMetadataColumnsValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns MetadataColumns
+		wantErr bool
+	}{
+		{"none", MetadataColumns{}, false},
+		{"all", MetadataColumns{SyncedAt: "_synced_at", SourceTimestamp: "_source_ts", Operation: "_op", Deleted: "_deleted"}, false},
+		{"invalid name", MetadataColumns{Operation: "op; DROP TABLE x"}, true},
+		{"primary key", MetadataColumns{Deleted: "_id"}, true},
+		{"duplicate", MetadataColumns{SyncedAt: "_ts", SourceTimestamp: "_ts"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewPostgreSQLSink("", "users", nil).SetMetadataColumns(tt.columns)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SetMetadataColumns() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestRowColumnsMetadata tests that metadata columns are appended and override event data
+func TestRowColumnsMetadata(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	changed := now.Add(-time.Minute)
+	ingested := now.Add(-time.Second)
+	s := NewPostgreSQLSink("", "users", nil)
+	s.SetClock(pipeline.NewManualClock(now))
+	if err := s.SetMetadataColumns(MetadataColumns{SyncedAt: "_synced_at", SourceTimestamp: "_source_ts", IngestedAt: "_ingested_at", Operation: "_op", Deleted: "_deleted"}); err != nil {
+		t.Fatalf("SetMetadataColumns() error = %v", err)
+	}
+
+	event := pipeline.Event{
+		Operation:  "update",
+		Timestamp:  changed,
+		IngestedAt: ingested,
+		Data:       map[string]interface{}{"_id": "1", "_op": "spoofed"},
+	}
+	columns, values, err := s.rowColumns(event, false)
+	if err != nil {
+		t.Fatalf("rowColumns() error = %v", err)
+	}
+	wantColumns := []string{"_id", "_synced_at", "_source_ts", "_ingested_at", "_op", "_deleted"}
+	wantValues := []interface{}{"1", now, changed, ingested, "update", false}
+	if !reflect.DeepEqual(columns, wantColumns) || !reflect.DeepEqual(values, wantValues) {
+		t.Errorf("rowColumns() = %v, %v, want %v, %v", columns, values, wantColumns, wantValues)
+	}
+
+	event.Timestamp, event.IngestedAt = time.Time{}, time.Time{}
+	if _, values, _ := s.rowColumns(event, true); values[2] != nil || values[3] != nil || values[5] != true {
+		t.Errorf("expected NULL timestamps and a soft delete, got %v", values)
+	}
+}
+
+// TestRowColumnsRowHash tests that the row hash covers the data but not the other metadata columns
+func TestRowColumnsRowHash(t *testing.T) {
+	s := NewPostgreSQLSink("", "users", nil)
+	clock := pipeline.NewManualClock(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+	s.SetClock(clock)
+	if err := s.SetMetadataColumns(MetadataColumns{SyncedAt: "_synced_at", RowHash: "_hash"}); err != nil {
+		t.Fatalf("SetMetadataColumns() error = %v", err)
+	}
+
+	hash := func(event pipeline.Event, deleted bool) interface{} {
+		columns, values, err := s.rowColumns(event, deleted)
+		if err != nil {
+			t.Fatalf("rowColumns() error = %v", err)
+		}
+		if columns[len(columns)-1] != "_hash" {
+			t.Fatalf("expected the hash column last, got %v", columns)
+		}
+		return values[len(values)-1]
+	}
+
+	event := pipeline.Event{Operation: "update", Data: map[string]interface{}{"_id": "1", "name": "a", "_hash": "spoofed"}}
+	first := hash(event, false)
+	clock.Advance(time.Minute)
+	if again := hash(pipeline.Event{Operation: "replace", Data: map[string]interface{}{"name": "a", "_id": "1"}}, false); again != first {
+		t.Errorf("expected the same hash for the same data, got %v and %v", first, again)
+	}
+	if changed := hash(pipeline.Event{Data: map[string]interface{}{"_id": "1", "name": "b"}}, false); changed == first {
+		t.Error("expected a different hash for changed data")
+	}
+	if deleted := hash(event, true); deleted == first {
+		t.Error("expected a different hash for a soft-deleted row")
+	}
+}
+
+// TestRowColumnsMetadataValues tests that event metadata values are written to their columns
+func TestRowColumnsMetadataValues(t *testing.T) {
+	s := NewPostgreSQLSink("", "users", nil)
+	if err := s.SetMetadataColumns(MetadataColumns{Values: []string{"tenant", "schema_version"}}); err != nil {
+		t.Fatalf("SetMetadataColumns() error = %v", err)
+	}
+	if err := s.SetMetadataColumns(MetadataColumns{Operation: "tenant", Values: []string{"tenant"}}); err == nil {
+		t.Error("expected a metadata value column clashing with another metadata column to be rejected")
+	}
+
+	event := pipeline.Event{
+		Operation: "insert",
+		Data:      map[string]interface{}{"_id": "1", "tenant": "spoofed"},
+		Metadata:  map[string]string{"tenant": "acme", "trace_id": "abc"},
+	}
+	columns, values, err := s.rowColumns(event, false)
+	if err != nil {
+		t.Fatalf("rowColumns() error = %v", err)
+	}
+	wantColumns := []string{"_id", "tenant", "schema_version"}
+	wantValues := []interface{}{"1", "acme", nil}
+	if !reflect.DeepEqual(columns, wantColumns) || !reflect.DeepEqual(values, wantValues) {
+		t.Errorf("rowColumns() = %v, %v, want %v, %v", columns, values, wantColumns, wantValues)
+	}
+}
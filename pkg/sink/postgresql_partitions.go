@@ -0,0 +1,199 @@
+package sink
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"github.com/lib/pq"
+)
+
+// PartitionScheme determines the partitions created for a partitioned table
+type PartitionScheme string
+
+const (
+	// PartitionMonthly creates a range partition per calendar month (UTC) of a
+	// timestamp column
+	PartitionMonthly PartitionScheme = "month"
+	// PartitionList creates a list partition per value of a column, such as a
+	// tenant ID
+	PartitionList PartitionScheme = "list"
+)
+
+// ParsePartitionScheme parses a partition scheme from configuration
+func ParsePartitionScheme(name string) (PartitionScheme, error) {
+	switch PartitionScheme(name) {
+	case PartitionMonthly, PartitionList:
+		return PartitionScheme(name), nil
+	default:
+		return "", fmt.Errorf("unsupported partition scheme: %s", name)
+	}
+}
+
+// PartitionConfig describes how the sink's declaratively partitioned table is
+// partitioned, so missing partitions can be created on demand
+type PartitionConfig struct {
+	Column string // Partition key column
+	Scheme PartitionScheme
+}
+
+// missingPartition is the SQLSTATE of a row without a partition; PostgreSQL
+// reports it as a check violation, so the message is matched too
+const missingPartition = "23514"
+
+// isMissingPartition reports whether an error is a row without a partition
+func isMissingPartition(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == missingPartition &&
+		strings.HasPrefix(pqErr.Message, "no partition of relation")
+}
+
+// maxPartitionSuffix is the longest list value used as is in a partition name
+const maxPartitionSuffix = 31
+
+// partitionSuffix replaces the characters a list value cannot use in a table name
+var partitionSuffix = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// SetPartitioning makes the sink create the partitions a batch needs when it
+// fails for want of one, and write it again. The table itself must already be
+// partitioned by the column, by range for monthly partitions or by list.
+func (p *PostgreSQLSink) SetPartitioning(config PartitionConfig) error {
+	if !validTableName.MatchString(config.Column) {
+		return fmt.Errorf("invalid partition column: %s", config.Column)
+	}
+	if _, err := ParsePartitionScheme(string(config.Scheme)); err != nil {
+		return err
+	}
+	p.partitions = &config
+	return nil
+}
+
+// createPartitions creates the partitions for the rows of a batch. Rows whose
+// partition cannot be determined are left to fail again, so error isolation
+// can dead-letter them.
+func (p *PostgreSQLSink) createPartitions(ctx context.Context, events []pipeline.Event) error {
+	// Concurrent writers may need the same partition
+	p.partitionMu.Lock()
+	defer p.partitionMu.Unlock()
+
+	created := make(map[string]bool)
+	for _, event := range events {
+		if event.Operation == "delete" && p.writeMode != WriteAppend {
+			continue
+		}
+		table, err := p.eventTable(event)
+		if err != nil {
+			continue
+		}
+		value, ok := p.partitionValue(event)
+		if !ok {
+			continue
+		}
+		name, bounds, err := p.partitionFor(table, value)
+		if err != nil {
+			p.logger.Printf("Cannot create a partition for event %s: %v", event.ID, err)
+			continue
+		}
+		if created[name] {
+			continue
+		}
+		created[name] = true
+		query := fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES %s", name, table, bounds)
+		if _, err := p.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to create partition %s of %s: %w", name, table, err)
+		}
+		p.logger.Printf("Created partition %s of %s", name, table)
+	}
+	return nil
+}
+
+// partitionValue returns the value of the partition column written for an event
+func (p *PostgreSQLSink) partitionValue(event pipeline.Event) (interface{}, bool) {
+	column := p.partitions.Column
+	switch {
+	case p.writeMode == WriteAppend && column == p.eventLog.Timestamp,
+		p.writeMode == WriteHistory && column == p.history.ValidFrom,
+		column == p.metadata.SourceTimestamp:
+		if event.Timestamp.IsZero() {
+			return p.clock.Now(), true
+		}
+		return event.Timestamp, true
+	case column == p.metadata.IngestedAt && !event.IngestedAt.IsZero():
+		return event.IngestedAt, true
+	case column == p.metadata.SyncedAt, column == p.metadata.IngestedAt:
+		return p.clock.Now(), true
+	}
+	value, ok := event.Data[column]
+	if !ok {
+		value, ok = event.Key[column]
+	}
+	return value, ok && value != nil
+}
+
+// partitionFor returns the name and bounds of the partition of a table holding a value
+func (p *PostgreSQLSink) partitionFor(table string, value interface{}) (string, string, error) {
+	if p.partitions.Scheme == PartitionMonthly {
+		t, err := partitionTime(value)
+		if err != nil {
+			return "", "", err
+		}
+		from := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		to := from.AddDate(0, 1, 0)
+		const layout = "2006-01-02 15:04:05-07"
+		return partitionName(table, from.Format("p2006_01")),
+			fmt.Sprintf("FROM (%s) TO (%s)", pq.QuoteLiteral(from.Format(layout)), pq.QuoteLiteral(to.Format(layout))), nil
+	}
+
+	var literal, suffix string
+	switch v := value.(type) {
+	case string:
+		literal, suffix = pq.QuoteLiteral(v), v
+	case int, int32, int64, float64, bool:
+		literal = fmt.Sprint(v)
+		suffix = literal
+	default:
+		return "", "", fmt.Errorf("unsupported list partition value of type %T", value)
+	}
+	clean := strings.Trim(partitionSuffix.ReplaceAllString(strings.ToLower(suffix), "_"), "_")
+	if clean != suffix || len(clean) > maxPartitionSuffix {
+		// Keep values that clean up or shorten alike, such as "A-1" and "a_1", apart
+		sum := sha256.Sum256([]byte(suffix))
+		if len(clean) > maxPartitionSuffix-9 {
+			clean = clean[:maxPartitionSuffix-9]
+		}
+		clean = strings.TrimPrefix(clean+"_"+hex.EncodeToString(sum[:4]), "_")
+	}
+	return partitionName(table, clean), fmt.Sprintf("IN (%s)", literal), nil
+}
+
+// partitionTime converts a partition column value to a time
+func partitionTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v.UTC(), nil
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.UTC(), nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("cannot parse %q as a time", v)
+	default:
+		return time.Time{}, fmt.Errorf("unsupported monthly partition value of type %T", value)
+	}
+}
+
+// partitionName names a partition after its table, shortening the table part
+// so the name fits PostgreSQL's identifier limit
+func partitionName(table, suffix string) string {
+	if room := 63 - len(suffix) - 1; len(table) > room {
+		table = table[:room]
+	}
+	return table + "_" + suffix
+}
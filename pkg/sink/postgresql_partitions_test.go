@@ -0,0 +1,118 @@
+package sink
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"github.com/lib/pq"
+)
+
+// TestSetPartitioning tests partition configuration validation
+func TestSetPartitioning(t *testing.T) {
+	s := NewPostgreSQLSink("", "orders", nil)
+	if err := s.SetPartitioning(PartitionConfig{Column: "created_at", Scheme: "week"}); err == nil {
+		t.Error("expected error for an unsupported scheme")
+	}
+	if err := s.SetPartitioning(PartitionConfig{Column: "created at", Scheme: PartitionMonthly}); err == nil {
+		t.Error("expected error for an invalid column")
+	}
+	if err := s.SetPartitioning(PartitionConfig{Column: "created_at", Scheme: PartitionMonthly}); err != nil {
+		t.Errorf("SetPartitioning() error = %v", err)
+	}
+}
+
+// TestIsMissingPartition tests recognizing rows without a partition
+func TestIsMissingPartition(t *testing.T) {
+	missing := &pq.Error{Code: "23514", Message: `no partition of relation "orders" found for row`}
+	if !isMissingPartition(fmt.Errorf("failed to write event: %w", missing)) {
+		t.Error("expected a missing partition")
+	}
+	if isMissingPartition(&pq.Error{Code: "23514", Message: `new row for relation "orders" violates check constraint "positive_total"`}) {
+		t.Error("expected a check constraint violation not to be a missing partition")
+	}
+}
+
+// TestPartitionFor tests partition names and bounds
+func TestPartitionFor(t *testing.T) {
+	monthly := NewPostgreSQLSink("", "orders", nil)
+	monthly.SetPartitioning(PartitionConfig{Column: "created_at", Scheme: PartitionMonthly})
+	list := NewPostgreSQLSink("", "orders", nil)
+	list.SetPartitioning(PartitionConfig{Column: "tenant", Scheme: PartitionList})
+
+	tests := []struct {
+		name       string
+		sink       *PostgreSQLSink
+		value      interface{}
+		wantName   string
+		wantBounds string
+		wantErr    bool
+	}{
+		{
+			name:       "time",
+			sink:       monthly,
+			value:      time.Date(2026, 12, 31, 23, 0, 0, 0, time.FixedZone("", -3600)),
+			wantName:   "orders_p2027_01",
+			wantBounds: "FROM ('2027-01-01 00:00:00+00') TO ('2027-02-01 00:00:00+00')",
+		},
+		{
+			name:       "date string",
+			sink:       monthly,
+			value:      "2026-03-15",
+			wantName:   "orders_p2026_03",
+			wantBounds: "FROM ('2026-03-01 00:00:00+00') TO ('2026-04-01 00:00:00+00')",
+		},
+		{name: "unparseable time", sink: monthly, value: "soon", wantErr: true},
+		{name: "tenant", sink: list, value: "acme", wantName: "orders_acme", wantBounds: "IN ('acme')"},
+		{name: "number", sink: list, value: int64(42), wantName: "orders_42", wantBounds: "IN (42)"},
+		{name: "quoted value", sink: list, value: "O'Brien & Co", wantName: "orders_o_brien_co_", wantBounds: "IN ('O''Brien & Co')"},
+		{name: "unsupported value", sink: list, value: []string{"a"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name, bounds, err := tt.sink.partitionFor("orders", tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("partitionFor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			// Cleaned up values carry a hash of the original
+			if !strings.HasPrefix(name, tt.wantName) || bounds != tt.wantBounds {
+				t.Errorf("partitionFor() = %q, %q; want %q, %q", name, bounds, tt.wantName, tt.wantBounds)
+			}
+		})
+	}
+
+	a, _, _ := list.partitionFor("orders", "A-1")
+	b, _, _ := list.partitionFor("orders", "a_1")
+	if a == b {
+		t.Errorf("expected values that clean up alike to get different partitions, both got %s", a)
+	}
+	long, _, _ := list.partitionFor(strings.Repeat("t", 63), strings.Repeat("v", 100))
+	if len(long) > 63 {
+		t.Errorf("expected a partition name within the identifier limit, got %d characters", len(long))
+	}
+}
+
+// TestPartitionValue tests which value decides an event's partition
+func TestPartitionValue(t *testing.T) {
+	changed := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	s := NewPostgreSQLSink("", "events", nil)
+	s.SetEventLogMode(EventLogColumns{})
+	s.SetPartitioning(PartitionConfig{Column: "event_time", Scheme: PartitionMonthly})
+	if value, ok := s.partitionValue(pipeline.Event{Timestamp: changed}); !ok || value != changed {
+		t.Errorf("expected the event time in append mode, got %v", value)
+	}
+
+	s = NewPostgreSQLSink("", "orders", nil)
+	s.SetPartitioning(PartitionConfig{Column: "tenant", Scheme: PartitionList})
+	if value, ok := s.partitionValue(pipeline.Event{Data: map[string]interface{}{"tenant": "acme"}}); !ok || value != "acme" {
+		t.Errorf("expected the tenant column, got %v", value)
+	}
+	if _, ok := s.partitionValue(pipeline.Event{Data: map[string]interface{}{"tenant": nil}}); ok {
+		t.Error("expected no partition for a null value")
+	}
+}
@@ -0,0 +1,42 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// Statement is a SQL statement with the values of its placeholders
+type Statement struct {
+	Query string
+	Args  []interface{}
+}
+
+// statementRecorder records statements instead of executing them
+type statementRecorder struct {
+	statements []Statement
+}
+
+func (r *statementRecorder) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	r.statements = append(r.statements, Statement{Query: query, Args: args})
+	return driverResult{}, nil
+}
+
+// driverResult is the result of a recorded statement
+type driverResult struct{}
+
+func (driverResult) LastInsertId() (int64, error) { return 0, nil }
+func (driverResult) RowsAffected() (int64, error) { return 1, nil }
+
+// Statements returns the statements the sink would execute to write an
+// event, without connecting, so the effect of a transformer on the table can
+// be previewed. Statements of the transaction around a batch, such as
+// checkpoints, are not included.
+func (p *PostgreSQLSink) Statements(event pipeline.Event) ([]Statement, error) {
+	var recorder statementRecorder
+	if err := p.writeEvent(context.Background(), &recorder, event); err != nil {
+		return nil, err
+	}
+	return recorder.statements, nil
+}
@@ -0,0 +1,35 @@
+package sink
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// TestStatements tests previewing the statements writing an event
+func TestStatements(t *testing.T) {
+	s := NewPostgreSQLSink("", "users", nil)
+
+	statements, err := s.Statements(pipeline.Event{ID: "1", Operation: "insert", Data: map[string]interface{}{"_id": "1"}})
+	if err != nil {
+		t.Fatalf("Statements() error = %v", err)
+	}
+	want := []Statement{{Query: "INSERT INTO users (_id) VALUES ($1) ON CONFLICT (_id) DO NOTHING", Args: []interface{}{"1"}}}
+	if !reflect.DeepEqual(statements, want) {
+		t.Errorf("Statements() = %+v, want %+v", statements, want)
+	}
+
+	statements, err = s.Statements(pipeline.Event{ID: "1", Operation: "delete", Key: map[string]interface{}{"_id": "1"}})
+	if err != nil {
+		t.Fatalf("Statements() error = %v", err)
+	}
+	want = []Statement{{Query: "DELETE FROM users WHERE _id = $1", Args: []interface{}{"1"}}}
+	if !reflect.DeepEqual(statements, want) {
+		t.Errorf("Statements() = %+v, want %+v", statements, want)
+	}
+
+	if _, err := s.Statements(pipeline.Event{ID: "1", Operation: "insert", Data: map[string]interface{}{"bad column": 1}}); err == nil {
+		t.Error("expected error for an invalid column name")
+	}
+}
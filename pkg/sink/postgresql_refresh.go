@@ -0,0 +1,116 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+)
+
+// RefreshMode determines how the destination table is prepared for a forced full sync
+type RefreshMode string
+
+const (
+	// RefreshNone upserts the full sync over the existing rows (default)
+	RefreshNone RefreshMode = "none"
+	// RefreshTruncate empties the table before the full sync, so rows deleted
+	// at the source do not linger
+	RefreshTruncate RefreshMode = "truncate"
+	// RefreshSwap loads a staging copy of the table and swaps it in once the
+	// full sync completes, so readers never see a partially loaded table
+	RefreshSwap RefreshMode = "swap"
+)
+
+// Suffixes of the tables used while swapping in a staged refresh
+const (
+	stagingSuffix  = "_new"
+	replacedSuffix = "_old"
+)
+
+// ParseRefreshMode parses a refresh mode from configuration
+func ParseRefreshMode(name string) (RefreshMode, error) {
+	switch RefreshMode(name) {
+	case "":
+		return RefreshNone, nil
+	case RefreshNone, RefreshTruncate, RefreshSwap:
+		return RefreshMode(name), nil
+	default:
+		return "", fmt.Errorf("unsupported refresh mode: %s", name)
+	}
+}
+
+// Truncate removes every row from the target table
+func (p *PostgreSQLSink) Truncate(ctx context.Context) error {
+	if _, err := p.db.ExecContext(ctx, fmt.Sprintf("TRUNCATE TABLE %s", p.table)); err != nil {
+		return fmt.Errorf("failed to truncate %s: %w", p.table, err)
+	}
+	p.logger.Printf("Truncated table %s", p.table)
+	return nil
+}
+
+// BeginStagedRefresh creates an empty copy of the target table, with its
+// columns, defaults, constraints and indexes, and directs writes to it until
+// the refresh is finished or aborted
+func (p *PostgreSQLSink) BeginStagedRefresh(ctx context.Context) error {
+	staging := p.table + stagingSuffix
+	if !validTableName.MatchString(staging) {
+		return fmt.Errorf("table name %s is too long for a staging table", p.table)
+	}
+
+	// A staging table left by an interrupted refresh is incomplete
+	if _, err := p.db.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s", staging)); err != nil {
+		return fmt.Errorf("failed to drop stale staging table %s: %w", staging, err)
+	}
+	if _, err := p.db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE %s (LIKE %s INCLUDING ALL)", staging, p.table)); err != nil {
+		return fmt.Errorf("failed to create staging table %s: %w", staging, err)
+	}
+
+	p.refreshTarget = p.table
+	p.table = staging
+	p.logger.Printf("Loading staging table %s", staging)
+	return nil
+}
+
+// FinishStagedRefresh swaps the loaded staging table in for the target table
+// in one transaction and drops the replaced table
+func (p *PostgreSQLSink) FinishStagedRefresh(ctx context.Context) error {
+	if p.refreshTarget == "" {
+		return fmt.Errorf("no staged refresh in progress")
+	}
+	target, staging := p.refreshTarget, p.table
+	replaced := target + replacedSuffix
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", target, replaced),
+		fmt.Sprintf("ALTER TABLE %s RENAME TO %s", staging, target),
+		fmt.Sprintf("DROP TABLE %s", replaced),
+	} {
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to swap in staging table %s: %w", staging, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to swap in staging table %s: %w", staging, err)
+	}
+
+	p.table, p.refreshTarget = target, ""
+	p.logger.Printf("Swapped staging table %s in for %s", staging, target)
+	return nil
+}
+
+// AbortStagedRefresh drops the staging table and directs writes back to the target table
+func (p *PostgreSQLSink) AbortStagedRefresh(ctx context.Context) error {
+	if p.refreshTarget == "" {
+		return nil
+	}
+	staging := p.table
+	p.table, p.refreshTarget = p.refreshTarget, ""
+	if _, err := p.db.ExecContext(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s", staging)); err != nil {
+		return fmt.Errorf("failed to drop staging table %s: %w", staging, err)
+	}
+	return nil
+}
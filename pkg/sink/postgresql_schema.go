@@ -0,0 +1,327 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SchemaPolicy determines what Connect does when the table does not match the
+// columns the pipeline writes
+type SchemaPolicy string
+
+const (
+	// SchemaOff does not check the table (default)
+	SchemaOff SchemaPolicy = "off"
+	// SchemaFail fails the connection with a description of the differences
+	SchemaFail SchemaPolicy = "fail"
+	// SchemaMigrate adds missing columns and fails on any other difference
+	SchemaMigrate SchemaPolicy = "migrate"
+)
+
+// ParseSchemaPolicy parses a schema policy from configuration
+func ParseSchemaPolicy(name string) (SchemaPolicy, error) {
+	switch SchemaPolicy(name) {
+	case "":
+		return SchemaOff, nil
+	case SchemaOff, SchemaFail, SchemaMigrate:
+		return SchemaPolicy(name), nil
+	default:
+		return "", fmt.Errorf("unsupported schema policy: %s", name)
+	}
+}
+
+// ColumnType is the kind of values written to a column. Each accepts a family
+// of PostgreSQL types.
+type ColumnType string
+
+const (
+	ColumnAny       ColumnType = "" // only the column's presence is checked
+	ColumnText      ColumnType = "text"
+	ColumnInteger   ColumnType = "integer"
+	ColumnNumeric   ColumnType = "numeric"
+	ColumnBoolean   ColumnType = "boolean"
+	ColumnTimestamp ColumnType = "timestamp"
+	ColumnJSON      ColumnType = "json"
+)
+
+// columnTypes lists the PostgreSQL types (as information_schema names them)
+// each column type can be written to, the first being the one a migration adds
+var columnTypes = map[ColumnType][]string{
+	ColumnText:      {"text", "character varying", "character", "uuid", "USER-DEFINED"},
+	ColumnInteger:   {"bigint", "integer", "smallint", "numeric"},
+	ColumnNumeric:   {"double precision", "real", "numeric"},
+	ColumnBoolean:   {"boolean"},
+	ColumnTimestamp: {"timestamp with time zone", "timestamp without time zone", "date"},
+	ColumnJSON:      {"jsonb", "json"},
+}
+
+// accepts reports whether values of a column type can be written to a PostgreSQL type
+func (t ColumnType) accepts(dataType string) bool {
+	if t == ColumnAny {
+		return true
+	}
+	for _, accepted := range columnTypes[t] {
+		if accepted == dataType {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpectedColumn is a column the pipeline writes
+type ExpectedColumn struct {
+	Name string
+	Type ColumnType
+}
+
+// CreateTableStatement returns a CREATE TABLE statement for a table with the
+// given columns and primary key, each column typed as a migration would add
+// it. Untyped columns are text.
+func CreateTableStatement(table string, columns []ExpectedColumn, keys []string) (string, error) {
+	if !validTableName.MatchString(table) {
+		return "", fmt.Errorf("invalid table name: %s", table)
+	}
+	if len(keys) == 0 {
+		keys = []string{"_id"}
+	}
+	defined := make(map[string]bool, len(columns))
+	var lines []string
+	for _, column := range columns {
+		if !validTableName.MatchString(column.Name) {
+			return "", fmt.Errorf("invalid column name: %s", column.Name)
+		}
+		if defined[column.Name] {
+			return "", fmt.Errorf("column %s is defined twice", column.Name)
+		}
+		defined[column.Name] = true
+		dataType := "text"
+		if column.Type != ColumnAny {
+			types, ok := columnTypes[column.Type]
+			if !ok {
+				return "", fmt.Errorf("unsupported type %s of column %s", column.Type, column.Name)
+			}
+			dataType = types[0]
+		}
+		lines = append(lines, fmt.Sprintf("    %s %s", column.Name, dataType))
+	}
+	for _, key := range keys {
+		if !defined[key] {
+			return "", fmt.Errorf("key column %s is not one of the columns", key)
+		}
+	}
+	lines = append(lines, fmt.Sprintf("    PRIMARY KEY (%s)", strings.Join(keys, ", ")))
+	return fmt.Sprintf("CREATE TABLE %s (\n%s\n);", table, strings.Join(lines, ",\n")), nil
+}
+
+// SchemaCheck describes the columns expected of the table, beyond those the
+// sink's own configuration implies (key, metadata, history and event log
+// columns)
+type SchemaCheck struct {
+	Policy SchemaPolicy
+	// Columns are the data columns, typically derived from the transformer.
+	// In append mode they are written into the payload, so they are ignored.
+	Columns []ExpectedColumn
+	// Complete is set when Columns are all the data columns there are, so
+	// NOT NULL columns without a default that are never written are reported
+	Complete bool
+}
+
+// tableColumn is a column of the table as the database describes it
+type tableColumn struct {
+	name       string
+	dataType   string
+	nullable   bool
+	hasDefault bool
+}
+
+// SetSchemaCheck makes Connect compare the table with the expected columns
+func (p *PostgreSQLSink) SetSchemaCheck(check SchemaCheck) error {
+	if _, err := ParseSchemaPolicy(string(check.Policy)); err != nil {
+		return err
+	}
+	for _, column := range check.Columns {
+		if !validTableName.MatchString(column.Name) {
+			return fmt.Errorf("invalid expected column name: %s", column.Name)
+		}
+		if _, ok := columnTypes[column.Type]; !ok && column.Type != ColumnAny {
+			return fmt.Errorf("unsupported type %s of expected column %s", column.Type, column.Name)
+		}
+	}
+	if check.Policy == SchemaOff {
+		p.schema = nil
+		return nil
+	}
+	p.schema = &check
+	return nil
+}
+
+// expectedColumns returns the columns the sink writes, the typed declaration
+// of a column winning over an untyped one
+func (p *PostgreSQLSink) expectedColumns() []ExpectedColumn {
+	var columns []ExpectedColumn
+	index := make(map[string]int)
+	add := func(name string, columnType ColumnType) {
+		if name == "" {
+			return
+		}
+		if i, ok := index[name]; ok {
+			if columns[i].Type == ColumnAny {
+				columns[i].Type = columnType
+			}
+			return
+		}
+		index[name] = len(columns)
+		columns = append(columns, ExpectedColumn{Name: name, Type: columnType})
+	}
+
+	switch p.writeMode {
+	case WriteAppend:
+		add(p.eventLog.ID, ColumnText)
+		add(p.eventLog.Timestamp, ColumnTimestamp)
+		add(p.eventLog.Operation, ColumnText)
+		add(p.eventLog.Key, ColumnJSON)
+		add(p.eventLog.Payload, ColumnJSON)
+	default:
+		for _, column := range p.keyColumns {
+			add(column, ColumnAny)
+		}
+		for _, column := range p.schema.Columns {
+			add(column.Name, column.Type)
+		}
+	}
+	if p.writeMode == WriteHistory {
+		add(p.history.ValidFrom, ColumnTimestamp)
+		add(p.history.ValidTo, ColumnTimestamp)
+		add(p.history.Current, ColumnBoolean)
+	}
+	add(p.metadata.SyncedAt, ColumnTimestamp)
+	add(p.metadata.SourceTimestamp, ColumnTimestamp)
+	add(p.metadata.IngestedAt, ColumnTimestamp)
+	add(p.metadata.Operation, ColumnText)
+	add(p.metadata.Deleted, ColumnBoolean)
+	add(p.metadata.RowHash, ColumnText)
+	for _, column := range p.metadata.Values {
+		add(column, ColumnText)
+	}
+	return columns
+}
+
+// schemaDrift lists the differences between the table and the expected columns
+type schemaDrift struct {
+	missing    []ExpectedColumn
+	mismatched []string
+	unwritten  []string
+}
+
+// empty reports whether the table matches
+func (d schemaDrift) empty() bool {
+	return len(d.missing) == 0 && len(d.mismatched) == 0 && len(d.unwritten) == 0
+}
+
+// String describes the differences, one per line
+func (d schemaDrift) String() string {
+	var lines []string
+	for _, column := range d.missing {
+		if column.Type == ColumnAny {
+			lines = append(lines, fmt.Sprintf("  missing column %s", column.Name))
+		} else {
+			lines = append(lines, fmt.Sprintf("  missing column %s (%s)", column.Name, column.Type))
+		}
+	}
+	lines = append(lines, d.mismatched...)
+	lines = append(lines, d.unwritten...)
+	return strings.Join(lines, "\n")
+}
+
+// diffSchema compares the table's columns with the expected ones
+func diffSchema(expected []ExpectedColumn, actual []tableColumn, complete bool) schemaDrift {
+	var drift schemaDrift
+	byName := make(map[string]tableColumn, len(actual))
+	for _, column := range actual {
+		byName[column.name] = column
+	}
+	written := make(map[string]bool, len(expected))
+	for _, column := range expected {
+		written[column.Name] = true
+		found, ok := byName[column.Name]
+		if !ok {
+			drift.missing = append(drift.missing, column)
+			continue
+		}
+		if !column.Type.accepts(found.dataType) {
+			drift.mismatched = append(drift.mismatched,
+				fmt.Sprintf("  column %s is %s, expected %s (one of %s)", column.Name, found.dataType, column.Type, strings.Join(columnTypes[column.Type], ", ")))
+		}
+	}
+	if complete {
+		for _, column := range actual {
+			if !written[column.name] && !column.nullable && !column.hasDefault {
+				drift.unwritten = append(drift.unwritten,
+					fmt.Sprintf("  column %s is NOT NULL without a default, but is never written", column.name))
+			}
+		}
+	}
+	return drift
+}
+
+// loadColumns reads the columns of the table
+func (p *PostgreSQLSink) loadColumns(ctx context.Context) ([]tableColumn, error) {
+	rows, err := p.db.QueryContext(ctx, `SELECT column_name, data_type, is_nullable = 'YES',
+		column_default IS NOT NULL OR is_identity = 'YES' OR is_generated <> 'NEVER'
+		FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = $1
+		ORDER BY ordinal_position`, p.table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns of %s: %w", p.table, err)
+	}
+	defer rows.Close()
+
+	var columns []tableColumn
+	for rows.Next() {
+		var column tableColumn
+		if err := rows.Scan(&column.name, &column.dataType, &column.nullable, &column.hasDefault); err != nil {
+			return nil, fmt.Errorf("failed to read columns of %s: %w", p.table, err)
+		}
+		columns = append(columns, column)
+	}
+	return columns, rows.Err()
+}
+
+// checkSchema compares the table with the expected columns on Connect, adding
+// missing columns under the migrate policy
+func (p *PostgreSQLSink) checkSchema(ctx context.Context) error {
+	actual, err := p.loadColumns(ctx)
+	if err != nil {
+		return err
+	}
+	if len(actual) == 0 {
+		return fmt.Errorf("table %s does not exist", p.table)
+	}
+	// Only data columns can be unwritten; in append mode they are in the payload
+	complete := p.schema.Complete && p.writeMode != WriteAppend
+	drift := diffSchema(p.expectedColumns(), actual, complete)
+	if drift.empty() {
+		return nil
+	}
+
+	if p.schema.Policy == SchemaMigrate && len(drift.missing) > 0 {
+		var untyped []ExpectedColumn
+		for _, column := range drift.missing {
+			if column.Type == ColumnAny {
+				untyped = append(untyped, column)
+				continue
+			}
+			dataType := columnTypes[column.Type][0]
+			query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", p.table, column.Name, dataType)
+			if _, err := p.db.ExecContext(ctx, query); err != nil {
+				return fmt.Errorf("failed to add column %s to %s: %w", column.Name, p.table, err)
+			}
+			p.logger.Printf("Added column %s %s to %s", column.Name, dataType, p.table)
+		}
+		drift.missing = untyped
+		if drift.empty() {
+			return nil
+		}
+	}
+	return fmt.Errorf("table %s does not match the expected schema:\n%s", p.table, drift)
+}
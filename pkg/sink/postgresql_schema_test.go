@@ -0,0 +1,132 @@
+package sink
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestSetSchemaCheck tests schema check validation
+func TestSetSchemaCheck(t *testing.T) {
+	s := NewPostgreSQLSink("", "users", nil)
+	if err := s.SetSchemaCheck(SchemaCheck{Policy: "warn"}); err == nil {
+		t.Error("expected error for an unsupported policy")
+	}
+	if err := s.SetSchemaCheck(SchemaCheck{Policy: SchemaFail, Columns: []ExpectedColumn{{Name: "a b"}}}); err == nil {
+		t.Error("expected error for an invalid column name")
+	}
+	if err := s.SetSchemaCheck(SchemaCheck{Policy: SchemaFail, Columns: []ExpectedColumn{{Name: "age", Type: "money"}}}); err == nil {
+		t.Error("expected error for an unsupported column type")
+	}
+	if err := s.SetSchemaCheck(SchemaCheck{Policy: SchemaOff}); err != nil || s.schema != nil {
+		t.Errorf("expected the off policy to disable the check, got %v", err)
+	}
+}
+
+// TestExpectedColumns tests the columns implied by the write mode and metadata columns
+func TestExpectedColumns(t *testing.T) {
+	s := NewPostgreSQLSink("", "users", nil)
+	s.SetMetadataColumns(MetadataColumns{SyncedAt: "synced_at"})
+	s.SetSchemaCheck(SchemaCheck{Policy: SchemaFail, Columns: []ExpectedColumn{{Name: "_id", Type: ColumnText}, {Name: "age", Type: ColumnInteger}}})
+	want := []ExpectedColumn{{"_id", ColumnText}, {"age", ColumnInteger}, {"synced_at", ColumnTimestamp}}
+	if got := s.expectedColumns(); !equalColumns(got, want) {
+		t.Errorf("expectedColumns() = %v, want %v", got, want)
+	}
+
+	s = NewPostgreSQLSink("", "events", nil)
+	s.SetEventLogMode(EventLogColumns{})
+	s.SetSchemaCheck(SchemaCheck{Policy: SchemaFail, Columns: []ExpectedColumn{{Name: "age", Type: ColumnInteger}}})
+	want = []ExpectedColumn{{"event_id", ColumnText}, {"event_time", ColumnTimestamp}, {"operation", ColumnText}, {"document_key", ColumnJSON}, {"payload", ColumnJSON}}
+	if got := s.expectedColumns(); !equalColumns(got, want) {
+		t.Errorf("expectedColumns() in append mode = %v, want %v", got, want)
+	}
+}
+
+// equalColumns reports whether two column lists are the same
+func equalColumns(a, b []ExpectedColumn) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestDiffSchema tests the differences reported between the table and the expected columns
+func TestDiffSchema(t *testing.T) {
+	expected := []ExpectedColumn{{"_id", ColumnAny}, {"age", ColumnInteger}, {"email", ColumnText}, {"joined", ColumnTimestamp}}
+	actual := []tableColumn{
+		{name: "_id", dataType: "text"},
+		{name: "age", dataType: "text", nullable: true},
+		{name: "joined", dataType: "timestamp with time zone", nullable: true},
+		{name: "created_by", dataType: "text"},
+		{name: "id", dataType: "bigint", hasDefault: true},
+	}
+
+	drift := diffSchema(expected, actual, false)
+	if len(drift.missing) != 1 || drift.missing[0].Name != "email" || len(drift.mismatched) != 1 || len(drift.unwritten) != 0 {
+		t.Fatalf("unexpected drift %+v", drift)
+	}
+	want := "  missing column email (text)\n" +
+		"  column age is text, expected integer (one of bigint, integer, smallint, numeric)"
+	if got := drift.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	drift = diffSchema(expected, actual, true)
+	if len(drift.unwritten) != 1 || !strings.Contains(drift.unwritten[0], "created_by") {
+		t.Errorf("expected created_by to be reported as never written, got %v", drift.unwritten)
+	}
+
+	if drift := diffSchema(expected[:1], actual[:1], true); !drift.empty() {
+		t.Errorf("expected no drift, got %+v", drift)
+	}
+}
+
+// TestCreateTableStatement tests generating a table definition
+func TestCreateTableStatement(t *testing.T) {
+	columns := []ExpectedColumn{
+		{Name: "_id", Type: ColumnText},
+		{Name: "age", Type: ColumnInteger},
+		{Name: "joined_at", Type: ColumnTimestamp},
+		{Name: "address", Type: ColumnJSON},
+		{Name: "note"},
+	}
+	got, err := CreateTableStatement("users", columns, nil)
+	if err != nil {
+		t.Fatalf("CreateTableStatement() error = %v", err)
+	}
+	want := `CREATE TABLE users (
+    _id text,
+    age bigint,
+    joined_at timestamp with time zone,
+    address jsonb,
+    note text,
+    PRIMARY KEY (_id)
+);`
+	if got != want {
+		t.Errorf("CreateTableStatement() =\n%s\nwant\n%s", got, want)
+	}
+
+	tests := []struct {
+		name    string
+		table   string
+		columns []ExpectedColumn
+		keys    []string
+	}{
+		{name: "invalid table", table: "a b", columns: columns},
+		{name: "invalid column", table: "users", columns: []ExpectedColumn{{Name: "_id"}, {Name: "a-b"}}},
+		{name: "duplicate column", table: "users", columns: []ExpectedColumn{{Name: "_id"}, {Name: "_id"}}},
+		{name: "unsupported type", table: "users", columns: []ExpectedColumn{{Name: "_id", Type: "money"}}},
+		{name: "missing key", table: "users", columns: columns, keys: []string{"tenant", "_id"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := CreateTableStatement(tt.table, tt.columns, tt.keys); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
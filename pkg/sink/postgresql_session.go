@@ -0,0 +1,49 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// validSessionVariable matches custom configuration parameters, which
+// PostgreSQL requires to have a prefix, such as app.tenant_id
+var validSessionVariable = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*(\.[a-zA-Z_][a-zA-Z0-9_]*)+$`)
+
+// sessionVariable is a configuration parameter set from event metadata
+type sessionVariable struct {
+	name string // parameter, such as app.tenant_id
+	key  string // Event.Metadata key holding its value
+}
+
+// SetSessionVariables sets configuration parameters, such as app.tenant_id,
+// from event metadata keys before each event is written, so tables protected
+// by row-level security policies reading them with current_setting see the
+// tenant of the row. They are local to the batch transaction; events without
+// the key set them to an empty string.
+func (p *PostgreSQLSink) SetSessionVariables(variables map[string]string) error {
+	session := make([]sessionVariable, 0, len(variables))
+	for name, key := range variables {
+		if !validSessionVariable.MatchString(name) {
+			return fmt.Errorf("invalid session variable name: %s (must be prefixed, such as app.tenant_id)", name)
+		}
+		if key == "" {
+			return fmt.Errorf("session variable %s requires a metadata key", name)
+		}
+		session = append(session, sessionVariable{name: name, key: key})
+	}
+	sort.Slice(session, func(i, j int) bool { return session[i].name < session[j].name })
+	p.session = session
+	return nil
+}
+
+// setSession sets the session variables for an event
+func (p *PostgreSQLSink) setSession(ctx context.Context, tx execer, metadata map[string]string) error {
+	for _, variable := range p.session {
+		if _, err := tx.ExecContext(ctx, "SELECT set_config($1, $2, true)", variable.name, metadata[variable.key]); err != nil {
+			return fmt.Errorf("failed to set session variable %s: %w", variable.name, err)
+		}
+	}
+	return nil
+}
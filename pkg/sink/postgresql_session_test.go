@@ -0,0 +1,54 @@
+package sink
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// TestSetSessionVariables tests session variable validation
+func TestSetSessionVariables(t *testing.T) {
+	tests := []struct {
+		name      string
+		variables map[string]string
+		wantErr   bool
+	}{
+		{"none", nil, false},
+		{"tenant", map[string]string{"app.tenant_id": "tenant"}, false},
+		{"no prefix", map[string]string{"tenant_id": "tenant"}, true},
+		{"invalid name", map[string]string{"app.tenant'; --": "tenant"}, true},
+		{"no metadata key", map[string]string{"app.tenant_id": ""}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewPostgreSQLSink("", "users", nil).SetSessionVariables(tt.variables)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SetSessionVariables() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestSessionStatements tests that session variables are set from metadata before each write
+func TestSessionStatements(t *testing.T) {
+	s := NewPostgreSQLSink("", "users", nil)
+	if err := s.SetSessionVariables(map[string]string{"app.tenant_id": "tenant", "app.region": "region"}); err != nil {
+		t.Fatalf("SetSessionVariables() error = %v", err)
+	}
+
+	statements, err := s.Statements(pipeline.Event{ID: "1", Operation: "insert", Data: map[string]interface{}{"_id": "1"},
+		Metadata: map[string]string{"tenant": "acme"}})
+	if err != nil {
+		t.Fatalf("Statements() error = %v", err)
+	}
+	want := []Statement{
+		{Query: "SELECT set_config($1, $2, true)", Args: []interface{}{"app.region", ""}},
+		{Query: "SELECT set_config($1, $2, true)", Args: []interface{}{"app.tenant_id", "acme"}},
+		{Query: "INSERT INTO users (_id) VALUES ($1) ON CONFLICT (_id) DO NOTHING", Args: []interface{}{"1"}},
+	}
+	if !reflect.DeepEqual(statements, want) {
+		t.Errorf("Statements() = %+v, want %+v", statements, want)
+	}
+}
@@ -2,7 +2,14 @@ package sink
 
 import (
 	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"github.com/lib/pq"
 )
 
 // TestTableNameValidation tests that invalid table names are rejected
@@ -138,3 +145,278 @@ func TestColumnNameValidation(t *testing.T) {
 		})
 	}
 }
+
+// TestParseErrorIsolation tests parsing error isolation modes
+func TestParseErrorIsolation(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    ErrorIsolation
+		wantErr bool
+	}{
+		{"", IsolationNone, false},
+		{"none", IsolationNone, false},
+		{"bisect", IsolationBisect, false},
+		{"individual", IsolationIndividual, false},
+		{"retry", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseErrorIsolation(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseErrorIsolation(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseErrorIsolation(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseWriteMode tests parsing write modes from configuration
+func TestParseWriteMode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    WriteMode
+		wantErr bool
+	}{
+		{input: "", want: WriteUpsert},
+		{input: "upsert", want: WriteUpsert},
+		{input: "history", want: WriteHistory},
+		{input: "append", want: WriteAppend},
+		{input: "scd", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseWriteMode(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseWriteMode(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseWriteMode(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestIsTransientError tests classification of connection vs data errors
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"context canceled", fmt.Errorf("write: %w", context.Canceled), true},
+		{"bad connection", driver.ErrBadConn, true},
+		{"connection failure", &pq.Error{Code: "08006"}, true},
+		{"too many connections", &pq.Error{Code: "53300"}, true},
+		{"not null violation", &pq.Error{Code: "23502"}, false},
+		{"undefined column", fmt.Errorf("failed to write event: %w", &pq.Error{Code: "42703"}), false},
+		{"plain error", errors.New("boom"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isTransientError(tt.err); got != tt.want {
+				t.Errorf("isTransientError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// recordingDLQ is a dead-letter queue that records the events it receives
+type recordingDLQ struct {
+	events  []pipeline.Event
+	reasons []string
+}
+
+func (r *recordingDLQ) Send(ctx context.Context, event pipeline.Event, reason string) error {
+	r.events = append(r.events, event)
+	r.reasons = append(r.reasons, reason)
+	return nil
+}
+
+// TestIsolateSingleEvent tests that an isolated bad event goes to the DLQ
+func TestIsolateSingleEvent(t *testing.T) {
+	sink := NewPostgreSQLSink("dummy", "users", nil)
+	sink.SetErrorIsolation(IsolationBisect)
+
+	event := pipeline.Event{ID: "bad"}
+	cause := &pq.Error{Code: "23502", Message: "null value in column"}
+
+	// Without a DLQ the event is reported as dropped
+	if err := sink.isolateBatch(context.Background(), []pipeline.Event{event}, cause); err == nil {
+		t.Error("expected error when no DLQ is configured")
+	}
+
+	dlq := &recordingDLQ{}
+	sink.SetDeadLetterQueue(dlq)
+	if err := sink.isolateBatch(context.Background(), []pipeline.Event{event}, cause); err != nil {
+		t.Fatalf("isolateBatch() error = %v", err)
+	}
+	if len(dlq.events) != 1 || dlq.events[0].ID != "bad" {
+		t.Errorf("expected bad event in DLQ, got %+v", dlq.events)
+	}
+}
+
+// TestEnableTransactionalCheckpoints tests checkpoint store configuration on the sink
+func TestEnableTransactionalCheckpoints(t *testing.T) {
+	s := NewPostgreSQLSink("", "events", nil)
+	var _ pipeline.TransactionalCheckpointStore = s
+
+	if s.CheckpointsInTransaction() {
+		t.Error("expected transactional checkpoints to be disabled by default")
+	}
+	if _, err := s.Load(context.Background(), "test"); err == nil {
+		t.Error("expected Load to fail when checkpoints are disabled")
+	}
+
+	s.EnableTransactionalCheckpoints("test", "")
+	if !s.CheckpointsInTransaction() {
+		t.Error("expected transactional checkpoints to be enabled")
+	}
+	if s.checkpointTable != defaultCheckpointTable {
+		t.Errorf("expected default table %q, got %q", defaultCheckpointTable, s.checkpointTable)
+	}
+}
+
+// TestParseRefreshMode tests parsing refresh modes
+func TestParseRefreshMode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    RefreshMode
+		wantErr bool
+	}{
+		{"", RefreshNone, false},
+		{"none", RefreshNone, false},
+		{"truncate", RefreshTruncate, false},
+		{"swap", RefreshSwap, false},
+		{"drop", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseRefreshMode(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRefreshMode(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("ParseRefreshMode(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+// TestStagedRefreshValidation tests staged refresh checks that run before touching the database
+func TestStagedRefreshValidation(t *testing.T) {
+	long := NewPostgreSQLSink("", strings.Repeat("t", 62), nil)
+	if err := long.BeginStagedRefresh(context.Background()); err == nil {
+		t.Error("expected an error when the staging table name would be too long")
+	}
+
+	s := NewPostgreSQLSink("", "users", nil)
+	if err := s.FinishStagedRefresh(context.Background()); err == nil {
+		t.Error("expected an error finishing a refresh that was not started")
+	}
+	if err := s.AbortStagedRefresh(context.Background()); err != nil {
+		t.Errorf("expected aborting without a refresh to be a no-op, got %v", err)
+	}
+}
+
+// TestSetKeyColumns tests composite key validation and its effect on the upsert columns
+func TestSetKeyColumns(t *testing.T) {
+	tests := []struct {
+		name    string
+		columns []string
+		wantErr bool
+	}{
+		{"default", nil, false},
+		{"composite", []string{"tenant_id", "order_id"}, false},
+		{"invalid name", []string{"tenant_id", "id; DROP TABLE x"}, true},
+		{"duplicate", []string{"order_id", "order_id"}, true},
+		{"denied", []string{"ssn"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewPostgreSQLSink("", "orders", nil)
+			if err := s.SetColumnPolicy(ColumnPolicy{Denied: []string{"ssn"}}); err != nil {
+				t.Fatalf("SetColumnPolicy() error = %v", err)
+			}
+			err := s.SetKeyColumns(tt.columns)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SetKeyColumns() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	s := NewPostgreSQLSink("", "orders", nil)
+	if err := s.SetKeyColumns([]string{"tenant_id", "order_id"}); err != nil {
+		t.Fatalf("SetKeyColumns() error = %v", err)
+	}
+	if err := s.SetColumnPolicy(ColumnPolicy{Allowed: []string{"total"}}); err != nil {
+		t.Fatalf("SetColumnPolicy() error = %v", err)
+	}
+	columns, _, err := s.rowColumns(pipeline.Event{Data: map[string]interface{}{"tenant_id": 1, "order_id": 2, "total": 3}}, false)
+	if err != nil {
+		t.Fatalf("rowColumns() error = %v", err)
+	}
+	if len(columns) != 3 {
+		t.Errorf("expected key columns to bypass the allow list, got %v", columns)
+	}
+	if got, want := s.buildUpdateClause([]string{"tenant_id", "order_id", "total"}), "total = EXCLUDED.total"; got != want {
+		t.Errorf("buildUpdateClause() = %q, want %q", got, want)
+	}
+	if err := s.SetColumnPolicy(ColumnPolicy{Denied: []string{"order_id"}}); err == nil {
+		t.Error("expected an error when denying a key column")
+	}
+}
+
+// TestEventKey tests that key columns fall back to the source document key
+func TestEventKey(t *testing.T) {
+	s := NewPostgreSQLSink("", "orders", nil)
+	if err := s.SetKeyColumns([]string{"tenant_id", "_id"}); err != nil {
+		t.Fatalf("SetKeyColumns() error = %v", err)
+	}
+
+	event := pipeline.Event{
+		ID:        "token",
+		Operation: "delete",
+		Data:      map[string]interface{}{"tenant_id": "t1"},
+		Key:       map[string]interface{}{"_id": "abc"},
+	}
+	key, err := s.eventKey(event)
+	if err != nil {
+		t.Fatalf("eventKey() error = %v", err)
+	}
+	if key["tenant_id"] != "t1" || key["_id"] != "abc" {
+		t.Errorf("eventKey() = %v", key)
+	}
+
+	event.Key = nil
+	if _, err := s.eventKey(event); err == nil {
+		t.Error("expected an error for a delete without a key")
+	}
+}
+
+// TestEventTable tests that routed events are written to their validated destination
+func TestEventTable(t *testing.T) {
+	s := NewPostgreSQLSink("", "orders", nil)
+	tests := []struct {
+		destination string
+		want        string
+		wantErr     bool
+	}{
+		{"", "orders", false},
+		{"orders_eu", "orders_eu", false},
+		{"orders; DROP TABLE x", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := s.eventTable(pipeline.Event{Destination: tt.destination})
+		if (err != nil) != tt.wantErr {
+			t.Errorf("eventTable(%q) error = %v, wantErr %v", tt.destination, err, tt.wantErr)
+		}
+		if got != tt.want {
+			t.Errorf("eventTable(%q) = %q, want %q", tt.destination, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package sink
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// statementTimeout is the SQLSTATE of a statement cancelled by the server,
+// for statement_timeout or a cancel request
+const statementTimeout = "57014"
+
+// SetWriteTimeout bounds each batch transaction. The statements of a batch
+// that runs longer are cancelled, and since the server enforces the timeout
+// as well, they do not outlive it even when the cancel request cannot reach
+// the server. A timed-out batch fails like a lost connection, so the circuit
+// breaker retries it.
+func (p *PostgreSQLSink) SetWriteTimeout(timeout time.Duration) {
+	p.writeTimeout = timeout
+}
+
+// beginBatch starts the transaction of a batch under the write timeout. The
+// returned function rolls the transaction back unless it was committed.
+func (p *PostgreSQLSink) beginBatch(ctx context.Context) (context.Context, *sql.Tx, func(), error) {
+	cancel := func() {}
+	if p.writeTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, p.writeTimeout)
+	}
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		cancel()
+		return nil, nil, nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	done := func() {
+		if rbErr := tx.Rollback(); rbErr != nil && rbErr != sql.ErrTxDone {
+			p.logger.Printf("Warning: failed to rollback transaction: %v", rbErr)
+		}
+		cancel()
+	}
+
+	if p.writeTimeout > 0 {
+		// The server cancels the statements itself should the connection hang
+		query := fmt.Sprintf("SET LOCAL statement_timeout = %d", p.writeTimeout.Milliseconds())
+		if _, err := tx.ExecContext(ctx, query); err != nil {
+			done()
+			return nil, nil, nil, fmt.Errorf("failed to set statement timeout: %w", err)
+		}
+	}
+	return ctx, tx, done, nil
+}
+
+// timedOut describes the error of a batch that ran out of time. Errors of a
+// batch cancelled by the caller, such as at shutdown, are returned as they are.
+func (p *PostgreSQLSink) timedOut(ctx context.Context, err error, events int) error {
+	if err == nil || p.writeTimeout == 0 || ctx.Err() != nil {
+		return err
+	}
+	var pqErr *pq.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &pqErr) && pqErr.Code == statementTimeout) {
+		return fmt.Errorf("batch of %d events timed out after %s: %w", events, p.writeTimeout, err)
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"github.com/lib/pq"
+)
+
+// TestTimedOut tests which batch errors are reported as timeouts
+func TestTimedOut(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		timeout time.Duration
+		err     error
+		want    bool
+	}{
+		{name: "deadline", ctx: context.Background(), timeout: time.Second, err: context.DeadlineExceeded, want: true},
+		{name: "statement timeout", ctx: context.Background(), timeout: time.Second, err: &pq.Error{Code: "57014"}, want: true},
+		{name: "other error", ctx: context.Background(), timeout: time.Second, err: &pq.Error{Code: "23502"}},
+		{name: "without timeout", ctx: context.Background(), err: context.DeadlineExceeded},
+		{name: "cancelled by caller", ctx: cancelled, timeout: time.Second, err: &pq.Error{Code: "57014"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := NewPostgreSQLSink("dummy", "users", nil)
+			sink.SetWriteTimeout(tt.timeout)
+			err := sink.timedOut(tt.ctx, tt.err, 10)
+			if got := strings.Contains(err.Error(), "timed out after"); got != tt.want {
+				t.Errorf("timedOut() = %v, want a timeout %v", err, tt.want)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("expected %v to wrap %v", err, tt.err)
+			}
+		})
+	}
+}
+
+// TestWriteAbandonsOnShutdown tests that the sink stops writing once its
+// context is cancelled and reports the events it did not write as failed
+func TestWriteAbandonsOnShutdown(t *testing.T) {
+	sink := NewPostgreSQLSink("dummy", "users", nil)
+	var mu sync.Mutex
+	var failed []string
+	sink.SetCommitHandler(func(events []pipeline.Event, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the batch to fail with the cancellation, got %v", err)
+		}
+		for _, event := range events {
+			failed = append(failed, event.ID)
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan pipeline.Event)
+	errs := sink.Write(ctx, events)
+	events <- pipeline.Event{ID: "1"}
+	events <- pipeline.Event{ID: "2"}
+	cancel()
+	// The sink keeps draining, so the pipeline never blocks handing events over
+	events <- pipeline.Event{ID: "3"}
+	close(events)
+	for err := range errs {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if strings.Join(failed, ",") != "1,2,3" {
+		t.Errorf("expected events 1, 2 and 3 to be reported unwritten, got %v", failed)
+	}
+}
@@ -0,0 +1,118 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TimescaleConfig turns the sink's table into a TimescaleDB hypertable
+type TimescaleConfig struct {
+	TimeColumn    string        // Partitioning column; defaults to the event time column in append mode and the valid-from column in history mode
+	ChunkInterval time.Duration // Time range of each chunk (default: TimescaleDB's, 7 days)
+	CompressAfter time.Duration // Compress chunks older than this; 0 leaves chunks uncompressed
+	SegmentBy     []string      // Columns compressed data is segmented by, typically the key columns without the time column
+	OrderBy       string        // Order of compressed rows (default: the time column, descending)
+}
+
+// timescaleStatement is a statement run to set up a hypertable
+type timescaleStatement struct {
+	query string
+	args  []interface{}
+}
+
+// SetTimescale makes Connect turn the table into a hypertable partitioned by
+// time, with an optional chunk interval and compression policy. Call it
+// after SetKeyColumns and the write mode. TimescaleDB requires unique
+// indexes to include the time column, so in upsert mode it must be one of
+// the key columns and come from the documents.
+func (p *PostgreSQLSink) SetTimescale(config TimescaleConfig) error {
+	if config.TimeColumn == "" {
+		switch p.writeMode {
+		case WriteAppend:
+			config.TimeColumn = p.eventLog.Timestamp
+		case WriteHistory:
+			config.TimeColumn = p.history.ValidFrom
+		default:
+			return fmt.Errorf("a TimescaleDB time column is required in %s mode", p.writeMode)
+		}
+	}
+	if !validTableName.MatchString(config.TimeColumn) {
+		return fmt.Errorf("invalid TimescaleDB time column: %s", config.TimeColumn)
+	}
+	if p.writeMode == WriteUpsert && !p.isKeyColumn(config.TimeColumn) {
+		return fmt.Errorf("TimescaleDB time column %s must be one of the key columns in upsert mode", config.TimeColumn)
+	}
+	if config.ChunkInterval < 0 || config.CompressAfter < 0 {
+		return fmt.Errorf("TimescaleDB chunk interval and compression age must not be negative")
+	}
+	for _, column := range config.SegmentBy {
+		if !validTableName.MatchString(column) {
+			return fmt.Errorf("invalid TimescaleDB segment by column: %s", column)
+		}
+	}
+	if config.OrderBy == "" {
+		config.OrderBy = config.TimeColumn + " DESC"
+	}
+	for _, term := range strings.Split(config.OrderBy, ",") {
+		fields := strings.Fields(term)
+		if len(fields) == 0 || len(fields) > 2 || !validTableName.MatchString(fields[0]) ||
+			(len(fields) == 2 && !strings.EqualFold(fields[1], "ASC") && !strings.EqualFold(fields[1], "DESC")) {
+			return fmt.Errorf("invalid TimescaleDB order by: %s", config.OrderBy)
+		}
+	}
+	p.timescale = &config
+	return nil
+}
+
+// timescaleStatements returns the statements that set up the hypertable. All
+// of them can run again on every start.
+func (p *PostgreSQLSink) timescaleStatements() []timescaleStatement {
+	config := p.timescale
+	create := timescaleStatement{
+		query: "SELECT create_hypertable($1::regclass, $2::name, if_not_exists => TRUE, migrate_data => TRUE)",
+		args:  []interface{}{p.table, config.TimeColumn},
+	}
+	statements := []timescaleStatement{create}
+	if config.ChunkInterval > 0 {
+		// Applies to chunks created from now on, also when the hypertable already existed
+		statements = append(statements, timescaleStatement{
+			query: "SELECT set_chunk_time_interval($1::regclass, $2::interval)",
+			args:  []interface{}{p.table, intervalString(config.ChunkInterval)},
+		})
+	}
+	if config.CompressAfter > 0 {
+		settings := fmt.Sprintf("timescaledb.compress, timescaledb.compress_orderby = '%s'", config.OrderBy)
+		if len(config.SegmentBy) > 0 {
+			settings += fmt.Sprintf(", timescaledb.compress_segmentby = '%s'", strings.Join(config.SegmentBy, ", "))
+		}
+		// Compression settings cannot be changed once chunks are compressed, so they are only set once
+		enable := fmt.Sprintf("DO $$ BEGIN IF NOT EXISTS (SELECT 1 FROM timescaledb_information.hypertables "+
+			"WHERE hypertable_name = '%s' AND compression_enabled) THEN ALTER TABLE %s SET (%s); END IF; END $$", p.table, p.table, settings)
+		statements = append(statements,
+			timescaleStatement{query: enable},
+			timescaleStatement{
+				query: "SELECT add_compression_policy($1::regclass, $2::interval, if_not_exists => TRUE)",
+				args:  []interface{}{p.table, intervalString(config.CompressAfter)},
+			},
+		)
+	}
+	return statements
+}
+
+// intervalString formats a duration as a PostgreSQL interval
+func intervalString(d time.Duration) string {
+	return fmt.Sprintf("%d seconds", int64(d/time.Second))
+}
+
+// ensureHypertable sets up the hypertable, its chunk interval and its compression policy
+func (p *PostgreSQLSink) ensureHypertable(ctx context.Context) error {
+	for _, statement := range p.timescaleStatements() {
+		if _, err := p.db.ExecContext(ctx, statement.query, statement.args...); err != nil {
+			return fmt.Errorf("failed to set up TimescaleDB hypertable %s: %w", p.table, err)
+		}
+	}
+	p.logger.Printf("Table %s is a TimescaleDB hypertable partitioned by %s", p.table, p.timescale.TimeColumn)
+	return nil
+}
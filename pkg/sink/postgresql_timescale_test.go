@@ -0,0 +1,87 @@
+package sink
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSetTimescale tests time column defaults and validation
+func TestSetTimescale(t *testing.T) {
+	s := NewPostgreSQLSink("", "users", nil)
+	if err := s.SetTimescale(TimescaleConfig{}); err == nil {
+		t.Error("expected error for upsert mode without a time column")
+	}
+	if err := s.SetTimescale(TimescaleConfig{TimeColumn: "updated_at"}); err == nil {
+		t.Error("expected error for a time column that is not a key column in upsert mode")
+	}
+	s.SetKeyColumns([]string{"device_id", "reading_time"})
+	if err := s.SetTimescale(TimescaleConfig{TimeColumn: "reading_time"}); err != nil {
+		t.Fatalf("SetTimescale() error = %v", err)
+	}
+	if s.timescale.OrderBy != "reading_time DESC" {
+		t.Errorf("expected the time column descending as the default order, got %q", s.timescale.OrderBy)
+	}
+
+	s = NewPostgreSQLSink("", "events", nil)
+	s.SetEventLogMode(EventLogColumns{})
+	if err := s.SetTimescale(TimescaleConfig{}); err != nil || s.timescale.TimeColumn != "event_time" {
+		t.Errorf("expected the event time column in append mode, got %v", err)
+	}
+	s = NewPostgreSQLSink("", "users_history", nil)
+	s.SetHistoryMode(HistoryColumns{})
+	if err := s.SetTimescale(TimescaleConfig{}); err != nil || s.timescale.TimeColumn != "valid_from" {
+		t.Errorf("expected the valid-from column in history mode, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		config TimescaleConfig
+	}{
+		{name: "invalid segment by", config: TimescaleConfig{SegmentBy: []string{"id; DROP TABLE x"}}},
+		{name: "invalid order by column", config: TimescaleConfig{OrderBy: "valid_from DESC, x'y"}},
+		{name: "invalid order by direction", config: TimescaleConfig{OrderBy: "valid_from SIDEWAYS"}},
+		{name: "negative chunk interval", config: TimescaleConfig{ChunkInterval: -time.Hour}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.SetTimescale(tt.config); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+// TestTimescaleStatements tests the statements that set up the hypertable
+func TestTimescaleStatements(t *testing.T) {
+	s := NewPostgreSQLSink("", "readings", nil)
+	s.SetEventLogMode(EventLogColumns{})
+	if err := s.SetTimescale(TimescaleConfig{}); err != nil {
+		t.Fatalf("SetTimescale() error = %v", err)
+	}
+	statements := s.timescaleStatements()
+	if len(statements) != 1 || !strings.Contains(statements[0].query, "create_hypertable") ||
+		statements[0].args[0] != "readings" || statements[0].args[1] != "event_time" {
+		t.Fatalf("expected only the hypertable to be created, got %+v", statements)
+	}
+
+	s.SetTimescale(TimescaleConfig{
+		ChunkInterval: 24 * time.Hour,
+		CompressAfter: 7 * 24 * time.Hour,
+		SegmentBy:     []string{"device_id"},
+	})
+	statements = s.timescaleStatements()
+	if len(statements) != 4 {
+		t.Fatalf("expected 4 statements, got %+v", statements)
+	}
+	if statements[1].args[1] != "86400 seconds" {
+		t.Errorf("unexpected chunk interval %v", statements[1].args[1])
+	}
+	want := "ALTER TABLE readings SET (timescaledb.compress, timescaledb.compress_orderby = 'event_time DESC', timescaledb.compress_segmentby = 'device_id')"
+	if !strings.Contains(statements[2].query, want) {
+		t.Errorf("expected %q in %q", want, statements[2].query)
+	}
+	if !strings.Contains(statements[3].query, "add_compression_policy") || statements[3].args[1] != "604800 seconds" {
+		t.Errorf("unexpected compression policy %+v", statements[3])
+	}
+}
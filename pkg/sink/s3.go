@@ -0,0 +1,292 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/compress"
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// defaultObjectEvents is the default number of events written to each S3 object
+const defaultObjectEvents = 10000
+
+// S3SinkConfig contains configuration for the S3 sink
+type S3SinkConfig struct {
+	Bucket           string         // Target bucket
+	Prefix           string         // Key prefix objects are written under
+	Region           string         // Bucket region (default: from the environment)
+	Endpoint         string         // Endpoint override for S3-compatible stores; uses path-style URLs
+	Compression      compress.Codec // Object codec (default: gzip)
+	CompressionLevel int            // Codec-specific level; 0 uses the default
+	ObjectEvents     int            // Events per object (default: 10000)
+	Tombstones       bool           // Write deletes as tombstones: the document key with null data
+}
+
+// S3Sink implements the Sink interface by uploading events to S3 as
+// compressed JSON-lines objects. Each object is a complete, independently
+// readable file; events are committed once their object is uploaded.
+type S3Sink struct {
+	config      S3SinkConfig
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	client      *http.Client
+	clock       pipeline.Clock
+	logger      *log.Logger
+	onCommit    pipeline.CommitHandler
+	sequence    int
+}
+
+// NewS3Sink creates a new S3 sink
+func NewS3Sink(config S3SinkConfig, logger *log.Logger) *S3Sink {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if config.Compression == "" {
+		config.Compression = compress.Gzip
+	}
+	if config.ObjectEvents <= 0 {
+		config.ObjectEvents = defaultObjectEvents
+	}
+	return &S3Sink{
+		config: config,
+		signer: v4.NewSigner(),
+		client: &http.Client{Timeout: 5 * time.Minute},
+		clock:  pipeline.SystemClock,
+		logger: logger,
+	}
+}
+
+// SetCredentials sets the credentials used to sign uploads instead of the default AWS chain
+func (s *S3Sink) SetCredentials(credentials aws.CredentialsProvider) {
+	s.credentials = aws.NewCredentialsCache(credentials)
+}
+
+// SetClock sets the time source used for object names and request signing
+func (s *S3Sink) SetClock(clock pipeline.Clock) {
+	s.clock = clock
+}
+
+// SetCommitHandler registers a handler called after every object upload.
+// The events slice is only valid for the duration of the call.
+func (s *S3Sink) SetCommitHandler(handler pipeline.CommitHandler) {
+	s.onCommit = handler
+}
+
+// Connect loads AWS credentials from the default chain unless credentials were set
+func (s *S3Sink) Connect(ctx context.Context) error {
+	if s.config.Bucket == "" {
+		return fmt.Errorf("S3 sink requires a bucket")
+	}
+	if s.credentials != nil && s.config.Region != "" {
+		return nil
+	}
+
+	var opts []func(*awsconfig.LoadOptions) error
+	if s.config.Region != "" {
+		opts = append(opts, awsconfig.WithRegion(s.config.Region))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+	if cfg.Region == "" {
+		return fmt.Errorf("AWS region is required for the S3 sink")
+	}
+	s.config.Region = cfg.Region
+	if s.credentials == nil {
+		s.credentials = cfg.Credentials
+	}
+	s.logger.Printf("Writing to S3 bucket %s", s.config.Bucket)
+	return nil
+}
+
+// Write collects events into compressed objects and uploads each one once it
+// holds the configured number of events, when the input closes or when the
+// context is cancelled, after which the events still to come are reported as
+// failed
+func (s *S3Sink) Write(ctx context.Context, events <-chan pipeline.Event) <-chan error {
+	errors := make(chan error)
+
+	go func() {
+		defer close(errors)
+
+		var body bytes.Buffer
+		var writer io.WriteCloser
+		var encoder *json.Encoder
+		pending := pipeline.GetBatch(s.config.ObjectEvents)
+		defer func() { pipeline.PutBatch(pending) }()
+
+		upload := func() {
+			if len(pending) == 0 {
+				return
+			}
+			err := writer.Close()
+			if err == nil {
+				_, err = s.upload(context.WithoutCancel(ctx), body.Bytes())
+			}
+			if err != nil {
+				sendError(ctx, errors, err)
+			}
+			if s.onCommit != nil {
+				s.onCommit(pending, err)
+			}
+			pipeline.ReleaseEvents(pending)
+			pending = pending[:0]
+			body.Reset()
+		}
+
+		var abandoned error // set once the context is cancelled
+		for {
+			var event pipeline.Event
+			var ok bool
+			select {
+			case event, ok = <-events:
+			case <-ctx.Done():
+				abandoned = ctx.Err()
+			}
+			if !ok {
+				break
+			}
+
+			if len(pending) == 0 {
+				var err error
+				writer, err = compress.NewWriter(&body, s.config.Compression, s.config.CompressionLevel)
+				if err != nil {
+					sendError(ctx, errors, err)
+					continue
+				}
+				encoder = json.NewEncoder(writer)
+			}
+			record := event
+			if s.config.Tombstones {
+				record = tombstone(event)
+			}
+			if err := encoder.Encode(record); err != nil {
+				sendError(ctx, errors, fmt.Errorf("failed to encode event: %w", err))
+				continue
+			}
+			pending = append(pending, event)
+
+			if len(pending) >= s.config.ObjectEvents {
+				upload()
+			}
+		}
+		upload()
+		if abandoned != nil {
+			abandon(s.logger, nil, events, abandoned, s.onCommit)
+		}
+	}()
+
+	return errors
+}
+
+// upload PUTs an object under a new key and returns the key
+func (s *S3Sink) upload(ctx context.Context, body []byte) (string, error) {
+	key := s.nextKey()
+	sum := sha256.Sum256(body)
+	header := http.Header{"Content-Type": {"application/x-ndjson"}}
+	if encoding := compress.ContentEncoding(s.config.Compression); encoding != "" {
+		header.Set("Content-Encoding", encoding)
+	}
+	if err := s.put(ctx, key, bytes.NewReader(body), int64(len(body)), hex.EncodeToString(sum[:]), header); err != nil {
+		return "", err
+	}
+	s.logger.Printf("Uploaded s3://%s/%s (%d bytes)", s.config.Bucket, key, len(body))
+	return key, nil
+}
+
+// PutObject streams size bytes from body to an object under the prefix, for
+// content stored alongside the events, such as GridFS files. The payload is
+// sent unsigned, so it is not buffered to be hashed.
+func (s *S3Sink) PutObject(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	key = path.Join(s.config.Prefix, key)
+	if err := s.put(ctx, key, body, size, "UNSIGNED-PAYLOAD", http.Header{"Content-Type": {contentType}}); err != nil {
+		return err
+	}
+	s.logger.Printf("Uploaded s3://%s/%s (%d bytes)", s.config.Bucket, key, size)
+	return nil
+}
+
+// put sends a signed PUT of an object
+func (s *S3Sink) put(ctx context.Context, key string, body io.Reader, size int64, payloadHash string, header http.Header) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key), body)
+	if err != nil {
+		return fmt.Errorf("failed to build S3 request: %w", err)
+	}
+	req.ContentLength = size
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	creds, err := s.credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve AWS credentials: %w", err)
+	}
+	if err := s.signer.SignHTTP(ctx, creds, req, payloadHash, "s3", s.config.Region, s.clock.Now()); err != nil {
+		return fmt.Errorf("failed to sign S3 request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload s3://%s/%s: %w", s.config.Bucket, key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to upload s3://%s/%s: %s: %s", s.config.Bucket, key, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// nextKey returns a unique, time-ordered object key
+func (s *S3Sink) nextKey() string {
+	s.sequence++
+	now := s.clock.Now().UTC()
+	name := fmt.Sprintf("%s-%06d.jsonl%s", now.Format("20060102T150405.000Z"), s.sequence, objectExtension(s.config.Compression))
+	return path.Join(s.config.Prefix, now.Format("2006/01/02"), name)
+}
+
+// objectURL returns the URL of an object, virtual-hosted on AWS and
+// path-style on a custom endpoint
+func (s *S3Sink) objectURL(key string) string {
+	escaped := (&url.URL{Path: key}).EscapedPath()
+	if s.config.Endpoint != "" {
+		return strings.TrimSuffix(s.config.Endpoint, "/") + "/" + s.config.Bucket + "/" + escaped
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.config.Bucket, s.config.Region, escaped)
+}
+
+// Close is a no-op; every object is uploaded by Write
+func (s *S3Sink) Close() error {
+	return nil
+}
+
+// objectExtension returns the file extension for objects written with a codec
+func objectExtension(codec compress.Codec) string {
+	switch codec {
+	case compress.Gzip:
+		return ".gz"
+	case compress.Zstd:
+		return ".zst"
+	case compress.Snappy:
+		return ".sz"
+	default:
+		return ""
+	}
+}
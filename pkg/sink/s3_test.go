@@ -0,0 +1,163 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/compress"
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// TestS3SinkUploadsCompressedObjects tests that events are uploaded as signed, compressed objects
+func TestS3SinkUploadsCompressedObjects(t *testing.T) {
+	var mu sync.Mutex
+	objects := map[string][]byte{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		objects[r.URL.Path] = body
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	snk := NewS3Sink(S3SinkConfig{
+		Bucket:       "archive",
+		Prefix:       "cdc",
+		Region:       "us-east-1",
+		Endpoint:     server.URL,
+		Compression:  compress.Zstd,
+		ObjectEvents: 2,
+	}, nil)
+	snk.SetCredentials(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+	}))
+	snk.SetClock(pipeline.NewManualClock(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)))
+
+	var committed int
+	snk.SetCommitHandler(func(events []pipeline.Event, err error) {
+		if err != nil {
+			t.Errorf("commit error = %v", err)
+		}
+		committed += len(events)
+	})
+
+	ctx := context.Background()
+	if err := snk.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	events := make(chan pipeline.Event, 3)
+	for _, id := range []string{"1", "2", "3"} {
+		events <- pipeline.Event{ID: id, Operation: "insert", Data: map[string]interface{}{"_id": id}}
+	}
+	close(events)
+	for err := range snk.Write(ctx, events) {
+		t.Errorf("Write() error = %v", err)
+	}
+
+	if committed != 3 {
+		t.Errorf("expected 3 committed events, got %d", committed)
+	}
+	if len(objects) != 2 {
+		t.Fatalf("expected 2 objects, got %d", len(objects))
+	}
+
+	var lines int
+	for key, body := range objects {
+		if !strings.HasPrefix(key, "/archive/cdc/2026/01/02/") || !strings.HasSuffix(key, ".jsonl.zst") {
+			t.Errorf("unexpected object key %s", key)
+		}
+		r, err := compress.NewReader(bytes.NewReader(body), compress.Zstd)
+		if err != nil {
+			t.Fatalf("NewReader() error = %v", err)
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("failed to decompress %s: %v", key, err)
+		}
+		lines += strings.Count(string(data), "\n")
+	}
+	if lines != 3 {
+		t.Errorf("expected 3 events across objects, got %d", lines)
+	}
+}
+
+// TestS3SinkUploadFailure tests that a rejected upload is reported to the commit handler
+func TestS3SinkUploadFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	snk := NewS3Sink(S3SinkConfig{Bucket: "archive", Region: "us-east-1", Endpoint: server.URL}, nil)
+	snk.SetCredentials(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+	}))
+
+	var commitErr error
+	snk.SetCommitHandler(func(events []pipeline.Event, err error) {
+		commitErr = err
+	})
+
+	events := make(chan pipeline.Event, 1)
+	events <- pipeline.Event{ID: "1", Data: map[string]interface{}{"_id": "1"}}
+	close(events)
+
+	var errs int
+	for range snk.Write(context.Background(), events) {
+		errs++
+	}
+	if errs != 1 || commitErr == nil {
+		t.Errorf("expected the upload failure to be reported, got %d errors, commit error %v", errs, commitErr)
+	}
+}
+
+// TestS3SinkPutObject tests that content is streamed unsigned under the prefix
+func TestS3SinkPutObject(t *testing.T) {
+	var path, contentType, payloadHash, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		path, contentType, payloadHash, body = r.URL.Path, r.Header.Get("Content-Type"), r.Header.Get("X-Amz-Content-Sha256"), string(data)
+	}))
+	defer server.Close()
+
+	snk := NewS3Sink(S3SinkConfig{Bucket: "archive", Prefix: "cdc", Region: "us-east-1", Endpoint: server.URL}, nil)
+	snk.SetCredentials(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+	}))
+	ctx := context.Background()
+	if err := snk.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	if err := snk.PutObject(ctx, "gridfs/fs/abc", strings.NewReader("%PDF-1.7"), 8, "application/pdf"); err != nil {
+		t.Fatalf("PutObject() error = %v", err)
+	}
+	if path != "/archive/cdc/gridfs/fs/abc" || contentType != "application/pdf" || payloadHash != "UNSIGNED-PAYLOAD" || body != "%PDF-1.7" {
+		t.Errorf("unexpected upload %s (%s, %s): %q", path, contentType, payloadHash, body)
+	}
+}
+
+// TestS3SinkDrainsOnShutdown tests that Write uploads what it holds on
+// cancellation and keeps receiving until its input closes, failing the rest
+func TestS3SinkDrainsOnShutdown(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	snk := NewS3Sink(S3SinkConfig{Bucket: "archive", Region: "us-east-1", Endpoint: server.URL, ObjectEvents: 100}, nil)
+	snk.SetCredentials(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+	}))
+	testWriteDrainsOnShutdown(t, snk)
+}
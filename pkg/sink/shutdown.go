@@ -0,0 +1,35 @@
+package sink
+
+import (
+	"context"
+	"log"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// abandon gives up on the held batch and the events still to come when a
+// sink is shut down, reporting them as failed so they are replayed on the
+// next run. Events are drained until the pipeline closes the channel, so it
+// never blocks handing them over.
+func abandon(logger *log.Logger, batch []pipeline.Event, events <-chan pipeline.Event, cause error, report pipeline.CommitHandler) {
+	for event := range events {
+		batch = append(batch, event)
+	}
+	if len(batch) == 0 {
+		return
+	}
+	logger.Printf("Shutting down, %d unwritten events are replayed on the next run", len(batch))
+	if report != nil {
+		report(batch, cause)
+	}
+	pipeline.ReleaseEvents(batch)
+}
+
+// sendError reports a write error, unless the sink is shut down first and the
+// pipeline may no longer be receiving
+func sendError(ctx context.Context, errors chan<- error, err error) {
+	select {
+	case errors <- err:
+	case <-ctx.Done():
+	}
+}
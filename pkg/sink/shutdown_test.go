@@ -0,0 +1,76 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// committingSink is a sink reporting its commits
+type committingSink interface {
+	pipeline.Sink
+	pipeline.CommitNotifier
+}
+
+// testWriteDrainsOnShutdown tests that a sink keeps receiving once its
+// context is cancelled, until the input is closed, and reports every event
+// to the commit handler exactly once, those it gave up on as cancelled
+func testWriteDrainsOnShutdown(t *testing.T, snk committingSink) {
+	t.Helper()
+	var mu sync.Mutex
+	reported := make(map[string]int)
+	var failed int
+	snk.SetCommitHandler(func(events []pipeline.Event, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil && !errors.Is(err, context.Canceled) {
+			t.Errorf("expected events to be written or cancelled, got %v", err)
+		}
+		for _, event := range events {
+			reported[event.ID]++
+			if err != nil {
+				failed++
+			}
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan pipeline.Event)
+	errs := snk.Write(ctx, events)
+	events <- pipeline.Event{ID: "0", Operation: "insert", Data: map[string]interface{}{"_id": "0"}}
+	cancel()
+	// The pipeline keeps handing events over until it closes the input
+	for i := 1; i <= 20; i++ {
+		event := pipeline.Event{ID: fmt.Sprint(i), Operation: "insert", Data: map[string]interface{}{"_id": fmt.Sprint(i)}}
+		select {
+		case events <- event:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Write() stopped receiving after cancellation at event %d", i)
+		}
+	}
+	close(events)
+	select {
+	case <-drain(errs):
+	case <-time.After(5 * time.Second):
+		t.Fatal("Write() did not return after its input closed")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reported) != 21 {
+		t.Errorf("expected all 21 events reported, got %d", len(reported))
+	}
+	for id, n := range reported {
+		if n != 1 {
+			t.Errorf("expected event %s reported once, got %d", id, n)
+		}
+	}
+	if failed == 0 {
+		t.Error("expected the events received after cancellation to be reported as failed")
+	}
+}
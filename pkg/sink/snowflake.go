@@ -0,0 +1,480 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/compress"
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// validSnowflakeName matches the unquoted Snowflake identifiers the sink accepts,
+// optionally qualified by database and schema
+var validSnowflakeName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_$]*(\.[a-zA-Z_][a-zA-Z0-9_$]*){0,2}$`)
+
+// defaultSnowflakeEvents is the default number of events staged in each file
+const defaultSnowflakeEvents = 10000
+
+// snowflakeTokenLifetime is how long a key pair JWT is valid; Snowflake allows at most an hour
+const snowflakeTokenLifetime = time.Hour
+
+// SnowflakeSinkConfig contains configuration for the Snowflake sink
+type SnowflakeSinkConfig struct {
+	Account       string        // Account identifier, e.g. myorg-myaccount
+	User          string        // User authenticating with a key pair
+	PrivateKey    []byte        // PEM-encoded, unencrypted RSA private key of the user
+	Role          string        // Optional role
+	Warehouse     string        // Warehouse running the loads
+	Database      string        // Default database for unqualified names
+	Schema        string        // Default schema for unqualified names
+	Table         string        // Target table
+	Stage         string        // External stage over the S3 location files are uploaded to
+	Columns       []string      // Table columns, filled from the document fields of the same name
+	KeyColumns    []string      // Merge key; empty appends with COPY INTO
+	BatchEvents   int           // Events per staged file (default: 10000)
+	FlushInterval time.Duration // Longest a partial batch waits before it is loaded (default: 10s)
+	Endpoint      string        // SQL API base URL (default: https://<account>.snowflakecomputing.com)
+	Timeout       time.Duration // Statement timeout (default: 10m)
+}
+
+// SnowflakeSink implements the Sink interface by loading batches into
+// Snowflake through a stage, since row-by-row inserts cannot keep up with
+// change data capture. Each batch is uploaded to the S3 location of an
+// external stage as a gzipped JSON-lines file and loaded with one statement
+// through the SQL API: COPY INTO to append, or a MERGE on the key columns
+// that applies the last change of each row, including deletes. Events are
+// committed once the statement succeeds.
+type SnowflakeSink struct {
+	config   SnowflakeSinkConfig
+	stage    *S3Sink
+	key      *rsa.PrivateKey
+	issuer   string // account and user qualified by the public key fingerprint
+	subject  string // account and user
+	token    string
+	expires  time.Time
+	client   *http.Client
+	clock    pipeline.Clock
+	logger   *log.Logger
+	onCommit pipeline.CommitHandler
+}
+
+// NewSnowflakeSink creates a new Snowflake sink that stages files with the
+// given S3 sink. The stage's URL must point at the S3 sink's prefix and its
+// file format must be JSON.
+func NewSnowflakeSink(config SnowflakeSinkConfig, stage *S3Sink, logger *log.Logger) (*SnowflakeSink, error) {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if config.Account == "" || config.User == "" {
+		return nil, fmt.Errorf("Snowflake sink requires an account and user")
+	}
+	if stage == nil {
+		return nil, fmt.Errorf("Snowflake sink requires an S3 location to stage files in")
+	}
+	for _, name := range append([]string{config.Table, config.Stage}, append(config.Columns, config.KeyColumns...)...) {
+		if !validSnowflakeName.MatchString(name) {
+			return nil, fmt.Errorf("invalid Snowflake identifier: %q", name)
+		}
+	}
+	if len(config.Columns) == 0 {
+		return nil, fmt.Errorf("Snowflake sink requires the table's columns")
+	}
+	for _, key := range config.KeyColumns {
+		if !containsFold(config.Columns, key) {
+			return nil, fmt.Errorf("key column %s is not one of the columns", key)
+		}
+	}
+
+	block, _ := pem.Decode(config.PrivateKey)
+	if block == nil {
+		return nil, fmt.Errorf("Snowflake private key is not PEM-encoded")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("invalid Snowflake private key: %w", err)
+		}
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("Snowflake private key must be an RSA key")
+	}
+	public, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Snowflake public key: %w", err)
+	}
+	fingerprint := sha256.Sum256(public)
+
+	if config.BatchEvents <= 0 {
+		config.BatchEvents = defaultSnowflakeEvents
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 10 * time.Second
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Minute
+	}
+	// The account in tokens is upper case and uses dashes instead of the dots of locators with a region
+	account := strings.ToUpper(strings.SplitN(config.Account, ".", 2)[0])
+	if config.Endpoint == "" {
+		config.Endpoint = fmt.Sprintf("https://%s.snowflakecomputing.com", strings.ToLower(config.Account))
+	}
+	stage.config.Compression = compress.Gzip
+
+	subject := account + "." + strings.ToUpper(config.User)
+	return &SnowflakeSink{
+		config:  config,
+		stage:   stage,
+		key:     key,
+		issuer:  subject + ".SHA256:" + base64.StdEncoding.EncodeToString(fingerprint[:]),
+		subject: subject,
+		client:  &http.Client{Timeout: config.Timeout + time.Minute},
+		clock:   pipeline.SystemClock,
+		logger:  logger,
+	}, nil
+}
+
+// containsFold reports whether names contains name, ignoring case like unquoted identifiers
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetClock sets the time source used for tokens, staged file names and flushing
+func (s *SnowflakeSink) SetClock(clock pipeline.Clock) {
+	s.clock = clock
+	s.stage.SetClock(clock)
+}
+
+// SetCommitHandler registers a handler called after every batch load.
+// The events slice is only valid for the duration of the call.
+func (s *SnowflakeSink) SetCommitHandler(handler pipeline.CommitHandler) {
+	s.onCommit = handler
+}
+
+// Connect prepares the stage upload and checks that the SQL API accepts the key pair
+func (s *SnowflakeSink) Connect(ctx context.Context) error {
+	if err := s.stage.Connect(ctx); err != nil {
+		return err
+	}
+	if err := s.execute(ctx, "SELECT 1"); err != nil {
+		return fmt.Errorf("failed to connect to Snowflake: %w", err)
+	}
+	s.logger.Printf("Loading into Snowflake table %s through stage %s", s.config.Table, s.config.Stage)
+	return nil
+}
+
+// Write collects events into batches and loads each one once it holds the
+// configured number of events, once the flush interval has passed since its
+// first event, and when the input closes or the context is cancelled, after
+// which the events still to come are reported as failed
+func (s *SnowflakeSink) Write(ctx context.Context, events <-chan pipeline.Event) <-chan error {
+	errors := make(chan error)
+
+	go func() {
+		defer close(errors)
+
+		pending := pipeline.GetBatch(s.config.BatchEvents)
+		defer func() { pipeline.PutBatch(pending) }()
+
+		load := func() {
+			if len(pending) == 0 {
+				return
+			}
+			err := s.load(context.WithoutCancel(ctx), pending)
+			if err != nil {
+				sendError(ctx, errors, err)
+			}
+			if s.onCommit != nil {
+				s.onCommit(pending, err)
+			}
+			pipeline.ReleaseEvents(pending)
+			pending = pending[:0]
+		}
+
+		// flush fires once a partial batch has waited for the flush interval
+		var flush <-chan time.Time
+		var abandoned error // set once the context is cancelled
+		for {
+			var event pipeline.Event
+			ok := true
+			select {
+			case event, ok = <-events:
+			case <-ctx.Done():
+				ok, abandoned = false, ctx.Err()
+			case <-flush:
+				flush = nil
+				load()
+				continue
+			}
+			if !ok {
+				break
+			}
+
+			pending = append(pending, event)
+			if len(pending) == 1 {
+				flush = s.clock.After(s.config.FlushInterval)
+			}
+			if len(pending) >= s.config.BatchEvents {
+				flush = nil
+				load()
+			}
+		}
+		load()
+		if abandoned != nil {
+			abandon(s.logger, nil, events, abandoned, s.onCommit)
+		}
+	}()
+
+	return errors
+}
+
+// load stages a batch and loads it into the table
+func (s *SnowflakeSink) load(ctx context.Context, events []pipeline.Event) error {
+	file, count, err := s.stageFile(events)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
+	}
+	key, err := s.stage.upload(ctx, file)
+	if err != nil {
+		return fmt.Errorf("failed to stage batch: %w", err)
+	}
+	path := strings.TrimPrefix(strings.TrimPrefix(key, s.stage.config.Prefix), "/")
+
+	statement := s.copyStatement(path)
+	if len(s.config.KeyColumns) > 0 {
+		statement = s.mergeStatement(path)
+	}
+	if err := s.execute(ctx, statement); err != nil {
+		return fmt.Errorf("failed to load %d events into %s: %w", count, s.config.Table, err)
+	}
+	s.logger.Printf("Loaded %d events into %s", count, s.config.Table)
+	return nil
+}
+
+// stageFile encodes a batch as a gzipped JSON-lines file. Without key
+// columns each line is a document and deletes are skipped. With key columns
+// only the last change of each row is kept, as {"op": ..., "data": {...}}.
+func (s *SnowflakeSink) stageFile(events []pipeline.Event) ([]byte, int, error) {
+	var records []interface{}
+	if len(s.config.KeyColumns) == 0 {
+		for _, event := range events {
+			if event.Operation != "delete" {
+				records = append(records, event.Data)
+			}
+		}
+	} else {
+		latest := make(map[string]int) // position of each row's change in records
+		for _, event := range events {
+			key, err := s.rowKey(event)
+			if err != nil {
+				return nil, 0, err
+			}
+			record := map[string]interface{}{"op": event.Operation, "data": event.Data}
+			if event.Operation == "delete" && len(event.Key) > 0 {
+				record["data"] = event.Key
+			}
+			if i, ok := latest[key]; ok {
+				records[i] = record
+				continue
+			}
+			latest[key] = len(records)
+			records = append(records, record)
+		}
+	}
+	if len(records) == 0 {
+		return nil, 0, nil
+	}
+
+	var body bytes.Buffer
+	writer, err := compress.NewWriter(&body, compress.Gzip, 0)
+	if err != nil {
+		return nil, 0, err
+	}
+	encoder := json.NewEncoder(writer)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return nil, 0, fmt.Errorf("failed to encode event: %w", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, 0, fmt.Errorf("failed to compress staged file: %w", err)
+	}
+	return body.Bytes(), len(records), nil
+}
+
+// rowKey identifies the row an event changes by its key column values
+func (s *SnowflakeSink) rowKey(event pipeline.Event) (string, error) {
+	values := make([]interface{}, len(s.config.KeyColumns))
+	for i, column := range s.config.KeyColumns {
+		value, ok := event.Key[column]
+		if !ok {
+			value, ok = event.Data[column]
+		}
+		if !ok {
+			return "", fmt.Errorf("event %s has no value for key column %s", event.ID, column)
+		}
+		values[i] = value
+	}
+	encoded, err := json.Marshal(values)
+	return string(encoded), err
+}
+
+// copyStatement appends the documents of a staged file, matching fields to columns by name
+func (s *SnowflakeSink) copyStatement(path string) string {
+	return fmt.Sprintf("COPY INTO %s (%s) FROM (SELECT %s FROM @%s/%s)",
+		s.config.Table, strings.Join(s.config.Columns, ", "), s.fieldList("$1"), s.config.Stage, path)
+}
+
+// mergeStatement applies the changes of a staged file: deletes remove the
+// row, other changes update it or insert it
+func (s *SnowflakeSink) mergeStatement(path string) string {
+	var on, set, inserted []string
+	for _, key := range s.config.KeyColumns {
+		on = append(on, fmt.Sprintf("t.%s = s.%s", key, key))
+	}
+	for _, column := range s.config.Columns {
+		inserted = append(inserted, "s."+column)
+		if !containsFold(s.config.KeyColumns, column) {
+			set = append(set, fmt.Sprintf("t.%s = s.%s", column, column))
+		}
+	}
+	source := fmt.Sprintf("SELECT $1:op::STRING AS datapipe_op, %s FROM @%s/%s", s.fieldList("$1:data"), s.config.Stage, path)
+	statement := fmt.Sprintf("MERGE INTO %s t USING (%s) s ON %s WHEN MATCHED AND s.datapipe_op = 'delete' THEN DELETE",
+		s.config.Table, source, strings.Join(on, " AND "))
+	if len(set) > 0 {
+		statement += " WHEN MATCHED THEN UPDATE SET " + strings.Join(set, ", ")
+	}
+	return statement + fmt.Sprintf(" WHEN NOT MATCHED AND s.datapipe_op <> 'delete' THEN INSERT (%s) VALUES (%s)",
+		strings.Join(s.config.Columns, ", "), strings.Join(inserted, ", "))
+}
+
+// fieldList selects each column from the field of the same name in a JSON document
+func (s *SnowflakeSink) fieldList(document string) string {
+	fields := make([]string, len(s.config.Columns))
+	for i, column := range s.config.Columns {
+		fields[i] = fmt.Sprintf(`%s:"%s" AS %s`, document, column, column)
+	}
+	return strings.Join(fields, ", ")
+}
+
+// snowflakeResponse is the part of an SQL API response the sink reads
+type snowflakeResponse struct {
+	Message         string `json:"message"`
+	StatementHandle string `json:"statementHandle"`
+}
+
+// execute runs a statement through the SQL API, waiting for it to finish
+func (s *SnowflakeSink) execute(ctx context.Context, statement string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"statement": statement,
+		"timeout":   int(s.config.Timeout / time.Second),
+		"database":  s.config.Database,
+		"schema":    s.config.Schema,
+		"warehouse": s.config.Warehouse,
+		"role":      s.config.Role,
+	})
+	if err != nil {
+		return err
+	}
+	status, result, err := s.request(ctx, http.MethodPost, "/api/v2/statements", body)
+	// 202 means the statement is still running
+	for err == nil && status == http.StatusAccepted {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-s.clock.After(time.Second):
+		}
+		status, result, err = s.request(ctx, http.MethodGet, "/api/v2/statements/"+result.StatementHandle, nil)
+	}
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("statement failed: %d %s", status, result.Message)
+	}
+	return nil
+}
+
+// request sends an authenticated SQL API request
+func (s *SnowflakeSink) request(ctx context.Context, method, path string, body []byte) (int, snowflakeResponse, error) {
+	var result snowflakeResponse
+	token, err := s.jwt()
+	if err != nil {
+		return 0, result, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(s.config.Endpoint, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, result, fmt.Errorf("failed to build Snowflake request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("X-Snowflake-Authorization-Token-Type", "KEYPAIR_JWT")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, result, fmt.Errorf("failed to reach Snowflake: %w", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if json.Unmarshal(data, &result) != nil && resp.StatusCode != http.StatusOK {
+		result.Message = strings.TrimSpace(string(data))
+	}
+	return resp.StatusCode, result, nil
+}
+
+// jwt returns a key pair token, signing a new one when the current one is
+// about to expire
+func (s *SnowflakeSink) jwt() (string, error) {
+	now := s.clock.Now()
+	if s.token != "" && now.Before(s.expires.Add(-5*time.Minute)) {
+		return s.token, nil
+	}
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss": s.issuer,
+		"sub": s.subject,
+		"iat": now.Unix(),
+		"exp": now.Add(snowflakeTokenLifetime).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("failed to sign Snowflake token: %w", err)
+	}
+	s.token = unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)
+	s.expires = now.Add(snowflakeTokenLifetime)
+	return s.token, nil
+}
+
+// Close is a no-op; every batch is loaded by Write
+func (s *SnowflakeSink) Close() error {
+	return nil
+}
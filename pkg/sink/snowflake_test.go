@@ -0,0 +1,276 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/compress"
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// newTestSnowflakeSink creates a Snowflake sink with a fresh key pair, staging to an S3 endpoint
+func newTestSnowflakeSink(t *testing.T, config SnowflakeSinkConfig, s3Endpoint string) *SnowflakeSink {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey() error = %v", err)
+	}
+	der, _ := x509.MarshalPKCS8PrivateKey(key)
+	config.PrivateKey = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if config.Account == "" {
+		config.Account = "myorg-account"
+		config.User = "loader"
+		config.Table = "users"
+		config.Stage = "cdc_stage"
+		config.Columns = []string{"_id", "name"}
+	}
+	stage := NewS3Sink(S3SinkConfig{Bucket: "cdc", Prefix: "users", Region: "us-east-1", Endpoint: s3Endpoint}, nil)
+	stage.SetCredentials(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+	}))
+	s, err := NewSnowflakeSink(config, stage, nil)
+	if err != nil {
+		t.Fatalf("NewSnowflakeSink() error = %v", err)
+	}
+	return s
+}
+
+// TestNewSnowflakeSinkValidation tests configuration validation
+func TestNewSnowflakeSinkValidation(t *testing.T) {
+	stage := NewS3Sink(S3SinkConfig{Bucket: "cdc"}, nil)
+	valid := SnowflakeSinkConfig{Account: "a", User: "u", Table: "users", Stage: "s", Columns: []string{"_id"}}
+	tests := []struct {
+		name   string
+		modify func(c *SnowflakeSinkConfig)
+	}{
+		{name: "missing user", modify: func(c *SnowflakeSinkConfig) { c.User = "" }},
+		{name: "invalid table", modify: func(c *SnowflakeSinkConfig) { c.Table = "users; DROP TABLE x" }},
+		{name: "no columns", modify: func(c *SnowflakeSinkConfig) { c.Columns = nil }},
+		{name: "key not a column", modify: func(c *SnowflakeSinkConfig) { c.KeyColumns = []string{"id"} }},
+		{name: "invalid private key", modify: func(c *SnowflakeSinkConfig) { c.PrivateKey = []byte("not a key") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := valid
+			tt.modify(&config)
+			if _, err := NewSnowflakeSink(config, stage, nil); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+// TestSnowflakeJWT tests that key pair tokens carry the expected claims and signature
+func TestSnowflakeJWT(t *testing.T) {
+	s := newTestSnowflakeSink(t, SnowflakeSinkConfig{}, "")
+	s.SetClock(pipeline.NewManualClock(time.Unix(1700000000, 0)))
+	token, err := s.jwt()
+	if err != nil {
+		t.Fatalf("jwt() error = %v", err)
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		t.Fatalf("expected a three-part token, got %q", token)
+	}
+	claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	var decoded map[string]interface{}
+	json.Unmarshal(claims, &decoded)
+	if decoded["sub"] != "MYORG-ACCOUNT.LOADER" || !strings.HasPrefix(decoded["iss"].(string), "MYORG-ACCOUNT.LOADER.SHA256:") {
+		t.Errorf("unexpected claims %v", decoded)
+	}
+	if decoded["exp"].(float64)-decoded["iat"].(float64) != 3600 {
+		t.Errorf("expected a one hour token, got %v", decoded)
+	}
+	signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(&s.key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+		t.Errorf("invalid signature: %v", err)
+	}
+	if again, _ := s.jwt(); again != token {
+		t.Error("expected the token to be reused until it nears expiry")
+	}
+}
+
+// TestSnowflakeStatements tests the generated load statements
+func TestSnowflakeStatements(t *testing.T) {
+	s := newTestSnowflakeSink(t, SnowflakeSinkConfig{}, "")
+	want := `COPY INTO users (_id, name) FROM (SELECT $1:"_id" AS _id, $1:"name" AS name FROM @cdc_stage/2026/01/02/f.jsonl.gz)`
+	if got := s.copyStatement("2026/01/02/f.jsonl.gz"); got != want {
+		t.Errorf("copyStatement() = %q, want %q", got, want)
+	}
+
+	s.config.KeyColumns = []string{"_id"}
+	want = `MERGE INTO users t USING (SELECT $1:op::STRING AS datapipe_op, $1:data:"_id" AS _id, $1:data:"name" AS name FROM @cdc_stage/f.gz) s` +
+		` ON t._id = s._id WHEN MATCHED AND s.datapipe_op = 'delete' THEN DELETE` +
+		` WHEN MATCHED THEN UPDATE SET t.name = s.name` +
+		` WHEN NOT MATCHED AND s.datapipe_op <> 'delete' THEN INSERT (_id, name) VALUES (s._id, s.name)`
+	if got := s.mergeStatement("f.gz"); got != want {
+		t.Errorf("mergeStatement() = %q, want %q", got, want)
+	}
+}
+
+// TestSnowflakeStageFile tests that merges stage only the last change of each row
+func TestSnowflakeStageFile(t *testing.T) {
+	s := newTestSnowflakeSink(t, SnowflakeSinkConfig{}, "")
+	events := []pipeline.Event{
+		{ID: "1", Operation: "insert", Data: map[string]interface{}{"_id": "a", "name": "Ann"}},
+		{ID: "2", Operation: "insert", Data: map[string]interface{}{"_id": "b", "name": "Bob"}},
+		{ID: "3", Operation: "delete", Key: map[string]interface{}{"_id": "a"}, Data: map[string]interface{}{"_id": "a"}},
+	}
+
+	readLines := func(file []byte) []string {
+		r, err := compress.NewReader(bytes.NewReader(file), compress.Gzip)
+		if err != nil {
+			t.Fatalf("NewReader() error = %v", err)
+		}
+		data, _ := io.ReadAll(r)
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+
+	file, count, err := s.stageFile(events)
+	if err != nil || count != 2 {
+		t.Fatalf("stageFile() = %d, %v; want 2 appended documents", count, err)
+	}
+	if lines := readLines(file); lines[0] != `{"_id":"a","name":"Ann"}` || lines[1] != `{"_id":"b","name":"Bob"}` {
+		t.Errorf("unexpected appended documents %v", lines)
+	}
+
+	s.config.KeyColumns = []string{"_id"}
+	file, count, err = s.stageFile(events)
+	if err != nil || count != 2 {
+		t.Fatalf("stageFile() = %d, %v; want 2 merged rows", count, err)
+	}
+	if lines := readLines(file); lines[0] != `{"data":{"_id":"a"},"op":"delete"}` || lines[1] != `{"data":{"_id":"b","name":"Bob"},"op":"insert"}` {
+		t.Errorf("unexpected merged rows %v", lines)
+	}
+
+	if _, _, err := s.stageFile([]pipeline.Event{{ID: "4", Operation: "insert", Data: map[string]interface{}{"name": "x"}}}); err == nil {
+		t.Error("expected error for an event without a key")
+	}
+}
+
+// TestSnowflakeSinkWrite tests staging a batch and running its load statement
+func TestSnowflakeSinkWrite(t *testing.T) {
+	var mu sync.Mutex
+	var objects []string
+	s3 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		objects = append(objects, r.URL.Path)
+		mu.Unlock()
+	}))
+	defer s3.Close()
+
+	var statements []string
+	polled := false
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Snowflake-Authorization-Token-Type") != "KEYPAIR_JWT" || !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method == http.MethodGet {
+			polled = r.URL.Path == "/api/v2/statements/h1"
+			w.Write([]byte(`{"message":"Statement executed successfully."}`))
+			return
+		}
+		var body struct {
+			Statement string `json:"statement"`
+			Warehouse string `json:"warehouse"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		statements = append(statements, body.Statement)
+		if strings.HasPrefix(body.Statement, "MERGE") {
+			// Pretend the load is still running
+			w.WriteHeader(http.StatusAccepted)
+			w.Write([]byte(`{"statementHandle":"h1"}`))
+			return
+		}
+		w.Write([]byte(`{"message":"Statement executed successfully."}`))
+	}))
+	defer api.Close()
+
+	s := newTestSnowflakeSink(t, SnowflakeSinkConfig{
+		Account: "acct", User: "loader", Table: "users", Stage: "cdc_stage", Warehouse: "load_wh",
+		Columns: []string{"_id", "name"}, KeyColumns: []string{"_id"}, Endpoint: api.URL,
+	}, s3.URL)
+	clock := pipeline.NewManualClock(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	s.SetClock(clock)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		// Keep time moving so the statement poll and flush timers fire
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+			}
+			if clock.Waiters() > 0 {
+				clock.Advance(time.Second)
+			}
+		}
+	}()
+
+	var committed int
+	s.SetCommitHandler(func(events []pipeline.Event, err error) {
+		if err != nil {
+			t.Errorf("commit error = %v", err)
+		}
+		committed += len(events)
+	})
+	ctx := context.Background()
+	if err := s.Connect(ctx); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	events := make(chan pipeline.Event, 2)
+	events <- pipeline.Event{ID: "1", Operation: "insert", Data: map[string]interface{}{"_id": "a", "name": "Ann"}}
+	events <- pipeline.Event{ID: "2", Operation: "update", Data: map[string]interface{}{"_id": "a", "name": "Anne"}}
+	close(events)
+	for err := range s.Write(ctx, events) {
+		t.Errorf("Write() error = %v", err)
+	}
+
+	if committed != 2 || len(objects) != 1 || !polled {
+		t.Fatalf("expected 2 events committed from 1 staged file after polling, got %d, %v, polled %v", committed, objects, polled)
+	}
+	if !strings.HasPrefix(objects[0], "/cdc/users/2026/01/02/") || !strings.HasSuffix(objects[0], ".jsonl.gz") {
+		t.Errorf("unexpected staged object %s", objects[0])
+	}
+	path := strings.TrimPrefix(objects[0], "/cdc/users/")
+	if len(statements) != 2 || statements[0] != "SELECT 1" || !strings.Contains(statements[1], "FROM @cdc_stage/"+path+")") {
+		t.Errorf("unexpected statements %v", statements)
+	}
+}
+
+// TestSnowflakeSinkDrainsOnShutdown tests that Write loads what it holds on
+// cancellation and keeps receiving until its input closes, failing the rest
+func TestSnowflakeSinkDrainsOnShutdown(t *testing.T) {
+	s3 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer s3.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"message":"Statement executed successfully."}`))
+	}))
+	defer api.Close()
+
+	s := newTestSnowflakeSink(t, SnowflakeSinkConfig{
+		Account: "acct", User: "loader", Table: "users", Stage: "cdc_stage",
+		Columns: []string{"_id"}, Endpoint: api.URL, BatchEvents: 100, FlushInterval: time.Hour,
+	}, s3.URL)
+	testWriteDrainsOnShutdown(t, s)
+}
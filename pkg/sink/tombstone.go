@@ -0,0 +1,20 @@
+package sink
+
+import "github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+
+// tombstone returns the record written for an event by sinks emitting
+// tombstones: deletes become the document key with null data, which
+// compacting consumers and downstream materializers treat as removing the
+// key. Other events are returned unchanged.
+func tombstone(event pipeline.Event) pipeline.Event {
+	if event.Operation != "delete" {
+		return event
+	}
+	if len(event.Key) == 0 {
+		if id, ok := event.Data["_id"]; ok {
+			event.Key = map[string]interface{}{"_id": id}
+		}
+	}
+	event.Data = nil
+	return event
+}
@@ -0,0 +1,104 @@
+package source
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/compress"
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// maxFileLineSize bounds the size of a single JSON line read from a file
+const maxFileLineSize = 64 * 1024 * 1024
+
+// FileSource implements the Source interface by reading JSON-lines event files
+type FileSource struct {
+	path        string
+	compression compress.Codec
+	file        io.ReadCloser
+	logger      *log.Logger
+}
+
+// NewFileSource creates a new file source. If compression is empty the codec
+// is detected from the file extension (.gz, .zst).
+func NewFileSource(path string, compression compress.Codec, logger *log.Logger) *FileSource {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &FileSource{
+		path:        path,
+		compression: compression,
+		logger:      logger,
+	}
+}
+
+// Connect opens the input file
+func (f *FileSource) Connect(ctx context.Context) error {
+	if f.path == "" {
+		return fmt.Errorf("file source requires a path")
+	}
+
+	f.logger.Printf("Opening file source: %s", f.path)
+	file, err := compress.Open(f.path, f.compression)
+	if err != nil {
+		return fmt.Errorf("failed to open file source: %w", err)
+	}
+
+	f.file = file
+	return nil
+}
+
+// Read emits one event per JSON line and closes the channels at end of file
+func (f *FileSource) Read(ctx context.Context) (<-chan pipeline.Event, <-chan error) {
+	events := make(chan pipeline.Event)
+	errors := make(chan error)
+
+	go func() {
+		defer close(events)
+		defer close(errors)
+
+		scanner := bufio.NewScanner(f.file)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxFileLineSize)
+
+		line := 0
+		for scanner.Scan() {
+			line++
+			if len(scanner.Bytes()) == 0 {
+				continue
+			}
+
+			event := pipeline.Event{Data: pipeline.NewData()}
+			if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+				errors <- fmt.Errorf("failed to decode event on line %d: %w", line, err)
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case events <- event:
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			errors <- fmt.Errorf("failed to read file source: %w", err)
+		}
+	}()
+
+	return events, errors
+}
+
+// Close closes the input file
+func (f *FileSource) Close() error {
+	if f.file == nil {
+		return nil
+	}
+	f.logger.Println("Closing file source")
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,179 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// GeneratorConfig describes the synthetic events produced by a GeneratorSource
+type GeneratorConfig struct {
+	Rate        int            // Target events per second (0 = as fast as possible)
+	Count       int            // Total events to generate (0 = until cancelled)
+	Fields      int            // Data fields per event besides _id
+	FieldSize   int            // Length of each generated string value
+	Cardinality int            // Distinct document IDs (0 = every event gets a new ID)
+	Operations  map[string]int // Relative weight of each operation (default: insert only)
+	Seed        int64          // Random seed (0 = time-based)
+}
+
+// GeneratorSource implements the Source interface by generating synthetic
+// events, so transformers and sinks can be benchmarked without a database
+type GeneratorSource struct {
+	config     GeneratorConfig
+	operations []string // operation per weight unit, sampled uniformly
+	clock      pipeline.Clock
+	logger     *log.Logger
+}
+
+// NewGeneratorSource creates a new generator source
+func NewGeneratorSource(config GeneratorConfig, logger *log.Logger) *GeneratorSource {
+	if logger == nil {
+		logger = log.Default()
+	}
+	if config.FieldSize <= 0 {
+		config.FieldSize = 16
+	}
+	if config.Seed == 0 {
+		config.Seed = time.Now().UnixNano()
+	}
+	return &GeneratorSource{
+		config: config,
+		clock:  pipeline.SystemClock,
+		logger: logger,
+	}
+}
+
+// ParseOperationMix parses an operation mix such as "insert:70,update:25,delete:5"
+func ParseOperationMix(s string) (map[string]int, error) {
+	mix := make(map[string]int)
+	if s == "" {
+		return mix, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		name, weight, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid operation mix entry %q (expected operation:weight)", part)
+		}
+		switch name {
+		case "insert", "update", "replace", "delete":
+		default:
+			return nil, fmt.Errorf("unknown operation %q in operation mix", name)
+		}
+		w, err := strconv.Atoi(weight)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("invalid weight %q for operation %s", weight, name)
+		}
+		mix[name] = w
+	}
+	return mix, nil
+}
+
+// SetClock sets the time source used for rate limiting and event timestamps
+func (g *GeneratorSource) SetClock(clock pipeline.Clock) {
+	g.clock = clock
+}
+
+// Connect validates the generator configuration
+func (g *GeneratorSource) Connect(ctx context.Context) error {
+	if g.config.Rate < 0 || g.config.Count < 0 || g.config.Fields < 0 || g.config.Cardinality < 0 {
+		return fmt.Errorf("generator settings must not be negative")
+	}
+
+	g.operations = g.operations[:0]
+	for _, op := range []string{"insert", "update", "replace", "delete"} {
+		for i := 0; i < g.config.Operations[op]; i++ {
+			g.operations = append(g.operations, op)
+		}
+	}
+	if len(g.operations) == 0 {
+		g.operations = []string{"insert"}
+	}
+
+	g.logger.Printf("Generator source: %d fields, rate %d/s, count %d, cardinality %d",
+		g.config.Fields, g.config.Rate, g.config.Count, g.config.Cardinality)
+	return nil
+}
+
+// Read emits generated events until Count is reached or the context is cancelled
+func (g *GeneratorSource) Read(ctx context.Context) (<-chan pipeline.Event, <-chan error) {
+	events := make(chan pipeline.Event)
+	errors := make(chan error)
+
+	go func() {
+		defer close(events)
+		defer close(errors)
+
+		rng := rand.New(rand.NewSource(g.config.Seed))
+		start := g.clock.Now()
+		for n := 0; g.config.Count == 0 || n < g.config.Count; n++ {
+			if g.config.Rate > 0 {
+				due := start.Add(time.Duration(n) * time.Second / time.Duration(g.config.Rate))
+				if wait := due.Sub(g.clock.Now()); wait > 0 {
+					select {
+					case <-ctx.Done():
+						return
+					case <-g.clock.After(wait):
+					}
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case events <- g.generate(rng, n):
+			}
+		}
+	}()
+
+	return events, errors
+}
+
+// generate builds the n-th synthetic event
+func (g *GeneratorSource) generate(rng *rand.Rand, n int) pipeline.Event {
+	id := n
+	if g.config.Cardinality > 0 {
+		id = rng.Intn(g.config.Cardinality)
+	}
+	docID := "doc-" + strconv.Itoa(id)
+
+	event := pipeline.Event{
+		ID:         docID,
+		Operation:  g.operations[rng.Intn(len(g.operations))],
+		Source:     "generator",
+		Collection: "generated",
+		Timestamp:  g.clock.Now(),
+		Data:       pipeline.NewData(),
+	}
+	event.IngestedAt = event.Timestamp
+	event.Data["_id"] = docID
+	if event.Operation == "delete" {
+		return event
+	}
+
+	for i := 0; i < g.config.Fields; i++ {
+		event.Data["field_"+strconv.Itoa(i)] = randomString(rng, g.config.FieldSize)
+	}
+	return event
+}
+
+// randomString returns a random lowercase string of length n
+func randomString(rng *rand.Rand, n int) string {
+	const letters = "abcdefghijklmnopqrstuvwxyz"
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = letters[rng.Intn(len(letters))]
+	}
+	return string(b)
+}
+
+// Close is a no-op for the generator source
+func (g *GeneratorSource) Close() error {
+	return nil
+}
@@ -0,0 +1,124 @@
+package source
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// TestParseOperationMix tests parsing of weighted operation mixes
+func TestParseOperationMix(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    map[string]int
+		wantErr bool
+	}{
+		{"", map[string]int{}, false},
+		{"insert:70,update:25,delete:5", map[string]int{"insert": 70, "update": 25, "delete": 5}, false},
+		{"insert:1, replace:1", map[string]int{"insert": 1, "replace": 1}, false},
+		{"insert", nil, true},
+		{"upsert:1", nil, true},
+		{"insert:-1", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseOperationMix(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseOperationMix(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("ParseOperationMix(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+			for op, w := range tt.want {
+				if got[op] != w {
+					t.Errorf("weight for %s = %d, want %d", op, got[op], w)
+				}
+			}
+		})
+	}
+}
+
+// TestGeneratorSourceShape tests event count, fields and cardinality
+func TestGeneratorSourceShape(t *testing.T) {
+	g := NewGeneratorSource(GeneratorConfig{
+		Count:       200,
+		Fields:      3,
+		FieldSize:   8,
+		Cardinality: 5,
+		Operations:  map[string]int{"insert": 1, "delete": 1},
+		Seed:        42,
+	}, nil)
+	if err := g.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	events, _ := g.Read(context.Background())
+	ids := make(map[string]bool)
+	ops := make(map[string]int)
+	n := 0
+	for event := range events {
+		n++
+		ids[event.ID] = true
+		ops[event.Operation]++
+		if event.Operation == "insert" {
+			if len(event.Data) != 4 {
+				t.Fatalf("expected _id plus 3 fields, got %v", event.Data)
+			}
+			if v := event.Data["field_0"].(string); len(v) != 8 {
+				t.Errorf("expected 8-byte field value, got %q", v)
+			}
+		}
+	}
+
+	if n != 200 {
+		t.Errorf("expected 200 events, got %d", n)
+	}
+	if len(ids) > 5 {
+		t.Errorf("expected at most 5 distinct IDs, got %d", len(ids))
+	}
+	if ops["insert"] == 0 || ops["delete"] == 0 {
+		t.Errorf("expected both operations in the mix, got %v", ops)
+	}
+}
+
+// TestGeneratorSourceRate tests that the generator paces events at the target rate
+func TestGeneratorSourceRate(t *testing.T) {
+	clock := pipeline.NewManualClock(time.Unix(0, 0))
+	g := NewGeneratorSource(GeneratorConfig{Rate: 10, Count: 3}, nil)
+	g.SetClock(clock)
+	if err := g.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	events, _ := g.Read(context.Background())
+
+	<-events // the first event is due immediately
+	for clock.Waiters() == 0 {
+		runtime.Gosched()
+	}
+	select {
+	case <-events:
+		t.Fatal("second event emitted before its slot")
+	default:
+	}
+
+	clock.Advance(100 * time.Millisecond)
+	<-events
+}
+
+// BenchmarkGeneratorSource measures raw event generation throughput
+func BenchmarkGeneratorSource(b *testing.B) {
+	g := NewGeneratorSource(GeneratorConfig{Count: b.N, Fields: 10, Cardinality: 1000, Seed: 1}, nil)
+	if err := g.Connect(context.Background()); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	events, _ := g.Read(context.Background())
+	for range events {
+	}
+}
@@ -4,21 +4,61 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // MongoDBSource implements the Source interface for MongoDB
 type MongoDBSource struct {
-	uri        string
-	database   string
-	collection string
-	client     *mongo.Client
-	logger     *log.Logger
+	uri         string
+	database    string
+	collection  string
+	client      *mongo.Client
+	logger      *log.Logger
+	resumeFrom  bson.Raw // change stream resume token to start after, if any
+	iamAuth     bool     // authenticate with AWS IAM credentials (MONGODB-AWS)
+	uriProvider func(ctx context.Context) (string, error)
+	rewatch     bool     // re-open the change stream after it is invalidated (default true)
+	include     []string // only these fields are fetched, if set
+	exclude     []string // these fields are never fetched
+	readyMu     sync.Mutex
+	ready       chan struct{} // closed once the current change stream is open
+	batchMax    int           // change events grouped before they are handed on, if more than 1
+	batchWindow time.Duration // longest a group waits for more events
+	stretched   atomic.Int64  // batch window while throttled, 0 if not, see ScaleBatchWindow
+	heartbeat   time.Duration // how often an idle change stream reports its position, 0 never
+	idStrategy  IDStrategy    // how event IDs are derived
+	idFields    []string      // fields composite and hash IDs are derived from
+
+	collation *options.Collation   // collation of initial sync queries, nil for the collection default
+	viewOn    string               // collection the configured view is defined on, empty if it is not a view
+	startAt   *primitive.Timestamp // operation time the change stream starts at, if set and there is no resume token
+
+	gridfsBucket string       // GridFS bucket whose files collection is read, if any
+	content      ContentStore // receives the content of GridFS files, if set
+	originField  string       // field of the loop prevention marker, see SetOriginField
+
+	watch func(ctx context.Context, opts *options.ChangeStreamOptions) (changeStream, error) // opens change streams, the collection's by default
+}
+
+// changeStream is the part of a *mongo.ChangeStream read by the source
+type changeStream interface {
+	Next(ctx context.Context) bool
+	TryNext(ctx context.Context) bool
+	Decode(val interface{}) error
+	ResumeToken() bson.Raw
+	Err() error
+	ID() int64
+	Close(ctx context.Context) error
 }
 
 // InitialSyncConfig contains configuration for initial sync
@@ -27,6 +67,20 @@ type InitialSyncConfig struct {
 	TimestampField string
 	FromTimestamp  interface{}
 	BatchSize      int
+	Filter         bson.M // Query limiting the documents copied; nil copies every document
+}
+
+// ParseFilter parses an initial sync query filter from MongoDB extended JSON,
+// such as {"archived": false, "created_at": {"$gte": {"$date": "2024-01-01T00:00:00Z"}}}
+func ParseFilter(data []byte) (bson.M, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var filter bson.M
+	if err := bson.UnmarshalExtJSON(data, false, &filter); err != nil {
+		return nil, fmt.Errorf("invalid initial sync filter: %w", err)
+	}
+	return filter, nil
 }
 
 // NewMongoDBSource creates a new MongoDB source
@@ -39,14 +93,230 @@ func NewMongoDBSource(uri, database, collection string, logger *log.Logger) *Mon
 		database:   database,
 		collection: collection,
 		logger:     logger,
+		rewatch:    true,
+	}
+}
+
+// SetStartPosition makes the change stream resume after the given position,
+// as previously reported in Event.Position
+func (m *MongoDBSource) SetStartPosition(position string) error {
+	m.startAt = nil
+	if position == "" {
+		m.resumeFrom = nil
+		return nil
+	}
+	var token bson.Raw
+	if err := bson.UnmarshalExtJSON([]byte(position), true, &token); err != nil {
+		return fmt.Errorf("invalid resume token: %w", err)
+	}
+	m.resumeFrom = token
+	return nil
+}
+
+// SetStartTime makes the change stream start with the changes made at or
+// after t (startAtOperationTime), which must still be in the oplog. The
+// cluster time has second precision.
+func (m *MongoDBSource) SetStartTime(t time.Time) error {
+	if t.IsZero() || t.After(time.Now()) {
+		return fmt.Errorf("start time must be in the past")
+	}
+	m.resumeFrom = nil
+	m.startAt = &primitive.Timestamp{T: uint32(t.Unix())}
+	return nil
+}
+
+// SetOriginField names the field in which another pipeline marks the
+// documents it writes (see pipeline.Origin). An update only carries the
+// marker in Event.Metadata if it changed the field, so an application update
+// to a replicated document is not mistaken for a replicated one.
+func (m *MongoDBSource) SetOriginField(field string) {
+	m.originField = field
+}
+
+// SetIAMAuth makes the source authenticate with AWS IAM credentials from the
+// default chain (environment, web identity, container or instance role)
+// instead of a password, as supported by Amazon DocumentDB. The driver
+// refreshes temporary credentials itself.
+func (m *MongoDBSource) SetIAMAuth(enabled bool) {
+	m.iamAuth = enabled
+}
+
+// SetRewatchOnInvalidate sets whether the source re-opens the change stream
+// with startAfter when it is invalidated (the collection was dropped or
+// renamed), which it does by default, or ends. The invalidate event is
+// emitted either way, so the pipeline's operation policy decides whether to
+// carry on.
+func (m *MongoDBSource) SetRewatchOnInvalidate(enabled bool) {
+	m.rewatch = enabled
+}
+
+// SetProjection limits the fields fetched from MongoDB to the included ones,
+// or to all but the excluded ones, during initial sync and in change stream
+// full documents and update descriptions. Fields may be dotted paths. _id and
+// the other documentKey fields are always fetched.
+func (m *MongoDBSource) SetProjection(include, exclude []string) error {
+	if len(include) > 0 && len(exclude) > 0 {
+		return fmt.Errorf("a projection can include or exclude fields, not both")
+	}
+	for _, field := range exclude {
+		if field == "_id" {
+			return fmt.Errorf("_id cannot be excluded")
+		}
+	}
+	m.include = include
+	m.exclude = exclude
+	return nil
+}
+
+// SetCollation sets the collation of initial sync queries and counts, so the
+// filter and the timestamp ordering compare strings by the rules of a locale,
+// for example case-insensitively with strength 2. nil uses the collection's
+// default collation. Queries on a view only accept the view's own collation.
+func (m *MongoDBSource) SetCollation(collation *options.Collation) error {
+	if collation != nil {
+		if collation.Locale == "" {
+			return fmt.Errorf("collation requires a locale")
+		}
+		if collation.Strength < 0 || collation.Strength > 5 {
+			return fmt.Errorf("collation strength must be between 1 and 5")
+		}
+	}
+	m.collation = collation
+	return nil
+}
+
+// findProjection returns the projection applied to initial sync queries, or nil
+func (m *MongoDBSource) findProjection() bson.D {
+	var projection bson.D
+	for _, field := range m.include {
+		projection = append(projection, bson.E{Key: field, Value: 1})
+	}
+	for _, field := range m.exclude {
+		projection = append(projection, bson.E{Key: field, Value: 0})
+	}
+	return projection
+}
+
+// changeStreamPipeline returns the change stream aggregation pipeline, which
+// projects the full document. The event's _id (its resume token) and the
+// other fields the source reads are always kept.
+func (m *MongoDBSource) changeStreamPipeline() mongo.Pipeline {
+	var project bson.D
+	if len(m.include) > 0 {
+		for _, field := range []string{"operationType", "documentKey", "ns", "to", "clusterTime", "wallTime", "updateDescription", "fullDocument._id"} {
+			project = append(project, bson.E{Key: field, Value: 1})
+		}
+		for _, field := range m.include {
+			if field != "_id" {
+				project = append(project, bson.E{Key: "fullDocument." + field, Value: 1})
+			}
+		}
+	}
+	for _, field := range m.exclude {
+		project = append(project, bson.E{Key: "fullDocument." + field, Value: 0})
+	}
+	if project == nil {
+		return mongo.Pipeline{}
+	}
+	return mongo.Pipeline{{{Key: "$project", Value: project}}}
+}
+
+// projects reports whether an updated field path is fetched under the
+// projection. Update descriptions key fields by dotted path, so they cannot be
+// projected by the server and are filtered here.
+func (m *MongoDBSource) projects(path string) bool {
+	for _, field := range m.exclude {
+		if path == field || strings.HasPrefix(path, field+".") {
+			return false
+		}
+	}
+	if len(m.include) == 0 {
+		return true
+	}
+	for _, field := range m.include {
+		if path == field || strings.HasPrefix(path, field+".") || strings.HasPrefix(field, path+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// SetBatching groups change events into micro-batches of up to maxEvents,
+// handed downstream together once the group is full or window has passed since
+// its first event, so sinks receive bursts of events to batch instead of a
+// trickle. The change stream then fetches up to maxEvents per round trip.
+// A maxEvents of 0 or 1 hands on every event as soon as it is read.
+func (m *MongoDBSource) SetBatching(maxEvents int, window time.Duration) error {
+	if maxEvents < 0 || window < 0 {
+		return fmt.Errorf("batch size and window must not be negative")
+	}
+	if maxEvents > 1 && window == 0 {
+		return fmt.Errorf("a batch window is required to group change events")
 	}
+	m.batchMax = maxEvents
+	m.batchWindow = window
+	return nil
+}
+
+// ScaleBatchWindow stretches the batch window by factor, so a throttled
+// pipeline receives fewer, larger groups; a factor of 1 restores it
+func (m *MongoDBSource) ScaleBatchWindow(factor float64) {
+	if factor <= 1 {
+		m.stretched.Store(0)
+		return
+	}
+	m.stretched.Store(int64(float64(m.batchWindow) * factor))
+}
+
+// currentBatchWindow returns the batch window, stretched while throttled
+func (m *MongoDBSource) currentBatchWindow() time.Duration {
+	if window := m.stretched.Load(); window > 0 {
+		return time.Duration(window)
+	}
+	return m.batchWindow
+}
+
+// SetHeartbeatInterval makes an idle change stream emit a heartbeat event
+// (pipeline.OperationHeartbeat) at most every interval, carrying the resume
+// token and cluster time of its latest empty fetch, so the checkpoint and lag
+// keep moving while no changes happen. 0 disables heartbeats.
+func (m *MongoDBSource) SetHeartbeatInterval(interval time.Duration) error {
+	if interval < 0 {
+		return fmt.Errorf("heartbeat interval must not be negative")
+	}
+	m.heartbeat = interval
+	return nil
+}
+
+// SetURIProvider makes the source ask the provider for the connection URI on
+// every Connect, so rotated credentials are used when the pipeline reconnects
+func (m *MongoDBSource) SetURIProvider(provider func(ctx context.Context) (string, error)) {
+	m.uriProvider = provider
+}
+
+// Ready returns a channel closed once the change stream opened by Read is
+// established, so changes made afterwards are captured. It is nil before Connect.
+func (m *MongoDBSource) Ready() <-chan struct{} {
+	m.readyMu.Lock()
+	defer m.readyMu.Unlock()
+	return m.ready
 }
 
 // Connect establishes connection to MongoDB
 func (m *MongoDBSource) Connect(ctx context.Context) error {
 	m.logger.Printf("Connecting to MongoDB: %s", m.uri)
 
-	clientOptions := options.Client().ApplyURI(m.uri)
+	uri := m.uri
+	if m.uriProvider != nil {
+		var err error
+		if uri, err = m.uriProvider(ctx); err != nil {
+			return fmt.Errorf("failed to get MongoDB credentials: %w", err)
+		}
+	}
+	clientOptions := options.Client().ApplyURI(uri)
+	if m.iamAuth {
+		clientOptions.SetAuth(options.Credential{AuthMechanism: "MONGODB-AWS", AuthSource: "$external"})
+	}
 	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
 		return fmt.Errorf("failed to connect to MongoDB: %w", err)
@@ -58,34 +328,171 @@ func (m *MongoDBSource) Connect(ctx context.Context) error {
 	}
 
 	m.client = client
+	if err := m.resolveView(ctx); err != nil {
+		client.Disconnect(ctx)
+		return err
+	}
+	m.readyMu.Lock()
+	m.ready = make(chan struct{})
+	m.readyMu.Unlock()
 	m.logger.Println("Successfully connected to MongoDB")
 	return nil
 }
 
+// resolveView looks up whether the configured collection is a view. Views
+// cannot be watched, so the change stream of the collection a view is defined
+// on is watched instead and each changed document is read back through the
+// view; see viewEvent.
+func (m *MongoDBSource) resolveView(ctx context.Context) error {
+	m.viewOn = ""
+	specs, err := m.client.Database(m.database).ListCollectionSpecifications(ctx, bson.M{"name": m.collection})
+	if err != nil {
+		return fmt.Errorf("failed to look up collection %s: %w", m.collection, err)
+	}
+	if len(specs) == 0 || specs[0].Type != "view" {
+		return nil
+	}
+	var view struct {
+		ViewOn string `bson:"viewOn"`
+	}
+	if err := bson.Unmarshal(specs[0].Options, &view); err != nil || view.ViewOn == "" {
+		return fmt.Errorf("failed to read the definition of view %s", m.collection)
+	}
+	m.viewOn = view.ViewOn
+	m.logger.Printf("%s.%s is a view on %s; watching %s and reading changes through the view", m.database, m.collection, view.ViewOn, view.ViewOn)
+	return nil
+}
+
+// viewEvent replaces the data of a change to the collection a view is defined
+// on with the document as the view shows it, looked up by _id. A document the
+// view no longer shows, because it was deleted or is now filtered out, is
+// emitted as a delete. The view must keep the _id of the documents it shows.
+func (m *MongoDBSource) viewEvent(ctx context.Context, event pipeline.Event) (pipeline.Event, error) {
+	switch event.Operation {
+	case "insert", "update", "replace":
+	default:
+		return event, nil
+	}
+	opts := options.FindOne()
+	if projection := m.findProjection(); projection != nil {
+		opts.SetProjection(projection)
+	}
+	var doc bson.M
+	err := m.client.Database(m.database).Collection(m.collection).FindOne(ctx, bson.M{"_id": event.Key["_id"]}, opts).Decode(&doc)
+	if err == mongo.ErrNoDocuments {
+		event.Operation = "delete"
+		event.Data = pipeline.NewData()
+		for k, v := range event.Key {
+			event.Data[k] = v
+		}
+		return event, nil
+	}
+	if err != nil {
+		return event, fmt.Errorf("failed to read change through view %s: %w", m.collection, err)
+	}
+	event.Data = convertBSONToMap(doc)
+	return event, nil
+}
+
+// changeStreamReadAhead is how many decoded change events may wait for the
+// pipeline, so reading the stream overlaps with processing
+const changeStreamReadAhead = 100
+
 // Read reads change events from MongoDB using change streams
 func (m *MongoDBSource) Read(ctx context.Context) (<-chan pipeline.Event, <-chan error) {
-	events := make(chan pipeline.Event)
+	events := make(chan pipeline.Event, changeStreamReadAhead)
 	errors := make(chan error)
 
 	go func() {
 		defer close(events)
 		defer close(errors)
 
-		collection := m.client.Database(m.database).Collection(m.collection)
+		watch := m.watch
+		if watch == nil {
+			collection := m.client.Database(m.database).Collection(m.collection)
+			changePipeline := m.changeStreamPipeline()
+			if m.viewOn != "" {
+				// Documents are projected when they are read through the view
+				collection = m.client.Database(m.database).Collection(m.viewOn)
+				changePipeline = mongo.Pipeline{}
+			}
+			watch = func(ctx context.Context, opts *options.ChangeStreamOptions) (changeStream, error) {
+				return collection.Watch(ctx, changePipeline, opts)
+			}
+		}
+		var startAfter bson.Raw
+		for opened := false; ; opened = true {
+			// Create a change stream
+			opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+			if m.batchMax > 1 {
+				// Bound each fetch by the window, so a waiting group is not held longer
+				opts.SetBatchSize(int32(m.batchMax)).SetMaxAwaitTime(m.batchWindow)
+			}
+			if startAfter != nil {
+				opts.SetStartAfter(startAfter)
+				m.logger.Printf("Re-opening invalidated change stream for %s.%s", m.database, m.collection)
+			} else if m.resumeFrom != nil {
+				opts.SetResumeAfter(m.resumeFrom)
+				m.logger.Printf("Resuming change stream after %s", m.resumeFrom)
+			} else if m.startAt != nil {
+				opts.SetStartAtOperationTime(m.startAt)
+				m.logger.Printf("Starting change stream at %s", time.Unix(int64(m.startAt.T), 0).UTC().Format(time.RFC3339))
+			}
 
-		// Create a change stream
-		pipeline := mongo.Pipeline{}
-		opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+			m.logger.Printf("Starting change stream for %s.%s", m.database, m.collection)
+			stream, err := watch(ctx, opts)
+			if err != nil {
+				errors <- fmt.Errorf("failed to create change stream: %w", err)
+				return
+			}
+			if !opened {
+				m.readyMu.Lock()
+				close(m.ready)
+				m.readyMu.Unlock()
+			}
 
-		m.logger.Printf("Starting change stream for %s.%s", m.database, m.collection)
-		stream, err := collection.Watch(ctx, pipeline, opts)
-		if err != nil {
-			errors <- fmt.Errorf("failed to create change stream: %w", err)
-			return
+			invalidated := m.readStream(ctx, stream, events, errors)
+			stream.Close(ctx)
+			if invalidated == nil || !m.rewatch || ctx.Err() != nil {
+				return
+			}
+			startAfter = invalidated
 		}
-		defer stream.Close(ctx)
+	}()
 
-		for stream.Next(ctx) {
+	return events, errors
+}
+
+// readStream emits the events of a change stream until it ends. If the stream
+// was invalidated, the invalidate event's resume token is returned.
+func (m *MongoDBSource) readStream(ctx context.Context, stream changeStream, events chan<- pipeline.Event, errors chan<- error) bson.Raw {
+	group := eventGroup{max: m.batchMax, window: m.batchWindow}
+	defer group.emit(ctx, events)
+	lastEmitted := time.Now()
+
+	for {
+		// Wait for the first event of a group, then only take what arrives
+		// within its window. With heartbeats, every fetch returns, so idle
+		// periods are noticed.
+		var more bool
+		if group.empty() && m.heartbeat == 0 {
+			more = stream.Next(ctx)
+		} else {
+			more = stream.TryNext(ctx)
+		}
+		if !more {
+			if stream.Err() != nil || stream.ID() == 0 || ctx.Err() != nil {
+				break
+			}
+			if group.empty() && m.heartbeat > 0 && time.Since(lastEmitted) >= m.heartbeat {
+				select {
+				case <-ctx.Done():
+					return nil
+				case events <- m.heartbeatEvent(stream.ResumeToken()):
+				}
+				lastEmitted = time.Now()
+			}
+		} else {
 			var changeDoc bson.M
 			if err := stream.Decode(&changeDoc); err != nil {
 				errors <- fmt.Errorf("failed to decode change event: %w", err)
@@ -93,15 +500,115 @@ func (m *MongoDBSource) Read(ctx context.Context) (<-chan pipeline.Event, <-chan
 			}
 
 			event := m.convertChangeEvent(changeDoc)
-			events <- event
-		}
+			if m.viewOn != "" {
+				var err error
+				if event, err = m.viewEvent(ctx, event); err != nil {
+					errors <- err
+					continue
+				}
+			}
+			event, err := m.copyContent(ctx, event)
+			if err != nil {
+				errors <- err
+				continue
+			}
+			if token, err := bson.MarshalExtJSON(stream.ResumeToken(), true, false); err == nil {
+				event.Position = string(token)
+			}
+			if group.empty() {
+				group.window = m.currentBatchWindow()
+			}
+			group.add(event, time.Now())
+			lastEmitted = time.Now()
 
-		if err := stream.Err(); err != nil {
-			errors <- fmt.Errorf("change stream error: %w", err)
+			if event.Operation == "invalidate" {
+				m.logger.Printf("Change stream for %s.%s was invalidated", m.database, m.collection)
+				return append(bson.Raw(nil), stream.ResumeToken()...)
+			}
 		}
-	}()
+		if group.due(time.Now()) && !group.emit(ctx, events) {
+			return nil
+		}
+	}
 
-	return events, errors
+	if err := stream.Err(); err != nil {
+		errors <- fmt.Errorf("change stream error: %w", err)
+	}
+	return nil
+}
+
+// heartbeatEvent reports the position of an idle change stream
+func (m *MongoDBSource) heartbeatEvent(token bson.Raw) pipeline.Event {
+	event := pipeline.Event{
+		Operation:  pipeline.OperationHeartbeat,
+		Source:     "mongodb",
+		Database:   m.database,
+		Collection: m.collection,
+		Timestamp:  time.Now(),
+	}
+	if at, ok := resumeTokenTime(token); ok {
+		event.Timestamp = at
+	}
+	if position, err := bson.MarshalExtJSON(token, true, false); err == nil && len(token) > 0 {
+		event.Position = string(position)
+	}
+	return event
+}
+
+// resumeTokenTime returns the cluster time encoded in a change stream resume
+// token, whose _data is a hex KeyString starting with the type byte 0x82 and
+// the timestamp's big-endian seconds
+func resumeTokenTime(token bson.Raw) (time.Time, bool) {
+	data, ok := token.Lookup("_data").StringValueOK()
+	if !ok || len(data) < 10 || data[:2] != "82" {
+		return time.Time{}, false
+	}
+	seconds, err := strconv.ParseUint(data[2:10], 16, 32)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(seconds), 0), true
+}
+
+// eventGroup collects change events into a micro-batch
+type eventGroup struct {
+	max      int
+	window   time.Duration
+	events   []pipeline.Event
+	deadline time.Time // when the group is handed on even if not full
+}
+
+// empty reports whether the group holds no events
+func (g *eventGroup) empty() bool {
+	return len(g.events) == 0
+}
+
+// add appends an event, starting the window if it is the group's first
+func (g *eventGroup) add(event pipeline.Event, now time.Time) {
+	if len(g.events) == 0 {
+		g.deadline = now.Add(g.window)
+	}
+	g.events = append(g.events, event)
+}
+
+// due reports whether the group is full or its window has passed
+func (g *eventGroup) due(now time.Time) bool {
+	return len(g.events) > 0 && (len(g.events) >= g.max || !now.Before(g.deadline))
+}
+
+// emit hands the group's events downstream in order and starts a new group.
+// It returns false if ctx was cancelled first; the events not handed on were
+// never checkpointed, so they are read again on the next run.
+func (g *eventGroup) emit(ctx context.Context, events chan<- pipeline.Event) bool {
+	defer func() { g.events = g.events[:0] }()
+	for _, event := range g.events {
+		select {
+		case <-ctx.Done():
+			return false
+		case events <- event:
+		}
+	}
+	return true
 }
 
 // convertChangeEvent converts MongoDB change stream event to pipeline event
@@ -110,7 +617,8 @@ func (m *MongoDBSource) convertChangeEvent(changeDoc bson.M) pipeline.Event {
 		Source:     "mongodb",
 		Database:   m.database,
 		Collection: m.collection,
-		Timestamp:  time.Now(),
+		Timestamp:  changeTime(changeDoc),
+		IngestedAt: time.Now(),
 	}
 
 	if id, ok := changeDoc["_id"]; ok {
@@ -125,23 +633,76 @@ func (m *MongoDBSource) convertChangeEvent(changeDoc bson.M) pipeline.Event {
 		event.Data = convertBSONToMap(fullDoc)
 	}
 
+	// Deletes carry no full document, only its key: _id plus the shard key
+	// fields on sharded collections
+	if docKey, ok := changeDoc["documentKey"].(bson.M); ok {
+		event.Key = make(map[string]interface{}, len(docKey))
+		if event.Data == nil {
+			event.Data = pipeline.NewData()
+		}
+		for k, v := range docKey {
+			event.Key[k] = v
+			if _, exists := event.Data[k]; !exists {
+				event.Data[k] = v
+			}
+		}
+	}
+
 	if updateDesc, ok := changeDoc["updateDescription"].(bson.M); ok {
 		if updatedFields, ok := updateDesc["updatedFields"].(bson.M); ok {
 			if event.Data == nil {
-				event.Data = make(map[string]interface{})
+				event.Data = pipeline.NewData()
 			}
-			for k, v := range convertBSONToMap(updatedFields) {
-				event.Data[k] = v
+			for k, v := range updatedFields {
+				if m.projects(k) {
+					event.Data[k] = v
+				}
+			}
+			if m.originField != "" {
+				marker, _ := updatedFields[m.originField].(string)
+				event.SetMetadata(m.originField, marker)
 			}
 		}
 	}
 
+	event.ID = m.eventID(event)
 	return event
 }
 
+// changeTime returns when a change happened: its wallTime (MongoDB 6.0+), its
+// clusterTime (second precision), or the zero time if it carries neither
+func changeTime(changeDoc bson.M) time.Time {
+	if wall, ok := changeDoc["wallTime"].(primitive.DateTime); ok {
+		return wall.Time()
+	}
+	if cluster, ok := changeDoc["clusterTime"].(primitive.Timestamp); ok && cluster.T > 0 {
+		return time.Unix(int64(cluster.T), 0)
+	}
+	return time.Time{}
+}
+
+// documentTime returns when an initially synced document last changed: the
+// date in its timestamp field, or the zero time if it has none
+func documentTime(doc bson.M, field string) time.Time {
+	if field == "" {
+		return time.Time{}
+	}
+	switch v := doc[field].(type) {
+	case primitive.DateTime:
+		return v.Time()
+	case primitive.Timestamp:
+		if v.T > 0 {
+			return time.Unix(int64(v.T), 0)
+		}
+	case time.Time:
+		return v
+	}
+	return time.Time{}
+}
+
 // convertBSONToMap converts BSON document to map
 func convertBSONToMap(doc bson.M) map[string]interface{} {
-	result := make(map[string]interface{})
+	result := pipeline.NewData()
 	for k, v := range doc {
 		result[k] = v
 	}
@@ -170,14 +731,15 @@ func (m *MongoDBSource) PerformInitialSync(ctx context.Context, config InitialSy
 
 		collection := m.client.Database(m.database).Collection(m.collection)
 
-		// Build query filter
-		filter := bson.M{}
 		if config.TimestampField != "" && config.FromTimestamp != nil {
-			filter[config.TimestampField] = bson.M{"$gte": config.FromTimestamp}
 			m.logger.Printf("Starting initial sync from timestamp: %v on field: %s", config.FromTimestamp, config.TimestampField)
 		} else {
 			m.logger.Printf("Starting full initial sync for %s.%s", m.database, m.collection)
 		}
+		if len(config.Filter) > 0 {
+			m.logger.Printf("Initial sync filter: %v", config.Filter)
+		}
+		filter := initialSyncFilter(config)
 
 		// Set batch size
 		batchSize := config.BatchSize
@@ -187,6 +749,12 @@ func (m *MongoDBSource) PerformInitialSync(ctx context.Context, config InitialSy
 
 		// Query with cursor
 		opts := options.Find().SetBatchSize(int32(batchSize))
+		if projection := m.findProjection(); projection != nil {
+			opts.SetProjection(projection)
+		}
+		if m.collation != nil {
+			opts.SetCollation(m.collation)
+		}
 		if config.TimestampField != "" {
 			// Sort by timestamp field to ensure ordered processing
 			opts.SetSort(bson.D{bson.E{Key: config.TimestampField, Value: 1}})
@@ -210,20 +778,28 @@ func (m *MongoDBSource) PerformInitialSync(ctx context.Context, config InitialSy
 			// Convert to pipeline event
 			event := pipeline.Event{
 				ID:         fmt.Sprintf("%v", doc["_id"]),
-				Timestamp:  time.Now(),
+				Timestamp:  documentTime(doc, config.TimestampField),
+				IngestedAt: time.Now(),
 				Operation:  "insert", // Initial sync is treated as insert
 				Source:     "mongodb",
 				Database:   m.database,
 				Collection: m.collection,
 				Data:       convertBSONToMap(doc),
+				Key:        map[string]interface{}{"_id": doc["_id"]},
+			}
+			event.ID = m.eventID(event)
+			event, err := m.copyContent(ctx, event)
+			if err != nil {
+				errors <- err
+				continue
 			}
 
-			events <- event
-			count++
-
-			if count%1000 == 0 {
-				m.logger.Printf("Initial sync progress: %d documents synced", count)
+			select {
+			case <-ctx.Done():
+				return
+			case events <- event:
 			}
+			count++
 		}
 
 		if err := cursor.Err(); err != nil {
@@ -237,6 +813,136 @@ func (m *MongoDBSource) PerformInitialSync(ctx context.Context, config InitialSy
 	return events, errors
 }
 
+// initialSyncFilter builds the query that selects the documents an initial sync reads
+func initialSyncFilter(config InitialSyncConfig) bson.M {
+	filter := bson.M{}
+	if config.TimestampField != "" && config.FromTimestamp != nil {
+		filter[config.TimestampField] = bson.M{"$gte": config.FromTimestamp}
+	}
+	if len(config.Filter) > 0 {
+		if len(filter) == 0 {
+			return config.Filter
+		}
+		// The user filter may constrain the timestamp field too
+		return bson.M{"$and": bson.A{config.Filter, filter}}
+	}
+	return filter
+}
+
+// CollectionStats describes the size of the source collection
+type CollectionStats struct {
+	Documents      int64 // number of documents
+	Bytes          int64 // uncompressed size of the documents
+	AvgObjectBytes int64 // average document size
+}
+
+// CollectionStats reads the collection's document count and size from
+// collStats. The figures come from collection metadata, so they are cheap to
+// read but may be slightly stale. Views have no stats of their own, so those
+// of the collection a view is defined on are read.
+func (m *MongoDBSource) CollectionStats(ctx context.Context) (CollectionStats, error) {
+	collection := m.collection
+	if m.viewOn != "" {
+		collection = m.viewOn
+	}
+	var result bson.M
+	err := m.client.Database(m.database).RunCommand(ctx, bson.D{{Key: "collStats", Value: collection}}).Decode(&result)
+	if err != nil {
+		return CollectionStats{}, fmt.Errorf("failed to read collection stats: %w", err)
+	}
+	return CollectionStats{
+		Documents:      statsInt(result["count"]),
+		Bytes:          statsInt(result["size"]),
+		AvgObjectBytes: statsInt(result["avgObjSize"]),
+	}, nil
+}
+
+// statsInt converts a collStats number, which may be any BSON numeric type
+func statsInt(v interface{}) int64 {
+	switch n := v.(type) {
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case float64:
+		return int64(n)
+	default:
+		return 0
+	}
+}
+
+// EstimateInitialSync estimates how many documents an initial sync will read
+// and their size. A full sync uses the collection stats; a filtered or
+// incremental sync counts the matching documents and sizes them at the
+// collection's average document size.
+func (m *MongoDBSource) EstimateInitialSync(ctx context.Context, config InitialSyncConfig) (pipeline.SyncEstimate, error) {
+	stats, err := m.CollectionStats(ctx)
+	if err != nil {
+		return pipeline.SyncEstimate{}, err
+	}
+	filter := initialSyncFilter(config)
+	if len(filter) == 0 && m.viewOn == "" {
+		return pipeline.SyncEstimate{Documents: stats.Documents, Bytes: stats.Bytes}, nil
+	}
+
+	collection := m.client.Database(m.database).Collection(m.collection)
+	count, err := collection.CountDocuments(ctx, filter, m.countOptions())
+	if err != nil {
+		return pipeline.SyncEstimate{}, fmt.Errorf("failed to count initial sync documents: %w", err)
+	}
+	return pipeline.SyncEstimate{Documents: count, Bytes: count * stats.AvgObjectBytes}, nil
+}
+
+// CountDocuments counts the documents of the collection matching a filter;
+// a nil filter counts every document. Unlike CollectionStats the count is
+// exact, but it scans the collection or an index.
+func (m *MongoDBSource) CountDocuments(ctx context.Context, filter bson.M) (int64, error) {
+	if filter == nil {
+		filter = bson.M{}
+	}
+	count, err := m.client.Database(m.database).Collection(m.collection).CountDocuments(ctx, filter, m.countOptions())
+	if err != nil {
+		return 0, fmt.Errorf("failed to count documents: %w", err)
+	}
+	return count, nil
+}
+
+// countOptions returns the options of counts, which match documents like the
+// initial sync query does
+func (m *MongoDBSource) countOptions() *options.CountOptions {
+	opts := options.Count()
+	if m.collation != nil {
+		opts.SetCollation(m.collation)
+	}
+	return opts
+}
+
+// Sample returns up to n documents picked at random from the collection
+func (m *MongoDBSource) Sample(ctx context.Context, n int) ([]map[string]interface{}, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("sample size must be positive")
+	}
+	collection := m.client.Database(m.database).Collection(m.collection)
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{{{Key: "$sample", Value: bson.M{"size": n}}}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sample documents: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var documents []map[string]interface{}
+	for cursor.Next(ctx) {
+		var document bson.M
+		if err := cursor.Decode(&document); err != nil {
+			return nil, fmt.Errorf("failed to decode sampled document: %w", err)
+		}
+		documents = append(documents, document)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to sample documents: %w", err)
+	}
+	return documents, nil
+}
+
 // GetLatestTimestamp retrieves the latest timestamp from the collection
 func (m *MongoDBSource) GetLatestTimestamp(ctx context.Context, timestampField string) (interface{}, error) {
 	if timestampField == "" {
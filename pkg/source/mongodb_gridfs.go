@@ -0,0 +1,81 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ContentStore receives the content of GridFS files, such as the S3 sink
+type ContentStore interface {
+	// PutObject stores size bytes read from body under key
+	PutObject(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+}
+
+// SetGridFS makes the source read the files collection of a GridFS bucket,
+// <bucket>.files, so events carry file metadata (filename, length, chunkSize,
+// uploadDate, metadata) rather than content. The chunks collection is not read.
+func (m *MongoDBSource) SetGridFS(bucket string) {
+	m.gridfsBucket = bucket
+	if bucket != "" {
+		m.collection = bucket + ".files"
+	}
+}
+
+// SetGridFSContent makes the source copy the content of every new or replaced
+// GridFS file to store, under gridfs/<bucket>/<file id>, before its event is
+// emitted; the key is added to the event as content_key. Content is not
+// removed from the store when a file is deleted.
+func (m *MongoDBSource) SetGridFSContent(store ContentStore) {
+	m.content = store
+}
+
+// copyContent copies the content of the file of a GridFS metadata event to
+// the content store, if one is set
+func (m *MongoDBSource) copyContent(ctx context.Context, event pipeline.Event) (pipeline.Event, error) {
+	if m.content == nil || m.gridfsBucket == "" {
+		return event, nil
+	}
+	switch event.Operation {
+	case "insert", "replace":
+	default:
+		return event, nil
+	}
+	id, ok := event.Key["_id"]
+	if !ok {
+		return event, nil
+	}
+
+	bucket, err := gridfs.NewBucket(m.client.Database(m.database), options.GridFSBucket().SetName(m.gridfsBucket))
+	if err != nil {
+		return event, fmt.Errorf("failed to open GridFS bucket %s: %w", m.gridfsBucket, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		bucket.SetReadDeadline(deadline)
+	}
+	stream, err := bucket.OpenDownloadStream(id)
+	if err == gridfs.ErrFileNotFound {
+		// Deleted since the change, a later event reports it
+		return event, nil
+	}
+	if err != nil {
+		return event, fmt.Errorf("failed to open GridFS file %v: %w", id, err)
+	}
+	defer stream.Close()
+
+	key := path.Join("gridfs", m.gridfsBucket, idValue(id))
+	contentType, _ := event.Data["contentType"].(string)
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if err := m.content.PutObject(ctx, key, stream, stream.GetFile().Length, contentType); err != nil {
+		return event, fmt.Errorf("failed to copy GridFS file %v: %w", id, err)
+	}
+	event.Data["content_key"] = key
+	return event, nil
+}
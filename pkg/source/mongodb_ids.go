@@ -0,0 +1,129 @@
+package source
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// IDStrategy selects how the MongoDB source derives Event.ID
+type IDStrategy string
+
+const (
+	// IDResumeToken uses the change stream event's _id (its resume token),
+	// different for every change, or the document _id during an initial sync
+	IDResumeToken IDStrategy = "resume_token"
+	// IDDocumentKey uses the document key: _id, followed by the shard key
+	// fields on sharded collections
+	IDDocumentKey IDStrategy = "document_key"
+	// IDComposite uses the values of the configured ID fields
+	IDComposite IDStrategy = "composite"
+	// IDHash uses a SHA-256 hash of the ID fields if configured, or else of
+	// the document key, for a fixed-length ID
+	IDHash IDStrategy = "hash"
+)
+
+// ParseIDStrategy parses an ID strategy from configuration
+func ParseIDStrategy(name string) (IDStrategy, error) {
+	switch IDStrategy(name) {
+	case "":
+		return IDResumeToken, nil
+	case IDResumeToken, IDDocumentKey, IDComposite, IDHash:
+		return IDStrategy(name), nil
+	default:
+		return "", fmt.Errorf("unsupported ID strategy: %s", name)
+	}
+}
+
+// SetIDStrategy sets how event IDs are derived. The composite strategy takes
+// its values from fields, which the hash strategy hashes instead of the
+// document key if set. Values are joined with ':'; an event lacking any of
+// them keeps the ID of the resume token strategy.
+func (m *MongoDBSource) SetIDStrategy(strategy IDStrategy, fields []string) error {
+	if strategy == IDComposite && len(fields) == 0 {
+		return fmt.Errorf("the composite ID strategy requires ID fields")
+	}
+	if len(fields) > 0 && strategy != IDComposite && strategy != IDHash {
+		return fmt.Errorf("ID fields require the composite or hash ID strategy")
+	}
+	m.idStrategy = strategy
+	m.idFields = fields
+	return nil
+}
+
+// eventID derives the ID of a change or initially synced document
+func (m *MongoDBSource) eventID(event pipeline.Event) string {
+	var parts []string
+	var ok bool
+	switch m.idStrategy {
+	case IDDocumentKey:
+		parts, ok = documentKeyParts(event.Key)
+	case IDComposite:
+		parts, ok = fieldParts(event, m.idFields)
+	case IDHash:
+		if len(m.idFields) > 0 {
+			parts, ok = fieldParts(event, m.idFields)
+		} else {
+			parts, ok = documentKeyParts(event.Key)
+		}
+		if ok {
+			sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+			return hex.EncodeToString(sum[:])
+		}
+	}
+	if !ok {
+		return event.ID
+	}
+	return strings.Join(parts, ":")
+}
+
+// documentKeyParts returns the values of a document key, _id first and the
+// shard key fields by name
+func documentKeyParts(key map[string]interface{}) ([]string, bool) {
+	id, ok := key["_id"]
+	if !ok {
+		return nil, false
+	}
+	fields := make([]string, 0, len(key)-1)
+	for field := range key {
+		if field != "_id" {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	parts := []string{idValue(id)}
+	for _, field := range fields {
+		parts = append(parts, idValue(key[field]))
+	}
+	return parts, true
+}
+
+// fieldParts returns the values of fields, taken from the document or else
+// its key, and whether the event has all of them
+func fieldParts(event pipeline.Event, fields []string) ([]string, bool) {
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		value, ok := event.Data[field]
+		if !ok {
+			value, ok = event.Key[field]
+		}
+		if !ok || value == nil {
+			return nil, false
+		}
+		parts[i] = idValue(value)
+	}
+	return parts, true
+}
+
+// idValue formats a key value for an ID, ObjectIDs as their hex string
+func idValue(value interface{}) string {
+	if id, ok := value.(primitive.ObjectID); ok {
+		return id.Hex()
+	}
+	return fmt.Sprint(value)
+}
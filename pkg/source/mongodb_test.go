@@ -0,0 +1,539 @@
+package source
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TestSetProjectionValidation tests that invalid projections are rejected
+func TestSetProjectionValidation(t *testing.T) {
+	tests := []struct {
+		name             string
+		include, exclude []string
+		wantErr          bool
+	}{
+		{"none", nil, nil, false},
+		{"include", []string{"name", "address.city"}, nil, false},
+		{"exclude", nil, []string{"ssn"}, false},
+		{"both", []string{"name"}, []string{"ssn"}, true},
+		{"exclude _id", nil, []string{"_id"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewMongoDBSource("", "db", "coll", nil).SetProjection(tt.include, tt.exclude)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("SetProjection() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestSetCollationValidation tests that invalid collations are rejected and
+// counts use the collation
+func TestSetCollationValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		collation *options.Collation
+		wantErr   bool
+	}{
+		{"none", nil, false},
+		{"locale", &options.Collation{Locale: "en", Strength: 2}, false},
+		{"no locale", &options.Collation{Strength: 2}, true},
+		{"strength", &options.Collation{Locale: "en", Strength: 6}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMongoDBSource("", "db", "coll", nil)
+			err := m.SetCollation(tt.collation)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetCollation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && m.countOptions().Collation != tt.collation {
+				t.Errorf("countOptions() collation = %v, want %v", m.countOptions().Collation, tt.collation)
+			}
+		})
+	}
+}
+
+// TestChangeStreamProjection tests the change stream pipeline and update field filtering
+func TestChangeStreamProjection(t *testing.T) {
+	m := NewMongoDBSource("", "db", "coll", nil)
+	if pipeline := m.changeStreamPipeline(); len(pipeline) != 0 {
+		t.Errorf("expected an empty pipeline without a projection, got %v", pipeline)
+	}
+
+	if err := m.SetProjection(nil, []string{"ssn"}); err != nil {
+		t.Fatalf("SetProjection() error = %v", err)
+	}
+	want := bson.D{{Key: "fullDocument.ssn", Value: 0}}
+	if pipeline := m.changeStreamPipeline(); len(pipeline) != 1 || !reflect.DeepEqual(pipeline[0][0].Value, want) {
+		t.Errorf("changeStreamPipeline() = %v, want $project %v", pipeline, want)
+	}
+	for path, want := range map[string]bool{"name": true, "ssn": false, "ssn.last4": false, "ssn_hash": true} {
+		if got := m.projects(path); got != want {
+			t.Errorf("projects(%q) with exclude = %v, want %v", path, got, want)
+		}
+	}
+
+	if err := m.SetProjection([]string{"name", "address.city"}, nil); err != nil {
+		t.Fatalf("SetProjection() error = %v", err)
+	}
+	project := m.changeStreamPipeline()[0][0].Value.(bson.D)
+	for _, key := range []string{"operationType", "documentKey", "fullDocument._id", "fullDocument.name", "fullDocument.address.city"} {
+		if !hasKey(project, key) {
+			t.Errorf("expected $project to keep %s, got %v", key, project)
+		}
+	}
+	for path, want := range map[string]bool{"name": true, "address": true, "address.city": true, "address.street": false, "ssn": false} {
+		if got := m.projects(path); got != want {
+			t.Errorf("projects(%q) with include = %v, want %v", path, got, want)
+		}
+	}
+}
+
+// hasKey reports whether a document has a key
+func hasKey(d bson.D, key string) bool {
+	for _, e := range d {
+		if e.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// TestParseFilter tests parsing initial sync filters from extended JSON
+func TestParseFilter(t *testing.T) {
+	filter, err := ParseFilter([]byte(`{"archived": false, "created_at": {"$gte": {"$date": "2024-01-01T00:00:00Z"}}}`))
+	if err != nil {
+		t.Fatalf("ParseFilter() error = %v", err)
+	}
+	if filter["archived"] != false {
+		t.Errorf("archived = %v, want false", filter["archived"])
+	}
+	createdAt, ok := filter["created_at"].(bson.M)
+	if !ok {
+		t.Fatalf("created_at = %T, want a document", filter["created_at"])
+	}
+	if _, ok := createdAt["$gte"].(primitive.DateTime); !ok {
+		t.Errorf("$gte = %T, want a date", createdAt["$gte"])
+	}
+
+	if filter, err := ParseFilter(nil); filter != nil || err != nil {
+		t.Errorf("ParseFilter(nil) = %v, %v, want no filter", filter, err)
+	}
+	if _, err := ParseFilter([]byte(`{"archived": `)); err == nil {
+		t.Error("expected an error for invalid JSON")
+	}
+}
+
+// TestInitialSyncFilter tests combining the user filter with the incremental timestamp condition
+func TestInitialSyncFilter(t *testing.T) {
+	userFilter := bson.M{"archived": false}
+	tests := []struct {
+		name   string
+		config InitialSyncConfig
+		want   bson.M
+	}{
+		{name: "full sync", config: InitialSyncConfig{TimestampField: "updated_at"}, want: bson.M{}},
+		{name: "user filter", config: InitialSyncConfig{Filter: userFilter}, want: userFilter},
+		{
+			name:   "incremental",
+			config: InitialSyncConfig{TimestampField: "updated_at", FromTimestamp: 5},
+			want:   bson.M{"updated_at": bson.M{"$gte": 5}},
+		},
+		{
+			name:   "incremental with user filter",
+			config: InitialSyncConfig{TimestampField: "updated_at", FromTimestamp: 5, Filter: userFilter},
+			want:   bson.M{"$and": bson.A{userFilter, bson.M{"updated_at": bson.M{"$gte": 5}}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := initialSyncFilter(tt.config); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("initialSyncFilter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestStatsInt tests reading collStats numbers of any BSON numeric type
+func TestStatsInt(t *testing.T) {
+	for _, v := range []interface{}{int32(42), int64(42), float64(42.9)} {
+		if got := statsInt(v); got != 42 {
+			t.Errorf("statsInt(%T) = %d, want 42", v, got)
+		}
+	}
+	if got := statsInt(nil); got != 0 {
+		t.Errorf("statsInt(nil) = %d, want 0", got)
+	}
+}
+
+// TestSetBatching tests micro-batching validation
+func TestSetBatching(t *testing.T) {
+	m := NewMongoDBSource("", "db", "coll", nil)
+	if err := m.SetBatching(-1, time.Second); err == nil {
+		t.Error("expected error for a negative batch size")
+	}
+	if err := m.SetBatching(100, 0); err == nil {
+		t.Error("expected error for a batch size without a window")
+	}
+	if err := m.SetBatching(1, 0); err != nil {
+		t.Errorf("SetBatching() error = %v", err)
+	}
+	if err := m.SetBatching(100, 50*time.Millisecond); err != nil || m.batchMax != 100 || m.batchWindow != 50*time.Millisecond {
+		t.Errorf("SetBatching() error = %v, got %d and %v", err, m.batchMax, m.batchWindow)
+	}
+
+	m.ScaleBatchWindow(4)
+	if got := m.currentBatchWindow(); got != 200*time.Millisecond {
+		t.Errorf("stretched batch window = %v, want 200ms", got)
+	}
+	m.ScaleBatchWindow(1)
+	if got := m.currentBatchWindow(); got != 50*time.Millisecond {
+		t.Errorf("restored batch window = %v, want 50ms", got)
+	}
+}
+
+// TestEventGroup tests when a group of change events is handed on
+func TestEventGroup(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// Without batching every event is handed on at once
+	unbatched := eventGroup{}
+	unbatched.add(pipeline.Event{ID: "1"}, start)
+	if !unbatched.due(start) {
+		t.Error("expected an unbatched event to be due immediately")
+	}
+
+	g := eventGroup{max: 3, window: 100 * time.Millisecond}
+	if g.due(start) {
+		t.Error("expected an empty group not to be due")
+	}
+	g.add(pipeline.Event{ID: "1"}, start)
+	g.add(pipeline.Event{ID: "2"}, start.Add(50*time.Millisecond))
+	if g.due(start.Add(99 * time.Millisecond)) {
+		t.Error("expected the group to wait for its window")
+	}
+	if !g.due(start.Add(100 * time.Millisecond)) {
+		t.Error("expected the group to be due once its window passed")
+	}
+	g.add(pipeline.Event{ID: "3"}, start.Add(60*time.Millisecond))
+	if !g.due(start.Add(60 * time.Millisecond)) {
+		t.Error("expected a full group to be due")
+	}
+
+	events := make(chan pipeline.Event, 3)
+	g.emit(context.Background(), events)
+	close(events)
+	var ids []string
+	for event := range events {
+		ids = append(ids, event.ID)
+	}
+	if strings.Join(ids, ",") != "1,2,3" || !g.empty() {
+		t.Errorf("expected events emitted in order and the group emptied, got %v", ids)
+	}
+
+	// A new group starts its own window
+	g.add(pipeline.Event{ID: "4"}, start.Add(time.Second))
+	if g.due(start.Add(time.Second + 50*time.Millisecond)) {
+		t.Error("expected a new group to start its own window")
+	}
+	// Emitting stops once the pipeline has stopped receiving
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if g.emit(ctx, make(chan pipeline.Event)) || !g.empty() {
+		t.Error("expected a cancelled emit to return false and drop the group")
+	}
+}
+
+// TestHeartbeatEvent tests that heartbeats carry the resume token and its cluster time
+func TestHeartbeatEvent(t *testing.T) {
+	m := NewMongoDBSource("mongodb://localhost", "db", "users", nil)
+	if err := m.SetHeartbeatInterval(-time.Second); err == nil {
+		t.Error("expected a negative heartbeat interval to be rejected")
+	}
+
+	// Cluster time 1700000000 (0x6553f100), increment 1
+	token, err := bson.Marshal(bson.M{"_data": "826553F100000000012B0229296E04"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := m.heartbeatEvent(token)
+	if event.Operation != pipeline.OperationHeartbeat || event.Collection != "users" {
+		t.Errorf("unexpected heartbeat %+v", event)
+	}
+	if !event.Timestamp.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("expected the token's cluster time, got %v", event.Timestamp)
+	}
+	if event.Position != `{"_data":"826553F100000000012B0229296E04"}` {
+		t.Errorf("unexpected position %q", event.Position)
+	}
+
+	// Tokens without a cluster time fall back to the current time
+	if _, ok := resumeTokenTime(nil); ok {
+		t.Error("expected no cluster time without a token")
+	}
+	if event := m.heartbeatEvent(nil); event.Position != "" || time.Since(event.Timestamp) > time.Minute {
+		t.Errorf("unexpected heartbeat without a token %+v", event)
+	}
+}
+
+// TestChangeTime tests that events are stamped with the time of the change, not of the read
+func TestChangeTime(t *testing.T) {
+	wall := time.Date(2026, 3, 1, 12, 0, 0, 500*int(time.Millisecond), time.UTC)
+	tests := []struct {
+		name string
+		got  time.Time
+		want time.Time
+	}{
+		{"wall time", changeTime(bson.M{"wallTime": primitive.NewDateTimeFromTime(wall), "clusterTime": primitive.Timestamp{T: 1700000000}}), wall},
+		{"cluster time", changeTime(bson.M{"clusterTime": primitive.Timestamp{T: 1700000000, I: 3}}), time.Unix(1700000000, 0)},
+		{"unknown change time", changeTime(bson.M{}), time.Time{}},
+		{"document date", documentTime(bson.M{"updatedAt": primitive.NewDateTimeFromTime(wall)}, "updatedAt"), wall},
+		{"document timestamp", documentTime(bson.M{"updatedAt": primitive.Timestamp{T: 1700000000}}, "updatedAt"), time.Unix(1700000000, 0)},
+		{"document without the field", documentTime(bson.M{"_id": 1}, "updatedAt"), time.Time{}},
+		{"non-date field", documentTime(bson.M{"updatedAt": "yesterday"}, "updatedAt"), time.Time{}},
+		{"no timestamp field", documentTime(bson.M{"updatedAt": primitive.NewDateTimeFromTime(wall)}, ""), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !tt.got.Equal(tt.want) {
+				t.Errorf("got %v, want %v", tt.got, tt.want)
+			}
+		})
+	}
+}
+
+// TestIDStrategy tests that event IDs are derived from the document key or ID fields as configured
+func TestIDStrategy(t *testing.T) {
+	oid, err := primitive.ObjectIDFromHex("65a1b2c3d4e5f60718293a4b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	changeDoc := bson.M{
+		"_id":           bson.M{"_data": "8265"},
+		"operationType": "delete",
+		"documentKey":   bson.M{"_id": oid, "region": "eu"},
+	}
+	update := bson.M{
+		"_id":           bson.M{"_data": "8266"},
+		"operationType": "insert",
+		"documentKey":   bson.M{"_id": oid},
+		"fullDocument":  bson.M{"_id": oid, "tenant": "acme", "order": int32(7)},
+	}
+	hash := func(s string) string {
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+
+	tests := []struct {
+		name     string
+		strategy IDStrategy
+		fields   []string
+		doc      bson.M
+		want     string
+	}{
+		{"resume token", IDResumeToken, nil, changeDoc, "map[_data:8265]"},
+		{"document key", IDDocumentKey, nil, changeDoc, "65a1b2c3d4e5f60718293a4b:eu"},
+		{"composite", IDComposite, []string{"tenant", "order"}, update, "acme:7"},
+		{"composite missing a field", IDComposite, []string{"tenant", "order"}, changeDoc, "map[_data:8265]"},
+		{"hash of the document key", IDHash, nil, changeDoc, hash("65a1b2c3d4e5f60718293a4b\x00eu")},
+		{"hash of ID fields", IDHash, []string{"tenant", "order"}, update, hash("acme\x007")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMongoDBSource("mongodb://localhost", "db", "orders", nil)
+			if err := m.SetIDStrategy(tt.strategy, tt.fields); err != nil {
+				t.Fatalf("SetIDStrategy() error = %v", err)
+			}
+			if got := m.convertChangeEvent(tt.doc).ID; got != tt.want {
+				t.Errorf("ID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ParseIDStrategy("random"); err == nil {
+		t.Error("expected an unknown ID strategy to be rejected")
+	}
+	m := NewMongoDBSource("mongodb://localhost", "db", "orders", nil)
+	if err := m.SetIDStrategy(IDComposite, nil); err == nil {
+		t.Error("expected the composite strategy without fields to be rejected")
+	}
+	if err := m.SetIDStrategy(IDDocumentKey, []string{"tenant"}); err == nil {
+		t.Error("expected ID fields with the document key strategy to be rejected")
+	}
+}
+
+// TestSetGridFS tests that a GridFS bucket reads its files collection
+func TestSetGridFS(t *testing.T) {
+	m := NewMongoDBSource("", "db", "", nil)
+	m.SetGridFS("attachments")
+	if m.collection != "attachments.files" {
+		t.Errorf("expected the files collection, got %s", m.collection)
+	}
+
+	// Without a content store, metadata events pass unchanged
+	event := pipeline.Event{Operation: "insert", Key: map[string]interface{}{"_id": "a"}, Data: map[string]interface{}{"filename": "a.pdf"}}
+	got, err := m.copyContent(context.Background(), event)
+	if err != nil || !reflect.DeepEqual(got, event) {
+		t.Errorf("copyContent() = %+v, %v", got, err)
+	}
+}
+
+// TestSetStartTime tests that a start time replaces the resume token
+func TestSetStartTime(t *testing.T) {
+	m := NewMongoDBSource("", "db", "coll", nil)
+	if err := m.SetStartPosition(`{"_data": "8263"}`); err != nil {
+		t.Fatalf("SetStartPosition() error = %v", err)
+	}
+	at := time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC)
+	if err := m.SetStartTime(at); err != nil {
+		t.Fatalf("SetStartTime() error = %v", err)
+	}
+	if m.resumeFrom != nil || m.startAt == nil || m.startAt.T != uint32(at.Unix()) {
+		t.Errorf("expected start at %v without a resume token, got %v, %v", at, m.startAt, m.resumeFrom)
+	}
+	if err := m.SetStartTime(time.Now().Add(time.Hour)); err == nil {
+		t.Error("expected error for a start time in the future")
+	}
+	if err := m.SetStartPosition(""); err != nil || m.startAt != nil {
+		t.Errorf("expected resetting the position to clear the start time, got %v, %v", m.startAt, err)
+	}
+}
+
+// TestOriginField tests that updates only carry the marker if they changed it
+func TestOriginField(t *testing.T) {
+	m := NewMongoDBSource("", "db", "coll", nil)
+	m.SetOriginField("sync_origin")
+	change := func(updated bson.M) bson.M {
+		return bson.M{
+			"operationType":     "update",
+			"documentKey":       bson.M{"_id": "a"},
+			"fullDocument":      bson.M{"_id": "a", "name": "x", "sync_origin": "postgresql:r1"},
+			"updateDescription": bson.M{"updatedFields": updated},
+		}
+	}
+
+	replicated := m.convertChangeEvent(change(bson.M{"name": "x", "sync_origin": "postgresql:r1"}))
+	if marker, ok := replicated.Metadata["sync_origin"]; !ok || marker != "postgresql:r1" {
+		t.Errorf("expected the replicated update to carry its marker, got %q", marker)
+	}
+	application := m.convertChangeEvent(change(bson.M{"name": "x"}))
+	if marker, ok := application.Metadata["sync_origin"]; !ok || marker != "" {
+		t.Errorf("expected an empty marker for an application update, got %q", marker)
+	}
+}
+
+// fakeChangeStream replays change documents, then ends like a closed cursor
+type fakeChangeStream struct {
+	docs    []bson.M
+	current bson.M
+}
+
+func (s *fakeChangeStream) Next(ctx context.Context) bool {
+	if len(s.docs) == 0 {
+		return false
+	}
+	s.current, s.docs = s.docs[0], s.docs[1:]
+	return true
+}
+
+func (s *fakeChangeStream) TryNext(ctx context.Context) bool { return s.Next(ctx) }
+
+func (s *fakeChangeStream) Decode(val interface{}) error {
+	raw, err := bson.Marshal(s.current)
+	if err != nil {
+		return err
+	}
+	return bson.Unmarshal(raw, val)
+}
+
+func (s *fakeChangeStream) ResumeToken() bson.Raw {
+	raw, _ := bson.Marshal(s.current["_id"])
+	return raw
+}
+
+func (s *fakeChangeStream) Err() error                      { return nil }
+func (s *fakeChangeStream) ID() int64                       { return 0 }
+func (s *fakeChangeStream) Close(ctx context.Context) error { return nil }
+
+// TestRewatchOnInvalidate tests that the change stream is re-opened after the
+// invalidate event by default, and ends there once re-watching is disabled
+func TestRewatchOnInvalidate(t *testing.T) {
+	streams := func() [][]bson.M {
+		return [][]bson.M{
+			{
+				{"_id": bson.M{"_data": "01"}, "operationType": "insert", "documentKey": bson.M{"_id": "a"}, "fullDocument": bson.M{"_id": "a"}},
+				{"_id": bson.M{"_data": "02"}, "operationType": "drop"},
+				{"_id": bson.M{"_data": "03"}, "operationType": "invalidate"},
+			},
+			{
+				{"_id": bson.M{"_data": "04"}, "operationType": "insert", "documentKey": bson.M{"_id": "b"}, "fullDocument": bson.M{"_id": "b"}},
+			},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		disable    bool
+		operations []string
+	}{
+		{name: "default", operations: []string{"insert", "drop", "invalidate", "insert"}},
+		{name: "disabled", disable: true, operations: []string{"insert", "drop", "invalidate"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMongoDBSource("mongodb://localhost", "db", "users", nil)
+			if tt.disable {
+				m.SetRewatchOnInvalidate(false)
+			}
+			m.ready = make(chan struct{})
+			pending := streams()
+			var startAfter []interface{}
+			m.watch = func(ctx context.Context, opts *options.ChangeStreamOptions) (changeStream, error) {
+				startAfter = append(startAfter, opts.StartAfter)
+				stream := &fakeChangeStream{docs: pending[0]}
+				pending = pending[1:]
+				return stream, nil
+			}
+
+			events, errs := m.Read(context.Background())
+			var operations []string
+			for event := range events {
+				operations = append(operations, event.Operation)
+			}
+			for err := range errs {
+				t.Errorf("Read() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(operations, tt.operations) {
+				t.Errorf("expected operations %v, got %v", tt.operations, operations)
+			}
+			if tt.disable {
+				if len(startAfter) != 1 {
+					t.Errorf("expected the stream opened once, got %d", len(startAfter))
+				}
+				return
+			}
+			if len(startAfter) != 2 || startAfter[0] != nil {
+				t.Fatalf("expected the stream re-opened once, got %v", startAfter)
+			}
+			var token bson.M
+			if err := bson.Unmarshal(startAfter[1].(bson.Raw), &token); err != nil || token["_data"] != "03" {
+				t.Errorf("expected the stream re-opened after the invalidate event, got %v", token)
+			}
+		})
+	}
+}
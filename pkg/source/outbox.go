@@ -0,0 +1,322 @@
+package source
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"github.com/lib/pq"
+)
+
+// validIdentifier matches the table and column names an outbox may use
+var validIdentifier = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]{0,62}$`)
+
+// OutboxConfig describes a transactional outbox table in PostgreSQL. Empty
+// names take the defaults shown.
+type OutboxConfig struct {
+	ConnectionString string
+	Table            string        // default: outbox
+	IDColumn         string        // Ordered, unique row ID (default: id)
+	AggregateColumn  string        // Aggregate type, used as the event's collection (default: aggregate)
+	PayloadColumn    string        // JSON object that becomes the event's data (default: payload)
+	CreatedAtColumn  string        // Event timestamp (default: created_at)
+	ProcessedColumn  string        // Boolean set once the row is read (default: processed)
+	OperationColumn  string        // Optional insert, update, replace or delete; rows are inserts without it
+	BatchSize        int           // Rows claimed per poll (default: 100)
+	PollInterval     time.Duration // Wait between polls once the outbox is drained (default: 1s)
+	Heartbeat        time.Duration // Emit a heartbeat after empty polls at most this often (0 never)
+	AckOnCommit      bool          // Mark rows processed once the sink commits their events rather than once they are read
+}
+
+// OutboxSource implements the Source interface by polling an outbox table
+// that applications write to in the same transaction as their own changes,
+// for change data capture without access to the database's replication log.
+// Each poll claims unprocessed rows with FOR UPDATE SKIP LOCKED, hands them
+// to the pipeline in ID order and marks them processed in the same
+// transaction, so rows are delivered at least once and several pipelines can
+// share an outbox.
+//
+// With AckOnCommit, rows are instead read past a cursor without being claimed
+// and marked processed when the pipeline acknowledges their commit, so a row
+// is only done once its event is durably written. Such an outbox must have a
+// single reader.
+type OutboxSource struct {
+	config OutboxConfig
+	db     *sql.DB
+	clock  pipeline.Clock
+	logger *log.Logger
+	after  string // with AckOnCommit, the ID of the last row read in this connection
+}
+
+// NewOutboxSource creates a new outbox source
+func NewOutboxSource(config OutboxConfig, logger *log.Logger) *OutboxSource {
+	if logger == nil {
+		logger = log.Default()
+	}
+	defaults := []struct {
+		field *string
+		value string
+	}{
+		{&config.Table, "outbox"},
+		{&config.IDColumn, "id"},
+		{&config.AggregateColumn, "aggregate"},
+		{&config.PayloadColumn, "payload"},
+		{&config.CreatedAtColumn, "created_at"},
+		{&config.ProcessedColumn, "processed"},
+	}
+	for _, d := range defaults {
+		if *d.field == "" {
+			*d.field = d.value
+		}
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.PollInterval <= 0 {
+		config.PollInterval = time.Second
+	}
+	return &OutboxSource{config: config, clock: pipeline.SystemClock, logger: logger}
+}
+
+// SetClock sets the time source used between polls
+func (o *OutboxSource) SetClock(clock pipeline.Clock) {
+	o.clock = clock
+}
+
+// validate checks the table and column names, which are interpolated into queries
+func (o *OutboxSource) validate() error {
+	names := []string{o.config.Table, o.config.IDColumn, o.config.AggregateColumn, o.config.PayloadColumn, o.config.CreatedAtColumn, o.config.ProcessedColumn}
+	if o.config.OperationColumn != "" {
+		names = append(names, o.config.OperationColumn)
+	}
+	for _, name := range names {
+		if !validIdentifier.MatchString(name) {
+			return fmt.Errorf("invalid outbox table or column name: %s", name)
+		}
+	}
+	return nil
+}
+
+// Connect establishes connection to the database holding the outbox
+func (o *OutboxSource) Connect(ctx context.Context) error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+	o.logger.Printf("Connecting to outbox table %s", o.config.Table)
+	db, err := sql.Open("postgres", o.config.ConnectionString)
+	if err != nil {
+		return fmt.Errorf("failed to connect to PostgreSQL: %w", err)
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return fmt.Errorf("failed to ping PostgreSQL: %w", err)
+	}
+	o.db = db
+	// Rows read but not acknowledged before a reconnect are read again
+	o.after = ""
+	o.logger.Println("Successfully connected to the outbox database")
+	return nil
+}
+
+// Read polls the outbox until the context is cancelled
+func (o *OutboxSource) Read(ctx context.Context) (<-chan pipeline.Event, <-chan error) {
+	events := make(chan pipeline.Event)
+	errors := make(chan error)
+
+	go func() {
+		defer close(events)
+		defer close(errors)
+
+		lastEmitted := o.clock.Now()
+		for ctx.Err() == nil {
+			claimed, err := o.poll(ctx, events, errors)
+			if err != nil && ctx.Err() == nil {
+				select {
+				case errors <- fmt.Errorf("outbox poll failed: %w", err):
+				case <-ctx.Done():
+					return
+				}
+			}
+			if claimed > 0 {
+				lastEmitted = o.clock.Now()
+			} else if err == nil && o.config.Heartbeat > 0 && o.clock.Since(lastEmitted) >= o.config.Heartbeat {
+				// A drained outbox is caught up, so the heartbeat is timed now
+				heartbeat := pipeline.Event{Operation: pipeline.OperationHeartbeat, Source: "outbox", Collection: o.config.Table, Timestamp: o.clock.Now()}
+				select {
+				case events <- heartbeat:
+				case <-ctx.Done():
+					return
+				}
+				lastEmitted = heartbeat.Timestamp
+			}
+			if claimed == o.config.BatchSize && err == nil {
+				continue // more rows are likely waiting
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-o.clock.After(o.config.PollInterval):
+			}
+		}
+	}()
+
+	return events, errors
+}
+
+// poll claims a batch of unprocessed rows, emits them and marks them
+// processed, returning the number of rows claimed. If the context is
+// cancelled before every row is emitted the claim is rolled back, so the
+// rows are read again. With AckOnCommit the rows after the cursor are read
+// and left for Ack to mark processed.
+func (o *OutboxSource) poll(ctx context.Context, events chan<- pipeline.Event, errors chan<- error) (int, error) {
+	tx, err := o.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() {
+		if rbErr := tx.Rollback(); rbErr != nil && rbErr != sql.ErrTxDone {
+			o.logger.Printf("Warning: failed to rollback transaction: %v", rbErr)
+		}
+	}()
+
+	args := []interface{}{o.config.BatchSize}
+	if o.after != "" {
+		args = append(args, o.after)
+	}
+	rows, err := tx.QueryContext(ctx, o.claimQuery(), args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim outbox rows: %w", err)
+	}
+	var claimed []pipeline.Event
+	var ids []string
+	for rows.Next() {
+		var id, aggregate string
+		var payload []byte
+		var createdAt time.Time
+		var operation sql.NullString
+		dest := []interface{}{&id, &aggregate, &payload, &createdAt}
+		if o.config.OperationColumn != "" {
+			dest = append(dest, &operation)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to read outbox row: %w", err)
+		}
+		ids = append(ids, id)
+		event, err := outboxEvent(id, aggregate, payload, createdAt, operation.String)
+		if err != nil {
+			// Skipped rather than left unprocessed, so it cannot stall the outbox
+			errors <- fmt.Errorf("skipping outbox row %s: %w", id, err)
+			continue
+		}
+		claimed = append(claimed, event)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read outbox rows: %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	for _, event := range claimed {
+		select {
+		case events <- event:
+			if o.config.AckOnCommit {
+				o.after = event.Position
+			}
+		case <-ctx.Done():
+			return len(ids), ctx.Err()
+		}
+	}
+	if o.config.AckOnCommit {
+		o.after = ids[len(ids)-1]
+		return len(ids), nil
+	}
+
+	query := fmt.Sprintf("UPDATE %s SET %s = TRUE WHERE %s = ANY($1)", o.config.Table, o.config.ProcessedColumn, o.config.IDColumn)
+	if _, err := tx.ExecContext(ctx, query, pq.Array(ids)); err != nil {
+		return len(ids), fmt.Errorf("failed to mark outbox rows processed: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return len(ids), fmt.Errorf("failed to commit outbox claim: %w", err)
+	}
+	return len(ids), nil
+}
+
+// claimQuery selects and locks the oldest unprocessed rows not claimed by
+// another reader, or with AckOnCommit selects the oldest unprocessed rows
+// after the cursor, passed as $2 once set
+func (o *OutboxSource) claimQuery() string {
+	columns := []string{o.config.IDColumn, o.config.AggregateColumn, o.config.PayloadColumn, o.config.CreatedAtColumn}
+	if o.config.OperationColumn != "" {
+		columns = append(columns, o.config.OperationColumn)
+	}
+	if o.config.AckOnCommit {
+		cursor := ""
+		if o.after != "" {
+			cursor = fmt.Sprintf(" AND %s > $2", o.config.IDColumn)
+		}
+		return fmt.Sprintf("SELECT %s FROM %s WHERE NOT %s%s ORDER BY %s LIMIT $1",
+			strings.Join(columns, ", "), o.config.Table, o.config.ProcessedColumn, cursor, o.config.IDColumn)
+	}
+	return fmt.Sprintf("SELECT %s FROM %s WHERE NOT %s ORDER BY %s LIMIT $1 FOR UPDATE SKIP LOCKED",
+		strings.Join(columns, ", "), o.config.Table, o.config.ProcessedColumn, o.config.IDColumn)
+}
+
+// ackQuery marks every unprocessed row up to an ID processed
+func (o *OutboxSource) ackQuery() string {
+	return fmt.Sprintf("UPDATE %s SET %s = TRUE WHERE %s <= $1 AND NOT %s",
+		o.config.Table, o.config.ProcessedColumn, o.config.IDColumn, o.config.ProcessedColumn)
+}
+
+// Ack marks the rows up to a committed event's row processed, including rows
+// skipped for an invalid payload. It does nothing unless AckOnCommit is set,
+// as rows are then marked when they are read.
+func (o *OutboxSource) Ack(ctx context.Context, position string) error {
+	if !o.config.AckOnCommit {
+		return nil
+	}
+	if _, err := o.db.ExecContext(ctx, o.ackQuery(), position); err != nil {
+		return fmt.Errorf("failed to mark outbox rows processed: %w", err)
+	}
+	return nil
+}
+
+// outboxEvent converts an outbox row to a pipeline event
+func outboxEvent(id, aggregate string, payload []byte, createdAt time.Time, operation string) (pipeline.Event, error) {
+	switch operation {
+	case "":
+		operation = "insert"
+	case "insert", "update", "replace", "delete":
+	default:
+		return pipeline.Event{}, fmt.Errorf("unknown operation %q", operation)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return pipeline.Event{}, fmt.Errorf("payload is not a JSON object: %w", err)
+	}
+	return pipeline.Event{
+		ID:         id,
+		Timestamp:  createdAt,
+		Operation:  operation,
+		Source:     "outbox",
+		Collection: aggregate,
+		Data:       data,
+		Position:   id,
+	}, nil
+}
+
+// Close closes the database connection
+func (o *OutboxSource) Close() error {
+	if o.db != nil {
+		return o.db.Close()
+	}
+	return nil
+}
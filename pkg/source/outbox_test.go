@@ -0,0 +1,92 @@
+package source
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestNewOutboxSourceDefaults tests the default outbox table layout and validation
+func TestNewOutboxSourceDefaults(t *testing.T) {
+	o := NewOutboxSource(OutboxConfig{}, nil)
+	if o.config.Table != "outbox" || o.config.BatchSize != 100 || o.config.PollInterval != time.Second {
+		t.Errorf("unexpected defaults %+v", o.config)
+	}
+	want := "SELECT id, aggregate, payload, created_at FROM outbox WHERE NOT processed ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED"
+	if got := o.claimQuery(); got != want {
+		t.Errorf("claimQuery() = %q, want %q", got, want)
+	}
+	if err := o.validate(); err != nil {
+		t.Errorf("validate() error = %v", err)
+	}
+
+	o = NewOutboxSource(OutboxConfig{OperationColumn: "op"}, nil)
+	if got := o.claimQuery(); !strings.HasPrefix(got, "SELECT id, aggregate, payload, created_at, op FROM") {
+		t.Errorf("expected the operation column to be selected, got %q", got)
+	}
+
+	o = NewOutboxSource(OutboxConfig{Table: "outbox; DROP TABLE users"}, nil)
+	if err := o.validate(); err == nil {
+		t.Error("expected error for an invalid table name")
+	}
+}
+
+// TestOutboxEvent tests the conversion of outbox rows to events
+func TestOutboxEvent(t *testing.T) {
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name      string
+		payload   string
+		operation string
+		wantOp    string
+		wantErr   bool
+	}{
+		{name: "default operation", payload: `{"_id":"o1","total":5}`, wantOp: "insert"},
+		{name: "explicit operation", payload: `{"_id":"o1"}`, operation: "delete", wantOp: "delete"},
+		{name: "unknown operation", payload: `{"_id":"o1"}`, operation: "upsert", wantErr: true},
+		{name: "payload not an object", payload: `[1,2]`, wantErr: true},
+		{name: "invalid payload", payload: `{`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := outboxEvent("42", "order", []byte(tt.payload), createdAt, tt.operation)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("outboxEvent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if event.ID != "42" || event.Position != "42" || event.Collection != "order" || event.Source != "outbox" {
+				t.Errorf("unexpected event %+v", event)
+			}
+			if event.Operation != tt.wantOp || !event.Timestamp.Equal(createdAt) || event.Data["_id"] != "o1" {
+				t.Errorf("unexpected event %+v", event)
+			}
+		})
+	}
+}
+
+// TestOutboxAckQueries tests that ack mode reads past a cursor and marks rows processed on acknowledgment
+func TestOutboxAckQueries(t *testing.T) {
+	o := NewOutboxSource(OutboxConfig{AckOnCommit: true}, nil)
+	want := "SELECT id, aggregate, payload, created_at FROM outbox WHERE NOT processed ORDER BY id LIMIT $1"
+	if got := o.claimQuery(); got != want {
+		t.Errorf("claimQuery() = %q, want %q", got, want)
+	}
+	o.after = "42"
+	want = "SELECT id, aggregate, payload, created_at FROM outbox WHERE NOT processed AND id > $2 ORDER BY id LIMIT $1"
+	if got := o.claimQuery(); got != want {
+		t.Errorf("claimQuery() after a read = %q, want %q", got, want)
+	}
+	want = "UPDATE outbox SET processed = TRUE WHERE id <= $1 AND NOT processed"
+	if got := o.ackQuery(); got != want {
+		t.Errorf("ackQuery() = %q, want %q", got, want)
+	}
+
+	// Without ack mode rows are marked when read, so Ack does nothing
+	o = NewOutboxSource(OutboxConfig{}, nil)
+	if err := o.Ack(context.Background(), "42"); err != nil {
+		t.Errorf("Ack() error = %v", err)
+	}
+}
@@ -0,0 +1,461 @@
+package spool
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+const (
+	segmentSuffix = ".seg"
+	cursorFile    = "cursor"
+
+	// defaultSegmentBytes is the size at which a new segment file is started
+	defaultSegmentBytes = 64 * 1024 * 1024
+	// cursorSyncInterval is how many automatic commits happen between cursor persists
+	cursorSyncInterval = 100
+)
+
+// ErrFull is returned by Append when the queue has reached its size cap
+var ErrFull = errors.New("spool is full")
+
+// Options configures a disk queue
+type Options struct {
+	MaxBytes     int64 // Maximum bytes of uncommitted events on disk (0 = unlimited)
+	SegmentBytes int64 // Size at which segment files are rotated (default: 64MB)
+	ManualCommit bool  // Only release events when Commit is called, not on Advance
+}
+
+// segment is a single append-only file of JSON-encoded events
+type segment struct {
+	id   int64
+	size int64
+}
+
+// position is a location within the segment files
+type position struct {
+	id     int64
+	offset int64
+}
+
+// DiskQueue is a durable FIFO queue of events stored as segment files in a
+// directory. It keeps two cursors: the read cursor, moved by Advance, and the
+// commit cursor, which marks events that are no longer needed. By default
+// Advance also commits; with ManualCommit the caller commits explicitly once
+// events are safely delivered, so uncommitted events are read again after a
+// restart. Segments are deleted once fully committed.
+type DiskQueue struct {
+	dir  string
+	opts Options
+
+	mu        sync.Mutex // protects the fields below
+	segments  []segment  // segments from the commit cursor onwards, oldest first
+	writer    *os.File
+	committed position        // commit cursor
+	read      position        // read cursor
+	reader    *bufio.Reader   // reader positioned at the read cursor
+	readFile  *os.File        // file backing reader
+	pending   *pipeline.Event // event returned by Peek but not yet advanced
+	pendLen   int64           // encoded length of pending
+	inflight  []position      // end positions of advanced but uncommitted events
+	count     int             // unread events
+	bytes     int64           // bytes from the commit cursor to the tail
+	commits   int             // automatic commits since the cursor was last persisted
+	last      *pipeline.Event // most recently appended event
+}
+
+// Open opens (or creates) a disk queue in dir, resuming from the persisted
+// commit cursor if a previous run left events behind
+func Open(dir string, opts Options) (*DiskQueue, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("spool directory is required")
+	}
+	if opts.SegmentBytes <= 0 {
+		opts.SegmentBytes = defaultSegmentBytes
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	q := &DiskQueue{dir: dir, opts: opts}
+	if err := q.load(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// load discovers existing segments and restores the commit cursor
+func (q *DiskQueue) load() error {
+	entries, err := os.ReadDir(q.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, segmentSuffix) {
+			continue
+		}
+		id, err := strconv.ParseInt(strings.TrimSuffix(name, segmentSuffix), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat spool segment: %w", err)
+		}
+		q.segments = append(q.segments, segment{id: id, size: info.Size()})
+	}
+	sort.Slice(q.segments, func(i, j int) bool { return q.segments[i].id < q.segments[j].id })
+
+	// Restore the cursor, dropping segments that were already committed
+	if len(q.segments) > 0 {
+		q.committed = position{id: q.segments[0].id}
+	}
+	if data, err := os.ReadFile(filepath.Join(q.dir, cursorFile)); err == nil {
+		var cursor position
+		if _, err := fmt.Sscanf(string(data), "%d %d", &cursor.id, &cursor.offset); err == nil {
+			for len(q.segments) > 0 && q.segments[0].id < cursor.id {
+				os.Remove(q.segmentPath(q.segments[0].id))
+				q.segments = q.segments[1:]
+			}
+			if len(q.segments) > 0 && q.segments[0].id == cursor.id && cursor.offset <= q.segments[0].size {
+				q.committed = cursor
+			}
+		}
+	}
+	q.read = q.committed
+
+	// Count unread events so Len is accurate after a restart
+	for i, seg := range q.segments {
+		start := int64(0)
+		if i == 0 {
+			start = q.committed.offset
+		}
+		n, last, err := q.scanSegment(seg.id, start)
+		if err != nil {
+			return err
+		}
+		q.count += n
+		q.bytes += seg.size - start
+		if last != nil {
+			q.last = last
+		}
+	}
+
+	if len(q.segments) == 0 {
+		return q.rotate()
+	}
+	return q.openWriter(q.segments[len(q.segments)-1].id)
+}
+
+// scanSegment counts the events in a segment starting at offset and decodes the last one
+func (q *DiskQueue) scanSegment(id, offset int64) (int, *pipeline.Event, error) {
+	f, err := os.Open(q.segmentPath(id))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, nil, fmt.Errorf("failed to seek spool segment: %w", err)
+	}
+
+	n := 0
+	var lastLine []byte
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, nil, fmt.Errorf("failed to read spool segment: %w", err)
+		}
+		n++
+		lastLine = line
+	}
+
+	if lastLine == nil {
+		return n, nil, nil
+	}
+	var last pipeline.Event
+	if err := json.Unmarshal(lastLine, &last); err != nil {
+		return 0, nil, fmt.Errorf("failed to decode spooled event: %w", err)
+	}
+	return n, &last, nil
+}
+
+// segmentPath returns the file path of a segment
+func (q *DiskQueue) segmentPath(id int64) string {
+	return filepath.Join(q.dir, fmt.Sprintf("%020d%s", id, segmentSuffix))
+}
+
+// openWriter opens a segment for appending
+func (q *DiskQueue) openWriter(id int64) error {
+	f, err := os.OpenFile(q.segmentPath(id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	if q.writer != nil {
+		q.writer.Close()
+	}
+	q.writer = f
+	return nil
+}
+
+// rotate starts a new segment for writing
+func (q *DiskQueue) rotate() error {
+	var id int64 = 1
+	if len(q.segments) > 0 {
+		id = q.segments[len(q.segments)-1].id + 1
+	}
+	if err := q.openWriter(id); err != nil {
+		return err
+	}
+	q.segments = append(q.segments, segment{id: id})
+	if len(q.segments) == 1 {
+		q.committed = position{id: id}
+		q.read = q.committed
+	}
+	return nil
+}
+
+// Append adds an event to the tail of the queue
+func (q *DiskQueue) Append(event pipeline.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode spooled event: %w", err)
+	}
+	line = append(line, '\n')
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.writer == nil {
+		return fmt.Errorf("spool is closed")
+	}
+	if q.opts.MaxBytes > 0 && q.bytes+int64(len(line)) > q.opts.MaxBytes {
+		return ErrFull
+	}
+
+	tail := &q.segments[len(q.segments)-1]
+	if tail.size > 0 && tail.size+int64(len(line)) > q.opts.SegmentBytes {
+		if err := q.rotate(); err != nil {
+			return err
+		}
+		tail = &q.segments[len(q.segments)-1]
+	}
+
+	if _, err := q.writer.Write(line); err != nil {
+		return fmt.Errorf("failed to write spooled event: %w", err)
+	}
+	tail.size += int64(len(line))
+	q.count++
+	q.bytes += int64(len(line))
+	q.last = &event
+	return nil
+}
+
+// Peek returns the event at the read cursor without advancing past it
+func (q *DiskQueue) Peek() (pipeline.Event, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.pending != nil {
+		return *q.pending, true, nil
+	}
+	if q.count == 0 {
+		return pipeline.Event{}, false, nil
+	}
+
+	var line []byte
+	for {
+		if q.reader == nil {
+			f, err := os.Open(q.segmentPath(q.read.id))
+			if err != nil {
+				return pipeline.Event{}, false, fmt.Errorf("failed to open spool segment: %w", err)
+			}
+			if _, err := f.Seek(q.read.offset, io.SeekStart); err != nil {
+				f.Close()
+				return pipeline.Event{}, false, fmt.Errorf("failed to seek spool segment: %w", err)
+			}
+			q.readFile = f
+			q.reader = bufio.NewReader(f)
+		}
+
+		var err error
+		line, err = q.reader.ReadBytes('\n')
+		if err == nil {
+			break
+		}
+		if err == io.EOF && len(line) == 0 && q.read.id < q.segments[len(q.segments)-1].id {
+			// Current segment exhausted, move on to the next one
+			q.closeReaderLocked()
+			q.read = position{id: q.nextSegmentLocked(q.read.id)}
+			continue
+		}
+		return pipeline.Event{}, false, fmt.Errorf("failed to read spooled event: %w", err)
+	}
+
+	var event pipeline.Event
+	if err := json.Unmarshal(line, &event); err != nil {
+		return pipeline.Event{}, false, fmt.Errorf("failed to decode spooled event: %w", err)
+	}
+	q.pending = &event
+	q.pendLen = int64(len(line))
+	return event, true, nil
+}
+
+// Advance moves the read cursor past the event returned by the last Peek.
+// Unless the queue uses manual commits, the event is committed as well.
+func (q *DiskQueue) Advance() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.pending == nil {
+		return fmt.Errorf("advance called without a peeked event")
+	}
+	q.pending = nil
+	q.read.offset += q.pendLen
+	q.count--
+	q.inflight = append(q.inflight, q.read)
+
+	if q.opts.ManualCommit {
+		return nil
+	}
+
+	persist := false
+	q.commits++
+	if q.commits%cursorSyncInterval == 0 || q.count == 0 {
+		persist = true
+	}
+	return q.commitLocked(1, persist)
+}
+
+// Commit releases the oldest n advanced events and persists the commit cursor
+func (q *DiskQueue) Commit(n int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if n > len(q.inflight) {
+		return fmt.Errorf("cannot commit %d events, only %d in flight", n, len(q.inflight))
+	}
+	return q.commitLocked(n, true)
+}
+
+// commitLocked moves the commit cursor past n in-flight events (caller must hold the lock)
+func (q *DiskQueue) commitLocked(n int, persist bool) error {
+	if n == 0 {
+		return nil
+	}
+
+	target := q.inflight[n-1]
+	q.inflight = q.inflight[n:]
+
+	// Release the bytes of every segment between the old and new cursor
+	for q.committed.id < target.id {
+		q.bytes -= q.segments[0].size - q.committed.offset
+		os.Remove(q.segmentPath(q.segments[0].id))
+		q.segments = q.segments[1:]
+		q.committed = position{id: q.segments[0].id}
+	}
+	q.bytes -= target.offset - q.committed.offset
+	q.committed = target
+
+	// Drop the head segment once fully committed, unless it is still being written
+	if len(q.segments) > 1 && q.committed.offset >= q.segments[0].size && q.read.id != q.segments[0].id {
+		os.Remove(q.segmentPath(q.segments[0].id))
+		q.segments = q.segments[1:]
+		q.committed = position{id: q.segments[0].id}
+		persist = true
+	}
+
+	if persist {
+		return q.saveCursorLocked()
+	}
+	return nil
+}
+
+// nextSegmentLocked returns the id of the segment following id (caller must hold the lock)
+func (q *DiskQueue) nextSegmentLocked(id int64) int64 {
+	for _, seg := range q.segments {
+		if seg.id > id {
+			return seg.id
+		}
+	}
+	return id
+}
+
+// closeReaderLocked closes the read cursor's file (caller must hold the lock)
+func (q *DiskQueue) closeReaderLocked() {
+	if q.readFile != nil {
+		q.readFile.Close()
+		q.readFile = nil
+		q.reader = nil
+	}
+}
+
+// saveCursorLocked persists the commit cursor (caller must hold the lock)
+func (q *DiskQueue) saveCursorLocked() error {
+	tmp := filepath.Join(q.dir, cursorFile+".tmp")
+	data := fmt.Sprintf("%d %d\n", q.committed.id, q.committed.offset)
+	if err := os.WriteFile(tmp, []byte(data), 0o644); err != nil {
+		return fmt.Errorf("failed to write spool cursor: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(q.dir, cursorFile)); err != nil {
+		return fmt.Errorf("failed to save spool cursor: %w", err)
+	}
+	return nil
+}
+
+// Last returns the most recently appended event known to the queue
+func (q *DiskQueue) Last() (pipeline.Event, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.last == nil {
+		return pipeline.Event{}, false
+	}
+	return *q.last, true
+}
+
+// Len returns the number of unread events
+func (q *DiskQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.count
+}
+
+// Bytes returns the size of uncommitted events on disk
+func (q *DiskQueue) Bytes() int64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.bytes
+}
+
+// Close persists the commit cursor and closes open segment files
+func (q *DiskQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.writer == nil {
+		return nil
+	}
+	err := q.saveCursorLocked()
+	q.closeReaderLocked()
+	if cerr := q.writer.Close(); err == nil {
+		err = cerr
+	}
+	q.writer = nil
+	return err
+}
@@ -0,0 +1,205 @@
+package spool
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// drain reads all events from the queue
+func drain(t *testing.T, q *DiskQueue) []string {
+	t.Helper()
+	var ids []string
+	for {
+		event, ok, err := q.Peek()
+		if err != nil {
+			t.Fatalf("Peek() error = %v", err)
+		}
+		if !ok {
+			return ids
+		}
+		ids = append(ids, event.ID)
+		if err := q.Advance(); err != nil {
+			t.Fatalf("Advance() error = %v", err)
+		}
+	}
+}
+
+// TestDiskQueueFIFO tests that events come out in the order they went in
+func TestDiskQueueFIFO(t *testing.T) {
+	q, err := Open(t.TempDir(), Options{})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer q.Close()
+
+	for i := 0; i < 5; i++ {
+		if err := q.Append(pipeline.Event{ID: fmt.Sprint(i)}); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+	if q.Len() != 5 {
+		t.Fatalf("Len() = %d, want 5", q.Len())
+	}
+
+	// Peek is idempotent until Advance
+	first, _, _ := q.Peek()
+	again, _, _ := q.Peek()
+	if first.ID != "0" || again.ID != "0" {
+		t.Fatalf("expected repeated peek of event 0, got %s and %s", first.ID, again.ID)
+	}
+
+	ids := drain(t, q)
+	if fmt.Sprint(ids) != "[0 1 2 3 4]" {
+		t.Errorf("got %v, want [0 1 2 3 4]", ids)
+	}
+	if q.Len() != 0 || q.Bytes() != 0 {
+		t.Errorf("expected empty queue, got len=%d bytes=%d", q.Len(), q.Bytes())
+	}
+}
+
+// TestDiskQueueSegmentRotation tests that segments rotate and are deleted once consumed
+func TestDiskQueueSegmentRotation(t *testing.T) {
+	dir := t.TempDir()
+	q, err := Open(dir, Options{SegmentBytes: 100})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer q.Close()
+
+	for i := 0; i < 20; i++ {
+		if err := q.Append(pipeline.Event{ID: fmt.Sprint(i)}); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+	if len(q.segments) < 2 {
+		t.Fatalf("expected multiple segments, got %d", len(q.segments))
+	}
+
+	ids := drain(t, q)
+	if len(ids) != 20 || ids[19] != "19" {
+		t.Errorf("expected 20 ordered events, got %v", ids)
+	}
+	if len(q.segments) != 1 {
+		t.Errorf("expected consumed segments to be deleted, %d remain", len(q.segments))
+	}
+}
+
+// TestDiskQueueMaxBytes tests the size cap
+func TestDiskQueueMaxBytes(t *testing.T) {
+	q, err := Open(t.TempDir(), Options{MaxBytes: 200})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer q.Close()
+
+	var appendErr error
+	for i := 0; i < 10 && appendErr == nil; i++ {
+		appendErr = q.Append(pipeline.Event{ID: fmt.Sprint(i)})
+	}
+	if appendErr != ErrFull {
+		t.Fatalf("expected ErrFull, got %v", appendErr)
+	}
+
+	// Consuming frees space again
+	drain(t, q)
+	if err := q.Append(pipeline.Event{ID: "again"}); err != nil {
+		t.Errorf("expected append after drain to succeed, got %v", err)
+	}
+}
+
+// TestDiskQueueReopen tests that unread events survive a restart
+func TestDiskQueueReopen(t *testing.T) {
+	dir := t.TempDir()
+
+	q, err := Open(dir, Options{SegmentBytes: 100})
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := q.Append(pipeline.Event{ID: fmt.Sprint(i)}); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		q.Peek()
+		q.Advance()
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	q, err = Open(dir, Options{SegmentBytes: 100})
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	defer q.Close()
+
+	if q.Len() != 6 {
+		t.Fatalf("Len() after reopen = %d, want 6", q.Len())
+	}
+	q.Append(pipeline.Event{ID: "10"})
+
+	ids := drain(t, q)
+	if fmt.Sprint(ids) != "[4 5 6 7 8 9 10]" {
+		t.Errorf("got %v, want [4 5 6 7 8 9 10]", ids)
+	}
+}
+
+// TestDiskQueueManualCommit tests that uncommitted events are read again after a restart
+func TestDiskQueueManualCommit(t *testing.T) {
+	dir := t.TempDir()
+	opts := Options{SegmentBytes: 100, ManualCommit: true}
+
+	q, err := Open(dir, opts)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := q.Append(pipeline.Event{ID: fmt.Sprint(i), Position: fmt.Sprint("pos", i)}); err != nil {
+			t.Fatalf("Append() error = %v", err)
+		}
+	}
+
+	// Read everything but only commit the first three
+	if ids := drain(t, q); len(ids) != 10 {
+		t.Fatalf("expected to read 10 events, got %d", len(ids))
+	}
+	if err := q.Commit(3); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if err := q.Commit(100); err == nil {
+		t.Error("expected error committing more events than in flight")
+	}
+	if err := q.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	q, err = Open(dir, opts)
+	if err != nil {
+		t.Fatalf("reopen error = %v", err)
+	}
+	defer q.Close()
+
+	last, ok := q.Last()
+	if !ok || last.Position != "pos9" {
+		t.Errorf("Last() = %+v, %v; want pos9", last, ok)
+	}
+
+	ids := drain(t, q)
+	if fmt.Sprint(ids) != "[3 4 5 6 7 8 9]" {
+		t.Errorf("got %v, want [3 4 5 6 7 8 9]", ids)
+	}
+
+	// Committing everything releases all but the tail segment
+	if err := q.Commit(7); err != nil {
+		t.Fatalf("Commit() error = %v", err)
+	}
+	if q.Bytes() != 0 {
+		t.Errorf("expected no uncommitted bytes, got %d", q.Bytes())
+	}
+	if len(q.segments) != 1 {
+		t.Errorf("expected committed segments to be deleted, %d remain", len(q.segments))
+	}
+}
@@ -0,0 +1,223 @@
+// Package testutil provides an end-to-end test harness that runs pipelines
+// against real MongoDB and PostgreSQL instances started with testcontainers.
+// Tests using it are skipped when no container runtime is available.
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	_ "github.com/lib/pq"
+	"github.com/testcontainers/testcontainers-go"
+	tcmongo "github.com/testcontainers/testcontainers-go/modules/mongodb"
+	tcpostgres "github.com/testcontainers/testcontainers-go/modules/postgres"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// MongoImage is the MongoDB image started by StartEnvironment
+	MongoImage = "mongo:7"
+	// PostgresImage is the PostgreSQL image started by StartEnvironment
+	PostgresImage = "postgres:16-alpine"
+	// Database is the database name used in both MongoDB and PostgreSQL
+	Database = "datapipe_test"
+)
+
+// Environment holds a MongoDB replica set and a PostgreSQL server for one test
+type Environment struct {
+	MongoURI    string // Connection string for the MongoDB source
+	PostgresURL string // Connection string for the PostgreSQL sink
+	Mongo       *mongo.Client
+	Postgres    *sql.DB
+}
+
+// StartEnvironment starts MongoDB (as a single-node replica set, so change
+// streams work) and PostgreSQL. Containers are removed when the test ends.
+func StartEnvironment(t *testing.T) *Environment {
+	t.Helper()
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+	ctx := context.Background()
+
+	mongoContainer, err := tcmongo.Run(ctx, MongoImage, tcmongo.WithReplicaSet("rs0"))
+	testcontainers.CleanupContainer(t, mongoContainer)
+	if err != nil {
+		t.Fatalf("failed to start MongoDB: %v", err)
+	}
+	mongoURI, err := mongoContainer.ConnectionString(ctx)
+	if err != nil {
+		t.Fatalf("failed to get MongoDB connection string: %v", err)
+	}
+	mongoURI += "/?directConnection=true"
+
+	pgContainer, err := tcpostgres.Run(ctx, PostgresImage,
+		tcpostgres.WithDatabase(Database),
+		tcpostgres.WithUsername("datapipe"),
+		tcpostgres.WithPassword("datapipe"),
+		tcpostgres.BasicWaitStrategies(),
+	)
+	testcontainers.CleanupContainer(t, pgContainer)
+	if err != nil {
+		t.Fatalf("failed to start PostgreSQL: %v", err)
+	}
+	pgURL, err := pgContainer.ConnectionString(ctx, "sslmode=disable")
+	if err != nil {
+		t.Fatalf("failed to get PostgreSQL connection string: %v", err)
+	}
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(mongoURI))
+	if err != nil {
+		t.Fatalf("failed to connect to MongoDB: %v", err)
+	}
+	t.Cleanup(func() { client.Disconnect(context.Background()) })
+
+	db, err := sql.Open("postgres", pgURL)
+	if err != nil {
+		t.Fatalf("failed to connect to PostgreSQL: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return &Environment{
+		MongoURI:    mongoURI,
+		PostgresURL: pgURL,
+		Mongo:       client,
+		Postgres:    db,
+	}
+}
+
+// Exec runs a SQL statement against PostgreSQL, e.g. to create the sink table
+func (e *Environment) Exec(t *testing.T, query string, args ...interface{}) {
+	t.Helper()
+	if _, err := e.Postgres.Exec(query, args...); err != nil {
+		t.Fatalf("failed to execute %q: %v", query, err)
+	}
+}
+
+// Seed inserts documents into a MongoDB collection
+func (e *Environment) Seed(t *testing.T, collection string, docs ...interface{}) {
+	t.Helper()
+	if len(docs) == 0 {
+		return
+	}
+	coll := e.Mongo.Database(Database).Collection(collection)
+	if _, err := coll.InsertMany(context.Background(), docs); err != nil {
+		t.Fatalf("failed to seed %s: %v", collection, err)
+	}
+}
+
+// StartPipeline runs a pipeline in the background until the returned stop
+// function is called (or the test ends) and fails the test if Run errors
+func StartPipeline(t *testing.T, p *pipeline.Pipeline) (stop func()) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- p.Run(ctx) }()
+
+	// Wait until the source and sink are connected, then until the change
+	// stream is open, so documents seeded afterwards are captured
+	deadline := time.After(30 * time.Second)
+	for !p.GetStatus().PipelineRunning {
+		select {
+		case err := <-done:
+			cancel()
+			t.Fatalf("pipeline stopped during startup: %v", err)
+		case <-deadline:
+			cancel()
+			t.Fatal("pipeline did not start within 30s")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+	select {
+	case <-p.SourceReady():
+	case err := <-done:
+		cancel()
+		t.Fatalf("pipeline stopped before the source was ready: %v", err)
+	case <-deadline:
+		cancel()
+		t.Fatal("source was not ready within 30s")
+	}
+
+	stopped := false
+	stop = func() {
+		if stopped {
+			return
+		}
+		stopped = true
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("pipeline error: %v", err)
+		}
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+// WaitForRows waits until a PostgreSQL table holds want rows
+func (e *Environment) WaitForRows(t *testing.T, table string, want int, timeout time.Duration) {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	var got int
+	for {
+		got = e.CountRows(t, table)
+		if got == want || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if got != want {
+		t.Fatalf("expected %d rows in %s, got %d after %v", want, table, got, timeout)
+	}
+}
+
+// CountRows returns the number of rows in a PostgreSQL table
+func (e *Environment) CountRows(t *testing.T, table string) int {
+	t.Helper()
+	var n int
+	if err := e.Postgres.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s", table)).Scan(&n); err != nil {
+		t.Fatalf("failed to count rows in %s: %v", table, err)
+	}
+	return n
+}
+
+// Rows returns the result of a query as one map per row, keyed by column name
+func (e *Environment) Rows(t *testing.T, query string, args ...interface{}) []map[string]interface{} {
+	t.Helper()
+	rows, err := e.Postgres.Query(query, args...)
+	if err != nil {
+		t.Fatalf("failed to query %q: %v", query, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatalf("failed to read columns: %v", err)
+	}
+	var result []map[string]interface{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			t.Fatalf("failed to scan row: %v", err)
+		}
+		row := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			if b, ok := values[i].([]byte); ok {
+				row[column] = string(b)
+			} else {
+				row[column] = values[i]
+			}
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("failed to read rows: %v", err)
+	}
+	return result
+}
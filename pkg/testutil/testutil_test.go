@@ -0,0 +1,36 @@
+package testutil_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+	"github.com/IEatCodeDaily/data-pipe/pkg/sink"
+	"github.com/IEatCodeDaily/data-pipe/pkg/source"
+	"github.com/IEatCodeDaily/data-pipe/pkg/testutil"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// TestMongoToPostgres tests change stream replication end to end
+func TestMongoToPostgres(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping container test in short mode")
+	}
+	env := testutil.StartEnvironment(t)
+	env.Exec(t, "CREATE TABLE users (_id TEXT PRIMARY KEY, name TEXT)")
+
+	src := source.NewMongoDBSource(env.MongoURI, testutil.Database, "users", nil)
+	snk := sink.NewPostgreSQLSink(env.PostgresURL, "users", nil)
+	testutil.StartPipeline(t, pipeline.New("e2e", src, snk, nil, nil))
+
+	env.Seed(t, "users",
+		bson.M{"_id": "u1", "name": "Ada"},
+		bson.M{"_id": "u2", "name": "Grace"},
+	)
+
+	env.WaitForRows(t, "users", 2, 30*time.Second)
+	rows := env.Rows(t, "SELECT name FROM users WHERE _id = $1", "u1")
+	if len(rows) != 1 || rows[0]["name"] != "Ada" {
+		t.Errorf("unexpected rows for u1: %v", rows)
+	}
+}
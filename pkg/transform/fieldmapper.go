@@ -1,8 +1,10 @@
 package transform
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
@@ -20,6 +22,7 @@ type FieldMapping struct {
 	Required    bool   `json:"required"`    // If true, error if field is missing
 	Extract     string `json:"extract"`     // Regex pattern to extract from source value
 	NestedPath  string `json:"nested_path"` // Dot-separated path for nested fields (e.g., "address.city")
+	Metadata    bool   `json:"metadata"`    // If true, set the value as Event.Metadata under the destination name instead of a field
 }
 
 // FieldMapperConfig contains field mapping configuration
@@ -32,9 +35,12 @@ type FieldMapperConfig struct {
 
 // FieldMapper is a transformer that maps and formats fields
 type FieldMapper struct {
-	config     FieldMapperConfig
-	extractors map[int]*regexp.Regexp // Key is mapping index, not source field name
-	logger     *log.Logger
+	config        FieldMapperConfig
+	extractors    map[int]*regexp.Regexp // Key is mapping index, not source field name
+	excluded      map[string]bool        // Fields dropped by include_all
+	mappedSources map[string]bool        // Source fields already handled by a mapping
+	passThrough   bool                   // include_all with no mappings or exclusions: Data is returned as-is
+	logger        *log.Logger
 }
 
 // NewFieldMapper creates a new field mapper transformer
@@ -49,9 +55,18 @@ func NewFieldMapperWithLogger(config FieldMapperConfig, logger *log.Logger) (*Fi
 	}
 
 	fm := &FieldMapper{
-		config:     config,
-		extractors: make(map[int]*regexp.Regexp),
-		logger:     logger,
+		config:        config,
+		extractors:    make(map[int]*regexp.Regexp),
+		excluded:      make(map[string]bool, len(config.ExcludeFields)),
+		mappedSources: make(map[string]bool, len(config.Mappings)),
+		passThrough:   config.IncludeAll && len(config.Mappings) == 0 && len(config.ExcludeFields) == 0,
+		logger:        logger,
+	}
+	for _, field := range config.ExcludeFields {
+		fm.excluded[field] = true
+	}
+	for _, mapping := range config.Mappings {
+		fm.mappedSources[mapping.Source] = true
 	}
 
 	// Compile regex patterns for extraction
@@ -68,21 +83,43 @@ func NewFieldMapperWithLogger(config FieldMapperConfig, logger *log.Logger) (*Fi
 	return fm, nil
 }
 
+// Fields returns the mappings that write fields, with their destination names
+// filled in, and whether fields without a mapping are passed through as well
+func (f *FieldMapper) Fields() ([]FieldMapping, bool) {
+	fields := make([]FieldMapping, 0, len(f.config.Mappings))
+	for _, mapping := range f.config.Mappings {
+		if mapping.Metadata {
+			continue
+		}
+		if mapping.Destination == "" {
+			mapping.Destination = mapping.Source
+		}
+		fields = append(fields, mapping)
+	}
+	return fields, f.config.IncludeAll
+}
+
 // Transform transforms an event by mapping and formatting fields
-func (f *FieldMapper) Transform(event pipeline.Event) (pipeline.Event, error) {
-	newData := make(map[string]interface{})
-	errors := make([]string, 0)
+func (f *FieldMapper) Transform(ctx context.Context, event pipeline.Event) (pipeline.Event, error) {
+	if f.passThrough {
+		return event, nil
+	}
+
+	newData := pipeline.NewData()
+	var errors []string
 
 	// Apply mappings
 	for i, mapping := range f.config.Mappings {
 		// Get value from source field (supports nested paths)
 		value, exists := f.getFieldValue(event.Data, mapping.Source, mapping.NestedPath)
 
-		// Handle missing required fields
+		// Handle missing required fields. Deletes carry only the document key,
+		// so their other fields are never required.
 		if !exists || value == nil {
-			if mapping.Required {
+			if mapping.Required && event.Operation != "delete" {
 				errors = append(errors, fmt.Sprintf("required field '%s' is missing", mapping.Source))
 				if f.config.StrictMode {
+					pipeline.ReleaseData(newData)
 					return event, fmt.Errorf("required field '%s' is missing", mapping.Source)
 				}
 			}
@@ -96,7 +133,7 @@ func (f *FieldMapper) Transform(event pipeline.Event) (pipeline.Event, error) {
 
 		// Extract using regex if specified
 		if extractor, ok := f.extractors[i]; ok {
-			strValue := fmt.Sprintf("%v", value)
+			strValue := toString(value)
 			matches := extractor.FindStringSubmatch(strValue)
 			if len(matches) > 1 {
 				value = matches[1] // Use first capture group
@@ -104,6 +141,7 @@ func (f *FieldMapper) Transform(event pipeline.Event) (pipeline.Event, error) {
 				value = matches[0] // Use full match
 			} else {
 				if mapping.Required && f.config.StrictMode {
+					pipeline.ReleaseData(newData)
 					return event, fmt.Errorf("extraction pattern failed for field '%s'", mapping.Source)
 				}
 				continue
@@ -115,6 +153,7 @@ func (f *FieldMapper) Transform(event pipeline.Event) (pipeline.Event, error) {
 		if err != nil {
 			errors = append(errors, fmt.Sprintf("formatting error for field '%s': %v", mapping.Source, err))
 			if f.config.StrictMode {
+				pipeline.ReleaseData(newData)
 				return event, fmt.Errorf("formatting error for field '%s': %w", mapping.Source, err)
 			}
 			continue
@@ -125,25 +164,17 @@ func (f *FieldMapper) Transform(event pipeline.Event) (pipeline.Event, error) {
 		if destName == "" {
 			destName = mapping.Source
 		}
+		if mapping.Metadata {
+			event.SetMetadata(destName, toString(formattedValue))
+			continue
+		}
 		newData[destName] = formattedValue
 	}
 
 	// Handle unmapped fields
 	if f.config.IncludeAll {
-		excludeMap := make(map[string]bool)
-		for _, field := range f.config.ExcludeFields {
-			excludeMap[field] = true
-		}
-
-		// Create map of mapped source fields
-		mappedSources := make(map[string]bool)
-		for _, mapping := range f.config.Mappings {
-			mappedSources[mapping.Source] = true
-		}
-
-		// Include unmapped fields
 		for key, value := range event.Data {
-			if !mappedSources[key] && !excludeMap[key] {
+			if !f.mappedSources[key] && !f.excluded[key] {
 				newData[key] = value
 			}
 		}
@@ -156,6 +187,9 @@ func (f *FieldMapper) Transform(event pipeline.Event) (pipeline.Event, error) {
 		}
 	}
 
+	if pipeline.PoolingEnabled() {
+		pipeline.ReleaseData(event.Data)
+	}
 	event.Data = newData
 	return event, nil
 }
@@ -166,7 +200,32 @@ func (f *FieldMapper) formatValue(value interface{}, format string) (interface{}
 		return value, nil
 	}
 
-	strValue := fmt.Sprintf("%v", value)
+	// Values already of the target type need no string round trip
+	switch v := value.(type) {
+	case int:
+		if format == "int" {
+			return v, nil
+		}
+	case float64:
+		if format == "float" {
+			return v, nil
+		}
+	case bool:
+		if format == "bool" || format == "boolean" {
+			return v, nil
+		}
+	case time.Time:
+		if format == "date" || format == "datetime" {
+			return v, nil
+		}
+	case interface{ Time() time.Time }:
+		// BSON dates
+		if format == "date" || format == "datetime" {
+			return v.Time(), nil
+		}
+	}
+
+	strValue := toString(value)
 
 	switch format {
 	case "string":
@@ -257,7 +316,7 @@ func (f *FieldMapper) getFieldValue(data map[string]interface{}, source string,
 	var current interface{} = data
 
 	for _, part := range parts {
-		if currentMap, ok := current.(map[string]interface{}); ok {
+		if currentMap, ok := asMap(current); ok {
 			var exists bool
 			current, exists = currentMap[part]
 			if !exists {
@@ -270,3 +329,34 @@ func (f *FieldMapper) getFieldValue(data map[string]interface{}, source string,
 
 	return current, true
 }
+
+// mapType is the type of event data and of nested documents
+var mapType = reflect.TypeOf(map[string]interface{}{})
+
+// asMap returns a nested document as a map, including named map types such
+// as the BSON documents of MongoDB events
+func asMap(value interface{}) (map[string]interface{}, bool) {
+	if m, ok := value.(map[string]interface{}); ok {
+		return m, true
+	}
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Map || !v.Type().ConvertibleTo(mapType) {
+		return nil, false
+	}
+	return v.Convert(mapType).Interface().(map[string]interface{}), true
+}
+
+// toString formats a value like fmt.Sprintf("%v"), without the overhead for
+// the common case of a value that is already a string
+func toString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
@@ -1,6 +1,8 @@
 package transform
 
 import (
+	"context"
+	"reflect"
 	"testing"
 	"time"
 
@@ -29,7 +31,7 @@ func TestFieldMapperBasicMapping(t *testing.T) {
 		},
 	}
 
-	result, err := mapper.Transform(event)
+	result, err := mapper.Transform(context.Background(), event)
 	if err != nil {
 		t.Fatalf("Transform failed: %v", err)
 	}
@@ -66,7 +68,7 @@ func TestFieldMapperIncludeAll(t *testing.T) {
 		},
 	}
 
-	result, err := mapper.Transform(event)
+	result, err := mapper.Transform(context.Background(), event)
 	if err != nil {
 		t.Fatalf("Transform failed: %v", err)
 	}
@@ -102,7 +104,7 @@ func TestFieldMapperExcludeFields(t *testing.T) {
 		},
 	}
 
-	result, err := mapper.Transform(event)
+	result, err := mapper.Transform(context.Background(), event)
 	if err != nil {
 		t.Fatalf("Transform failed: %v", err)
 	}
@@ -184,7 +186,7 @@ func TestFieldMapperFormatting(t *testing.T) {
 				},
 			}
 
-			result, err := mapper.Transform(event)
+			result, err := mapper.Transform(context.Background(), event)
 			if (err != nil) != tt.wantErr {
 				t.Fatalf("Transform() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -230,7 +232,7 @@ func TestFieldMapperExtraction(t *testing.T) {
 		},
 	}
 
-	result, err := mapper.Transform(event)
+	result, err := mapper.Transform(context.Background(), event)
 	if err != nil {
 		t.Fatalf("Transform failed: %v", err)
 	}
@@ -263,7 +265,7 @@ func TestFieldMapperDefaultValues(t *testing.T) {
 		},
 	}
 
-	result, err := mapper.Transform(event)
+	result, err := mapper.Transform(context.Background(), event)
 	if err != nil {
 		t.Fatalf("Transform failed: %v", err)
 	}
@@ -298,10 +300,20 @@ func TestFieldMapperRequiredFields(t *testing.T) {
 		},
 	}
 
-	_, err = mapper.Transform(event)
+	_, err = mapper.Transform(context.Background(), event)
 	if err == nil {
 		t.Errorf("Expected error for missing required field, got nil")
 	}
+
+	// Deletes carry only the document key
+	event.Operation = "delete"
+	result, err := mapper.Transform(context.Background(), event)
+	if err != nil {
+		t.Errorf("Expected delete without required fields to pass, got %v", err)
+	}
+	if result.Data["id"] != "123" {
+		t.Errorf("Expected id to be mapped for delete, got %v", result.Data["id"])
+	}
 }
 
 func TestFieldMapperStrictMode(t *testing.T) {
@@ -324,7 +336,7 @@ func TestFieldMapperStrictMode(t *testing.T) {
 		},
 	}
 
-	_, err = mapper.Transform(event)
+	_, err = mapper.Transform(context.Background(), event)
 	if err == nil {
 		t.Errorf("Expected error in strict mode for invalid format, got nil")
 	}
@@ -352,7 +364,7 @@ func TestFieldMapperNonStrictMode(t *testing.T) {
 		},
 	}
 
-	result, err := mapper.Transform(event)
+	result, err := mapper.Transform(context.Background(), event)
 	if err != nil {
 		t.Fatalf("Transform should not fail in non-strict mode: %v", err)
 	}
@@ -392,7 +404,7 @@ func TestFieldMapperDateParsing(t *testing.T) {
 			},
 		}
 
-		result, err := mapper.Transform(event)
+		result, err := mapper.Transform(context.Background(), event)
 		if err != nil {
 			t.Fatalf("Transform failed for date %s: %v", dateStr, err)
 		}
@@ -421,7 +433,7 @@ func TestFieldMapperEdgeCases(t *testing.T) {
 			},
 		}
 
-		result, err := mapper.Transform(event)
+		result, err := mapper.Transform(context.Background(), event)
 		if err != nil {
 			t.Fatalf("Transform failed: %v", err)
 		}
@@ -449,7 +461,7 @@ func TestFieldMapperEdgeCases(t *testing.T) {
 			},
 		}
 
-		result, err := mapper.Transform(event)
+		result, err := mapper.Transform(context.Background(), event)
 		if err != nil {
 			t.Fatalf("Transform failed: %v", err)
 		}
@@ -476,7 +488,7 @@ func TestFieldMapperEdgeCases(t *testing.T) {
 			},
 		}
 
-		result, err := mapper.Transform(event)
+		result, err := mapper.Transform(context.Background(), event)
 		if err != nil {
 			t.Fatalf("Transform failed: %v", err)
 		}
@@ -537,7 +549,7 @@ func TestFieldMapperBooleanFormat(t *testing.T) {
 				},
 			}
 
-			result, err := mapper.Transform(event)
+			result, err := mapper.Transform(context.Background(), event)
 			if err != nil {
 				t.Fatalf("Transform failed: %v", err)
 			}
@@ -567,7 +579,7 @@ func TestFieldMapperBooleanFormat(t *testing.T) {
 			},
 		}
 
-		result, err := mapper.Transform(event)
+		result, err := mapper.Transform(context.Background(), event)
 		if err != nil {
 			t.Fatalf("Transform should not fail in non-strict mode: %v", err)
 		}
@@ -579,6 +591,31 @@ func TestFieldMapperBooleanFormat(t *testing.T) {
 	})
 }
 
+// bsonDate is a date value with a Time method, like primitive.DateTime
+type bsonDate int64
+
+func (d bsonDate) Time() time.Time {
+	return time.UnixMilli(int64(d)).UTC()
+}
+
+// TestFieldMapperDateValues tests the date format on values that are already dates
+func TestFieldMapperDateValues(t *testing.T) {
+	mapper, err := NewFieldMapper(FieldMapperConfig{Mappings: []FieldMapping{{Source: "created_at", Format: "date"}}})
+	if err != nil {
+		t.Fatalf("Failed to create mapper: %v", err)
+	}
+	want := time.Date(2023, 1, 15, 10, 30, 0, 0, time.UTC)
+	for _, value := range []interface{}{want, bsonDate(want.UnixMilli())} {
+		result, err := mapper.Transform(context.Background(), pipeline.Event{Data: map[string]interface{}{"created_at": value}})
+		if err != nil {
+			t.Fatalf("Transform failed for %T: %v", value, err)
+		}
+		if got, ok := result.Data["created_at"].(time.Time); !ok || !got.Equal(want) {
+			t.Errorf("Expected %v for %T, got %v", want, value, result.Data["created_at"])
+		}
+	}
+}
+
 func TestFieldMapperNestedFields(t *testing.T) {
 	t.Run("simple nested path", func(t *testing.T) {
 		config := FieldMapperConfig{
@@ -604,7 +641,7 @@ func TestFieldMapperNestedFields(t *testing.T) {
 			},
 		}
 
-		result, err := mapper.Transform(event)
+		result, err := mapper.Transform(context.Background(), event)
 		if err != nil {
 			t.Fatalf("Transform failed: %v", err)
 		}
@@ -617,6 +654,27 @@ func TestFieldMapperNestedFields(t *testing.T) {
 		}
 	})
 
+	t.Run("named map types", func(t *testing.T) {
+		// MongoDB events hold nested documents as bson.M
+		type document map[string]interface{}
+		mapper, err := NewFieldMapper(FieldMapperConfig{Mappings: []FieldMapping{
+			{Source: "address", NestedPath: "address.geo.lat", Destination: "lat"},
+		}})
+		if err != nil {
+			t.Fatalf("Failed to create mapper: %v", err)
+		}
+		event := pipeline.Event{Data: map[string]interface{}{
+			"address": document{"geo": document{"lat": 59.9}},
+		}}
+		result, err := mapper.Transform(context.Background(), event)
+		if err != nil {
+			t.Fatalf("Transform failed: %v", err)
+		}
+		if result.Data["lat"] != 59.9 {
+			t.Errorf("Expected lat=59.9, got %v", result.Data["lat"])
+		}
+	})
+
 	t.Run("deeply nested path", func(t *testing.T) {
 		config := FieldMapperConfig{
 			Mappings: []FieldMapping{
@@ -643,7 +701,7 @@ func TestFieldMapperNestedFields(t *testing.T) {
 			},
 		}
 
-		result, err := mapper.Transform(event)
+		result, err := mapper.Transform(context.Background(), event)
 		if err != nil {
 			t.Fatalf("Transform failed: %v", err)
 		}
@@ -671,7 +729,7 @@ func TestFieldMapperNestedFields(t *testing.T) {
 			},
 		}
 
-		result, err := mapper.Transform(event)
+		result, err := mapper.Transform(context.Background(), event)
 		if err != nil {
 			t.Fatalf("Transform failed: %v", err)
 		}
@@ -701,7 +759,7 @@ func TestFieldMapperNestedFields(t *testing.T) {
 			},
 		}
 
-		result, err := mapper.Transform(event)
+		result, err := mapper.Transform(context.Background(), event)
 		if err != nil {
 			t.Fatalf("Transform failed: %v", err)
 		}
@@ -740,7 +798,7 @@ func TestFieldMapperMultipleExtractFromSameSource(t *testing.T) {
 		},
 	}
 
-	result, err := mapper.Transform(event)
+	result, err := mapper.Transform(context.Background(), event)
 	if err != nil {
 		t.Fatalf("Transform failed: %v", err)
 	}
@@ -784,7 +842,7 @@ func TestFieldMapperJSONBCompatibility(t *testing.T) {
 			},
 		}
 
-		result, err := mapper.Transform(event)
+		result, err := mapper.Transform(context.Background(), event)
 		if err != nil {
 			t.Fatalf("Transform failed: %v", err)
 		}
@@ -840,7 +898,7 @@ func TestFieldMapperJSONBCompatibility(t *testing.T) {
 			},
 		}
 
-		result, err := mapper.Transform(event)
+		result, err := mapper.Transform(context.Background(), event)
 		if err != nil {
 			t.Fatalf("Transform failed: %v", err)
 		}
@@ -861,3 +919,191 @@ func TestFieldMapperJSONBCompatibility(t *testing.T) {
 		}
 	})
 }
+
+// TestFieldMapperFastPaths tests that fast paths match the general formatting behaviour
+func TestFieldMapperFastPaths(t *testing.T) {
+	tests := []struct {
+		value  interface{}
+		format string
+		want   interface{}
+	}{
+		{42, "int", 42},
+		{"42", "int", 42},
+		{98.5, "float", 98.5},
+		{true, "bool", true},
+		{true, "string", "true"},
+		{7, "string", "7"},
+		{"Ada", "uppercase", "ADA"},
+		{int64(5), "string", "5"},
+	}
+
+	mapper, err := NewFieldMapper(FieldMapperConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		got, err := mapper.formatValue(tt.value, tt.format)
+		if err != nil {
+			t.Errorf("formatValue(%v, %s) error = %v", tt.value, tt.format, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("formatValue(%v, %s) = %v (%T), want %v (%T)", tt.value, tt.format, got, got, tt.want, tt.want)
+		}
+	}
+}
+
+// TestFieldMapperIncludeAllPassThrough tests that a mapping-free include_all mapper keeps the data untouched
+func TestFieldMapperIncludeAllPassThrough(t *testing.T) {
+	mapper, err := NewFieldMapper(FieldMapperConfig{IncludeAll: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := benchmarkEvent()
+	result, err := mapper.Transform(context.Background(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Data) != len(event.Data) || result.Data["city"] != "London" {
+		t.Errorf("expected data to pass through unchanged, got %v", result.Data)
+	}
+
+	// Pooled maps are handed out empty after release
+	pipeline.ReleaseData(map[string]interface{}{"stale": true})
+	mapped, err := NewFieldMapper(FieldMapperConfig{Mappings: []FieldMapping{{Source: "city"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = mapped.Transform(context.Background(), benchmarkEvent())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Data) != 1 {
+		t.Errorf("expected only the mapped field, got %v", result.Data)
+	}
+}
+
+// benchmarkEvent returns an event shaped like a typical CDC document
+func benchmarkEvent() pipeline.Event {
+	return pipeline.Event{
+		ID:        "1",
+		Operation: "insert",
+		Data: map[string]interface{}{
+			"_id":       "507f1f77bcf86cd799439011",
+			"firstName": "Ada",
+			"lastName":  "Lovelace",
+			"email":     "ADA@EXAMPLE.COM",
+			"age":       36,
+			"score":     98.5,
+			"active":    "true",
+			"city":      "London",
+		},
+	}
+}
+
+func BenchmarkPassThrough(b *testing.B) {
+	transformer := NewPassThroughTransformer()
+	event := benchmarkEvent()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := transformer.Transform(context.Background(), event); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFieldMapperIncludeAll(b *testing.B) {
+	mapper, err := NewFieldMapper(FieldMapperConfig{IncludeAll: true})
+	if err != nil {
+		b.Fatal(err)
+	}
+	event := benchmarkEvent()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := mapper.Transform(context.Background(), event); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFieldMapperSimpleMapping(b *testing.B) {
+	mapper, err := NewFieldMapper(FieldMapperConfig{
+		Mappings: []FieldMapping{
+			{Source: "_id", Destination: "id"},
+			{Source: "firstName", Destination: "first_name", Format: "trim"},
+			{Source: "lastName", Destination: "last_name", Format: "string"},
+			{Source: "email", Format: "lowercase"},
+			{Source: "age", Format: "int"},
+			{Source: "active", Format: "bool"},
+		},
+		IncludeAll:    true,
+		ExcludeFields: []string{"score"},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	event := benchmarkEvent()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		transformed, err := mapper.Transform(context.Background(), event)
+		if err != nil {
+			b.Fatal(err)
+		}
+		pipeline.ReleaseData(transformed.Data)
+	}
+}
+
+// TestFieldMapperFields tests the destination fields reported by the mapper
+func TestFieldMapperFields(t *testing.T) {
+	mapper, err := NewFieldMapper(FieldMapperConfig{Mappings: []FieldMapping{
+		{Source: "_id", Format: "string"},
+		{Source: "userAge", Destination: "age", Format: "int"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields, open := mapper.Fields()
+	if open || len(fields) != 2 || fields[0].Destination != "_id" || fields[1].Destination != "age" || fields[1].Format != "int" {
+		t.Errorf("Fields() = %v, %v", fields, open)
+	}
+
+	mapper, _ = NewFieldMapper(FieldMapperConfig{IncludeAll: true})
+	if _, open := mapper.Fields(); !open {
+		t.Error("expected include_all to pass other fields through")
+	}
+}
+
+// TestFieldMapperMetadata tests that metadata mappings set event metadata instead of fields
+func TestFieldMapperMetadata(t *testing.T) {
+	mapper, err := NewFieldMapper(FieldMapperConfig{
+		IncludeAll: true,
+		Mappings: []FieldMapping{
+			{Source: "tenantId", Destination: "tenant", Metadata: true},
+			{Source: "traceparent", Destination: "trace_id", Extract: `^00-([0-9a-f]{32})-`, Metadata: true},
+			{Source: "rev", Metadata: true},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	event := pipeline.Event{ID: "1", Operation: "insert", Data: map[string]interface{}{
+		"_id":         "1",
+		"tenantId":    "acme",
+		"traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01",
+		"rev":         7,
+	}}
+	result, err := mapper.Transform(context.Background(), event)
+	if err != nil {
+		t.Fatalf("Transform() error = %v", err)
+	}
+	wantMetadata := map[string]string{"tenant": "acme", "trace_id": "0af7651916cd43dd8448eb211c80319c", "rev": "7"}
+	if !reflect.DeepEqual(result.Metadata, wantMetadata) {
+		t.Errorf("Metadata = %v, want %v", result.Metadata, wantMetadata)
+	}
+	if !reflect.DeepEqual(result.Data, map[string]interface{}{"_id": "1"}) {
+		t.Errorf("expected the metadata fields to be left out of the data, got %v", result.Data)
+	}
+	if fields, _ := mapper.Fields(); len(fields) != 0 {
+		t.Errorf("expected metadata mappings not to be reported as fields, got %v", fields)
+	}
+}
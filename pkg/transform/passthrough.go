@@ -1,6 +1,8 @@
 package transform
 
 import (
+	"context"
+
 	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
 )
 
@@ -13,6 +15,6 @@ func NewPassThroughTransformer() *PassThroughTransformer {
 }
 
 // Transform passes the event through unchanged
-func (t *PassThroughTransformer) Transform(event pipeline.Event) (pipeline.Event, error) {
+func (t *PassThroughTransformer) Transform(ctx context.Context, event pipeline.Event) (pipeline.Event, error) {
 	return event, nil
 }
@@ -0,0 +1,74 @@
+package transform
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// routePlaceholder matches a {field} placeholder in a route template
+var routePlaceholder = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// RouterConfig contains router transformer configuration
+type RouterConfig struct {
+	Destination string `json:"destination"` // Template such as "orders_{tenant_id}"; {field} is replaced by the field's value
+	Default     string `json:"default"`     // Destination for events missing a template field (empty fails them)
+	Lowercase   bool   `json:"lowercase"`   // Lowercase the rendered destination
+}
+
+// Router is a transformer that sets each event's destination hint from its
+// field values, so routing-aware sinks can split a collection by tenant,
+// region and so on. It runs after an optional inner transformer, so the
+// template refers to fields as the inner transformer outputs them.
+type Router struct {
+	config RouterConfig
+	fields []string // template fields, in order of appearance
+	next   pipeline.Transformer
+}
+
+// NewRouter creates a router transformer applied after next, which may be nil
+func NewRouter(config RouterConfig, next pipeline.Transformer) (*Router, error) {
+	if config.Destination == "" {
+		return nil, fmt.Errorf("router transformer requires a destination template")
+	}
+	r := &Router{config: config, next: next}
+	for _, match := range routePlaceholder.FindAllStringSubmatch(config.Destination, -1) {
+		r.fields = append(r.fields, match[1])
+	}
+	return r, nil
+}
+
+// Transform applies the inner transformer and sets the event's destination
+func (r *Router) Transform(ctx context.Context, event pipeline.Event) (pipeline.Event, error) {
+	if r.next != nil {
+		var err error
+		if event, err = r.next.Transform(ctx, event); err != nil {
+			return event, err
+		}
+	}
+
+	destination := r.config.Destination
+	for _, field := range r.fields {
+		value, ok := event.Data[field]
+		if !ok {
+			value, ok = event.Key[field]
+		}
+		if !ok || value == nil {
+			if r.config.Default == "" {
+				return event, fmt.Errorf("cannot route event %s: field '%s' is missing", event.ID, field)
+			}
+			destination = r.config.Default
+			break
+		}
+		destination = strings.ReplaceAll(destination, "{"+field+"}", toString(value))
+	}
+	if r.config.Lowercase {
+		destination = strings.ToLower(destination)
+	}
+
+	event.Destination = destination
+	return event, nil
+}
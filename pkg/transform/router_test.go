@@ -0,0 +1,90 @@
+package transform
+
+import (
+	"context"
+	"testing"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+func TestRouterDestination(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  RouterConfig
+		data    map[string]interface{}
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "single field",
+			config: RouterConfig{Destination: "orders_{region}"},
+			data:   map[string]interface{}{"region": "eu"},
+			want:   "orders_eu",
+		},
+		{
+			name:   "several fields",
+			config: RouterConfig{Destination: "{tenant_id}_orders_{region}"},
+			data:   map[string]interface{}{"tenant_id": 42, "region": "us"},
+			want:   "42_orders_us",
+		},
+		{
+			name:   "lowercase",
+			config: RouterConfig{Destination: "orders_{region}", Lowercase: true},
+			data:   map[string]interface{}{"region": "EU"},
+			want:   "orders_eu",
+		},
+		{
+			name:   "default for missing field",
+			config: RouterConfig{Destination: "orders_{region}", Default: "orders"},
+			data:   map[string]interface{}{},
+			want:   "orders",
+		},
+		{
+			name:    "missing field without default",
+			config:  RouterConfig{Destination: "orders_{region}"},
+			data:    map[string]interface{}{"region": nil},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router, err := NewRouter(tt.config, nil)
+			if err != nil {
+				t.Fatalf("NewRouter() error = %v", err)
+			}
+			result, err := router.Transform(context.Background(), pipeline.Event{ID: "1", Data: tt.data})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Transform() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if result.Destination != tt.want {
+				t.Errorf("Destination = %q, want %q", result.Destination, tt.want)
+			}
+		})
+	}
+
+	if _, err := NewRouter(RouterConfig{}, nil); err == nil {
+		t.Error("expected an error for a missing destination template")
+	}
+}
+
+func TestRouterAppliesInnerTransformer(t *testing.T) {
+	mapper, err := NewFieldMapper(FieldMapperConfig{
+		Mappings: []FieldMapping{{Source: "Region", Destination: "region", Format: "lowercase"}},
+	})
+	if err != nil {
+		t.Fatalf("NewFieldMapper() error = %v", err)
+	}
+	router, err := NewRouter(RouterConfig{Destination: "orders_{region}"}, mapper)
+	if err != nil {
+		t.Fatalf("NewRouter() error = %v", err)
+	}
+
+	result, err := router.Transform(context.Background(), pipeline.Event{Data: map[string]interface{}{"Region": "EU"}})
+	if err != nil {
+		t.Fatalf("Transform() error = %v", err)
+	}
+	if result.Destination != "orders_eu" || result.Data["region"] != "eu" {
+		t.Errorf("Transform() = %q, %v", result.Destination, result.Data)
+	}
+}
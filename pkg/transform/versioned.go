@@ -0,0 +1,142 @@
+package transform
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// Migration upgrades documents from one version to the next
+type Migration struct {
+	From   int                    `json:"from"`   // Version the migration applies to; it produces From+1
+	Rename map[string]string      `json:"rename"` // Old field name to new field name
+	Remove []string               `json:"remove"` // Fields dropped
+	Set    map[string]interface{} `json:"set"`    // Fields added with a value when missing
+}
+
+// VersionedConfig contains versioned transformer configuration
+type VersionedConfig struct {
+	VersionField   string      `json:"version_field"`   // Field holding the document's schema version
+	Current        int         `json:"current"`         // Version documents are migrated to
+	DefaultVersion int         `json:"default_version"` // Version of documents without the field (default: 1)
+	Migrations     []Migration `json:"migrations"`      // One per version from the default up to the current one
+}
+
+// Versioned is a transformer that migrates documents written under older
+// schema versions to the current one, so old and new documents can coexist
+// in the same stream. It runs before an optional inner transformer, which
+// only ever sees current documents.
+type Versioned struct {
+	config     VersionedConfig
+	migrations map[int]Migration
+	next       pipeline.Transformer
+}
+
+// NewVersioned creates a versioned transformer applied before next, which may be nil
+func NewVersioned(config VersionedConfig, next pipeline.Transformer) (*Versioned, error) {
+	if config.VersionField == "" {
+		return nil, fmt.Errorf("versioned transformer requires a version field")
+	}
+	if config.DefaultVersion == 0 {
+		config.DefaultVersion = 1
+	}
+	if config.Current < config.DefaultVersion {
+		return nil, fmt.Errorf("current version %d is older than the default version %d", config.Current, config.DefaultVersion)
+	}
+	v := &Versioned{config: config, migrations: make(map[int]Migration, len(config.Migrations)), next: next}
+	for _, migration := range config.Migrations {
+		if migration.From < 1 || migration.From >= config.Current {
+			return nil, fmt.Errorf("migration from version %d is outside versions 1 to %d", migration.From, config.Current)
+		}
+		if _, ok := v.migrations[migration.From]; ok {
+			return nil, fmt.Errorf("duplicate migration from version %d", migration.From)
+		}
+		v.migrations[migration.From] = migration
+	}
+	for version := config.DefaultVersion; version < config.Current; version++ {
+		if _, ok := v.migrations[version]; !ok {
+			return nil, fmt.Errorf("missing migration from version %d to %d", version, version+1)
+		}
+	}
+	return v, nil
+}
+
+// Transform migrates the event's document to the current version and applies
+// the inner transformer. The version the document was written under is kept
+// as schema_version metadata. Deletes carry only the document key and are
+// not migrated.
+func (v *Versioned) Transform(ctx context.Context, event pipeline.Event) (pipeline.Event, error) {
+	if event.Operation != "delete" && len(event.Data) > 0 {
+		version, err := v.version(event.Data)
+		if err != nil {
+			return event, fmt.Errorf("cannot migrate event %s: %w", event.ID, err)
+		}
+		if version > v.config.Current {
+			return event, fmt.Errorf("cannot migrate event %s: version %d is newer than the current version %d", event.ID, version, v.config.Current)
+		}
+		event.SetMetadata(pipeline.MetadataSchemaVersion, strconv.Itoa(version))
+		for ; version < v.config.Current; version++ {
+			migration, ok := v.migrations[version]
+			if !ok {
+				return event, fmt.Errorf("cannot migrate event %s: no migration from version %d", event.ID, version)
+			}
+			migration.apply(event.Data)
+		}
+		event.Data[v.config.VersionField] = v.config.Current
+	}
+
+	if v.next != nil {
+		return v.next.Transform(ctx, event)
+	}
+	return event, nil
+}
+
+// version reads a document's schema version
+func (v *Versioned) version(data map[string]interface{}) (int, error) {
+	value, ok := data[v.config.VersionField]
+	if !ok || value == nil {
+		return v.config.DefaultVersion, nil
+	}
+	switch n := value.(type) {
+	case int:
+		return n, nil
+	case int32:
+		return int(n), nil
+	case int64:
+		return int(n), nil
+	case float64:
+		if n == float64(int(n)) {
+			return int(n), nil
+		}
+	case string:
+		if parsed, err := strconv.Atoi(n); err == nil {
+			return parsed, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid version %v in field '%s'", value, v.config.VersionField)
+}
+
+// apply upgrades a document in place
+func (m Migration) apply(data map[string]interface{}) {
+	// Renames happen at once, so fields can swap names
+	renamed := make(map[string]interface{}, len(m.Rename))
+	for from, to := range m.Rename {
+		if value, ok := data[from]; ok {
+			delete(data, from)
+			renamed[to] = value
+		}
+	}
+	for field, value := range renamed {
+		data[field] = value
+	}
+	for _, field := range m.Remove {
+		delete(data, field)
+	}
+	for field, value := range m.Set {
+		if _, ok := data[field]; !ok {
+			data[field] = value
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package transform
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+var versionedConfig = VersionedConfig{
+	VersionField: "schema_version",
+	Current:      3,
+	Migrations: []Migration{
+		{From: 1, Rename: map[string]string{"fullname": "name"}, Remove: []string{"legacy"}},
+		{From: 2, Set: map[string]interface{}{"active": true}},
+	},
+}
+
+func TestNewVersioned(t *testing.T) {
+	tests := []struct {
+		name   string
+		config VersionedConfig
+	}{
+		{name: "missing version field", config: VersionedConfig{Current: 1}},
+		{name: "current older than default", config: VersionedConfig{VersionField: "v", Current: 1, DefaultVersion: 2}},
+		{name: "missing migration", config: VersionedConfig{VersionField: "v", Current: 3, Migrations: []Migration{{From: 1}}}},
+		{name: "duplicate migration", config: VersionedConfig{VersionField: "v", Current: 2, Migrations: []Migration{{From: 1}, {From: 1}}}},
+		{name: "migration past current", config: VersionedConfig{VersionField: "v", Current: 2, Migrations: []Migration{{From: 1}, {From: 2}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewVersioned(tt.config, nil); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+
+	// Documents never older than the default version need no earlier migrations
+	if _, err := NewVersioned(VersionedConfig{VersionField: "v", Current: 3, DefaultVersion: 2, Migrations: []Migration{{From: 2}}}, nil); err != nil {
+		t.Errorf("NewVersioned() error = %v", err)
+	}
+}
+
+func TestVersionedTransform(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation string
+		data      map[string]interface{}
+		want      map[string]interface{}
+		version   string
+		wantErr   bool
+	}{
+		{
+			name:      "without version",
+			operation: "insert",
+			data:      map[string]interface{}{"fullname": "Ann", "legacy": 1},
+			want:      map[string]interface{}{"name": "Ann", "active": true, "schema_version": 3},
+			version:   "1",
+		},
+		{
+			name:      "version 2",
+			operation: "update",
+			data:      map[string]interface{}{"schema_version": int32(2), "name": "Ann", "active": false},
+			want:      map[string]interface{}{"name": "Ann", "active": false, "schema_version": 3},
+			version:   "2",
+		},
+		{
+			name:      "current version",
+			operation: "insert",
+			data:      map[string]interface{}{"schema_version": 3.0, "name": "Ann"},
+			want:      map[string]interface{}{"name": "Ann", "schema_version": 3},
+			version:   "3",
+		},
+		{
+			name:      "newer version",
+			operation: "insert",
+			data:      map[string]interface{}{"schema_version": 4},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid version",
+			operation: "insert",
+			data:      map[string]interface{}{"schema_version": "two"},
+			wantErr:   true,
+		},
+		{
+			name:      "delete",
+			operation: "delete",
+			data:      map[string]interface{}{"fullname": "Ann"},
+			want:      map[string]interface{}{"fullname": "Ann"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			versioned, err := NewVersioned(versionedConfig, nil)
+			if err != nil {
+				t.Fatalf("NewVersioned() error = %v", err)
+			}
+			result, err := versioned.Transform(context.Background(), pipeline.Event{ID: "1", Operation: tt.operation, Data: tt.data})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Transform() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(result.Data, tt.want) {
+				t.Errorf("Data = %v, want %v", result.Data, tt.want)
+			}
+			if got := result.Metadata[pipeline.MetadataSchemaVersion]; !tt.wantErr && got != tt.version {
+				t.Errorf("schema_version metadata = %q, want %q", got, tt.version)
+			}
+		})
+	}
+}
+
+// TestVersionedNext tests that the inner transformer sees migrated documents
+func TestVersionedNext(t *testing.T) {
+	mapper, err := NewFieldMapper(FieldMapperConfig{Mappings: []FieldMapping{{Source: "name", Destination: "display_name"}}})
+	if err != nil {
+		t.Fatalf("NewFieldMapper() error = %v", err)
+	}
+	versioned, err := NewVersioned(versionedConfig, mapper)
+	if err != nil {
+		t.Fatalf("NewVersioned() error = %v", err)
+	}
+	result, err := versioned.Transform(context.Background(), pipeline.Event{ID: "1", Operation: "insert", Data: map[string]interface{}{"fullname": "Ann"}})
+	if err != nil {
+		t.Fatalf("Transform() error = %v", err)
+	}
+	if result.Data["display_name"] != "Ann" {
+		t.Errorf("expected the mapper to see the renamed field, got %v", result.Data)
+	}
+}
+
+// TestMigrationSwap tests that renames apply at once
+func TestMigrationSwap(t *testing.T) {
+	data := map[string]interface{}{"a": 1, "b": 2}
+	Migration{Rename: map[string]string{"a": "b", "b": "a"}}.apply(data)
+	if data["a"] != 2 || data["b"] != 1 {
+		t.Errorf("expected the fields to swap, got %v", data)
+	}
+}
@@ -0,0 +1,257 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// Auth methods
+const (
+	AuthToken      = "token"
+	AuthAppRole    = "approle"
+	AuthKubernetes = "kubernetes"
+)
+
+// DefaultKubernetesTokenPath is where the pod's service account token is mounted
+const DefaultKubernetesTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// Config contains Vault connection and authentication settings
+type Config struct {
+	Address   string // Vault server URL, e.g. https://vault:8200 (default: VAULT_ADDR)
+	Namespace string // Vault Enterprise namespace (optional)
+	Auth      string // token (default), approle or kubernetes
+	MountPath string // Auth method mount path (default: the auth method name)
+
+	Token    string // Token for the token auth method (default: VAULT_TOKEN)
+	RoleID   string // AppRole role ID (default: VAULT_ROLE_ID)
+	SecretID string // AppRole secret ID (default: VAULT_SECRET_ID)
+	Role     string // Kubernetes auth role
+	JWTPath  string // Kubernetes service account token file (default: DefaultKubernetesTokenPath)
+}
+
+// Secret is a secret read from Vault
+type Secret struct {
+	Data          map[string]interface{}
+	LeaseID       string
+	LeaseDuration time.Duration
+	Renewable     bool
+}
+
+// Client is a minimal Vault HTTP API client
+type Client struct {
+	cfg        Config
+	httpClient *http.Client
+	clock      pipeline.Clock
+
+	mu           sync.Mutex // protects the fields below
+	token        string
+	tokenExpires time.Time // zero for tokens that do not expire
+}
+
+// NewClient creates a Vault client. Call Login before reading secrets.
+func NewClient(cfg Config) (*Client, error) {
+	if cfg.Address == "" {
+		cfg.Address = os.Getenv("VAULT_ADDR")
+	}
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("vault address is required")
+	}
+	if cfg.RoleID == "" {
+		cfg.RoleID = os.Getenv("VAULT_ROLE_ID")
+	}
+	if cfg.SecretID == "" {
+		cfg.SecretID = os.Getenv("VAULT_SECRET_ID")
+	}
+	if cfg.Auth == "" {
+		cfg.Auth = AuthToken
+	}
+	if cfg.MountPath == "" {
+		cfg.MountPath = cfg.Auth
+	}
+	if cfg.JWTPath == "" {
+		cfg.JWTPath = DefaultKubernetesTokenPath
+	}
+
+	switch cfg.Auth {
+	case AuthToken:
+		if cfg.Token == "" {
+			cfg.Token = os.Getenv("VAULT_TOKEN")
+		}
+		if cfg.Token == "" {
+			return nil, fmt.Errorf("vault token auth requires a token or VAULT_TOKEN")
+		}
+	case AuthAppRole:
+		if cfg.RoleID == "" || cfg.SecretID == "" {
+			return nil, fmt.Errorf("vault approle auth requires role_id and secret_id")
+		}
+	case AuthKubernetes:
+		if cfg.Role == "" {
+			return nil, fmt.Errorf("vault kubernetes auth requires a role")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported vault auth method: %s", cfg.Auth)
+	}
+
+	return &Client{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+		clock:      pipeline.SystemClock,
+	}, nil
+}
+
+// SetClock sets the time source used to track login token expiry
+func (c *Client) SetClock(clock pipeline.Clock) {
+	c.clock = clock
+}
+
+// Login authenticates with the configured auth method
+func (c *Client) Login(ctx context.Context) error {
+	var body map[string]interface{}
+	switch c.cfg.Auth {
+	case AuthToken:
+		c.mu.Lock()
+		c.token = c.cfg.Token
+		c.mu.Unlock()
+		return nil
+	case AuthAppRole:
+		body = map[string]interface{}{"role_id": c.cfg.RoleID, "secret_id": c.cfg.SecretID}
+	case AuthKubernetes:
+		jwt, err := os.ReadFile(c.cfg.JWTPath)
+		if err != nil {
+			return fmt.Errorf("failed to read kubernetes service account token: %w", err)
+		}
+		body = map[string]interface{}{"role": c.cfg.Role, "jwt": strings.TrimSpace(string(jwt))}
+	}
+
+	var resp response
+	if err := c.do(ctx, http.MethodPost, "auth/"+c.cfg.MountPath+"/login", body, false, &resp); err != nil {
+		return fmt.Errorf("failed to log in to vault: %w", err)
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return fmt.Errorf("failed to log in to vault: no token returned")
+	}
+
+	c.mu.Lock()
+	c.token = resp.Auth.ClientToken
+	c.tokenExpires = time.Time{}
+	if resp.Auth.LeaseDuration > 0 {
+		c.tokenExpires = c.clock.Now().Add(time.Duration(resp.Auth.LeaseDuration) * time.Second)
+	}
+	c.mu.Unlock()
+	return nil
+}
+
+// ensureToken logs in again when the current login token is about to expire
+func (c *Client) ensureToken(ctx context.Context) error {
+	c.mu.Lock()
+	expires := c.tokenExpires
+	c.mu.Unlock()
+	if expires.IsZero() || expires.Sub(c.clock.Now()) > time.Minute {
+		return nil
+	}
+	return c.Login(ctx)
+}
+
+// Read reads a secret. KV version 2 responses are unwrapped so Data holds the secret's keys.
+func (c *Client) Read(ctx context.Context, path string) (*Secret, error) {
+	if err := c.ensureToken(ctx); err != nil {
+		return nil, err
+	}
+	var resp response
+	if err := c.do(ctx, http.MethodGet, path, nil, true, &resp); err != nil {
+		return nil, fmt.Errorf("failed to read vault secret %s: %w", path, err)
+	}
+
+	data := resp.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, hasMetadata := data["metadata"]; hasMetadata {
+			data = nested
+		}
+	}
+	return &Secret{
+		Data:          data,
+		LeaseID:       resp.LeaseID,
+		LeaseDuration: time.Duration(resp.LeaseDuration) * time.Second,
+		Renewable:     resp.Renewable,
+	}, nil
+}
+
+// Renew extends a lease and returns its new duration
+func (c *Client) Renew(ctx context.Context, leaseID string, increment time.Duration) (time.Duration, error) {
+	if err := c.ensureToken(ctx); err != nil {
+		return 0, err
+	}
+	body := map[string]interface{}{"lease_id": leaseID, "increment": int(increment.Seconds())}
+	var resp response
+	if err := c.do(ctx, http.MethodPut, "sys/leases/renew", body, true, &resp); err != nil {
+		return 0, fmt.Errorf("failed to renew vault lease: %w", err)
+	}
+	return time.Duration(resp.LeaseDuration) * time.Second, nil
+}
+
+// response is the envelope of Vault API responses
+type response struct {
+	LeaseID       string                 `json:"lease_id"`
+	LeaseDuration int                    `json:"lease_duration"`
+	Renewable     bool                   `json:"renewable"`
+	Data          map[string]interface{} `json:"data"`
+	Auth          *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+// do sends a request to the Vault API and decodes the response
+func (c *Client) do(ctx context.Context, method, path string, body interface{}, authenticated bool, out *response) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	url := strings.TrimRight(c.cfg.Address, "/") + "/v1/" + strings.TrimLeft(path, "/")
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.cfg.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.cfg.Namespace)
+	}
+	if authenticated {
+		c.mu.Lock()
+		req.Header.Set("X-Vault-Token", c.token)
+		c.mu.Unlock()
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if len(out.Errors) > 0 {
+			return fmt.Errorf("vault returned %s: %s", resp.Status, strings.Join(out.Errors, "; "))
+		}
+		return fmt.Errorf("vault returned %s", resp.Status)
+	}
+	return nil
+}
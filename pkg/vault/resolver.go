@@ -0,0 +1,208 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// renewCheckInterval is how often leases are checked for renewal
+const renewCheckInterval = 10 * time.Second
+
+// Secret references are "vault:<path>#<key>" as a whole value, or
+// "${vault:<path>#<key>}" embedded in a longer string such as a connection string
+var (
+	wholeReference    = regexp.MustCompile(`^vault:([^#\s]+)#([^\s}]+)$`)
+	embeddedReference = regexp.MustCompile(`\$\{vault:([^#\s}]+)#([^\s}]+)\}`)
+)
+
+// HasReferences reports whether a value contains Vault secret references
+func HasReferences(value string) bool {
+	return wholeReference.MatchString(value) || embeddedReference.MatchString(value)
+}
+
+// cachedSecret is a secret together with when its lease must next be renewed
+type cachedSecret struct {
+	secret  *Secret
+	renewAt time.Time // zero for secrets without a lease
+}
+
+// Resolver replaces Vault references with secret values and keeps leased
+// secrets (such as dynamic database credentials) renewed, reading fresh
+// credentials when a lease cannot be extended any further
+type Resolver struct {
+	client *Client
+	logger *log.Logger
+	clock  pipeline.Clock
+
+	mu      sync.Mutex // protects secrets
+	secrets map[string]*cachedSecret
+}
+
+// NewResolver creates a resolver reading secrets with the given client
+func NewResolver(client *Client, logger *log.Logger) *Resolver {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &Resolver{
+		client:  client,
+		logger:  logger,
+		clock:   pipeline.SystemClock,
+		secrets: make(map[string]*cachedSecret),
+	}
+}
+
+// SetClock sets the time source used to schedule lease renewals
+func (r *Resolver) SetClock(clock pipeline.Clock) {
+	r.clock = clock
+}
+
+// Resolve returns value with every Vault reference replaced by the current secret value.
+// Secrets are cached, so resolving again only contacts Vault for secrets not read yet.
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	if m := wholeReference.FindStringSubmatch(value); m != nil {
+		return r.lookup(ctx, m[1], m[2])
+	}
+
+	var resolveErr error
+	resolved := embeddedReference.ReplaceAllStringFunc(value, func(ref string) string {
+		m := embeddedReference.FindStringSubmatch(ref)
+		v, err := r.lookup(ctx, m[1], m[2])
+		if err != nil && resolveErr == nil {
+			resolveErr = err
+		}
+		return v
+	})
+	if resolveErr != nil {
+		return "", resolveErr
+	}
+	return resolved, nil
+}
+
+// ResolveSettings resolves references in every string of a settings map, including nested maps and lists
+func (r *Resolver) ResolveSettings(ctx context.Context, settings map[string]interface{}) error {
+	for key, value := range settings {
+		resolved, err := r.resolveValue(ctx, value)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s: %w", key, err)
+		}
+		settings[key] = resolved
+	}
+	return nil
+}
+
+// resolveValue resolves references in a single settings value
+func (r *Resolver) resolveValue(ctx context.Context, value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return r.Resolve(ctx, v)
+	case map[string]interface{}:
+		return v, r.ResolveSettings(ctx, v)
+	case []interface{}:
+		for i := range v {
+			resolved, err := r.resolveValue(ctx, v[i])
+			if err != nil {
+				return nil, err
+			}
+			v[i] = resolved
+		}
+		return v, nil
+	default:
+		return value, nil
+	}
+}
+
+// lookup returns one key of a secret, reading the secret if it is not cached
+func (r *Resolver) lookup(ctx context.Context, path, key string) (string, error) {
+	r.mu.Lock()
+	cached, ok := r.secrets[path]
+	r.mu.Unlock()
+
+	if !ok {
+		secret, err := r.client.Read(ctx, path)
+		if err != nil {
+			return "", err
+		}
+		cached = r.cache(path, secret)
+	}
+
+	value, ok := cached.secret.Data[key]
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no key %q", path, key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+// cache stores a secret and schedules its renewal at two thirds of its lease
+func (r *Resolver) cache(path string, secret *Secret) *cachedSecret {
+	now := r.clock.Now()
+	cached := &cachedSecret{secret: secret}
+	if secret.LeaseID != "" && secret.LeaseDuration > 0 {
+		cached.renewAt = now.Add(secret.LeaseDuration * 2 / 3)
+	}
+
+	r.mu.Lock()
+	r.secrets[path] = cached
+	r.mu.Unlock()
+	return cached
+}
+
+// Run renews leases until the context is cancelled
+func (r *Resolver) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.clock.After(renewCheckInterval):
+			r.renewDue(ctx)
+		}
+	}
+}
+
+// renewDue renews every lease that reached its renewal time. A lease that
+// cannot be renewed, or whose renewal no longer extends it (max TTL reached),
+// is replaced by reading the secret again.
+func (r *Resolver) renewDue(ctx context.Context) {
+	now := r.clock.Now()
+
+	r.mu.Lock()
+	due := make(map[string]*cachedSecret)
+	for path, cached := range r.secrets {
+		if !cached.renewAt.IsZero() && !now.Before(cached.renewAt) {
+			due[path] = cached
+		}
+	}
+	r.mu.Unlock()
+
+	for path, cached := range due {
+		secret := cached.secret
+		if secret.Renewable {
+			duration, err := r.client.Renew(ctx, secret.LeaseID, secret.LeaseDuration)
+			if err == nil && duration >= secret.LeaseDuration/2 {
+				r.mu.Lock()
+				cached.renewAt = now.Add(duration * 2 / 3)
+				r.mu.Unlock()
+				continue
+			}
+			if err != nil {
+				r.logger.Printf("Failed to renew vault lease for %s, reading new credentials: %v", path, err)
+			}
+		}
+
+		fresh, err := r.client.Read(ctx, path)
+		if err != nil {
+			r.logger.Printf("Failed to rotate vault secret %s: %v", path, err)
+			continue
+		}
+		r.cache(path, fresh)
+		r.logger.Printf("Rotated vault secret %s, new connections will use it", path)
+	}
+}
@@ -0,0 +1,215 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/IEatCodeDaily/data-pipe/pkg/pipeline"
+)
+
+// fakeVault serves AppRole login, a KV v2 secret and rotating dynamic database credentials
+type fakeVault struct {
+	mu         sync.Mutex
+	creds      int  // dynamic credential generation
+	renewable  bool // whether leases can still be renewed
+	renewCalls int
+	logins     int
+}
+
+func (f *fakeVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path != "/v1/auth/approle/login" && r.Header.Get("X-Vault-Token") != "s.token" {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"errors":["permission denied"]}`)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v1/auth/approle/login":
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["role_id"] != "role" || body["secret_id"] != "secret" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		f.logins++
+		fmt.Fprint(w, `{"auth":{"client_token":"s.token","lease_duration":3600}}`)
+	case "/v1/secret/data/datapipe":
+		fmt.Fprint(w, `{"data":{"data":{"password":"p@ss","port":5432},"metadata":{"version":1}}}`)
+	case "/v1/database/creds/datapipe":
+		f.creds++
+		fmt.Fprintf(w, `{"lease_id":"database/creds/datapipe/%d","lease_duration":300,"renewable":true,"data":{"username":"v-user-%d","password":"pw-%d"}}`, f.creds, f.creds, f.creds)
+	case "/v1/sys/leases/renew":
+		f.renewCalls++
+		if !f.renewable {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"errors":["lease expired"]}`)
+			return
+		}
+		fmt.Fprint(w, `{"lease_duration":300,"renewable":true}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestResolver(t *testing.T, vault *fakeVault) *Resolver {
+	t.Helper()
+	server := httptest.NewServer(vault)
+	t.Cleanup(server.Close)
+
+	client, err := NewClient(Config{Address: server.URL, Auth: AuthAppRole, RoleID: "role", SecretID: "secret"})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if err := client.Login(context.Background()); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	return NewResolver(client, nil)
+}
+
+// TestResolveSettings tests whole and embedded references, including nested values
+func TestResolveSettings(t *testing.T) {
+	r := newTestResolver(t, &fakeVault{})
+	settings := map[string]interface{}{
+		"password":          "vault:secret/data/datapipe#password",
+		"connection_string": "postgres://${vault:database/creds/datapipe#username}:${vault:database/creds/datapipe#password}@db/app",
+		"nested":            map[string]interface{}{"port": "vault:secret/data/datapipe#port"},
+		"list":              []interface{}{"plain", "vault:secret/data/datapipe#password"},
+		"count":             3,
+	}
+	if err := r.ResolveSettings(context.Background(), settings); err != nil {
+		t.Fatalf("ResolveSettings() error = %v", err)
+	}
+
+	if settings["password"] != "p@ss" {
+		t.Errorf("expected password to resolve, got %v", settings["password"])
+	}
+	if settings["connection_string"] != "postgres://v-user-1:pw-1@db/app" {
+		t.Errorf("expected one credential pair from a single read, got %v", settings["connection_string"])
+	}
+	if settings["nested"].(map[string]interface{})["port"] != "5432" {
+		t.Errorf("expected nested port to resolve, got %v", settings["nested"])
+	}
+	if settings["list"].([]interface{})[1] != "p@ss" || settings["count"] != 3 {
+		t.Errorf("unexpected settings %v", settings)
+	}
+
+	if _, err := r.Resolve(context.Background(), "vault:secret/data/datapipe#missing"); err == nil {
+		t.Error("expected an error for a missing key")
+	}
+	if _, err := r.Resolve(context.Background(), "vault:secret/data/unknown#key"); err == nil {
+		t.Error("expected an error for a missing secret")
+	}
+}
+
+// TestResolverRenewsAndRotates tests that leases are renewed and replaced once renewal fails
+func TestResolverRenewsAndRotates(t *testing.T) {
+	fake := &fakeVault{renewable: true}
+	r := newTestResolver(t, fake)
+	clock := pipeline.NewManualClock(time.Unix(1700000000, 0))
+	r.SetClock(clock)
+	ctx := context.Background()
+	ref := "${vault:database/creds/datapipe#username}"
+
+	if got, _ := r.Resolve(ctx, ref); got != "v-user-1" {
+		t.Fatalf("expected v-user-1, got %s", got)
+	}
+
+	r.renewDue(ctx)
+	if fake.renewCalls != 0 {
+		t.Fatalf("expected no renewal before two thirds of the lease, got %d", fake.renewCalls)
+	}
+
+	clock.Advance(201 * time.Second)
+	r.renewDue(ctx)
+	if got, _ := r.Resolve(ctx, ref); fake.renewCalls != 1 || got != "v-user-1" {
+		t.Fatalf("expected lease to be renewed in place, got %d renewals and %s", fake.renewCalls, got)
+	}
+
+	fake.mu.Lock()
+	fake.renewable = false
+	fake.mu.Unlock()
+	clock.Advance(201 * time.Second)
+	r.renewDue(ctx)
+	if got, _ := r.Resolve(ctx, ref); got != "v-user-2" {
+		t.Errorf("expected rotated credentials v-user-2, got %s", got)
+	}
+}
+
+// TestClientRenewsLoginToken tests that the client logs in again shortly before its token expires
+func TestClientRenewsLoginToken(t *testing.T) {
+	fake := &fakeVault{}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	client, err := NewClient(Config{Address: server.URL, Auth: AuthAppRole, RoleID: "role", SecretID: "secret"})
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	clock := pipeline.NewManualClock(time.Unix(1700000000, 0))
+	client.SetClock(clock)
+	ctx := context.Background()
+	if err := client.Login(ctx); err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+
+	if _, err := client.Read(ctx, "secret/data/datapipe"); err != nil || fake.logins != 1 {
+		t.Fatalf("expected a read without logging in again, got %d logins and error %v", fake.logins, err)
+	}
+
+	clock.Advance(59*time.Minute + time.Second)
+	if _, err := client.Read(ctx, "secret/data/datapipe"); err != nil || fake.logins != 2 {
+		t.Errorf("expected a new login within a minute of expiry, got %d logins and error %v", fake.logins, err)
+	}
+}
+
+// TestNewClientValidation tests auth method validation
+func TestNewClientValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "token", cfg: Config{Address: "http://vault", Token: "t"}},
+		{name: "missing token", cfg: Config{Address: "http://vault", Auth: AuthToken}, wantErr: true},
+		{name: "approle", cfg: Config{Address: "http://vault", Auth: AuthAppRole, RoleID: "r", SecretID: "s"}},
+		{name: "approle without secret", cfg: Config{Address: "http://vault", Auth: AuthAppRole, RoleID: "r"}, wantErr: true},
+		{name: "kubernetes", cfg: Config{Address: "http://vault", Auth: AuthKubernetes, Role: "data-pipe"}},
+		{name: "unknown method", cfg: Config{Address: "http://vault", Auth: "ldap"}, wantErr: true},
+	}
+	t.Setenv("VAULT_ADDR", "")
+	t.Setenv("VAULT_TOKEN", "")
+	t.Setenv("VAULT_ROLE_ID", "")
+	t.Setenv("VAULT_SECRET_ID", "")
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClient(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestHasReferences tests reference detection
+func TestHasReferences(t *testing.T) {
+	for value, want := range map[string]bool{
+		"vault:secret/data/x#password":          true,
+		"postgres://u:${vault:db/creds/r#p}@db": true,
+		"postgres://u:p@db":                     false,
+		"vault:missing-key":                     false,
+	} {
+		if got := HasReferences(value); got != want {
+			t.Errorf("HasReferences(%q) = %v, want %v", value, got, want)
+		}
+	}
+}